@@ -185,6 +185,7 @@ func (a *APIBackend) GetAPIs(filterSystem *filters.FilterSystem) []rpc.API {
 	})
 
 	apis = append(apis, tracers.APIs(a)...)
+	apis = append(apis, tracers.BrontesAPIs(a, a.b.config.Brontes.tracersConfig())...)
 
 	return apis
 }
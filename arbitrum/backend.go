@@ -70,6 +70,9 @@ func NewBackend(stack *node.Node, config *Config, chainDb ethdb.Database, publis
 		return nil, nil, err
 	}
 	backend.filterSystem = filterSystem
+	if err := registerBrontes(stack, backend.apiBackend, config.Brontes.tracersConfig()); err != nil {
+		return nil, nil, err
+	}
 	return backend, filterSystem, nil
 }
 
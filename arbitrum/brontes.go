@@ -0,0 +1,115 @@
+package arbitrum
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/node"
+	flag "github.com/spf13/pflag"
+)
+
+// BrontesConfig holds the settings of the brontes tracing API, see
+// tracers.BrontesConfig.
+type BrontesConfig struct {
+	Authenticated  bool          `koanf:"authrpc"`
+	RateLimit      float64       `koanf:"rate-limit"`
+	MaxConcurrent  int           `koanf:"max-concurrent"`
+	MaxBlocks      uint64        `koanf:"max-blocks"`
+	MaxGas         uint64        `koanf:"max-gas"`
+	MaxReexec      uint64        `koanf:"max-reexec"`
+	Reexec         uint64        `koanf:"reexec"`
+	Cache          int           `koanf:"cache"`
+	CacheDir       string        `koanf:"cache-dir"`
+	CacheDirSize   int           `koanf:"cache-dir-size"`
+	MaxSessions    int           `koanf:"max-sessions"`
+	SessionTimeout time.Duration `koanf:"session-timeout"`
+	ABIDir         string        `koanf:"abi-dir"`
+	AddressBook    string        `koanf:"address-book"`
+	FetchABIs      bool          `koanf:"fetch-abis"`
+	SourcifyURL    string        `koanf:"sourcify-url"`
+	EtherscanURL   string        `koanf:"etherscan-url"`
+	EtherscanKey   string        `koanf:"etherscan-key"`
+	ABICacheDir    string        `koanf:"abi-cache-dir"`
+	SelectorCache  string        `koanf:"selector-cache"`
+	SelectorURL    string        `koanf:"selector-url"`
+	BackfillDir    string        `koanf:"backfill-dir"`
+}
+
+var DefaultBrontesConfig = BrontesConfig{}
+
+func BrontesConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".authrpc", DefaultBrontesConfig.Authenticated, "serve the brontes API only on the JWT-authenticated RPC endpoint")
+	f.Float64(prefix+".rate-limit", DefaultBrontesConfig.RateLimit, "maximum number of brontes requests accepted per second (0 = unlimited)")
+	f.Int(prefix+".max-concurrent", DefaultBrontesConfig.MaxConcurrent, "maximum number of brontes requests served concurrently (0 = unlimited)")
+	f.Uint64(prefix+".max-blocks", DefaultBrontesConfig.MaxBlocks, "maximum number of blocks traced by a single brontes request (0 = 100000)")
+	f.Uint64(prefix+".max-gas", DefaultBrontesConfig.MaxGas, "maximum total gas of the blocks and calls traced by a single brontes request (0 = unlimited)")
+	f.Uint64(prefix+".max-reexec", DefaultBrontesConfig.MaxReexec, "maximum number of blocks a brontes request may re-execute to regenerate state (0 = unlimited)")
+	f.Uint64(prefix+".reexec", DefaultBrontesConfig.Reexec, "number of blocks a brontes request re-executes to regenerate missing historical state by default (0 = 128)")
+	f.Int(prefix+".cache", DefaultBrontesConfig.Cache, "megabytes of memory allocated to caching recently computed brontes traces (0 = disabled)")
+	f.String(prefix+".cache-dir", DefaultBrontesConfig.CacheDir, "directory to additionally cache brontes traces in on disk")
+	f.Int(prefix+".cache-dir-size", DefaultBrontesConfig.CacheDirSize, "megabytes of brontes traces cached on disk, the oldest being evicted beyond (0 = 1024)")
+	f.Int(prefix+".max-sessions", DefaultBrontesConfig.MaxSessions, "maximum number of brontes simulation sessions open at once (0 = 16)")
+	f.Duration(prefix+".session-timeout", DefaultBrontesConfig.SessionTimeout, "time after which an idle brontes simulation session is discarded (0 = 5m)")
+	f.String(prefix+".abi-dir", DefaultBrontesConfig.ABIDir, "directory of <address>.json ABI files brontes traces decode calls with")
+	f.String(prefix+".address-book", DefaultBrontesConfig.AddressBook, "JSON file mapping addresses to the names attached to brontes traces")
+	f.Bool(prefix+".fetch-abis", DefaultBrontesConfig.FetchABIs, "fetch the verified ABIs of contracts missing from the ABI directory for brontes traces (requires outbound connections)")
+	f.String(prefix+".sourcify-url", DefaultBrontesConfig.SourcifyURL, "Sourcify server verified ABIs are fetched from (default = sourcify.dev)")
+	f.String(prefix+".etherscan-url", DefaultBrontesConfig.EtherscanURL, "Etherscan compatible API queried for the ABIs Sourcify has no match for")
+	f.String(prefix+".etherscan-key", DefaultBrontesConfig.EtherscanKey, "API key sent to the Etherscan compatible API")
+	f.String(prefix+".abi-cache-dir", DefaultBrontesConfig.ABICacheDir, "directory the fetched ABIs are persisted in")
+	f.String(prefix+".selector-cache", DefaultBrontesConfig.SelectorCache, "directory of the function signature cache used to decode calls to contracts without a known ABI")
+	f.String(prefix+".selector-url", DefaultBrontesConfig.SelectorURL, "4byte.directory compatible signature database unknown selectors are looked up at (default = www.4byte.directory)")
+	f.String(prefix+".backfill-dir", DefaultBrontesConfig.BackfillDir, "directory the traces of the blocks queued with brontes_queueBackfill are written to (backfills are disabled if unset)")
+}
+
+// tracersConfig converts the config into the settings of the tracers package.
+func (c *BrontesConfig) tracersConfig() *tracers.BrontesConfig {
+	return &tracers.BrontesConfig{
+		Authenticated:    c.Authenticated,
+		RateLimit:        c.RateLimit,
+		MaxConcurrent:    c.MaxConcurrent,
+		MaxBlocks:        c.MaxBlocks,
+		MaxGas:           c.MaxGas,
+		MaxReexec:        c.MaxReexec,
+		Reexec:           c.Reexec,
+		CacheSize:        uint64(c.Cache) * 1024 * 1024,
+		CacheDir:         c.CacheDir,
+		CacheDirSize:     uint64(c.CacheDirSize) * 1024 * 1024,
+		MaxSessions:      c.MaxSessions,
+		SessionTimeout:   c.SessionTimeout,
+		ABIDir:           c.ABIDir,
+		AddressBook:      c.AddressBook,
+		FetchABIs:        c.FetchABIs,
+		SourcifyURL:      c.SourcifyURL,
+		EtherscanURL:     c.EtherscanURL,
+		EtherscanAPIKey:  c.EtherscanKey,
+		ABICacheDir:      c.ABICacheDir,
+		SelectorCacheDir: c.SelectorCache,
+		SelectorURL:      c.SelectorURL,
+		BackfillDir:      c.BackfillDir,
+	}
+}
+
+// registerBrontes registers the node-wide services the brontes API relies on,
+// as the eth backend does for geth.
+func registerBrontes(stack *node.Node, backend tracers.Backend, config *tracers.BrontesConfig) error {
+	tracers.RegisterBrontesOrderflow(backend)
+	tracers.RegisterBrontesRetracer(backend, config)
+	if err := tracers.RegisterBrontesABIs(stack, config); err != nil {
+		return fmt.Errorf("failed to load the brontes abi directory: %w", err)
+	}
+	if err := tracers.RegisterBrontesAddressBook(stack, config); err != nil {
+		return fmt.Errorf("failed to load the brontes address book: %w", err)
+	}
+	if err := tracers.RegisterBrontesABIFetcher(stack, backend, config); err != nil {
+		return fmt.Errorf("failed to create the brontes abi fetcher: %w", err)
+	}
+	if err := tracers.RegisterBrontesSelectorCache(stack, config); err != nil {
+		return fmt.Errorf("failed to open the brontes selector cache: %w", err)
+	}
+	if err := tracers.RegisterBrontesBackfill(stack, backend, config); err != nil {
+		return fmt.Errorf("failed to open the brontes backfill: %w", err)
+	}
+	return nil
+}
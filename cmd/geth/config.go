@@ -218,6 +218,12 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	if ctx.IsSet(utils.GraphQLEnabledFlag.Name) {
 		utils.RegisterGraphQLService(stack, backend, filterSystem, &cfg.Node)
 	}
+	// Configure the brontes tracing API and the trace export if requested.
+	brontesCfg := utils.MakeBrontesConfig(ctx)
+	utils.RegisterBrontesService(stack, backend, brontesCfg)
+	if ctx.IsSet(utils.BrontesExportEnabledFlag.Name) {
+		utils.RegisterBrontesExportService(stack, backend, brontesCfg, &cfg.Node)
+	}
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, backend, cfg.Ethstats.URL)
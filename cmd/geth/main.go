@@ -169,6 +169,29 @@ var (
 		utils.GraphQLEnabledFlag,
 		utils.GraphQLCORSDomainFlag,
 		utils.GraphQLVirtualHostsFlag,
+		utils.BrontesExportEnabledFlag,
+		utils.BrontesAuthFlag,
+		utils.BrontesRateLimitFlag,
+		utils.BrontesMaxConcurrentFlag,
+		utils.BrontesMaxBlocksFlag,
+		utils.BrontesMaxGasFlag,
+		utils.BrontesReexecFlag,
+		utils.BrontesCacheFlag,
+		utils.BrontesCacheDirFlag,
+		utils.BrontesCacheDirSizeFlag,
+		utils.BrontesMaxReexecFlag,
+		utils.BrontesMaxSessionsFlag,
+		utils.BrontesSessionTimeoutFlag,
+		utils.BrontesABIDirFlag,
+		utils.BrontesAddressBookFlag,
+		utils.BrontesFetchABIsFlag,
+		utils.BrontesSourcifyURLFlag,
+		utils.BrontesEtherscanURLFlag,
+		utils.BrontesEtherscanKeyFlag,
+		utils.BrontesABICacheDirFlag,
+		utils.BrontesSelectorCacheFlag,
+		utils.BrontesSelectorURLFlag,
+		utils.BrontesBackfillDirFlag,
 		utils.HTTPApiFlag,
 		utils.HTTPPathPrefixFlag,
 		utils.WSEnabledFlag,
@@ -660,6 +660,122 @@ var (
 		Value:    strings.Join(node.DefaultConfig.GraphQLVirtualHosts, ","),
 		Category: flags.APICategory,
 	}
+	BrontesExportEnabledFlag = &cli.BoolFlag{
+		Name:     "brontes.export",
+		Usage:    "Enable the brontes NDJSON trace export on the HTTP-RPC server under " + tracers.BrontesExportPath,
+		Category: flags.APICategory,
+	}
+	BrontesAuthFlag = &cli.BoolFlag{
+		Name:     "brontes.authrpc",
+		Usage:    "Serve the brontes API only on the JWT-authenticated RPC endpoint (see --authrpc.jwtsecret)",
+		Category: flags.APICategory,
+	}
+	BrontesRateLimitFlag = &cli.Float64Flag{
+		Name:     "brontes.ratelimit",
+		Usage:    "Maximum number of brontes requests accepted per second (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	BrontesMaxConcurrentFlag = &cli.IntFlag{
+		Name:     "brontes.maxconcurrent",
+		Usage:    "Maximum number of brontes requests served concurrently (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	BrontesMaxBlocksFlag = &cli.Uint64Flag{
+		Name:     "brontes.maxblocks",
+		Usage:    "Maximum number of blocks traced by a single brontes request (0 = 100000)",
+		Category: flags.APICategory,
+	}
+	BrontesMaxGasFlag = &cli.Uint64Flag{
+		Name:     "brontes.maxgas",
+		Usage:    "Maximum total gas of the blocks and calls traced by a single brontes request (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	BrontesReexecFlag = &cli.Uint64Flag{
+		Name:     "brontes.reexec",
+		Usage:    "Number of blocks a brontes request re-executes to regenerate missing historical state by default (0 = 128)",
+		Category: flags.APICategory,
+	}
+	BrontesCacheFlag = &cli.IntFlag{
+		Name:     "brontes.cache",
+		Usage:    "Megabytes of memory allocated to caching recently computed brontes traces (0 = disabled)",
+		Category: flags.APICategory,
+	}
+	BrontesCacheDirFlag = &flags.DirectoryFlag{
+		Name:     "brontes.cachedir",
+		Usage:    "Directory to additionally cache brontes traces in on disk",
+		Category: flags.APICategory,
+	}
+	BrontesCacheDirSizeFlag = &cli.IntFlag{
+		Name:     "brontes.cachedirsize",
+		Usage:    "Megabytes of brontes traces cached on disk, the oldest being evicted beyond (0 = 1024)",
+		Category: flags.APICategory,
+	}
+	BrontesMaxReexecFlag = &cli.Uint64Flag{
+		Name:     "brontes.maxreexec",
+		Usage:    "Maximum number of blocks a brontes request may re-execute to regenerate state (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	BrontesMaxSessionsFlag = &cli.IntFlag{
+		Name:     "brontes.maxsessions",
+		Usage:    "Maximum number of brontes simulation sessions open at once (0 = 16)",
+		Category: flags.APICategory,
+	}
+	BrontesSessionTimeoutFlag = &cli.DurationFlag{
+		Name:     "brontes.sessiontimeout",
+		Usage:    "Time after which an idle brontes simulation session is discarded (0 = 5m)",
+		Category: flags.APICategory,
+	}
+	BrontesABIDirFlag = &flags.DirectoryFlag{
+		Name:     "brontes.abidir",
+		Usage:    "Directory of <address>.json ABI files brontes traces decode calls with",
+		Category: flags.APICategory,
+	}
+	BrontesAddressBookFlag = &cli.StringFlag{
+		Name:      "brontes.addressbook",
+		Usage:     "JSON file mapping addresses to the names attached to brontes traces",
+		TakesFile: true,
+		Category:  flags.APICategory,
+	}
+	BrontesFetchABIsFlag = &cli.BoolFlag{
+		Name:     "brontes.fetchabis",
+		Usage:    "Fetch the verified ABIs of contracts missing from --brontes.abidir for brontes traces (requires outbound connections)",
+		Category: flags.APICategory,
+	}
+	BrontesSourcifyURLFlag = &cli.StringFlag{
+		Name:     "brontes.sourcifyurl",
+		Usage:    "Sourcify server verified ABIs are fetched from (default = sourcify.dev)",
+		Category: flags.APICategory,
+	}
+	BrontesEtherscanURLFlag = &cli.StringFlag{
+		Name:     "brontes.etherscanurl",
+		Usage:    "Etherscan compatible API queried for the ABIs Sourcify has no match for",
+		Category: flags.APICategory,
+	}
+	BrontesEtherscanKeyFlag = &cli.StringFlag{
+		Name:     "brontes.etherscankey",
+		Usage:    "API key sent to the Etherscan compatible API",
+		Category: flags.APICategory,
+	}
+	BrontesABICacheDirFlag = &flags.DirectoryFlag{
+		Name:     "brontes.abicachedir",
+		Usage:    "Directory the fetched ABIs are persisted in",
+		Category: flags.APICategory,
+	}
+	BrontesSelectorCacheFlag = &flags.DirectoryFlag{
+		Name:     "brontes.selectorcache",
+		Usage:    "Directory of the function signature cache used to decode calls to contracts without a known ABI",
+		Category: flags.APICategory,
+	}
+	BrontesSelectorURLFlag = &cli.StringFlag{
+		Name:     "brontes.selectorurl",
+		Usage:    "4byte.directory compatible signature database unknown selectors are looked up at (default = www.4byte.directory)",
+		Category: flags.APICategory,
+	}
+	BrontesBackfillDirFlag = &flags.DirectoryFlag{
+		Name:     "brontes.backfilldir",
+		Usage:    "Directory the traces of the blocks queued with brontes_queueBackfill are written to (backfills are disabled if unset)",
+		Category: flags.APICategory,
+	}
 	WSEnabledFlag = &cli.BoolFlag{
 		Name:     "ws",
 		Usage:    "Enable the WS-RPC server",
@@ -1909,6 +2025,72 @@ func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, filterSyst
 	}
 }
 
+// MakeBrontesConfig creates the access settings of the brontes endpoints from
+// the set command line flags.
+func MakeBrontesConfig(ctx *cli.Context) *tracers.BrontesConfig {
+	return &tracers.BrontesConfig{
+		Authenticated: ctx.Bool(BrontesAuthFlag.Name),
+		RateLimit:     ctx.Float64(BrontesRateLimitFlag.Name),
+		MaxConcurrent: ctx.Int(BrontesMaxConcurrentFlag.Name),
+		MaxBlocks:     ctx.Uint64(BrontesMaxBlocksFlag.Name),
+		MaxGas:        ctx.Uint64(BrontesMaxGasFlag.Name),
+		MaxReexec:     ctx.Uint64(BrontesMaxReexecFlag.Name),
+		Reexec:        ctx.Uint64(BrontesReexecFlag.Name),
+		CacheSize:     uint64(ctx.Int(BrontesCacheFlag.Name)) * 1024 * 1024,
+		CacheDir:      ctx.String(BrontesCacheDirFlag.Name),
+		CacheDirSize:  uint64(ctx.Int(BrontesCacheDirSizeFlag.Name)) * 1024 * 1024,
+
+		MaxSessions:    ctx.Int(BrontesMaxSessionsFlag.Name),
+		SessionTimeout: ctx.Duration(BrontesSessionTimeoutFlag.Name),
+
+		ABIDir:      ctx.String(BrontesABIDirFlag.Name),
+		AddressBook: ctx.String(BrontesAddressBookFlag.Name),
+
+		FetchABIs:       ctx.Bool(BrontesFetchABIsFlag.Name),
+		SourcifyURL:     ctx.String(BrontesSourcifyURLFlag.Name),
+		EtherscanURL:    ctx.String(BrontesEtherscanURLFlag.Name),
+		EtherscanAPIKey: ctx.String(BrontesEtherscanKeyFlag.Name),
+		ABICacheDir:     ctx.String(BrontesABICacheDirFlag.Name),
+
+		SelectorCacheDir: ctx.String(BrontesSelectorCacheFlag.Name),
+		SelectorURL:      ctx.String(BrontesSelectorURLFlag.Name),
+
+		BackfillDir: ctx.String(BrontesBackfillDirFlag.Name),
+	}
+}
+
+// RegisterBrontesService adds the brontes tracing API to the node.
+func RegisterBrontesService(stack *node.Node, backend tracers.Backend, cfg *tracers.BrontesConfig) {
+	stack.RegisterAPIs(tracers.BrontesAPIs(backend, cfg))
+	tracers.RegisterBrontesOrderflow(backend)
+	tracers.RegisterBrontesRetracer(backend, cfg)
+	if err := tracers.RegisterBrontesABIs(stack, cfg); err != nil {
+		Fatalf("Failed to load the brontes abi directory: %v", err)
+	}
+	if err := tracers.RegisterBrontesAddressBook(stack, cfg); err != nil {
+		Fatalf("Failed to load the brontes address book: %v", err)
+	}
+	if err := tracers.RegisterBrontesABIFetcher(stack, backend, cfg); err != nil {
+		Fatalf("Failed to create the brontes abi fetcher: %v", err)
+	}
+	if err := tracers.RegisterBrontesSelectorCache(stack, cfg); err != nil {
+		Fatalf("Failed to open the brontes selector cache: %v", err)
+	}
+	if err := tracers.RegisterBrontesBackfill(stack, backend, cfg); err != nil {
+		Fatalf("Failed to open the brontes backfill: %v", err)
+	}
+}
+
+// RegisterBrontesExportService adds the brontes trace export endpoint to the
+// HTTP server of the node.
+func RegisterBrontesExportService(stack *node.Node, backend tracers.Backend, brontesCfg *tracers.BrontesConfig, cfg *node.Config) {
+	// The export is served on the unauthenticated HTTP server.
+	if brontesCfg.Authenticated {
+		Fatalf("The brontes export cannot be enabled with --%s", BrontesAuthFlag.Name)
+	}
+	tracers.RegisterBrontesExport(stack, backend, brontesCfg, cfg.HTTPCors, cfg.HTTPVirtualHosts)
+}
+
 // RegisterFilterAPI adds the eth log filtering RPC API to the node.
 func RegisterFilterAPI(stack *node.Node, backend ethapi.Backend, ethcfg *ethconfig.Config) *filters.FilterSystem {
 	filterSystem := filters.NewFilterSystem(backend, filters.Config{
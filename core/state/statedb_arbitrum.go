@@ -217,6 +217,22 @@ func (s *StateDB) GetSelfDestructs() []common.Address {
 	return selfDestructs
 }
 
+// GetDirtyAccounts returns the accounts changed since the last commit to the
+// database, whether the changes are pending in the journal of the current
+// transaction or were finalised.
+func (s *StateDB) GetDirtyAccounts() []common.Address {
+	dirty := make([]common.Address, 0, len(s.journal.dirties)+len(s.mutations))
+	for addr := range s.journal.dirties {
+		dirty = append(dirty, addr)
+	}
+	for addr := range s.mutations {
+		if _, ok := s.journal.dirties[addr]; !ok {
+			dirty = append(dirty, addr)
+		}
+	}
+	return dirty
+}
+
 // making the function public to be used by external tests
 func ForEachStorage(s *StateDB, addr common.Address, cb func(key, value common.Hash) bool) error {
 	return forEachStorage(s, addr, cb)
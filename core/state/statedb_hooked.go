@@ -373,3 +373,7 @@ func (s *hookedStateDB) GetSelfDestructs() []common.Address {
 func (s *hookedStateDB) GetCurrentTxLogs() []*types.Log {
 	return s.inner.GetCurrentTxLogs()
 }
+
+func (s *hookedStateDB) GetDirtyAccounts() []common.Address {
+	return s.inner.GetDirtyAccounts()
+}
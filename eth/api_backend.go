@@ -430,6 +430,12 @@ func (b *EthAPIBackend) CurrentHeader() *types.Header {
 	return b.eth.blockchain.CurrentHeader()
 }
 
+// HasState reports whether the state trie with the given root is present,
+// without regenerating it.
+func (b *EthAPIBackend) HasState(root common.Hash) bool {
+	return b.eth.blockchain.HasState(root)
+}
+
 func (b *EthAPIBackend) StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, readOnly bool, preferDisk bool) (*state.StateDB, tracers.StateReleaseFunc, error) {
 	return b.eth.stateAtBlock(ctx, block, reexec, base, nil, readOnly, preferDisk)
 }
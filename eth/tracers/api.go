@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/eth/tracers/logger"
@@ -162,6 +163,10 @@ type TraceConfig struct {
 	// Config specific to given tracer. Note struct logger
 	// config are historically embedded in main object.
 	TracerConfig json.RawMessage
+
+	// deferEncoding makes tracers supporting it return a deferredResult,
+	// leaving the encoding of their results to the caller.
+	deferEncoding bool
 }
 
 // TraceCallConfig is the config for traceCall API. It holds one more
@@ -911,6 +916,42 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 // the trace will be conducted on the state after executing the specified transaction
 // within the specified block.
 func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig) (interface{}, error) {
+	return api.traceCall(ctx, args, blockNrOrHash, config, nil)
+}
+
+// traceCall traces a call like TraceCall, relaxing the execution rules
+// according to the EVM overrides if given.
+func (api *API) traceCall(ctx context.Context, args ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig, overrides *EVMOverrides) (interface{}, error) {
+	env, err := api.callEnv(ctx, blockNrOrHash, config, overrides)
+	if err != nil {
+		return nil, err
+	}
+	defer env.release()
+
+	msg, tx, vmctx, err := env.message(api, args, overrides)
+	if err != nil {
+		return nil, err
+	}
+	var traceConfig *TraceConfig
+	if config != nil {
+		traceConfig = &config.TraceConfig
+	}
+	return api.traceTxWithPrecompiles(ctx, tx, msg, new(Context), vmctx, env.statedb, traceConfig, env.precompiles, nil)
+}
+
+// callEnv is the environment calls are traced in, on top of the state of a
+// block.
+type callEnv struct {
+	block       *types.Block
+	vmctx       vm.BlockContext
+	statedb     *state.StateDB
+	precompiles vm.PrecompiledContracts // nil for the default set of the EVM
+	release     StateReleaseFunc
+}
+
+// callEnv prepares the environment of calls traced on top of the given
+// block, applying the overrides of the config.
+func (api *API) callEnv(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig, overrides *EVMOverrides) (*callEnv, error) {
 	// Try to retrieve the specified block
 	var (
 		err     error
@@ -950,47 +991,68 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 	if err != nil {
 		return nil, err
 	}
-	defer release()
 
 	vmctx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+	var precompiles vm.PrecompiledContracts
 	// Apply the customization rules if required.
 	if config != nil {
 		config.BlockOverrides.Apply(&vmctx)
 		rules := api.backend.ChainConfig().Rules(vmctx.BlockNumber, vmctx.Random != nil, vmctx.Time, vmctx.ArbOSVersion)
 
-		precompiles := vm.ActivePrecompiledContracts(rules)
+		precompiles = vm.ActivePrecompiledContracts(rules)
 		if err := config.StateOverrides.Apply(statedb, precompiles); err != nil {
+			release()
 			return nil, err
 		}
 	}
-	// Execute the trace
+	// Stub precompiles for what-if simulations, other calls are executed with
+	// the default precompiles of the EVM.
+	if overrides != nil && len(overrides.Precompiles) > 0 {
+		if precompiles == nil {
+			rules := api.backend.ChainConfig().Rules(vmctx.BlockNumber, vmctx.Random != nil, vmctx.Time, vmctx.ArbOSVersion)
+			precompiles = vm.ActivePrecompiledContracts(rules)
+		}
+		overrides.applyPrecompiles(precompiles)
+	} else {
+		precompiles = nil
+	}
+	return &callEnv{block: block, vmctx: vmctx, statedb: statedb, precompiles: precompiles, release: release}, nil
+}
+
+// message converts the call arguments into the message executed in the
+// environment, returning the block context adjusted to it.
+func (env *callEnv) message(api *API, args ethapi.TransactionArgs, overrides *EVMOverrides) (*core.Message, *types.Transaction, vm.BlockContext, error) {
+	vmctx := env.vmctx
 	if err := args.CallDefaults(api.backend.RPCGasCap(), vmctx.BaseFee, api.backend.ChainConfig().ChainID); err != nil {
-		return nil, err
+		return nil, nil, vmctx, err
 	}
 	var (
-		msg         = args.ToMessage(vmctx.BaseFee, api.backend.RPCGasCap(), block.Header(), statedb, core.MessageEthcallMode, true, true)
-		tx          = args.ToTransaction(types.LegacyTxType)
-		traceConfig *TraceConfig
+		msg = args.ToMessage(vmctx.BaseFee, api.backend.RPCGasCap(), env.block.Header(), env.statedb, core.MessageEthcallMode, true, true)
+		tx  = args.ToTransaction(types.LegacyTxType)
 	)
 	// Lower the basefee to 0 to avoid breaking EVM
 	// invariants (basefee < feecap).
-	if msg.GasPrice.Sign() == 0 {
+	if msg.GasPrice.Sign() == 0 || (overrides != nil && overrides.NoBaseFee && vmctx.BaseFee != nil) {
 		vmctx.BaseFeeInBlock = new(big.Int).Set(vmctx.BaseFee)
 		vmctx.BaseFee = new(big.Int)
 	}
 	if msg.BlobGasFeeCap != nil && msg.BlobGasFeeCap.BitLen() == 0 {
 		vmctx.BlobBaseFee = new(big.Int)
 	}
-	if config != nil {
-		traceConfig = &config.TraceConfig
-	}
-	return api.traceTx(ctx, tx, msg, new(Context), vmctx, statedb, traceConfig)
+	return msg, tx, vmctx, nil
 }
 
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
 func (api *API) traceTx(ctx context.Context, tx *types.Transaction, message *core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
+	return api.traceTxWithPrecompiles(ctx, tx, message, txctx, vmctx, statedb, config, nil, nil)
+}
+
+// traceTxWithPrecompiles is traceTx executing the message with the given set
+// of precompiled contracts, or the default set of the EVM if nil. The state
+// change hooks of the observer, if any, are run along with the tracer.
+func (api *API) traceTxWithPrecompiles(ctx context.Context, tx *types.Transaction, message *core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig, precompiles vm.PrecompiledContracts, observer *tracing.Hooks) (interface{}, error) {
 	var (
 		tracer  *Tracer
 		err     error
@@ -1014,8 +1076,15 @@ func (api *API) traceTx(ctx context.Context, tx *types.Transaction, message *cor
 			return nil, err
 		}
 	}
-	tracingStateDB := state.NewHookedState(statedb, tracer.Hooks)
-	evm := vm.NewEVM(vmctx, tracingStateDB, api.backend.ChainConfig(), vm.Config{Tracer: tracer.Hooks, NoBaseFee: true})
+	hooks := tracer.Hooks
+	if observer != nil {
+		hooks = withStateObserver(tracer.Hooks, observer)
+	}
+	tracingStateDB := state.NewHookedState(statedb, hooks)
+	evm := vm.NewEVM(vmctx, tracingStateDB, api.backend.ChainConfig(), vm.Config{Tracer: hooks, NoBaseFee: true})
+	if precompiles != nil {
+		evm.SetPrecompiles(precompiles)
+	}
 
 	// Define a meaningful timeout of a single transaction trace
 	if config.Timeout != nil {
@@ -1030,6 +1099,10 @@ func (api *API) traceTx(ctx context.Context, tx *types.Transaction, message *cor
 			tracer.Stop(errors.New("execution timeout"))
 			// Stop evm execution. Note cancellation is not necessarily immediate.
 			evm.Cancel()
+		} else if ctx.Err() != nil {
+			// The caller went away, there's no point in finishing the trace.
+			tracer.Stop(ctx.Err())
+			evm.Cancel()
 		}
 	}()
 	defer cancel()
@@ -1040,6 +1113,13 @@ func (api *API) traceTx(ctx context.Context, tx *types.Transaction, message *cor
 	if err != nil {
 		return nil, fmt.Errorf("tracing failed: %w", err)
 	}
+	if config != nil && config.deferEncoding && tracer.GetDeferredResult != nil {
+		encode, err := tracer.GetDeferredResult()
+		if err != nil {
+			return nil, err
+		}
+		return deferredResult(encode), nil
+	}
 	return tracer.GetResult()
 }
 
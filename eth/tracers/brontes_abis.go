@@ -0,0 +1,94 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// brontesABIService installs the node-wide ABI registry used by the brontes
+// tracers opting into decoding while the node runs.
+type brontesABIService struct {
+	registry *brontes.ABIRegistry
+}
+
+// Start implements node.Lifecycle.
+func (s *brontesABIService) Start() error {
+	brontes.SetABIRegistry(s.registry)
+	return nil
+}
+
+// Stop implements node.Lifecycle.
+func (s *brontesABIService) Stop() error {
+	brontes.SetABIRegistry(nil)
+	s.registry.Close()
+	return nil
+}
+
+// brontesABIFetcherService installs the node-wide ABI fetcher used by the
+// brontes tracers opting into fetching while the node runs.
+type brontesABIFetcherService struct {
+	fetcher *brontes.ABIFetcher
+}
+
+// Start implements node.Lifecycle.
+func (s *brontesABIFetcherService) Start() error {
+	brontes.SetABIFetcher(s.fetcher)
+	return nil
+}
+
+// Stop implements node.Lifecycle.
+func (s *brontesABIFetcherService) Stop() error {
+	brontes.SetABIFetcher(nil)
+	return nil
+}
+
+// RegisterBrontesABIFetcher creates the ABI fetcher of the config for the
+// chain of the backend and installs it for the lifetime of the node. Nothing
+// is done unless fetching is enabled.
+func RegisterBrontesABIFetcher(stack *node.Node, backend Backend, config *BrontesConfig) error {
+	if config == nil || !config.FetchABIs {
+		return nil
+	}
+	fetcher, err := brontes.NewABIFetcher(brontes.ABIFetcherConfig{
+		SourcifyURL:     config.SourcifyURL,
+		EtherscanURL:    config.EtherscanURL,
+		EtherscanAPIKey: config.EtherscanAPIKey,
+		CacheDir:        config.ABICacheDir,
+	}, backend.ChainConfig().ChainID.Uint64())
+	if err != nil {
+		return err
+	}
+	stack.RegisterLifecycle(&brontesABIFetcherService{fetcher: fetcher})
+	return nil
+}
+
+// RegisterBrontesABIs loads the ABI directory of the config and installs it
+// for the lifetime of the node. Nothing is done if no directory is
+// configured.
+func RegisterBrontesABIs(stack *node.Node, config *BrontesConfig) error {
+	if config == nil || config.ABIDir == "" {
+		return nil
+	}
+	registry, err := brontes.OpenABIDir(config.ABIDir)
+	if err != nil {
+		return err
+	}
+	stack.RegisterLifecycle(&brontesABIService{registry: registry})
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+)
+
+const (
+	// maxAnnotationSize is the size of a single encoded annotation.
+	maxAnnotationSize = 4096
+	// maxAnnotations is the number of annotations kept per transaction.
+	maxAnnotations = 64
+)
+
+// brontesAnnotationPrefix prefixes the database keys of the annotations of
+// a transaction, followed by its hash.
+var brontesAnnotationPrefix = []byte("brontes-annotations-")
+
+func brontesAnnotationKey(hash common.Hash) []byte {
+	return append(append([]byte{}, brontesAnnotationPrefix...), hash[:]...)
+}
+
+// annotations returns the annotations stored for a transaction.
+func (api *BrontesAPI) annotations(hash common.Hash) ([]brontes.Annotation, error) {
+	db := api.api.backend.ChainDb()
+	if ok, _ := db.Has(brontesAnnotationKey(hash)); !ok {
+		return nil, nil
+	}
+	enc, err := db.Get(brontesAnnotationKey(hash))
+	if err != nil {
+		return nil, err
+	}
+	var annotations []brontes.Annotation
+	if err := json.Unmarshal(enc, &annotations); err != nil {
+		return nil, fmt.Errorf("corrupt annotations of %x: %v", hash, err)
+	}
+	return annotations, nil
+}
+
+// AnnotateTrace attaches an annotation to the trace of a transaction, which
+// is returned along with the trace from then on. Annotations are kept in the
+// node database and survive restarts. They are also written next to the trace
+// tables exported by the live tracer, if it writes tables.
+func (api *BrontesAdminAPI) AnnotateTrace(ctx context.Context, hash common.Hash, annotation brontes.Annotation) error {
+	release, err := api.api.limiter.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	found, _, blockHash, blockNumber, index, err := api.api.api.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("transaction %#x not found", hash)
+	}
+	annotation.CreatedAt = uint64(time.Now().Unix())
+	if enc, err := json.Marshal(annotation); err != nil {
+		return err
+	} else if len(enc) > maxAnnotationSize {
+		return fmt.Errorf("annotation too large: %d bytes, limit %d", len(enc), maxAnnotationSize)
+	}
+
+	api.api.annotationLock.Lock()
+	defer api.api.annotationLock.Unlock()
+
+	annotations, err := api.api.annotations(hash)
+	if err != nil {
+		return err
+	}
+	if len(annotations) >= maxAnnotations {
+		return errors.New("too many annotations")
+	}
+	enc, err := json.Marshal(append(annotations, annotation))
+	if err != nil {
+		return err
+	}
+	if err := api.api.api.backend.ChainDb().Put(brontesAnnotationKey(hash), enc); err != nil {
+		return err
+	}
+	if sink := brontes.RegisteredAnnotationSink(); sink != nil {
+		trace := &brontes.TxTrace{
+			BlockNumber: blockNumber,
+			BlockHash:   blockHash,
+			TxHash:      hash,
+			TxIndex:     int(index),
+		}
+		if chainId := api.api.api.backend.ChainConfig().ChainID; chainId != nil {
+			trace.ChainId = chainId.Uint64()
+		}
+		if err := sink.WriteAnnotations(trace, []brontes.Annotation{annotation}); err != nil {
+			return fmt.Errorf("annotation stored, but not exported: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetAnnotations returns the annotations attached to the trace of a
+// transaction, in the order they were attached.
+func (api *BrontesAPI) GetAnnotations(ctx context.Context, hash common.Hash) ([]brontes.Annotation, error) {
+	annotations, err := api.annotations(hash)
+	if err != nil {
+		return nil, err
+	}
+	if annotations == nil {
+		annotations = []brontes.Annotation{}
+	}
+	return annotations, nil
+}
+
+// withAnnotations adds the annotations of a transaction to its trace, as
+// an annotations field of the trace object.
+func (api *BrontesAPI) withAnnotations(hash common.Hash, trace json.RawMessage) (json.RawMessage, error) {
+	annotations, err := api.annotations(hash)
+	if err != nil || len(annotations) == 0 {
+		return trace, err
+	}
+	enc, err := json.Marshal(annotations)
+	if err != nil {
+		return nil, err
+	}
+	body := bytes.TrimSpace(trace)
+	if len(body) < 2 || body[0] != '{' || body[len(body)-1] != '}' {
+		return trace, nil
+	}
+	result := append([]byte{}, body[:len(body)-1]...)
+	if len(bytes.TrimSpace(body[1:len(body)-1])) > 0 {
+		result = append(result, ',')
+	}
+	result = append(result, `"annotations":`...)
+	result = append(result, enc...)
+	return append(result, '}'), nil
+}
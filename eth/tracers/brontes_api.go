@@ -0,0 +1,818 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/internal/ethapi/override"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/time/rate"
+)
+
+// brontesTracerName is the name the brontes tracer is registered under.
+const brontesTracerName = "brontesTracer"
+
+var (
+	errBrontesRateLimited = errors.New("brontes request rate limit exceeded")
+	errBrontesBusy        = errors.New("too many concurrent brontes requests")
+	errBrontesBudget      = errors.New("brontes request budget exceeded")
+	errBrontesIntercepted = errors.New("call intercepted by node interface")
+)
+
+// defaultMaxBrontesBlocks is the number of blocks a request may trace if not
+// configured otherwise.
+const defaultMaxBrontesBlocks = 100_000
+
+// BrontesConfig holds the access settings of the brontes tracing endpoints,
+// which re-execute transactions and are expensive to serve. The zero value
+// exposes them unauthenticated and without limits.
+type BrontesConfig struct {
+	// Authenticated restricts the brontes namespace to the JWT-authenticated
+	// RPC endpoint shared with the engine API.
+	Authenticated bool
+	// RateLimit is the number of requests accepted per second, with bursts
+	// of up to one second worth of requests. Zero disables the limit.
+	RateLimit float64
+	// MaxConcurrent is the number of requests served at once, further
+	// requests being rejected. Zero disables the limit.
+	MaxConcurrent int
+
+	// MaxBlocks is the number of blocks a single range request, or request
+	// of transactions of several blocks, may trace. Zero selects a default of
+	// 100000 blocks.
+	MaxBlocks uint64
+	// MaxGas is the total gas used by the blocks, or available to the calls,
+	// a single request may trace. Zero disables the limit.
+	MaxGas uint64
+	// MaxReexec is the number of blocks a request may re-execute to
+	// regenerate missing historical state. Zero disables the limit.
+	MaxReexec uint64
+
+	// Reexec is the number of blocks re-executed to regenerate missing
+	// historical state for requests not specifying it, allowing full nodes
+	// to trace blocks older than their retained states. Zero selects the
+	// default of the debug tracing methods.
+	Reexec uint64
+
+	// CacheSize is the total size in bytes of the recently computed traces
+	// kept in memory. Zero disables the memory cache.
+	CacheSize uint64
+	// CacheDir is the directory traces are additionally cached in on disk,
+	// disabled if empty.
+	CacheDir string
+	// CacheDirSize is the total size in bytes of the traces cached on disk,
+	// beyond which the oldest are evicted. Zero selects a default of 1 GiB.
+	CacheDirSize uint64
+
+	// MaxSessions is the number of simulation sessions open at once. Zero
+	// selects a default of 16 sessions.
+	MaxSessions int
+	// SessionTimeout is the time after which an idle simulation session is
+	// discarded. Zero selects a default of five minutes.
+	SessionTimeout time.Duration
+
+	// ABIDir is a directory of <address>.json ABI files the tracers opting
+	// into decodeAbis decode calls with. Decoding with ABIs is disabled if
+	// empty.
+	ABIDir string
+	// AddressBook is a JSON file mapping addresses to the names the tracers
+	// opting into resolveNames attach. Names are not resolved if empty.
+	AddressBook string
+
+	// FetchABIs enables fetching the verified ABIs of contracts missing from
+	// ABIDir for the tracers opting into fetchAbis, from Sourcify and then an
+	// Etherscan compatible API if configured.
+	FetchABIs bool
+	// SourcifyURL is the Sourcify server ABIs are fetched from, the public
+	// one if empty.
+	SourcifyURL string
+	// EtherscanURL is the Etherscan compatible API queried for the ABIs
+	// Sourcify has no match for, not queried if empty.
+	EtherscanURL    string
+	EtherscanAPIKey string
+	// ABICacheDir is the directory fetched ABIs are persisted in, kept in
+	// memory only if empty.
+	ABICacheDir string
+
+	// SelectorCacheDir is the directory of the node-wide cache of function
+	// signatures, used to decode calls to contracts without a known ABI.
+	// Selector resolution is disabled if empty.
+	SelectorCacheDir string
+	// SelectorURL is the 4byte.directory compatible signature database
+	// unknown selectors are looked up at, the public one if empty.
+	SelectorURL string
+
+	// BackfillDir is the directory the traces of the blocks queued for
+	// backfill through the API are written to. Backfills are disabled if
+	// empty.
+	BackfillDir string
+}
+
+// brontesBudget tracks the gas a request may still trace.
+type brontesBudget struct {
+	limit uint64 // zero if unlimited
+	used  uint64
+}
+
+// charge accounts the gas of a block or call about to be traced, failing
+// without charging if it exceeds the budget.
+func (b *brontesBudget) charge(gas uint64) error {
+	if b.limit > 0 && b.used+gas > b.limit {
+		return fmt.Errorf("%w: %d gas traced, next trace uses %d, limit %d", errBrontesBudget, b.used, gas, b.limit)
+	}
+	b.used += gas
+	return nil
+}
+
+// brontesLimiter enforces the rate and concurrency limits of a brontes
+// endpoint. A nil limiter admits every request.
+type brontesLimiter struct {
+	rate  *rate.Limiter
+	slots chan struct{}
+}
+
+func newBrontesLimiter(config *BrontesConfig) *brontesLimiter {
+	if config == nil || (config.RateLimit <= 0 && config.MaxConcurrent <= 0) {
+		return nil
+	}
+	l := new(brontesLimiter)
+	if config.RateLimit > 0 {
+		l.rate = rate.NewLimiter(rate.Limit(config.RateLimit), max(1, int(math.Ceil(config.RateLimit))))
+	}
+	if config.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, config.MaxConcurrent)
+	}
+	return l
+}
+
+// acquire admits a request, returning the function releasing it once served.
+func (l *brontesLimiter) acquire() (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	if l.rate != nil && !l.rate.Allow() {
+		return nil, errBrontesRateLimited
+	}
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	default:
+		return nil, errBrontesBusy
+	}
+}
+
+// BrontesAPI is the collection of brontes tracing APIs, running the brontes
+// tracer with a pinned trace schema version.
+type BrontesAPI struct {
+	api     *API
+	config  BrontesConfig
+	limiter *brontesLimiter
+	cache   *brontesCache
+
+	annotationLock sync.Mutex // serializes updates of trace annotations
+
+	sessionLock sync.Mutex
+	sessions    map[string]*brontesSession // open simulation sessions by id
+}
+
+// NewBrontesAPI creates a new API definition for the brontes tracing methods,
+// without any access limits.
+func NewBrontesAPI(backend Backend) *BrontesAPI {
+	return newBrontesAPI(backend, nil)
+}
+
+func newBrontesAPI(backend Backend, config *BrontesConfig) *BrontesAPI {
+	api := &BrontesAPI{
+		api:      NewAPI(backend),
+		limiter:  newBrontesLimiter(config),
+		sessions: make(map[string]*brontesSession),
+	}
+	if config != nil {
+		api.config = *config
+		api.cache = newBrontesCache(config.CacheSize, config.CacheDir, config.CacheDirSize)
+	}
+	return api
+}
+
+// BrontesAdminAPI is the collection of brontes methods writing to the
+// database of the node, only served on the authenticated RPC endpoint.
+type BrontesAdminAPI struct {
+	api *BrontesAPI
+}
+
+var (
+	brontesAPI     *BrontesAPI
+	brontesAPILock sync.RWMutex
+)
+
+// RegisteredBrontesAPI returns the brontes API of the node, shared by the
+// interfaces serving brontes traces besides RPC, or nil if none is set.
+func RegisteredBrontesAPI() *BrontesAPI {
+	brontesAPILock.RLock()
+	defer brontesAPILock.RUnlock()
+	return brontesAPI
+}
+
+// nodeBrontesAPI returns the brontes API of the node if one is registered, so
+// the services tracing besides RPC share its limits and trace cache, or else
+// a new one for the given config.
+func nodeBrontesAPI(backend Backend, config *BrontesConfig) *BrontesAPI {
+	if api := RegisteredBrontesAPI(); api != nil {
+		return api
+	}
+	return newBrontesAPI(backend, config)
+}
+
+// BrontesAPIs returns the brontes RPC namespace, limited according to the
+// given config. The methods writing to the node are always authenticated.
+// The API becomes the one of the node, served by GraphQL and used to trace
+// transactions again, so its limits and trace cache apply to all of them.
+func BrontesAPIs(backend Backend, config *BrontesConfig) []rpc.API {
+	api := newBrontesAPI(backend, config)
+
+	brontesAPILock.Lock()
+	brontesAPI = api
+	brontesAPILock.Unlock()
+
+	return []rpc.API{{
+		Namespace:     "brontes",
+		Service:       api,
+		Authenticated: config != nil && config.Authenticated,
+	}, {
+		Namespace:     "brontes",
+		Service:       &BrontesAdminAPI{api: api},
+		Authenticated: true,
+	}}
+}
+
+// Authenticated reports whether the API may only be served on the
+// authenticated RPC endpoint.
+func (api *BrontesAPI) Authenticated() bool {
+	return api.config.Authenticated
+}
+
+// cacheFor returns the trace cache for traces requested with the given
+// config, or nil if they are not cacheable.
+func (api *BrontesAPI) cacheFor(config *TraceConfig) *brontesCache {
+	if api.cache == nil || !brontesCacheable(config) {
+		return nil
+	}
+	return api.cache
+}
+
+// newBudget returns the budget of a new request.
+func (api *BrontesAPI) newBudget() *brontesBudget {
+	return &brontesBudget{limit: api.config.MaxGas}
+}
+
+// checkRange fails if the inclusive block range is empty or longer than
+// allowed for a single request.
+func (api *BrontesAPI) checkRange(from, to uint64) error {
+	if from > to {
+		return fmt.Errorf("from block %d after to block %d", from, to)
+	}
+	if limit := api.maxBlocks(); to-from >= limit {
+		return fmt.Errorf("%w: %d blocks requested, limit %d", errBrontesBudget, to-from+1, limit)
+	}
+	return nil
+}
+
+// maxBlocks returns the number of blocks a single request may trace.
+func (api *BrontesAPI) maxBlocks() uint64 {
+	if api.config.MaxBlocks == 0 {
+		return defaultMaxBrontesBlocks
+	}
+	return api.config.MaxBlocks
+}
+
+// BrontesTraceConfig holds the options of the brontes tracing methods.
+type BrontesTraceConfig struct {
+	// Version is the trace schema version the caller understands, the latest
+	// if unset.
+	Version *int    `json:"version"`
+	Timeout *string `json:"timeout"`
+	Reexec  *uint64 `json:"reexec"`
+	// TracerConfig holds the brontes inspector options.
+	TracerConfig json.RawMessage `json:"tracerConfig"`
+}
+
+// traceConfig converts the options of a request into the config of the debug
+// tracing methods, applying the default re-execution depth and enforcing its
+// limit.
+func (api *BrontesAPI) traceConfig(c *BrontesTraceConfig) (*TraceConfig, error) {
+	config, err := c.traceConfig()
+	if err != nil {
+		return nil, err
+	}
+	limit := api.config.MaxReexec
+	if config.Reexec == nil {
+		reexec := api.defaultReexec()
+		if limit > 0 {
+			reexec = min(reexec, limit)
+		}
+		config.Reexec = &reexec
+	} else if limit > 0 && *config.Reexec > limit {
+		return nil, fmt.Errorf("%w: reexec %d above limit %d", errBrontesBudget, *config.Reexec, limit)
+	}
+	return config, nil
+}
+
+// defaultReexec returns the re-execution depth of requests not specifying it.
+func (api *BrontesAPI) defaultReexec() uint64 {
+	if api.config.Reexec > 0 {
+		return api.config.Reexec
+	}
+	return defaultTraceReexec
+}
+
+// traceConfig converts the options into the config of the debug tracing
+// methods, passing the schema version on to the tracer.
+func (c *BrontesTraceConfig) traceConfig() (*TraceConfig, error) {
+	tracer := brontesTracerName
+	config := &TraceConfig{Tracer: &tracer}
+	if c == nil {
+		return config, nil
+	}
+	config.Timeout, config.Reexec = c.Timeout, c.Reexec
+	config.TracerConfig = c.TracerConfig
+	if c.Version == nil {
+		return config, nil
+	}
+	fields := make(map[string]json.RawMessage)
+	if len(c.TracerConfig) > 0 {
+		if err := json.Unmarshal(c.TracerConfig, &fields); err != nil {
+			return nil, fmt.Errorf("invalid tracer config: %v", err)
+		}
+	}
+	version, err := json.Marshal(*c.Version)
+	if err != nil {
+		return nil, err
+	}
+	fields["schemaVersion"] = version
+	if config.TracerConfig, err = json.Marshal(fields); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// TraceTransaction returns the brontes trace of the given transaction, encoded
+// in the requested schema version.
+func (api *BrontesAPI) TraceTransaction(ctx context.Context, hash common.Hash, config *BrontesTraceConfig) (interface{}, error) {
+	traceConfig, err := api.traceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	found, _, blockHash, number, index, err := api.api.backend.GetTransaction(ctx, hash)
+	if err != nil || !found {
+		// Let the debug method report the lookup failure.
+		return api.api.TraceTransaction(ctx, hash, traceConfig)
+	}
+	cache := api.cacheFor(traceConfig)
+	key := brontesCacheKey(blockHash, int(index), traceConfig)
+	if trace, ok := cache.get(key); ok {
+		return api.withAnnotations(hash, trace)
+	}
+	if err := api.checkState(ctx, number, reexecOf(traceConfig)); err != nil {
+		return nil, err
+	}
+	// The transaction is traced after replaying the ones preceding it.
+	if api.config.MaxGas > 0 {
+		gas, err := api.gasUpTo(ctx, blockHash, number, index)
+		if err != nil {
+			return nil, err
+		}
+		if err := api.newBudget().charge(gas); err != nil {
+			return nil, err
+		}
+	}
+	result, err := api.api.TraceTransaction(ctx, hash, traceConfig)
+	if trace, ok := result.(json.RawMessage); ok && err == nil {
+		cache.put(key, trace)
+		return api.withAnnotations(hash, trace)
+	}
+	return result, err
+}
+
+// gasUpTo returns the gas used by a block up to and including the transaction
+// at the given index, or by the whole block if its receipts are missing.
+func (api *BrontesAPI) gasUpTo(ctx context.Context, blockHash common.Hash, number, index uint64) (uint64, error) {
+	receipts := rawdb.ReadRawReceipts(api.api.backend.ChainDb(), blockHash, number)
+	if index < uint64(len(receipts)) {
+		return receipts[index].CumulativeGasUsed, nil
+	}
+	block, err := api.api.blockByHash(ctx, blockHash)
+	if err != nil {
+		return 0, err
+	}
+	return block.GasUsed(), nil
+}
+
+// TraceBlockByNumber returns the brontes traces of all transactions in the
+// given block, encoded in the requested schema version.
+func (api *BrontesAPI) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *BrontesTraceConfig) ([]*txTraceResult, error) {
+	traceConfig, err := api.traceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	block, err := api.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return api.traceBlock(ctx, block, traceConfig, api.newBudget())
+}
+
+// TraceBlockByHash returns the brontes traces of all transactions in the
+// given block, encoded in the requested schema version.
+func (api *BrontesAPI) TraceBlockByHash(ctx context.Context, hash common.Hash, config *BrontesTraceConfig) ([]*txTraceResult, error) {
+	traceConfig, err := api.traceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	block, err := api.api.blockByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.traceBlock(ctx, block, traceConfig, api.newBudget())
+}
+
+// TraceTransactions returns the brontes traces of the given transactions in
+// request order. The transactions are grouped by block and every block is
+// replayed once, which is far cheaper than tracing them one by one.
+// Transactions that cannot be found or traced are reported in the error field
+// of their result.
+func (api *BrontesAPI) TraceTransactions(ctx context.Context, hashes []common.Hash, config *BrontesTraceConfig) ([]*txTraceResult, error) {
+	traceConfig, err := api.traceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	type location struct {
+		block common.Hash
+		index uint64
+	}
+	var (
+		results   = make([]*txTraceResult, len(hashes))
+		locations = make(map[common.Hash]location)
+		numbers   = make(map[common.Hash]uint64)
+		blocks    []common.Hash
+	)
+	for i, hash := range hashes {
+		found, _, blockHash, number, index, err := api.api.backend.GetTransaction(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			results[i] = &txTraceResult{TxHash: hash, Error: "transaction not found"}
+			continue
+		}
+		locations[hash] = location{blockHash, index}
+		if _, ok := numbers[blockHash]; !ok {
+			if limit := api.maxBlocks(); uint64(len(blocks)) >= limit {
+				return nil, fmt.Errorf("%w: more than %d blocks requested", errBrontesBudget, limit)
+			}
+			numbers[blockHash] = number
+			blocks = append(blocks, blockHash)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool { return numbers[blocks[i]] < numbers[blocks[j]] })
+
+	budget := api.newBudget()
+	traces := make(map[common.Hash][]*txTraceResult, len(blocks))
+	for _, hash := range blocks {
+		block, err := api.api.blockByHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if traces[hash], err = api.traceBlock(ctx, block, traceConfig, budget); err != nil {
+			return nil, err
+		}
+	}
+	for i, hash := range hashes {
+		if results[i] != nil {
+			continue
+		}
+		loc := locations[hash]
+		result := *traces[loc.block][loc.index]
+		if trace, ok := result.Result.(json.RawMessage); ok {
+			if result.Result, err = api.withAnnotations(hash, trace); err != nil {
+				return nil, err
+			}
+		}
+		results[i] = &result
+	}
+	return results, nil
+}
+
+// BrontesCallConfig is the config of brontes_traceCall.
+type BrontesCallConfig struct {
+	BrontesTraceConfig
+	StateOverrides *override.StateOverride  `json:"stateOverrides"`
+	BlockOverrides *override.BlockOverrides `json:"blockOverrides"`
+	// EVMOverrides relax the execution rules for what-if simulations.
+	EVMOverrides *EVMOverrides `json:"evmOverrides"`
+}
+
+// callConfig checks the state a call is executed on and converts the brontes
+// call options.
+func (api *BrontesAPI) callConfig(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, traceConfig *TraceConfig, config *BrontesCallConfig) (*TraceCallConfig, error) {
+	// The call runs on top of the state after the block, which is checked
+	// like the state of its child. Lookup failures are left to the tracer.
+	var block *types.Block
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block, _ = api.api.blockByHash(ctx, hash)
+	} else if number, ok := blockNrOrHash.Number(); ok && number != rpc.PendingBlockNumber {
+		block, _ = api.api.blockByNumber(ctx, number)
+	}
+	if block != nil {
+		if err := api.checkState(ctx, block.NumberU64()+1, reexecOf(traceConfig)); err != nil {
+			return nil, err
+		}
+	}
+	return &TraceCallConfig{
+		TraceConfig:    *traceConfig,
+		StateOverrides: config.StateOverrides,
+		BlockOverrides: config.BlockOverrides,
+	}, nil
+}
+
+// TraceCall returns the brontes trace of a call executed on top of the state
+// of the given block, with optional state, block and EVM overrides.
+func (api *BrontesAPI) TraceCall(ctx context.Context, args ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *BrontesCallConfig) (interface{}, error) {
+	if config == nil {
+		config = new(BrontesCallConfig)
+	}
+	traceConfig, err := api.traceConfig(&config.BrontesTraceConfig)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	callConfig, err := api.callConfig(ctx, blockNrOrHash, traceConfig, config)
+	if err != nil {
+		return nil, err
+	}
+	env, err := api.api.callEnv(ctx, blockNrOrHash, callConfig, config.EVMOverrides)
+	if err != nil {
+		return nil, err
+	}
+	defer env.release()
+
+	msg, tx, vmctx, err := env.message(api.api, args, config.EVMOverrides)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.newBudget().charge(msg.GasLimit); err != nil {
+		return nil, err
+	}
+	return api.api.traceTxWithPrecompiles(ctx, tx, msg, new(Context), vmctx, env.statedb, &callConfig.TraceConfig, env.precompiles, nil)
+}
+
+// brontesCallResult is the result of a call along with its brontes trace.
+type brontesCallResult struct {
+	Output  hexutil.Bytes   `json:"output"`
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Error   string          `json:"error,omitempty"`
+	Trace   json.RawMessage `json:"trace"`
+}
+
+// Call executes a call exactly like eth_call, returning its result along with
+// the brontes trace of that same execution. The backend must serve eth_call.
+func (api *BrontesAPI) Call(ctx context.Context, args ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *BrontesCallConfig) (*brontesCallResult, error) {
+	backend, ok := api.api.backend.(ethapi.Backend)
+	if !ok {
+		return nil, errors.New("calls are not supported by the backend")
+	}
+	if config == nil {
+		config = new(BrontesCallConfig)
+	}
+	if config.EVMOverrides != nil {
+		return nil, errors.New("evm overrides are not supported by calls")
+	}
+	traceConfig, err := api.traceConfig(&config.BrontesTraceConfig)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	timeout := backend.RPCEVMTimeout()
+	if traceConfig.Timeout != nil {
+		if timeout, err = time.ParseDuration(*traceConfig.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	tracer, err := DefaultDirectory.New(*traceConfig.Tracer, new(Context), traceConfig.TracerConfig, backend.ChainConfig())
+	if err != nil {
+		return nil, err
+	}
+	// Calls to the NodeInterface are answered by the node without executing
+	// a transaction, leaving nothing to trace.
+	var (
+		started bool
+		hooks   = *tracer.Hooks
+	)
+	hooks.OnTxStart = func(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+		started = true
+		if tracer.OnTxStart != nil {
+			tracer.OnTxStart(env, tx, from)
+		}
+	}
+	result, err := ethapi.DoCallWithTracer(ctx, backend, args, blockNrOrHash, config.StateOverrides, config.BlockOverrides, timeout, backend.RPCGasCap(), core.MessageEthcallMode, &hooks)
+	if err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, errBrontesIntercepted
+	}
+	trace, err := tracer.GetResult()
+	if err != nil {
+		return nil, err
+	}
+	res := &brontesCallResult{
+		Output:  result.Return(),
+		GasUsed: hexutil.Uint64(result.UsedGas),
+		Trace:   trace,
+	}
+	if len(result.Revert()) > 0 {
+		res.Output = result.Revert()
+	}
+	if result.Err != nil {
+		res.Error = result.Err.Error()
+	}
+	return res, nil
+}
+
+// TraceCallAt returns the brontes trace of a call executed on top of the state
+// in the middle of the given block, after replaying the transactions preceding
+// txIndex, for precise mid-block simulations.
+func (api *BrontesAPI) TraceCallAt(ctx context.Context, blockHash common.Hash, txIndex hexutil.Uint, args ethapi.TransactionArgs, config *BrontesTraceConfig) (interface{}, error) {
+	traceConfig, err := api.traceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	block, err := api.api.blockByHash(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if int(txIndex) >= len(block.Transactions()) {
+		return nil, fmt.Errorf("transaction index %d out of range for block %#x", txIndex, blockHash)
+	}
+	if err := api.checkState(ctx, block.NumberU64(), reexecOf(traceConfig)); err != nil {
+		return nil, err
+	}
+	// The replayed transactions are charged as a whole block.
+	if err := api.newBudget().charge(block.GasUsed()); err != nil {
+		return nil, err
+	}
+	callConfig := &TraceCallConfig{TraceConfig: *traceConfig, TxIndex: &txIndex}
+	return api.api.TraceCall(ctx, args, rpc.BlockNumberOrHashWithHash(blockHash, false), callConfig)
+}
+
+// brontesBlockResult holds the brontes traces of a block of a range.
+type brontesBlockResult struct {
+	Number hexutil.Uint64   `json:"blockNumber"`
+	Hash   common.Hash      `json:"blockHash"`
+	Traces []*txTraceResult `json:"traces"`
+}
+
+// TraceBlockRange returns the brontes traces of all transactions in the given
+// inclusive block range, encoded in the requested schema version.
+func (api *BrontesAPI) TraceBlockRange(ctx context.Context, from, to rpc.BlockNumber, config *BrontesTraceConfig) ([]*brontesBlockResult, error) {
+	traceConfig, err := api.traceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	start, err := api.api.blockByNumber(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := api.api.blockByNumber(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.checkRange(start.NumberU64(), end.NumberU64()); err != nil {
+		return nil, err
+	}
+	var (
+		budget  = api.newBudget()
+		results []*brontesBlockResult
+	)
+	for number := start.NumberU64(); number <= end.NumberU64(); number++ {
+		block, err := api.api.blockByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		// The genesis block has no transactions to trace.
+		var traces []*txTraceResult
+		if number > 0 {
+			if traces, err = api.traceBlock(ctx, block, traceConfig, budget); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, &brontesBlockResult{
+			Number: hexutil.Uint64(number),
+			Hash:   block.Hash(),
+			Traces: traces,
+		})
+	}
+	return results, nil
+}
+
+// traceBlock traces a block after charging its gas to the request budget,
+// unless all its traces are cached. It fails early if the state needed to
+// trace the block is unavailable.
+func (api *BrontesAPI) traceBlock(ctx context.Context, block *types.Block, config *TraceConfig, budget *brontesBudget) ([]*txTraceResult, error) {
+	cache := api.cacheFor(config)
+	if results := cache.getBlock(block, config); results != nil {
+		return results, nil
+	}
+	if err := api.checkState(ctx, block.NumberU64(), reexecOf(config)); err != nil {
+		return nil, err
+	}
+	if err := budget.charge(block.GasUsed()); err != nil {
+		return nil, err
+	}
+	// Leave the encoding of the traces to encodeResults, so it does not hold
+	// up the sequential execution of the block.
+	deferred := *config
+	deferred.deferEncoding = true
+	results, err := api.api.traceBlock(ctx, block, &deferred)
+	if err != nil {
+		return nil, err
+	}
+	if err := encodeResults(ctx, results); err != nil {
+		return nil, err
+	}
+	cache.putBlock(block, config, results)
+	return results, nil
+}
@@ -0,0 +1,267 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestBrontesTraceConfig(t *testing.T) {
+	version := 1
+	tests := []struct {
+		config *BrontesTraceConfig
+		want   string
+		fail   bool
+	}{
+		{config: nil, want: ``},
+		{config: &BrontesTraceConfig{TracerConfig: json.RawMessage(`{"outputMode":"flat"}`)}, want: `{"outputMode":"flat"}`},
+		{config: &BrontesTraceConfig{Version: &version}, want: `{"schemaVersion":1}`},
+		{
+			config: &BrontesTraceConfig{Version: &version, TracerConfig: json.RawMessage(`{"schemaVersion":2,"fetchAbis":true}`)},
+			want:   `{"schemaVersion":1,"fetchAbis":true}`,
+		},
+		{config: &BrontesTraceConfig{Version: &version, TracerConfig: json.RawMessage(`[]`)}, fail: true},
+	}
+	for i, tt := range tests {
+		config, err := tt.config.traceConfig()
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if *config.Tracer != brontesTracerName {
+			t.Errorf("test %d: tracer mismatch: have %s", i, *config.Tracer)
+		}
+		if tt.want == "" {
+			if len(config.TracerConfig) != 0 {
+				t.Errorf("test %d: unexpected tracer config %s", i, config.TracerConfig)
+			}
+			continue
+		}
+		var have, want any
+		json.Unmarshal(config.TracerConfig, &have)
+		json.Unmarshal([]byte(tt.want), &want)
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("test %d: tracer config mismatch: have %s, want %s", i, config.TracerConfig, tt.want)
+		}
+	}
+}
+
+func TestBrontesLimiter(t *testing.T) {
+	// Without limits every request is admitted.
+	var unlimited *brontesLimiter
+	if l := newBrontesLimiter(&BrontesConfig{}); l != nil {
+		t.Fatalf("expected no limiter for zero config")
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := unlimited.acquire(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Concurrent requests beyond the limit are rejected until released.
+	l := newBrontesLimiter(&BrontesConfig{MaxConcurrent: 2})
+	release1, err := l.acquire()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release2, err := l.acquire()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := l.acquire(); err != errBrontesBusy {
+		t.Fatalf("expected %v, have %v", errBrontesBusy, err)
+	}
+	release1()
+	if _, err := l.acquire(); err != nil {
+		t.Fatalf("unexpected error after release: %v", err)
+	}
+	release2()
+
+	// Requests beyond the burst are rejected.
+	l = newBrontesLimiter(&BrontesConfig{RateLimit: 0.001})
+	if _, err := l.acquire(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := l.acquire(); err != errBrontesRateLimited {
+		t.Fatalf("expected %v, have %v", errBrontesRateLimited, err)
+	}
+}
+
+func TestBrontesAPIsAuthenticated(t *testing.T) {
+	if apis := BrontesAPIs(nil, nil); apis[0].Authenticated {
+		t.Errorf("brontes namespace authenticated by default")
+	}
+	if apis := BrontesAPIs(nil, &BrontesConfig{Authenticated: true}); !apis[0].Authenticated {
+		t.Errorf("brontes namespace not authenticated")
+	}
+	// Methods writing to the node are only served on the authenticated endpoint.
+	for _, method := range []string{"SetOrderflow", "AnnotateTrace"} {
+		served := false
+		for _, api := range BrontesAPIs(nil, nil) {
+			if _, ok := reflect.TypeOf(api.Service).MethodByName(method); ok {
+				served = true
+				if !api.Authenticated {
+					t.Errorf("%s served without authentication", method)
+				}
+			}
+		}
+		if !served {
+			t.Errorf("%s not served", method)
+		}
+	}
+}
+
+func TestBrontesAPIsRegistered(t *testing.T) {
+	apis := BrontesAPIs(nil, &BrontesConfig{MaxConcurrent: 1})
+	if api := RegisteredBrontesAPI(); api != apis[0].Service {
+		t.Fatalf("registered brontes API not the one served over RPC")
+	}
+	// The export, retracer and backfill share it, along with its trace cache.
+	if api := nodeBrontesAPI(nil, &BrontesConfig{CacheDir: t.TempDir()}); api != apis[0].Service {
+		t.Fatalf("services not sharing the brontes API of the node")
+	}
+}
+
+func TestBrontesReexec(t *testing.T) {
+	explicit := uint64(64)
+	tests := []struct {
+		config  BrontesConfig
+		request *uint64
+		want    uint64
+		fail    bool
+	}{
+		{want: defaultTraceReexec},
+		{config: BrontesConfig{Reexec: 4096}, want: 4096},
+		{config: BrontesConfig{Reexec: 4096}, request: &explicit, want: 64},
+		{config: BrontesConfig{Reexec: 4096, MaxReexec: 1024}, want: 1024},
+		{config: BrontesConfig{MaxReexec: 32}, want: 32},
+		{config: BrontesConfig{MaxReexec: 32}, request: &explicit, fail: true},
+	}
+	for i, tt := range tests {
+		api := newBrontesAPI(nil, &tt.config)
+		config, err := api.traceConfig(&BrontesTraceConfig{Reexec: tt.request})
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if *config.Reexec != tt.want {
+			t.Errorf("test %d: reexec mismatch: have %d, want %d", i, *config.Reexec, tt.want)
+		}
+	}
+}
+
+func TestBrontesCallUnsupportedBackend(t *testing.T) {
+	api := newBrontesAPI(nil, nil)
+	if _, err := api.Call(context.Background(), ethapi.TransactionArgs{}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), nil); err == nil {
+		t.Fatal("expected calls to fail without an eth_call backend")
+	}
+}
+
+func TestBrontesReplayProducts(t *testing.T) {
+	products := BrontesProducts{StateDiff: true, Transfers: true, AccessList: true}
+	config, err := products.tracerOptions(json.RawMessage(`{"schemaVersion": 1, "transferFlow": "gross"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"recordStateDiff":true,"recordStorageAccess":true,"transferFlow":"gross"}`
+	if string(config) != want {
+		t.Errorf("tracer options mismatch: have %s, want %s", config, want)
+	}
+
+	var (
+		contract = common.HexToAddress("0xcc")
+		trace    = &brontes.TxTrace{
+			StateDiff: map[common.Address]*brontes.AccountDiff{
+				contract: {Nonce: &brontes.Diff[hexutil.Uint64]{From: 0, To: 1}},
+			},
+		}
+	)
+	result, err := newBrontesReplayResult(trace, BrontesProducts{Trace: true, StateDiff: true, Transfers: true}, brontes.SchemaVersionV2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.StateDiff) != 1 || result.StateDiff[contract] == nil {
+		t.Errorf("state diff mismatch: have %v", result.StateDiff)
+	}
+	if result.Transfers == nil || len(result.Transfers) != 0 {
+		t.Errorf("expected empty transfers, have %v", result.Transfers)
+	}
+	if result.AccessList != nil {
+		t.Errorf("unrequested access list returned")
+	}
+	var encoded map[string]json.RawMessage
+	if err := json.Unmarshal(result.Trace, &encoded); err != nil {
+		t.Fatalf("failed to decode trace: %v", err)
+	}
+	if _, ok := encoded["state_diff"]; ok {
+		t.Errorf("state diff left in the trace")
+	}
+	if string(encoded["schema_version"]) != "2" {
+		t.Errorf("schema version mismatch: have %s", encoded["schema_version"])
+	}
+}
+
+func TestBrontesBackfillerQueue(t *testing.T) {
+	dir := t.TempDir()
+	b, err := openBrontesBackfiller(nil, dir)
+	if err != nil {
+		t.Fatalf("failed to open backfiller: %v", err)
+	}
+	// The genesis block is skipped, and ranges already queued are not
+	// queued again.
+	for _, r := range [][2]uint64{{0, 9}, {3, 5}, {10, 12}, {7, 6}} {
+		if err := b.QueueBackfill(r[0], r[1]); err != nil {
+			t.Fatalf("failed to queue %d-%d: %v", r[0], r[1], err)
+		}
+	}
+	b.out.Close()
+
+	// The queue must be resumed by the next backfiller.
+	b, err = openBrontesBackfiller(nil, dir)
+	if err != nil {
+		t.Fatalf("failed to reopen backfiller: %v", err)
+	}
+	defer b.out.Close()
+	if len(b.queue.Ranges) != 2 {
+		t.Fatalf("queued range count mismatch: have %d, want 2", len(b.queue.Ranges))
+	}
+	for i, want := range [][2]uint64{{1, 9}, {10, 12}} {
+		r := b.queue.Ranges[i]
+		if uint64(r.From) != want[0] || uint64(r.To) != want[1] || r.Order != BrontesBackfillOldestFirst {
+			t.Errorf("range %d mismatch: have %d-%d %s, want %d-%d", i, r.From, r.To, r.Order, want[0], want[1])
+		}
+	}
+}
@@ -0,0 +1,558 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// brontesBackfilledFile is the file the traces of backfilled blocks are
+	// appended to, one JSON object per line.
+	brontesBackfilledFile = "brontes_backfilled.jsonl"
+	// brontesBackfillQueueFile holds the queued ranges and whether the
+	// backfill is paused, so backfills survive restarts of the node.
+	brontesBackfillQueueFile = "brontes_backfill_queue.json"
+
+	// BrontesBackfillOldestFirst traces the blocks of a range in ascending
+	// order, the default.
+	BrontesBackfillOldestFirst = "oldestFirst"
+	// BrontesBackfillNewestFirst traces the blocks of a range in descending
+	// order, so the most recent history becomes available first.
+	BrontesBackfillNewestFirst = "newestFirst"
+)
+
+var (
+	errBrontesBackfillDisabled = errors.New("brontes backfill disabled, see --brontes.backfilldir")
+	errBrontesBackfillNotFound = errors.New("backfill range not found")
+)
+
+// BrontesBackfillRange is a range of blocks queued for backfill.
+type BrontesBackfillRange struct {
+	Id    uint64         `json:"id"`
+	From  hexutil.Uint64 `json:"from"`
+	To    hexutil.Uint64 `json:"to"`
+	Order string         `json:"order"`
+	// Low and High bound the blocks of the range left to trace. Oldest
+	// first backfills trace Low next, newest first ones High.
+	Low  hexutil.Uint64 `json:"low"`
+	High hexutil.Uint64 `json:"high"`
+	// Failed is the number of blocks of the range that failed to trace.
+	Failed uint64 `json:"failed"`
+}
+
+// remaining returns the number of blocks of the range left to trace.
+func (r *BrontesBackfillRange) remaining() uint64 {
+	return uint64(r.High-r.Low) + 1
+}
+
+// next returns the block of the range to trace next.
+func (r *BrontesBackfillRange) next() uint64 {
+	if r.Order == BrontesBackfillNewestFirst {
+		return uint64(r.High)
+	}
+	return uint64(r.Low)
+}
+
+// BrontesBackfillStatus describes the progress of the backfill.
+type BrontesBackfillStatus struct {
+	Paused bool `json:"paused"`
+	// Ranges are the queued ranges, in the order they are traced in.
+	Ranges    []BrontesBackfillRange `json:"ranges"`
+	Remaining uint64                 `json:"remaining"`
+	// Traced and Failed count the blocks traced and failed to trace since
+	// the node started.
+	Traced uint64 `json:"traced"`
+	Failed uint64 `json:"failed"`
+	// BlocksPerSecond is the recent tracing rate, not counting pauses, and
+	// ETA the estimated number of seconds left at that rate. Both are zero
+	// until a block is traced.
+	BlocksPerSecond float64 `json:"blocksPerSecond"`
+	ETA             uint64  `json:"eta"`
+}
+
+// brontesBackfillQueue is the persisted state of the backfill.
+type brontesBackfillQueue struct {
+	Paused bool                   `json:"paused"`
+	NextId uint64                 `json:"nextId"`
+	Ranges []BrontesBackfillRange `json:"ranges"`
+}
+
+// brontesBackfillFailure is the line written in place of the traces of a
+// block that failed to trace.
+type brontesBackfillFailure struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	Error       string `json:"error"`
+}
+
+// brontesBackfiller traces queued ranges of historical blocks from a
+// background goroutine, one block at a time, in the order set by the
+// operator.
+type brontesBackfiller struct {
+	api *BrontesAPI
+	dir string
+	out *os.File
+
+	lock      sync.Mutex
+	queue     brontesBackfillQueue
+	traced    uint64
+	failed    uint64
+	blockTime time.Duration // moving average of the time to trace a block
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// openBrontesBackfiller creates a backfiller writing to the given directory,
+// resuming the backfill queued there before.
+func openBrontesBackfiller(api *BrontesAPI, dir string) (*brontesBackfiller, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	b := &brontesBackfiller{
+		api:  api,
+		dir:  dir,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	blob, err := os.ReadFile(filepath.Join(dir, brontesBackfillQueueFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(blob, &b.queue); err != nil {
+			return nil, fmt.Errorf("invalid brontes backfill queue: %w", err)
+		}
+	}
+	b.out, err = os.OpenFile(filepath.Join(dir, brontesBackfilledFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	return b, nil
+}
+
+// start launches the backfill.
+func (b *brontesBackfiller) start() {
+	go b.loop()
+}
+
+// stop interrupts the block being traced, which is traced again once the
+// node restarts, and waits for the backfill to stop.
+func (b *brontesBackfiller) stop() error {
+	b.cancel()
+	<-b.done
+	return b.out.Close()
+}
+
+// save persists the queue. It must be called with the lock held.
+func (b *brontesBackfiller) save() {
+	blob, err := json.Marshal(&b.queue)
+	if err != nil {
+		log.Warn("Failed to encode brontes backfill queue", "err", err)
+		return
+	}
+	// Write to a temporary file first, so a crash never leaves a partial
+	// queue behind.
+	path := filepath.Join(b.dir, brontesBackfillQueueFile)
+	if err := os.WriteFile(path+".tmp", blob, 0644); err != nil {
+		log.Warn("Failed to save brontes backfill queue", "err", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Warn("Failed to save brontes backfill queue", "err", err)
+	}
+}
+
+// add queues a range, assigning its id. It must be called with the lock held.
+func (b *brontesBackfiller) add(r *BrontesBackfillRange) {
+	b.queue.NextId++
+	r.Id = b.queue.NextId
+	b.queue.Ranges = append(b.queue.Ranges, *r)
+	b.save()
+	b.notify()
+}
+
+// QueueBackfill implements brontes.BackfillQueue, queueing the blocks missing
+// from the export of the live tracer oldest first. Ranges already queued,
+// such as those queued by an earlier run still catching up, are skipped.
+func (b *brontesBackfiller) QueueBackfill(from, to uint64) error {
+	// The genesis block has no transactions to trace.
+	from = max(from, 1)
+	if from > to {
+		return nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, queued := range b.queue.Ranges {
+		if uint64(queued.Low) <= from && to <= uint64(queued.High) {
+			return nil
+		}
+	}
+	b.add(&BrontesBackfillRange{
+		From:  hexutil.Uint64(from),
+		To:    hexutil.Uint64(to),
+		Order: BrontesBackfillOldestFirst,
+		Low:   hexutil.Uint64(from),
+		High:  hexutil.Uint64(to),
+	})
+	return nil
+}
+
+// notify wakes up the backfill after the queue changed.
+func (b *brontesBackfiller) notify() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *brontesBackfiller) loop() {
+	defer close(b.done)
+	for {
+		id, number, ok := b.next()
+		if !ok {
+			select {
+			case <-b.wake:
+				continue
+			case <-b.ctx.Done():
+				return
+			}
+		}
+		start := time.Now()
+		results, err := b.traceBlock(number)
+		if b.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warn("Failed to backfill brontes traces", "number", number, "err", err)
+			b.write(brontesBackfillFailure{BlockNumber: number, Error: err.Error()})
+		}
+		for _, res := range results {
+			var line interface{} = res
+			if res.Error == "" {
+				line = res.Result
+			}
+			b.write(line)
+		}
+		b.complete(id, number, err, time.Since(start))
+	}
+}
+
+// next returns the id of the range to trace next and its block to trace,
+// unless the backfill is paused or there is nothing left to trace.
+func (b *brontesBackfiller) next() (uint64, uint64, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.queue.Paused || len(b.queue.Ranges) == 0 {
+		return 0, 0, false
+	}
+	r := &b.queue.Ranges[0]
+	return r.Id, r.next(), true
+}
+
+// complete records a block as traced, removing its range once all its blocks
+// are. The range may have been cancelled or reordered in the meantime.
+func (b *brontesBackfiller) complete(id, number uint64, err error, elapsed time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.traced++
+	if b.blockTime == 0 {
+		b.blockTime = elapsed
+	} else {
+		b.blockTime = (9*b.blockTime + elapsed) / 10
+	}
+	if err != nil {
+		b.failed++
+	}
+	i := slices.IndexFunc(b.queue.Ranges, func(r BrontesBackfillRange) bool { return r.Id == id })
+	if i < 0 {
+		return
+	}
+	r := &b.queue.Ranges[i]
+	if number != uint64(r.Low) && number != uint64(r.High) {
+		return
+	}
+	if err != nil {
+		r.Failed++
+	}
+	switch {
+	case r.Low == r.High:
+		log.Info("Backfilled brontes traces", "from", r.From, "to", r.To, "failed", r.Failed)
+		b.queue.Ranges = slices.Delete(b.queue.Ranges, i, i+1)
+	case number == uint64(r.Low):
+		r.Low++
+	default:
+		r.High--
+	}
+	b.save()
+}
+
+// traceBlock traces the block with the given number.
+func (b *brontesBackfiller) traceBlock(number uint64) ([]*txTraceResult, error) {
+	traceConfig, err := b.api.traceConfig(new(BrontesTraceConfig))
+	if err != nil {
+		return nil, err
+	}
+	block, err := b.api.api.blockByNumber(b.ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	return b.api.traceBlock(b.ctx, block, traceConfig, new(brontesBudget))
+}
+
+// write appends a line to the output.
+func (b *brontesBackfiller) write(line interface{}) {
+	out, err := json.Marshal(line)
+	if err != nil {
+		log.Warn("Failed to encode brontes backfill output", "err", err)
+		return
+	}
+	if _, err := b.out.Write(append(out, '\n')); err != nil {
+		log.Warn("Failed to write brontes backfill output", "err", err)
+	}
+}
+
+// status returns the progress of the backfill.
+func (b *brontesBackfiller) status() *BrontesBackfillStatus {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	status := &BrontesBackfillStatus{
+		Paused: b.queue.Paused,
+		Ranges: slices.Clone(b.queue.Ranges),
+		Traced: b.traced,
+		Failed: b.failed,
+	}
+	if status.Ranges == nil {
+		status.Ranges = []BrontesBackfillRange{}
+	}
+	for i := range status.Ranges {
+		status.Remaining += status.Ranges[i].remaining()
+	}
+	if b.blockTime > 0 {
+		status.BlocksPerSecond = float64(time.Second) / float64(b.blockTime)
+		status.ETA = uint64(float64(status.Remaining) * b.blockTime.Seconds())
+	}
+	return status
+}
+
+var (
+	brontesBackfill     *brontesBackfiller
+	brontesBackfillLock sync.RWMutex
+)
+
+// registeredBackfiller returns the backfiller of the node, failing if
+// backfills are disabled.
+func registeredBackfiller() (*brontesBackfiller, error) {
+	brontesBackfillLock.RLock()
+	defer brontesBackfillLock.RUnlock()
+	if brontesBackfill == nil {
+		return nil, errBrontesBackfillDisabled
+	}
+	return brontesBackfill, nil
+}
+
+// brontesBackfillService runs the backfill while the node runs.
+type brontesBackfillService struct {
+	backfiller *brontesBackfiller
+}
+
+// Start implements node.Lifecycle.
+func (s *brontesBackfillService) Start() error {
+	brontesBackfillLock.Lock()
+	brontesBackfill = s.backfiller
+	brontesBackfillLock.Unlock()
+	brontes.SetBackfillQueue(s.backfiller)
+	s.backfiller.start()
+	return nil
+}
+
+// Stop implements node.Lifecycle.
+func (s *brontesBackfillService) Stop() error {
+	brontesBackfillLock.Lock()
+	brontesBackfill = nil
+	brontesBackfillLock.Unlock()
+	brontes.SetBackfillQueue(nil)
+	return s.backfiller.stop()
+}
+
+// RegisterBrontesBackfill opens the backfill in the directory of the config,
+// controlled through the brontes API, and runs it for the lifetime of the
+// node. It traces through the brontes API of the node if one is registered,
+// sharing its trace cache. Nothing is done if no directory is configured.
+func RegisterBrontesBackfill(stack *node.Node, backend Backend, config *BrontesConfig) error {
+	if config == nil || config.BackfillDir == "" {
+		return nil
+	}
+	backfiller, err := openBrontesBackfiller(nodeBrontesAPI(backend, config), config.BackfillDir)
+	if err != nil {
+		return err
+	}
+	stack.RegisterLifecycle(&brontesBackfillService{backfiller: backfiller})
+	return nil
+}
+
+// checkBackfillOrder fails on unknown backfill orders.
+func checkBackfillOrder(order string) error {
+	if order != BrontesBackfillOldestFirst && order != BrontesBackfillNewestFirst {
+		return fmt.Errorf("invalid backfill order %q, want %q or %q", order, BrontesBackfillOldestFirst, BrontesBackfillNewestFirst)
+	}
+	return nil
+}
+
+// QueueBackfill queues the inclusive block range for backfill, in the given
+// order, oldest first if unset. The traces of the blocks are appended to the
+// backfill output of the node as they are traced, the ranges being traced in
+// the order they were queued in.
+func (api *BrontesAPI) QueueBackfill(ctx context.Context, from, to rpc.BlockNumber, order *string) (*BrontesBackfillRange, error) {
+	b, err := registeredBackfiller()
+	if err != nil {
+		return nil, err
+	}
+	r := BrontesBackfillRange{Order: BrontesBackfillOldestFirst}
+	if order != nil {
+		if err := checkBackfillOrder(*order); err != nil {
+			return nil, err
+		}
+		r.Order = *order
+	}
+	start, err := api.api.blockByNumber(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := api.api.blockByNumber(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	if start.NumberU64() > end.NumberU64() {
+		return nil, fmt.Errorf("from block %d after to block %d", start.NumberU64(), end.NumberU64())
+	}
+	// The genesis block has no transactions to trace.
+	r.From, r.To = hexutil.Uint64(start.NumberU64()), hexutil.Uint64(end.NumberU64())
+	r.Low, r.High = max(r.From, 1), r.To
+	if r.High == 0 {
+		return nil, errors.New("nothing to backfill before block 1")
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.add(&r)
+	return &r, nil
+}
+
+// PrioritizeBackfill moves a queued range to the front of the queue, changing
+// the order its remaining blocks are traced in if given.
+func (api *BrontesAPI) PrioritizeBackfill(ctx context.Context, id uint64, order *string) error {
+	b, err := registeredBackfiller()
+	if err != nil {
+		return err
+	}
+	if order != nil {
+		if err := checkBackfillOrder(*order); err != nil {
+			return err
+		}
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	i := slices.IndexFunc(b.queue.Ranges, func(r BrontesBackfillRange) bool { return r.Id == id })
+	if i < 0 {
+		return errBrontesBackfillNotFound
+	}
+	r := b.queue.Ranges[i]
+	if order != nil {
+		r.Order = *order
+	}
+	b.queue.Ranges = slices.Insert(slices.Delete(b.queue.Ranges, i, i+1), 0, r)
+	b.save()
+	b.notify()
+	return nil
+}
+
+// CancelBackfill drops a queued range. Its blocks already traced are kept in
+// the backfill output.
+func (api *BrontesAPI) CancelBackfill(ctx context.Context, id uint64) error {
+	b, err := registeredBackfiller()
+	if err != nil {
+		return err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	i := slices.IndexFunc(b.queue.Ranges, func(r BrontesBackfillRange) bool { return r.Id == id })
+	if i < 0 {
+		return errBrontesBackfillNotFound
+	}
+	b.queue.Ranges = slices.Delete(b.queue.Ranges, i, i+1)
+	b.save()
+	return nil
+}
+
+// PauseBackfill pauses the backfill once the block being traced is done,
+// until resumed. Pauses persist across restarts of the node.
+func (api *BrontesAPI) PauseBackfill(ctx context.Context) error {
+	return api.setBackfillPaused(true)
+}
+
+// ResumeBackfill resumes a paused backfill.
+func (api *BrontesAPI) ResumeBackfill(ctx context.Context) error {
+	return api.setBackfillPaused(false)
+}
+
+func (api *BrontesAPI) setBackfillPaused(paused bool) error {
+	b, err := registeredBackfiller()
+	if err != nil {
+		return err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.queue.Paused = paused
+	b.save()
+	b.notify()
+	return nil
+}
+
+// BackfillStatus returns the queued ranges and the progress of the backfill.
+func (api *BrontesAPI) BackfillStatus(ctx context.Context) (*BrontesBackfillStatus, error) {
+	b, err := registeredBackfiller()
+	if err != nil {
+		return nil, err
+	}
+	return b.status(), nil
+}
@@ -0,0 +1,248 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// defaultBrontesCacheDirSize is the size of the disk cache if not configured
+// otherwise.
+const defaultBrontesCacheDirSize = 1024 * 1024 * 1024
+
+// brontesCache keeps recently computed brontes traces, so repeated requests
+// for hot transactions are served without re-executing the EVM. Traces are
+// keyed by block hash, which keeps the cache consistent across reorgs. A nil
+// cache stores nothing.
+type brontesCache struct {
+	mem *lru.SizeConstrainedCache[common.Hash, json.RawMessage]
+	dir string // directory of the disk cache, disabled if empty
+
+	dirLimit uint64 // size of the disk cache, the oldest traces evicted beyond it
+	dirSize  uint64 // size of the traces on disk, scanned on the first write
+	dirLock  sync.Mutex
+	scanned  bool
+}
+
+func newBrontesCache(size uint64, dir string, dirLimit uint64) *brontesCache {
+	if size == 0 && dir == "" {
+		return nil
+	}
+	if dirLimit == 0 {
+		dirLimit = defaultBrontesCacheDirSize
+	}
+	c := &brontesCache{dir: dir, dirLimit: dirLimit}
+	if size > 0 {
+		c.mem = lru.NewSizeConstrainedCache[common.Hash, json.RawMessage](size)
+	}
+	return c
+}
+
+// brontesCacheable reports whether traces requested with the given tracer
+// config can be cached. Traces enriched with metadata that changes over time,
+// such as fetched ABIs or orderflow, and traces which may be cut short are
+// computed every time.
+func brontesCacheable(config *TraceConfig) bool {
+	var options brontes.TracingInspectorConfig
+	if len(config.TracerConfig) > 0 {
+		if err := json.Unmarshal(config.TracerConfig, &options); err != nil {
+			return false
+		}
+	}
+	return !options.PartialResults && !options.DecodeABIs && !options.FetchABIs &&
+		!options.ResolveSelectors && !options.AttachOrderflow && !options.ResolveNames &&
+		!options.ResolveENS
+}
+
+// brontesTraceFailed reports whether a trace lists failures which cut it
+// short, such as panics of the tracer.
+func brontesTraceFailed(trace json.RawMessage) bool {
+	var result struct {
+		Errors []json.RawMessage `json:"errors"`
+	}
+	// Traces not encoded as an object carry no failures.
+	return json.Unmarshal(trace, &result) == nil && len(result.Errors) > 0
+}
+
+// brontesCacheKey derives the cache key of the trace of a transaction. Only
+// the tracer options affect the trace, the timeout and reexec depth do not.
+func brontesCacheKey(blockHash common.Hash, txIndex int, config *TraceConfig) common.Hash {
+	var index [8]byte
+	binary.BigEndian.PutUint64(index[:], uint64(txIndex))
+	return crypto.Keccak256Hash(blockHash[:], index[:], config.TracerConfig)
+}
+
+// path returns the disk cache file of the given key.
+func (c *brontesCache) path(key common.Hash) string {
+	return filepath.Join(c.dir, common.Bytes2Hex(key[:])+".json")
+}
+
+// get returns the cached trace with the given key.
+func (c *brontesCache) get(key common.Hash) (json.RawMessage, bool) {
+	if c == nil {
+		return nil, false
+	}
+	if c.mem != nil {
+		if trace, ok := c.mem.Get(key); ok {
+			return trace, true
+		}
+	}
+	if c.dir == "" {
+		return nil, false
+	}
+	trace, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	if c.mem != nil {
+		c.mem.Add(key, trace)
+	}
+	return trace, true
+}
+
+// put stores a trace, keeping it in memory and on disk if enabled. Traces
+// listing failures are not stored.
+func (c *brontesCache) put(key common.Hash, trace json.RawMessage) {
+	if c == nil || brontesTraceFailed(trace) {
+		return
+	}
+	if c.mem != nil {
+		c.mem.Add(key, trace)
+	}
+	if c.dir == "" {
+		return
+	}
+	// Write to a temporary file first, so concurrent readers never see a
+	// partial trace.
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		log.Warn("Failed to create brontes trace cache", "dir", c.dir, "err", err)
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, "trace-*.tmp")
+	if err != nil {
+		log.Warn("Failed to cache brontes trace", "err", err)
+		return
+	}
+	_, err = tmp.Write(trace)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Warn("Failed to cache brontes trace", "err", err)
+		return
+	}
+	c.grow(uint64(len(trace)))
+}
+
+// grow accounts a trace written to disk, evicting the oldest traces once the
+// disk cache exceeds its size, down to three quarters of it.
+func (c *brontesCache) grow(size uint64) {
+	c.dirLock.Lock()
+	defer c.dirLock.Unlock()
+
+	// Traces cached by earlier runs are counted on the first write.
+	if !c.scanned {
+		c.scanned = true
+		c.dirSize = 0
+		for _, file := range c.files() {
+			c.dirSize += uint64(file.Size())
+		}
+	} else {
+		c.dirSize += size
+	}
+	if c.dirSize <= c.dirLimit {
+		return
+	}
+	files := c.files()
+	slices.SortFunc(files, func(a, b os.FileInfo) int {
+		return a.ModTime().Compare(b.ModTime())
+	})
+	for _, file := range files {
+		if c.dirSize <= c.dirLimit/4*3 {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			log.Warn("Failed to evict brontes trace", "file", file.Name(), "err", err)
+			continue
+		}
+		c.dirSize -= min(c.dirSize, uint64(file.Size()))
+	}
+}
+
+// files lists the traces cached on disk.
+func (c *brontesCache) files() []os.FileInfo {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			files = append(files, info)
+		}
+	}
+	return files
+}
+
+// getBlock returns the cached traces of all transactions of the block, or nil
+// unless all of them are cached.
+func (c *brontesCache) getBlock(block *types.Block, config *TraceConfig) []*txTraceResult {
+	if c == nil || len(block.Transactions()) == 0 {
+		return nil
+	}
+	results := make([]*txTraceResult, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		trace, ok := c.get(brontesCacheKey(block.Hash(), i, config))
+		if !ok {
+			return nil
+		}
+		results[i] = &txTraceResult{TxHash: tx.Hash(), Result: trace}
+	}
+	return results
+}
+
+// putBlock stores the successful traces of the transactions of the block.
+func (c *brontesCache) putBlock(block *types.Block, config *TraceConfig, results []*txTraceResult) {
+	if c == nil {
+		return
+	}
+	for i, res := range results {
+		if trace, ok := res.Result.(json.RawMessage); ok && res.Error == "" {
+			c.put(brontesCacheKey(block.Hash(), i, config), trace)
+		}
+	}
+}
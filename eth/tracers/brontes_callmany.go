@@ -0,0 +1,270 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxBrontesCalls is the number of calls a single brontes_traceCallMany
+// request may execute.
+const maxBrontesCalls = 256
+
+// BrontesDiff is the value of a field before and after a sequence of calls.
+type BrontesDiff[T any] struct {
+	From T `json:"from"`
+	To   T `json:"to"`
+}
+
+// BrontesAccountDiff holds the fields of an account changed by a sequence of
+// calls, unchanged fields are left out.
+type BrontesAccountDiff struct {
+	Balance *BrontesDiff[*hexutil.Big]               `json:"balance,omitempty"`
+	Nonce   *BrontesDiff[hexutil.Uint64]             `json:"nonce,omitempty"`
+	Code    *BrontesDiff[hexutil.Bytes]              `json:"code,omitempty"`
+	Storage map[common.Hash]BrontesDiff[common.Hash] `json:"storage,omitempty"`
+}
+
+// BrontesCallResult is the result of a call of brontes_traceCallMany.
+type BrontesCallResult struct {
+	Trace interface{} `json:"trace,omitempty"`
+	Error string      `json:"error,omitempty"`
+	// StateDiff is the change of the state by all calls so far.
+	StateDiff map[common.Address]*BrontesAccountDiff `json:"stateDiff"`
+}
+
+// stateDiffRecorder remembers the original values of the state changed by a
+// sequence of calls, so the cumulative diff can be taken after every call.
+type stateDiffRecorder struct {
+	balances map[common.Address]*big.Int
+	nonces   map[common.Address]uint64
+	codes    map[common.Address][]byte
+	storage  map[common.Address]map[common.Hash]common.Hash
+	order    []common.Address // accounts in order of first change
+}
+
+func newStateDiffRecorder() *stateDiffRecorder {
+	return &stateDiffRecorder{
+		balances: make(map[common.Address]*big.Int),
+		nonces:   make(map[common.Address]uint64),
+		codes:    make(map[common.Address][]byte),
+		storage:  make(map[common.Address]map[common.Hash]common.Hash),
+	}
+}
+
+// touch records the first change of an account.
+func (r *stateDiffRecorder) touch(addr common.Address) {
+	_, b := r.balances[addr]
+	_, n := r.nonces[addr]
+	_, c := r.codes[addr]
+	_, s := r.storage[addr]
+	if !b && !n && !c && !s {
+		r.order = append(r.order, addr)
+	}
+}
+
+// hooks returns the state change hooks recording the original values.
+func (r *stateDiffRecorder) hooks() *tracing.Hooks {
+	return &tracing.Hooks{
+		OnBalanceChange: func(addr common.Address, prev, _ *big.Int, _ tracing.BalanceChangeReason) {
+			if _, ok := r.balances[addr]; !ok {
+				r.touch(addr)
+				r.balances[addr] = new(big.Int).Set(prev)
+			}
+		},
+		OnNonceChange: func(addr common.Address, prev, _ uint64) {
+			if _, ok := r.nonces[addr]; !ok {
+				r.touch(addr)
+				r.nonces[addr] = prev
+			}
+		},
+		OnCodeChange: func(addr common.Address, _ common.Hash, prevCode []byte, _ common.Hash, _ []byte) {
+			if _, ok := r.codes[addr]; !ok {
+				r.touch(addr)
+				r.codes[addr] = common.CopyBytes(prevCode)
+			}
+		},
+		OnStorageChange: func(addr common.Address, slot common.Hash, prev, _ common.Hash) {
+			slots, ok := r.storage[addr]
+			if !ok {
+				r.touch(addr)
+				slots = make(map[common.Hash]common.Hash)
+				r.storage[addr] = slots
+			}
+			if _, ok := slots[slot]; !ok {
+				slots[slot] = prev
+			}
+		},
+	}
+}
+
+// diff compares the recorded original values with the current state. Values
+// changed back to their original are left out.
+func (r *stateDiffRecorder) diff(statedb *state.StateDB) map[common.Address]*BrontesAccountDiff {
+	result := make(map[common.Address]*BrontesAccountDiff)
+	for _, addr := range r.order {
+		account := new(BrontesAccountDiff)
+		if prev, ok := r.balances[addr]; ok {
+			if cur := statedb.GetBalance(addr).ToBig(); cur.Cmp(prev) != 0 {
+				account.Balance = &BrontesDiff[*hexutil.Big]{From: (*hexutil.Big)(prev), To: (*hexutil.Big)(cur)}
+			}
+		}
+		if prev, ok := r.nonces[addr]; ok {
+			if cur := statedb.GetNonce(addr); cur != prev {
+				account.Nonce = &BrontesDiff[hexutil.Uint64]{From: hexutil.Uint64(prev), To: hexutil.Uint64(cur)}
+			}
+		}
+		if prev, ok := r.codes[addr]; ok {
+			if cur := statedb.GetCode(addr); string(cur) != string(prev) {
+				account.Code = &BrontesDiff[hexutil.Bytes]{From: prev, To: common.CopyBytes(cur)}
+			}
+		}
+		for slot, prev := range r.storage[addr] {
+			if cur := statedb.GetState(addr, slot); cur != prev {
+				if account.Storage == nil {
+					account.Storage = make(map[common.Hash]BrontesDiff[common.Hash])
+				}
+				account.Storage[slot] = BrontesDiff[common.Hash]{From: prev, To: cur}
+			}
+		}
+		if account.Balance != nil || account.Nonce != nil || account.Code != nil || account.Storage != nil {
+			result[addr] = account
+		}
+	}
+	return result
+}
+
+// withStateObserver returns hooks running the state change hooks of the
+// observer along with those of the tracer.
+func withStateObserver(hooks, observer *tracing.Hooks) *tracing.Hooks {
+	merged := *hooks
+	if observer.OnBalanceChange != nil {
+		traced := hooks.OnBalanceChange
+		merged.OnBalanceChange = func(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+			observer.OnBalanceChange(addr, prev, new, reason)
+			if traced != nil {
+				traced(addr, prev, new, reason)
+			}
+		}
+	}
+	if observer.OnNonceChange != nil {
+		// The hooked state only calls the newer nonce hook if set.
+		if traced := hooks.OnNonceChangeV2; traced != nil {
+			merged.OnNonceChangeV2 = func(addr common.Address, prev, new uint64, reason tracing.NonceChangeReason) {
+				observer.OnNonceChange(addr, prev, new)
+				traced(addr, prev, new, reason)
+			}
+		} else {
+			traced := hooks.OnNonceChange
+			merged.OnNonceChange = func(addr common.Address, prev, new uint64) {
+				observer.OnNonceChange(addr, prev, new)
+				if traced != nil {
+					traced(addr, prev, new)
+				}
+			}
+		}
+	}
+	if observer.OnCodeChange != nil {
+		traced := hooks.OnCodeChange
+		merged.OnCodeChange = func(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+			observer.OnCodeChange(addr, prevCodeHash, prevCode, codeHash, code)
+			if traced != nil {
+				traced(addr, prevCodeHash, prevCode, codeHash, code)
+			}
+		}
+	}
+	if observer.OnStorageChange != nil {
+		traced := hooks.OnStorageChange
+		merged.OnStorageChange = func(addr common.Address, slot common.Hash, prev, new common.Hash) {
+			observer.OnStorageChange(addr, slot, prev, new)
+			if traced != nil {
+				traced(addr, slot, prev, new)
+			}
+		}
+	}
+	return &merged
+}
+
+// TraceCallMany executes a sequence of calls on top of the state of the given
+// block, every call seeing the state left by the previous ones, and returns
+// the brontes trace of every call along with the change of the state by all
+// calls so far. A failing call is reported in its result, the sequence goes
+// on with the state left by the previous calls.
+func (api *BrontesAPI) TraceCallMany(ctx context.Context, calls []ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *BrontesCallConfig) ([]*BrontesCallResult, error) {
+	if len(calls) == 0 {
+		return nil, errors.New("no calls given")
+	}
+	if len(calls) > maxBrontesCalls {
+		return nil, fmt.Errorf("%w: %d calls requested, limit %d", errBrontesBudget, len(calls), maxBrontesCalls)
+	}
+	if config == nil {
+		config = new(BrontesCallConfig)
+	}
+	traceConfig, err := api.traceConfig(&config.BrontesTraceConfig)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	callConfig, err := api.callConfig(ctx, blockNrOrHash, traceConfig, config)
+	if err != nil {
+		return nil, err
+	}
+	env, err := api.api.callEnv(ctx, blockNrOrHash, callConfig, config.EVMOverrides)
+	if err != nil {
+		return nil, err
+	}
+	defer env.release()
+
+	var (
+		recorder = newStateDiffRecorder()
+		observer = recorder.hooks()
+		budget   = api.newBudget()
+		results  = make([]*BrontesCallResult, len(calls))
+	)
+	for i, args := range calls {
+		result := new(BrontesCallResult)
+		msg, tx, vmctx, err := env.message(api.api, args, config.EVMOverrides)
+		if err == nil {
+			// Running out of budget fails the whole sequence.
+			if err := budget.charge(msg.GasLimit); err != nil {
+				return nil, err
+			}
+			txctx := &Context{TxIndex: i, TxHash: tx.Hash(), BlockHash: env.block.Hash(), BlockNumber: env.block.Number()}
+			result.Trace, err = api.api.traceTxWithPrecompiles(ctx, tx, msg, txctx, vmctx, env.statedb, &callConfig.TraceConfig, env.precompiles, observer)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.StateDiff = recorder.diff(env.statedb)
+		results[i] = result
+	}
+	return results, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"sync"
+)
+
+// deferredResult is the result of a transaction traced with deferEncoding,
+// encoding the completed trace when called.
+type deferredResult func() (json.RawMessage, error)
+
+// MarshalJSON encodes the trace, so deferred results are valid results even
+// if they are not encoded by encodeResults.
+func (r deferredResult) MarshalJSON() ([]byte, error) {
+	return r()
+}
+
+// encodeResults replaces the deferred results of a block by their encoding,
+// encoding the traces of the transactions concurrently. Marshaling the
+// traces of large blocks takes longer than executing them, and unlike the
+// execution it does not need to be sequential. Encoding failures are reported
+// in the error field of the affected transactions.
+func encodeResults(ctx context.Context, results []*txTraceResult) error {
+	var (
+		threads = min(runtime.NumCPU(), len(results))
+		jobs    = make(chan *txTraceResult, len(results))
+		wg      sync.WaitGroup
+	)
+	for _, res := range results {
+		if _, ok := res.Result.(deferredResult); ok {
+			jobs <- res
+		}
+	}
+	close(jobs)
+	for th := 0; th < threads; th++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for res := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				trace, err := res.Result.(deferredResult)()
+				if err != nil {
+					res.Result, res.Error = nil, err.Error()
+					continue
+				}
+				res.Result = trace
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
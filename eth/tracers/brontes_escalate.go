@@ -0,0 +1,56 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+)
+
+// brontesRetracer traces transactions of the chain again through the brontes
+// API, sharing its access limits and trace cache.
+type brontesRetracer struct {
+	api *BrontesAPI
+}
+
+// RegisterBrontesRetracer installs the node-wide retracer, so live tracers
+// escalating flagged transactions can trace them again in full. It traces
+// through the brontes API of the node if one is registered.
+func RegisterBrontesRetracer(backend Backend, config *BrontesConfig) {
+	brontes.SetRetracer(&brontesRetracer{api: nodeBrontesAPI(backend, config)})
+}
+
+// Retrace implements brontes.Retracer.
+func (r *brontesRetracer) Retrace(ctx context.Context, hash common.Hash, config brontes.TracingInspectorConfig) (json.RawMessage, error) {
+	tracerConfig, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.api.TraceTransaction(ctx, hash, &BrontesTraceConfig{TracerConfig: tracerConfig})
+	if err != nil {
+		return nil, err
+	}
+	trace, ok := result.(json.RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected brontes trace %T", result)
+	}
+	return trace, nil
+}
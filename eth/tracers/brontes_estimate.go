@@ -0,0 +1,183 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/gasestimator"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// BrontesGasEstimate is the result of brontes_estimateGasWithTrace.
+type BrontesGasEstimate struct {
+	// Gas is the estimated gas limit, zero if the call fails at any limit.
+	Gas hexutil.Uint64 `json:"gas"`
+	// Trace is the brontes trace of the call executed with the estimated gas
+	// limit, or with the highest allowed limit if it fails.
+	Trace interface{} `json:"trace"`
+	// Error is the reason the call fails, empty if it succeeds.
+	Error        string        `json:"error,omitempty"`
+	Revert       hexutil.Bytes `json:"revert,omitempty"`
+	RevertReason string        `json:"revertReason,omitempty"`
+}
+
+// EstimateGasWithTrace estimates the gas limit needed by a call executed on
+// top of the state of the given block, like eth_estimateGas, and returns the
+// brontes trace of its execution with the estimated limit. If the call fails
+// regardless of the limit, the trace of its execution with the highest allowed
+// limit is returned along with the failure, locating the revert.
+func (api *BrontesAPI) EstimateGasWithTrace(ctx context.Context, args ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *BrontesCallConfig) (*BrontesGasEstimate, error) {
+	if config == nil {
+		config = new(BrontesCallConfig)
+	}
+	traceConfig, err := api.traceConfig(&config.BrontesTraceConfig)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	callConfig, err := api.callConfig(ctx, blockNrOrHash, traceConfig, config)
+	if err != nil {
+		return nil, err
+	}
+	env, err := api.api.callEnv(ctx, blockNrOrHash, callConfig, config.EVMOverrides)
+	if err != nil {
+		return nil, err
+	}
+	defer env.release()
+
+	// Determine the highest gas limit can be used during the estimation.
+	hi := env.block.GasLimit()
+	if args.Gas != nil && uint64(*args.Gas) >= params.TxGas {
+		hi = uint64(*args.Gas)
+	}
+	if gasCap := api.api.backend.RPCGasCap(); gasCap != 0 && hi > gasCap {
+		hi = gasCap
+	}
+	args.Gas = (*hexutil.Uint64)(&hi)
+	msg, tx, vmctx, err := env.message(api.api, args, config.EVMOverrides)
+	if err != nil {
+		return nil, err
+	}
+	// execute runs the call with the given gas limit on a copy of the state,
+	// reporting whether it failed.
+	execute := func(gas uint64) (bool, *core.ExecutionResult, error) {
+		call := *msg
+		call.GasLimit = gas
+		evm := vm.NewEVM(vmctx, env.statedb.Copy(), api.api.backend.ChainConfig(), vm.Config{NoBaseFee: true})
+		if env.precompiles != nil {
+			evm.SetPrecompiles(env.precompiles)
+		}
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				evm.Cancel()
+			case <-done:
+			}
+		}()
+		result, err := core.ApplyMessage(evm, &call, new(core.GasPool).AddGas(gas))
+		if err != nil {
+			if errors.Is(err, core.ErrIntrinsicGas) {
+				return true, nil, nil // Raise the gas limit
+			}
+			return true, nil, err
+		}
+		return result.Failed(), result, nil
+	}
+	// trace returns the brontes trace of the call with the given gas limit.
+	trace := func(gas uint64) (interface{}, error) {
+		call := *msg
+		call.GasLimit = gas
+		txctx := &Context{TxHash: tx.Hash(), BlockHash: env.block.Hash(), BlockNumber: env.block.Number()}
+		return api.api.traceTxWithPrecompiles(ctx, tx, &call, txctx, vmctx, env.statedb.Copy(), &callConfig.TraceConfig, env.precompiles, nil)
+	}
+
+	// Plain transfers most likely need no more than the intrinsic gas.
+	if len(msg.Data) == 0 && msg.To != nil && env.statedb.GetCodeSize(*msg.To) == 0 {
+		if failed, _, err := execute(params.TxGas); !failed && err == nil {
+			traced, err := trace(params.TxGas)
+			if err != nil {
+				return nil, err
+			}
+			return &BrontesGasEstimate{Gas: hexutil.Uint64(params.TxGas), Trace: traced}, nil
+		}
+	}
+	// Execute the call with the highest limit first, returning the evidence
+	// right away if it fails.
+	failed, result, err := execute(hi)
+	if err != nil {
+		return nil, err
+	}
+	if failed {
+		estimate := new(BrontesGasEstimate)
+		if estimate.Trace, err = trace(hi); err != nil {
+			return nil, err
+		}
+		if result == nil {
+			estimate.Error = core.ErrIntrinsicGas.Error()
+		} else if errors.Is(result.Err, vm.ErrOutOfGas) {
+			estimate.Error = fmt.Sprintf("gas required exceeds allowance (%d)", hi)
+		} else {
+			estimate.Error = result.Err.Error()
+			estimate.Revert = result.Revert()
+			if reason, err := abi.UnpackRevert(estimate.Revert); err == nil {
+				estimate.RevertReason = reason
+			}
+		}
+		return estimate, nil
+	}
+	// Binary search for the smallest gas limit allowing the call to succeed,
+	// the gas used by the unconstrained execution being a lower bound.
+	lo := result.UsedGas - 1
+	for lo+1 < hi {
+		if float64(hi-lo)/float64(hi) < gasestimator.EstimateGasErrorRatio {
+			break
+		}
+		mid := (hi + lo) / 2
+		if mid > lo*2 {
+			mid = lo * 2
+		}
+		failed, _, err := execute(mid)
+		if err != nil {
+			return nil, err
+		}
+		if failed {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	traced, err := trace(hi)
+	if err != nil {
+		return nil, err
+	}
+	return &BrontesGasEstimate{Gas: hexutil.Uint64(hi), Trace: traced}, nil
+}
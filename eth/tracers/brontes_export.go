@@ -0,0 +1,194 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// BrontesExportPath is the HTTP path the brontes export is served on.
+const BrontesExportPath = "/brontes/export"
+
+// brontesExportHandler streams the brontes traces of a block range as
+// newline-delimited JSON, one transaction trace per line, so bulk exports can
+// be pulled with plain HTTP clients:
+//
+//	curl 'http://localhost:8545/brontes/export?from=100&to=200&version=1'
+//
+// Transactions that fail to trace are emitted as {"txHash":...,"error":...}
+// lines. Blocks whose state is no longer available are skipped with a
+// {"skippedFrom":...,"skippedTo":...,"error":...} marker, so they can be
+// backfilled from an archive node. An error aborting the export is emitted as
+// a final {"error":...} line, as the response status has already been sent by
+// then.
+//
+// The limits of the brontes config apply per export request.
+type brontesExportHandler struct {
+	api *BrontesAPI
+}
+
+// RegisterBrontesExport adds the brontes export handler to the HTTP server of
+// the node. It exports through the brontes API of the node if one is
+// registered, so the access limits and trace cache are shared with RPC.
+func RegisterBrontesExport(stack *node.Node, backend Backend, config *BrontesConfig, cors, vhosts []string) {
+	handler := &brontesExportHandler{api: nodeBrontesAPI(backend, config)}
+	stack.RegisterHandler("Brontes export", BrontesExportPath, node.NewHTTPHandlerStack(handler, cors, vhosts, nil))
+}
+
+// parseExportRange reads the inclusive block range and trace options of an
+// export request.
+func parseExportRange(r *http.Request) (from, to uint64, config *BrontesTraceConfig, err error) {
+	query := r.URL.Query()
+	if from, err = strconv.ParseUint(query.Get("from"), 0, 64); err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid from block: %q", query.Get("from"))
+	}
+	if to, err = strconv.ParseUint(query.Get("to"), 0, 64); err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid to block: %q", query.Get("to"))
+	}
+	config = new(BrontesTraceConfig)
+	if v := query.Get("version"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("invalid version: %q", v)
+		}
+		config.Version = &version
+	}
+	if timeout := query.Get("timeout"); timeout != "" {
+		config.Timeout = &timeout
+	}
+	if v := query.Get("reexec"); v != "" {
+		reexec, err := strconv.ParseUint(v, 0, 64)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("invalid reexec: %q", v)
+		}
+		config.Reexec = &reexec
+	}
+	return from, to, config, nil
+}
+
+// exportSkip marks a range of blocks left out of an export.
+type exportSkip struct {
+	SkippedFrom uint64 `json:"skippedFrom"`
+	SkippedTo   uint64 `json:"skippedTo"`
+	Error       string `json:"error"`
+}
+
+// traceBlock traces the block with the given number, charging the budget of
+// the export.
+func (h *brontesExportHandler) traceBlock(ctx context.Context, number uint64, config *TraceConfig, budget *brontesBudget) ([]*txTraceResult, error) {
+	block, err := h.api.api.blockByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	return h.api.traceBlock(ctx, block, config, budget)
+}
+
+func (h *brontesExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	from, to, config, err := parseExportRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.api.checkRange(from, to); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	traceConfig, err := h.api.traceConfig(config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	release, err := h.api.limiter.acquire()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	defer release()
+	var (
+		ctx        = r.Context()
+		enc        = json.NewEncoder(w)
+		flusher, _ = w.(http.Flusher)
+		budget     = h.api.newBudget()
+		started    bool
+	)
+	for number := from; number <= to; number++ {
+		// The genesis block has no transactions to trace.
+		if number == 0 {
+			continue
+		}
+		results, err := h.traceBlock(ctx, number, traceConfig, budget)
+
+		// Skip ahead to the first block with available state.
+		var unavailable *StateUnavailableError
+		if errors.As(err, &unavailable) {
+			skip := exportSkip{SkippedFrom: number, SkippedTo: to, Error: err.Error()}
+			if unavailable.EarliestTraceable > number && unavailable.EarliestTraceable <= to {
+				skip.SkippedTo = unavailable.EarliestTraceable - 1
+			}
+			if !started {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				w.WriteHeader(http.StatusOK)
+				started = true
+			}
+			if err := enc.Encode(skip); err != nil {
+				return
+			}
+			number = skip.SkippedTo
+			continue
+		}
+		if err != nil {
+			// Nothing was streamed yet, so the failure can still be reported
+			// in the status code.
+			if !started {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			enc.Encode(map[string]string{"error": fmt.Sprintf("block %d: %v", number, err)})
+			return
+		}
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		for _, res := range results {
+			var line interface{} = res
+			if res.Error == "" {
+				line = res.Result
+			}
+			// Stop once the client went away.
+			if err := enc.Encode(line); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,1376 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/internal/ethapi/override"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// registerStubBrontesTracer registers a tracer under the brontes tracer name,
+// which only reports the hash of the traced transaction, the balance of its
+// recipient and the output of the top-level call. The real tracer lives in the native package, which cannot be
+// imported here.
+func registerStubBrontesTracer() {
+	DefaultDirectory.Register(brontesTracerName, func(ctx *Context, cfg json.RawMessage, _ *params.ChainConfig) (*Tracer, error) {
+		var (
+			balance *big.Int
+			output  hexutil.Bytes
+		)
+		return &Tracer{
+			Hooks: &tracing.Hooks{
+				OnTxStart: func(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+					if tx.To() != nil {
+						balance = env.StateDB.GetBalance(*tx.To()).ToBig()
+					}
+				},
+				OnExit: func(depth int, out []byte, gasUsed uint64, err error, reverted bool) {
+					if depth == 0 {
+						output = common.CopyBytes(out)
+					}
+				},
+			},
+			GetResult: func() (json.RawMessage, error) {
+				return json.Marshal(map[string]interface{}{"tx_hash": ctx.TxHash, "config": cfg, "to_balance": (*hexutil.Big)(balance), "output": output})
+			},
+			GetDeferredResult: func() (func() (json.RawMessage, error), error) {
+				result := map[string]interface{}{"tx_hash": ctx.TxHash, "config": cfg, "to_balance": (*hexutil.Big)(balance), "output": output}
+				return func() (json.RawMessage, error) { return json.Marshal(result) }, nil
+			},
+			Stop: func(err error) {},
+		}, nil
+	}, false)
+}
+
+// newBrontesTestBackend creates a chain of three blocks with two transfers
+// each, returning the transaction hashes in order.
+func newBrontesTestBackend(t *testing.T) (*testBackend, []common.Hash) {
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	var (
+		signer   = types.HomesteadSigner{}
+		txHashes []common.Hash
+	)
+	backend := newTestBackend(t, 3, genesis, func(i int, b *core.BlockGen) {
+		for j := 0; j < 2; j++ {
+			tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{
+				Nonce:    uint64(2*i + j),
+				To:       &accounts[1].addr,
+				Value:    big.NewInt(1000),
+				Gas:      params.TxGas,
+				GasPrice: b.BaseFee(),
+			}), signer, accounts[0].key)
+			b.AddTx(tx)
+			txHashes = append(txHashes, tx.Hash())
+		}
+	})
+	return backend, txHashes
+}
+
+func TestBrontesExport(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, txHashes := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	server := httptest.NewServer(&brontesExportHandler{api: NewBrontesAPI(backend)})
+	defer server.Close()
+
+	// Stream blocks 0 to 2, skipping the genesis block.
+	resp, err := http.Get(fmt.Sprintf("%s%s?from=0&to=0x2&version=1", server.URL, BrontesExportPath))
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	type exportLine struct {
+		TxHash common.Hash     `json:"tx_hash"`
+		Config json.RawMessage `json:"config"`
+	}
+	var lines []exportLine
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var line exportLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 4 {
+		t.Fatalf("unexpected number of traces: have %d, want 4", len(lines))
+	}
+	for i, line := range lines {
+		if line.TxHash != txHashes[i] {
+			t.Errorf("trace %d: tx hash mismatch: have %x, want %x", i, line.TxHash, txHashes[i])
+		}
+		if string(line.Config) != `{"schemaVersion":1}` {
+			t.Errorf("trace %d: unexpected tracer config %s", i, line.Config)
+		}
+	}
+
+	// Invalid ranges are rejected before streaming.
+	for _, query := range []string{"from=2&to=1", "from=a&to=1", "to=1", "from=0&to=1000000", "from=1&to=2&version=x"} {
+		resp, err := http.Get(fmt.Sprintf("%s%s?%s", server.URL, BrontesExportPath, query))
+		if err != nil {
+			t.Fatalf("export failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("query %q: unexpected status %d", query, resp.StatusCode)
+		}
+	}
+	// Missing blocks fail with the first block.
+	resp, err = http.Get(fmt.Sprintf("%s%s?from=10&to=11", server.URL, BrontesExportPath))
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("unexpected status %d for missing blocks", resp.StatusCode)
+	}
+
+	// Requests beyond the limits are rejected.
+	limited := httptest.NewServer(&brontesExportHandler{api: newBrontesAPI(backend, &BrontesConfig{RateLimit: 0.001})})
+	defer limited.Close()
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		resp, err := http.Get(fmt.Sprintf("%s%s?from=1&to=1", limited.URL, BrontesExportPath))
+		if err != nil {
+			t.Fatalf("export failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("request %d: unexpected status %d, want %d", i, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestBrontesBudgets(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, txHashes := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	// Every block uses the gas of two transfers.
+	blockGas := 2 * params.TxGas
+	api := newBrontesAPI(backend, &BrontesConfig{MaxBlocks: 2, MaxGas: 3 * blockGas, MaxReexec: 16})
+	ctx := context.Background()
+
+	results, err := api.TraceBlockRange(ctx, 1, 2, nil)
+	if err != nil {
+		t.Fatalf("failed to trace range: %v", err)
+	}
+	if len(results) != 2 || len(results[1].Traces) != 2 {
+		t.Fatalf("unexpected range result: %d blocks", len(results))
+	}
+	if have := results[1].Traces[1].TxHash; have != txHashes[3] {
+		t.Errorf("tx hash mismatch: have %x, want %x", have, txHashes[3])
+	}
+	// Ranges above the block limit are rejected.
+	if _, err := api.TraceBlockRange(ctx, 1, 3, nil); !errors.Is(err, errBrontesBudget) {
+		t.Errorf("expected block budget error, have %v", err)
+	}
+	// The gas budget spans all blocks of an export.
+	server := httptest.NewServer(&brontesExportHandler{api: newBrontesAPI(backend, &BrontesConfig{MaxGas: 2 * blockGas})})
+	defer server.Close()
+	resp, err := http.Get(fmt.Sprintf("%s%s?from=1&to=3", server.URL, BrontesExportPath))
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 5 || !strings.Contains(lines[4], errBrontesBudget.Error()) {
+		t.Errorf("expected four traces and a budget error, have %q", body)
+	}
+	// Re-execution beyond the limit is rejected.
+	reexec := uint64(128)
+	if _, err := api.TraceTransaction(ctx, txHashes[0], &BrontesTraceConfig{Reexec: &reexec}); !errors.Is(err, errBrontesBudget) {
+		t.Errorf("expected reexec budget error, have %v", err)
+	}
+	if _, err := api.TraceTransaction(ctx, txHashes[0], nil); err != nil {
+		t.Errorf("failed to trace transaction: %v", err)
+	}
+}
+
+func TestBrontesCallBudgets(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, txHashes := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	var (
+		ctx    = context.Background()
+		api    = newBrontesAPI(backend, &BrontesConfig{MaxGas: params.TxGas + 1000})
+		block  = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		to     = *backend.chain.GetBlockByNumber(1).Transactions()[0].To()
+		from   = common.HexToAddress("0x0200")
+		gas    = hexutil.Uint64(params.TxGas)
+		large  = hexutil.Uint64(2 * params.TxGas)
+		config = &BrontesCallConfig{StateOverrides: &override.StateOverride{
+			from: {Balance: (*hexutil.Big)(big.NewInt(params.Ether))},
+		}}
+	)
+	// Transactions are charged the gas of the block up to them.
+	if _, err := api.TraceTransaction(ctx, txHashes[0], nil); err != nil {
+		t.Errorf("failed to trace first transaction: %v", err)
+	}
+	if _, err := api.TraceTransaction(ctx, txHashes[1], nil); !errors.Is(err, errBrontesBudget) {
+		t.Errorf("expected budget error for second transaction, have %v", err)
+	}
+	// Calls are charged their gas limit.
+	if _, err := api.TraceCall(ctx, ethapi.TransactionArgs{From: &from, To: &to, Gas: &gas}, block, config); err != nil {
+		t.Errorf("failed to trace call: %v", err)
+	}
+	if _, err := api.TraceCall(ctx, ethapi.TransactionArgs{From: &from, To: &to, Gas: &large}, block, config); !errors.Is(err, errBrontesBudget) {
+		t.Errorf("expected budget error for call, have %v", err)
+	}
+	calls := []ethapi.TransactionArgs{{From: &from, To: &to, Gas: &gas}, {From: &from, To: &to, Gas: &gas}}
+	if _, err := api.TraceCallMany(ctx, calls, block, config); !errors.Is(err, errBrontesBudget) {
+		t.Errorf("expected budget error for call sequence, have %v", err)
+	}
+	session, err := api.CreateSession(ctx, block, config)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if _, err := api.SessionSend(ctx, session.Id, ethapi.TransactionArgs{From: &from, To: &to, Gas: &gas}); err != nil {
+		t.Errorf("failed to send: %v", err)
+	}
+	if _, err := api.SessionSend(ctx, session.Id, ethapi.TransactionArgs{From: &from, To: &to, Gas: &large}); !errors.Is(err, errBrontesBudget) {
+		t.Errorf("expected budget error for send, have %v", err)
+	}
+	if _, err := api.SessionCall(ctx, session.Id, ethapi.TransactionArgs{From: &from, To: &to, Gas: &large}); !errors.Is(err, errBrontesBudget) {
+		t.Errorf("expected budget error for session call, have %v", err)
+	}
+}
+
+// prunedBackend hides the states of the blocks before a given number.
+type prunedBackend struct {
+	*testBackend
+	earliest uint64
+}
+
+func (b *prunedBackend) HasState(root common.Hash) bool {
+	for n := uint64(0); n < b.earliest; n++ {
+		if b.chain.GetHeaderByNumber(n).Root == root {
+			return false
+		}
+	}
+	return b.chain.HasState(root)
+}
+
+func TestBrontesStateUnavailable(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, txHashes := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	// Only the state of the head block 3 is available, making block 4 the
+	// first traceable one.
+	api := newBrontesAPI(&prunedBackend{backend, 3}, nil)
+	reexec := uint64(0)
+	_, err := api.TraceTransaction(context.Background(), txHashes[0], &BrontesTraceConfig{Reexec: &reexec})
+	var unavailable *StateUnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("expected state unavailable error, have %v", err)
+	}
+	if unavailable.Block != 1 || unavailable.EarliestTraceable != 4 {
+		t.Errorf("unexpected error %v", unavailable)
+	}
+	if unavailable.ErrorCode() != StateUnavailableErrorCode {
+		t.Errorf("unexpected error code %d", unavailable.ErrorCode())
+	}
+	// Blocks are traceable on top of older states within the reexec depth.
+	api = newBrontesAPI(&prunedBackend{backend, 1}, nil)
+	reexec = 1
+	if _, err := api.TraceBlockByNumber(context.Background(), 3, &BrontesTraceConfig{Reexec: &reexec}); err != nil {
+		t.Errorf("failed to trace block: %v", err)
+	}
+	if _, err := api.TraceBlockByNumber(context.Background(), 1, &BrontesTraceConfig{Reexec: &reexec}); !errors.As(err, &unavailable) {
+		t.Errorf("expected state unavailable error, have %v", err)
+	}
+	// States are only probed as far back as the default depth, older ones
+	// are found by the tracer.
+	api = newBrontesAPI(&prunedBackend{backend, 3}, &BrontesConfig{Reexec: 1})
+	if _, err := api.TraceBlockByNumber(context.Background(), 3, nil); !errors.As(err, &unavailable) {
+		t.Errorf("expected state unavailable error, have %v", err)
+	}
+	reexec = 2
+	if _, err := api.TraceBlockByNumber(context.Background(), 3, &BrontesTraceConfig{Reexec: &reexec}); err != nil {
+		t.Errorf("failed to trace block beyond the probed depth: %v", err)
+	}
+
+	// The export skips the blocks without state and continues.
+	server := httptest.NewServer(&brontesExportHandler{api: newBrontesAPI(&prunedBackend{backend, 2}, nil)})
+	defer server.Close()
+	resp, err := http.Get(fmt.Sprintf("%s%s?from=1&to=3&reexec=0", server.URL, BrontesExportPath))
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a skip marker and two traces, have %q", body)
+	}
+	var skip exportSkip
+	if err := json.Unmarshal([]byte(lines[0]), &skip); err != nil {
+		t.Fatalf("invalid skip marker %q: %v", lines[0], err)
+	}
+	if skip.SkippedFrom != 1 || skip.SkippedTo != 2 {
+		t.Errorf("unexpected skip marker %+v", skip)
+	}
+	if !strings.Contains(lines[1], txHashes[4].Hex()) {
+		t.Errorf("unexpected trace after skip marker: %s", lines[1])
+	}
+}
+
+func TestBrontesTraceCallAt(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, _ := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	var (
+		api   = NewBrontesAPI(backend)
+		block = backend.chain.GetBlockByNumber(2)
+		// The recipient of all transfers of the test chain.
+		to = *block.Transactions()[0].To()
+	)
+	// Block 1 transferred 2000 wei to the recipient, and every transaction of
+	// block 2 another 1000 wei.
+	for i, want := range []uint64{2000, 3000} {
+		result, err := api.TraceCallAt(context.Background(), block.Hash(), hexutil.Uint(i), ethapi.TransactionArgs{To: &to}, nil)
+		if err != nil {
+			t.Fatalf("index %d: failed to trace call: %v", i, err)
+		}
+		var res struct {
+			ToBalance *hexutil.Big `json:"to_balance"`
+		}
+		if err := json.Unmarshal(result.(json.RawMessage), &res); err != nil {
+			t.Fatalf("index %d: invalid result: %v", i, err)
+		}
+		if res.ToBalance.ToInt().Uint64() != want {
+			t.Errorf("index %d: balance mismatch: have %v, want %d", i, res.ToBalance, want)
+		}
+	}
+	if _, err := api.TraceCallAt(context.Background(), block.Hash(), 2, ethapi.TransactionArgs{To: &to}, nil); err == nil {
+		t.Errorf("expected error for out of range index")
+	}
+}
+
+func TestBrontesCache(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, hashes := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	var (
+		dir   = t.TempDir()
+		api   = newBrontesAPI(backend, &BrontesConfig{CacheSize: 1024 * 1024, CacheDir: dir})
+		block = backend.chain.GetBlockByNumber(2)
+	)
+	traced, err := api.TraceBlockByNumber(context.Background(), 2, nil)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	// Cached traces are served even once the state has been pruned, both
+	// from memory and from disk.
+	pruned := &prunedBackend{backend, 4}
+	for _, cache := range []*brontesCache{api.cache, newBrontesCache(0, dir, 0)} {
+		api := &BrontesAPI{api: NewAPI(pruned), cache: cache}
+		results, err := api.TraceBlockByNumber(context.Background(), 2, nil)
+		if err != nil {
+			t.Fatalf("failed to trace block from cache: %v", err)
+		}
+		if !reflect.DeepEqual(results, traced) {
+			t.Errorf("cached block traces mismatch: have %v, want %v", results, traced)
+		}
+		result, err := api.TraceTransaction(context.Background(), hashes[2], nil)
+		if err != nil {
+			t.Fatalf("failed to trace transaction from cache: %v", err)
+		}
+		if !reflect.DeepEqual(result, traced[0].Result) {
+			t.Errorf("cached transaction trace mismatch: have %s, want %s", result, traced[0].Result)
+		}
+		// Traces requested with other tracer options are not shared.
+		config := &BrontesTraceConfig{TracerConfig: json.RawMessage(`{"withLog":true}`)}
+		if _, err := api.TraceTransaction(context.Background(), hashes[2], config); err == nil {
+			t.Errorf("expected error for uncached trace of pruned block")
+		}
+	}
+	if results := newBrontesCache(0, t.TempDir(), 0).getBlock(block, &TraceConfig{}); results != nil {
+		t.Errorf("expected no traces from empty cache, have %v", results)
+	}
+}
+
+func TestBrontesCacheable(t *testing.T) {
+	for _, tt := range []struct {
+		config string
+		want   bool
+	}{
+		{``, true},
+		{`{"recordSteps":true}`, true},
+		{`{"partialResults":true}`, false},
+		{`{"decodeAbis":true}`, false},
+		{`{"fetchAbis":true}`, false},
+		{`{"resolveSelectors":true}`, false},
+		{`{"attachOrderflow":true}`, false},
+		{`{"resolveNames":true}`, false},
+		{`{"resolveEns":true}`, false},
+	} {
+		if have := brontesCacheable(&TraceConfig{TracerConfig: json.RawMessage(tt.config)}); have != tt.want {
+			t.Errorf("config %s: cacheable mismatch: have %v, want %v", tt.config, have, tt.want)
+		}
+	}
+	// Traces cut short by a failure are not cached.
+	cache := newBrontesCache(1024, t.TempDir(), 0)
+	cache.put(common.Hash{1}, json.RawMessage(`{"errors":[{"stage":"execution"}]}`))
+	if trace, ok := cache.get(common.Hash{1}); ok {
+		t.Errorf("failed trace cached: %s", trace)
+	}
+}
+
+func TestBrontesCacheEviction(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		trace = json.RawMessage(`{"trace":"` + strings.Repeat("0", 20) + `"}`) // 32 bytes
+		start = time.Now().Add(-time.Hour)
+	)
+	// A trace cached by an earlier run is counted and evicted first.
+	old := newBrontesCache(0, dir, 0)
+	old.put(common.Hash{0}, trace)
+	os.Chtimes(old.path(common.Hash{0}), start, start)
+
+	cache := newBrontesCache(0, dir, 100)
+	for i := byte(1); i <= 3; i++ {
+		cache.put(common.Hash{i}, trace)
+		at := start.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(cache.path(common.Hash{i}), at, at)
+	}
+	// The fourth trace exceeds the limit, evicting the oldest down to 75 bytes.
+	for i, want := range []bool{false, false, true, true} {
+		if _, ok := cache.get(common.Hash{byte(i)}); ok != want {
+			t.Errorf("trace %d: cached mismatch: have %v, want %v", i, ok, want)
+		}
+	}
+	if cache.dirSize > 100 {
+		t.Errorf("disk cache exceeds its size: %d bytes", cache.dirSize)
+	}
+}
+
+func TestBrontesTraceCallOverrides(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, _ := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	var (
+		api   = NewBrontesAPI(backend)
+		block = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		stub  = common.HexToAddress("0x0100")
+		price = (*hexutil.Big)(big.NewInt(1))
+		// Fund the sender of the calls to pay for gas.
+		state = &override.StateOverride{
+			common.Address{}: {Balance: (*hexutil.Big)(big.NewInt(params.Ether))},
+		}
+	)
+	// Fee caps below the base fee are rejected unless the check is disabled.
+	args := ethapi.TransactionArgs{To: &stub, GasPrice: price}
+	config := &BrontesCallConfig{StateOverrides: state}
+	if _, err := api.TraceCall(context.Background(), args, block, config); err == nil {
+		t.Fatalf("expected error for gas price below base fee")
+	}
+	config.EVMOverrides = &EVMOverrides{
+		NoBaseFee: true,
+		Precompiles: map[common.Address]*PrecompileStub{
+			stub: {Output: hexutil.Bytes{0xca, 0xfe}, Gas: 100},
+		},
+	}
+	result, err := api.TraceCall(context.Background(), args, block, config)
+	if err != nil {
+		t.Fatalf("failed to trace call: %v", err)
+	}
+	var res struct {
+		Output hexutil.Bytes `json:"output"`
+	}
+	if err := json.Unmarshal(result.(json.RawMessage), &res); err != nil {
+		t.Fatalf("invalid result: %v", err)
+	}
+	if !bytes.Equal(res.Output, []byte{0xca, 0xfe}) {
+		t.Errorf("stub output mismatch: have %x, want cafe", res.Output)
+	}
+}
+
+// stubAnnotationSink records the annotations written to it.
+type stubAnnotationSink struct {
+	traces      []*brontes.TxTrace
+	annotations []brontes.Annotation
+}
+
+func (s *stubAnnotationSink) WriteAnnotations(trace *brontes.TxTrace, annotations []brontes.Annotation) error {
+	s.traces = append(s.traces, trace)
+	s.annotations = append(s.annotations, annotations...)
+	return nil
+}
+
+func TestBrontesAnnotations(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, hashes := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	sink := new(stubAnnotationSink)
+	brontes.SetAnnotationSink(sink)
+	defer brontes.SetAnnotationSink(nil)
+
+	api := NewBrontesAPI(backend)
+	admin := &BrontesAdminAPI{api: api}
+	if err := admin.AnnotateTrace(context.Background(), common.Hash{1}, brontes.Annotation{Note: "unknown"}); err == nil {
+		t.Errorf("expected error annotating unknown transaction")
+	}
+	if err := admin.AnnotateTrace(context.Background(), hashes[1], brontes.Annotation{Note: strings.Repeat("x", maxAnnotationSize)}); err == nil {
+		t.Errorf("expected error for oversized annotation")
+	}
+	annotation := brontes.Annotation{Labels: []string{"exploit"}, IncidentId: "INC-1", Classification: "drainer"}
+	for i := 0; i < 2; i++ {
+		if err := admin.AnnotateTrace(context.Background(), hashes[1], annotation); err != nil {
+			t.Fatalf("failed to annotate trace: %v", err)
+		}
+	}
+	annotations, err := api.GetAnnotations(context.Background(), hashes[1])
+	if err != nil {
+		t.Fatalf("failed to get annotations: %v", err)
+	}
+	if len(annotations) != 2 || annotations[0].IncidentId != "INC-1" || annotations[0].CreatedAt == 0 {
+		t.Errorf("unexpected annotations: %+v", annotations)
+	}
+	// Every stored annotation is written through to the sink.
+	if !reflect.DeepEqual(sink.annotations, annotations) {
+		t.Errorf("sink annotations mismatch: have %+v, want %+v", sink.annotations, annotations)
+	}
+	for _, trace := range sink.traces {
+		if trace.TxHash != hashes[1] || trace.BlockNumber == 0 || trace.ChainId != backend.ChainConfig().ChainID.Uint64() {
+			t.Errorf("unexpected sink position: %+v", trace)
+		}
+	}
+	result, err := api.TraceTransaction(context.Background(), hashes[1], nil)
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	var trace struct {
+		TxHash      common.Hash          `json:"tx_hash"`
+		Annotations []brontes.Annotation `json:"annotations"`
+	}
+	if err := json.Unmarshal(result.(json.RawMessage), &trace); err != nil {
+		t.Fatalf("failed to decode trace: %v", err)
+	}
+	if trace.TxHash != hashes[1] || !reflect.DeepEqual(trace.Annotations, annotations) {
+		t.Errorf("unexpected annotated trace: %s", result)
+	}
+	// Traces of other transactions are left untouched.
+	result, err = api.TraceTransaction(context.Background(), hashes[0], nil)
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	if strings.Contains(string(result.(json.RawMessage)), "annotations") {
+		t.Errorf("unexpected annotations in trace: %s", result)
+	}
+}
+
+func TestBrontesTraceTransactions(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, hashes := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	api := NewBrontesAPI(backend)
+	requested := []common.Hash{hashes[5], {1}, hashes[0], hashes[4]}
+	results, err := api.TraceTransactions(context.Background(), requested, nil)
+	if err != nil {
+		t.Fatalf("failed to trace transactions: %v", err)
+	}
+	if len(results) != len(requested) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), len(requested))
+	}
+	for i, hash := range requested {
+		if results[i].TxHash != hash {
+			t.Errorf("result %d: hash mismatch: have %x, want %x", i, results[i].TxHash, hash)
+		}
+		if i == 1 {
+			if results[i].Error == "" {
+				t.Errorf("expected error for unknown transaction")
+			}
+			continue
+		}
+		want, err := api.TraceTransaction(context.Background(), hash, nil)
+		if err != nil {
+			t.Fatalf("failed to trace transaction: %v", err)
+		}
+		if !reflect.DeepEqual(results[i].Result, want) {
+			t.Errorf("result %d: trace mismatch: have %s, want %s", i, results[i].Result, want)
+		}
+	}
+	// The blocks to replay count against the range limit.
+	limited := newBrontesAPI(backend, &BrontesConfig{MaxBlocks: 1})
+	if _, err := limited.TraceTransactions(context.Background(), requested, nil); !errors.Is(err, errBrontesBudget) {
+		t.Errorf("expected budget error, have %v", err)
+	}
+	// Without a configured limit, the default range limit applies.
+	if limit := api.maxBlocks(); limit != defaultMaxBrontesBlocks {
+		t.Errorf("default block limit mismatch: have %d, want %d", limit, defaultMaxBrontesBlocks)
+	}
+}
+
+func TestBrontesTraceBlockEncoding(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, hashes := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	// The concurrently encoded traces of a block match the traces of its
+	// transactions encoded one by one.
+	api := NewBrontesAPI(backend)
+	results, err := api.TraceBlockByNumber(context.Background(), 2, nil)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("result count mismatch: have %d, want 2", len(results))
+	}
+	config, _ := (*BrontesTraceConfig)(nil).traceConfig()
+	for i, res := range results {
+		have, ok := res.Result.(json.RawMessage)
+		if !ok {
+			t.Fatalf("result %d: unexpected type %T", i, res.Result)
+		}
+		want, err := api.api.TraceTransaction(context.Background(), hashes[2+i], config)
+		if err != nil {
+			t.Fatalf("failed to trace transaction: %v", err)
+		}
+		if !bytes.Equal(have, want.(json.RawMessage)) {
+			t.Errorf("result %d: trace mismatch: have %s, want %s", i, have, want)
+		}
+	}
+
+	// Encoding failures are reported per transaction.
+	failing := func() (json.RawMessage, error) { return nil, errors.New("boom") }
+	results = []*txTraceResult{{Result: deferredResult(failing)}, {Result: json.RawMessage(`{}`)}}
+	if err := encodeResults(context.Background(), results); err != nil {
+		t.Fatalf("failed to encode results: %v", err)
+	}
+	if results[0].Error != "boom" || results[0].Result != nil {
+		t.Errorf("unexpected result of failed encoding: %+v", results[0])
+	}
+	if string(results[1].Result.(json.RawMessage)) != `{}` {
+		t.Errorf("encoded result changed: %s", results[1].Result)
+	}
+}
+
+func TestBlockTraceRunner(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, _ := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	block := backend.chain.GetBlockByNumber(2)
+	parent := backend.chain.GetBlockByNumber(1)
+	statedb, err := backend.chain.StateAt(parent.Root())
+	if err != nil {
+		t.Fatalf("failed to get parent state: %v", err)
+	}
+	config := json.RawMessage(`{"schemaVersion":1}`)
+	runner := NewBlockTraceRunner(backend.chain.Config(), config)
+	vmctx := core.NewEVMBlockContext(block.Header(), backend.chain, nil)
+	results, err := runner.Run(context.Background(), block, statedb, vmctx)
+	if err != nil {
+		t.Fatalf("failed to run block: %v", err)
+	}
+
+	// The traces match the ones served over RPC, which replay the block the
+	// same way.
+	want, err := NewBrontesAPI(backend).TraceBlockByNumber(context.Background(), 2, &BrontesTraceConfig{TracerConfig: config})
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(results) != len(want) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), len(want))
+	}
+	for i := range results {
+		if !bytes.Equal(results[i], want[i].Result.(json.RawMessage)) {
+			t.Errorf("result %d: trace mismatch: have %s, want %s", i, results[i], want[i].Result)
+		}
+	}
+	// The state is left after the transactions of the block.
+	if balance := statedb.GetBalance(*block.Transactions()[0].To()); balance.Uint64() != 4000 {
+		t.Errorf("recipient balance mismatch: have %v, want 4000", balance)
+	}
+
+	// Cancelled runs fail.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := runner.Run(ctx, block, statedb, vmctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation, have %v", err)
+	}
+}
+
+func TestBrontesTraceCallMany(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, _ := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	var (
+		api   = NewBrontesAPI(backend)
+		block = backend.chain.CurrentBlock()
+		// The recipient of all transfers of the test chain, holding 6000 wei.
+		to     = *backend.chain.GetBlockByNumber(1).Transactions()[0].To()
+		from   = common.HexToAddress("0x0200")
+		value  = (*hexutil.Big)(big.NewInt(500))
+		config = &BrontesCallConfig{StateOverrides: &override.StateOverride{
+			from: {Balance: (*hexutil.Big)(big.NewInt(params.Ether))},
+		}}
+		calls = []ethapi.TransactionArgs{
+			{From: &from, To: &to, Value: value},
+			{From: &from, To: &to, Value: value},
+		}
+	)
+	results, err := api.TraceCallMany(context.Background(), calls, rpc.BlockNumberOrHashWithHash(block.Hash(), false), config)
+	if err != nil {
+		t.Fatalf("failed to trace calls: %v", err)
+	}
+	if len(results) != len(calls) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), len(calls))
+	}
+	for i, result := range results {
+		if result.Error != "" {
+			t.Fatalf("call %d: unexpected error: %v", i, result.Error)
+		}
+		// Every call sees the transfers of the previous ones.
+		var res struct {
+			ToBalance *hexutil.Big `json:"to_balance"`
+		}
+		if err := json.Unmarshal(result.Trace.(json.RawMessage), &res); err != nil {
+			t.Fatalf("call %d: invalid result: %v", i, err)
+		}
+		if have, want := res.ToBalance.ToInt().Uint64(), uint64(6000+500*i); have != want {
+			t.Errorf("call %d: balance mismatch: have %d, want %d", i, have, want)
+		}
+		// The state diff covers all calls so far.
+		diff := result.StateDiff[to]
+		if diff == nil || diff.Balance == nil {
+			t.Fatalf("call %d: missing balance diff of recipient", i)
+		}
+		if have, want := diff.Balance.From.ToInt().Uint64(), uint64(6000); have != want {
+			t.Errorf("call %d: original balance mismatch: have %d, want %d", i, have, want)
+		}
+		if have, want := diff.Balance.To.ToInt().Uint64(), uint64(6000+500*(i+1)); have != want {
+			t.Errorf("call %d: new balance mismatch: have %d, want %d", i, have, want)
+		}
+		if diff := result.StateDiff[from]; diff == nil || diff.Nonce == nil || uint64(diff.Nonce.To) != uint64(i+1) {
+			t.Errorf("call %d: nonce diff mismatch: have %+v", i, diff)
+		}
+	}
+	if _, err := api.TraceCallMany(context.Background(), nil, rpc.BlockNumberOrHashWithHash(block.Hash(), false), nil); err == nil {
+		t.Errorf("expected error for empty call list")
+	}
+}
+
+func TestBrontesSession(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, _ := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	var (
+		ctx    = context.Background()
+		api    = newBrontesAPI(backend, &BrontesConfig{MaxSessions: 1})
+		block  = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		to     = *backend.chain.GetBlockByNumber(1).Transactions()[0].To()
+		from   = common.HexToAddress("0x0200")
+		value  = (*hexutil.Big)(big.NewInt(500))
+		args   = ethapi.TransactionArgs{From: &from, To: &to, Value: value}
+		config = &BrontesCallConfig{StateOverrides: &override.StateOverride{
+			from: {Balance: (*hexutil.Big)(big.NewInt(params.Ether))},
+		}}
+	)
+	session, err := api.CreateSession(ctx, block, config)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if _, err := api.CreateSession(ctx, block, config); !errors.Is(err, errBrontesTooManySessions) {
+		t.Fatalf("expected session limit error, have %v", err)
+	}
+	balance := func(trace interface{}) uint64 {
+		var res struct {
+			ToBalance *hexutil.Big `json:"to_balance"`
+		}
+		if err := json.Unmarshal(trace.(json.RawMessage), &res); err != nil {
+			t.Fatalf("invalid result: %v", err)
+		}
+		return res.ToBalance.ToInt().Uint64()
+	}
+	// Sent transactions build upon each other, calls are discarded.
+	for i := 0; i < 2; i++ {
+		trace, err := api.SessionCall(ctx, session.Id, args)
+		if err != nil {
+			t.Fatalf("failed to call: %v", err)
+		}
+		if have, want := balance(trace), uint64(6000+500*i); have != want {
+			t.Errorf("call %d: balance mismatch: have %d, want %d", i, have, want)
+		}
+		result, err := api.SessionSend(ctx, session.Id, args)
+		if err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+		if have, want := balance(result.Trace), uint64(6000+500*i); have != want {
+			t.Errorf("send %d: balance mismatch: have %d, want %d", i, have, want)
+		}
+		if have, want := result.StateDiff[to].Balance.To.ToInt().Uint64(), uint64(6000+500*(i+1)); have != want {
+			t.Errorf("send %d: diff mismatch: have %d, want %d", i, have, want)
+		}
+	}
+	results, err := api.SessionTraces(ctx, session.Id)
+	if err != nil {
+		t.Fatalf("failed to get traces: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("trace count mismatch: have %d, want 2", len(results))
+	}
+	if err := api.DiscardSession(session.Id); err != nil {
+		t.Fatalf("failed to discard session: %v", err)
+	}
+	if _, err := api.SessionSend(ctx, session.Id, args); !errors.Is(err, errBrontesSessionNotFound) {
+		t.Errorf("expected missing session error, have %v", err)
+	}
+	// Idle sessions expire.
+	api.config.SessionTimeout = time.Nanosecond
+	session, err = api.CreateSession(ctx, block, config)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := api.SessionTraces(ctx, session.Id); !errors.Is(err, errBrontesSessionNotFound) {
+		t.Errorf("expected expired session error, have %v", err)
+	}
+}
+
+func TestBrontesEstimateGasWithTrace(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, _ := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	var (
+		api      = NewBrontesAPI(backend)
+		block    = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		to       = *backend.chain.GetBlockByNumber(1).Transactions()[0].To()
+		reverter = common.HexToAddress("0x0300")
+		config   = &BrontesCallConfig{StateOverrides: &override.StateOverride{
+			// PUSH1 0 PUSH1 0 REVERT
+			reverter: {Code: &hexutil.Bytes{0x60, 0x00, 0x60, 0x00, 0xfd}},
+		}}
+	)
+	estimate, err := api.EstimateGasWithTrace(context.Background(), ethapi.TransactionArgs{To: &to}, block, config)
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if estimate.Gas != hexutil.Uint64(params.TxGas) || estimate.Error != "" || estimate.Trace == nil {
+		t.Errorf("transfer estimate mismatch: have %+v", estimate)
+	}
+	estimate, err = api.EstimateGasWithTrace(context.Background(), ethapi.TransactionArgs{To: &reverter}, block, config)
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if estimate.Gas != 0 || estimate.Error != vm.ErrExecutionReverted.Error() {
+		t.Errorf("revert estimate mismatch: have %+v", estimate)
+	}
+	if estimate.Trace == nil {
+		t.Errorf("missing trace of reverting call")
+	}
+}
+
+func TestBrontesTraceUncles(t *testing.T) {
+	registerStubBrontesTracer()
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	var (
+		signer  = types.HomesteadSigner{}
+		uncles  []*types.Header
+		uncleTx *types.Transaction
+	)
+	transfer := func(nonce uint64, value int64, gasPrice *big.Int) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			To:       &accounts[1].addr,
+			Value:    big.NewInt(value),
+			Gas:      params.TxGas,
+			GasPrice: gasPrice,
+		}), signer, accounts[0].key)
+		return tx
+	}
+	// The third block includes two uncles competing with the second block,
+	// of which only the first is known to the node.
+	backend := newTestBackend(t, 3, genesis, func(i int, b *core.BlockGen) {
+		b.AddTx(transfer(uint64(i), 1000, b.BaseFee()))
+		if i != 2 {
+			return
+		}
+		uncleTx = transfer(1, 3000, new(big.Int).Mul(b.BaseFee(), big.NewInt(2)))
+		txHashes := []common.Hash{types.DeriveSha(types.Transactions{uncleTx}, trie.NewStackTrie(nil)), types.EmptyTxsHash}
+		for j, coinbase := range []common.Address{{0xaa}, {0xbb}} {
+			uncle := &types.Header{
+				ParentHash: b.PrevBlock(0).Hash(),
+				Number:     big.NewInt(2),
+				Coinbase:   coinbase,
+				TxHash:     txHashes[j],
+			}
+			b.AddUncle(uncle)
+			uncles = append(uncles, uncle)
+		}
+	})
+	defer backend.chain.Stop()
+	rawdb.WriteBlock(backend.chaindb, types.NewBlockWithHeader(uncles[0]).WithBody(types.Body{Transactions: []*types.Transaction{uncleTx}}))
+
+	api := NewBrontesAPI(backend)
+	results, err := api.TraceUncles(context.Background(), 3, nil)
+	if err != nil {
+		t.Fatalf("failed to trace uncles: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("result count mismatch: have %d, want 2", len(results))
+	}
+	included := backend.chain.GetBlockByNumber(3).Hash()
+	for i, res := range results {
+		if res.Hash != uncles[i].Hash() || res.Number != 2 || res.IncludedIn != included || res.Canonical {
+			t.Errorf("result %d: unexpected uncle %+v", i, res)
+		}
+	}
+	if results[0].Error != "" || len(results[0].Traces) != 1 {
+		t.Fatalf("unexpected traces of known uncle: %+v", results[0])
+	}
+	// The uncle executes on top of the state after the first block.
+	var trace struct {
+		TxHash    common.Hash  `json:"tx_hash"`
+		ToBalance *hexutil.Big `json:"to_balance"`
+	}
+	if err := json.Unmarshal(results[0].Traces[0].Result.(json.RawMessage), &trace); err != nil {
+		t.Fatalf("failed to decode trace: %v", err)
+	}
+	if trace.TxHash != uncleTx.Hash() || trace.ToBalance.ToInt().Int64() != 1000 {
+		t.Errorf("unexpected uncle trace: %+v", trace)
+	}
+	if results[1].Error == "" || results[1].Traces != nil {
+		t.Errorf("expected error for uncle without body: %+v", results[1])
+	}
+
+	// Blocks without uncles have nothing to trace.
+	if results, err := api.TraceUncles(context.Background(), 2, nil); err != nil || len(results) != 0 {
+		t.Errorf("unexpected uncles of block 2: %v, %v", results, err)
+	}
+}
+
+// pendingTestBackend is a test backend assembling a fixed pending block.
+type pendingTestBackend struct {
+	*testBackend
+	pending *types.Block
+}
+
+func (b *pendingTestBackend) Pending() (*types.Block, types.Receipts, *state.StateDB) {
+	return b.pending, nil, nil
+}
+
+func TestBrontesTracePendingBlock(t *testing.T) {
+	registerStubBrontesTracer()
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	transfer := func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{
+			Nonce:    uint64(i),
+			To:       &accounts[1].addr,
+			Value:    big.NewInt(1000),
+			Gas:      params.TxGas,
+			GasPrice: b.BaseFee(),
+		}), types.HomesteadSigner{}, accounts[0].key)
+		b.AddTx(tx)
+	}
+	backend := newTestBackend(t, 3, genesis, transfer)
+	defer backend.chain.Stop()
+
+	// The pending block holds the fourth transfer on top of the head.
+	head := backend.chain.CurrentBlock()
+	blocks, _ := core.GenerateChain(backend.chainConfig, backend.chain.GetBlock(head.Hash(), head.Number.Uint64()), backend.engine, backend.chaindb, 1, func(i int, b *core.BlockGen) {
+		transfer(3, b)
+	})
+	pending := &pendingTestBackend{testBackend: backend}
+	api := NewBrontesAPI(pending)
+	if _, err := api.TracePendingBlock(context.Background(), nil); err == nil {
+		t.Fatalf("expected error without pending block")
+	}
+	pending.pending = blocks[0]
+	results, err := api.TracePendingBlock(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("failed to trace pending block: %v", err)
+	}
+	if len(results) != 1 || results[0].TxHash != blocks[0].Transactions()[0].Hash() {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	// The pending transaction executes on top of the head state.
+	var trace struct {
+		ToBalance *hexutil.Big `json:"to_balance"`
+	}
+	if err := json.Unmarshal(results[0].Result.(json.RawMessage), &trace); err != nil {
+		t.Fatalf("failed to decode trace: %v", err)
+	}
+	if trace.ToBalance.ToInt().Int64() != 3000 {
+		t.Errorf("unexpected balance before pending transaction: %v", trace.ToBalance)
+	}
+}
+
+func TestBrontesOrderflow(t *testing.T) {
+	backend, hashes := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	var (
+		api   = NewBrontesAPI(backend)
+		admin = &BrontesAdminAPI{api: api}
+	)
+	orderflow, err := api.GetOrderflow(context.Background(), hashes[0])
+	if err != nil || orderflow != nil {
+		t.Fatalf("unexpected orderflow before set: %+v, %v", orderflow, err)
+	}
+	if err := admin.SetOrderflow(context.Background(), hashes[0], brontes.Orderflow{Source: brontes.OrderflowBundle}); err == nil {
+		t.Errorf("expected error for bundle without hash")
+	}
+	var (
+		bundle = common.Hash{2}
+		want   = brontes.Orderflow{Source: brontes.OrderflowBundle, BundleHash: &bundle, Tags: []string{"searcher"}}
+	)
+	if err := admin.SetOrderflow(context.Background(), common.Hash{1}, want); err == nil {
+		t.Errorf("expected error for unknown transaction")
+	}
+	if err := admin.SetOrderflow(context.Background(), hashes[0], want); err != nil {
+		t.Fatalf("failed to set orderflow: %v", err)
+	}
+	orderflow, err = api.GetOrderflow(context.Background(), hashes[0])
+	if err != nil {
+		t.Fatalf("failed to get orderflow: %v", err)
+	}
+	if !reflect.DeepEqual(orderflow, &want) {
+		t.Errorf("orderflow mismatch: have %+v, want %+v", orderflow, want)
+	}
+}
+
+func TestBrontesTraceFixture(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, _ := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		chainId  = params.SepoliaChainConfig.ChainID
+	)
+	// The contract returns a zero word pushed by PUSH0, which only exists
+	// from Shanghai on.
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainId), &types.DynamicFeeTx{
+		ChainID:   chainId,
+		To:        &contract,
+		Gas:       100000,
+		GasFeeCap: big.NewInt(params.GWei),
+	})
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	enc, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode transaction: %v", err)
+	}
+	newFixture := func(network string, time uint64) BrontesFixture {
+		return BrontesFixture{
+			Network: network,
+			Alloc: types.GenesisAlloc{
+				sender:   {Balance: big.NewInt(params.Ether)},
+				contract: {Code: common.FromHex("0x5f5f5260205ff3")},
+			},
+			Block: BrontesFixtureBlock{
+				Number:   hexutil.Uint64(params.SepoliaChainConfig.MergeNetsplitBlock.Uint64()),
+				Time:     hexutil.Uint64(time),
+				GasLimit: 30_000_000,
+				BaseFee:  (*hexutil.Big)(big.NewInt(params.GWei)),
+			},
+			Transaction: enc,
+		}
+	}
+	output := func(result interface{}) hexutil.Bytes {
+		var res struct {
+			TxHash common.Hash   `json:"tx_hash"`
+			Output hexutil.Bytes `json:"output"`
+		}
+		if err := json.Unmarshal(result.(json.RawMessage), &res); err != nil {
+			t.Fatalf("invalid result: %v", err)
+		}
+		if res.TxHash != tx.Hash() {
+			t.Errorf("tx hash mismatch: have %x, want %x", res.TxHash, tx.Hash())
+		}
+		return res.Output
+	}
+	api := NewBrontesAPI(backend)
+	shanghai := *params.SepoliaChainConfig.ShanghaiTime
+
+	result, err := api.TraceFixture(context.Background(), newFixture("sepolia", shanghai), nil)
+	if err != nil {
+		t.Fatalf("failed to trace fixture: %v", err)
+	}
+	if have := output(result); !bytes.Equal(have, make([]byte, 32)) {
+		t.Errorf("unexpected output after Shanghai: %x", have)
+	}
+	result, err = api.TraceFixture(context.Background(), newFixture("sepolia", shanghai-1), nil)
+	if err != nil {
+		t.Fatalf("failed to trace fixture: %v", err)
+	}
+	if have := output(result); len(have) != 0 {
+		t.Errorf("unexpected output before Shanghai: %x", have)
+	}
+	// The transaction is signed for another chain than the node's.
+	if _, err := api.TraceFixture(context.Background(), newFixture("", shanghai), nil); err == nil {
+		t.Errorf("expected error tracing with the config of the node")
+	}
+	if _, err := api.TraceFixture(context.Background(), newFixture("goerli", shanghai), nil); err == nil {
+		t.Errorf("expected error for unknown network")
+	}
+	// Balances that do not fit an account are rejected rather than panicking.
+	for _, balance := range []*big.Int{big.NewInt(-1), new(big.Int).Lsh(common.Big1, 256)} {
+		fixture := newFixture("sepolia", shanghai)
+		fixture.Alloc[contract] = types.Account{Code: fixture.Alloc[contract].Code, Balance: balance}
+		if _, err := api.TraceFixture(context.Background(), fixture, nil); err == nil {
+			t.Errorf("expected error for balance %v", balance)
+		}
+	}
+}
+
+func TestBrontesRetracer(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, hashes := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	retracer := &brontesRetracer{api: NewBrontesAPI(backend)}
+	trace, err := retracer.Retrace(context.Background(), hashes[1], brontes.DefaultEscalationConfig)
+	if err != nil {
+		t.Fatalf("failed to retrace: %v", err)
+	}
+	var result struct {
+		TxHash common.Hash                    `json:"tx_hash"`
+		Config brontes.TracingInspectorConfig `json:"config"`
+	}
+	if err := json.Unmarshal(trace, &result); err != nil {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+	if result.TxHash != hashes[1] {
+		t.Errorf("traced %v, want %v", result.TxHash, hashes[1])
+	}
+	if !result.Config.RecordSteps || !result.Config.RecordStateDiff {
+		t.Errorf("trace not in full detail: %+v", result.Config)
+	}
+	// Transactions not in the chain yet cannot be traced.
+	if _, err := retracer.Retrace(context.Background(), common.Hash{1}, brontes.DefaultEscalationConfig); err == nil {
+		t.Error("expected error for unknown transaction")
+	}
+}
+
+func TestBrontesBackfill(t *testing.T) {
+	registerStubBrontesTracer()
+	backend, hashes := newBrontesTestBackend(t)
+	defer backend.chain.Stop()
+
+	var (
+		api    = NewBrontesAPI(backend)
+		ctx    = context.Background()
+		dir    = t.TempDir()
+		newest = BrontesBackfillNewestFirst
+	)
+	if _, err := api.BackfillStatus(ctx); !errors.Is(err, errBrontesBackfillDisabled) {
+		t.Fatalf("have %v, want %v", err, errBrontesBackfillDisabled)
+	}
+	open := func() *brontesBackfillService {
+		backfiller, err := openBrontesBackfiller(api, dir)
+		if err != nil {
+			t.Fatalf("failed to open backfill: %v", err)
+		}
+		service := &brontesBackfillService{backfiller: backfiller}
+		service.Start()
+		return service
+	}
+	service := open()
+
+	// Queue the whole chain newest first and block 1 on its own while paused.
+	if err := api.PauseBackfill(ctx); err != nil {
+		t.Fatalf("failed to pause backfill: %v", err)
+	}
+	all, err := api.QueueBackfill(ctx, 0, rpc.LatestBlockNumber, &newest)
+	if err != nil {
+		t.Fatalf("failed to queue backfill: %v", err)
+	}
+	if all.Low != 1 || all.High != 3 {
+		t.Fatalf("have range %d-%d, want 1-3", all.Low, all.High)
+	}
+	single, err := api.QueueBackfill(ctx, 1, 1, nil)
+	if err != nil {
+		t.Fatalf("failed to queue backfill: %v", err)
+	}
+	if _, err := api.QueueBackfill(ctx, 2, 1, nil); err == nil {
+		t.Error("expected error for empty range")
+	}
+	invalid := "random"
+	if _, err := api.QueueBackfill(ctx, 1, 2, &invalid); err == nil {
+		t.Error("expected error for invalid order")
+	}
+	if err := api.PrioritizeBackfill(ctx, single.Id, nil); err != nil {
+		t.Fatalf("failed to prioritize backfill: %v", err)
+	}
+	if err := api.CancelBackfill(ctx, 42); !errors.Is(err, errBrontesBackfillNotFound) {
+		t.Errorf("have %v, want %v", err, errBrontesBackfillNotFound)
+	}
+	// The queue survives restarts, paused.
+	if err := service.Stop(); err != nil {
+		t.Fatalf("failed to stop backfill: %v", err)
+	}
+	service = open()
+	defer service.Stop()
+
+	status, err := api.BackfillStatus(ctx)
+	if err != nil {
+		t.Fatalf("failed to get backfill status: %v", err)
+	}
+	if !status.Paused || status.Remaining != 4 || len(status.Ranges) != 2 || status.Ranges[0].Id != single.Id || status.Ranges[1].Id != all.Id {
+		t.Fatalf("unexpected backfill status: %+v", status)
+	}
+	if err := api.ResumeBackfill(ctx); err != nil {
+		t.Fatalf("failed to resume backfill: %v", err)
+	}
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if status, err = api.BackfillStatus(ctx); err != nil {
+			t.Fatalf("failed to get backfill status: %v", err)
+		}
+		if len(status.Ranges) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("backfill not done: %+v", status)
+		}
+	}
+	if status.Traced != 4 || status.Failed != 0 || status.Remaining != 0 || status.BlocksPerSecond == 0 {
+		t.Errorf("unexpected backfill status: %+v", status)
+	}
+
+	// Block 1 is traced first, then the chain from its head.
+	blob, err := os.ReadFile(filepath.Join(dir, brontesBackfilledFile))
+	if err != nil {
+		t.Fatalf("failed to read backfill output: %v", err)
+	}
+	var have []common.Hash
+	for _, line := range strings.Split(strings.TrimSpace(string(blob)), "\n") {
+		var trace struct {
+			TxHash common.Hash `json:"tx_hash"`
+		}
+		if err := json.Unmarshal([]byte(line), &trace); err != nil {
+			t.Fatalf("failed to parse trace: %v", err)
+		}
+		have = append(have, trace.TxHash)
+	}
+	want := []common.Hash{hashes[0], hashes[1], hashes[4], hashes[5], hashes[2], hashes[3], hashes[0], hashes[1]}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("have traces of %v, want %v", have, want)
+	}
+}
+
+func TestBrontesStatus(t *testing.T) {
+	backend, _ := newBrontesTestBackend(t)
+	api := NewBrontesAPI(backend)
+
+	status, err := api.Status(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if status.Head != 3 || status.Behind != 3 || status.LastBlock != nil {
+		t.Fatalf("have head %d, %d behind, last block %v, want nothing committed", status.Head, status.Behind, status.LastBlock)
+	}
+	block := backend.chain.GetBlockByNumber(1)
+	imported := time.Unix(int64(block.Time())+1, 0)
+	brontes.RecordBlockLatency(brontes.BlockLatency{
+		Number:    1,
+		Hash:      block.Hash(),
+		Timestamp: block.Time(),
+		Imported:  imported,
+		Traced:    imported.Add(10 * time.Millisecond),
+		Converted: imported.Add(15 * time.Millisecond),
+		Committed: imported.Add(20 * time.Millisecond),
+	})
+	if status, err = api.Status(context.Background()); err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if status.Behind != 2 || status.LastBlock == nil || status.LastBlock.Hash != block.Hash() {
+		t.Fatalf("have %d behind, last block %v, want 2 behind block 1", status.Behind, status.LastBlock)
+	}
+	if status.LastBlock.Lag != 1.02 {
+		t.Errorf("have lag %v, want 1.02", status.LastBlock.Lag)
+	}
+	want := map[string]BrontesStageLatency{
+		brontes.StageTraced:    {Count: 1, P50: 10, P90: 10, P99: 10, Max: 10},
+		brontes.StageConverted: {Count: 1, P50: 15, P90: 15, P99: 15, Max: 15},
+		brontes.StageCommitted: {Count: 1, P50: 20, P90: 20, P99: 20, Max: 20},
+	}
+	if !reflect.DeepEqual(status.Latency, want) {
+		t.Errorf("have latencies %v, want %v", status.Latency, want)
+	}
+}
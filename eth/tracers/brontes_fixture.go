@@ -0,0 +1,206 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+// brontesNetworks are the chain configs of the networks fixtures can name.
+var brontesNetworks = map[string]*params.ChainConfig{
+	"mainnet": params.MainnetChainConfig,
+	"sepolia": params.SepoliaChainConfig,
+	"holesky": params.HoleskyChainConfig,
+}
+
+// BrontesFixture is a transaction to replay outside of the chain of the node,
+// along with the state and block it executes in, like the fixtures of hive
+// and the state tests. It lets transactions of other networks be traced
+// with the forks of their own network.
+type BrontesFixture struct {
+	// Network names the network whose chain config applies, one of mainnet,
+	// sepolia and holesky. ChainConfig gives the config explicitly instead.
+	// The config of the node applies if neither is set.
+	Network     string              `json:"network,omitempty"`
+	ChainConfig *params.ChainConfig `json:"chainConfig,omitempty"`
+	// Alloc is the state before the transaction.
+	Alloc types.GenesisAlloc  `json:"alloc"`
+	Block BrontesFixtureBlock `json:"block"`
+	// Transaction is the signed transaction, in its binary encoding.
+	Transaction hexutil.Bytes `json:"transaction"`
+}
+
+// BrontesFixtureBlock is the header of the block a fixture executes in.
+// Blocks without difficulty are post-merge blocks, whose random value is zero
+// unless given. Hashes of previous blocks read by BLOCKHASH are zero.
+type BrontesFixtureBlock struct {
+	Number      hexutil.Uint64 `json:"number"`
+	Time        hexutil.Uint64 `json:"timestamp"`
+	Coinbase    common.Address `json:"coinbase"`
+	GasLimit    hexutil.Uint64 `json:"gasLimit"`
+	Difficulty  *hexutil.Big   `json:"difficulty"`
+	BaseFee     *hexutil.Big   `json:"baseFeePerGas"`
+	BlobBaseFee *hexutil.Big   `json:"blobBaseFee"`
+	Random      *common.Hash   `json:"random"`
+}
+
+// chainConfig returns the chain config the fixture executes with, falling
+// back to the given one.
+func (f *BrontesFixture) chainConfig(fallback *params.ChainConfig) (*params.ChainConfig, error) {
+	switch {
+	case f.Network != "" && f.ChainConfig != nil:
+		return nil, errors.New("network and chainConfig are mutually exclusive")
+	case f.ChainConfig != nil:
+		if f.ChainConfig.ChainID == nil {
+			return nil, errors.New("chainConfig without chain id")
+		}
+		return f.ChainConfig, nil
+	case f.Network != "":
+		config, ok := brontesNetworks[f.Network]
+		if !ok {
+			return nil, fmt.Errorf("unknown network %q", f.Network)
+		}
+		return config, nil
+	default:
+		return fallback, nil
+	}
+}
+
+// blockContext returns the context of the block of the fixture.
+func (b *BrontesFixtureBlock) blockContext(chainConfig *params.ChainConfig) vm.BlockContext {
+	context := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		Coinbase:    b.Coinbase,
+		BlockNumber: new(big.Int).SetUint64(uint64(b.Number)),
+		Time:        uint64(b.Time),
+		Difficulty:  new(big.Int),
+		GasLimit:    uint64(b.GasLimit),
+		Random:      b.Random,
+	}
+	if b.Difficulty != nil {
+		context.Difficulty = b.Difficulty.ToInt()
+	}
+	if context.Difficulty.Sign() == 0 && context.Random == nil {
+		context.Random = new(common.Hash)
+	}
+	if chainConfig.IsLondon(context.BlockNumber) {
+		context.BaseFee = new(big.Int)
+		if b.BaseFee != nil {
+			context.BaseFee = b.BaseFee.ToInt()
+		}
+	}
+	if b.BlobBaseFee != nil {
+		context.BlobBaseFee = b.BlobBaseFee.ToInt()
+	}
+	return context
+}
+
+// newFixtureState returns a state holding the given accounts.
+func newFixtureState(alloc types.GenesisAlloc) (*state.StateDB, error) {
+	db := state.NewDatabase(triedb.NewDatabase(rawdb.NewMemoryDatabase(), nil), nil)
+	statedb, err := state.New(types.EmptyRootHash, db)
+	if err != nil {
+		return nil, err
+	}
+	for addr, account := range alloc {
+		statedb.SetCode(addr, account.Code)
+		statedb.SetNonce(addr, account.Nonce, tracing.NonceChangeUnspecified)
+		if account.Balance != nil {
+			if account.Balance.Sign() < 0 {
+				return nil, fmt.Errorf("negative balance of account %v", addr)
+			}
+			balance, overflow := uint256.FromBig(account.Balance)
+			if overflow {
+				return nil, fmt.Errorf("balance of account %v overflows 256 bits", addr)
+			}
+			statedb.SetBalance(addr, balance, tracing.BalanceChangeUnspecified)
+		}
+		for key, value := range account.Storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
+	// Commit and re-open to start with a clean state.
+	root, err := statedb.Commit(0, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return state.New(root, db)
+}
+
+// TraceFixture returns the brontes trace of the transaction of a fixture,
+// executed on top of the state of the fixture with the chain config of its
+// network, independently of the chain of the node.
+func (api *BrontesAPI) TraceFixture(ctx context.Context, fixture BrontesFixture, config *BrontesTraceConfig) (interface{}, error) {
+	traceConfig, err := api.traceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	chainConfig, err := fixture.chainConfig(api.api.backend.ChainConfig())
+	if err != nil {
+		return nil, err
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(fixture.Transaction); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %v", err)
+	}
+	if err := api.newBudget().charge(tx.Gas()); err != nil {
+		return nil, err
+	}
+	timeout := defaultTraceTimeout
+	if traceConfig.Timeout != nil {
+		if timeout, err = time.ParseDuration(*traceConfig.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	statedb, err := newFixtureState(fixture.Alloc)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		vmctx  = fixture.Block.blockContext(chainConfig)
+		signer = types.MakeSigner(chainConfig, vmctx.BlockNumber, vmctx.Time, vmctx.ArbOSVersion)
+		txctx  = &Context{BlockNumber: vmctx.BlockNumber, TxHash: tx.Hash()}
+		runner = NewBlockTraceRunner(chainConfig, traceConfig.TracerConfig)
+	)
+	deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return runner.runTx(deadlineCtx, tx, signer, txctx, statedb, vmctx)
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// brontesAddressBookService installs the node-wide address book used by the
+// brontes tracers resolving names while the node runs.
+type brontesAddressBookService struct {
+	book *brontes.AddressBookFile
+}
+
+// Start implements node.Lifecycle.
+func (s *brontesAddressBookService) Start() error {
+	brontes.SetAddressBook(s.book)
+	return nil
+}
+
+// Stop implements node.Lifecycle.
+func (s *brontesAddressBookService) Stop() error {
+	brontes.SetAddressBook(nil)
+	s.book.Close()
+	return nil
+}
+
+// RegisterBrontesAddressBook loads the address book of the config and
+// installs it for the lifetime of the node. Nothing is done if no address
+// book is configured.
+func RegisterBrontesAddressBook(stack *node.Node, config *BrontesConfig) error {
+	if config == nil || config.AddressBook == "" {
+		return nil
+	}
+	book, err := brontes.OpenAddressBook(config.AddressBook)
+	if err != nil {
+		return err
+	}
+	stack.RegisterLifecycle(&brontesAddressBookService{book: book})
+	return nil
+}
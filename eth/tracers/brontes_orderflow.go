@@ -0,0 +1,58 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+)
+
+// RegisterBrontesOrderflow installs the node-wide orderflow store, kept in
+// the database of the node, so tracers opting in attach the orderflow
+// metadata supplied through the API.
+func RegisterBrontesOrderflow(backend Backend) {
+	brontes.SetOrderflowStore(brontes.NewOrderflowStore(backend.ChainDb()))
+}
+
+// SetOrderflow stores the orderflow metadata of a transaction known to the
+// node, such as the bundle it is part of, replacing any stored before. Traces
+// of tracers configured with attachOrderflow carry the metadata from then on.
+func (api *BrontesAdminAPI) SetOrderflow(ctx context.Context, hash common.Hash, orderflow brontes.Orderflow) error {
+	release, err := api.api.limiter.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	found, _, _, _, _, err := api.api.api.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("transaction %#x not found", hash)
+	}
+	return brontes.NewOrderflowStore(api.api.api.backend.ChainDb()).SetOrderflow(hash, &orderflow)
+}
+
+// GetOrderflow returns the orderflow metadata of a transaction, or nil if
+// none was supplied.
+func (api *BrontesAPI) GetOrderflow(ctx context.Context, hash common.Hash) (*brontes.Orderflow, error) {
+	return brontes.NewOrderflowStore(api.api.backend.ChainDb()).Orderflow(hash)
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// EVMOverrides relax the execution rules of a traced call, allowing what-if
+// simulations that strict replay semantics forbid.
+type EVMOverrides struct {
+	// NoBaseFee executes the call with a zero base fee, so fee caps below the
+	// base fee of the block are accepted.
+	NoBaseFee bool `json:"noBaseFee"`
+	// Precompiles installs stubs returning canned data at the given
+	// addresses, replacing the precompiles found there.
+	Precompiles map[common.Address]*PrecompileStub `json:"precompiles"`
+}
+
+// applyPrecompiles installs the precompile stubs into the given set.
+func (o *EVMOverrides) applyPrecompiles(precompiles vm.PrecompiledContracts) {
+	for addr, stub := range o.Precompiles {
+		precompiles[addr] = stub
+	}
+}
+
+// PrecompileStub is a precompiled contract consuming a fixed amount of gas
+// and returning canned data for any input.
+type PrecompileStub struct {
+	Output hexutil.Bytes  `json:"output"`
+	Gas    hexutil.Uint64 `json:"gas"`
+	// Revert makes the stub revert with Output as the revert data.
+	Revert bool `json:"revert"`
+}
+
+// RequiredGas implements vm.PrecompiledContract.
+func (s *PrecompileStub) RequiredGas(input []byte) uint64 {
+	return uint64(s.Gas)
+}
+
+// Run implements vm.PrecompiledContract.
+func (s *PrecompileStub) Run(input []byte) ([]byte, error) {
+	if s.Revert {
+		return common.CopyBytes(s.Output), vm.ErrExecutionReverted
+	}
+	return common.CopyBytes(s.Output), nil
+}
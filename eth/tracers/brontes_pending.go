@@ -0,0 +1,69 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// pendingBackend is implemented by backends assembling a pending block out of
+// the transaction pool.
+type pendingBackend interface {
+	Pending() (*types.Block, types.Receipts, *state.StateDB)
+}
+
+// TracePendingBlock returns the brontes traces of all transactions in the
+// pending block of the node, in the order the transaction pool would include
+// them, encoded in the requested schema version. Pending blocks change with
+// every new transaction, so their traces are never cached.
+func (api *BrontesAPI) TracePendingBlock(ctx context.Context, config *BrontesTraceConfig) ([]*txTraceResult, error) {
+	traceConfig, err := api.traceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	backend, ok := api.api.backend.(pendingBackend)
+	if !ok {
+		return nil, errors.New("pending block is not supported")
+	}
+	block, _, _ := backend.Pending()
+	if block == nil {
+		return nil, errors.New("pending block is not available")
+	}
+	if err := api.newBudget().charge(block.GasUsed()); err != nil {
+		return nil, err
+	}
+	deferred := *traceConfig
+	deferred.deferEncoding = true
+	results, err := api.api.traceBlock(ctx, block, &deferred)
+	if err != nil {
+		return nil, err
+	}
+	if err := encodeResults(ctx, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+)
+
+// BrontesProducts selects the results computed by a brontes replay, all of
+// them taken from a single execution of the transaction.
+type BrontesProducts struct {
+	Trace      bool `json:"trace"`
+	StateDiff  bool `json:"stateDiff"`
+	Transfers  bool `json:"transfers"`
+	AccessList bool `json:"accessList"`
+}
+
+// tracerOptions returns the brontes inspector options computing the
+// products on top of the requested ones.
+func (p BrontesProducts) tracerOptions(config json.RawMessage) (json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &fields); err != nil {
+			return nil, fmt.Errorf("invalid tracer config: %v", err)
+		}
+	}
+	// The trace is decoded in the latest format and encoded in the requested
+	// one afterwards, as older formats lack the other products.
+	delete(fields, "schemaVersion")
+	if p.StateDiff {
+		fields["recordStateDiff"] = json.RawMessage("true")
+	}
+	if _, ok := fields["transferFlow"]; p.Transfers && !ok {
+		fields["transferFlow"] = json.RawMessage(`"` + brontes.TransferFlowNet + `"`)
+	}
+	if p.AccessList {
+		fields["recordStorageAccess"] = json.RawMessage("true")
+	}
+	return json.Marshal(fields)
+}
+
+// brontesReplayResult holds the products of a brontes replay, unrequested
+// ones left out.
+type brontesReplayResult struct {
+	Trace      json.RawMessage                         `json:"trace,omitempty"`
+	StateDiff  map[common.Address]*brontes.AccountDiff `json:"stateDiff,omitempty"`
+	Transfers  []brontes.Transfer                      `json:"transfers,omitempty"`
+	AccessList *types.AccessList                       `json:"accessList,omitempty"`
+}
+
+// newBrontesReplayResult splits the trace of a replay into the requested
+// products, encoding the trace in the given schema version.
+func newBrontesReplayResult(trace *brontes.TxTrace, products BrontesProducts, version int) (*brontesReplayResult, error) {
+	result := new(brontesReplayResult)
+	if products.StateDiff {
+		result.StateDiff = trace.StateDiff
+		if result.StateDiff == nil {
+			result.StateDiff = make(map[common.Address]*brontes.AccountDiff)
+		}
+	}
+	if products.Transfers {
+		result.Transfers = trace.Transfers
+		if result.Transfers == nil {
+			result.Transfers = []brontes.Transfer{}
+		}
+	}
+	if products.AccessList {
+		list := brontes.NewAccessList(trace)
+		result.AccessList = &list
+	}
+	if products.Trace {
+		// The other products are returned on their own.
+		cpy := *trace
+		cpy.StateDiff, cpy.StateDiffDiscrepancies, cpy.Transfers = nil, nil, nil
+		blob, err := cpy.MarshalSchema(version)
+		if err != nil {
+			return nil, err
+		}
+		result.Trace = blob
+	}
+	return result, nil
+}
+
+// ReplayTransaction replays the given transaction once, returning all the
+// requested products of the execution, like trace_replayTransaction does.
+func (api *BrontesAPI) ReplayTransaction(ctx context.Context, hash common.Hash, products BrontesProducts, config *BrontesTraceConfig) (*brontesReplayResult, error) {
+	if products == (BrontesProducts{}) {
+		return nil, errors.New("no replay products requested")
+	}
+	var version int
+	if config != nil && config.Version != nil {
+		if err := brontes.ValidateSchemaVersion(*config.Version); err != nil {
+			return nil, err
+		}
+		version = *config.Version
+	}
+	traceConfig, err := api.traceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if traceConfig.TracerConfig, err = products.tracerOptions(traceConfig.TracerConfig); err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	found, _, _, number, _, err := api.api.backend.GetTransaction(ctx, hash)
+	if err == nil && found {
+		if err := api.checkState(ctx, number, reexecOf(traceConfig)); err != nil {
+			return nil, err
+		}
+	}
+	res, err := api.api.TraceTransaction(ctx, hash, traceConfig)
+	if err != nil {
+		return nil, err
+	}
+	blob, ok := res.(json.RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected trace result %T", res)
+	}
+	var trace brontes.TxTrace
+	if err := json.Unmarshal(blob, &trace); err != nil {
+		return nil, fmt.Errorf("failed to decode trace: %v", err)
+	}
+	return newBrontesReplayResult(&trace, products, version)
+}
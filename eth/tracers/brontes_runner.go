@@ -0,0 +1,119 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// BlockTraceRunner traces all transactions of a block in a single pass over
+// its state, running every transaction with a fresh tracer on top of the
+// state left by the previous ones. It holds the setup shared by the brontes
+// RPC handlers and tests, and is safe for concurrent use.
+type BlockTraceRunner struct {
+	chainConfig  *params.ChainConfig
+	tracer       string
+	tracerConfig json.RawMessage
+}
+
+// NewBlockTraceRunner creates a runner tracing with the brontes tracer
+// configured by tracerConfig.
+func NewBlockTraceRunner(chainConfig *params.ChainConfig, tracerConfig json.RawMessage) *BlockTraceRunner {
+	return &BlockTraceRunner{
+		chainConfig:  chainConfig,
+		tracer:       brontesTracerName,
+		tracerConfig: tracerConfig,
+	}
+}
+
+// Run applies the transactions of the block on top of statedb, which must
+// hold the state of its parent, returning their traces in block order. The
+// state is modified in place. vmctx is the context of the block, as returned
+// by core.NewEVMBlockContext.
+func (r *BlockTraceRunner) Run(ctx context.Context, block *types.Block, statedb *state.StateDB, vmctx vm.BlockContext) ([]json.RawMessage, error) {
+	// Apply the system calls preceding the transactions untraced.
+	evm := vm.NewEVM(vmctx, statedb, r.chainConfig, vm.Config{})
+	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
+		core.ProcessBeaconBlockRoot(*beaconRoot, evm)
+	}
+	if !r.chainConfig.IsArbitrum() && r.chainConfig.IsPrague(block.Number(), block.Time(), vmctx.ArbOSVersion) {
+		core.ProcessParentBlockHash(block.ParentHash(), evm)
+	}
+	var (
+		txs          = block.Transactions()
+		arbosVersion = types.DeserializeHeaderExtraInformation(block.Header()).ArbOSFormatVersion
+		signer       = types.MakeSigner(r.chainConfig, block.Number(), block.Time(), arbosVersion)
+		results      = make([]json.RawMessage, len(txs))
+	)
+	for i, tx := range txs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		txctx := &Context{
+			BlockHash:   block.Hash(),
+			BlockNumber: block.Number(),
+			TxIndex:     i,
+			TxHash:      tx.Hash(),
+		}
+		result, err := r.runTx(ctx, tx, signer, txctx, statedb, vmctx)
+		if err != nil {
+			return nil, fmt.Errorf("tx %d: %w", i, err)
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// runTx applies a single transaction with a fresh tracer hooked into the
+// EVM and the state.
+func (r *BlockTraceRunner) runTx(ctx context.Context, tx *types.Transaction, signer types.Signer, txctx *Context, statedb *state.StateDB, vmctx vm.BlockContext) (json.RawMessage, error) {
+	msg, err := core.TransactionToMessage(tx, signer, vmctx.BaseFee, core.MessageReplayMode)
+	if err != nil {
+		return nil, err
+	}
+	tracer, err := DefaultDirectory.New(r.tracer, txctx, r.tracerConfig, r.chainConfig)
+	if err != nil {
+		return nil, err
+	}
+	evm := tracer.NewEVM(vmctx, statedb, r.chainConfig, vm.Config{NoBaseFee: true})
+
+	// Abort the execution if the caller goes away.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			tracer.Stop(ctx.Err())
+			evm.Cancel()
+		case <-done:
+		}
+	}()
+	var usedGas uint64
+	statedb.SetTxContext(txctx.TxHash, txctx.TxIndex)
+	if _, _, err := core.ApplyTransactionWithEVM(msg, new(core.GasPool).AddGas(msg.GasLimit), statedb, vmctx.BlockNumber, txctx.BlockHash, tx, &usedGas, evm, nil); err != nil {
+		return nil, fmt.Errorf("tracing failed: %w", err)
+	}
+	return tracer.GetResult()
+}
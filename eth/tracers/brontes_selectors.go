@@ -0,0 +1,56 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// brontesSelectorService installs the node-wide selector cache shared by the
+// brontes RPC methods and the live tracer while the node runs.
+type brontesSelectorService struct {
+	cache *brontes.SelectorCache
+}
+
+// Start implements node.Lifecycle.
+func (s *brontesSelectorService) Start() error {
+	brontes.SetSelectorCache(s.cache)
+	return nil
+}
+
+// Stop implements node.Lifecycle.
+func (s *brontesSelectorService) Stop() error {
+	brontes.SetSelectorCache(nil)
+	return s.cache.Close()
+}
+
+// RegisterBrontesSelectorCache opens the selector cache in the directory of
+// the config, resolving unknown selectors at its signature database, and
+// installs it for the lifetime of the node. Nothing is done if no directory
+// is configured.
+func RegisterBrontesSelectorCache(stack *node.Node, config *BrontesConfig) error {
+	if config == nil || config.SelectorCacheDir == "" {
+		return nil
+	}
+	cache, err := brontes.OpenSelectorCache(config.SelectorCacheDir, brontes.NewFourByteSource(config.SelectorURL, 0))
+	if err != nil {
+		return err
+	}
+	stack.RegisterLifecycle(&brontesSelectorService{cache: cache})
+	return nil
+}
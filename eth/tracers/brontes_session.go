@@ -0,0 +1,306 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// defaultMaxBrontesSessions is the number of simulation sessions open at
+	// once if not configured otherwise.
+	defaultMaxBrontesSessions = 16
+	// defaultBrontesSessionTimeout is the time after which an idle session is
+	// discarded if not configured otherwise.
+	defaultBrontesSessionTimeout = 5 * time.Minute
+)
+
+var (
+	errBrontesSessionNotFound = errors.New("simulation session not found")
+	errBrontesTooManySessions = errors.New("too many open simulation sessions")
+)
+
+// brontesSession is a simulation session, a scratch state pinned on top of a
+// block which transactions are executed on one after another. Sessions are
+// only kept in memory, they do not survive a restart of the node.
+type brontesSession struct {
+	lock        chan struct{} // held while the session executes, see acquire
+	env         *callEnv      // nil once the session is closed
+	config      *BrontesCallConfig
+	traceConfig *TraceConfig
+	recorder    *stateDiffRecorder
+	results     []*BrontesCallResult // results of the sent transactions
+	lastUsed    time.Time
+}
+
+// BrontesSession describes an open simulation session.
+type BrontesSession struct {
+	Id          string         `json:"id"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	BlockHash   string         `json:"blockHash"`
+	Sent        int            `json:"sent"` // number of transactions sent
+}
+
+// acquire locks the session for execution, failing if it has been closed in
+// the meantime or the request is cancelled.
+func (s *brontesSession) acquire(ctx context.Context) error {
+	select {
+	case s.lock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if s.env == nil {
+		<-s.lock
+		return errBrontesSessionNotFound
+	}
+	return nil
+}
+
+// unlock releases the session, marking it as used.
+func (s *brontesSession) unlock() {
+	s.lastUsed = time.Now()
+	<-s.lock
+}
+
+// close releases the state of the session once it is no longer executing.
+func (s *brontesSession) close() {
+	s.lock <- struct{}{}
+	defer func() { <-s.lock }()
+
+	if s.env != nil {
+		s.env.release()
+		s.env = nil
+	}
+}
+
+// sessionTimeout returns the time after which idle sessions are discarded.
+func (api *BrontesAPI) sessionTimeout() time.Duration {
+	if api.config.SessionTimeout > 0 {
+		return api.config.SessionTimeout
+	}
+	return defaultBrontesSessionTimeout
+}
+
+// expireSessions removes the sessions idle for longer than the timeout,
+// returning them for closing. The session lock must be held.
+func (api *BrontesAPI) expireSessions() []*brontesSession {
+	var (
+		expired []*brontesSession
+		cutoff  = time.Now().Add(-api.sessionTimeout())
+	)
+	for id, s := range api.sessions {
+		// Sessions in use are locked, their last use is not up to date.
+		select {
+		case s.lock <- struct{}{}:
+			if s.lastUsed.Before(cutoff) {
+				delete(api.sessions, id)
+				expired = append(expired, s)
+			}
+			<-s.lock
+		default:
+		}
+	}
+	return expired
+}
+
+// session looks up an open session, discarding expired ones.
+func (api *BrontesAPI) session(id string) (*brontesSession, error) {
+	api.sessionLock.Lock()
+	expired := api.expireSessions()
+	s, ok := api.sessions[id]
+	api.sessionLock.Unlock()
+
+	for _, s := range expired {
+		s.close()
+	}
+	if !ok {
+		return nil, errBrontesSessionNotFound
+	}
+	return s, nil
+}
+
+// CreateSession opens a simulation session on top of the state of the given
+// block, with optional state, block and EVM overrides, returning its id.
+// Transactions sent within the session build upon each other, until the
+// session is discarded or expires after being idle.
+func (api *BrontesAPI) CreateSession(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, config *BrontesCallConfig) (*BrontesSession, error) {
+	if config == nil {
+		config = new(BrontesCallConfig)
+	}
+	traceConfig, err := api.traceConfig(&config.BrontesTraceConfig)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	callConfig, err := api.callConfig(ctx, blockNrOrHash, traceConfig, config)
+	if err != nil {
+		return nil, err
+	}
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	// Reserve a slot before regenerating the state.
+	limit := api.config.MaxSessions
+	if limit <= 0 {
+		limit = defaultMaxBrontesSessions
+	}
+	s := &brontesSession{
+		lock:        make(chan struct{}, 1),
+		config:      config,
+		traceConfig: &callConfig.TraceConfig,
+		recorder:    newStateDiffRecorder(),
+		lastUsed:    time.Now(),
+	}
+	s.lock <- struct{}{}
+	defer s.unlock()
+
+	api.sessionLock.Lock()
+	expired := api.expireSessions()
+	full := len(api.sessions) >= limit
+	if !full {
+		api.sessions[hexutil.Encode(id[:])] = s
+	}
+	api.sessionLock.Unlock()
+	for _, s := range expired {
+		s.close()
+	}
+	if full {
+		return nil, fmt.Errorf("%w: limit %d", errBrontesTooManySessions, limit)
+	}
+	env, err := api.api.callEnv(ctx, blockNrOrHash, callConfig, config.EVMOverrides)
+	if err != nil {
+		api.sessionLock.Lock()
+		delete(api.sessions, hexutil.Encode(id[:]))
+		api.sessionLock.Unlock()
+		return nil, err
+	}
+	s.env = env
+	return &BrontesSession{
+		Id:          hexutil.Encode(id[:]),
+		BlockNumber: hexutil.Uint64(env.block.NumberU64()),
+		BlockHash:   env.block.Hash().Hex(),
+	}, nil
+}
+
+// SessionSend executes a transaction in the session, keeping its state
+// changes, and returns its brontes trace along with the change of the state
+// by all transactions sent so far.
+func (api *BrontesAPI) SessionSend(ctx context.Context, id string, args ethapi.TransactionArgs) (*BrontesCallResult, error) {
+	s, err := api.session(id)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := s.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	result := new(BrontesCallResult)
+	msg, tx, vmctx, err := s.env.message(api.api, args, s.config.EVMOverrides)
+	if err == nil {
+		err = api.newBudget().charge(msg.GasLimit)
+	}
+	if err == nil {
+		txctx := &Context{TxIndex: len(s.results), TxHash: tx.Hash(), BlockHash: s.env.block.Hash(), BlockNumber: s.env.block.Number()}
+		result.Trace, err = api.api.traceTxWithPrecompiles(ctx, tx, msg, txctx, vmctx, s.env.statedb, s.traceConfig, s.env.precompiles, s.recorder.hooks())
+	}
+	if err != nil {
+		// Transactions failing before execution leave the state unchanged,
+		// and are not recorded.
+		return nil, err
+	}
+	result.StateDiff = s.recorder.diff(s.env.statedb)
+	s.results = append(s.results, result)
+	return result, nil
+}
+
+// SessionCall executes a call on top of the state of the session and returns
+// its brontes trace, discarding its state changes.
+func (api *BrontesAPI) SessionCall(ctx context.Context, id string, args ethapi.TransactionArgs) (interface{}, error) {
+	s, err := api.session(id)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := s.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	env := *s.env
+	env.statedb = s.env.statedb.Copy()
+	msg, tx, vmctx, err := env.message(api.api, args, s.config.EVMOverrides)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.newBudget().charge(msg.GasLimit); err != nil {
+		return nil, err
+	}
+	txctx := &Context{TxIndex: len(s.results), TxHash: tx.Hash(), BlockHash: env.block.Hash(), BlockNumber: env.block.Number()}
+	return api.api.traceTxWithPrecompiles(ctx, tx, msg, txctx, vmctx, env.statedb, s.traceConfig, env.precompiles, nil)
+}
+
+// SessionTraces returns the results of the transactions sent in the session,
+// in order of execution.
+func (api *BrontesAPI) SessionTraces(ctx context.Context, id string) ([]*BrontesCallResult, error) {
+	s, err := api.session(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	return append([]*BrontesCallResult(nil), s.results...), nil
+}
+
+// DiscardSession closes a session, dropping its state.
+func (api *BrontesAPI) DiscardSession(id string) error {
+	api.sessionLock.Lock()
+	s, ok := api.sessions[id]
+	delete(api.sessions, id)
+	api.sessionLock.Unlock()
+
+	if !ok {
+		return errBrontesSessionNotFound
+	}
+	s.close()
+	return nil
+}
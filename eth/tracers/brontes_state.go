@@ -0,0 +1,146 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// StateUnavailableErrorCode is the JSON-RPC error code of StateUnavailableError,
+// the "resource unavailable" code of EIP-1474.
+const StateUnavailableErrorCode = -32002
+
+// StateUnavailableError is returned when the state needed to trace a block has
+// been pruned and cannot be regenerated within the allowed re-execution depth.
+type StateUnavailableError struct {
+	Block uint64 // Block that could not be traced
+	// EarliestTraceable is the oldest block the node can trace, zero if the
+	// node holds no historical state at all.
+	EarliestTraceable uint64
+}
+
+func (e *StateUnavailableError) Error() string {
+	if e.EarliestTraceable == 0 {
+		return fmt.Sprintf("state unavailable for block %d", e.Block)
+	}
+	return fmt.Sprintf("state unavailable for block %d, earliest traceable block = %d", e.Block, e.EarliestTraceable)
+}
+
+// ErrorCode implements rpc.Error.
+func (e *StateUnavailableError) ErrorCode() int {
+	return StateUnavailableErrorCode
+}
+
+// ErrorData implements rpc.DataError, exposing the block numbers to clients.
+func (e *StateUnavailableError) ErrorData() interface{} {
+	return map[string]uint64{
+		"block":             e.Block,
+		"earliestTraceable": e.EarliestTraceable,
+	}
+}
+
+// reexecOf returns the re-execution depth of a trace request.
+func reexecOf(config *TraceConfig) uint64 {
+	if config != nil && config.Reexec != nil {
+		return *config.Reexec
+	}
+	return defaultTraceReexec
+}
+
+// stateChecker is implemented by backends able to tell whether a state is
+// present without regenerating it.
+type stateChecker interface {
+	HasState(root common.Hash) bool
+}
+
+// checkState fails with a StateUnavailableError if tracing the given block
+// requires regenerating state from further back than the re-execution depth.
+// Backends that cannot check for states are assumed to hold them all.
+func (api *BrontesAPI) checkState(ctx context.Context, number, reexec uint64) error {
+	checker, ok := api.api.backend.(stateChecker)
+	if !ok || number == 0 {
+		return nil
+	}
+	// The block is traced on top of its parent state, or a state at most
+	// reexec blocks older than it. The depth is not limited without a
+	// MaxReexec, so states are only probed as far back as the default depth.
+	// Older ones are left for the tracer to find while re-executing.
+	probe := min(reexec, api.defaultReexec())
+	for i := uint64(0); i <= probe && i < number; i++ {
+		available, err := api.hasState(ctx, checker, number-1-i)
+		if err != nil {
+			return err
+		}
+		if available {
+			return nil
+		}
+	}
+	if probe < reexec && probe < number-1 {
+		return nil
+	}
+	earliest, err := api.earliestState(ctx, checker)
+	if err != nil {
+		return err
+	}
+	return &StateUnavailableError{Block: number, EarliestTraceable: earliest}
+}
+
+// earliestState returns the first block traceable on top of a state kept by
+// the node, zero if there is none. Pruning nodes keep the states of the most
+// recent blocks, so the boundary is found with a binary search over the
+// chain. States kept sporadically further back are not taken into account.
+func (api *BrontesAPI) earliestState(ctx context.Context, checker stateChecker) (uint64, error) {
+	head, err := api.api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return 0, err
+	}
+	var searchErr error
+	n := sort.Search(int(head.Number.Uint64())+1, func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		available, err := api.hasState(ctx, checker, uint64(i))
+		if err != nil {
+			searchErr = err
+		}
+		return available
+	})
+	if searchErr != nil {
+		return 0, searchErr
+	}
+	if uint64(n) > head.Number.Uint64() {
+		return 0, nil
+	}
+	return uint64(n) + 1, nil
+}
+
+// hasState reports whether the state of the given block is present.
+func (api *BrontesAPI) hasState(ctx context.Context, checker stateChecker, number uint64) (bool, error) {
+	header, err := api.api.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return false, err
+	}
+	if header == nil {
+		return false, fmt.Errorf("block #%d not found", number)
+	}
+	return checker.HasState(header.Root), nil
+}
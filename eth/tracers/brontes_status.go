@@ -0,0 +1,104 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// BrontesStatus is the state of the live export of the node.
+type BrontesStatus struct {
+	Head uint64 `json:"head"`
+	// LastBlock is the last block committed by the live tracer since the
+	// node started, nil if none was.
+	LastBlock *BrontesCommittedBlock `json:"lastBlock"`
+	// Behind is the number of blocks of the chain past the last committed
+	// block.
+	Behind uint64 `json:"behind"`
+	// Latency holds the recent latencies of the stages of the export, from
+	// the import of a block until it is traced, converted and committed.
+	Latency map[string]BrontesStageLatency `json:"latency"`
+}
+
+// BrontesCommittedBlock is a block committed by the live tracer.
+type BrontesCommittedBlock struct {
+	Number    uint64      `json:"number"`
+	Hash      common.Hash `json:"hash"`
+	Committed uint64      `json:"committed"` // unix time of the commit
+	// Lag is the number of seconds between the timestamp of the block and
+	// its commit.
+	Lag float64 `json:"lag"`
+}
+
+// BrontesStageLatency are the percentiles of the latency of a stage in
+// milliseconds, over the recent blocks.
+type BrontesStageLatency struct {
+	Count int64   `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// Status returns how far the live export is behind the head of the chain and
+// the latencies of its stages over the recent blocks. While metrics are
+// enabled, the latencies are also reported as the brontes/latency timers.
+func (api *BrontesAPI) Status(ctx context.Context) (*BrontesStatus, error) {
+	header, err := api.api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		latency = brontes.PipelineLatency()
+		status  = &BrontesStatus{
+			Head:    header.Number.Uint64(),
+			Behind:  header.Number.Uint64(),
+			Latency: make(map[string]BrontesStageLatency, len(latency.Stages)),
+		}
+	)
+	if last := latency.Last; last != nil {
+		status.LastBlock = &BrontesCommittedBlock{
+			Number:    last.Number,
+			Hash:      last.Hash,
+			Committed: uint64(last.Committed.Unix()),
+			Lag:       last.Lag().Seconds(),
+		}
+		status.Behind = 0
+		if status.Head > last.Number {
+			status.Behind = status.Head - last.Number
+		}
+	}
+	for stage, stats := range latency.Stages {
+		status.Latency[stage] = BrontesStageLatency{
+			Count: stats.Count,
+			P50:   milliseconds(stats.P50),
+			P90:   milliseconds(stats.P90),
+			P99:   milliseconds(stats.P99),
+			Max:   milliseconds(stats.Max),
+		}
+	}
+	return status, nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// brontesUncleResult holds the brontes traces of an uncle of a block. Uncles
+// are never part of the canonical chain, which is recorded by the canonical
+// flag so the traces are not mistaken for executed ones.
+type brontesUncleResult struct {
+	Number     hexutil.Uint64   `json:"blockNumber"`
+	Hash       common.Hash      `json:"blockHash"`
+	Canonical  bool             `json:"canonical"`
+	IncludedIn common.Hash      `json:"includedIn"`
+	Traces     []*txTraceResult `json:"traces,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// TraceUncles returns the brontes traces of the transactions of all uncles
+// included by the given block, executed on top of the state of their parent.
+// Only the headers of uncles are part of the block, so uncles whose body the
+// node never received, or which cannot be traced, are reported in the error
+// field of their result.
+func (api *BrontesAPI) TraceUncles(ctx context.Context, number rpc.BlockNumber, config *BrontesTraceConfig) ([]*brontesUncleResult, error) {
+	traceConfig, err := api.traceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.limiter.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	block, err := api.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		budget  = api.newBudget()
+		results = make([]*brontesUncleResult, 0, len(block.Uncles()))
+	)
+	for _, uncle := range block.Uncles() {
+		result := &brontesUncleResult{
+			Number:     hexutil.Uint64(uncle.Number.Uint64()),
+			Hash:       uncle.Hash(),
+			IncludedIn: block.Hash(),
+		}
+		results = append(results, result)
+
+		body, err := api.api.backend.BlockByHash(ctx, uncle.Hash())
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		if body == nil {
+			result.Error = fmt.Sprintf("body of uncle %s not available", uncle.Hash().Hex())
+			continue
+		}
+		if result.Traces, err = api.traceBlock(ctx, body, traceConfig, budget); err != nil {
+			// Running out of budget or being cancelled fails the whole request.
+			if ctx.Err() != nil || errors.Is(err, errBrontesBudget) {
+				return nil, err
+			}
+			result.Error = err.Error()
+		}
+	}
+	return results, nil
+}
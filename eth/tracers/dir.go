@@ -21,7 +21,9 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -41,10 +43,23 @@ type Context struct {
 type Tracer struct {
 	*tracing.Hooks
 	GetResult func() (json.RawMessage, error)
+	// GetDeferredResult, if set, completes the result like GetResult but
+	// returns a function encoding it instead, which must not depend on the
+	// state anymore. It lets callers encode the results of many transactions
+	// concurrently.
+	GetDeferredResult func() (func() (json.RawMessage, error), error)
 	// Stop terminates execution of the tracer at the first opportune moment.
 	Stop func(err error)
 }
 
+// NewEVM creates an EVM running the tracer over the given state. The tracer
+// is set on the vm config and the state is wrapped to report its changes to
+// the state hooks, which never fire when only the former is done.
+func (t *Tracer) NewEVM(blockCtx vm.BlockContext, statedb *state.StateDB, chainConfig *params.ChainConfig, config vm.Config) *vm.EVM {
+	config.Tracer = t.Hooks
+	return vm.NewEVM(blockCtx, state.NewHookedState(statedb, t.Hooks), chainConfig, config)
+}
+
 type ctorFn func(*Context, json.RawMessage, *params.ChainConfig) (*Tracer, error)
 type jsCtorFn func(string, *Context, json.RawMessage, *params.ChainConfig) (*Tracer, error)
 
@@ -0,0 +1,388 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build integrationtests
+
+package tracetest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// brontesClickhouseImage is the ClickHouse server started if no server is
+// given in BRONTES_CLICKHOUSE_URL.
+const brontesClickhouseImage = "clickhouse/clickhouse-server:24.8"
+
+// brontesClickhouseSchema creates the tables the exported rows are loaded
+// into. Only the columns asserted on are declared, the others are skipped on
+// insertion.
+var brontesClickhouseSchema = []string{
+	`CREATE TABLE call_actions (chain_id UInt64, block_number UInt64, block_hash String, parent_hash String, tx_hash String, tx_index UInt64, trace_idx UInt64, ` + "`from`" + ` String, call_type String, ` + "`to`" + ` String) ENGINE = MergeTree ORDER BY (block_number, tx_index, trace_idx)`,
+	`CREATE TABLE create_actions (chain_id UInt64, block_number UInt64, block_hash String, parent_hash String, tx_hash String, tx_index UInt64, trace_idx UInt64, ` + "`from`" + ` String, init String) ENGINE = MergeTree ORDER BY (block_number, tx_index, trace_idx)`,
+	`CREATE TABLE logs (chain_id UInt64, block_number UInt64, block_hash String, parent_hash String, tx_hash String, tx_index UInt64, trace_idx UInt64, log_idx UInt64, address String, topics Array(String), data String) ENGINE = MergeTree ORDER BY (block_number, tx_index, trace_idx, log_idx)`,
+}
+
+// brontesTableLine is a line of a table file written by the live tracer.
+type brontesTableLine struct {
+	BlockNumber uint64                     `json:"block_number"`
+	TxHash      common.Hash                `json:"tx_hash"`
+	TxIndex     int                        `json:"tx_index"`
+	Rows        map[string]json.RawMessage `json:"rows"`
+}
+
+// TestBrontesExportPipeline runs the live tracer writing tables over a chain
+// of representative transactions: a transfer, a contract deployment and a
+// call emitting a log. The table files are checked, then loaded into
+// ClickHouse and queried back the way the brontes pipeline ingests them.
+func TestBrontesExportPipeline(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		// LOG1(0, 0, 0xff) SSTORE(0, 0x2a), deployed by an init code copying
+		// and returning it.
+		runtime  = common.FromHex("0x60ff60006000a1602a60005500")
+		init     = append(common.FromHex("0x600d600c600039600d6000f3"), runtime...)
+		contract = crypto.CreateAddress(sender, 1)
+		gspec    = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+		dir    = t.TempDir()
+	)
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"tables":{}}`, dir)))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 2, func(i int, b *core.BlockGen) {
+		newTx := func(nonce uint64, to *common.Address, value *big.Int, gas uint64, data []byte) *types.Transaction {
+			tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   gspec.Config.ChainID,
+				Nonce:     nonce,
+				To:        to,
+				Value:     value,
+				Gas:       gas,
+				GasFeeCap: b.BaseFee(),
+				Data:      data,
+			})
+			return tx
+		}
+		switch i {
+		case 0:
+			b.AddTx(newTx(0, &to, big.NewInt(1000), 21000, nil))
+			b.AddTx(newTx(1, nil, nil, 200000, init))
+		case 1:
+			b.AddTx(newTx(2, &contract, nil, 100000, nil))
+		}
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	hooks.OnClose()
+
+	tables := map[string][]map[string]json.RawMessage{
+		brontes.TableCallActions:   readBrontesTable(t, dir, brontes.TableCallActions, blocks),
+		brontes.TableCreateActions: readBrontesTable(t, dir, brontes.TableCreateActions, blocks),
+		brontes.TableLogs:          readBrontesTable(t, dir, brontes.TableLogs, blocks),
+	}
+	want := map[string][]string{
+		// The transfer and the call, in chain order.
+		brontes.TableCallActions:   {blocks[0].Transactions()[0].Hash().Hex(), blocks[1].Transactions()[0].Hash().Hex()},
+		brontes.TableCreateActions: {blocks[0].Transactions()[1].Hash().Hex()},
+		brontes.TableLogs:          {blocks[1].Transactions()[0].Hash().Hex()},
+	}
+	for table, hashes := range want {
+		rows := tables[table]
+		if len(rows) != len(hashes) {
+			t.Fatalf("table %s: have %d rows, want %d", table, len(rows), len(hashes))
+		}
+		for i, hash := range hashes {
+			var have string
+			json.Unmarshal(rows[i]["tx_hash"], &have)
+			if have != hash {
+				t.Errorf("table %s row %d: have transaction %s, want %s", table, i, have, hash)
+			}
+		}
+	}
+	var topics []string
+	json.Unmarshal(tables[brontes.TableLogs][0]["topics"], &topics)
+	if len(topics) != 1 || topics[0] != common.BigToHash(big.NewInt(0xff)).Hex() {
+		t.Errorf("unexpected log topics: %v", topics)
+	}
+
+	server := startBrontesClickhouse(t)
+	for _, statement := range brontesClickhouseSchema {
+		server.exec(t, statement, nil)
+	}
+	for table, rows := range tables {
+		var body bytes.Buffer
+		for _, row := range rows {
+			blob, _ := json.Marshal(row)
+			body.Write(append(blob, '\n'))
+		}
+		server.exec(t, fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", table), &body)
+	}
+	queries := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT count() FROM call_actions", "2"},
+		{"SELECT count() FROM create_actions", "1"},
+		{fmt.Sprintf("SELECT count() FROM call_actions WHERE block_hash = '%s' AND parent_hash = '%s'", blocks[0].Hash().Hex(), blocks[0].ParentHash().Hex()), "1"},
+		{"SELECT lower(`to`) FROM call_actions WHERE block_number = 2", strings.ToLower(contract.Hex())},
+		// Logs are attributed to the frame emitting them.
+		{"SELECT count() FROM logs AS l INNER JOIN call_actions AS c ON l.block_hash = c.block_hash AND l.tx_hash = c.tx_hash AND l.trace_idx = c.trace_idx", "1"},
+	}
+	for _, q := range queries {
+		if have := server.query(t, q.query); have != q.want {
+			t.Errorf("%s: have %q, want %q", q.query, have, q.want)
+		}
+	}
+}
+
+// readBrontesTable reads the rows of a table file, checking every row is
+// located at the block and transaction of its line.
+func readBrontesTable(t *testing.T, dir, table string, blocks []*types.Block) []map[string]json.RawMessage {
+	t.Helper()
+	file, err := os.Open(filepath.Join(dir, "brontes_"+table+".jsonl"))
+	if err != nil {
+		t.Fatalf("failed to open table %s: %v", table, err)
+	}
+	defer file.Close()
+
+	var rows []map[string]json.RawMessage
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var line brontesTableLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("table %s: failed to parse line: %v", table, err)
+		}
+		if line.BlockNumber == 0 || line.BlockNumber > uint64(len(blocks)) {
+			t.Fatalf("table %s: unexpected block %d", table, line.BlockNumber)
+		}
+		block := blocks[line.BlockNumber-1]
+		lineRows := brontesRowsOf(t, line.Rows)
+		for i, row := range lineRows {
+			want := map[string]interface{}{
+				"block_number": line.BlockNumber,
+				"block_hash":   block.Hash().Hex(),
+				"parent_hash":  block.ParentHash().Hex(),
+				"tx_hash":      line.TxHash.Hex(),
+				"tx_index":     line.TxIndex,
+			}
+			for column, value := range want {
+				blob, _ := json.Marshal(value)
+				if !bytes.Equal(row[column], blob) {
+					t.Errorf("table %s block %d tx %d row %d: %s is %s, want %s", table, line.BlockNumber, line.TxIndex, i, column, row[column], blob)
+				}
+			}
+		}
+		rows = append(rows, lineRows...)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read table %s: %v", table, err)
+	}
+	return rows
+}
+
+// brontesRowsOf transposes the columns of a table into rows keyed by
+// ClickHouse column name.
+func brontesRowsOf(t *testing.T, columns map[string]json.RawMessage) []map[string]json.RawMessage {
+	t.Helper()
+	var rows []map[string]json.RawMessage
+	for field, blob := range columns {
+		var cells []json.RawMessage
+		if err := json.Unmarshal(blob, &cells); err != nil {
+			t.Fatalf("column %s is not a list: %v", field, err)
+		}
+		for i, cell := range cells {
+			if i == len(rows) {
+				rows = append(rows, make(map[string]json.RawMessage))
+			}
+			rows[i][brontesColumnName(field)] = cell
+		}
+	}
+	return rows
+}
+
+// brontesColumnName returns the ClickHouse column name of a table field.
+func brontesColumnName(field string) string {
+	var name strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				name.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		name.WriteRune(r)
+	}
+	return name.String()
+}
+
+// brontesClickhouse is a ClickHouse server reached over its HTTP interface,
+// with a database of its own for the test.
+type brontesClickhouse struct {
+	url      string
+	database string
+}
+
+// skipWithoutClickhouse skips the test for lack of a ClickHouse server. If
+// BRONTES_CLICKHOUSE_REQUIRED is set, as in CI, the test fails instead, so the
+// export pipeline cannot go untested unnoticed.
+func skipWithoutClickhouse(t *testing.T, format string, args ...interface{}) {
+	t.Helper()
+	if os.Getenv("BRONTES_CLICKHOUSE_REQUIRED") != "" {
+		t.Fatalf(format, args...)
+	}
+	t.Skipf(format+" (set BRONTES_CLICKHOUSE_REQUIRED to fail instead)", args...)
+}
+
+// startBrontesClickhouse connects to the server in BRONTES_CLICKHOUSE_URL, or
+// starts one in a container removed when the test ends. The test is skipped
+// if neither is possible, see skipWithoutClickhouse.
+func startBrontesClickhouse(t *testing.T) *brontesClickhouse {
+	t.Helper()
+	server := &brontesClickhouse{
+		url:      os.Getenv("BRONTES_CLICKHOUSE_URL"),
+		database: fmt.Sprintf("brontes_%d", time.Now().UnixNano()),
+	}
+	if server.url == "" {
+		ctx := context.Background()
+		container, err := runBrontesClickhouse(ctx)
+		testcontainers.CleanupContainer(t, container)
+		if err != nil {
+			skipWithoutClickhouse(t, "no clickhouse server: BRONTES_CLICKHOUSE_URL is not set and the container failed to start: %v", err)
+		}
+		if server.url, err = container.PortEndpoint(ctx, "8123/tcp", "http"); err != nil {
+			t.Fatalf("failed to find clickhouse port: %v", err)
+		}
+	}
+	deadline := time.Now().Add(time.Minute)
+	for {
+		res, err := http.Get(server.url + "/ping")
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("clickhouse at %s not ready: %v", server.url, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	server.do(t, "", "CREATE DATABASE "+server.database, nil)
+	t.Cleanup(func() { server.do(t, "", "DROP DATABASE IF EXISTS "+server.database, nil) })
+	return server
+}
+
+// runBrontesClickhouse starts a ClickHouse server container, turning the
+// panic of testcontainers on hosts without docker into an error.
+func runBrontesClickhouse(ctx context.Context) (container testcontainers.Container, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image: brontesClickhouseImage,
+			// The default user only accepts local connections unless the user
+			// setup is skipped.
+			Env:          map[string]string{"CLICKHOUSE_SKIP_USER_SETUP": "1"},
+			ExposedPorts: []string{"8123/tcp"},
+			WaitingFor:   wait.ForHTTP("/ping").WithPort("8123/tcp").WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	})
+}
+
+// exec runs a statement in the database of the test, sending body as its
+// input if not nil. Input columns unknown to the table are skipped.
+func (c *brontesClickhouse) exec(t *testing.T, statement string, body io.Reader) {
+	t.Helper()
+	c.do(t, c.database, statement, body)
+}
+
+// query runs a query in the database of the test, returning its output.
+func (c *brontesClickhouse) query(t *testing.T, query string) string {
+	t.Helper()
+	return strings.TrimSpace(c.do(t, c.database, query+" FORMAT TabSeparated", nil))
+}
+
+func (c *brontesClickhouse) do(t *testing.T, database, statement string, body io.Reader) string {
+	t.Helper()
+	params := url.Values{
+		"query":                            {statement},
+		"input_format_skip_unknown_fields": {"1"},
+	}
+	if database != "" {
+		params.Set("database", database)
+	}
+	if body == nil {
+		body = http.NoBody
+	}
+	res, err := http.Post(c.url+"/?"+params.Encode(), "text/plain", body)
+	if err != nil {
+		t.Fatalf("%s: %v", statement, err)
+	}
+	defer res.Body.Close()
+	out, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", statement, res.StatusCode, out)
+	}
+	return string(out)
+}
@@ -17,21 +17,33 @@
 package tracetest
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
-	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/tests"
 )
@@ -111,15 +123,11 @@ func testBrontesTracer(tracerName string, dirPath string, t *testing.T) {
 			if err != nil {
 				t.Fatalf("failed to create brontes tracer: %v", err)
 			}
-			logState := vm.StateDB(st.StateDB)
-			if tracer.Hooks != nil {
-				logState = state.NewHookedState(st.StateDB, tracer.Hooks)
-			}
 			msg, err := core.TransactionToMessage(tx, signer, context.BaseFee, core.MessageReplayMode)
 			if err != nil {
 				t.Fatalf("failed to prepare transaction for tracing: %v", err)
 			}
-			evm := vm.NewEVM(context, logState, test.Genesis.Config, vm.Config{Tracer: tracer.Hooks})
+			evm := tracer.NewEVM(context, st.StateDB, test.Genesis.Config, vm.Config{})
 			tracer.OnTxStart(evm.GetVMContext(), tx, msg.From)
 
 			// Create gas pool with enough gas
@@ -222,10 +230,6 @@ func TestBrontesTracerInternal(t *testing.T) {
 		defer st.Close()
 
 		tracer := mkTracer("brontesTracer", nil)
-		logState := vm.StateDB(st.StateDB)
-		if hooks := tracer.Hooks; hooks != nil {
-			logState = state.NewHookedState(st.StateDB, hooks)
-		}
 
 		tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
 			To:       &to,
@@ -236,7 +240,7 @@ func TestBrontesTracerInternal(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to sign transaction: %v", err)
 		}
-		evm := vm.NewEVM(context, logState, config, vm.Config{Tracer: tracer.Hooks})
+		evm := tracer.NewEVM(context, st.StateDB, config, vm.Config{})
 		msg, err := core.TransactionToMessage(tx, signer, context.BaseFee, core.MessageReplayMode)
 		if err != nil {
 			t.Fatalf("failed to create message: %v", err)
@@ -272,7 +276,420 @@ func TestBrontesTracerInternal(t *testing.T) {
 	})
 }
 
+// runBrontesTracer executes a single transaction calling to with the given
+// prestate on a post-cancun chain and returns the brontes tracer output.
+func runBrontesTracer(t *testing.T, alloc types.GenesisAlloc, to *common.Address, input []byte, cfg json.RawMessage) []byte {
+	t.Helper()
+
+	res, err := traceBrontes(t, alloc, to, input, cfg, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	return res
+}
+
+// traceBrontes executes a transaction with the brontes tracer, returning its
+// result. The hooks of the tracer are replaced by the result of wrap if set.
+func traceBrontes(t *testing.T, alloc types.GenesisAlloc, to *common.Address, input []byte, cfg json.RawMessage, wrap func(*tracers.Tracer) *tracing.Hooks) (json.RawMessage, error) {
+	t.Helper()
+
+	var (
+		config  = params.MergedTestChainConfig
+		signer  = types.LatestSigner(config)
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		origin  = crypto.PubkeyToAddress(key.PublicKey)
+		random  = common.Hash{}
+		context = vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			GetHash:     func(uint64) common.Hash { return common.Hash{} },
+			BlockNumber: big.NewInt(1),
+			Time:        5,
+			Random:      &random,
+			GasLimit:    uint64(30000000),
+			BaseFee:     big.NewInt(1),
+			BlobBaseFee: big.NewInt(1),
+		}
+	)
+	if alloc == nil {
+		alloc = types.GenesisAlloc{}
+	}
+	alloc[origin] = types.Account{Balance: big.NewInt(1e18)}
+	st := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false, rawdb.HashScheme)
+	defer st.Close()
+
+	tracer, err := tracers.DefaultDirectory.New("brontesTracer", new(tracers.Context), cfg, config)
+	if err != nil {
+		t.Fatalf("failed to create brontes tracer: %v", err)
+	}
+	tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+		ChainID:   config.ChainID,
+		To:        to,
+		Data:      input,
+		Gas:       5000000,
+		GasFeeCap: big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	traced := *tracer
+	if wrap != nil {
+		traced.Hooks = wrap(tracer)
+	}
+	evm := traced.NewEVM(context, st.StateDB, config, vm.Config{})
+	msg, err := core.TransactionToMessage(tx, signer, context.BaseFee, core.MessageReplayMode)
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	tracer.OnTxStart(evm.GetVMContext(), tx, msg.From)
+	vmRet, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	status := types.ReceiptStatusSuccessful
+	if vmRet.Failed() {
+		status = types.ReceiptStatusFailed
+	}
+	tracer.OnTxEnd(&types.Receipt{GasUsed: vmRet.UsedGas, Status: status}, nil)
+
+	return tracer.GetResult()
+}
+
+func TestBrontesTracerOpcodeSummary(t *testing.T) {
+	var (
+		callee = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		entry  = common.HexToAddress("0x00000000000000000000000000000000000000ee")
+	)
+	alloc := types.GenesisAlloc{
+		// SSTORE(0, 1); MSTORE(0, SLOAD(0))
+		callee: types.Account{Code: common.FromHex("0x600160005560005460005200")},
+		// CALL(gas, callee, 0, 0, 0, 0, 0)
+		entry: types.Account{Code: common.FromHex("0x600060006000600060007300000000000000000000000000000000000000cc5af100")},
+	}
+	res := runBrontesTracer(t, alloc, &entry, nil, json.RawMessage(`{"recordOpcodeSummary": true}`))
+
+	var result struct {
+		Trace []struct {
+			Summary *brontes.FrameSummary `json:"summary"`
+		} `json:"trace"`
+	}
+	if err := json.Unmarshal(res, &result); err != nil {
+		t.Fatalf("failed to parse trace result: %v", err)
+	}
+	if len(result.Trace) != 2 {
+		t.Fatalf("unexpected number of traces: have %d want 2", len(result.Trace))
+	}
+	top, inner := result.Trace[0].Summary, result.Trace[1].Summary
+	if top == nil || inner == nil {
+		t.Fatalf("missing frame summaries: %s", res)
+	}
+	if top.OpcodeCounts["CALL"] != 1 || top.MaxDepth != 1 {
+		t.Errorf("unexpected top frame summary: %+v", top)
+	}
+	if inner.SloadCount != 1 || inner.SstoreCount != 1 || inner.MemoryHighWater != 32 || inner.MaxDepth != 1 {
+		t.Errorf("unexpected inner frame summary: %+v", inner)
+	}
+}
+
+func TestBrontesTracerFlatOutput(t *testing.T) {
+	var (
+		callee = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		entry  = common.HexToAddress("0x00000000000000000000000000000000000000ee")
+	)
+	alloc := types.GenesisAlloc{
+		callee: types.Account{Code: common.FromHex("0x00")},
+		// CALL(gas, callee, 0, 0, 0, 0, 0)
+		entry: types.Account{Code: common.FromHex("0x600060006000600060007300000000000000000000000000000000000000cc5af100")},
+	}
+	res := runBrontesTracer(t, alloc, &entry, nil, json.RawMessage(`{"outputMode": "flat"}`))
+
+	var result struct {
+		IsSuccess bool `json:"is_success"`
+		Trace     []struct {
+			Type         string          `json:"type"`
+			Action       json.RawMessage `json:"action"`
+			Subtraces    uint            `json:"subtraces"`
+			TraceAddress []uint          `json:"traceAddress"`
+			Logs         json.RawMessage `json:"logs"`
+		} `json:"trace"`
+	}
+	if err := json.Unmarshal(res, &result); err != nil {
+		t.Fatalf("failed to parse trace result: %v", err)
+	}
+	if !result.IsSuccess || len(result.Trace) != 2 {
+		t.Fatalf("unexpected flat trace: %s", res)
+	}
+	top, inner := result.Trace[0], result.Trace[1]
+	if top.Type != "call" || top.Subtraces != 1 || len(top.TraceAddress) != 0 || top.Action == nil {
+		t.Errorf("unexpected top frame: %+v", top)
+	}
+	if inner.Type != "call" || inner.Subtraces != 0 || len(inner.TraceAddress) != 1 || inner.TraceAddress[0] != 0 {
+		t.Errorf("unexpected inner frame: %+v", inner)
+	}
+	if top.Logs != nil {
+		t.Errorf("flat frames should not carry logs: %s", top.Logs)
+	}
+
+	if _, err := tracers.DefaultDirectory.New("brontesTracer", new(tracers.Context), json.RawMessage(`{"outputMode": "tree"}`), params.MainnetChainConfig); err == nil {
+		t.Error("expected unsupported output mode to be rejected")
+	}
+}
+
+func TestBrontesTracerStorageAccess(t *testing.T) {
+	var (
+		library = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		proxy   = common.HexToAddress("0x00000000000000000000000000000000000000ee")
+	)
+	alloc := types.GenesisAlloc{
+		// SSTORE(1, SLOAD(2)); SSTORE(1, 3)
+		library: types.Account{Code: common.FromHex("0x6002546001556003600155")},
+		// SLOAD(5); DELEGATECALL(gas, library, 0, 0, 0, 0)
+		proxy: types.Account{Code: common.FromHex("0x600554506000600060006000" + "7300000000000000000000000000000000000000cc5af400")},
+	}
+	res := runBrontesTracer(t, alloc, &proxy, nil, json.RawMessage(`{"recordStorageAccess": true}`))
+
+	var result struct {
+		Trace []struct {
+			StorageAccess  *brontes.StorageAccess `json:"storage_access"`
+			CodeAddress    common.Address         `json:"code_address"`
+			ContextAddress common.Address         `json:"context_address"`
+		} `json:"trace"`
+	}
+	if err := json.Unmarshal(res, &result); err != nil {
+		t.Fatalf("failed to parse trace result: %v", err)
+	}
+	if len(result.Trace) != 2 {
+		t.Fatalf("unexpected number of traces: have %d want 2", len(result.Trace))
+	}
+	top, inner := result.Trace[0].StorageAccess, result.Trace[1].StorageAccess
+	if top == nil || inner == nil {
+		t.Fatalf("missing storage access sets: %s", res)
+	}
+	slot := func(n int64) common.Hash { return common.BigToHash(big.NewInt(n)) }
+	if top.Address != proxy || len(top.Reads) != 1 || top.Reads[0] != slot(5) || len(top.Writes) != 0 {
+		t.Errorf("unexpected top frame access set: %+v", top)
+	}
+	// The delegate call runs against the proxy storage, and the second write
+	// to the same slot is deduplicated.
+	if inner.Address != proxy || len(inner.Reads) != 1 || inner.Reads[0] != slot(2) || len(inner.Writes) != 1 || inner.Writes[0] != slot(1) {
+		t.Errorf("unexpected inner frame access set: %+v", inner)
+	}
+	if have := result.Trace[1]; have.CodeAddress != library || have.ContextAddress != proxy {
+		t.Errorf("unexpected delegate call addresses: code %v context %v", have.CodeAddress, have.ContextAddress)
+	}
+}
+
+func TestBrontesTracerTransientStorageAccess(t *testing.T) {
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	alloc := types.GenesisAlloc{
+		// TSTORE(1, 7); TLOAD(1); SLOAD(1)
+		contract: types.Account{Code: common.FromHex("0x600760015d60015c5060015400")},
+	}
+	res := runBrontesTracer(t, alloc, &contract, nil, json.RawMessage(`{"recordStorageAccess": true, "recordSteps": true}`))
+
+	var result struct {
+		Trace []struct {
+			StorageAccess *brontes.StorageAccess `json:"storage_access"`
+		} `json:"trace"`
+	}
+	if err := json.Unmarshal(res, &result); err != nil {
+		t.Fatalf("failed to parse trace result: %v", err)
+	}
+	if len(result.Trace) != 1 || result.Trace[0].StorageAccess == nil {
+		t.Fatalf("missing storage access set: %s", res)
+	}
+	var (
+		access = result.Trace[0].StorageAccess
+		slot   = common.BigToHash(big.NewInt(1))
+	)
+	if len(access.TransientWrites) != 1 || access.TransientWrites[0] != slot {
+		t.Errorf("unexpected transient writes: %v", access.TransientWrites)
+	}
+	if len(access.TransientReads) != 1 || access.TransientReads[0] != slot {
+		t.Errorf("unexpected transient reads: %v", access.TransientReads)
+	}
+	if len(access.Reads) != 1 || len(access.Writes) != 0 {
+		t.Errorf("transient accesses leaked into persistent sets: %+v", access)
+	}
+}
+
+func TestBrontesTracerSelfDestructCancun(t *testing.T) {
+	var (
+		contract    = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		beneficiary = "00000000000000000000000000000000000000bb"
+		// SELFDESTRUCT(beneficiary)
+		code = common.FromHex("0x73" + beneficiary + "ff")
+	)
+	codeRemoved := func(t *testing.T, res []byte) bool {
+		var result struct {
+			Trace []struct {
+				Trace struct {
+					Type   string `json:"type"`
+					Action struct {
+						CodeRemoved *bool `json:"codeRemoved"`
+					} `json:"action"`
+				} `json:"trace"`
+			} `json:"trace"`
+		}
+		if err := json.Unmarshal(res, &result); err != nil {
+			t.Fatalf("failed to parse trace result: %v", err)
+		}
+		for _, trace := range result.Trace {
+			if trace.Trace.Type == "selfdestruct" {
+				if trace.Trace.Action.CodeRemoved == nil {
+					t.Fatalf("selfdestruct action missing codeRemoved: %s", res)
+				}
+				return *trace.Trace.Action.CodeRemoved
+			}
+		}
+		t.Fatalf("no selfdestruct trace found: %s", res)
+		return false
+	}
+	t.Run("ExistingContract", func(t *testing.T) {
+		alloc := types.GenesisAlloc{contract: types.Account{Code: code, Balance: big.NewInt(1)}}
+		if codeRemoved(t, runBrontesTracer(t, alloc, &contract, nil, nil)) {
+			t.Error("selfdestruct of pre-existing contract reported as removing code")
+		}
+	})
+	t.Run("CreatedInSameTx", func(t *testing.T) {
+		if !codeRemoved(t, runBrontesTracer(t, nil, nil, code, nil)) {
+			t.Error("selfdestruct in constructor not reported as removing code")
+		}
+	})
+}
+
+func TestBrontesTracerSelfDestructAction(t *testing.T) {
+	var (
+		contract    = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		beneficiary = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+		// SELFDESTRUCT(beneficiary)
+		code  = common.FromHex("0x73" + common.Bytes2Hex(beneficiary.Bytes()) + "ff")
+		alloc = types.GenesisAlloc{contract: types.Account{Code: code, Balance: big.NewInt(7)}}
+	)
+	var result brontes.TxTrace
+	if err := json.Unmarshal(runBrontesTracer(t, alloc, &contract, nil, nil), &result); err != nil {
+		t.Fatalf("failed to parse trace result: %v", err)
+	}
+	if len(result.Trace) != 2 || result.Trace[1].Trace.Action.SelfDestruct == nil {
+		t.Fatalf("no selfdestruct frame found: %+v", result.Trace)
+	}
+	action := result.Trace[1].Trace.Action.SelfDestruct
+	if action.Address != contract {
+		t.Errorf("selfdestruct address mismatch: have %v, want %v", action.Address, contract)
+	}
+	if action.RefundAddress != beneficiary {
+		t.Errorf("selfdestruct refund address mismatch: have %v, want %v", action.RefundAddress, beneficiary)
+	}
+	if action.Balance == nil || action.Balance.Cmp(big.NewInt(7)) != 0 {
+		t.Errorf("selfdestruct balance mismatch: have %v, want 7", action.Balance)
+	}
+}
+
 // Helper to create an RLP-encoded transaction for test cases
+func TestBrontesTracerPartialResults(t *testing.T) {
+	var (
+		callee = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		entry  = common.HexToAddress("0x00000000000000000000000000000000000000ee")
+	)
+	alloc := types.GenesisAlloc{
+		callee: types.Account{Code: common.FromHex("0x00")},
+		// CALL(gas, callee, 0, 0, 0, 0, 0)
+		entry: types.Account{Code: common.FromHex("0x600060006000600060007300000000000000000000000000000000000000cc5af100")},
+	}
+	// Stop the tracer as the subcall is entered, as a timeout would.
+	stopAtSubcall := func(tracer *tracers.Tracer) *tracing.Hooks {
+		hooks := *tracer.Hooks
+		hooks.OnEnter = func(depth int, typ byte, from, to common.Address, input []byte, gas uint64, value *big.Int) {
+			if depth == 1 {
+				tracer.Stop(errors.New("execution timeout"))
+			}
+			tracer.OnEnter(depth, typ, from, to, input, gas, value)
+		}
+		return &hooks
+	}
+	if _, err := traceBrontes(t, alloc, &entry, nil, nil, stopAtSubcall); err == nil || err.Error() != "execution timeout" {
+		t.Fatalf("expected stopped trace to fail, have %v", err)
+	}
+
+	res, err := traceBrontes(t, alloc, &entry, nil, json.RawMessage(`{"partialResults": true}`), stopAtSubcall)
+	if err != nil {
+		t.Fatalf("failed to retrieve partial result: %v", err)
+	}
+	var result brontes.TxTrace
+	if err := json.Unmarshal(res, &result); err != nil {
+		t.Fatalf("failed to parse trace result: %v", err)
+	}
+	// The frame entered before the tracer stopped is kept.
+	if len(result.Trace) != 1 || result.Trace[0].Trace.Action.Call.To != entry {
+		t.Fatalf("unexpected partial frames: %s", res)
+	}
+	want := []brontes.TraceError{{Stage: brontes.TraceErrorStageExecution, Message: "execution timeout"}}
+	if !reflect.DeepEqual(result.Errors, want) {
+		t.Errorf("unexpected errors: have %+v, want %+v", result.Errors, want)
+	}
+
+	// Complete traces have no errors.
+	res = runBrontesTracer(t, alloc, &entry, nil, json.RawMessage(`{"partialResults": true}`))
+	if strings.Contains(string(res), `"errors"`) {
+		t.Errorf("unexpected errors in complete trace: %s", res)
+	}
+}
+
+func TestBrontesTracerRecoversPanics(t *testing.T) {
+	var (
+		callee = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		entry  = common.HexToAddress("0x00000000000000000000000000000000000000ee")
+	)
+	alloc := types.GenesisAlloc{
+		callee: types.Account{Code: common.FromHex("0x00")},
+		// CALL(gas, callee, 0, 0, 0, 0, 0)
+		entry: types.Account{Code: common.FromHex("0x600060006000600060007300000000000000000000000000000000000000cc5af100")},
+	}
+	// Exiting more frames than were entered makes the tracer panic.
+	unbalanced := func(tracer *tracers.Tracer) *tracing.Hooks {
+		hooks := *tracer.Hooks
+		hooks.OnExit = func(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+			if depth == 1 {
+				tracer.OnExit(depth, output, gasUsed, err, reverted)
+				tracer.OnExit(depth, output, gasUsed, err, reverted)
+			}
+			tracer.OnExit(depth, output, gasUsed, err, reverted)
+		}
+		return &hooks
+	}
+	res, err := traceBrontes(t, alloc, &entry, nil, nil, unbalanced)
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	var result brontes.TxTrace
+	if err := json.Unmarshal(res, &result); err != nil {
+		t.Fatalf("failed to parse trace result: %v", err)
+	}
+	if len(result.Errors) != 1 {
+		t.Fatalf("expected the panic to be reported: %s", res)
+	}
+	if panicked := result.Errors[0]; panicked.Stage != brontes.TraceErrorStageExecution || !strings.HasPrefix(panicked.Message, "OnExit panicked") || panicked.Stack == "" {
+		t.Errorf("unexpected panic report: %+v", panicked)
+	}
+}
+
+// Calls answered without executing a transaction, such as those intercepted
+// by the NodeInterface, never start the tracer.
+func TestBrontesTracerWithoutTransaction(t *testing.T) {
+	tracer, err := tracers.DefaultDirectory.New("brontesTracer", new(tracers.Context), nil, params.MainnetChainConfig)
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	tracer.OnEnter(0, byte(vm.CALL), common.Address{}, common.Address{}, nil, 0, big.NewInt(0))
+	tracer.OnOpcode(0, byte(vm.STOP), 0, 0, nil, nil, 1, nil)
+	tracer.OnGasChange(1, 0, tracing.GasChangeUnspecified)
+	tracer.OnLog(&types.Log{})
+	tracer.OnExit(0, nil, 0, nil, false)
+	if _, err := tracer.GetResult(); err == nil {
+		t.Fatalf("expected error without a traced transaction")
+	}
+}
+
 func TestCreateEncodedTx(t *testing.T) {
 	config := params.MainnetChainConfig
 	signer := types.LatestSigner(config)
@@ -386,3 +803,1012 @@ func benchBrontesTracer(tracerName string, test *brontesTracerTest, b *testing.B
 		state.StateDB.RevertToSnapshot(snap)
 	}
 }
+
+func TestBrontesLiveTracer(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		gspec  = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+		dir    = t.TempDir()
+		output = filepath.Join(dir, "brontes.jsonl")
+	)
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q}`, dir)))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	// Every trace must be on disk as soon as its transaction ends.
+	var emitted []int
+	onTxEnd := hooks.OnTxEnd
+	hooks.OnTxEnd = func(receipt *types.Receipt, err error) {
+		onTxEnd(receipt, err)
+		blob, _ := os.ReadFile(output)
+		emitted = append(emitted, strings.Count(string(blob), "\n"))
+	}
+
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *core.BlockGen) {
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   gspec.Config.ChainID,
+				Nonce:     nonce,
+				To:        &to,
+				Gas:       21000,
+				GasFeeCap: b.BaseFee(),
+			})
+			b.AddTx(tx)
+		}
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	if len(emitted) != 2 || emitted[0] != 1 || emitted[1] != 2 {
+		t.Fatalf("traces not emitted per transaction: %v", emitted)
+	}
+
+	blob, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read traces: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(blob)), "\n")
+	for i, line := range lines {
+		var trace brontes.TxTrace
+		if err := json.Unmarshal([]byte(line), &trace); err != nil {
+			t.Fatalf("failed to parse trace %d: %v", i, err)
+		}
+		if trace.TxIndex != i || trace.TxHash != blocks[0].Transactions()[i].Hash() || trace.BlockNumber != 1 {
+			t.Errorf("unexpected trace %d: index %d hash %v block %d", i, trace.TxIndex, trace.TxHash, trace.BlockNumber)
+		}
+	}
+}
+
+// TestBrontesLiveManifest checks that the live tracer writes a manifest of
+// every batch of blocks matching the traces it exported.
+func TestBrontesLiveManifest(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		gspec  = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+		dir    = t.TempDir()
+	)
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"manifest":{"blocks":2}}`, dir)))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	var nonce uint64
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 3, func(i int, b *core.BlockGen) {
+		// Two transactions in the second block, one in the others.
+		for n := 0; n < 1+i%2; n++ {
+			tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   gspec.Config.ChainID,
+				Nonce:     nonce,
+				To:        &to,
+				Gas:       21000,
+				GasFeeCap: b.BaseFee(),
+			})
+			b.AddTx(tx)
+			nonce++
+		}
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	hooks.OnClose()
+
+	var traces []*brontes.TxTrace
+	blob, err := os.ReadFile(filepath.Join(dir, "brontes.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read traces: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(blob)), "\n") {
+		trace := new(brontes.TxTrace)
+		if err := json.Unmarshal([]byte(line), trace); err != nil {
+			t.Fatalf("failed to parse trace: %v", err)
+		}
+		traces = append(traces, trace)
+	}
+	blob, err = os.ReadFile(filepath.Join(dir, "brontes_manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read manifests: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(blob)), "\n")
+	// The first batch holds block 1 alone, the second blocks 2 and 3.
+	ranges := [][2]uint64{{1, 1}, {2, 3}}
+	if len(lines) != len(ranges) {
+		t.Fatalf("have %d manifests, want %d", len(lines), len(ranges))
+	}
+	for i, line := range lines {
+		var manifest brontes.ExportManifest
+		if err := json.Unmarshal([]byte(line), &manifest); err != nil {
+			t.Fatalf("failed to parse manifest %d: %v", i, err)
+		}
+		if manifest.FromBlock != ranges[i][0] || manifest.ToBlock != ranges[i][1] || manifest.ChainId != config.ChainID.Uint64() {
+			t.Errorf("manifest %d: unexpected range %d-%d of chain %d", i, manifest.FromBlock, manifest.ToBlock, manifest.ChainId)
+		}
+		if err := manifest.VerifyTraces(traces); err != nil {
+			t.Errorf("manifest %d: %v", i, err)
+		}
+		if err := manifest.VerifyTraces(traces[1:]); i == 0 && err == nil {
+			t.Errorf("manifest %d: missing trace not detected", i)
+		}
+	}
+}
+
+// TestBrontesLiveSummaries checks that the summary mode writes a summary of
+// every transaction, queueing the flagged ones for full tracing.
+func TestBrontesLiveSummaries(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		gspec  = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+		dir    = t.TempDir()
+	)
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"summaryOnly":true,"flag":{"minValue":"0x64"}}`, dir)))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	if hooks.OnOpcode != nil {
+		t.Fatal("summary mode follows the steps")
+	}
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Transfer 10 and 100 wei, only the latter being flagged.
+	values := []int64{10, 100}
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *core.BlockGen) {
+		for nonce, value := range values {
+			tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   gspec.Config.ChainID,
+				Nonce:     uint64(nonce),
+				To:        &to,
+				Value:     big.NewInt(value),
+				Gas:       21000,
+				GasFeeCap: b.BaseFee(),
+			})
+			b.AddTx(tx)
+		}
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	hooks.OnClose()
+
+	readSummaries := func(name string) []brontes.TxSummary {
+		blob, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		var summaries []brontes.TxSummary
+		for _, line := range strings.Split(strings.TrimSpace(string(blob)), "\n") {
+			var summary brontes.TxSummary
+			if err := json.Unmarshal([]byte(line), &summary); err != nil {
+				t.Fatalf("failed to parse summary: %v", err)
+			}
+			summaries = append(summaries, summary)
+		}
+		return summaries
+	}
+	summaries := readSummaries("brontes_summaries.jsonl")
+	if len(summaries) != len(values) {
+		t.Fatalf("have %d summaries, want %d", len(summaries), len(values))
+	}
+	for i, summary := range summaries {
+		if summary.TxHash != blocks[0].Transactions()[i].Hash() || summary.TxIndex != i || summary.BlockNumber != 1 {
+			t.Errorf("summary %d: unexpected transaction %v index %d block %d", i, summary.TxHash, summary.TxIndex, summary.BlockNumber)
+		}
+		if !summary.IsSuccess || summary.GasUsed.Uint64() != 21000 || summary.Frames != 1 {
+			t.Errorf("summary %d: unexpected outcome: success %v gas %v frames %d", i, summary.IsSuccess, summary.GasUsed, summary.Frames)
+		}
+		if summary.ValueTransfers != 1 || summary.ValueTransferred.Int64() != values[i] {
+			t.Errorf("summary %d: have %d transfers of %v, want 1 of %d", i, summary.ValueTransfers, summary.ValueTransferred, values[i])
+		}
+	}
+	flagged := readSummaries("brontes_flagged.jsonl")
+	if len(flagged) != 1 || flagged[0].TxHash != blocks[0].Transactions()[1].Hash() || len(flagged[0].Flags) != 1 || flagged[0].Flags[0] != brontes.FlagValue {
+		t.Errorf("unexpected flagged transactions: %+v", flagged)
+	}
+}
+
+// stubRetracer traces any transaction into its hash, recording the
+// configurations it is asked to trace with.
+type stubRetracer struct {
+	lock    sync.Mutex
+	configs []brontes.TracingInspectorConfig
+}
+
+func (r *stubRetracer) Retrace(ctx context.Context, hash common.Hash, config brontes.TracingInspectorConfig) (json.RawMessage, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.configs = append(r.configs, config)
+	return json.Marshal(hash)
+}
+
+func TestBrontesLiveEscalation(t *testing.T) {
+	retracer := new(stubRetracer)
+	brontes.SetRetracer(retracer)
+	t.Cleanup(func() { brontes.SetRetracer(nil) })
+
+	var (
+		config = *params.MergedTestChainConfig
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		gspec  = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+		dir    = t.TempDir()
+	)
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"summaryOnly":true,"flag":{"minValue":"0x64"},"escalate":{}}`, dir)))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Transfer 10 and 100 wei, only the latter being traced again.
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *core.BlockGen) {
+		for nonce, value := range []int64{10, 100} {
+			tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   gspec.Config.ChainID,
+				Nonce:     uint64(nonce),
+				To:        &to,
+				Value:     big.NewInt(value),
+				Gas:       21000,
+				GasFeeCap: b.BaseFee(),
+			})
+			b.AddTx(tx)
+		}
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	hooks.OnClose()
+
+	blob, err := os.ReadFile(filepath.Join(dir, "brontes_escalated.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read escalated traces: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(blob)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("have %d escalated traces, want 1", len(lines))
+	}
+	var escalated struct {
+		Summary brontes.TxSummary `json:"summary"`
+		Trace   common.Hash       `json:"trace"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &escalated); err != nil {
+		t.Fatalf("failed to parse escalated trace: %v", err)
+	}
+	want := blocks[0].Transactions()[1].Hash()
+	if escalated.Summary.TxHash != want || escalated.Trace != want {
+		t.Errorf("unexpected escalated transaction: summary of %v, trace of %v, want %v", escalated.Summary.TxHash, escalated.Trace, want)
+	}
+	if len(retracer.configs) != 1 || !retracer.configs[0].RecordSteps || !retracer.configs[0].RecordStateDiff {
+		t.Errorf("unexpected escalation configs: %+v", retracer.configs)
+	}
+}
+
+func TestBrontesLiveCompaction(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		token  = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		topic  = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+		gspec  = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc: types.GenesisAlloc{
+				sender: {Balance: big.NewInt(params.Ether)},
+				// LOG3(0, 32, Transfer, 1, 2) of an amount of 7.
+				token: {Code: append(append([]byte{
+					byte(vm.PUSH1), 7, byte(vm.PUSH1), 0, byte(vm.MSTORE),
+					byte(vm.PUSH1), 2, byte(vm.PUSH1), 1, byte(vm.PUSH32),
+				}, topic.Bytes()...), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.LOG3), byte(vm.STOP))},
+			},
+		}
+		signer = types.LatestSigner(gspec.Config)
+		dir    = t.TempDir()
+	)
+	if _, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"compact":true}`, dir))); err == nil {
+		t.Fatal("expected error for compaction without tables")
+	}
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"tables":{},"compact":true}`, dir)))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// The token is called twice on the first day, the third block moving the
+	// chain to the next day.
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 3, func(i int, b *core.BlockGen) {
+		if i == 2 {
+			b.OffsetTime(24 * 60 * 60)
+		}
+		tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     uint64(i),
+			To:        &token,
+			Value:     big.NewInt(5),
+			Gas:       100000,
+			GasFeeCap: b.BaseFee(),
+		})
+		b.AddTx(tx)
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	hooks.OnClose()
+
+	blob, err := os.ReadFile(filepath.Join(dir, "brontes_daily_contracts.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read daily statistics: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(blob)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("have %d days of statistics, want 1", len(lines))
+	}
+	var daily struct {
+		Day  string                               `json:"day"`
+		Rows brontes.ClickhouseDailyContractStats `json:"rows"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &daily); err != nil {
+		t.Fatalf("failed to parse daily statistics: %v", err)
+	}
+	if want := brontes.Day(blocks[0].Time()); daily.Day != want {
+		t.Errorf("have day %s, want %s", daily.Day, want)
+	}
+	rows := daily.Rows
+	if len(rows.Address) != 1 || rows.Address[0] != token.String() {
+		t.Fatalf("unexpected contracts: %v", rows.Address)
+	}
+	if rows.Calls[0] != 2 || rows.Failed[0] != 0 || rows.GasUsed[0] == 0 {
+		t.Errorf("have %d calls, %d failed, %d gas used", rows.Calls[0], rows.Failed[0], rows.GasUsed[0])
+	}
+	if value := new(big.Int).SetBytes(rows.Value[0][:]); value.Int64() != 10 {
+		t.Errorf("have value %v, want 10", value)
+	}
+	if volume := new(big.Int).SetBytes(rows.TokenVolume[0][:]); rows.TokenTransfers[0] != 2 || volume.Int64() != 14 {
+		t.Errorf("have %d token transfers of %v, want 2 of 14", rows.TokenTransfers[0], volume)
+	}
+}
+
+// TestBrontesLiveAnnotations checks that a live tracer writing tables exports
+// the annotations attached through the API next to them.
+func TestBrontesLiveAnnotations(t *testing.T) {
+	dir := t.TempDir()
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"tables":{}}`, dir)))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	sink := brontes.RegisteredAnnotationSink()
+	if sink == nil {
+		t.Fatal("annotation sink not registered")
+	}
+	trace := &brontes.TxTrace{ChainId: 1, BlockNumber: 7, TxHash: common.Hash{7}, TxIndex: 2}
+	if err := sink.WriteAnnotations(trace, []brontes.Annotation{{IncidentId: "INC-1", CreatedAt: 10}}); err != nil {
+		t.Fatalf("failed to write annotations: %v", err)
+	}
+	hooks.OnClose()
+	if brontes.RegisteredAnnotationSink() != nil {
+		t.Error("annotation sink left registered after close")
+	}
+
+	blob, err := os.ReadFile(filepath.Join(dir, "brontes_annotations.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read annotations: %v", err)
+	}
+	var line struct {
+		BlockNumber uint64                        `json:"block_number"`
+		TxHash      common.Hash                   `json:"tx_hash"`
+		Rows        brontes.ClickhouseAnnotations `json:"rows"`
+	}
+	if err := json.Unmarshal(blob, &line); err != nil {
+		t.Fatalf("failed to parse annotations: %v", err)
+	}
+	if line.BlockNumber != 7 || line.TxHash != trace.TxHash {
+		t.Errorf("unexpected position: block %d tx %v", line.BlockNumber, line.TxHash)
+	}
+	if rows := line.Rows; len(rows.IncidentId) != 1 || rows.IncidentId[0] != "INC-1" || rows.TxIndex[0] != 2 {
+		t.Errorf("unexpected rows: %+v", rows)
+	}
+}
+
+// readBrontesTraces returns the traces of the given output files, in order.
+func readBrontesTraces(t *testing.T, paths ...string) []*brontes.TxTrace {
+	t.Helper()
+	var traces []*brontes.TxTrace
+	for _, path := range paths {
+		blob, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read traces: %v", err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(blob)), "\n") {
+			if line == "" {
+				continue
+			}
+			trace := new(brontes.TxTrace)
+			if err := json.Unmarshal([]byte(line), trace); err != nil {
+				t.Fatalf("failed to parse trace: %v", err)
+			}
+			traces = append(traces, trace)
+		}
+	}
+	return traces
+}
+
+// TestBrontesLiveShards checks that sharded tracers split the blocks among
+// themselves, each rotating its own output files.
+func TestBrontesLiveShards(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		gspec  = &core.Genesis{
+			Config:   &config,
+			GasLimit: 30_000_000,
+			BaseFee:  big.NewInt(params.InitialBaseFee),
+			Alloc:    types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+		engine = beacon.New(ethash.NewFaker())
+	)
+	// Every transaction carries 400KB of calldata, so the 1MB output files
+	// of a shard rotate after its first block.
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 4, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     uint64(i),
+			To:        &to,
+			Gas:       5_000_000,
+			GasFeeCap: b.BaseFee(),
+			Data:      make([]byte, 400*1024),
+		})
+		b.AddTx(tx)
+	})
+	if _, err := tracers.LiveDirectory.New("brontes", json.RawMessage(`{"path":"x","shard":{"index":2,"count":2}}`)); err == nil {
+		t.Fatal("expected error for shard index out of range")
+	}
+	for index := uint64(0); index < 2; index++ {
+		dir := t.TempDir()
+		hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"maxSize":1,"shard":{"index":%d,"count":2}}`, dir, index)))
+		if err != nil {
+			t.Fatalf("failed to create brontes live tracer: %v", err)
+		}
+		chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+		if err != nil {
+			t.Fatalf("failed to create tester chain: %v", err)
+		}
+		if n, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+		}
+		// Stopping the chain closes the tracer.
+		chain.Stop()
+
+		rotated, err := filepath.Glob(filepath.Join(dir, "brontes-*.jsonl"))
+		if err != nil {
+			t.Fatalf("failed to list rotated files: %v", err)
+		}
+		if len(rotated) != 1 {
+			t.Fatalf("shard %d: have %d rotated files, want 1", index, len(rotated))
+		}
+		// The shard owns the blocks whose number modulo 2 is its index, the
+		// rotated file holding the older one.
+		traces := readBrontesTraces(t, rotated[0], filepath.Join(dir, "brontes.jsonl"))
+		if len(traces) != 2 {
+			t.Fatalf("shard %d: have %d traces, want 2", index, len(traces))
+		}
+		for i, trace := range traces {
+			block := blocks[2*uint64(i)+1-index]
+			if trace.BlockNumber%2 != index || trace.BlockNumber != block.NumberU64() || trace.TxHash != block.Transactions()[0].Hash() {
+				t.Errorf("shard %d trace %d: unexpected block %d tx %v", index, i, trace.BlockNumber, trace.TxHash)
+			}
+		}
+	}
+}
+
+// TestBrontesLiveAlerts checks that the findings of the traced transactions
+// are POSTed to every webhook, a failing one not holding the others back.
+func TestBrontesLiveAlerts(t *testing.T) {
+	var (
+		config   = *params.MergedTestChainConfig
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		coinbase = common.HexToAddress("0x000000000000000000000000000000000000c0c0")
+		gspec    = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+
+		lock     sync.Mutex
+		received [][]brontes.Finding
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var findings []brontes.Finding
+		if err := json.NewDecoder(r.Body).Decode(&findings); err != nil {
+			t.Errorf("invalid findings: %v", err)
+		}
+		lock.Lock()
+		received = append(received, findings)
+		lock.Unlock()
+	}))
+	defer hook.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	if _, err := tracers.LiveDirectory.New("brontes", json.RawMessage(`{"path":"x","alerts":{"webhooks":["ftp://example.com"]}}`)); err == nil {
+		t.Fatal("expected error for unsupported webhook scheme")
+	}
+	cfg := fmt.Sprintf(`{"path":%q,"alerts":{"webhooks":[%q,%q],"coinbaseBribe":"1000"}}`, t.TempDir(), broken.URL, hook.URL)
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(cfg))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+
+	// Only the first transfer to the fee recipient reaches the bribe
+	// threshold.
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(coinbase)
+		for nonce, value := range []int64{5000, 10} {
+			tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   gspec.Config.ChainID,
+				Nonce:     uint64(nonce),
+				To:        &coinbase,
+				Value:     big.NewInt(value),
+				Gas:       21000,
+				GasFeeCap: b.BaseFee(),
+			})
+			b.AddTx(tx)
+		}
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	// Stopping the chain closes the tracer, delivering the queued findings.
+	chain.Stop()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(received) != 1 || len(received[0]) != 1 {
+		t.Fatalf("unexpected findings delivered: %+v", received)
+	}
+	finding := received[0][0]
+	if finding.Kind != brontes.FindingCoinbaseBribe || finding.TxHash != blocks[0].Transactions()[0].Hash() || finding.BlockNumber != 1 {
+		t.Errorf("unexpected finding: %+v", finding)
+	}
+}
+
+// stubBackfillQueue records the ranges queued for backfill.
+type stubBackfillQueue struct {
+	ranges [][2]uint64
+}
+
+func (q *stubBackfillQueue) QueueBackfill(from, to uint64) error {
+	q.ranges = append(q.ranges, [2]uint64{from, to})
+	return nil
+}
+
+// TestBrontesLiveResume checks that a tracer started on a node whose export
+// is behind hands the missing blocks to the backfill, once there is one.
+func TestBrontesLiveResume(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		gspec  = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+		engine = beacon.New(ethash.NewFaker())
+		db     = rawdb.NewMemoryDatabase()
+		dir    = t.TempDir()
+	)
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 6, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     uint64(i),
+			To:        &to,
+			Gas:       21000,
+			GasFeeCap: b.BaseFee(),
+		})
+		b.AddTx(tx)
+	})
+	// The node processed blocks 1 to 3 without the tracer.
+	chain, err := core.NewBlockChain(db, core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks[:3]); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	chain.Stop()
+
+	// The export holds block 1 in a rotated file, the active one being
+	// empty after the rotation.
+	trace, _ := json.Marshal(&brontes.TxTrace{BlockNumber: 1, TxHash: blocks[0].Transactions()[0].Hash()})
+	if err := os.WriteFile(filepath.Join(dir, "brontes-2024-01-01T00-00-00.000.jsonl"), append(trace, '\n'), 0644); err != nil {
+		t.Fatalf("failed to write export: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "brontes.jsonl"), nil, 0644); err != nil {
+		t.Fatalf("failed to write export: %v", err)
+	}
+
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q}`, dir)))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	// The backfill only starts after the first block was processed.
+	queue := new(stubBackfillQueue)
+	defer brontes.SetBackfillQueue(nil)
+	var started int
+	onBlockStart := hooks.OnBlockStart
+	hooks.OnBlockStart = func(ev tracing.BlockEvent) {
+		onBlockStart(ev)
+		if started++; started == 1 {
+			if len(queue.ranges) != 0 {
+				t.Errorf("blocks queued without a backfill: %v", queue.ranges)
+			}
+			brontes.SetBackfillQueue(queue)
+		}
+	}
+	chain, err = core.NewBlockChain(db, core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen tester chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks[3:]); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	chain.Stop()
+
+	// Blocks 2 and 3 were never exported, and are queued exactly once.
+	if want := [][2]uint64{{2, 3}}; !reflect.DeepEqual(queue.ranges, want) {
+		t.Errorf("queued ranges mismatch: have %v, want %v", queue.ranges, want)
+	}
+	traces := readBrontesTraces(t, filepath.Join(dir, "brontes.jsonl"))
+	if len(traces) != 3 || traces[0].BlockNumber != 4 {
+		t.Errorf("unexpected traces after resuming: %d", len(traces))
+	}
+}
+
+// TestBrontesLiveTablesCompat checks that the compatibility mode writes only
+// the tables and columns of the brontes writer.
+func TestBrontesLiveTablesCompat(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		gspec  = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+		dir    = t.TempDir()
+	)
+	if _, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"tables":{"access_list":true},"tablesCompat":true}`, dir))); err == nil {
+		t.Fatal("expected error for a table brontes does not write")
+	}
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"tables":{},"tablesCompat":true}`, dir)))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	if brontes.RegisteredAnnotationSink() != nil {
+		t.Error("annotations exported in compatibility mode")
+	}
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			To:        &to,
+			Gas:       21000,
+			GasFeeCap: b.BaseFee(),
+		})
+		b.AddTx(tx)
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	chain.Stop()
+
+	if _, err := os.Stat(filepath.Join(dir, "brontes_access_list.jsonl")); err == nil {
+		t.Error("access list written in compatibility mode")
+	}
+	blob, err := os.ReadFile(filepath.Join(dir, "brontes_call_actions.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read call actions: %v", err)
+	}
+	var line struct {
+		Rows map[string]json.RawMessage `json:"rows"`
+	}
+	if err := json.Unmarshal(blob, &line); err != nil {
+		t.Fatalf("failed to parse call actions: %v", err)
+	}
+	var columns []string
+	for column := range line.Rows {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	if want := []string{"CallType", "From", "Gas", "Input", "To", "TraceIdx", "Value"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("call action columns mismatch: have %v, want %v", columns, want)
+	}
+}
+
+func TestBrontesTracerStateDiff(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		alloc    = types.GenesisAlloc{
+			// SSTORE(1, 2)
+			contract: types.Account{Code: common.FromHex("0x600260015500")},
+		}
+	)
+	res := runBrontesTracer(t, alloc, &contract, nil, json.RawMessage(`{"recordStateDiff": true}`))
+
+	var result brontes.TxTrace
+	if err := json.Unmarshal(res, &result); err != nil {
+		t.Fatalf("failed to parse trace result: %v", err)
+	}
+	if len(result.StateDiffDiscrepancies) != 0 {
+		t.Errorf("unexpected state diff discrepancies: %+v", result.StateDiffDiscrepancies)
+	}
+	diff, ok := result.StateDiff[contract]
+	if !ok {
+		t.Fatalf("no state diff for %v: %+v", contract, result.StateDiff)
+	}
+	slot := common.BigToHash(big.NewInt(1))
+	if change, ok := diff.Storage[slot]; !ok || change.From != (common.Hash{}) || change.To != common.BigToHash(big.NewInt(2)) {
+		t.Errorf("storage diff mismatch: have %+v", diff.Storage)
+	}
+	sender := result.Trace[0].Trace.Action.Call.From
+	if acc := result.StateDiff[sender]; acc == nil || acc.Nonce == nil || acc.Nonce.From != 0 || acc.Nonce.To != 1 {
+		t.Errorf("sender nonce diff mismatch: have %+v", acc)
+	}
+}
+
+// TestBrontesLiveNames checks that the live tracer names addresses with the
+// address book of the node, and refuses to fetch ABIs over the network.
+func TestBrontesLiveNames(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		gspec  = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+		dir    = t.TempDir()
+		path   = filepath.Join(t.TempDir(), "names.json")
+	)
+	if _, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"config":{"fetchAbis":true}}`, dir))); err == nil {
+		t.Fatalf("live tracer fetching abis accepted")
+	}
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(`{%q:"vault"}`, to.Hex())), 0644); err != nil {
+		t.Fatalf("failed to write address book: %v", err)
+	}
+	book, err := brontes.OpenAddressBook(path)
+	if err != nil {
+		t.Fatalf("failed to open address book: %v", err)
+	}
+	defer book.Close()
+	brontes.SetAddressBook(book)
+	defer brontes.SetAddressBook(nil)
+
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q,"config":{"resolveNames":true}}`, dir)))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			To:        &to,
+			Gas:       21000,
+			GasFeeCap: b.BaseFee(),
+		})
+		b.AddTx(tx)
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	traces := readBrontesTraces(t, filepath.Join(dir, "brontes.jsonl"))
+	if len(traces) != 1 {
+		t.Fatalf("have %d traces, want 1", len(traces))
+	}
+	if have := traces[0].AddressNames[to]; have != "vault" {
+		t.Errorf("recipient named %q, want %q", have, "vault")
+	}
+}
+
+func TestBrontesLiveLatency(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		gspec  = &core.Genesis{
+			Config:  &config,
+			BaseFee: big.NewInt(params.InitialBaseFee),
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	hooks, err := tracers.LiveDirectory.New("brontes", json.RawMessage(fmt.Sprintf(`{"path":%q}`, t.TempDir())))
+	if err != nil {
+		t.Fatalf("failed to create brontes live tracer: %v", err)
+	}
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), nil, gspec, nil, engine, vm.Config{Tracer: hooks}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// The second block is empty.
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 3, func(i int, b *core.BlockGen) {
+		if i == 1 {
+			return
+		}
+		tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     b.TxNonce(sender),
+			To:        &to,
+			Gas:       21000,
+			GasFeeCap: b.BaseFee(),
+		})
+		b.AddTx(tx)
+	})
+	before := brontes.PipelineLatency().Stages[brontes.StageCommitted].Count
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	hooks.OnClose()
+
+	latency := brontes.PipelineLatency()
+	if have := latency.Stages[brontes.StageCommitted].Count - before; have != int64(len(blocks)) {
+		t.Errorf("have %d blocks committed, want %d", have, len(blocks))
+	}
+	last := latency.Last
+	if last == nil || last.Number != 3 || last.Hash != blocks[2].Hash() || last.Timestamp != blocks[2].Time() {
+		t.Fatalf("unexpected last block: %+v", last)
+	}
+	if last.Traced.Before(last.Imported) || last.Converted.Before(last.Traced) || last.Committed.Before(last.Converted) {
+		t.Errorf("stages out of order: imported %v, traced %v, converted %v, committed %v", last.Imported, last.Traced, last.Converted, last.Committed)
+	}
+}
+
+func TestBrontesTracerABIRegistry(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		alloc    = types.GenesisAlloc{contract: types.Account{Code: []byte{byte(vm.STOP)}}}
+		input    = crypto.Keccak256([]byte("poke()"))[:4]
+	)
+	contractABI, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"poke","inputs":[],"outputs":[]}]`))
+	if err != nil {
+		t.Fatalf("failed to parse abi: %v", err)
+	}
+	registry := brontes.NewABIRegistry()
+	registry.Register(contract, &contractABI)
+	brontes.SetABIRegistry(registry)
+	defer brontes.SetABIRegistry(nil)
+
+	decoded := func(cfg string) *brontes.DecodedCallData {
+		var result brontes.TxTrace
+		if err := json.Unmarshal(runBrontesTracer(t, alloc, &contract, input, json.RawMessage(cfg)), &result); err != nil {
+			t.Fatalf("failed to parse trace result: %v", err)
+		}
+		return result.Trace[0].DecodedData
+	}
+	if data := decoded(`{}`); data != nil {
+		t.Errorf("call decoded without opting in: %+v", data)
+	}
+	if data := decoded(`{"decodeAbis": true}`); data == nil || data.FunctionName != "poke" {
+		t.Errorf("have decoded call %+v, want poke", data)
+	}
+}
+
+func TestBrontesTracerAddressBook(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+		alloc    = types.GenesisAlloc{contract: types.Account{Code: []byte{byte(vm.STOP)}}}
+		path     = filepath.Join(t.TempDir(), "names.json")
+	)
+	if err := os.WriteFile(path, []byte(`{"`+contract.Hex()+`": "router"}`), 0644); err != nil {
+		t.Fatalf("failed to write address book: %v", err)
+	}
+	book, err := brontes.OpenAddressBook(path)
+	if err != nil {
+		t.Fatalf("failed to open address book: %v", err)
+	}
+	defer book.Close()
+	brontes.SetAddressBook(book)
+	defer brontes.SetAddressBook(nil)
+
+	names := func(cfg string) map[common.Address]string {
+		var result brontes.TxTrace
+		if err := json.Unmarshal(runBrontesTracer(t, alloc, &contract, nil, json.RawMessage(cfg)), &result); err != nil {
+			t.Fatalf("failed to parse trace result: %v", err)
+		}
+		return result.AddressNames
+	}
+	if have := names(`{}`); len(have) != 0 {
+		t.Errorf("names resolved without opting in: %v", have)
+	}
+	if have := names(`{"resolveNames": true}`); have[contract] != "router" {
+		t.Errorf("have names %v, want the contract named router", have)
+	}
+}
@@ -0,0 +1,675 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"runtime/debug"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+func init() {
+	tracers.LiveDirectory.Register("brontes", newBrontesLiveTracer)
+}
+
+type brontesLiveTracerConfig struct {
+	Path    string                         `json:"path"`    // Path to the directory where the traces will be stored
+	MaxSize int                            `json:"maxSize"` // MaxSize is the maximum size in megabytes of the trace file before it gets rotated. It defaults to 100 megabytes.
+	Config  brontes.TracingInspectorConfig `json:"config"`  // Inspector options, missing options keep their defaults
+	// SelectorStats writes the number of calls and gas used per block,
+	// contract and selector instead of the full traces.
+	SelectorStats bool `json:"selectorStats"`
+	// Shard splits the export among several nodes writing into the same
+	// sink. Each node only traces the blocks of its own shard.
+	Shard *brontesShardConfig `json:"shard,omitempty"`
+	// Verify checks every trace against the receipt of its transaction,
+	// writing discrepancies to a quarantine file next to the traces.
+	Verify bool `json:"verify"`
+	// Alerts publishes the findings of the analyzers to external sinks in
+	// near-real-time.
+	Alerts *brontesAlertConfig `json:"alerts,omitempty"`
+	// Retention bounds the age of the rotated trace files.
+	Retention *brontesRetentionConfig `json:"retention,omitempty"`
+	// Tables writes the ClickHouse tables of the traces into a file per table
+	// instead of the full traces. Every table is written unless switched off.
+	// The annotations attached through the API are written to
+	// brontes_annotations.jsonl.
+	Tables brontes.ClickhouseTableSwitches `json:"tables,omitempty"`
+	// TablesCompat writes only the tables and columns of the brontes
+	// ClickHouse writer, so the files load into existing brontes databases.
+	// Annotations are not written then.
+	TablesCompat bool `json:"tablesCompat"`
+	// TableFilter restricts the tables to the frames passing the filter.
+	TableFilter *brontes.ClickhouseFilter `json:"tableFilter,omitempty"`
+	// Compact rolls the call and log tables up into daily statistics per
+	// contract once the chain moved past each day.
+	Compact bool `json:"compact"`
+	// Manifest writes a manifest of every batch of exported blocks, so
+	// consumers can check the completeness of the archive.
+	Manifest *brontesManifestConfig `json:"manifest,omitempty"`
+	// SummaryOnly writes the summaries of the transactions instead of their
+	// traces, without recording their call trees or steps.
+	SummaryOnly bool `json:"summaryOnly"`
+	// Flag queues the transactions meeting its criteria for full tracing,
+	// in summary mode.
+	Flag *brontes.SummaryFlagConfig `json:"flag,omitempty"`
+	// Escalate traces the flagged transactions again in full once their
+	// block is part of the chain. It holds the inspector options of the
+	// full traces, missing options recording the steps and state diff.
+	Escalate json.RawMessage `json:"escalate,omitempty"`
+}
+
+// brontesShardConfig assigns the blocks whose number modulo Count equals
+// Index to a node.
+type brontesShardConfig struct {
+	Index uint64 `json:"index"`
+	Count uint64 `json:"count"`
+}
+
+func (c *brontesShardConfig) validate() error {
+	if c.Count == 0 {
+		return errors.New("brontes shard count must be positive")
+	}
+	if c.Index >= c.Count {
+		return fmt.Errorf("brontes shard index %d out of range, shard count is %d", c.Index, c.Count)
+	}
+	return nil
+}
+
+// owns reports whether the given block belongs to the shard. All blocks
+// belong to a nil shard.
+func (c *brontesShardConfig) owns(number uint64) bool {
+	return c == nil || number%c.Count == c.Index
+}
+
+// brontesLiveTracer traces every transaction of the processed blocks with the
+// brontes inspector. Each trace is written out as soon as its transaction
+// ends, so consumers can stream them and memory use is bounded by the largest
+// transaction instead of the largest block.
+type brontesLiveTracer struct {
+	config      brontes.TracingInspectorConfig
+	chainConfig *params.ChainConfig
+	logger      *lumberjack.Logger
+	quarantine  *lumberjack.Logger // nil unless traces are verified
+	dir         string             // output directory
+	names       []string           // names of the output files, without extension
+	shard       *brontesShardConfig
+
+	resumed     bool                  // whether the export was checked for missing blocks
+	backfill    *brontesBackfillRange // blocks missing from the export, until handed to the backfill
+	skipBlock   bool                  // whether the current block belongs to another shard
+	coinbase    common.Address        // fee recipient of the current block
+	blockNumber uint64                // number of the current block
+	blockTime   uint64                // timestamp of the current block
+	blockHash   common.Hash           // hash of the current block
+	parentHash  common.Hash           // parent hash of the current block
+	latency     brontesLatency        // stage times of the current block
+
+	inspector *brontes.BrontesInspector
+	tx        *types.Transaction
+	txIndex   int
+	panicked  bool // whether a hook panicked while tracing the transaction
+
+	selectors *brontes.SelectorStatsAggregator // nil unless only selector statistics are written
+	alerter   *brontesAlerter                  // nil unless findings are published
+	pruner    *brontesPruner                   // nil unless traces are summarized
+	tables    *brontesTableWriter              // nil unless tables are written instead of traces
+	manifest  *brontesManifestWriter           // nil unless manifests are written
+	compactor *brontesCompactor                // nil unless tables are compacted
+
+	recorder  *brontes.SummaryRecorder   // recorder of the current transaction in summary mode
+	flags     *brontes.SummaryFlagConfig // nil unless transactions are flagged
+	flagged   *lumberjack.Logger         // nil unless transactions are flagged
+	escalator *brontesEscalator          // nil unless flagged transactions are traced again
+}
+
+func newBrontesLiveTracer(cfg json.RawMessage) (*tracing.Hooks, error) {
+	config := brontesLiveTracerConfig{Config: brontes.DefaultTracingInspectorConfig}
+	if err := json.Unmarshal(cfg, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %v", err)
+	}
+	if config.Path == "" {
+		return nil, errors.New("brontes tracer output path is required")
+	}
+	if err := config.Config.Validate(); err != nil {
+		return nil, err
+	}
+	// Blocks must not wait on the network, and the fetcher has no lookups
+	// deferred to later blocks like the selector cache.
+	if config.Config.FetchABIs {
+		return nil, errors.New("brontes live tracer cannot fetch abis")
+	}
+	if config.Shard != nil {
+		if err := config.Shard.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.SelectorStats && config.Tables != nil {
+		return nil, errors.New("brontes selector statistics cannot be combined with tables")
+	}
+	if config.TablesCompat && config.Tables == nil {
+		return nil, errors.New("brontes table compatibility requires tables")
+	}
+	if config.TableFilter != nil && config.Tables == nil {
+		return nil, errors.New("brontes table filter requires tables")
+	}
+	if config.Compact {
+		if config.Tables == nil {
+			return nil, errors.New("brontes compaction requires tables")
+		}
+		// Every node would only count the blocks of its own shard.
+		if config.Shard != nil {
+			return nil, errors.New("brontes compaction cannot be combined with shards")
+		}
+	}
+	if config.Retention != nil {
+		if err := config.Retention.validate(!config.SelectorStats && config.Tables == nil && !config.SummaryOnly); err != nil {
+			return nil, err
+		}
+	}
+	if config.Manifest != nil {
+		if err := config.Manifest.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.SummaryOnly {
+		if config.SelectorStats || config.Tables != nil {
+			return nil, errors.New("brontes summaries cannot be combined with selector statistics or tables")
+		}
+		if config.Verify || config.Alerts != nil {
+			return nil, errors.New("brontes summaries cannot be verified or analyzed")
+		}
+	}
+	if config.Flag != nil {
+		if !config.SummaryOnly {
+			return nil, errors.New("brontes flagging requires summaries")
+		}
+		if err := config.Flag.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.Escalate != nil && config.Flag == nil {
+		return nil, errors.New("brontes escalation requires flagging")
+	}
+
+	var alerter *brontesAlerter
+	if config.Alerts != nil {
+		var err error
+		if alerter, err = newBrontesAlerter(config.Alerts); err != nil {
+			return nil, err
+		}
+	}
+
+	// Store traces in a rotating file
+	name := "brontes"
+	if config.SelectorStats {
+		name = "brontes_selectors"
+	}
+	if config.SummaryOnly {
+		name = "brontes_summaries"
+	}
+	logger := &lumberjack.Logger{
+		Filename: filepath.Join(config.Path, name+".jsonl"),
+	}
+	if config.MaxSize > 0 {
+		logger.MaxSize = config.MaxSize
+	}
+	var maxAge int
+	if config.Retention != nil {
+		maxAge = config.Retention.MaxAge
+	}
+	logger.MaxAge = maxAge
+
+	var tables *brontesTableWriter
+	if config.Tables != nil {
+		var err error
+		if tables, err = newBrontesTableWriter(config.Path, config.Tables, config.TableFilter, config.TablesCompat, config.MaxSize, maxAge); err != nil {
+			return nil, err
+		}
+	}
+
+	t := &brontesLiveTracer{
+		config:  config.Config,
+		dir:     config.Path,
+		names:   []string{name},
+		logger:  logger,
+		shard:   config.Shard,
+		alerter: alerter,
+		tables:  tables,
+	}
+	if config.SelectorStats {
+		t.selectors = brontes.NewSelectorStatsAggregator()
+	}
+	if tables != nil {
+		t.names = t.names[:0]
+		for _, table := range tables.names {
+			t.names = append(t.names, brontesTableFile(table))
+		}
+		if !config.TablesCompat {
+			brontes.SetAnnotationSink(tables)
+		}
+	}
+	if config.Compact {
+		var err error
+		if t.compactor, err = newBrontesCompactor(config.Path, config.Tables); err != nil {
+			return nil, err
+		}
+	}
+	if config.Verify {
+		t.quarantine = &lumberjack.Logger{
+			Filename: filepath.Join(config.Path, "brontes_quarantine.jsonl"),
+		}
+		t.quarantine.MaxAge = maxAge
+	}
+	if config.Retention != nil && config.Retention.SummaryAfter > 0 {
+		t.pruner = &brontesPruner{dir: config.Path, config: *config.Retention}
+	}
+	if config.Flag != nil {
+		t.flags = config.Flag
+		t.flagged = &lumberjack.Logger{
+			Filename: filepath.Join(config.Path, brontesFlaggedFile),
+			MaxAge:   maxAge,
+		}
+	}
+	if config.Escalate != nil {
+		var err error
+		if t.escalator, err = newBrontesEscalator(config.Path, config.Escalate, maxAge); err != nil {
+			return nil, fmt.Errorf("invalid brontes escalation config: %v", err)
+		}
+	}
+	if config.Manifest != nil {
+		t.manifest = newBrontesManifestWriter(config.Path, config.Manifest, maxAge)
+		if tables != nil {
+			tables.manifest = t.manifest
+		}
+	}
+	if config.SummaryOnly {
+		return t.summaryHooks(), nil
+	}
+	hooks := &tracing.Hooks{
+		OnBlockchainInit: t.onBlockchainInit,
+		OnBlockStart:     t.onBlockStart,
+		OnBlockEnd:       t.onBlockEnd,
+		OnTxStart:        t.onTxStart,
+		OnTxEnd:          t.onTxEnd,
+		OnEnter:          t.onEnter,
+		OnExit:           t.onExit,
+		OnOpcode:         t.onOpcode,
+		OnLog:            t.onLog,
+		OnGasChange:      t.onGasChange,
+		OnClose:          t.onClose,
+	}
+	// The state change hooks slow down every transaction, install them only
+	// if the state diff is recorded.
+	if config.Config.RecordStateDiff {
+		hooks.OnBalanceChange = t.onBalanceChange
+		hooks.OnNonceChange = t.onNonceChange
+		hooks.OnCodeChange = t.onCodeChange
+		hooks.OnStorageChange = t.onStorageChange
+	}
+	return hooks, nil
+}
+
+func (t *brontesLiveTracer) onBlockchainInit(chainConfig *params.ChainConfig) {
+	t.chainConfig = chainConfig
+	var chainId uint64
+	if chainConfig.ChainID != nil {
+		chainId = chainConfig.ChainID.Uint64()
+	}
+	t.manifest.init(chainId, t.config.SchemaVersion)
+}
+
+func (t *brontesLiveTracer) onBlockStart(ev tracing.BlockEvent) {
+	if !t.resumed {
+		t.resumed = true
+		t.resume(ev.Block.NumberU64())
+	}
+	if t.backfill != nil {
+		t.queueBackfill()
+	}
+	t.txIndex = 0
+	t.skipBlock = !t.shard.owns(ev.Block.NumberU64())
+	t.coinbase = ev.Block.Coinbase()
+	t.blockNumber = ev.Block.NumberU64()
+	t.blockTime = ev.Block.Time()
+	t.blockHash = ev.Block.Hash()
+	t.parentHash = ev.Block.ParentHash()
+	t.latency.start(t.blockNumber, t.blockHash, t.blockTime)
+}
+
+func (t *brontesLiveTracer) onBlockEnd(err error) {
+	if err == nil {
+		t.pruner.onBlock(t.blockNumber)
+		t.compactor.onBlock(t.blockTime)
+	}
+	// The manifest covers the block once its statistics are written, and the
+	// block is committed along with it.
+	exported := err == nil && !t.skipBlock
+	defer t.latency.commit(exported)
+	defer t.manifest.onBlockEnd(t.blockNumber, t.blockHash, exported)
+	t.escalator.onBlockEnd(exported)
+	if t.selectors == nil {
+		return
+	}
+	// Aborted blocks are not part of the chain, drop their statistics.
+	stats := t.selectors.Flush()
+	if err != nil || len(stats.Address) == 0 {
+		return
+	}
+	out, err := json.Marshal(stats)
+	if err != nil {
+		log.Warn("failed to marshal brontes selector stats", "error", err)
+		return
+	}
+	if _, err := t.logger.Write(append(out, '\n')); err != nil {
+		log.Warn("failed to write to brontes tracer log file", "error", err)
+		return
+	}
+	t.manifest.addRows(brontes.ManifestSelectors, len(stats.Address))
+}
+
+func (t *brontesLiveTracer) onTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	if t.skipBlock {
+		return
+	}
+	t.inspector = brontes.NewBrontesInspector(context.Background(), t.config, t.chainConfig, env, tx, from)
+	t.inspector.BlockHash, t.inspector.ParentHash = t.blockHash, t.parentHash
+	// The registries, cache and store are installed as the node starts,
+	// after the tracer is created. Blocks are not held up by selector
+	// lookups, which resolve in the background for later blocks.
+	if t.config.DecodeABIs {
+		if registry := brontes.RegisteredABIRegistry(); registry != nil {
+			t.inspector.ABIs = registry
+		}
+	}
+	if t.config.ResolveSelectors {
+		if cache := brontes.RegisteredSelectorCache(); cache != nil {
+			t.inspector.Selectors = cache.Deferred()
+		}
+	}
+	if t.config.AttachOrderflow {
+		if store := brontes.RegisteredOrderflowStore(); store != nil {
+			t.inspector.Orderflow = store
+		}
+	}
+	var resolvers brontes.ChainedNameResolver
+	if t.config.ResolveNames {
+		if book := brontes.RegisteredAddressBook(); book != nil {
+			resolvers = append(resolvers, book)
+		}
+	}
+	if t.config.ResolveENS {
+		resolvers = append(resolvers, brontes.ENSReverseResolver{Registry: brontes.ENSRegistryAddress})
+	}
+	if len(resolvers) > 0 {
+		t.inspector.Names = resolvers
+	}
+	t.tx = tx
+	t.panicked = false
+}
+
+func (t *brontesLiveTracer) onTxEnd(receipt *types.Receipt, err error) {
+	// System calls and invalid transactions have nothing to emit.
+	if t.inspector == nil {
+		return
+	}
+	defer func() {
+		t.inspector.Close()
+		t.inspector, t.tx = nil, nil
+		t.txIndex++
+	}()
+	defer t.recoverHook("OnTxEnd")
+	if err != nil || receipt == nil {
+		return
+	}
+	t.inspector.OnTxEnd()
+	t.latency.traced()
+	// Tables are converted straight from the call arena, unless the trace
+	// is needed anyway to verify it or look for findings.
+	if t.tables != nil && t.quarantine == nil && t.alerter == nil {
+		if err := t.tables.writeInspected(t.inspector, t.blockNumber, t.blockTime, t.tx.Hash(), t.txIndex); err != nil {
+			log.Warn("Failed to build brontes tables", "tx", t.tx.Hash(), "err", err)
+			return
+		}
+		t.latency.converted()
+		return
+	}
+	result, err := t.inspector.IntoTraceResults(t.tx, receipt, t.txIndex)
+	if err != nil {
+		log.Warn("Failed to build brontes trace", "tx", t.tx.Hash(), "err", err)
+		return
+	}
+	t.latency.converted()
+	t.verify(result, receipt)
+	t.alerter.publish(result, t.coinbase)
+	if t.selectors != nil {
+		t.selectors.Add(result)
+		return
+	}
+	if t.tables != nil {
+		t.tables.write(result, t.blockTime)
+		return
+	}
+	t.write(result)
+}
+
+func (t *brontesLiveTracer) onEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if t.inspector == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnEnter")
+	if err := t.inspector.OnEnter(depth, typ, from, to, input, gas, value); err != nil {
+		log.Warn("Failed to trace call frame", "tx", t.tx.Hash(), "err", err)
+	}
+}
+
+func (t *brontesLiveTracer) onExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if t.inspector == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnExit")
+	t.inspector.OnExit(depth, output, gasUsed, err, reverted)
+}
+
+func (t *brontesLiveTracer) onOpcode(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+	if t.inspector == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnOpcode")
+	t.inspector.OnOpcode(pc, op, gas, cost, scope, rData, depth, err)
+}
+
+func (t *brontesLiveTracer) onGasChange(old, new uint64, reason tracing.GasChangeReason) {
+	if t.inspector == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnGasChange")
+	t.inspector.OnGasChange(old, new, reason)
+}
+
+func (t *brontesLiveTracer) onLog(l *types.Log) {
+	if t.inspector == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnLog")
+	t.inspector.OnLog(l)
+}
+
+func (t *brontesLiveTracer) onBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	if t.inspector == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnBalanceChange")
+	t.inspector.OnBalanceChange(addr, prev, new, reason)
+}
+
+func (t *brontesLiveTracer) onNonceChange(addr common.Address, prev, new uint64) {
+	if t.inspector == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnNonceChange")
+	t.inspector.OnNonceChange(addr, prev, new)
+}
+
+func (t *brontesLiveTracer) onCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	if t.inspector == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnCodeChange")
+	t.inspector.OnCodeChange(addr, prevCodeHash, prevCode, codeHash, code)
+}
+
+func (t *brontesLiveTracer) onStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+	if t.inspector == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnStorageChange")
+	t.inspector.OnStorageChange(addr, slot, prev, new)
+}
+
+// recoverHook stops tracing the current transaction if a hook panicked,
+// recording the panic with its stack trace in the trace, so a bug of the
+// tracer can never crash block processing.
+func (t *brontesLiveTracer) recoverHook(hook string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	log.Error("Brontes tracer panicked", "hook", hook, "tx", t.tx.Hash(), "panic", r, "stack", string(stack))
+	if t.inspector != nil {
+		t.inspector.RecordPanic(hook, r, stack)
+	}
+	t.panicked = true
+}
+
+func (t *brontesLiveTracer) onClose() {
+	if t.tables != nil {
+		if !t.tables.compat {
+			brontes.SetAnnotationSink(nil)
+		}
+		t.tables.close()
+	}
+	t.alerter.close()
+	t.pruner.close()
+	t.compactor.close()
+	t.manifest.close()
+	t.escalator.close()
+	if err := t.logger.Close(); err != nil {
+		log.Warn("failed to close brontes tracer log file", "error", err)
+	}
+	if t.quarantine != nil {
+		if err := t.quarantine.Close(); err != nil {
+			log.Warn("failed to close brontes quarantine file", "error", err)
+		}
+	}
+	if t.flagged != nil {
+		if err := t.flagged.Close(); err != nil {
+			log.Warn("failed to close brontes flagged file", "error", err)
+		}
+	}
+}
+
+// resume records the blocks between the last exported block and the first
+// block processed since startup for backfill, as the tracer only sees the
+// blocks processed while it is running.
+func (t *brontesLiveTracer) resume(first uint64) {
+	var (
+		last  uint64
+		found bool
+	)
+	for _, name := range t.names {
+		mark, ok, err := highWaterMark(t.dir, name)
+		if err != nil {
+			log.Warn("Failed to find last exported brontes block", "dir", t.dir, "file", name, "err", err)
+			return
+		}
+		if ok && (!found || mark > last) {
+			last, found = mark, true
+		}
+	}
+	if !found || last+1 >= first {
+		return
+	}
+	t.backfill = &brontesBackfillRange{From: last + 1, To: first - 1}
+	if brontes.RegisteredBackfillQueue() == nil {
+		log.Warn("Brontes export is behind, missing blocks wait for a backfill (see --brontes.backfilldir)", "from", last+1, "to", first-1)
+	}
+}
+
+// queueBackfill hands the blocks missing from the export to the node's
+// backfill, once it is running.
+func (t *brontesLiveTracer) queueBackfill() {
+	queue := brontes.RegisteredBackfillQueue()
+	if queue == nil {
+		return
+	}
+	from, to := t.backfill.From, t.backfill.To
+	t.backfill = nil
+	if err := queue.QueueBackfill(from, to); err != nil {
+		log.Warn("Failed to queue brontes backfill", "from", from, "to", to, "err", err)
+		return
+	}
+	log.Warn("Brontes export is behind, queued missing blocks for backfill", "from", from, "to", to)
+}
+
+// verify records the discrepancies between the trace and the receipt of its
+// transaction in the quarantine file, if verification is enabled.
+func (t *brontesLiveTracer) verify(trace *brontes.TxTrace, receipt *types.Receipt) {
+	if t.quarantine == nil {
+		return
+	}
+	discrepancies := brontes.VerifyReceipt(trace, receipt)
+	if len(discrepancies) == 0 {
+		return
+	}
+	log.Warn("Brontes trace does not match receipt", "tx", trace.TxHash, "checks", len(discrepancies))
+	out, err := json.Marshal(brontes.NewClickhouseQuarantine(trace, discrepancies))
+	if err != nil {
+		log.Warn("failed to marshal brontes quarantine entry", "error", err)
+		return
+	}
+	if _, err := t.quarantine.Write(append(out, '\n')); err != nil {
+		log.Warn("failed to write to brontes quarantine file", "error", err)
+	}
+}
+
+func (t *brontesLiveTracer) write(trace *brontes.TxTrace) {
+	out, err := trace.MarshalSchema(t.config.SchemaVersion)
+	if err != nil {
+		log.Warn("failed to marshal brontes trace", "tx", trace.TxHash, "error", err)
+		return
+	}
+	if _, err := t.logger.Write(append(out, '\n')); err != nil {
+		log.Warn("failed to write to brontes tracer log file", "error", err)
+		return
+	}
+	t.manifest.addTrace(trace)
+}
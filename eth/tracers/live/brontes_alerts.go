@@ -0,0 +1,191 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// defaultAlertQueue is the number of finding batches waiting to be
+	// delivered to a sink before further findings are dropped.
+	defaultAlertQueue = 1024
+	// alertTimeout bounds the delivery of a batch of findings.
+	alertTimeout = 5 * time.Second
+)
+
+// brontesAlertConfig selects the sinks the findings of the analyzers are
+// published to. Findings are produced by the detectors enabled in the
+// inspector config, and by the coinbase bribe check configured here.
+type brontesAlertConfig struct {
+	// Webhooks are the URLs the findings of each transaction are POSTed to,
+	// as a JSON array.
+	Webhooks []string `json:"webhooks"`
+	// CoinbaseBribe is the value in wei transferred to the fee recipient of
+	// the block from which a transaction raises a finding. Disabled if unset.
+	CoinbaseBribe *math.HexOrDecimal256 `json:"coinbaseBribe,omitempty"`
+	// QueueSize is the number of batches of findings buffered per sink.
+	QueueSize int `json:"queueSize,omitempty"`
+}
+
+func (c *brontesAlertConfig) validate() error {
+	if len(c.Webhooks) == 0 {
+		return errors.New("brontes alerts require at least one webhook")
+	}
+	for _, hook := range c.Webhooks {
+		u, err := url.Parse(hook)
+		if err != nil {
+			return fmt.Errorf("invalid brontes alert webhook %q: %v", hook, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("unsupported brontes alert webhook scheme %q", u.Scheme)
+		}
+	}
+	return nil
+}
+
+// alertSink delivers findings to an external system. Publishing must not
+// block the block processing.
+type alertSink interface {
+	publish(findings []brontes.Finding)
+	close()
+}
+
+// webhookSink POSTs findings to a URL from a background goroutine. Findings
+// are dropped when the endpoint does not keep up.
+type webhookSink struct {
+	url    string
+	client *http.Client
+	queue  chan []brontes.Finding
+	wg     sync.WaitGroup
+}
+
+func newWebhookSink(url string, queueSize int) *webhookSink {
+	if queueSize <= 0 {
+		queueSize = defaultAlertQueue
+	}
+	s := &webhookSink{
+		url:    url,
+		client: &http.Client{Timeout: alertTimeout},
+		queue:  make(chan []brontes.Finding, queueSize),
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+func (s *webhookSink) publish(findings []brontes.Finding) {
+	select {
+	case s.queue <- findings:
+	default:
+		log.Warn("Dropping brontes findings, webhook is lagging behind", "url", s.url, "findings", len(findings))
+	}
+}
+
+func (s *webhookSink) loop() {
+	defer s.wg.Done()
+	for findings := range s.queue {
+		if err := s.post(findings); err != nil {
+			log.Warn("Failed to deliver brontes findings", "url", s.url, "findings", len(findings), "err", err)
+		}
+	}
+}
+
+func (s *webhookSink) post(findings []brontes.Finding) error {
+	body, err := json.Marshal(findings)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// close delivers the queued findings and stops the sink.
+func (s *webhookSink) close() {
+	close(s.queue)
+	s.wg.Wait()
+}
+
+// brontesAlerter publishes the findings of the traced transactions to the
+// configured sinks.
+type brontesAlerter struct {
+	sinks         []alertSink
+	coinbaseBribe *big.Int // nil unless bribes are checked
+}
+
+func newBrontesAlerter(config *brontesAlertConfig) (*brontesAlerter, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	a := new(brontesAlerter)
+	for _, hook := range config.Webhooks {
+		a.sinks = append(a.sinks, newWebhookSink(hook, config.QueueSize))
+	}
+	if config.CoinbaseBribe != nil {
+		a.coinbaseBribe = (*big.Int)(config.CoinbaseBribe)
+	}
+	return a, nil
+}
+
+// publish sends the findings of a trace to all sinks. A nil alerter
+// publishes nothing.
+func (a *brontesAlerter) publish(trace *brontes.TxTrace, coinbase common.Address) {
+	if a == nil {
+		return
+	}
+	findings := brontes.Findings(trace)
+	if a.coinbaseBribe != nil {
+		if bribe := brontes.FindCoinbaseBribe(trace, coinbase, a.coinbaseBribe); bribe != nil {
+			findings = append(findings, *bribe)
+		}
+	}
+	if len(findings) == 0 {
+		return
+	}
+	for _, sink := range a.sinks {
+		sink.publish(findings)
+	}
+}
+
+func (a *brontesAlerter) close() {
+	if a == nil {
+		return
+	}
+	for _, sink := range a.sinks {
+		sink.close()
+	}
+}
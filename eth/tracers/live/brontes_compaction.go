@@ -0,0 +1,379 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// brontesDailyFile is the file the daily statistics of the contracts are
+// appended to, one JSON object per day.
+const brontesDailyFile = "brontes_daily_contracts.jsonl"
+
+// brontesDailyRows is a line of the daily file, holding the statistics of a
+// single day.
+type brontesDailyRows struct {
+	Day  string                                `json:"day"`
+	Rows *brontes.ClickhouseDailyContractStats `json:"rows"`
+}
+
+// brontesTableLine is a line of a table file, with the rows left encoded.
+type brontesTableLine struct {
+	BlockNumber    uint64          `json:"block_number"`
+	BlockTimestamp uint64          `json:"block_timestamp"`
+	TxHash         common.Hash     `json:"tx_hash"`
+	Rows           json.RawMessage `json:"rows"`
+}
+
+// day returns the day of the block of the line, or false for lines written
+// before block timestamps were recorded.
+func (l *brontesTableLine) day() (string, bool) {
+	if l.BlockTimestamp == 0 {
+		return "", false
+	}
+	return brontes.Day(l.BlockTimestamp), true
+}
+
+// brontesCompactor rolls the call and log tables up into daily statistics
+// per contract in the background, once the chain moved past each day, so
+// long-term analytics need not scan the detailed tables.
+type brontesCompactor struct {
+	dir       string
+	outputs   bool   // whether call outputs are written, for the gas used
+	logs      bool   // whether logs are written, for the token transfers
+	scheduled string // day of the last run scheduled
+
+	lock    sync.Mutex
+	next    string // day of the next run, empty if none is due
+	running bool
+	wg      sync.WaitGroup
+}
+
+func newBrontesCompactor(dir string, tables brontes.ClickhouseTableSwitches) (*brontesCompactor, error) {
+	if !tables.Enabled(brontes.TableCallActions) {
+		return nil, errors.New("brontes compaction requires the call actions table")
+	}
+	return &brontesCompactor{
+		dir:     dir,
+		outputs: tables.Enabled(brontes.TableCallOutputs),
+		logs:    tables.Enabled(brontes.TableLogs),
+	}, nil
+}
+
+// onBlock schedules a run compacting the days before the day of the block
+// once per day. Runs scheduled while one is ongoing follow it. A nil
+// compactor does nothing.
+func (c *brontesCompactor) onBlock(timestamp uint64) {
+	if c == nil {
+		return
+	}
+	day := brontes.Day(timestamp)
+	if day == c.scheduled {
+		return
+	}
+	c.scheduled = day
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.next = day
+	if !c.running {
+		c.running = true
+		c.wg.Add(1)
+		go c.loop()
+	}
+}
+
+// loop runs the scheduled runs until none is due.
+func (c *brontesCompactor) loop() {
+	defer c.wg.Done()
+	for {
+		c.lock.Lock()
+		day := c.next
+		c.next = ""
+		if day == "" {
+			c.running = false
+			c.lock.Unlock()
+			return
+		}
+		c.lock.Unlock()
+
+		if err := c.compact(day); err != nil {
+			log.Warn("Failed to compact brontes tables", "dir", c.dir, "err", err)
+		}
+	}
+}
+
+// close waits for the ongoing run to finish.
+func (c *brontesCompactor) close() {
+	if c != nil {
+		c.wg.Wait()
+	}
+}
+
+// compact appends the statistics of the days after the last compacted day
+// and before the given one to the daily file.
+func (c *brontesCompactor) compact(before string) error {
+	path := filepath.Join(c.dir, brontesDailyFile)
+	var after string
+	line, err := lastLine(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	case len(line) > 0:
+		var last brontesDailyRows
+		if err := json.Unmarshal(line, &last); err != nil {
+			return fmt.Errorf("invalid daily statistics: %v", err)
+		}
+		after = last.Day
+	}
+	var (
+		aggregator = brontes.NewDailyStatsAggregator()
+		pending    = func(day string) bool { return day > after && day < before }
+	)
+	if err := c.compactCalls(aggregator, after, pending); err != nil {
+		return err
+	}
+	if c.logs {
+		if err := c.compactLogs(aggregator, after, pending); err != nil {
+			return err
+		}
+	}
+	var out bytes.Buffer
+	for _, table := range aggregator.Flush() {
+		blob, err := json.Marshal(&brontesDailyRows{Day: table.Day[0], Rows: table})
+		if err != nil {
+			return err
+		}
+		out.Write(append(blob, '\n'))
+	}
+	if out.Len() == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(out.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	log.Info("Compacted brontes tables", "from", after, "before", before)
+	return f.Close()
+}
+
+// compactCalls counts the call actions of the pending days, joined with their
+// outputs. The outputs of a transaction are written along with its actions
+// and only if it has any, so both tables are read side by side.
+func (c *brontesCompactor) compactCalls(aggregator *brontes.DailyStatsAggregator, after string, pending func(string) bool) error {
+	actions, err := openTableReader(c.dir, brontes.TableCallActions, after)
+	if err != nil {
+		return err
+	}
+	defer actions.close()
+
+	var outputs *brontesTableReader
+	if c.outputs {
+		if outputs, err = openTableReader(c.dir, brontes.TableCallOutputs, after); err != nil {
+			return err
+		}
+		defer outputs.close()
+	}
+	var (
+		next    *brontesTableLine // next line of the outputs
+		aligned bool              // whether the tables were matched up yet
+	)
+	for {
+		line, err := actions.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var output *brontesTableLine
+		for outputs != nil {
+			if next == nil {
+				if next, err = outputs.next(); err == io.EOF {
+					outputs = nil
+					break
+				} else if err != nil {
+					return err
+				}
+			}
+			if next.TxHash == line.TxHash && next.BlockNumber == line.BlockNumber {
+				output, next, aligned = next, nil, true
+				break
+			}
+			// The files of the tables rotate independently, so the outputs
+			// may start with blocks before the first action.
+			if !aligned && next.BlockNumber < line.BlockNumber {
+				next = nil
+				continue
+			}
+			break
+		}
+		day, ok := line.day()
+		if !ok || !pending(day) {
+			continue
+		}
+		var calls brontes.ClickhouseCallAction
+		if err := json.Unmarshal(line.Rows, &calls); err != nil {
+			return fmt.Errorf("invalid call actions of %x: %v", line.TxHash, err)
+		}
+		var results *brontes.ClickhouseCallOutput
+		if output != nil {
+			results = new(brontes.ClickhouseCallOutput)
+			if err := json.Unmarshal(output.Rows, results); err != nil {
+				return fmt.Errorf("invalid call outputs of %x: %v", line.TxHash, err)
+			}
+		}
+		aggregator.AddCalls(day, &calls, results)
+	}
+}
+
+// compactLogs counts the token transfers of the pending days.
+func (c *brontesCompactor) compactLogs(aggregator *brontes.DailyStatsAggregator, after string, pending func(string) bool) error {
+	logs, err := openTableReader(c.dir, brontes.TableLogs, after)
+	if err != nil {
+		return err
+	}
+	defer logs.close()
+	for {
+		line, err := logs.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		day, ok := line.day()
+		if !ok || !pending(day) {
+			continue
+		}
+		var rows brontes.ClickhouseLogs
+		if err := json.Unmarshal(line.Rows, &rows); err != nil {
+			return fmt.Errorf("invalid logs of %x: %v", line.TxHash, err)
+		}
+		aggregator.AddLogs(day, &rows)
+	}
+}
+
+// brontesTableReader reads the lines of the files of a table in the order
+// they were written.
+type brontesTableReader struct {
+	dir    string
+	files  []string
+	file   *os.File
+	reader *bufio.Reader
+}
+
+// openTableReader opens the files of a table, skipping the rotated files
+// holding no rows after the given day.
+func openTableReader(dir, table, after string) (*brontesTableReader, error) {
+	files, err := outputFiles(dir, brontesTableFile(table))
+	if err != nil {
+		return nil, err
+	}
+	// A file only holds rows before the first row of the next one.
+	if after != "" {
+		for i := len(files) - 1; i > 0; i-- {
+			if day, ok := firstDay(filepath.Join(dir, files[i])); ok && day <= after {
+				files = files[i:]
+				break
+			}
+		}
+	}
+	return &brontesTableReader{dir: dir, files: files}, nil
+}
+
+// firstDay returns the day of the first line of a table file, or false if
+// unknown.
+func firstDay(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	first, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return "", false
+	}
+	var line brontesTableLine
+	if err := json.Unmarshal(first, &line); err != nil {
+		return "", false
+	}
+	return line.day()
+}
+
+// next returns the next line of the table, or io.EOF once all were read. A
+// line being written, without its newline yet, is left out.
+func (r *brontesTableReader) next() (*brontesTableLine, error) {
+	for {
+		if r.reader == nil {
+			if len(r.files) == 0 {
+				return nil, io.EOF
+			}
+			f, err := os.Open(filepath.Join(r.dir, r.files[0]))
+			r.files = r.files[1:]
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			r.file, r.reader = f, bufio.NewReader(f)
+		}
+		raw, err := r.reader.ReadBytes('\n')
+		if err == io.EOF {
+			r.close()
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+		line := new(brontesTableLine)
+		if err := json.Unmarshal(raw, line); err != nil {
+			return nil, fmt.Errorf("invalid table line in %s: %v", r.file.Name(), err)
+		}
+		return line, nil
+	}
+}
+
+// close closes the file being read.
+func (r *brontesTableReader) close() {
+	if r.file != nil {
+		r.file.Close()
+		r.file, r.reader = nil, nil
+	}
+}
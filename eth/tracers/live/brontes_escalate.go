@@ -0,0 +1,186 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// brontesEscalatedFile is the file the full traces of the flagged
+	// transactions are written to, one JSON object per line.
+	brontesEscalatedFile = "brontes_escalated.jsonl"
+	// escalationQueue is the number of flagged transactions waiting to be
+	// traced again before further ones are dropped.
+	escalationQueue = 1024
+	// escalationAttempts is the number of times a flagged transaction is
+	// traced before giving up. The first attempts usually fail as the block
+	// of the transaction is not written yet.
+	escalationAttempts = 10
+	// escalationRetryDelay is the time between two attempts to trace a
+	// flagged transaction.
+	escalationRetryDelay = time.Second
+	// escalationTimeout bounds a single trace of a flagged transaction.
+	escalationTimeout = 30 * time.Second
+)
+
+// brontesEscalation is a line of the escalated file: the summary of a
+// flagged transaction and its full trace.
+type brontesEscalation struct {
+	Summary *brontes.TxSummary `json:"summary"`
+	Trace   json.RawMessage    `json:"trace"`
+}
+
+// brontesEscalator traces the flagged transactions again in full from a
+// background goroutine, once their block is part of the chain. The traces
+// are made by the node-wide retracer, installed as the node starts.
+type brontesEscalator struct {
+	config  brontes.TracingInspectorConfig
+	logger  *lumberjack.Logger
+	pending []*brontes.TxSummary // flagged transactions of the current block
+	queue   chan *brontes.TxSummary
+	quit    chan struct{} // closed to stop waiting between attempts
+	wg      sync.WaitGroup
+	closed  sync.Once
+}
+
+// newBrontesEscalator creates an escalator tracing with the given inspector
+// options, the default escalation options if empty.
+func newBrontesEscalator(dir string, options json.RawMessage, maxAge int) (*brontesEscalator, error) {
+	config := brontes.DefaultEscalationConfig
+	if len(options) > 0 {
+		if err := json.Unmarshal(options, &config); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	e := &brontesEscalator{
+		config: config,
+		logger: &lumberjack.Logger{
+			Filename: filepath.Join(dir, brontesEscalatedFile),
+			MaxAge:   maxAge,
+		},
+		queue: make(chan *brontes.TxSummary, escalationQueue),
+		quit:  make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.loop()
+	return e, nil
+}
+
+// add records a flagged transaction of the current block. A nil escalator
+// records nothing.
+func (e *brontesEscalator) add(summary *brontes.TxSummary) {
+	if e != nil {
+		e.pending = append(e.pending, summary)
+	}
+}
+
+// onBlockEnd queues the flagged transactions of the block for tracing,
+// unless the block was aborted.
+func (e *brontesEscalator) onBlockEnd(exported bool) {
+	if e == nil {
+		return
+	}
+	pending := e.pending
+	e.pending = nil
+	if !exported {
+		return
+	}
+	for _, summary := range pending {
+		select {
+		case e.queue <- summary:
+		default:
+			log.Warn("Dropping flagged brontes transaction, escalation is lagging behind", "tx", summary.TxHash)
+		}
+	}
+}
+
+func (e *brontesEscalator) loop() {
+	defer e.wg.Done()
+	for summary := range e.queue {
+		trace, err := e.retrace(summary.TxHash)
+		if err != nil {
+			log.Warn("Failed to trace flagged brontes transaction", "tx", summary.TxHash, "err", err)
+			continue
+		}
+		out, err := json.Marshal(&brontesEscalation{Summary: summary, Trace: trace})
+		if err != nil {
+			log.Warn("Failed to marshal escalated brontes trace", "tx", summary.TxHash, "err", err)
+			continue
+		}
+		if _, err := e.logger.Write(append(out, '\n')); err != nil {
+			log.Warn("Failed to write escalated brontes trace", "err", err)
+		}
+	}
+}
+
+// retrace traces a transaction with the retracer, retrying until its block
+// is part of the chain. Once the escalator is closing, every transaction is
+// only attempted once more.
+func (e *brontesEscalator) retrace(hash common.Hash) (json.RawMessage, error) {
+	retracer := brontes.RegisteredRetracer()
+	if retracer == nil {
+		return nil, errors.New("no retracer registered")
+	}
+	var err error
+	for attempt := 0; attempt < escalationAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(escalationRetryDelay):
+			case <-e.quit:
+				return nil, err
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), escalationTimeout)
+		var trace json.RawMessage
+		trace, err = retracer.Retrace(ctx, hash, e.config)
+		cancel()
+		if err == nil {
+			return trace, nil
+		}
+	}
+	return nil, err
+}
+
+// close traces the queued transactions and stops the escalator. Only the
+// first call has any effect, as the chain closes its tracer again on stop.
+func (e *brontesEscalator) close() {
+	if e == nil {
+		return
+	}
+	e.closed.Do(func() {
+		close(e.quit)
+		close(e.queue)
+		e.wg.Wait()
+		if err := e.logger.Close(); err != nil {
+			log.Warn("Failed to close brontes escalated file", "err", err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+)
+
+// brontesLatency marks the times the current block reaches the stages of the
+// export. Transactions are traced and converted as they end, so the block is
+// traced and converted once its last transaction is.
+type brontesLatency struct {
+	block brontes.BlockLatency
+}
+
+// start marks the start of the import of a block.
+func (l *brontesLatency) start(number uint64, hash common.Hash, timestamp uint64) {
+	l.block = brontes.BlockLatency{
+		Number:    number,
+		Hash:      hash,
+		Timestamp: timestamp,
+		Imported:  time.Now(),
+	}
+}
+
+// traced marks the end of the tracing of a transaction.
+func (l *brontesLatency) traced() {
+	l.block.Traced = time.Now()
+}
+
+// converted marks the end of the conversion of a transaction.
+func (l *brontesLatency) converted() {
+	l.block.Converted = time.Now()
+}
+
+// commit marks the output of the block as written and records its latencies,
+// unless it was not exported. Blocks without transactions pass all stages on
+// commit, and a failed conversion counts as done once traced.
+func (l *brontesLatency) commit(exported bool) {
+	if !exported || l.block.Imported.IsZero() {
+		return
+	}
+	l.block.Committed = time.Now()
+	if l.block.Traced.IsZero() {
+		l.block.Traced = l.block.Committed
+	}
+	if l.block.Converted.Before(l.block.Traced) {
+		l.block.Converted = l.block.Traced
+	}
+	brontes.RecordBlockLatency(l.block)
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// brontesManifestFile is the file the manifests of the exported batches are
+// written to, one JSON object per line.
+const brontesManifestFile = "brontes_manifest.jsonl"
+
+// brontesManifestConfig splits the export into batches of aligned block
+// ranges, writing a manifest of every batch once it is complete.
+type brontesManifestConfig struct {
+	// Blocks is the number of blocks of a batch.
+	Blocks uint64 `json:"blocks"`
+}
+
+func (c *brontesManifestConfig) validate() error {
+	if c.Blocks == 0 {
+		return errors.New("brontes manifest batch size must be positive")
+	}
+	return nil
+}
+
+// brontesManifestWriter records the exports of the tracer and writes the
+// manifest of every batch of blocks. A nil writer records nothing.
+type brontesManifestWriter struct {
+	blocks  uint64
+	logger  *lumberjack.Logger
+	builder *brontes.ManifestBuilder // nil until the chain config is known
+}
+
+func newBrontesManifestWriter(dir string, config *brontesManifestConfig, maxAge int) *brontesManifestWriter {
+	return &brontesManifestWriter{
+		blocks: config.Blocks,
+		logger: &lumberjack.Logger{
+			Filename: filepath.Join(dir, brontesManifestFile),
+			MaxAge:   maxAge,
+		},
+	}
+}
+
+// init starts recording the exports of the given chain.
+func (w *brontesManifestWriter) init(chainId uint64, schemaVersion int) {
+	if w != nil {
+		w.builder = brontes.NewManifestBuilder(chainId, schemaVersion)
+	}
+}
+
+// addTrace records a full trace written out.
+func (w *brontesManifestWriter) addTrace(trace *brontes.TxTrace) {
+	if w == nil || w.builder == nil {
+		return
+	}
+	if err := w.builder.AddTrace(trace); err != nil {
+		log.Warn("Failed to hash brontes trace", "tx", trace.TxHash, "err", err)
+	}
+}
+
+// addTransaction records the tables of a transaction written out.
+func (w *brontesManifestWriter) addTransaction() {
+	if w != nil && w.builder != nil {
+		w.builder.AddTransaction()
+	}
+}
+
+// addRows records rows of a table written out.
+func (w *brontesManifestWriter) addRows(table string, rows int) {
+	if w != nil && w.builder != nil {
+		w.builder.AddRows(table, rows)
+	}
+}
+
+// onBlockEnd adds the exports of a block to the batch, unless the block was
+// aborted or not traced, and writes the manifest of the batch if the block
+// is its last.
+func (w *brontesManifestWriter) onBlockEnd(number uint64, hash common.Hash, exported bool) {
+	if w == nil || w.builder == nil {
+		return
+	}
+	if exported {
+		w.builder.EndBlock(number, hash)
+	} else {
+		w.builder.DiscardBlock()
+	}
+	if number%w.blocks == w.blocks-1 {
+		w.flush()
+	}
+}
+
+// flush writes the manifest of the blocks added since the last one, if any.
+func (w *brontesManifestWriter) flush() {
+	manifest := w.builder.Build()
+	if manifest == nil {
+		return
+	}
+	out, err := json.Marshal(manifest)
+	if err != nil {
+		log.Warn("Failed to marshal brontes manifest", "err", err)
+		return
+	}
+	if _, err := w.logger.Write(append(out, '\n')); err != nil {
+		log.Warn("Failed to write brontes manifest", "err", err)
+	}
+}
+
+// close writes the manifest of the incomplete batch and closes the file.
+func (w *brontesManifestWriter) close() {
+	if w == nil {
+		return
+	}
+	if w.builder != nil {
+		w.flush()
+	}
+	if err := w.logger.Close(); err != nil {
+		log.Warn("Failed to close brontes manifest file", "err", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// brontesBackfillRange is an inclusive range of blocks missing from the
+// export.
+type brontesBackfillRange struct {
+	From, To uint64
+}
+
+// outputFiles returns the output files of the given name in the order they
+// were written: the rotated files, oldest first, then the active one. Files
+// may have been removed in the meantime.
+func outputFiles(dir, name string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Rotated files are named after their rotation time, so the newest sort
+	// last.
+	var files []string
+	for _, entry := range entries {
+		if n := entry.Name(); strings.HasPrefix(n, name+"-") && strings.HasSuffix(n, ".jsonl") {
+			files = append(files, n)
+		}
+	}
+	sort.Strings(files)
+	return append(files, name+".jsonl"), nil
+}
+
+// highWaterMark returns the highest block written to the output files of the
+// given name, looking at the active file first and at the rotated ones if it
+// is empty. It reports false if nothing was exported yet.
+func highWaterMark(dir, name string) (uint64, bool, error) {
+	files, err := outputFiles(dir, name)
+	if err != nil {
+		return 0, false, err
+	}
+	slices.Reverse(files)
+	for _, file := range files {
+		line, err := lastLine(filepath.Join(dir, file))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		if len(line) == 0 {
+			continue
+		}
+		// Traces and summaries carry the block number of their transaction,
+		// selector statistics a column of block numbers.
+		var mark struct {
+			Block  *uint64  `json:"block_number"`
+			Blocks []uint64 `json:"BlockNumber"`
+		}
+		if err := json.Unmarshal(line, &mark); err != nil {
+			return 0, false, err
+		}
+		if mark.Block != nil {
+			return *mark.Block, true, nil
+		}
+		if len(mark.Blocks) > 0 {
+			return mark.Blocks[len(mark.Blocks)-1], true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// lastLine returns the last non-empty line of a file, reading it backwards
+// so large files are not read entirely.
+func lastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	const chunk = 64 * 1024
+	var tail []byte
+	for pos := info.Size(); pos > 0; {
+		n := min(chunk, pos)
+		pos -= n
+		block := make([]byte, n)
+		if _, err := f.ReadAt(block, pos); err != nil {
+			return nil, err
+		}
+		tail = append(block, tail...)
+		trimmed := bytes.TrimRight(tail, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+	}
+	return bytes.TrimRight(tail, "\n"), nil
+}
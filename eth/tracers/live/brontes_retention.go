@@ -0,0 +1,188 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// retentionInterval is the number of blocks between runs of the retention
+// policy.
+const retentionInterval = 256
+
+// brontesRetentionConfig bounds the growth of the trace archive. Rotated
+// trace files are first replaced by the summaries of their transactions,
+// then dropped altogether.
+type brontesRetentionConfig struct {
+	// MaxAge is the number of days rotated files are kept. Zero keeps them
+	// forever.
+	MaxAge int `json:"maxAge"`
+	// SummaryAfter is the number of blocks after which the rotated trace
+	// files are replaced by summaries. Zero keeps the full traces.
+	SummaryAfter uint64 `json:"summaryAfter"`
+}
+
+// validate checks the policy, given whether full traces are written.
+func (c *brontesRetentionConfig) validate(traces bool) error {
+	if c.MaxAge < 0 {
+		return errors.New("brontes retention max age must not be negative")
+	}
+	if c.SummaryAfter > 0 && !traces {
+		return errors.New("brontes retention summaries require trace output")
+	}
+	return nil
+}
+
+// brontesPruner applies the retention policy to the rotated trace files in
+// the background. Rotated files older than the maximum age are removed by
+// the file logger itself, the pruner handles the summaries.
+type brontesPruner struct {
+	dir     string
+	config  brontesRetentionConfig
+	last    uint64 // block of the last run
+	running atomic.Bool
+	wg      sync.WaitGroup
+}
+
+// onBlock starts a run of the retention policy every retentionInterval
+// blocks, unless the previous run is still ongoing. A nil pruner does
+// nothing.
+func (p *brontesPruner) onBlock(number uint64) {
+	if p == nil || number < p.last+retentionInterval {
+		return
+	}
+	if !p.running.CompareAndSwap(false, true) {
+		return
+	}
+	p.last = number
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer p.running.Store(false)
+		p.prune(number)
+	}()
+}
+
+// close waits for the ongoing run to finish.
+func (p *brontesPruner) close() {
+	if p != nil {
+		p.wg.Wait()
+	}
+}
+
+// prune summarizes the rotated trace files whose blocks are all older than
+// the summary horizon and removes the expired summaries.
+func (p *brontesPruner) prune(head uint64) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		log.Warn("Failed to list brontes trace files", "dir", p.dir, "err", err)
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -p.config.MaxAge)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "brontes-") || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		path := filepath.Join(p.dir, name)
+		if strings.HasSuffix(name, ".summary.jsonl") {
+			if p.config.MaxAge == 0 {
+				continue
+			}
+			if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+				if err := os.Remove(path); err != nil {
+					log.Warn("Failed to remove expired brontes summaries", "file", path, "err", err)
+				}
+			}
+			continue
+		}
+		if p.config.SummaryAfter > 0 {
+			if err := p.summarize(path, head); err != nil {
+				log.Warn("Failed to summarize brontes traces", "file", path, "err", err)
+			}
+		}
+	}
+}
+
+// summarize replaces a rotated trace file by the summaries of its
+// transactions, if all of them are beyond the summary horizon.
+func (p *brontesPruner) summarize(path string, head uint64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var (
+		reader = bufio.NewReader(f)
+		out    bytes.Buffer
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var trace brontes.TxTrace
+			if err := json.Unmarshal(line, &trace); err != nil {
+				return fmt.Errorf("invalid trace: %v", err)
+			}
+			if trace.BlockNumber+p.config.SummaryAfter > head {
+				return nil
+			}
+			summary, err := json.Marshal(trace.Summary())
+			if err != nil {
+				return err
+			}
+			out.Write(append(summary, '\n'))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// Write the summaries under a temporary name first, so a crash never
+	// leaves the traces without their summaries.
+	dest := strings.TrimSuffix(path, ".jsonl") + ".summary.jsonl"
+	if err := os.WriteFile(dest+".tmp", out.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(dest+".tmp", dest); err != nil {
+		return err
+	}
+	// The summaries expire with the age of the traces they replace.
+	if err := os.Chtimes(dest, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
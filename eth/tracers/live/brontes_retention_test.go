@@ -0,0 +1,177 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+)
+
+// writeTraces writes a trace file holding a trace of each of the blocks.
+func writeTraces(t *testing.T, path string, blocks ...uint64) {
+	t.Helper()
+	var out []byte
+	for i, number := range blocks {
+		line, err := json.Marshal(&brontes.TxTrace{BlockNumber: number, TxHash: common.Hash{byte(i + 1)}})
+		if err != nil {
+			t.Fatalf("failed to encode trace: %v", err)
+		}
+		out = append(append(out, line...), '\n')
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		t.Fatalf("failed to write traces: %v", err)
+	}
+}
+
+// age sets the modification time of a file to the given number of days ago.
+func age(t *testing.T, path string, days int) {
+	t.Helper()
+	mtime := time.Now().AddDate(0, 0, -days)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("failed to age file: %v", err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func TestBrontesPrunerSummaryHorizon(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		old    = filepath.Join(dir, "brontes-2024-01-01T00-00-00.000.jsonl")
+		recent = filepath.Join(dir, "brontes-2024-01-02T00-00-00.000.jsonl")
+		active = filepath.Join(dir, "brontes.jsonl")
+	)
+	writeTraces(t, old, 10, 20)
+	writeTraces(t, recent, 20, 60)
+	writeTraces(t, active, 10)
+	age(t, old, 3)
+
+	// With the head at 100 and a horizon of 50 blocks, only the file whose
+	// blocks are all older than block 50 is summarized.
+	p := &brontesPruner{dir: dir, config: brontesRetentionConfig{SummaryAfter: 50}}
+	p.prune(100)
+
+	if exists(old) {
+		t.Errorf("traces beyond the horizon not removed")
+	}
+	summary := strings.TrimSuffix(old, ".jsonl") + ".summary.jsonl"
+	blob, err := os.ReadFile(summary)
+	if err != nil {
+		t.Fatalf("failed to read summaries: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(blob)), "\n"); len(lines) != 2 {
+		t.Errorf("have %d summaries, want 2", len(lines))
+	}
+	// The summaries keep the age of the traces they replace.
+	if info, err := os.Stat(summary); err != nil || time.Since(info.ModTime()) < 2*24*time.Hour {
+		t.Errorf("summaries do not keep the age of the traces: %v", err)
+	}
+	if !exists(recent) {
+		t.Errorf("traces within the horizon removed")
+	}
+	if !exists(active) {
+		t.Errorf("active trace file removed")
+	}
+	if exists(strings.TrimSuffix(recent, ".jsonl") + ".summary.jsonl") {
+		t.Errorf("traces within the horizon summarized")
+	}
+}
+
+func TestBrontesPrunerMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		expired = filepath.Join(dir, "brontes-2024-01-01T00-00-00.000.summary.jsonl")
+		fresh   = filepath.Join(dir, "brontes-2024-01-02T00-00-00.000.summary.jsonl")
+		active  = filepath.Join(dir, "brontes.jsonl")
+		tables  = filepath.Join(dir, "brontes_logs-2024-01-01T00-00-00.000.jsonl")
+	)
+	for _, path := range []string{expired, fresh, active, tables} {
+		writeTraces(t, path, 1)
+		age(t, path, 10)
+	}
+	age(t, fresh, 2)
+
+	// Summaries are kept forever without a maximum age.
+	p := &brontesPruner{dir: dir, config: brontesRetentionConfig{SummaryAfter: 1}}
+	p.prune(1000)
+	if !exists(expired) {
+		t.Fatalf("summaries removed without a maximum age")
+	}
+	p.config.MaxAge = 7
+	p.prune(1000)
+
+	if exists(expired) {
+		t.Errorf("expired summaries not removed")
+	}
+	if !exists(fresh) {
+		t.Errorf("summaries younger than the maximum age removed")
+	}
+	// Files the pruner does not own are left alone, however old.
+	if !exists(active) {
+		t.Errorf("active trace file removed")
+	}
+	if !exists(tables) {
+		t.Errorf("table file removed")
+	}
+}
+
+func TestBrontesPrunerStaysInDir(t *testing.T) {
+	var (
+		root    = t.TempDir()
+		dir     = filepath.Join(root, "traces")
+		nested  = filepath.Join(dir, "archive")
+		outside = filepath.Join(root, "brontes-2024-01-01T00-00-00.000.summary.jsonl")
+		target  = filepath.Join(root, "target.jsonl")
+	)
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("failed to create directories: %v", err)
+	}
+	var (
+		inNested = filepath.Join(nested, "brontes-2024-01-01T00-00-00.000.summary.jsonl")
+		link     = filepath.Join(dir, "brontes-2024-01-01T00-00-00.000.jsonl")
+	)
+	for _, path := range []string{outside, inNested, target} {
+		writeTraces(t, path, 1)
+		age(t, path, 30)
+	}
+	// A rotated file linking outside of the directory only loses the link.
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	p := &brontesPruner{dir: dir, config: brontesRetentionConfig{MaxAge: 7, SummaryAfter: 1}}
+	p.prune(1000)
+
+	if !exists(outside) {
+		t.Errorf("file outside of the trace directory removed")
+	}
+	if !exists(inNested) {
+		t.Errorf("file in a nested directory removed")
+	}
+	if !exists(target) {
+		t.Errorf("target of a link removed")
+	}
+}
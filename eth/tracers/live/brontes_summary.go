@@ -0,0 +1,116 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// brontesFlaggedFile is the file the summaries of the transactions flagged
+// for full tracing are queued in, one JSON object per line. Consumers trace
+// them on demand with brontes_traceTransactions, unless the tracer escalates
+// them itself.
+const brontesFlaggedFile = "brontes_flagged.jsonl"
+
+// summaryHooks installs the hooks of the summary mode, which only follows
+// the call frames and logs of the transactions.
+func (t *brontesLiveTracer) summaryHooks() *tracing.Hooks {
+	return &tracing.Hooks{
+		OnBlockchainInit: t.onBlockchainInit,
+		OnBlockStart:     t.onBlockStart,
+		OnBlockEnd:       t.onBlockEnd,
+		OnTxStart:        t.onSummaryTxStart,
+		OnTxEnd:          t.onSummaryTxEnd,
+		OnEnter:          t.onSummaryEnter,
+		OnExit:           t.onSummaryExit,
+		OnLog:            t.onSummaryLog,
+		OnClose:          t.onClose,
+	}
+}
+
+func (t *brontesLiveTracer) onSummaryTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	if t.skipBlock {
+		return
+	}
+	t.recorder = brontes.NewSummaryRecorder(t.chainConfig, env, tx, t.txIndex, t.flags)
+	t.tx = tx
+	t.panicked = false
+}
+
+func (t *brontesLiveTracer) onSummaryTxEnd(receipt *types.Receipt, err error) {
+	if t.recorder == nil {
+		return
+	}
+	defer func() {
+		t.recorder, t.tx = nil, nil
+		t.txIndex++
+	}()
+	if err != nil || receipt == nil || t.panicked {
+		return
+	}
+	t.latency.traced()
+	summary := t.recorder.Summary(receipt)
+	out, err := json.Marshal(summary)
+	if err != nil {
+		log.Warn("failed to marshal brontes summary", "tx", summary.TxHash, "error", err)
+		return
+	}
+	t.latency.converted()
+	if _, err := t.logger.Write(append(out, '\n')); err != nil {
+		log.Warn("failed to write to brontes tracer log file", "error", err)
+		return
+	}
+	t.manifest.addTransaction()
+	t.manifest.addRows(brontes.ManifestSummaries, 1)
+	if len(summary.Flags) > 0 {
+		if _, err := t.flagged.Write(append(out, '\n')); err != nil {
+			log.Warn("failed to write to brontes flagged file", "error", err)
+		}
+		t.escalator.add(summary)
+	}
+}
+
+func (t *brontesLiveTracer) onSummaryEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if t.recorder == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnEnter")
+	t.recorder.OnEnter(depth, typ, from, to, input, gas, value)
+}
+
+func (t *brontesLiveTracer) onSummaryExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if t.recorder == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnExit")
+	t.recorder.OnExit(depth, output, gasUsed, err, reverted)
+}
+
+func (t *brontesLiveTracer) onSummaryLog(l *types.Log) {
+	if t.recorder == nil || t.panicked {
+		return
+	}
+	defer t.recoverHook("OnLog")
+	t.recorder.OnLog(l)
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// brontesTableRows is a line of a table file, holding the rows of a single
+// transaction.
+type brontesTableRows struct {
+	BlockNumber    uint64      `json:"block_number"`
+	BlockTimestamp uint64      `json:"block_timestamp"`
+	TxHash         common.Hash `json:"tx_hash"`
+	TxIndex        int         `json:"tx_index"`
+	Rows           interface{} `json:"rows"`
+}
+
+// brontesAnnotationTable is the table the annotations attached through the
+// API are written to, as they arrive rather than per traced transaction.
+const brontesAnnotationTable = "annotations"
+
+// brontesTableWriter writes the enabled ClickHouse tables of the traces into
+// a rotating file per table, named brontes_<table>.jsonl, so every table can
+// be ingested separately.
+type brontesTableWriter struct {
+	switches    brontes.ClickhouseTableSwitches
+	filter      *brontes.ClickhouseFilter // nil unless the frames are filtered
+	compat      bool                      // whether only the columns of the brontes writer are written
+	names       []string                  // names of the written tables
+	loggers     map[string]*lumberjack.Logger
+	annotations *lumberjack.Logger     // annotations, written from the API
+	manifest    *brontesManifestWriter // nil unless manifests are written
+}
+
+func newBrontesTableWriter(dir string, switches brontes.ClickhouseTableSwitches, filter *brontes.ClickhouseFilter, compat bool, maxSize, maxAge int) (*brontesTableWriter, error) {
+	if err := switches.Validate(); err != nil {
+		return nil, err
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	names := switches.Tables()
+	if compat {
+		var err error
+		if names, err = switches.CompatTables(); err != nil {
+			return nil, err
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("all brontes tables are disabled")
+	}
+	w := &brontesTableWriter{switches: switches, filter: filter, compat: compat, names: names, loggers: make(map[string]*lumberjack.Logger)}
+	for _, table := range names {
+		w.loggers[table] = &lumberjack.Logger{
+			Filename: filepath.Join(dir, brontesTableFile(table)+".jsonl"),
+			MaxSize:  maxSize,
+			MaxAge:   maxAge,
+		}
+	}
+	w.annotations = &lumberjack.Logger{
+		Filename: filepath.Join(dir, brontesTableFile(brontesAnnotationTable)+".jsonl"),
+		MaxSize:  maxSize,
+		MaxAge:   maxAge,
+	}
+	return w, nil
+}
+
+// brontesTableFile returns the name of the files of a table, without
+// extension.
+func brontesTableFile(table string) string {
+	return "brontes_" + table
+}
+
+// write appends the rows of the enabled tables of a trace to their files.
+func (w *brontesTableWriter) write(trace *brontes.TxTrace, blockTime uint64) {
+	w.writeRows(trace.BlockNumber, blockTime, trace.TxHash, trace.TxIndex, brontes.NewClickhouseTables(trace, w.switches, nil, w.filter))
+}
+
+// writeInspected appends the rows of the enabled tables of the transaction
+// recorded by the inspector to their files, converting the call arena
+// directly instead of building the trace first.
+func (w *brontesTableWriter) writeInspected(inspector *brontes.BrontesInspector, blockNumber, blockTime uint64, txHash common.Hash, txIndex int) error {
+	tables, err := inspector.IntoClickhouseTables(txIndex, w.switches, nil, w.filter)
+	if err != nil {
+		return err
+	}
+	w.writeRows(blockNumber, blockTime, txHash, txIndex, tables)
+	return nil
+}
+
+// writeRows appends the rows of the tables of a transaction to their files.
+func (w *brontesTableWriter) writeRows(blockNumber, blockTime uint64, txHash common.Hash, txIndex int, tables map[string]interface{}) {
+	w.manifest.addTransaction()
+	for table, rows := range tables {
+		logger, ok := w.loggers[table]
+		if !ok {
+			continue
+		}
+		count, countErr := brontes.ClickhouseRowCount(rows)
+		if w.compat {
+			compat, err := brontes.ClickhouseCompat(table, rows)
+			if err != nil {
+				log.Warn("failed to convert brontes table rows", "table", table, "tx", txHash, "error", err)
+				continue
+			}
+			rows = compat
+		}
+		out, err := json.Marshal(&brontesTableRows{
+			BlockNumber:    blockNumber,
+			BlockTimestamp: blockTime,
+			TxHash:         txHash,
+			TxIndex:        txIndex,
+			Rows:           rows,
+		})
+		if err != nil {
+			log.Warn("failed to marshal brontes table rows", "table", table, "tx", txHash, "error", err)
+			continue
+		}
+		if _, err := logger.Write(append(out, '\n')); err != nil {
+			log.Warn("failed to write to brontes table file", "table", table, "error", err)
+			continue
+		}
+		if countErr == nil {
+			w.manifest.addRows(table, count)
+		}
+	}
+}
+
+// WriteAnnotations implements brontes.AnnotationSink, appending the rows of
+// the annotations of a transaction to the annotations file.
+func (w *brontesTableWriter) WriteAnnotations(trace *brontes.TxTrace, annotations []brontes.Annotation) error {
+	out, err := json.Marshal(&brontesTableRows{
+		BlockNumber: trace.BlockNumber,
+		TxHash:      trace.TxHash,
+		TxIndex:     trace.TxIndex,
+		Rows:        brontes.NewClickhouseAnnotations(trace, annotations),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.annotations.Write(append(out, '\n'))
+	return err
+}
+
+func (w *brontesTableWriter) close() {
+	for table, logger := range w.loggers {
+		if err := logger.Close(); err != nil {
+			log.Warn("failed to close brontes table file", "table", table, "error", err)
+		}
+	}
+	if err := w.annotations.Close(); err != nil {
+		log.Warn("failed to close brontes table file", "table", brontesAnnotationTable, "error", err)
+	}
+}
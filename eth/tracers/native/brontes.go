@@ -1,8 +1,11 @@
 package native
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"math/big"
+	"runtime/debug"
 	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -18,22 +21,85 @@ func init() {
 	tracers.DefaultDirectory.Register("brontesTracer", newBrontesTracer, false)
 }
 
+// errBrontesNoTransaction is returned for the result of a tracer the
+// execution of which never started a transaction.
+var errBrontesNoTransaction = errors.New("no transaction traced")
+
 type brontesTracer struct {
 	ctx         *tracers.Context
+	config      brontes.TracingInspectorConfig
 	inspector   *brontes.BrontesInspector
 	chainConfig *params.ChainConfig
+	abis        brontes.ABIProvider
+	selectors   brontes.SelectorResolver
+	orderflow   brontes.OrderflowProvider
+	names       brontes.NameResolver
 	receipt     *types.Receipt
 	tx          *types.Transaction
 	// for stopping the tracer
 	interrupt atomic.Bool
 	reason    error
+	// building is set once the results are being built
+	building atomic.Bool
+	// runCtx is cancelled on Stop, aborting the conversion of the results
+	runCtx context.Context
+	cancel context.CancelCauseFunc
 }
 
-func newBrontesTracerObject(ctx *tracers.Context, _ json.RawMessage, chainConfig *params.ChainConfig) (*brontesTracer, error) {
-	return &brontesTracer{
+func newBrontesTracerObject(ctx *tracers.Context, cfg json.RawMessage, chainConfig *params.ChainConfig) (*brontesTracer, error) {
+	// Options missing from the config keep their default values.
+	config := brontes.DefaultTracingInspectorConfig
+	if err := json.Unmarshal(cfg, &config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	t := &brontesTracer{
 		ctx:         ctx,
+		config:      config,
 		chainConfig: chainConfig,
-	}, nil
+	}
+	t.runCtx, t.cancel = context.WithCancelCause(context.Background())
+	// The registry and address book are loaded by the node from the files of
+	// the operator, so callers cannot point the tracer at arbitrary files.
+	if config.DecodeABIs {
+		if registry := brontes.RegisteredABIRegistry(); registry != nil {
+			t.abis = registry
+		}
+	}
+	if config.FetchABIs {
+		if fetcher := brontes.RegisteredABIFetcher(); fetcher != nil {
+			if t.abis != nil {
+				t.abis = brontes.ChainedABIProvider{t.abis, fetcher}
+			} else {
+				t.abis = fetcher
+			}
+		}
+	}
+	if config.ResolveSelectors {
+		if cache := brontes.RegisteredSelectorCache(); cache != nil {
+			t.selectors = cache
+		}
+	}
+	if config.AttachOrderflow {
+		if store := brontes.RegisteredOrderflowStore(); store != nil {
+			t.orderflow = store
+		}
+	}
+	var resolvers brontes.ChainedNameResolver
+	if config.ResolveNames {
+		if book := brontes.RegisteredAddressBook(); book != nil {
+			resolvers = append(resolvers, book)
+		}
+	}
+	if config.ResolveENS {
+		resolvers = append(resolvers, brontes.ENSReverseResolver{Registry: brontes.ENSRegistryAddress})
+	}
+	if len(resolvers) > 0 {
+		t.names = resolvers
+	}
+	return t, nil
 }
 
 func newBrontesTracer(ctx *tracers.Context, cfg json.RawMessage, chainConfig *params.ChainConfig) (*tracers.Tracer, error) {
@@ -41,23 +107,33 @@ func newBrontesTracer(ctx *tracers.Context, cfg json.RawMessage, chainConfig *pa
 	if err != nil {
 		return nil, err
 	}
+	hooks := &tracing.Hooks{
+		OnTxStart:   t.OnTxStart,
+		OnTxEnd:     t.OnTxEnd,
+		OnEnter:     t.OnEnter,
+		OnExit:      t.OnExit,
+		OnOpcode:    t.OnOpcode,
+		OnLog:       t.OnLog,
+		OnGasChange: t.OnGasChange,
+	}
+	if t.config.RecordStateDiff {
+		hooks.OnBalanceChange = t.OnBalanceChange
+		hooks.OnNonceChange = t.OnNonceChange
+		hooks.OnCodeChange = t.OnCodeChange
+		hooks.OnStorageChange = t.OnStorageChange
+	}
 	return &tracers.Tracer{
-		Hooks: &tracing.Hooks{
-			OnTxStart: t.OnTxStart,
-			OnTxEnd:   t.OnTxEnd,
-			OnEnter:   t.OnEnter,
-			OnExit:    t.OnExit,
-			OnOpcode:  t.OnOpcode,
-			OnLog:     t.OnLog,
-		},
-		GetResult: t.GetResult,
-		Stop:      t.Stop,
+		Hooks:             hooks,
+		GetResult:         t.GetResult,
+		GetDeferredResult: t.GetDeferredResult,
+		Stop:              t.Stop,
 	}, nil
 }
 
 // step
 func (t *brontesTracer) OnOpcode(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
-	if t.interrupt.Load() {
+	defer t.recoverHook("OnOpcode")
+	if t.inspector == nil || t.interrupt.Load() {
 		return
 	}
 	t.inspector.OnOpcode(pc, op, gas, cost, scope, rData, depth, err)
@@ -68,20 +144,25 @@ func (*brontesTracer) OnFault(pc uint64, op byte, gas, cost uint64, _ tracing.Op
 
 // Step in
 func (t *brontesTracer) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
-	if t.interrupt.Load() {
+	defer t.recoverHook("OnEnter")
+	if t.inspector == nil || t.interrupt.Load() {
 		return
 	}
 	ethlog.Debug("BrontesTracer: OnEnter", "depth", depth, "typ", typ, "from", from.Hex(), "to", to.Hex(), "input", input, "gas", gas, "value", value)
 	err := t.inspector.OnEnter(depth, typ, from, to, input, gas, value)
 	if err != nil {
 		ethlog.Error("BrontesTracer: OnEnter", "error", err)
+		if t.config.PartialResults {
+			t.inspector.RecordError(brontes.TraceErrorStageExecution, err, nil)
+		}
 		t.interrupt.Store(true)
 	}
 }
 
 // Step out
 func (t *brontesTracer) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
-	if t.interrupt.Load() {
+	defer t.recoverHook("OnExit")
+	if t.inspector == nil || t.interrupt.Load() {
 		return
 	}
 	ethlog.Debug("BrontesTracer: OnExit", "depth", depth, "output", output, "gasUsed", gasUsed, "err", err, "reverted", reverted)
@@ -90,7 +171,14 @@ func (t *brontesTracer) OnExit(depth int, output []byte, gasUsed uint64, err err
 
 func (t *brontesTracer) OnTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
 	// Initialize the BrontesInspector
-	t.inspector = brontes.NewBrontesInspector(brontes.DefaultTracingInspectorConfig, t.chainConfig, env, tx, from)
+	t.inspector = brontes.NewBrontesInspector(t.runCtx, t.config, t.chainConfig, env, tx, from)
+	t.inspector.ABIs = t.abis
+	t.inspector.Selectors = t.selectors
+	t.inspector.Orderflow = t.orderflow
+	if t.ctx != nil {
+		t.inspector.BlockHash = t.ctx.BlockHash
+	}
+	t.inspector.Names = t.names
 	t.tx = tx
 }
 
@@ -99,25 +187,150 @@ func (t *brontesTracer) OnTxEnd(receipt *types.Receipt, err error) {
 		ethlog.Debug("BrontesTracer: Transaction ended", "txHash", receipt.TxHash.Hex(), "err", err)
 	}
 	t.receipt = receipt
+	if t.inspector != nil && !t.interrupt.Load() {
+		defer t.recoverHook("OnTxEnd")
+		t.inspector.OnTxEnd()
+	}
+}
+
+func (t *brontesTracer) OnBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	defer t.recoverHook("OnBalanceChange")
+	if t.inspector == nil || t.interrupt.Load() {
+		return
+	}
+	t.inspector.OnBalanceChange(addr, prev, new, reason)
+}
+
+func (t *brontesTracer) OnNonceChange(addr common.Address, prev, new uint64) {
+	defer t.recoverHook("OnNonceChange")
+	if t.inspector == nil || t.interrupt.Load() {
+		return
+	}
+	t.inspector.OnNonceChange(addr, prev, new)
+}
+
+func (t *brontesTracer) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	defer t.recoverHook("OnCodeChange")
+	if t.inspector == nil || t.interrupt.Load() {
+		return
+	}
+	t.inspector.OnCodeChange(addr, prevCodeHash, prevCode, codeHash, code)
+}
+
+func (t *brontesTracer) OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+	defer t.recoverHook("OnStorageChange")
+	if t.inspector == nil || t.interrupt.Load() {
+		return
+	}
+	t.inspector.OnStorageChange(addr, slot, prev, new)
+}
+
+func (t *brontesTracer) OnGasChange(old, new uint64, reason tracing.GasChangeReason) {
+	defer t.recoverHook("OnGasChange")
+	if t.inspector == nil || t.interrupt.Load() {
+		return
+	}
+	t.inspector.OnGasChange(old, new, reason)
 }
 
 func (t *brontesTracer) OnLog(log *types.Log) {
-	if t.interrupt.Load() {
+	defer t.recoverHook("OnLog")
+	if t.inspector == nil || t.interrupt.Load() {
 		return
 	}
 	t.inspector.OnLog(log)
 }
 
+// recoverHook turns a panic of a hook into an interrupt of the tracer, which
+// is reported with its stack trace in the result, so a bug of the tracer can
+// never crash the node.
+func (t *brontesTracer) recoverHook(hook string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	ethlog.Error("BrontesTracer: hook panicked", "hook", hook, "panic", r, "stack", string(stack))
+	if t.inspector != nil {
+		t.inspector.RecordPanic(hook, r, stack)
+	}
+	t.interrupt.Store(true)
+}
+
 func (t *brontesTracer) GetResult() (json.RawMessage, error) {
-	result, err := t.inspector.IntoTraceResults(t.tx, t.receipt, t.ctx.TxIndex)
+	encode, err := t.GetDeferredResult()
+	if err != nil {
+		return nil, err
+	}
+	return encode()
+}
+
+// GetDeferredResult builds the result, which may read the state of the
+// transaction, and returns a function encoding it that is safe to call once
+// the state moved on.
+func (t *brontesTracer) GetDeferredResult() (func() (json.RawMessage, error), error) {
+	if t.inspector == nil {
+		return nil, errBrontesNoTransaction
+	}
+	t.building.Store(true)
+	if t.config.PartialResults && t.reason != nil {
+		t.inspector.RecordError(brontes.TraceErrorStageExecution, t.reason, nil)
+	}
+	var txIndex int
+	if t.ctx != nil {
+		txIndex = t.ctx.TxIndex
+	}
+	var (
+		result any
+		err    error
+	)
+	switch t.config.OutputMode {
+	case brontes.OutputModeFlat:
+		result, err = t.inspector.IntoFlatTraceResults(t.receipt, txIndex)
+	case brontes.OutputModeArena:
+		result, err = t.inspector.IntoArenaTraceResults(t.receipt, txIndex)
+	default:
+		result, err = t.inspector.IntoTraceResults(t.tx, t.receipt, txIndex)
+	}
 	if err != nil {
+		t.inspector.Close()
 		return nil, err
 	}
-	return json.Marshal(result)
+	// Arena results stream the spilled steps while encoded, so the inspector
+	// is closed once the result is.
+	return func() (json.RawMessage, error) {
+		defer t.inspector.Close()
+		// Skip marshaling if the tracer was stopped while building the result.
+		if t.runCtx.Err() != nil {
+			return nil, context.Cause(t.runCtx)
+		}
+		var (
+			blob []byte
+			err  error
+		)
+		if trace, ok := result.(*brontes.TxTrace); ok {
+			blob, err = trace.MarshalSchema(t.config.SchemaVersion)
+		} else {
+			blob, err = json.Marshal(result)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if blob, err = t.config.NumberEncoding.Apply(blob); err != nil {
+			return nil, err
+		}
+		return t.config.Projection.Apply(blob)
+	}, nil
 }
 
 // Stop terminates execution of the tracer at the first opportune moment.
 func (t *brontesTracer) Stop(err error) {
 	t.reason = err
 	t.interrupt.Store(true)
+	// Partial results are still built out of the frames recorded before the
+	// execution was stopped.
+	if t.config.PartialResults && !t.building.Load() {
+		return
+	}
+	t.cancel(err)
 }
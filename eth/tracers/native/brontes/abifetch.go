@@ -0,0 +1,324 @@
+package brontes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultSourcifyURL is the Sourcify API queried if no URL is configured.
+	DefaultSourcifyURL = "https://sourcify.dev/server"
+
+	defaultABIFetchRate    = 2 // requests per second
+	defaultABIFetchTimeout = 10 * time.Second
+
+	// abiMissTTL is the time contracts without a verified ABI are not looked
+	// up again for, and abiErrorTTL that of failed lookups.
+	abiMissTTL  = time.Hour
+	abiErrorTTL = time.Minute
+	// abiMissLimit bounds the number of remembered misses.
+	abiMissLimit = 64 * 1024
+)
+
+// ABIFetcherConfig configures fetching verified ABIs over HTTP. Fetching is
+// disabled unless a fetcher is explicitly constructed, since it requires
+// outbound connections from the node.
+type ABIFetcherConfig struct {
+	SourcifyURL     string  `json:"sourcifyUrl"`     // Sourcify server, DefaultSourcifyURL if empty
+	EtherscanURL    string  `json:"etherscanUrl"`    // Etherscan compatible API, queried if Sourcify has no match
+	EtherscanAPIKey string  `json:"etherscanApiKey"` // API key sent to the Etherscan API
+	CacheDir        string  `json:"cacheDir"`        // Directory persisting fetched ABIs, no disk cache if empty
+	RateLimit       float64 `json:"rateLimit"`       // Maximum requests per second, across both services
+}
+
+// ABIFetcher is an ABIProvider retrieving verified ABIs from Sourcify and
+// Etherscan. Fetched ABIs are cached on disk, while contracts without a
+// verified ABI and failed lookups are remembered for a while.
+type ABIFetcher struct {
+	config  ABIFetcherConfig
+	chainId uint64
+	client  *http.Client
+	limiter *rate.Limiter
+
+	lock     sync.Mutex
+	abis     map[common.Address]*abi.ABI
+	missing  map[common.Address]time.Time     // expiry of the remembered misses
+	fetching map[common.Address]chan struct{} // closed once the lookup ends
+}
+
+// NewABIFetcher creates a fetcher for contracts on the given chain.
+func NewABIFetcher(config ABIFetcherConfig, chainId uint64) (*ABIFetcher, error) {
+	if config.SourcifyURL == "" {
+		config.SourcifyURL = DefaultSourcifyURL
+	}
+	if config.RateLimit <= 0 {
+		config.RateLimit = defaultABIFetchRate
+	}
+	if config.CacheDir != "" {
+		if err := os.MkdirAll(filepath.Join(config.CacheDir, fmt.Sprint(chainId)), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &ABIFetcher{
+		config:   config,
+		chainId:  chainId,
+		client:   &http.Client{Timeout: defaultABIFetchTimeout},
+		limiter:  rate.NewLimiter(rate.Limit(config.RateLimit), 1),
+		abis:     make(map[common.Address]*abi.ABI),
+		missing:  make(map[common.Address]time.Time),
+		fetching: make(map[common.Address]chan struct{}),
+	}, nil
+}
+
+// ABI implements ABIProvider. Concurrent lookups of a contract share a single
+// fetch, which does not hold up the lookups of the others.
+func (f *ABIFetcher) ABI(ctx context.Context, address common.Address) (*abi.ABI, error) {
+	for {
+		f.lock.Lock()
+		if contractABI, ok := f.abis[address]; ok {
+			f.lock.Unlock()
+			return contractABI, nil
+		}
+		if expiry, ok := f.missing[address]; ok {
+			if time.Now().Before(expiry) {
+				f.lock.Unlock()
+				return nil, nil
+			}
+			delete(f.missing, address)
+		}
+		if done, ok := f.fetching[address]; ok {
+			f.lock.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		f.fetching[address] = done
+		f.lock.Unlock()
+
+		return f.lookup(ctx, address, done)
+	}
+}
+
+// lookup reads the ABI of a contract from the disk cache or fetches it,
+// keeping the ABI found or remembering the miss.
+func (f *ABIFetcher) lookup(ctx context.Context, address common.Address, done chan struct{}) (*abi.ABI, error) {
+	var (
+		contractABI *abi.ABI
+		ttl         time.Duration
+	)
+	blob, err := f.readCache(address)
+	fetched := err != nil
+	if fetched {
+		blob, err = f.fetch(ctx, address)
+	}
+	switch {
+	case err != nil:
+		// Lookups given up by the caller are not the fault of the services.
+		if ctx.Err() == nil {
+			ttl = abiErrorTTL
+		}
+	case blob == nil:
+		ttl = abiMissTTL
+	default:
+		var parsed abi.ABI
+		if parsed, err = abi.JSON(bytes.NewReader(blob)); err != nil {
+			ttl = abiMissTTL
+			break
+		}
+		contractABI = &parsed
+		if fetched {
+			f.writeCache(address, blob)
+		}
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if contractABI != nil {
+		f.abis[address] = contractABI
+	} else if ttl > 0 {
+		f.remember(address, ttl)
+	}
+	delete(f.fetching, address)
+	close(done)
+	return contractABI, err
+}
+
+// remember records a miss, unless too many are remembered already. The lock
+// must be held.
+func (f *ABIFetcher) remember(address common.Address, ttl time.Duration) {
+	if len(f.missing) >= abiMissLimit {
+		now := time.Now()
+		for missed, expiry := range f.missing {
+			if now.After(expiry) {
+				delete(f.missing, missed)
+			}
+		}
+		if len(f.missing) >= abiMissLimit {
+			return
+		}
+	}
+	f.missing[address] = time.Now().Add(ttl)
+}
+
+func (f *ABIFetcher) cachePath(address common.Address) string {
+	return filepath.Join(f.config.CacheDir, fmt.Sprint(f.chainId), address.Hex()+".json")
+}
+
+func (f *ABIFetcher) readCache(address common.Address) ([]byte, error) {
+	if f.config.CacheDir == "" {
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(f.cachePath(address))
+}
+
+func (f *ABIFetcher) writeCache(address common.Address, blob []byte) {
+	if f.config.CacheDir == "" {
+		return
+	}
+	if err := os.WriteFile(f.cachePath(address), blob, 0644); err != nil {
+		log.Warn("Failed to cache fetched abi", "address", address, "err", err)
+	}
+}
+
+// fetch queries Sourcify and then Etherscan for the ABI of the contract. It
+// returns nil without an error if neither has a verified ABI.
+func (f *ABIFetcher) fetch(ctx context.Context, address common.Address) ([]byte, error) {
+	blob, err := f.fetchSourcify(ctx, address)
+	if err != nil || blob != nil || f.config.EtherscanURL == "" {
+		return blob, err
+	}
+	return f.fetchEtherscan(ctx, address)
+}
+
+func (f *ABIFetcher) fetchSourcify(ctx context.Context, address common.Address) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/v2/contract/%d/%s?fields=abi", f.config.SourcifyURL, f.chainId, address.Hex())
+	body, status, err := f.get(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("sourcify returned status %d", status)
+	}
+	var res struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	if len(res.ABI) == 0 || string(res.ABI) == "null" {
+		return nil, nil
+	}
+	return res.ABI, nil
+}
+
+func (f *ABIFetcher) fetchEtherscan(ctx context.Context, address common.Address) ([]byte, error) {
+	query := url.Values{
+		"chainid": {fmt.Sprint(f.chainId)},
+		"module":  {"contract"},
+		"action":  {"getabi"},
+		"address": {address.Hex()},
+	}
+	if f.config.EtherscanAPIKey != "" {
+		query.Set("apikey", f.config.EtherscanAPIKey)
+	}
+	body, status, err := f.get(ctx, f.config.EtherscanURL+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("etherscan returned status %d", status)
+	}
+	var res struct {
+		Status string `json:"status"`
+		Result string `json:"result"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	// Unverified contracts are reported with status "0".
+	if res.Status != "1" {
+		return nil, nil
+	}
+	return []byte(res.Result), nil
+}
+
+func (f *ABIFetcher) get(ctx context.Context, endpoint string) ([]byte, int, error) {
+	if err := f.limiter.Wait(ctx); err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	res, err := f.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 16*1024*1024))
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, res.StatusCode, nil
+}
+
+// ChainedABIProvider queries a list of providers in order, returning the
+// first ABI found.
+type ChainedABIProvider []ABIProvider
+
+// ABI implements ABIProvider.
+func (c ChainedABIProvider) ABI(ctx context.Context, address common.Address) (*abi.ABI, error) {
+	var errs []error
+	for _, provider := range c {
+		contractABI, err := provider.ABI(ctx, address)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if contractABI != nil {
+			return contractABI, nil
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+var (
+	abiFetcher     *ABIFetcher
+	abiFetcherLock sync.RWMutex
+)
+
+// SetABIFetcher installs the node-wide fetcher used by tracers that opt into
+// remote ABI lookups. Fetching stays disabled until this is called.
+func SetABIFetcher(fetcher *ABIFetcher) {
+	abiFetcherLock.Lock()
+	defer abiFetcherLock.Unlock()
+	abiFetcher = fetcher
+}
+
+// RegisteredABIFetcher returns the node-wide fetcher, or nil if none is set.
+func RegisteredABIFetcher() *ABIFetcher {
+	abiFetcherLock.RLock()
+	defer abiFetcherLock.RUnlock()
+	return abiFetcher
+}
@@ -0,0 +1,145 @@
+package brontes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestABIFetcher(t *testing.T) {
+	var (
+		verified   = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		etherscan  = common.HexToAddress("0x4444444444444444444444444444444444444444")
+		unverified = common.HexToAddress("0x5555555555555555555555555555555555555555")
+		requests   atomic.Int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch {
+		case r.URL.Path == "/v2/contract/1/"+verified.Hex():
+			fmt.Fprintf(w, `{"abi":%s}`, testABI)
+		case strings.HasPrefix(r.URL.Path, "/v2/contract/"):
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/api" && r.URL.Query().Get("address") == etherscan.Hex():
+			assert.Equal(t, "key", r.URL.Query().Get("apikey"))
+			fmt.Fprintf(w, `{"status":"1","message":"OK","result":%q}`, testABI)
+		default:
+			fmt.Fprint(w, `{"status":"0","message":"NOTOK","result":"Contract source code not verified"}`)
+		}
+	}))
+	defer server.Close()
+
+	config := ABIFetcherConfig{
+		SourcifyURL:     server.URL,
+		EtherscanURL:    server.URL + "/api",
+		EtherscanAPIKey: "key",
+		CacheDir:        t.TempDir(),
+		RateLimit:       1000,
+	}
+	fetcher, err := NewABIFetcher(config, 1)
+	require.NoError(t, err)
+
+	for _, address := range []common.Address{verified, etherscan} {
+		contractABI, err := fetcher.ABI(context.Background(), address)
+		require.NoError(t, err)
+		require.NotNil(t, contractABI)
+		assert.Contains(t, contractABI.Methods, "transfer")
+	}
+	contractABI, err := fetcher.ABI(context.Background(), unverified)
+	require.NoError(t, err)
+	assert.Nil(t, contractABI)
+
+	// Repeated lookups are served from memory.
+	count := requests.Load()
+	for _, address := range []common.Address{verified, etherscan, unverified} {
+		_, err := fetcher.ABI(context.Background(), address)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, count, requests.Load())
+
+	// A new fetcher reads the verified ABIs from disk.
+	fetcher, err = NewABIFetcher(config, 1)
+	require.NoError(t, err)
+	contractABI, err = fetcher.ABI(context.Background(), verified)
+	require.NoError(t, err)
+	require.NotNil(t, contractABI)
+	assert.Equal(t, count, requests.Load())
+}
+
+func TestABIFetcherSlowServer(t *testing.T) {
+	var (
+		slow     = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		release  = make(chan struct{})
+		requests atomic.Int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		fmt.Fprintf(w, `{"abi":%s}`, testABI)
+	}))
+	defer server.Close()
+
+	registry := NewABIRegistry()
+	fetcher, err := NewABIFetcher(ABIFetcherConfig{SourcifyURL: server.URL, RateLimit: 1000}, 1)
+	require.NoError(t, err)
+
+	lookup := make(chan error)
+	go func() {
+		_, err := fetcher.ABI(context.Background(), slow)
+		lookup <- err
+	}()
+	require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+
+	// Callers give up on the ongoing fetch with their context, without
+	// holding up the providers queried before the fetcher.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = ChainedABIProvider{registry, fetcher}.ABI(ctx, slow)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	require.NoError(t, <-lookup)
+	contractABI, err := fetcher.ABI(context.Background(), slow)
+	require.NoError(t, err)
+	require.NotNil(t, contractABI)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestABIFetcherFailures(t *testing.T) {
+	var (
+		address  = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		requests atomic.Int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	fetcher, err := NewABIFetcher(ABIFetcherConfig{SourcifyURL: server.URL, RateLimit: 1000}, 1)
+	require.NoError(t, err)
+	_, err = fetcher.ABI(context.Background(), address)
+	assert.Error(t, err)
+
+	// Failed lookups are not retried for a while.
+	contractABI, err := fetcher.ABI(context.Background(), address)
+	require.NoError(t, err)
+	assert.Nil(t, contractABI)
+	assert.Equal(t, int32(1), requests.Load())
+
+	fetcher.lock.Lock()
+	fetcher.missing[address] = time.Now().Add(-time.Second)
+	fetcher.lock.Unlock()
+	_, err = fetcher.ABI(context.Background(), address)
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
@@ -0,0 +1,139 @@
+package brontes
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Kinds of alerts raised on token approvals and transfers.
+const (
+	// AlertApprovalToNewContract is raised when tokens are pulled by a
+	// contract deployed in the same transaction that was approved to spend
+	// them, the signature of phishing contracts deployed on demand.
+	AlertApprovalToNewContract = "approval_to_new_contract"
+	// AlertTokenDrain is raised when a single spender pulls many distinct
+	// tokens from the same owner.
+	AlertTokenDrain = "token_drain"
+)
+
+// DefaultDrainTokens is the number of distinct tokens pulled from an owner
+// raising an AlertTokenDrain.
+const DefaultDrainTokens = 3
+
+// Selectors of the token functions recognized by the alerts.
+var (
+	approveSelector           = []byte{0x09, 0x5e, 0xa7, 0xb3} // approve(address,uint256)
+	increaseAllowanceSelector = []byte{0x39, 0x50, 0x93, 0x51} // increaseAllowance(address,uint256)
+	approvalForAllSelector    = []byte{0xa2, 0x2c, 0xb4, 0x65} // setApprovalForAll(address,bool)
+	transferFromSelector      = []byte{0x23, 0xb8, 0x72, 0xdd} // transferFrom(address,address,uint256)
+	safeTransferFromSelector  = []byte{0x42, 0x84, 0x2e, 0x0e} // safeTransferFrom(address,address,uint256)
+	safeTransferDataSelector  = []byte{0xb8, 0x8d, 0x4f, 0xde} // safeTransferFrom(address,address,uint256,bytes)
+)
+
+// Alert is a suspicious pattern of token approvals and transfers.
+type Alert struct {
+	Kind    string           `json:"kind"`
+	Owner   common.Address   `json:"owner"`
+	Spender common.Address   `json:"spender"`
+	Tokens  []common.Address `json:"tokens"`
+	// TraceIdx lists the frames matching the pattern, in execution order.
+	TraceIdx []uint64 `json:"trace_idx"`
+}
+
+// approvalKey identifies the allowance of a spender over the tokens of an
+// owner.
+type approvalKey struct {
+	token, owner, spender common.Address
+}
+
+// FindApprovalAlerts returns the alerts raised by the token approvals and
+// transfers of a trace, taking only frames that were not reverted into
+// account. Drains are reported once drainTokens distinct tokens are pulled
+// from an owner, DefaultDrainTokens if zero.
+func FindApprovalAlerts(value *TxTrace, drainTokens int) []Alert {
+	if drainTokens <= 0 {
+		drainTokens = DefaultDrainTokens
+	}
+	var (
+		reverted  = revertedFrames(value)
+		created   = make(map[common.Address]bool)
+		approvals = make(map[approvalKey]uint64)
+	)
+	for i := range value.Trace {
+		if frame := &value.Trace[i]; !reverted[i] && frame.IsCreate() && frame.Trace.Result != nil {
+			created[frame.GetCreateOutput()] = true
+		}
+	}
+	// Alerts are grouped per owner and spender, in order of first transfer.
+	type alertKey struct {
+		kind           string
+		owner, spender common.Address
+	}
+	var (
+		order  []alertKey
+		alerts = make(map[alertKey]*Alert)
+	)
+	raise := func(key alertKey, token common.Address, frames ...uint64) *Alert {
+		alert, ok := alerts[key]
+		if !ok {
+			alert = &Alert{Kind: key.kind, Owner: key.owner, Spender: key.spender}
+			alerts[key] = alert
+			order = append(order, key)
+		}
+		if !slices.Contains(alert.Tokens, token) {
+			alert.Tokens = append(alert.Tokens, token)
+		}
+		alert.TraceIdx = append(alert.TraceIdx, frames...)
+		return alert
+	}
+	for i := range value.Trace {
+		frame := &value.Trace[i]
+		if reverted[i] || frame.Trace.Type != ActionTypeCall || frame.IsDelegateCall() {
+			continue
+		}
+		call := frame.Trace.Action.Call
+		input := []byte(call.Input)
+		if len(input) < 4 {
+			continue
+		}
+		switch selector := input[:4]; {
+		case bytes.Equal(selector, approveSelector), bytes.Equal(selector, increaseAllowanceSelector), bytes.Equal(selector, approvalForAllSelector):
+			if spender, ok := addressArg(input, 0); ok {
+				key := approvalKey{token: call.To, owner: call.From, spender: spender}
+				if _, ok := approvals[key]; !ok {
+					approvals[key] = frame.TraceIdx
+				}
+			}
+		case bytes.Equal(selector, transferFromSelector), bytes.Equal(selector, safeTransferFromSelector), bytes.Equal(selector, safeTransferDataSelector):
+			owner, ok := addressArg(input, 0)
+			if !ok || owner == call.From {
+				continue
+			}
+			if approval, ok := approvals[approvalKey{token: call.To, owner: owner, spender: call.From}]; ok && created[call.From] {
+				raise(alertKey{AlertApprovalToNewContract, owner, call.From}, call.To, approval, frame.TraceIdx)
+			}
+			raise(alertKey{AlertTokenDrain, owner, call.From}, call.To, frame.TraceIdx)
+		}
+	}
+	var result []Alert
+	for _, key := range order {
+		alert := alerts[key]
+		if alert.Kind == AlertTokenDrain && len(alert.Tokens) < drainTokens {
+			continue
+		}
+		result = append(result, *alert)
+	}
+	return result
+}
+
+// addressArg decodes the nth ABI-encoded argument of call data as an
+// address.
+func addressArg(input []byte, n int) (common.Address, bool) {
+	start := 4 + 32*n
+	if len(input) < start+32 {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(input[start+12 : start+32]), true
+}
@@ -0,0 +1,57 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// tokenCall creates a call frame invoking a token function with address
+// arguments.
+func tokenCall(idx uint64, from, token common.Address, selector []byte, args ...common.Address) TransactionTraceWithLogs {
+	frame := callFrame(idx, CallKindCall, from, token, 0, uint(idx))
+	input := append([]byte{}, selector...)
+	for _, arg := range args {
+		input = append(input, common.LeftPadBytes(arg.Bytes(), 32)...)
+	}
+	frame.Trace.Action.Call.Input = append(input, make([]byte, 32)...)
+	return frame
+}
+
+func TestFindApprovalAlerts(t *testing.T) {
+	var (
+		victim  = common.HexToAddress("0x01")
+		drainer = common.HexToAddress("0x02")
+		router  = common.HexToAddress("0x03")
+		tokens  = []common.Address{common.HexToAddress("0xa1"), common.HexToAddress("0xa2"), common.HexToAddress("0xa3")}
+		failed  = "execution reverted"
+	)
+	create := TransactionTraceWithLogs{
+		Trace: TransactionTrace{
+			Type:         ActionTypeCreate,
+			Action:       &Action{Type: ActionTypeCreate, Create: &CreateAction{From: victim}},
+			Result:       &TraceOutput{Type: TraceOutputTypeCreate, Create: &CreateOutput{Address: drainer}},
+			TraceAddress: []uint{0, 0},
+		},
+		TraceIdx: 1,
+	}
+	trace := &TxTrace{Trace: []TransactionTraceWithLogs{
+		callFrame(0, CallKindCall, victim, router),
+		create,
+		tokenCall(2, victim, tokens[0], approveSelector, drainer),
+		tokenCall(3, drainer, tokens[0], transferFromSelector, victim, drainer),
+		tokenCall(4, router, tokens[1], transferFromSelector, victim, router),
+		tokenCall(5, router, tokens[2], transferFromSelector, victim, router),
+		tokenCall(6, router, tokens[0], transferFromSelector, victim, router),
+		// Reverted transfers are not taken into account.
+		tokenCall(7, drainer, tokens[1], transferFromSelector, victim, drainer),
+	}}
+	trace.Trace[7].Trace.Error = &failed
+
+	assert.Equal(t, []Alert{
+		{Kind: AlertApprovalToNewContract, Owner: victim, Spender: drainer, Tokens: tokens[:1], TraceIdx: []uint64{2, 3}},
+		{Kind: AlertTokenDrain, Owner: victim, Spender: router, Tokens: []common.Address{tokens[1], tokens[2], tokens[0]}, TraceIdx: []uint64{4, 5, 6}},
+	}, FindApprovalAlerts(trace, 0))
+	assert.Len(t, FindApprovalAlerts(trace, 4), 1)
+}
@@ -0,0 +1,79 @@
+package brontes
+
+import "sync"
+
+// Annotation is a note attached to the trace of a transaction by an external
+// system, such as the classification of an incident it is part of.
+type Annotation struct {
+	Labels         []string `json:"labels,omitempty"`
+	IncidentId     string   `json:"incident_id,omitempty"`
+	Classification string   `json:"classification,omitempty"`
+	Note           string   `json:"note,omitempty"`
+	// Source names the system that attached the annotation.
+	Source string `json:"source,omitempty"`
+	// CreatedAt is the unix time the annotation was stored at.
+	CreatedAt uint64 `json:"created_at"`
+}
+
+// ClickhouseAnnotations represents the annotations of a transaction for
+// ClickHouse, one row per annotation.
+type ClickhouseAnnotations struct {
+	ChainId []uint64
+	ClickhouseTxPosition
+	Labels         [][]string
+	IncidentId     []string
+	Classification []string
+	Note           []string
+	Source         []string
+	CreatedAt      []uint64
+}
+
+// NewClickhouseAnnotations creates a ClickhouseAnnotations from the
+// annotations of the transaction of a TxTrace.
+func NewClickhouseAnnotations(value *TxTrace, annotations []Annotation) *ClickhouseAnnotations {
+	result := &ClickhouseAnnotations{}
+	for _, annotation := range annotations {
+		labels := annotation.Labels
+		if labels == nil {
+			labels = []string{}
+		}
+		result.ChainId = append(result.ChainId, value.ChainId)
+		result.appendTx(value)
+		result.Labels = append(result.Labels, labels)
+		result.IncidentId = append(result.IncidentId, annotation.IncidentId)
+		result.Classification = append(result.Classification, annotation.Classification)
+		result.Note = append(result.Note, annotation.Note)
+		result.Source = append(result.Source, annotation.Source)
+		result.CreatedAt = append(result.CreatedAt, annotation.CreatedAt)
+	}
+	return result
+}
+
+// AnnotationSink writes the annotations attached through the API next to the
+// trace tables exported by the live tracer.
+type AnnotationSink interface {
+	// WriteAnnotations writes the annotations of the transaction of a trace,
+	// of which only the chain and position are set.
+	WriteAnnotations(trace *TxTrace, annotations []Annotation) error
+}
+
+var (
+	annotationSink     AnnotationSink
+	annotationSinkLock sync.RWMutex
+)
+
+// SetAnnotationSink installs the node-wide sink annotations are written to
+// besides the node database.
+func SetAnnotationSink(sink AnnotationSink) {
+	annotationSinkLock.Lock()
+	defer annotationSinkLock.Unlock()
+	annotationSink = sink
+}
+
+// RegisteredAnnotationSink returns the node-wide annotation sink, or nil if
+// none is set.
+func RegisteredAnnotationSink() AnnotationSink {
+	annotationSinkLock.RLock()
+	defer annotationSinkLock.RUnlock()
+	return annotationSink
+}
@@ -0,0 +1,243 @@
+// Package api exposes brontes traces to Go programs embedding geth as a
+// library, such as indexers. Traces are read through accessors instead of
+// the structures the tracer builds, so consumers keep compiling while the
+// internal representation evolves, and values handed out are copies that do
+// not alias the trace.
+package api
+
+import (
+	"encoding/json"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+)
+
+// Frame kinds reported by Frame.Kind.
+const (
+	KindCall            = string(brontes.CallKindCall)
+	KindStaticCall      = string(brontes.CallKindStaticCall)
+	KindCallCode        = string(brontes.CallKindCallCode)
+	KindDelegateCall    = string(brontes.CallKindDelegateCall)
+	KindExtCall         = string(brontes.CallKindExtCall)
+	KindExtStaticCall   = string(brontes.CallKindExtStaticCall)
+	KindExtDelegateCall = string(brontes.CallKindExtDelegateCall)
+	KindCreate          = string(brontes.CallKindCreate)
+	KindSelfDestruct    = string(brontes.CallKindSelfDestruct)
+	KindReward          = "reward"
+)
+
+// Trace is the brontes trace of a transaction. It is immutable.
+type Trace struct {
+	trace  *brontes.TxTrace
+	frames []Frame
+}
+
+// Decode parses a trace as returned by the brontes tracer in any schema
+// version.
+func Decode(data []byte) (*Trace, error) {
+	trace := new(brontes.TxTrace)
+	if err := json.Unmarshal(data, trace); err != nil {
+		return nil, err
+	}
+	return Wrap(trace), nil
+}
+
+// Wrap creates a trace from the result of BrontesInspector.IntoTraceResults.
+// The result must not be modified afterwards.
+func Wrap(trace *brontes.TxTrace) *Trace {
+	t := &Trace{trace: trace, frames: make([]Frame, len(trace.Trace))}
+	for i := range trace.Trace {
+		t.frames[i] = Frame{frame: &trace.Trace[i]}
+	}
+	return t
+}
+
+// ChainID returns the id of the chain of the transaction.
+func (t *Trace) ChainID() uint64 { return t.trace.ChainId }
+
+// BlockNumber returns the number of the block of the transaction.
+func (t *Trace) BlockNumber() uint64 { return t.trace.BlockNumber }
+
+// TxHash returns the hash of the transaction.
+func (t *Trace) TxHash() common.Hash { return t.trace.TxHash }
+
+// TxIndex returns the position of the transaction in its block.
+func (t *Trace) TxIndex() int { return t.trace.TxIndex }
+
+// Success reports whether the transaction succeeded.
+func (t *Trace) Success() bool { return t.trace.IsSuccess }
+
+// GasUsed returns the gas used by the transaction.
+func (t *Trace) GasUsed() uint64 {
+	if t.trace.GasUsed == nil {
+		return 0
+	}
+	return t.trace.GasUsed.Uint64()
+}
+
+// Len returns the number of frames of the transaction.
+func (t *Trace) Len() int { return len(t.frames) }
+
+// Frame returns the i-th frame in execution order.
+func (t *Trace) Frame(i int) Frame { return t.frames[i] }
+
+// Frames returns the frames in execution order.
+func (t *Trace) Frames() []Frame { return slices.Clone(t.frames) }
+
+// Logs returns the logs emitted by all frames in execution order, including
+// the logs of frames that were reverted.
+func (t *Trace) Logs() []types.Log {
+	var logs []types.Log
+	for _, frame := range t.frames {
+		logs = append(logs, frame.Logs()...)
+	}
+	return logs
+}
+
+// Frame is a call, creation or selfdestruct of a transaction.
+type Frame struct {
+	frame *brontes.TransactionTraceWithLogs
+}
+
+// Index returns the index of the frame, unique within the transaction.
+func (f Frame) Index() uint64 { return f.frame.TraceIdx }
+
+// TraceAddress returns the position of the frame in the call tree, as the
+// indices of the subcalls leading to it from the top frame.
+func (f Frame) TraceAddress() []uint { return slices.Clone(f.frame.Trace.TraceAddress) }
+
+// Depth returns the depth of the frame, zero for the top frame.
+func (f Frame) Depth() int { return len(f.frame.Trace.TraceAddress) }
+
+// Kind returns the kind of the frame, one of the Kind constants.
+func (f Frame) Kind() string {
+	action := f.frame.Trace.Action
+	if action == nil {
+		return ""
+	}
+	switch action.Type {
+	case brontes.ActionTypeCall:
+		return action.Call.CallType.String()
+	case brontes.ActionTypeCreate:
+		return KindCreate
+	case brontes.ActionTypeSelfDestruct:
+		return KindSelfDestruct
+	case brontes.ActionTypeReward:
+		return KindReward
+	}
+	return ""
+}
+
+// From returns the caller of the frame.
+func (f Frame) From() common.Address { return f.frame.GetFromAddr() }
+
+// To returns the account called by the frame, the created contract for
+// successful creations, or the beneficiary of a selfdestruct.
+func (f Frame) To() common.Address {
+	if f.frame.IsCreate() {
+		if result := f.frame.Trace.Result; result != nil && result.Create != nil {
+			return result.Create.Address
+		}
+		return common.Address{}
+	}
+	return f.frame.GetToAddr()
+}
+
+// Value returns the value transferred by the frame.
+func (f Frame) Value() *big.Int {
+	var value *big.Int
+	if action := f.frame.Trace.Action; action != nil {
+		switch action.Type {
+		case brontes.ActionTypeCall:
+			value = action.Call.Value
+		case brontes.ActionTypeCreate:
+			value = action.Create.Value
+		case brontes.ActionTypeSelfDestruct:
+			value = action.SelfDestruct.Balance
+		case brontes.ActionTypeReward:
+			value = action.Reward.Value
+		}
+	}
+	if value == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(value)
+}
+
+// Input returns the call data of calls or the init code of creations.
+func (f Frame) Input() []byte { return common.CopyBytes(f.frame.GetCallData()) }
+
+// Output returns the return data of calls or the code deployed by
+// creations.
+func (f Frame) Output() []byte {
+	result := f.frame.Trace.Result
+	switch {
+	case result == nil:
+		return nil
+	case result.Call != nil:
+		return common.CopyBytes(result.Call.Output)
+	case result.Create != nil:
+		return common.CopyBytes(result.Create.Code)
+	}
+	return nil
+}
+
+// GasUsed returns the gas used by the frame.
+func (f Frame) GasUsed() uint64 {
+	result := f.frame.Trace.Result
+	switch {
+	case result == nil:
+		return 0
+	case result.Call != nil:
+		return result.Call.GasUsed
+	case result.Create != nil:
+		return result.Create.GasUsed
+	}
+	return 0
+}
+
+// Failed reports whether the frame failed or reverted.
+func (f Frame) Failed() bool { return f.frame.Trace.Error != nil }
+
+// Error returns the reason the frame failed, empty if it succeeded.
+func (f Frame) Error() string {
+	if f.frame.Trace.Error == nil {
+		return ""
+	}
+	return *f.frame.Trace.Error
+}
+
+// Logs returns the logs emitted by the frame.
+func (f Frame) Logs() []types.Log {
+	logs := make([]types.Log, len(f.frame.Logs))
+	for i, log := range f.frame.Logs {
+		logs[i] = types.Log{
+			Address: log.Address,
+			Topics:  slices.Clone(log.Topics),
+			Data:    common.CopyBytes(log.Data),
+		}
+	}
+	return logs
+}
+
+// FunctionName returns the name of the function called by the frame, empty
+// unless the call data was decoded.
+func (f Frame) FunctionName() string {
+	if f.frame.DecodedData == nil {
+		return ""
+	}
+	return f.frame.DecodedData.FunctionName
+}
+
+// DecodeProvenance returns the source the call data of the frame was decoded
+// from and the confidence, between 0 and 1, in the decoding. It returns an
+// empty provenance unless the call data was decoded.
+func (f Frame) DecodeProvenance() (provenance string, confidence float64) {
+	if f.frame.DecodedData == nil {
+		return "", 0
+	}
+	return string(f.frame.DecodedData.Provenance), f.frame.DecodedData.Confidence
+}
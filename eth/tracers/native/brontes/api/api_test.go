@@ -0,0 +1,111 @@
+package api
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTrace() *brontes.TxTrace {
+	var (
+		sender   = common.HexToAddress("0x11")
+		contract = common.HexToAddress("0x22")
+		created  = common.HexToAddress("0x33")
+		reverted = "execution reverted"
+	)
+	return &brontes.TxTrace{
+		ChainId:     1,
+		BlockNumber: 100,
+		TxHash:      common.HexToHash("0xaa"),
+		TxIndex:     2,
+		GasUsed:     big.NewInt(50000),
+		IsSuccess:   true,
+		Trace: []brontes.TransactionTraceWithLogs{
+			{
+				Trace: brontes.TransactionTrace{
+					Type: brontes.ActionTypeCall,
+					Action: &brontes.Action{Type: brontes.ActionTypeCall, Call: &brontes.CallAction{
+						From: sender, To: contract, CallType: brontes.CallKindCall, Value: big.NewInt(5), Input: []byte{1, 2, 3, 4},
+					}},
+					Result:       &brontes.TraceOutput{Type: brontes.TraceOutputTypeCall, Call: &brontes.CallOutput{GasUsed: 30000, Output: []byte{9}}},
+					TraceAddress: []uint{},
+					Subtraces:    1,
+				},
+				Logs:        []types.Log{{Address: contract, Topics: []common.Hash{{1}}, Data: []byte{7}}},
+				DecodedData: &brontes.DecodedCallData{FunctionName: "deploy"},
+			},
+			{
+				Trace: brontes.TransactionTrace{
+					Type: brontes.ActionTypeCreate,
+					Action: &brontes.Action{Type: brontes.ActionTypeCreate, Create: &brontes.CreateAction{
+						From: contract, Value: big.NewInt(0), Init: []byte{0x60},
+					}},
+					Result:       &brontes.TraceOutput{Type: brontes.TraceOutputTypeCreate, Create: &brontes.CreateOutput{GasUsed: 100, Address: created}},
+					Error:        &reverted,
+					TraceAddress: []uint{0},
+				},
+				TraceIdx: 1,
+			},
+		},
+	}
+}
+
+func TestTrace(t *testing.T) {
+	trace := Wrap(newTestTrace())
+	assert.Equal(t, uint64(1), trace.ChainID())
+	assert.Equal(t, uint64(100), trace.BlockNumber())
+	assert.Equal(t, common.HexToHash("0xaa"), trace.TxHash())
+	assert.Equal(t, 2, trace.TxIndex())
+	assert.True(t, trace.Success())
+	assert.Equal(t, uint64(50000), trace.GasUsed())
+	require.Equal(t, 2, trace.Len())
+	assert.Len(t, trace.Logs(), 1)
+
+	call, create := trace.Frame(0), trace.Frame(1)
+	assert.Equal(t, KindCall, call.Kind())
+	assert.Equal(t, 0, call.Depth())
+	assert.Equal(t, common.HexToAddress("0x11"), call.From())
+	assert.Equal(t, common.HexToAddress("0x22"), call.To())
+	assert.Equal(t, big.NewInt(5), call.Value())
+	assert.Equal(t, []byte{1, 2, 3, 4}, call.Input())
+	assert.Equal(t, []byte{9}, call.Output())
+	assert.Equal(t, uint64(30000), call.GasUsed())
+	assert.False(t, call.Failed())
+	assert.Equal(t, "deploy", call.FunctionName())
+
+	assert.Equal(t, KindCreate, create.Kind())
+	assert.Equal(t, uint64(1), create.Index())
+	assert.Equal(t, []uint{0}, create.TraceAddress())
+	assert.Equal(t, common.HexToAddress("0x33"), create.To())
+	assert.True(t, create.Failed())
+	assert.Equal(t, "execution reverted", create.Error())
+	assert.Empty(t, create.FunctionName())
+
+	// Values handed out do not alias the trace.
+	call.Input()[0] = 0xff
+	call.Value().SetUint64(0)
+	call.Logs()[0].Data[0] = 0
+	assert.Equal(t, []byte{1, 2, 3, 4}, call.Input())
+	assert.Equal(t, big.NewInt(5), call.Value())
+	assert.Equal(t, []byte{7}, call.Logs()[0].Data)
+}
+
+func TestDecode(t *testing.T) {
+	for _, version := range []int{brontes.SchemaVersionV1, brontes.SchemaVersionV2} {
+		blob, err := newTestTrace().MarshalSchema(version)
+		require.NoError(t, err)
+		trace, err := Decode(blob)
+		require.NoError(t, err, "version %d", version)
+		assert.Equal(t, common.HexToHash("0xaa"), trace.TxHash())
+		require.Equal(t, 2, trace.Len())
+		assert.Equal(t, KindCreate, trace.Frame(1).Kind())
+		assert.Equal(t, []byte{1, 2, 3, 4}, trace.Frame(0).Input())
+	}
+	_, err := Decode([]byte(`{"trace": 1}`))
+	assert.Error(t, err)
+}
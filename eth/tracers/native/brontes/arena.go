@@ -12,8 +12,10 @@ func NewCallTraceArena() *CallTraceArena {
 	}
 }
 
-// PushTrace pushes a new trace into the arena, returning the trace ID.
-// It will attach the trace to its parent if kind.IsAttachToParent() returns true.
+// PushTrace pushes a new trace into the arena, returning the trace ID. The
+// trace becomes a child of entry if entry is one level above it, otherwise
+// the parent is searched along the last children of entry. It will attach the
+// trace to its parent if kind.IsAttachToParent() returns true.
 func (cta *CallTraceArena) PushTrace(entry int, kind PushTraceKind, newTrace CallTrace) int {
 	log.Trace("Pushing trace", "newTrace", newTrace)
 	for {
@@ -0,0 +1,196 @@
+package brontes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ArenaTxTrace is the result of the arena output mode, the lossless form of
+// the recorded call tree: the frames in order of entry with the indices of
+// their parents and children, and the interleaving of their logs and calls.
+type ArenaTxTrace struct {
+	ChainId     uint64      `json:"chain_id"`
+	BlockNumber uint64      `json:"block_number"`
+	TxHash      common.Hash `json:"tx_hash"`
+	TxIndex     int         `json:"tx_index"`
+	IsSuccess   bool        `json:"is_success"`
+	Nodes       []ArenaNode `json:"nodes"`
+}
+
+// ArenaNode is a CallTraceNode of the arena output mode.
+type ArenaNode struct {
+	Idx      int             `json:"idx"`    // trace_idx of the frame in the other outputs
+	Parent   *int            `json:"parent"` // nil for the root frame
+	Children []int           `json:"children"`
+	Trace    ArenaCallTrace  `json:"trace"`
+	Logs     []ArenaLog      `json:"logs"`
+	Ordering []ArenaOrdering `json:"ordering"`
+}
+
+// ArenaCallTrace is a CallTrace of the arena output mode.
+type ArenaCallTrace struct {
+	Depth                    int             `json:"depth"`
+	Success                  bool            `json:"success"`
+	Caller                   common.Address  `json:"caller"`
+	Address                  common.Address  `json:"address"`
+	CodeAddress              common.Address  `json:"code_address"`
+	ContextAddress           common.Address  `json:"context_address"`
+	MaybePrecompile          *bool           `json:"maybe_precompile,omitempty"`
+	SelfDestructRefundTarget *common.Address `json:"selfdestruct_refund_target,omitempty"`
+	SelfDestructCodeRemoved  bool            `json:"selfdestruct_code_removed,omitempty"`
+	Kind                     CallKind        `json:"kind"`
+	Value                    *hexutil.Big    `json:"value"`
+	Data                     hexutil.Bytes   `json:"data"`
+	Output                   hexutil.Bytes   `json:"output"`
+	GasUsed                  uint64          `json:"gas_used"`
+	GasLimit                 uint64          `json:"gas_limit"`
+	Reverted                 bool            `json:"reverted"`
+	Error                    string          `json:"error,omitempty"`
+	Steps                    *ArenaSteps     `json:"steps,omitempty"`
+	SkippedSteps             int             `json:"skipped_steps,omitempty"`
+	CallerStep               *int            `json:"caller_step,omitempty"`
+	Summary                  *FrameSummary   `json:"summary,omitempty"`
+	StorageAccess            *StorageAccess  `json:"storage_access,omitempty"`
+}
+
+// ArenaSteps are the recorded steps of a frame of the arena output mode. They
+// are encoded one at a time, streaming the steps spilled to disk from the
+// spill file instead of reading them all back into memory, so the inspector
+// must not be closed before the result is encoded.
+type ArenaSteps struct {
+	inspector *BrontesInspector
+	node      *CallTraceNode
+}
+
+// Len returns the number of steps.
+func (s *ArenaSteps) Len() int {
+	return s.node.Trace.StepCount()
+}
+
+// Iter calls fn with every step in execution order.
+func (s *ArenaSteps) Iter(fn func(*CallTraceStep) error) error {
+	return s.inspector.IterSteps(s.node, fn)
+}
+
+// MarshalJSON encodes the steps as a JSON array.
+func (s *ArenaSteps) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	err := s.Iter(func(step *CallTraceStep) error {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		blob, err := json.Marshal(step)
+		if err != nil {
+			return err
+		}
+		buf.Write(blob)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// ArenaLog is a log of the arena output mode.
+type ArenaLog struct {
+	Topics []common.Hash `json:"topics"`
+	Data   hexutil.Bytes `json:"data"`
+}
+
+// ArenaOrdering is a LogCallOrder of the arena output mode, Index being the
+// index of a log of the node for "log" entries and the position of a child
+// node in its children for "call" entries.
+type ArenaOrdering struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+}
+
+// IntoArenaTraceResults returns the recorded call tree as is, without the
+// conversion into parity style actions.
+func (b *BrontesInspector) IntoArenaTraceResults(receipt *types.Receipt, txIndex int) (*ArenaTxTrace, error) {
+	if len(b.Traces.Nodes()) == 0 {
+		return nil, errors.New("no traces found")
+	}
+	nodes := b.Traces.Nodes()
+	result := &ArenaTxTrace{
+		ChainId:     b.ChainId,
+		BlockNumber: b.VMContext.BlockNumber.Uint64(),
+		TxHash:      b.Transaction.Hash(),
+		TxIndex:     txIndex,
+		IsSuccess:   receipt.Status == types.ReceiptStatusSuccessful,
+		Nodes:       make([]ArenaNode, 0, len(nodes)),
+	}
+	for i := range nodes {
+		if err := b.interrupted(); err != nil {
+			return nil, err
+		}
+		node, err := b.arenaNode(&nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		result.Nodes = append(result.Nodes, node)
+	}
+	return result, nil
+}
+
+// arenaNode converts a node of the arena.
+func (b *BrontesInspector) arenaNode(node *CallTraceNode) (ArenaNode, error) {
+	trace := &node.Trace
+	out := ArenaNode{
+		Idx:      node.Idx,
+		Parent:   node.Parent,
+		Children: node.Children,
+		Trace: ArenaCallTrace{
+			Depth:                    trace.Depth,
+			Success:                  trace.Success,
+			Caller:                   trace.Caller,
+			Address:                  trace.Address,
+			CodeAddress:              trace.CodeAddress,
+			ContextAddress:           trace.ContextAddress,
+			MaybePrecompile:          trace.MaybePrecompile,
+			SelfDestructRefundTarget: trace.SelfDestructRefundTarget,
+			SelfDestructCodeRemoved:  trace.SelfDestructCodeRemoved,
+			Kind:                     trace.Kind,
+			Value:                    (*hexutil.Big)(trace.Value),
+			Data:                     trace.Data,
+			Output:                   trace.Output,
+			GasUsed:                  trace.GasUsed,
+			GasLimit:                 trace.GasLimit,
+			Reverted:                 trace.Reverted,
+			SkippedSteps:             trace.SkippedSteps,
+			CallerStep:               trace.CallerStep,
+			Summary:                  trace.Summary,
+			StorageAccess:            trace.StorageAccess,
+		},
+		Logs:     make([]ArenaLog, 0, len(node.Logs)),
+		Ordering: make([]ArenaOrdering, 0, len(node.Ordering)),
+	}
+	if out.Children == nil {
+		out.Children = []int{}
+	}
+	if trace.Error != nil {
+		out.Trace.Error = trace.Error.Error()
+	}
+	if trace.StepCount() > 0 {
+		// Steps lost to a failed spill fail the result before it is encoded.
+		if b.spillErr != nil {
+			return ArenaNode{}, b.spillErr
+		}
+		out.Trace.Steps = &ArenaSteps{inspector: b, node: node}
+	}
+	for _, log := range node.Logs {
+		out.Logs = append(out.Logs, ArenaLog{Topics: log.Topics, Data: log.Data})
+	}
+	for _, order := range node.Ordering {
+		out.Ordering = append(out.Ordering, ArenaOrdering{Type: order.Type.String(), Index: order.Index})
+	}
+	return out, nil
+}
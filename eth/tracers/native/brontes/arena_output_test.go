@@ -0,0 +1,51 @@
+package brontes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArenaTraceResults(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	// The caller calls the callee, which pushes and pops a word.
+	code := append([]byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH20),
+	}, callee.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.STOP))
+	calleeCode := []byte{byte(vm.PUSH1), 0, byte(vm.POP), byte(vm.STOP)}
+
+	config := DefaultTracingInspectorConfig
+	config.RecordSteps = true
+	inspector, _, receipt := executeInspected(t, config, code, callee, calleeCode)
+	result, err := inspector.IntoArenaTraceResults(receipt, 0)
+	require.NoError(t, err)
+
+	require.Len(t, result.Nodes, 2)
+	root, child := result.Nodes[0], result.Nodes[1]
+	assert.Nil(t, root.Parent)
+	assert.Equal(t, []int{1}, root.Children)
+	require.NotNil(t, child.Parent)
+	assert.Equal(t, 0, *child.Parent)
+	assert.Equal(t, callee, child.Trace.Address)
+	require.NotNil(t, child.Trace.Steps)
+	require.Equal(t, 3, child.Trace.Steps.Len())
+	var ops []vm.OpCode
+	require.NoError(t, child.Trace.Steps.Iter(func(step *CallTraceStep) error {
+		ops = append(ops, step.Op)
+		return nil
+	}))
+	assert.Equal(t, []vm.OpCode{vm.PUSH1, vm.POP, vm.STOP}, ops)
+	assert.Empty(t, child.Logs)
+
+	blob, err := json.Marshal(result)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(blob, &decoded))
+	assert.Len(t, decoded["nodes"], 2)
+}
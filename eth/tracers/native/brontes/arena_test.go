@@ -0,0 +1,108 @@
+package brontes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushTraceParent(t *testing.T) {
+	arena := NewCallTraceArena()
+	arena.PushTrace(0, PushTraceKindPushAndAttachToParent, CallTrace{Depth: 0})
+	a := arena.PushTrace(0, PushTraceKindPushAndAttachToParent, CallTrace{Depth: 1})
+	b := arena.PushTrace(0, PushTraceKindPushAndAttachToParent, CallTrace{Depth: 1})
+
+	// A child pushed with its parent given attaches to it, even if the
+	// parent is not the last child of its own parent.
+	child := arena.PushTrace(a, PushTraceKindPushAndAttachToParent, CallTrace{Depth: 2})
+	require.NotNil(t, arena.Arena[child].Parent)
+	assert.Equal(t, a, *arena.Arena[child].Parent)
+	assert.Equal(t, []int{child}, arena.Arena[a].Children)
+	assert.Empty(t, arena.Arena[b].Children)
+
+	// Without it, the parent is searched along the last children.
+	child = arena.PushTrace(0, PushTraceKindPushAndAttachToParent, CallTrace{Depth: 2})
+	assert.Equal(t, b, *arena.Arena[child].Parent)
+}
+
+func TestNestedTraceTree(t *testing.T) {
+	var (
+		from    = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to      = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		factory = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		created = common.HexToAddress("0x4444444444444444444444444444444444444444")
+		token   = common.HexToAddress("0x5555555555555555555555555555555555555555")
+		sha256  = common.BytesToAddress([]byte{2})
+		env     = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1}
+		tx      = types.NewTx(&types.LegacyTx{To: &to, Gas: 1000000})
+	)
+	inspector := NewBrontesInspector(context.Background(), DefaultTracingInspectorConfig, params.MainnetChainConfig, env, tx, from)
+	enter := func(depth int, op vm.OpCode, from, to common.Address) {
+		require.NoError(t, inspector.OnEnter(depth, byte(op), from, to, nil, 100000, big.NewInt(0)))
+	}
+	exit := func(depth int) { inspector.OnExit(depth, nil, 1000, nil, false) }
+
+	// root
+	// ├─ factory: create
+	// │  ├─ created: call into the token from the constructor
+	// │  │  └─ sha256 precompile, left out
+	// │  └─ token: call
+	// └─ created: call
+	//    ├─ token: delegatecall
+	//    │  └─ factory: create2
+	//    └─ token: staticcall
+	enter(0, vm.CALL, from, to)
+	enter(1, vm.CALL, to, factory)
+	enter(2, vm.CREATE, factory, created)
+	enter(3, vm.CALL, created, token)
+	enter(4, vm.STATICCALL, token, sha256)
+	exit(4)
+	exit(3)
+	exit(2)
+	enter(2, vm.CALL, factory, token)
+	exit(2)
+	exit(1)
+	enter(1, vm.CALL, to, created)
+	enter(2, vm.DELEGATECALL, created, token)
+	enter(3, vm.CREATE2, created, factory)
+	exit(3)
+	exit(2)
+	enter(2, vm.STATICCALL, created, token)
+	exit(2)
+	exit(1)
+	exit(0)
+
+	nodes := inspector.Traces.Nodes()
+	require.Len(t, nodes, 10)
+	parents := []int{-1, 0, 1, 2, 3, 1, 0, 6, 7, 6}
+	children := [][]int{{1, 6}, {2, 5}, {3}, nil, nil, nil, {7, 9}, {8}, nil, nil}
+	for i, node := range nodes {
+		if parents[i] < 0 {
+			assert.Nil(t, node.Parent, "node %d", i)
+		} else if assert.NotNil(t, node.Parent, "node %d", i) {
+			assert.Equal(t, parents[i], *node.Parent, "node %d", i)
+		}
+		assert.Equal(t, children[i], node.Children, "node %d", i)
+	}
+	// The precompile is not a child of its caller.
+	assert.Empty(t, nodes[3].Children)
+
+	result, err := inspector.IntoTraceResults(tx, &types.Receipt{Status: types.ReceiptStatusSuccessful}, 0)
+	require.NoError(t, err)
+	addresses := make(map[uint64][]uint)
+	for _, frame := range result.Trace {
+		addresses[frame.TraceIdx] = frame.Trace.TraceAddress
+	}
+	assert.Equal(t, []uint{0, 0, 0}, addresses[3])
+	assert.Equal(t, []uint{0, 1}, addresses[5])
+	assert.Equal(t, []uint{1, 0, 0}, addresses[8])
+	assert.Equal(t, []uint{1, 1}, addresses[9])
+}
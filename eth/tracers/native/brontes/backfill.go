@@ -0,0 +1,33 @@
+package brontes
+
+import "sync"
+
+// BackfillQueue queues ranges of blocks to be traced in the background, such
+// as the blocks processed while the live tracer was not running.
+type BackfillQueue interface {
+	// QueueBackfill queues the inclusive range of blocks, unless a queued
+	// range covers it already.
+	QueueBackfill(from, to uint64) error
+}
+
+var (
+	backfillQueue     BackfillQueue
+	backfillQueueLock sync.RWMutex
+)
+
+// SetBackfillQueue installs the node-wide backfill queue the live tracer
+// hands the blocks missing from its export to. Missing blocks are only
+// reported until this is called.
+func SetBackfillQueue(q BackfillQueue) {
+	backfillQueueLock.Lock()
+	defer backfillQueueLock.Unlock()
+	backfillQueue = q
+}
+
+// RegisteredBackfillQueue returns the node-wide backfill queue, or nil if
+// none is set.
+func RegisteredBackfillQueue() BackfillQueue {
+	backfillQueueLock.RLock()
+	defer backfillQueueLock.RUnlock()
+	return backfillQueue
+}
@@ -2,14 +2,49 @@ package brontes
 
 import (
 	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
+// ClickhouseTxPosition holds the columns locating the rows of a table in the
+// chain, so every table can be queried, and the rows of reorged blocks
+// deleted, without joining other tables. The block and parent hashes are
+// empty if unknown, as for simulated calls.
+type ClickhouseTxPosition struct {
+	BlockNumber []uint64
+	BlockHash   []string
+	ParentHash  []string
+	TxHash      []string
+	TxIndex     []uint64
+}
+
+// hashColumn encodes a block hash for ClickHouse, empty if unknown.
+func hashColumn(hash common.Hash) string {
+	if hash == (common.Hash{}) {
+		return ""
+	}
+	return hash.Hex()
+}
+
+// appendTx appends the position of a transaction for a new row.
+func (p *ClickhouseTxPosition) appendTx(value *TxTrace) {
+	p.BlockNumber = append(p.BlockNumber, value.BlockNumber)
+	p.BlockHash = append(p.BlockHash, hashColumn(value.BlockHash))
+	p.ParentHash = append(p.ParentHash, hashColumn(value.ParentHash))
+	p.TxHash = append(p.TxHash, value.TxHash.Hex())
+	p.TxIndex = append(p.TxIndex, uint64(value.TxIndex))
+}
+
 // ClickhouseDecodedCallData represents decoded function call data for ClickHouse
 type ClickhouseDecodedCallData struct {
+	ChainId []uint64
+	ClickhouseTxPosition
 	TraceIdx     []uint64
 	FunctionName []string
 	CallData     [][]DecodedParams
 	ReturnData   [][]DecodedParams
+	Provenance   []string
+	Confidence   []float64
 }
 
 // NewClickhouseDecodedCallData creates a ClickhouseDecodedCallData from a TxTrace
@@ -17,10 +52,14 @@ func NewClickhouseDecodedCallData(value *TxTrace) *ClickhouseDecodedCallData {
 	result := &ClickhouseDecodedCallData{}
 	for _, trace := range value.Trace {
 		if trace.DecodedData != nil {
+			result.ChainId = append(result.ChainId, value.ChainId)
+			result.appendTx(value)
 			result.TraceIdx = append(result.TraceIdx, trace.TraceIdx)
 			result.FunctionName = append(result.FunctionName, trace.DecodedData.FunctionName)
 			result.CallData = append(result.CallData, trace.DecodedData.CallData)
 			result.ReturnData = append(result.ReturnData, trace.DecodedData.ReturnData)
+			result.Provenance = append(result.Provenance, string(trace.DecodedData.Provenance))
+			result.Confidence = append(result.Confidence, trace.DecodedData.Confidence)
 		}
 	}
 	return result
@@ -28,6 +67,8 @@ func NewClickhouseDecodedCallData(value *TxTrace) *ClickhouseDecodedCallData {
 
 // ClickhouseLogs represents transaction logs for ClickHouse
 type ClickhouseLogs struct {
+	ChainId []uint64
+	ClickhouseTxPosition
 	TraceIdx []uint64
 	LogIdx   []uint64
 	Address  []string
@@ -40,6 +81,8 @@ func NewClickhouseLogs(value *TxTrace) *ClickhouseLogs {
 	result := &ClickhouseLogs{}
 	for _, trace := range value.Trace {
 		for logIdx, log := range trace.Logs {
+			result.ChainId = append(result.ChainId, value.ChainId)
+			result.appendTx(value)
 			result.TraceIdx = append(result.TraceIdx, trace.TraceIdx)
 			result.LogIdx = append(result.LogIdx, uint64(logIdx))
 			result.Address = append(result.Address, log.Address.String())
@@ -51,7 +94,7 @@ func NewClickhouseLogs(value *TxTrace) *ClickhouseLogs {
 			}
 			result.Topics = append(result.Topics, topicStrings)
 
-			result.Data = append(result.Data, fmt.Sprintf("%x", log.Data))
+			result.Data = append(result.Data, fmt.Sprintf("%x", []byte(log.Data)))
 		}
 	}
 	return result
@@ -59,22 +102,35 @@ func NewClickhouseLogs(value *TxTrace) *ClickhouseLogs {
 
 // ClickhouseCreateAction represents contract creation actions for ClickHouse
 type ClickhouseCreateAction struct {
+	ChainId []uint64
+	ClickhouseTxPosition
 	TraceIdx []uint64
 	From     []string
 	Gas      []uint64
 	Init     []string
+	InitHash []string // set instead of Init if the init code was interned
 	Value    [][32]byte
 }
 
 // NewClickhouseCreateAction creates a ClickhouseCreateAction from a TxTrace
 func NewClickhouseCreateAction(value *TxTrace) *ClickhouseCreateAction {
+	return NewClickhouseCreateActionInterned(value, nil)
+}
+
+// NewClickhouseCreateActionInterned creates a ClickhouseCreateAction from a
+// TxTrace, interning the init code if an interner is given.
+func NewClickhouseCreateActionInterned(value *TxTrace, interner *BlobInterner) *ClickhouseCreateAction {
 	result := &ClickhouseCreateAction{}
 	for _, trace := range value.Trace {
 		if trace.IsCreate() {
+			result.ChainId = append(result.ChainId, value.ChainId)
+			result.appendTx(value)
 			result.TraceIdx = append(result.TraceIdx, trace.TraceIdx)
 			result.From = append(result.From, trace.Trace.Action.Create.From.String())
 			result.Gas = append(result.Gas, trace.Trace.Action.Create.Gas)
-			result.Init = append(result.Init, fmt.Sprintf("%x", trace.Trace.Action.Create.Init))
+			init, initHash := interner.Intern(trace.Trace.Action.Create.Init)
+			result.Init = append(result.Init, init)
+			result.InitHash = append(result.InitHash, initHash)
 
 			// Convert big.Int to [32]byte
 			var valueBytes [32]byte
@@ -87,26 +143,39 @@ func NewClickhouseCreateAction(value *TxTrace) *ClickhouseCreateAction {
 
 // ClickhouseCallAction represents contract call actions for ClickHouse
 type ClickhouseCallAction struct {
-	TraceIdx []uint64
-	From     []string
-	CallType []string
-	Gas      []uint64
-	Input    []string
-	To       []string
-	Value    [][32]byte
+	ChainId []uint64
+	ClickhouseTxPosition
+	TraceIdx  []uint64
+	From      []string
+	CallType  []string
+	Gas       []uint64
+	Input     []string
+	InputHash []string // set instead of Input if the call data was interned
+	To        []string
+	Value     [][32]byte
 }
 
 // NewClickhouseCallAction creates a ClickhouseCallAction from a TxTrace
 func NewClickhouseCallAction(value *TxTrace) *ClickhouseCallAction {
+	return NewClickhouseCallActionInterned(value, nil)
+}
+
+// NewClickhouseCallActionInterned creates a ClickhouseCallAction from a
+// TxTrace, interning the call data if an interner is given.
+func NewClickhouseCallActionInterned(value *TxTrace, interner *BlobInterner) *ClickhouseCallAction {
 	result := &ClickhouseCallAction{}
 	for _, trace := range value.Trace {
 
 		if trace.Trace.Action.Type == ActionTypeCall {
+			result.ChainId = append(result.ChainId, value.ChainId)
+			result.appendTx(value)
 			result.TraceIdx = append(result.TraceIdx, trace.TraceIdx)
 			result.From = append(result.From, trace.Trace.Action.Call.From.String())
-			result.CallType = append(result.CallType, string(trace.Trace.Action.Call.CallType))
+			result.CallType = append(result.CallType, trace.Trace.Action.Call.CallType.String())
 			result.Gas = append(result.Gas, trace.Trace.Action.Call.Gas)
-			result.Input = append(result.Input, fmt.Sprintf("%x", trace.Trace.Action.Call.Input))
+			input, inputHash := interner.Intern(trace.Trace.Action.Call.Input)
+			result.Input = append(result.Input, input)
+			result.InputHash = append(result.InputHash, inputHash)
 			result.To = append(result.To, trace.Trace.Action.Call.To.String())
 
 			var valueBytes [32]byte
@@ -119,6 +188,8 @@ func NewClickhouseCallAction(value *TxTrace) *ClickhouseCallAction {
 
 // ClickhouseSelfDestructAction represents self-destruct actions for ClickHouse
 type ClickhouseSelfDestructAction struct {
+	ChainId []uint64
+	ClickhouseTxPosition
 	TraceIdx      []uint64
 	Address       []string
 	Balance       [][32]byte
@@ -130,6 +201,8 @@ func NewClickhouseSelfDestructAction(value *TxTrace) *ClickhouseSelfDestructActi
 	result := &ClickhouseSelfDestructAction{}
 	for _, trace := range value.Trace {
 		if trace.Trace.Action.Type == ActionTypeSelfDestruct {
+			result.ChainId = append(result.ChainId, value.ChainId)
+			result.appendTx(value)
 			result.TraceIdx = append(result.TraceIdx, trace.TraceIdx)
 			result.Address = append(result.Address, trace.Trace.Action.SelfDestruct.Address.String())
 			result.RefundAddress = append(result.RefundAddress, trace.Trace.Action.SelfDestruct.RefundAddress.String())
@@ -145,6 +218,8 @@ func NewClickhouseSelfDestructAction(value *TxTrace) *ClickhouseSelfDestructActi
 
 // ClickhouseRewardAction represents reward actions for ClickHouse
 type ClickhouseRewardAction struct {
+	ChainId []uint64
+	ClickhouseTxPosition
 	TraceIdx   []uint64
 	Author     []string
 	Value      [][32]byte
@@ -156,6 +231,8 @@ func NewClickhouseRewardAction(value *TxTrace) *ClickhouseRewardAction {
 	result := &ClickhouseRewardAction{}
 	for _, trace := range value.Trace {
 		if trace.Trace.Action.Type == ActionTypeReward {
+			result.ChainId = append(result.ChainId, value.ChainId)
+			result.appendTx(value)
 			result.TraceIdx = append(result.TraceIdx, trace.TraceIdx)
 			result.Author = append(result.Author, trace.Trace.Action.Reward.Author.String())
 
@@ -179,20 +256,33 @@ func NewClickhouseRewardAction(value *TxTrace) *ClickhouseRewardAction {
 
 // ClickhouseCallOutput represents call outputs for ClickHouse
 type ClickhouseCallOutput struct {
-	TraceIdx []uint64
-	GasUsed  []uint64
-	Output   []string
+	ChainId []uint64
+	ClickhouseTxPosition
+	TraceIdx   []uint64
+	GasUsed    []uint64
+	Output     []string
+	OutputHash []string // set instead of Output if the return data was interned
 }
 
 // NewClickhouseCallOutput creates a ClickhouseCallOutput from a TxTrace
 func NewClickhouseCallOutput(value *TxTrace) *ClickhouseCallOutput {
+	return NewClickhouseCallOutputInterned(value, nil)
+}
+
+// NewClickhouseCallOutputInterned creates a ClickhouseCallOutput from a
+// TxTrace, interning the return data if an interner is given.
+func NewClickhouseCallOutputInterned(value *TxTrace, interner *BlobInterner) *ClickhouseCallOutput {
 	result := &ClickhouseCallOutput{}
 	for _, trace := range value.Trace {
 		if trace.Trace.Result != nil && trace.Trace.Result.Type == TraceOutputTypeCall && trace.Trace.Result.Call != nil {
 			callOutput := trace.Trace.Result.Call
+			result.ChainId = append(result.ChainId, value.ChainId)
+			result.appendTx(value)
 			result.TraceIdx = append(result.TraceIdx, trace.TraceIdx)
 			result.GasUsed = append(result.GasUsed, callOutput.GasUsed)
-			result.Output = append(result.Output, fmt.Sprintf("%x", callOutput.Output))
+			output, outputHash := interner.Intern(callOutput.Output)
+			result.Output = append(result.Output, output)
+			result.OutputHash = append(result.OutputHash, outputHash)
 		}
 	}
 	return result
@@ -200,21 +290,34 @@ func NewClickhouseCallOutput(value *TxTrace) *ClickhouseCallOutput {
 
 // ClickhouseCreateOutput represents contract creation outputs for ClickHouse
 type ClickhouseCreateOutput struct {
+	ChainId []uint64
+	ClickhouseTxPosition
 	TraceIdx []uint64
 	Address  []string
 	Code     []string
+	CodeHash []string // set instead of Code if the code was interned
 	GasUsed  []uint64
 }
 
 // NewClickhouseCreateOutput creates a ClickhouseCreateOutput from a TxTrace
 func NewClickhouseCreateOutput(value *TxTrace) *ClickhouseCreateOutput {
+	return NewClickhouseCreateOutputInterned(value, nil)
+}
+
+// NewClickhouseCreateOutputInterned creates a ClickhouseCreateOutput from a
+// TxTrace, interning the deployed code if an interner is given.
+func NewClickhouseCreateOutputInterned(value *TxTrace, interner *BlobInterner) *ClickhouseCreateOutput {
 	result := &ClickhouseCreateOutput{}
 	for _, trace := range value.Trace {
 		if trace.Trace.Result != nil && trace.Trace.Result.Type == TraceOutputTypeCreate && trace.Trace.Result.Create != nil {
 			createOutput := trace.Trace.Result.Create
+			result.ChainId = append(result.ChainId, value.ChainId)
+			result.appendTx(value)
 			result.TraceIdx = append(result.TraceIdx, trace.TraceIdx)
 			result.Address = append(result.Address, createOutput.Address.String())
-			result.Code = append(result.Code, fmt.Sprintf("%x", createOutput.Code))
+			code, codeHash := interner.Intern(createOutput.Code)
+			result.Code = append(result.Code, code)
+			result.CodeHash = append(result.CodeHash, codeHash)
 			result.GasUsed = append(result.GasUsed, createOutput.GasUsed)
 		}
 	}
@@ -0,0 +1,127 @@
+package brontes
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ClickhouseAccessList represents the accounts and storage slots accessed by
+// transactions for ClickHouse, one row per transaction and account, in order
+// of first access, so contention on hot contracts and slots can be aggregated
+// per block without joining the traces.
+//
+// Storage slots are only listed if the trace recorded storage accesses.
+// Transient storage is left out, as it does not outlive the transaction.
+type ClickhouseAccessList struct {
+	ChainId []uint64
+	ClickhouseTxPosition
+	Address       []string
+	StorageReads  [][]string
+	StorageWrites [][]string
+}
+
+// accountAccess is the storage accessed in a single account, deduplicated
+// across the frames of a transaction.
+type accountAccess struct {
+	reads, writes []string
+	keys          []common.Hash // slots read or written, in order of first access
+	seenReads     map[common.Hash]struct{}
+	seenWrites    map[common.Hash]struct{}
+}
+
+// accessedAccounts returns the accounts accessed by a transaction in order of
+// first access, along with the storage accessed in each.
+func accessedAccounts(value *TxTrace) ([]common.Address, map[common.Address]*accountAccess) {
+	var (
+		order    []common.Address
+		accounts = make(map[common.Address]*accountAccess)
+	)
+	touch := func(addr common.Address) *accountAccess {
+		acc, ok := accounts[addr]
+		if !ok {
+			acc = &accountAccess{
+				reads:      []string{},
+				writes:     []string{},
+				seenReads:  make(map[common.Hash]struct{}),
+				seenWrites: make(map[common.Hash]struct{}),
+			}
+			accounts[addr] = acc
+			order = append(order, addr)
+		}
+		return acc
+	}
+	for _, trace := range value.Trace {
+		action := trace.Trace.Action
+		if action == nil {
+			continue
+		}
+		switch action.Type {
+		case ActionTypeCall:
+			touch(action.Call.From)
+			touch(action.Call.To)
+		case ActionTypeCreate:
+			touch(action.Create.From)
+			if created := trace.GetCreateOutput(); created != (common.Address{}) {
+				touch(created)
+			}
+		case ActionTypeSelfDestruct:
+			touch(action.SelfDestruct.Address)
+			touch(action.SelfDestruct.RefundAddress)
+		case ActionTypeReward:
+			touch(action.Reward.Author)
+		}
+		if access := trace.StorageAccess; access != nil {
+			acc := touch(access.Address)
+			for _, slot := range access.Reads {
+				if _, ok := acc.seenReads[slot]; !ok {
+					acc.seenReads[slot] = struct{}{}
+					acc.reads = append(acc.reads, slot.Hex())
+					if _, ok := acc.seenWrites[slot]; !ok {
+						acc.keys = append(acc.keys, slot)
+					}
+				}
+			}
+			for _, slot := range access.Writes {
+				if _, ok := acc.seenWrites[slot]; !ok {
+					acc.seenWrites[slot] = struct{}{}
+					acc.writes = append(acc.writes, slot.Hex())
+					if _, ok := acc.seenReads[slot]; !ok {
+						acc.keys = append(acc.keys, slot)
+					}
+				}
+			}
+		}
+	}
+	return order, accounts
+}
+
+// NewClickhouseAccessList creates a ClickhouseAccessList from a TxTrace
+func NewClickhouseAccessList(value *TxTrace) *ClickhouseAccessList {
+	order, accounts := accessedAccounts(value)
+	result := &ClickhouseAccessList{}
+	for _, addr := range order {
+		acc := accounts[addr]
+		result.ChainId = append(result.ChainId, value.ChainId)
+		result.appendTx(value)
+		result.Address = append(result.Address, addr.String())
+		result.StorageReads = append(result.StorageReads, acc.reads)
+		result.StorageWrites = append(result.StorageWrites, acc.writes)
+	}
+	return result
+}
+
+// NewAccessList returns the accounts and storage slots accessed by a
+// transaction as an EIP-2930 access list, in order of first access. Storage
+// slots are only listed if the trace recorded storage accesses.
+func NewAccessList(value *TxTrace) types.AccessList {
+	order, accounts := accessedAccounts(value)
+	list := make(types.AccessList, 0, len(order))
+	for _, addr := range order {
+		keys := accounts[addr].keys
+		if keys == nil {
+			keys = []common.Hash{}
+		}
+		list = append(list, types.AccessTuple{Address: addr, StorageKeys: keys})
+	}
+	return list
+}
@@ -0,0 +1,300 @@
+package brontes
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// arenaColumns accumulates the rows of the enabled per-transaction tables
+// while walking the call arena. Disabled tables are nil.
+type arenaColumns struct {
+	decoded      *ClickhouseDecodedCallData
+	logs         *ClickhouseLogs
+	creates      *ClickhouseCreateAction
+	calls        *ClickhouseCallAction
+	selfDestruct *ClickhouseSelfDestructAction
+	callOutputs  *ClickhouseCallOutput
+	createOutput *ClickhouseCreateOutput
+
+	accessList bool
+	order      []common.Address
+	accounts   map[common.Address]*accountAccess
+}
+
+func newArenaColumns(switches ClickhouseTableSwitches) *arenaColumns {
+	c := new(arenaColumns)
+	if switches.Enabled(TableDecodedCallData) {
+		c.decoded = &ClickhouseDecodedCallData{}
+	}
+	if switches.Enabled(TableLogs) {
+		c.logs = &ClickhouseLogs{}
+	}
+	if switches.Enabled(TableCreateActions) {
+		c.creates = &ClickhouseCreateAction{}
+	}
+	if switches.Enabled(TableCallActions) {
+		c.calls = &ClickhouseCallAction{}
+	}
+	if switches.Enabled(TableSelfDestructActions) {
+		c.selfDestruct = &ClickhouseSelfDestructAction{}
+	}
+	if switches.Enabled(TableCallOutputs) {
+		c.callOutputs = &ClickhouseCallOutput{}
+	}
+	if switches.Enabled(TableCreateOutputs) {
+		c.createOutput = &ClickhouseCreateOutput{}
+	}
+	if switches.Enabled(TableAccessList) {
+		c.accessList = true
+		c.accounts = make(map[common.Address]*accountAccess)
+	}
+	return c
+}
+
+// touch returns the accessed storage of an account, adding it to the access
+// list on first access.
+func (c *arenaColumns) touch(addr common.Address) *accountAccess {
+	acc, ok := c.accounts[addr]
+	if !ok {
+		acc = &accountAccess{
+			reads:      []string{},
+			writes:     []string{},
+			seenReads:  make(map[common.Hash]struct{}),
+			seenWrites: make(map[common.Hash]struct{}),
+		}
+		c.accounts[addr] = acc
+		c.order = append(c.order, addr)
+	}
+	return acc
+}
+
+// value32 converts a value into its big endian 32 byte representation.
+func value32(value *big.Int) [32]byte {
+	var out [32]byte
+	value.FillBytes(out[:])
+	return out
+}
+
+// IntoClickhouseTables converts the recorded frames straight into the enabled
+// ClickHouse tables, keyed by name like NewClickhouseTables, without building
+// the intermediate TxTrace. Reward tables are never produced, as transactions
+// hold no reward frames. Large blobs are interned if an interner is given,
+// and only the frames passing the filter are converted if one is given.
+func (b *BrontesInspector) IntoClickhouseTables(txIndex int, switches ClickhouseTableSwitches, interner *BlobInterner, filter *ClickhouseFilter) (map[string]interface{}, error) {
+	nodes := b.Traces.Nodes()
+	if len(nodes) == 0 {
+		return nil, errors.New("no traces found")
+	}
+	var (
+		chainId  = b.ChainId
+		columns  = newArenaColumns(switches)
+		reverted = revertedByParent(nodes)
+		// header carries the position of the transaction for every row.
+		header = &TxTrace{
+			ChainId:     chainId,
+			BlockNumber: b.VMContext.BlockNumber.Uint64(),
+			BlockHash:   b.BlockHash,
+			ParentHash:  b.ParentHash,
+			TxHash:      b.Transaction.Hash(),
+			TxIndex:     txIndex,
+		}
+	)
+	for i := range nodes {
+		if err := b.interrupted(); err != nil {
+			return nil, err
+		}
+		node := &nodes[i]
+		if node.Trace.MaybePrecompile != nil && *node.Trace.MaybePrecompile {
+			continue
+		}
+		if !filter.keepsNode(&node.Trace, reverted[i]) {
+			continue
+		}
+		var (
+			trace    = &node.Trace
+			traceIdx = node.TraceIdx()
+			redacted = b.Config.Redact.redacts(trace)
+			data     = trace.Data
+			output   = trace.Output
+			// Failed frames have no output unless they reverted, and
+			// selfdestructs never have one.
+			hasOutput = !(trace.IsError() && !trace.IsRevert()) && !trace.Kind.IsSelfDestruct()
+		)
+		if redacted {
+			data, output = redactBytes(data), redactBytes(output)
+		}
+		switch {
+		case trace.Kind.IsAnyCall():
+			if columns.calls != nil {
+				t := columns.calls
+				input, inputHash := interner.Intern(data)
+				t.ChainId = append(t.ChainId, chainId)
+				t.appendTx(header)
+				t.TraceIdx = append(t.TraceIdx, traceIdx)
+				t.From = append(t.From, trace.Caller.String())
+				t.CallType = append(t.CallType, trace.Kind.String())
+				t.Gas = append(t.Gas, trace.GasLimit)
+				t.Input = append(t.Input, input)
+				t.InputHash = append(t.InputHash, inputHash)
+				t.To = append(t.To, trace.Address.String())
+				t.Value = append(t.Value, value32(trace.Value))
+			}
+			if hasOutput && columns.callOutputs != nil {
+				t := columns.callOutputs
+				out, outHash := interner.Intern(output)
+				t.ChainId = append(t.ChainId, chainId)
+				t.appendTx(header)
+				t.TraceIdx = append(t.TraceIdx, traceIdx)
+				t.GasUsed = append(t.GasUsed, trace.GasUsed)
+				t.Output = append(t.Output, out)
+				t.OutputHash = append(t.OutputHash, outHash)
+			}
+			if columns.accessList {
+				columns.touch(trace.Caller)
+				columns.touch(trace.Address)
+			}
+		case trace.Kind.IsAnyCreate():
+			if columns.creates != nil {
+				t := columns.creates
+				init, initHash := interner.Intern(data)
+				t.ChainId = append(t.ChainId, chainId)
+				t.appendTx(header)
+				t.TraceIdx = append(t.TraceIdx, traceIdx)
+				t.From = append(t.From, trace.Caller.String())
+				t.Gas = append(t.Gas, trace.GasLimit)
+				t.Init = append(t.Init, init)
+				t.InitHash = append(t.InitHash, initHash)
+				t.Value = append(t.Value, value32(trace.Value))
+			}
+			if hasOutput && columns.createOutput != nil {
+				t := columns.createOutput
+				code, codeHash := interner.Intern(output)
+				t.ChainId = append(t.ChainId, chainId)
+				t.appendTx(header)
+				t.TraceIdx = append(t.TraceIdx, traceIdx)
+				t.Address = append(t.Address, trace.Address.String())
+				t.Code = append(t.Code, code)
+				t.CodeHash = append(t.CodeHash, codeHash)
+				t.GasUsed = append(t.GasUsed, trace.GasUsed)
+			}
+			if columns.accessList {
+				columns.touch(trace.Caller)
+				if hasOutput && trace.Address != (common.Address{}) {
+					columns.touch(trace.Address)
+				}
+			}
+		case trace.Kind.IsSelfDestruct():
+			if columns.selfDestruct != nil {
+				t := columns.selfDestruct
+				t.ChainId = append(t.ChainId, chainId)
+				t.appendTx(header)
+				t.TraceIdx = append(t.TraceIdx, traceIdx)
+				t.Address = append(t.Address, trace.ContextAddress.String())
+				t.RefundAddress = append(t.RefundAddress, trace.SelfDestructRefundTarget.String())
+				t.Balance = append(t.Balance, value32(trace.Value))
+			}
+			if columns.accessList {
+				columns.touch(trace.ContextAddress)
+				columns.touch(*trace.SelfDestructRefundTarget)
+			}
+		}
+		if columns.accessList && trace.StorageAccess != nil {
+			acc := columns.touch(trace.StorageAccess.Address)
+			for _, slot := range trace.StorageAccess.Reads {
+				if _, ok := acc.seenReads[slot]; !ok {
+					acc.seenReads[slot] = struct{}{}
+					acc.reads = append(acc.reads, slot.Hex())
+				}
+			}
+			for _, slot := range trace.StorageAccess.Writes {
+				if _, ok := acc.seenWrites[slot]; !ok {
+					acc.seenWrites[slot] = struct{}{}
+					acc.writes = append(acc.writes, slot.Hex())
+				}
+			}
+		}
+		if columns.logs != nil {
+			t := columns.logs
+			for logIdx, log := range node.Logs {
+				topics := make([]string, len(log.Topics))
+				for i, topic := range log.Topics {
+					topics[i] = topic.String()
+				}
+				t.ChainId = append(t.ChainId, chainId)
+				t.appendTx(header)
+				t.TraceIdx = append(t.TraceIdx, traceIdx)
+				t.LogIdx = append(t.LogIdx, uint64(logIdx))
+				t.Address = append(t.Address, trace.Address.String())
+				t.Topics = append(t.Topics, topics)
+				t.Data = append(t.Data, fmt.Sprintf("%x", []byte(log.Data)))
+			}
+		}
+		if columns.decoded != nil && !redacted {
+			var constructorArgs []byte
+			if trace.Kind.IsAnyCreate() && trace.Success {
+				_, constructorArgs = SplitInitCode(trace.Data, trace.Output)
+			}
+			if decoded := b.decodeNode(node, constructorArgs); decoded != nil {
+				t := columns.decoded
+				t.ChainId = append(t.ChainId, chainId)
+				t.appendTx(header)
+				t.TraceIdx = append(t.TraceIdx, traceIdx)
+				t.FunctionName = append(t.FunctionName, decoded.FunctionName)
+				t.CallData = append(t.CallData, decoded.CallData)
+				t.ReturnData = append(t.ReturnData, decoded.ReturnData)
+				t.Provenance = append(t.Provenance, string(decoded.Provenance))
+				t.Confidence = append(t.Confidence, decoded.Confidence)
+			}
+		}
+	}
+	tables := columns.tables(header)
+	if switches.Enabled(TableOrderflow) {
+		if orderflow := b.orderflow(); orderflow != nil {
+			header.Orderflow = orderflow
+			tables[TableOrderflow] = NewClickhouseOrderflow(header)
+		}
+	}
+	return tables, nil
+}
+
+// tables returns the enabled tables holding any rows, keyed by name.
+func (c *arenaColumns) tables(header *TxTrace) map[string]interface{} {
+	tables := make(map[string]interface{})
+	if c.decoded != nil && len(c.decoded.TraceIdx) > 0 {
+		tables[TableDecodedCallData] = c.decoded
+	}
+	if c.logs != nil && len(c.logs.TraceIdx) > 0 {
+		tables[TableLogs] = c.logs
+	}
+	if c.creates != nil && len(c.creates.TraceIdx) > 0 {
+		tables[TableCreateActions] = c.creates
+	}
+	if c.calls != nil && len(c.calls.TraceIdx) > 0 {
+		tables[TableCallActions] = c.calls
+	}
+	if c.selfDestruct != nil && len(c.selfDestruct.TraceIdx) > 0 {
+		tables[TableSelfDestructActions] = c.selfDestruct
+	}
+	if c.callOutputs != nil && len(c.callOutputs.TraceIdx) > 0 {
+		tables[TableCallOutputs] = c.callOutputs
+	}
+	if c.createOutput != nil && len(c.createOutput.TraceIdx) > 0 {
+		tables[TableCreateOutputs] = c.createOutput
+	}
+	if len(c.order) > 0 {
+		access := &ClickhouseAccessList{}
+		for _, addr := range c.order {
+			acc := c.accounts[addr]
+			access.ChainId = append(access.ChainId, header.ChainId)
+			access.appendTx(header)
+			access.Address = append(access.Address, addr.String())
+			access.StorageReads = append(access.StorageReads, acc.reads)
+			access.StorageWrites = append(access.StorageWrites, acc.writes)
+		}
+		tables[TableAccessList] = access
+	}
+	return tables
+}
@@ -0,0 +1,89 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClickhouseTablesFromArena(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	// The caller calls the callee, which stores a word and returns it.
+	code := append([]byte{
+		byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.PUSH1), 4, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH20),
+	}, callee.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.STOP))
+	calleeCode := []byte{
+		byte(vm.PUSH1), 7, byte(vm.PUSH1), 1, byte(vm.SSTORE),
+		byte(vm.PUSH1), 7, byte(vm.PUSH1), 0, byte(vm.MSTORE),
+		byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	}
+
+	tests := []struct {
+		name     string
+		switches ClickhouseTableSwitches
+		redact   *RedactionConfig
+		filter   *ClickhouseFilter
+	}{
+		{name: "all tables"},
+		{name: "no access list", switches: ClickhouseTableSwitches{TableAccessList: false}},
+		{name: "redacted", redact: &RedactionConfig{Addresses: []common.Address{callee}}},
+		{name: "filtered", filter: &ClickhouseFilter{Addresses: []common.Address{callee}, Kinds: []CallKind{CallKindCall}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultTracingInspectorConfig
+			config.RecordStorageAccess = true
+			config.Redact = tt.redact
+			inspector, _, receipt := executeInspected(t, config, code, callee, calleeCode)
+
+			trace, err := inspector.IntoTraceResults(nil, receipt, 3)
+			require.NoError(t, err)
+			want := NewClickhouseTables(trace, tt.switches, nil, tt.filter)
+			have, err := inspector.IntoClickhouseTables(3, tt.switches, nil, tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, want, have)
+			assert.Contains(t, have, TableCallOutputs)
+		})
+	}
+}
+
+func TestRevertedByParent(t *testing.T) {
+	var (
+		callee = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		other  = common.HexToAddress("0x3333333333333333333333333333333333333333")
+	)
+	call := func(to common.Address) []byte {
+		code := append([]byte{
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+			byte(vm.PUSH20),
+		}, to.Bytes()...)
+		return append(code, byte(vm.GAS), byte(vm.CALL))
+	}
+	// The callee reverts after a successful call of its own.
+	code := append(call(callee), byte(vm.STOP))
+	calleeCode := append(call(other), byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT))
+
+	inspector, _, receipt := executeInspected(t, DefaultTracingInspectorConfig, code, callee, calleeCode)
+	trace, err := inspector.IntoTraceResults(nil, receipt, 0)
+	require.NoError(t, err)
+	require.Len(t, trace.Trace, 3)
+	assert.False(t, trace.Trace[0].RevertedByParent)
+	assert.NotNil(t, trace.Trace[1].Trace.Error)
+	assert.False(t, trace.Trace[1].RevertedByParent)
+	assert.Nil(t, trace.Trace[2].Trace.Error)
+	assert.True(t, trace.Trace[2].RevertedByParent)
+	assert.Equal(t, []bool{false, true, true}, revertedFrames(trace))
+
+	// Reverted frames are left out of the tables on request.
+	filter := &ClickhouseFilter{ExcludeReverted: true}
+	want := NewClickhouseTables(trace, nil, nil, filter)
+	have, err := inspector.IntoClickhouseTables(0, nil, nil, filter)
+	require.NoError(t, err)
+	assert.Equal(t, want, have)
+	assert.Equal(t, []uint64{0}, have[TableCallActions].(*ClickhouseCallAction).TraceIdx)
+}
@@ -0,0 +1,61 @@
+package brontes
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ClickhouseBlobs holds content-addressed byte blobs referenced by hash from
+// the call data and code columns of the other tables.
+type ClickhouseBlobs struct {
+	Hash []string
+	Data []string
+}
+
+// BlobInterner replaces large call data, return data and code with their
+// keccak256 hash, collecting every distinct blob once into a blobs table.
+// Router heavy blocks repeat the same blobs many times, so this shrinks the
+// stored traces considerably. It is not safe for concurrent use.
+type BlobInterner struct {
+	minSize int
+	seen    lru.BasicLRU[common.Hash, struct{}]
+	pending *ClickhouseBlobs
+}
+
+// NewBlobInterner creates an interner for blobs of at least minSize bytes,
+// remembering up to capacity hashes already emitted. Evicted blobs are
+// emitted again when next seen, so the blobs table should deduplicate rows
+// by hash.
+func NewBlobInterner(minSize int, capacity int) *BlobInterner {
+	return &BlobInterner{
+		minSize: minSize,
+		seen:    lru.NewBasicLRU[common.Hash, struct{}](capacity),
+		pending: &ClickhouseBlobs{},
+	}
+}
+
+// Intern returns the inline hex encoding of data, or an empty string and the
+// hash of data if the blob is interned.
+func (i *BlobInterner) Intern(data []byte) (inline string, hash string) {
+	if i == nil || len(data) < i.minSize {
+		return fmt.Sprintf("%x", data), ""
+	}
+	key := crypto.Keccak256Hash(data)
+	if !i.seen.Contains(key) {
+		i.seen.Add(key, struct{}{})
+		i.pending.Hash = append(i.pending.Hash, key.Hex())
+		i.pending.Data = append(i.pending.Data, fmt.Sprintf("%x", data))
+	}
+	return "", key.Hex()
+}
+
+// Flush returns the blobs interned since the last flush. They have to be
+// written along with the rows referencing them.
+func (i *BlobInterner) Flush() *ClickhouseBlobs {
+	blobs := i.pending
+	i.pending = &ClickhouseBlobs{}
+	return blobs
+}
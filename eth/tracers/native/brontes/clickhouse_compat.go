@@ -0,0 +1,69 @@
+package brontes
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// clickhouseCompatColumns lists the columns of the tables written by the
+// brontes ClickHouse writer, the schema of existing brontes databases. The
+// tables and columns this package adds on top, such as the chain id, the
+// position of the transaction, the provenance of decoded calls and the hashes
+// of interned blobs, are missing there.
+var clickhouseCompatColumns = map[string][]string{
+	TableDecodedCallData:     {"TraceIdx", "FunctionName", "CallData", "ReturnData"},
+	TableLogs:                {"TraceIdx", "LogIdx", "Address", "Topics", "Data"},
+	TableCreateActions:       {"TraceIdx", "From", "Gas", "Init", "Value"},
+	TableCallActions:         {"TraceIdx", "From", "CallType", "Gas", "Input", "To", "Value"},
+	TableSelfDestructActions: {"TraceIdx", "Address", "Balance", "RefundAddress"},
+	TableRewardActions:       {"TraceIdx", "Author", "Value", "RewardType"},
+	TableCallOutputs:         {"TraceIdx", "GasUsed", "Output"},
+	TableCreateOutputs:       {"TraceIdx", "Address", "Code", "GasUsed"},
+}
+
+// CompatTables returns the names of the enabled tables the brontes writer
+// has, in a fixed order. It fails if a table it lacks is explicitly enabled.
+func (s ClickhouseTableSwitches) CompatTables() ([]string, error) {
+	var names []string
+	for name, enabled := range s {
+		if _, ok := clickhouseCompatColumns[name]; !ok && enabled {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return nil, fmt.Errorf("clickhouse tables %v are not written by brontes", names)
+	}
+	names = names[:0]
+	for _, name := range s.Tables() {
+		if _, ok := clickhouseCompatColumns[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// ClickhouseCompat projects the rows of a table built by NewClickhouseTables
+// onto the columns of the brontes writer, keyed by field name like the table
+// itself is encoded. Blobs must not be interned, as the writer has no column
+// for their hashes.
+func ClickhouseCompat(table string, rows interface{}) (map[string]interface{}, error) {
+	columns, ok := clickhouseCompatColumns[table]
+	if !ok {
+		return nil, fmt.Errorf("clickhouse table %q is not written by brontes", table)
+	}
+	value := reflect.ValueOf(rows)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("invalid clickhouse table %T", rows)
+	}
+	result := make(map[string]interface{}, len(columns))
+	for _, name := range columns {
+		column := value.Elem().FieldByName(name)
+		if !column.IsValid() {
+			return nil, fmt.Errorf("clickhouse table %q lacks column %s", table, name)
+		}
+		result[name] = column.Interface()
+	}
+	return result, nil
+}
@@ -0,0 +1,155 @@
+package brontes
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ClickhouseDailyContractStats represents the activity of contracts for
+// ClickHouse, one row per day and called contract, rolled up from the call
+// and log tables. Days are in UTC.
+type ClickhouseDailyContractStats struct {
+	ChainId []uint64
+	Day     []string
+	Address []string
+	Calls   []uint64
+	Failed  []uint64 // calls without output, which ran into an error
+	GasUsed []uint64
+	// Value is the ether sent along the calls to the contract.
+	Value [][32]byte
+	// TokenTransfers and TokenVolume are the ERC-20 transfers emitted by the
+	// contract and the tokens they moved.
+	TokenTransfers []uint64
+	TokenVolume    [][32]byte
+}
+
+// Day returns the day of a block timestamp, as used by the daily tables.
+func Day(timestamp uint64) string {
+	return time.Unix(int64(timestamp), 0).UTC().Format(time.DateOnly)
+}
+
+type dailyKey struct {
+	chainId uint64
+	day     string
+	address string
+}
+
+type dailyCount struct {
+	calls, failed, gasUsed, tokenTransfers uint64
+	value, tokenVolume                     *big.Int
+}
+
+// DailyStatsAggregator rolls the rows of the call and log tables up into
+// daily statistics per contract. It is not safe for concurrent use.
+type DailyStatsAggregator struct {
+	counts map[dailyKey]*dailyCount
+}
+
+// NewDailyStatsAggregator creates an empty aggregator.
+func NewDailyStatsAggregator() *DailyStatsAggregator {
+	return &DailyStatsAggregator{counts: make(map[dailyKey]*dailyCount)}
+}
+
+func (a *DailyStatsAggregator) count(chainId uint64, day, address string) *dailyCount {
+	key := dailyKey{chainId: chainId, day: day, address: address}
+	count, ok := a.counts[key]
+	if !ok {
+		count = &dailyCount{value: new(big.Int), tokenVolume: new(big.Int)}
+		a.counts[key] = count
+	}
+	return count
+}
+
+// AddCalls counts the call actions of a transaction executed on the given
+// day, along with the gas used by those having an output. The outputs may be
+// nil if the transaction has none.
+func (a *DailyStatsAggregator) AddCalls(day string, actions *ClickhouseCallAction, outputs *ClickhouseCallOutput) {
+	gasUsed := make(map[uint64]uint64)
+	if outputs != nil {
+		for i, idx := range outputs.TraceIdx {
+			gasUsed[idx] = outputs.GasUsed[i]
+		}
+	}
+	for i, idx := range actions.TraceIdx {
+		count := a.count(actions.ChainId[i], day, actions.To[i])
+		count.calls++
+		if gas, ok := gasUsed[idx]; ok {
+			count.gasUsed += gas
+		} else {
+			count.failed++
+		}
+		count.value.Add(count.value, new(big.Int).SetBytes(actions.Value[i][:]))
+	}
+}
+
+// AddLogs counts the ERC-20 transfers among the logs of a transaction
+// executed on the given day.
+func (a *DailyStatsAggregator) AddLogs(day string, logs *ClickhouseLogs) {
+	topic := transferTopic.Hex()
+	for i, topics := range logs.Topics {
+		data := common.FromHex(logs.Data[i])
+		if len(topics) != 3 || !strings.EqualFold(topics[0], topic) || len(data) != 32 {
+			continue
+		}
+		count := a.count(logs.ChainId[i], day, logs.Address[i])
+		count.tokenTransfers++
+		count.tokenVolume.Add(count.tokenVolume, new(big.Int).SetBytes(data))
+	}
+}
+
+// Flush returns the statistics counted since the last flush, one table per
+// day in ascending order, with the contracts of a day sorted by address.
+func (a *DailyStatsAggregator) Flush() []*ClickhouseDailyContractStats {
+	keys := make([]dailyKey, 0, len(a.counts))
+	for key := range a.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].day != keys[j].day {
+			return keys[i].day < keys[j].day
+		}
+		if keys[i].chainId != keys[j].chainId {
+			return keys[i].chainId < keys[j].chainId
+		}
+		return keys[i].address < keys[j].address
+	})
+	var result []*ClickhouseDailyContractStats
+	for _, key := range keys {
+		if len(result) == 0 || result[len(result)-1].Day[0] != key.day {
+			result = append(result, &ClickhouseDailyContractStats{})
+		}
+		var (
+			table = result[len(result)-1]
+			count = a.counts[key]
+		)
+		table.ChainId = append(table.ChainId, key.chainId)
+		table.Day = append(table.Day, key.day)
+		table.Address = append(table.Address, key.address)
+		table.Calls = append(table.Calls, count.calls)
+		table.Failed = append(table.Failed, count.failed)
+		table.GasUsed = append(table.GasUsed, count.gasUsed)
+		table.TokenTransfers = append(table.TokenTransfers, count.tokenTransfers)
+		table.Value = append(table.Value, saturatedWord(count.value))
+		table.TokenVolume = append(table.TokenVolume, saturatedWord(count.tokenVolume))
+	}
+	a.counts = make(map[dailyKey]*dailyCount)
+	return result
+}
+
+// saturatedWord encodes a sum as a 256 bit word, capped at the largest word
+// as the volumes of tokens with huge supplies may overflow it.
+func saturatedWord(sum *big.Int) [32]byte {
+	var word [32]byte
+	if sum.BitLen() > 256 {
+		for i := range word {
+			word[i] = 0xff
+		}
+		return word
+	}
+	sum.FillBytes(word[:])
+	return word
+}
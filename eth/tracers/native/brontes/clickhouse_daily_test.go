@@ -0,0 +1,77 @@
+package brontes
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDailyStatsAggregator(t *testing.T) {
+	var (
+		router = common.HexToAddress("0x1111111111111111111111111111111111111111").String()
+		token  = common.HexToAddress("0x2222222222222222222222222222222222222222").String()
+		day    = Day(1700000000)
+		next   = Day(1700000000 + 24*60*60)
+	)
+	assert.Equal(t, "2023-11-14", day)
+	assert.Equal(t, "2023-11-15", next)
+
+	word := func(v int64) [32]byte {
+		var w [32]byte
+		big.NewInt(v).FillBytes(w[:])
+		return w
+	}
+	// The router forwards 5 wei to the token, then calls it again, running
+	// into an error.
+	actions := &ClickhouseCallAction{
+		ChainId:  []uint64{1, 1, 1},
+		TraceIdx: []uint64{0, 1, 2},
+		To:       []string{router, token, token},
+		Value:    [][32]byte{word(5), word(5), word(0)},
+	}
+	outputs := &ClickhouseCallOutput{
+		TraceIdx: []uint64{0, 1},
+		GasUsed:  []uint64{100000, 30000},
+	}
+	transfer := transferTopic.Hex()
+	logs := &ClickhouseLogs{
+		ChainId: []uint64{1, 1, 1},
+		Address: []string{token, token, router},
+		Topics:  [][]string{{transfer, "0x01", "0x02"}, {transfer, "0x02", "0x03"}, {common.Hash{}.Hex()}},
+		Data:    []string{fmt.Sprintf("%064x", 7), fmt.Sprintf("%064x", 8), ""},
+	}
+	aggregator := NewDailyStatsAggregator()
+	aggregator.AddCalls(day, actions, outputs)
+	aggregator.AddLogs(day, logs)
+	aggregator.AddCalls(next, &ClickhouseCallAction{
+		ChainId:  []uint64{1},
+		TraceIdx: []uint64{0},
+		To:       []string{router},
+		Value:    [][32]byte{word(0)},
+	}, nil)
+
+	tables := aggregator.Flush()
+	require.Len(t, tables, 2)
+	assert.Equal(t, &ClickhouseDailyContractStats{
+		ChainId:        []uint64{1, 1},
+		Day:            []string{day, day},
+		Address:        []string{router, token},
+		Calls:          []uint64{1, 2},
+		Failed:         []uint64{0, 1},
+		GasUsed:        []uint64{100000, 30000},
+		Value:          [][32]byte{word(5), word(5)},
+		TokenTransfers: []uint64{0, 2},
+		TokenVolume:    [][32]byte{word(0), word(15)},
+	}, tables[0])
+	assert.Equal(t, []string{next}, tables[1].Day)
+	assert.Equal(t, []uint64{1}, tables[1].Failed)
+	assert.Empty(t, aggregator.Flush())
+
+	// Volumes beyond 256 bits saturate.
+	largest := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	assert.Equal(t, saturatedWord(largest), saturatedWord(new(big.Int).Lsh(big.NewInt(1), 300)))
+}
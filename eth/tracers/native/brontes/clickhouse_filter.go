@@ -0,0 +1,124 @@
+package brontes
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ClickhouseFilter selects the frames converted into the ClickHouse tables,
+// so exporters can produce slim tables for specific analytics. A frame is
+// kept if it matches all criteria that are set. Create2 frames are matched
+// and EOF create frames are matched as creations.
+type ClickhouseFilter struct {
+	// Kinds keeps the frames of the given kinds.
+	Kinds []CallKind `json:"kinds,omitempty"`
+	// MinValue keeps the frames transferring at least the given value.
+	MinValue *hexutil.Big `json:"minValue,omitempty"`
+	// Addresses keeps the frames whose caller, target, executed code or
+	// refund address is one of the given accounts.
+	Addresses []common.Address `json:"addresses,omitempty"`
+	// ExcludeReverted drops the frames that failed or were reverted by a
+	// failed ancestor, so the values and balances of the tables only reflect
+	// effective transfers.
+	ExcludeReverted bool `json:"excludeReverted,omitempty"`
+}
+
+// Validate fails on unknown call kinds.
+func (f *ClickhouseFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	for _, kind := range f.Kinds {
+		if !kind.IsValid() || (kind.IsAnyCreate() && kind != CallKindCreate) {
+			return fmt.Errorf("invalid clickhouse filter kind %q", kind)
+		}
+	}
+	return nil
+}
+
+// keeps reports whether a frame of the given kind, transferring value and
+// involving the given accounts, passes the filter.
+func (f *ClickhouseFilter) keeps(kind CallKind, value *big.Int, addrs ...common.Address) bool {
+	if f == nil {
+		return true
+	}
+	if kind.IsAnyCreate() {
+		kind = CallKindCreate
+	}
+	if len(f.Kinds) > 0 && !slices.Contains(f.Kinds, kind) {
+		return false
+	}
+	if f.MinValue != nil && (value == nil || value.Cmp(f.MinValue.ToInt()) < 0) {
+		return false
+	}
+	if len(f.Addresses) > 0 && !slices.ContainsFunc(addrs, func(addr common.Address) bool {
+		return slices.Contains(f.Addresses, addr)
+	}) {
+		return false
+	}
+	return true
+}
+
+// keepsFrame reports whether a frame of a trace passes the filter.
+func (f *ClickhouseFilter) keepsFrame(frame *TransactionTraceWithLogs) bool {
+	if f != nil && f.ExcludeReverted && (frame.Trace.Error != nil || frame.RevertedByParent) {
+		return false
+	}
+	action := frame.Trace.Action
+	if action == nil {
+		return f == nil
+	}
+	switch action.Type {
+	case ActionTypeCall:
+		return f.keeps(action.Call.CallType, action.Call.Value, action.Call.From, action.Call.To, frame.CodeAddress, frame.ContextAddress)
+	case ActionTypeCreate:
+		var created common.Address
+		if result := frame.Trace.Result; result != nil && result.Create != nil {
+			created = result.Create.Address
+		}
+		return f.keeps(CallKindCreate, action.Create.Value, action.Create.From, created)
+	case ActionTypeSelfDestruct:
+		return f.keeps(CallKindSelfDestruct, action.SelfDestruct.Balance, action.SelfDestruct.Address, action.SelfDestruct.RefundAddress)
+	}
+	return f == nil
+}
+
+// keepsNode reports whether a recorded frame passes the filter, given
+// whether it was reverted by an ancestor.
+func (f *ClickhouseFilter) keepsNode(trace *CallTrace, revertedByParent bool) bool {
+	if f != nil && f.ExcludeReverted && (!trace.Success || revertedByParent) {
+		return false
+	}
+	switch {
+	case trace.Kind.IsSelfDestruct():
+		return f.keeps(trace.Kind, trace.Value, trace.ContextAddress, *trace.SelfDestructRefundTarget)
+	case trace.Kind.IsAnyCreate():
+		// Creations failing without revert have no created account.
+		if trace.IsError() && !trace.IsRevert() {
+			return f.keeps(trace.Kind, trace.Value, trace.Caller)
+		}
+		return f.keeps(trace.Kind, trace.Value, trace.Caller, trace.Address)
+	}
+	return f.keeps(trace.Kind, trace.Value, trace.Caller, trace.Address, trace.CodeAddress, trace.ContextAddress)
+}
+
+// Apply returns a copy of the trace holding only the frames passing the
+// filter, which can be passed to any of the ClickHouse builders. The frames
+// are shared with the original trace.
+func (f *ClickhouseFilter) Apply(value *TxTrace) *TxTrace {
+	if f == nil {
+		return value
+	}
+	filtered := *value
+	filtered.Trace = make([]TransactionTraceWithLogs, 0, len(value.Trace))
+	for i := range value.Trace {
+		if f.keepsFrame(&value.Trace[i]) {
+			filtered.Trace = append(filtered.Trace, value.Trace[i])
+		}
+	}
+	return &filtered
+}
@@ -0,0 +1,150 @@
+package brontes
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clickhouseGolden is a trace along with the rows expected in every table,
+// one object per row keyed by column name, in the format of SELECT ... FORMAT
+// JSONEachRow. The rows are written by hand after the brontes schema, they are
+// not exported by the brontes ClickHouse writer.
+type clickhouseGolden struct {
+	Trace  *TxTrace                                `json:"trace"`
+	Tables map[string][]map[string]json.RawMessage `json:"tables"`
+}
+
+// clickhouseColumn returns the ClickHouse column name of a table field.
+func clickhouseColumn(field string) string {
+	var name strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				name.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		name.WriteRune(r)
+	}
+	return name.String()
+}
+
+// clickhouseRows transposes a columnar table into rows, encoding the values
+// the way ClickHouse outputs them in JSON: 32 byte words are UInt256 columns
+// output in decimal. Columns of embedded structs are part of the table.
+func clickhouseRows(t *testing.T, table interface{}) []map[string]json.RawMessage {
+	t.Helper()
+	var rows []map[string]json.RawMessage
+	var transpose func(value reflect.Value)
+	transpose = func(value reflect.Value) {
+		for i := 0; i < value.NumField(); i++ {
+			column := value.Field(i)
+			if value.Type().Field(i).Anonymous {
+				transpose(column)
+				continue
+			}
+			for j := 0; j < column.Len(); j++ {
+				if j == len(rows) {
+					rows = append(rows, make(map[string]json.RawMessage))
+				}
+				var cell interface{} = column.Index(j).Interface()
+				if word, ok := cell.([32]byte); ok {
+					cell = new(big.Int).SetBytes(word[:]).String()
+				}
+				blob, err := json.Marshal(cell)
+				require.NoError(t, err)
+				rows[j][clickhouseColumn(value.Type().Field(i).Name)] = blob
+			}
+		}
+	}
+	transpose(reflect.ValueOf(table).Elem())
+	return rows
+}
+
+// TestClickhouseGolden checks the tables built from the traces of the golden
+// files against the expected rows, pinning the column names and encodings of
+// the schema. Every listed column must be produced with the same value.
+// Tables and columns left out of the golden files, such as the access list and
+// the hashes of interned blobs, are not checked.
+//
+// The golden rows are hand written after the schema of this package, which
+// extends the one of the brontes writer; the columns loaded into existing
+// brontes databases are pinned by TestClickhouseCompat. Rows exported by the
+// writer can be dropped in the directory as is.
+func TestClickhouseGolden(t *testing.T) {
+	dir := filepath.Join("testdata", "clickhouse_golden")
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		t.Run(strings.TrimSuffix(file.Name(), ".json"), func(t *testing.T) {
+			blob, err := os.ReadFile(filepath.Join(dir, file.Name()))
+			require.NoError(t, err)
+			var golden clickhouseGolden
+			require.NoError(t, json.Unmarshal(blob, &golden))
+
+			tables := NewClickhouseTables(golden.Trace, nil, nil, nil)
+			for name, want := range golden.Tables {
+				table, ok := tables[name]
+				if !ok {
+					assert.Empty(t, want, "table %s", name)
+					continue
+				}
+				have := clickhouseRows(t, table)
+				require.Len(t, have, len(want), "table %s", name)
+				for i, row := range want {
+					for column, cell := range row {
+						require.Contains(t, have[i], column, "table %s row %d", name, i)
+						assert.JSONEq(t, string(cell), string(have[i][column]), "table %s row %d column %s", name, i, column)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestClickhouseCompat checks that the compatibility projection of every
+// table holds exactly the columns of the brontes writer.
+func TestClickhouseCompat(t *testing.T) {
+	blob, err := os.ReadFile(filepath.Join("testdata", "clickhouse_golden", "call_and_create.json"))
+	require.NoError(t, err)
+	var golden clickhouseGolden
+	require.NoError(t, json.Unmarshal(blob, &golden))
+
+	tables := NewClickhouseTables(golden.Trace, nil, nil, nil)
+	require.NotEmpty(t, tables)
+	for name, rows := range tables {
+		compat, err := ClickhouseCompat(name, rows)
+		if _, ok := clickhouseCompatColumns[name]; !ok {
+			assert.Error(t, err, "table %s", name)
+			continue
+		}
+		require.NoError(t, err, "table %s", name)
+		var columns []string
+		for column := range compat {
+			columns = append(columns, column)
+		}
+		assert.ElementsMatch(t, clickhouseCompatColumns[name], columns, "table %s", name)
+	}
+	names, err := ClickhouseTableSwitches{}.CompatTables()
+	require.NoError(t, err)
+	assert.Len(t, names, len(clickhouseCompatColumns))
+	assert.NotContains(t, names, TableAccessList)
+
+	_, err = ClickhouseTableSwitches{TableAccessList: true}.CompatTables()
+	assert.Error(t, err)
+	names, err = ClickhouseTableSwitches{TableAccessList: false, TableLogs: false}.CompatTables()
+	require.NoError(t, err)
+	assert.NotContains(t, names, TableLogs)
+}
@@ -0,0 +1,96 @@
+package brontes
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ClickhouseSelectorStats represents call statistics for ClickHouse, one row
+// per block, called contract and function selector. Calls with less than four
+// bytes of call data, such as plain transfers, have an empty selector.
+type ClickhouseSelectorStats struct {
+	ChainId     []uint64
+	BlockNumber []uint64
+	BlockHash   []string
+	ParentHash  []string
+	Address     []string
+	Selector    []string
+	Calls       []uint64
+	Failed      []uint64 // calls that reverted or ran into an error
+	GasUsed     []uint64
+}
+
+type selectorKey struct {
+	chainId  uint64
+	block    uint64
+	hash     common.Hash
+	parent   common.Hash
+	address  common.Address
+	selector string
+}
+
+type selectorCount struct {
+	calls, failed, gasUsed uint64
+}
+
+// SelectorStatsAggregator counts the calls of traced transactions per block,
+// contract and selector, producing a compact table of protocol usage without
+// keeping the traces themselves. It is not safe for concurrent use.
+type SelectorStatsAggregator struct {
+	order  []selectorKey
+	counts map[selectorKey]*selectorCount
+}
+
+// NewSelectorStatsAggregator creates an empty aggregator.
+func NewSelectorStatsAggregator() *SelectorStatsAggregator {
+	return &SelectorStatsAggregator{counts: make(map[selectorKey]*selectorCount)}
+}
+
+// Add counts the call frames of a trace.
+func (a *SelectorStatsAggregator) Add(value *TxTrace) {
+	for _, trace := range value.Trace {
+		if trace.Trace.Action == nil || trace.Trace.Action.Type != ActionTypeCall {
+			continue
+		}
+		call := trace.Trace.Action.Call
+		key := selectorKey{chainId: value.ChainId, block: value.BlockNumber, hash: value.BlockHash, parent: value.ParentHash, address: call.To}
+		if len(call.Input) >= 4 {
+			key.selector = fmt.Sprintf("%x", []byte(call.Input[:4]))
+		}
+		count, ok := a.counts[key]
+		if !ok {
+			count = new(selectorCount)
+			a.counts[key] = count
+			a.order = append(a.order, key)
+		}
+		count.calls++
+		if trace.Trace.Error != nil {
+			count.failed++
+		}
+		if result := trace.Trace.Result; result != nil && result.Type == TraceOutputTypeCall && result.Call != nil {
+			count.gasUsed += result.Call.GasUsed
+		}
+	}
+}
+
+// Flush returns the statistics counted since the last flush, in order of
+// first call.
+func (a *SelectorStatsAggregator) Flush() *ClickhouseSelectorStats {
+	result := &ClickhouseSelectorStats{}
+	for _, key := range a.order {
+		count := a.counts[key]
+		result.ChainId = append(result.ChainId, key.chainId)
+		result.BlockNumber = append(result.BlockNumber, key.block)
+		result.BlockHash = append(result.BlockHash, hashColumn(key.hash))
+		result.ParentHash = append(result.ParentHash, hashColumn(key.parent))
+		result.Address = append(result.Address, key.address.String())
+		result.Selector = append(result.Selector, key.selector)
+		result.Calls = append(result.Calls, count.calls)
+		result.Failed = append(result.Failed, count.failed)
+		result.GasUsed = append(result.GasUsed, count.gasUsed)
+	}
+	a.order = nil
+	a.counts = make(map[selectorKey]*selectorCount)
+	return result
+}
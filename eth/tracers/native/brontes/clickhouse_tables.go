@@ -0,0 +1,126 @@
+package brontes
+
+import (
+	"fmt"
+)
+
+// Names of the per-transaction ClickHouse tables.
+const (
+	TableDecodedCallData     = "decoded_call_data"
+	TableLogs                = "logs"
+	TableCreateActions       = "create_actions"
+	TableCallActions         = "call_actions"
+	TableSelfDestructActions = "self_destruct_actions"
+	TableRewardActions       = "reward_actions"
+	TableCallOutputs         = "call_outputs"
+	TableCreateOutputs       = "create_outputs"
+	TableAccessList          = "access_list"
+	TableOrderflow           = "orderflow"
+)
+
+// clickhouseTable builds a table from a trace, returning its number of rows.
+type clickhouseTable struct {
+	name  string
+	build func(value *TxTrace, interner *BlobInterner) (interface{}, int)
+}
+
+var clickhouseTables = []clickhouseTable{
+	{TableDecodedCallData, func(value *TxTrace, _ *BlobInterner) (interface{}, int) {
+		table := NewClickhouseDecodedCallData(value)
+		return table, len(table.TraceIdx)
+	}},
+	{TableLogs, func(value *TxTrace, _ *BlobInterner) (interface{}, int) {
+		table := NewClickhouseLogs(value)
+		return table, len(table.TraceIdx)
+	}},
+	{TableCreateActions, func(value *TxTrace, interner *BlobInterner) (interface{}, int) {
+		table := NewClickhouseCreateActionInterned(value, interner)
+		return table, len(table.TraceIdx)
+	}},
+	{TableCallActions, func(value *TxTrace, interner *BlobInterner) (interface{}, int) {
+		table := NewClickhouseCallActionInterned(value, interner)
+		return table, len(table.TraceIdx)
+	}},
+	{TableSelfDestructActions, func(value *TxTrace, _ *BlobInterner) (interface{}, int) {
+		table := NewClickhouseSelfDestructAction(value)
+		return table, len(table.TraceIdx)
+	}},
+	{TableRewardActions, func(value *TxTrace, _ *BlobInterner) (interface{}, int) {
+		table := NewClickhouseRewardAction(value)
+		return table, len(table.TraceIdx)
+	}},
+	{TableCallOutputs, func(value *TxTrace, interner *BlobInterner) (interface{}, int) {
+		table := NewClickhouseCallOutputInterned(value, interner)
+		return table, len(table.TraceIdx)
+	}},
+	{TableCreateOutputs, func(value *TxTrace, interner *BlobInterner) (interface{}, int) {
+		table := NewClickhouseCreateOutputInterned(value, interner)
+		return table, len(table.TraceIdx)
+	}},
+	{TableAccessList, func(value *TxTrace, _ *BlobInterner) (interface{}, int) {
+		table := NewClickhouseAccessList(value)
+		return table, len(table.Address)
+	}},
+	{TableOrderflow, func(value *TxTrace, _ *BlobInterner) (interface{}, int) {
+		table := NewClickhouseOrderflow(value)
+		return table, len(table.TxHash)
+	}},
+}
+
+// ClickhouseTableSwitches enables or disables the per-transaction tables by
+// name. Tables not listed are enabled.
+type ClickhouseTableSwitches map[string]bool
+
+// Validate fails on unknown table names.
+func (s ClickhouseTableSwitches) Validate() error {
+	for name := range s {
+		if !isClickhouseTable(name) {
+			return fmt.Errorf("unknown clickhouse table %q", name)
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether the given table is written.
+func (s ClickhouseTableSwitches) Enabled(name string) bool {
+	enabled, ok := s[name]
+	return !ok || enabled
+}
+
+// Tables returns the names of the enabled tables, in a fixed order.
+func (s ClickhouseTableSwitches) Tables() []string {
+	var names []string
+	for _, table := range clickhouseTables {
+		if s.Enabled(table.name) {
+			names = append(names, table.name)
+		}
+	}
+	return names
+}
+
+func isClickhouseTable(name string) bool {
+	for _, table := range clickhouseTables {
+		if table.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NewClickhouseTables converts a trace into the enabled tables, keyed by
+// name. Tables without rows are left out. Large blobs are interned if an
+// interner is given, and only the frames passing the filter are converted if
+// one is given.
+func NewClickhouseTables(value *TxTrace, switches ClickhouseTableSwitches, interner *BlobInterner, filter *ClickhouseFilter) map[string]interface{} {
+	value = filter.Apply(value)
+	tables := make(map[string]interface{})
+	for _, table := range clickhouseTables {
+		if !switches.Enabled(table.name) {
+			continue
+		}
+		if rows, n := table.build(value, interner); n > 0 {
+			tables[table.name] = rows
+		}
+	}
+	return tables
+}
@@ -0,0 +1,296 @@
+package brontes
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTxTrace returns a small trace with a top-level call emitting a log
+// and a nested create.
+func newTestTxTrace() *TxTrace {
+	var (
+		sender   = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		target   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		deployed = common.HexToAddress("0x3333333333333333333333333333333333333333")
+	)
+	return &TxTrace{
+		ChainId:        10,
+		BlockNumber:    12345,
+		TxHash:         common.HexToHash("0xabcdef"),
+		GasUsed:        big.NewInt(80000),
+		EffectivePrice: big.NewInt(1),
+		IsSuccess:      true,
+		Trace: []TransactionTraceWithLogs{
+			{
+				TraceIdx:  0,
+				MsgSender: sender,
+				Logs: []types.Log{{
+					Address: target,
+					Topics:  []common.Hash{common.HexToHash("0x01")},
+					Data:    []byte{0x01},
+				}},
+				Trace: TransactionTrace{
+					Type: ActionTypeCall,
+					Action: &Action{
+						Type: ActionTypeCall,
+						Call: &CallAction{
+							From:     sender,
+							To:       target,
+							Value:    big.NewInt(0),
+							Gas:      100000,
+							Input:    []byte{0xde, 0xad, 0xbe, 0xef},
+							CallType: CallKindCall,
+						},
+					},
+					Result: &TraceOutput{
+						Type: TraceOutputTypeCall,
+						Call: &CallOutput{GasUsed: 60000},
+					},
+					Subtraces:    1,
+					TraceAddress: []uint{},
+				},
+			},
+			{
+				TraceIdx:  1,
+				MsgSender: target,
+				Trace: TransactionTrace{
+					Type: ActionTypeCreate,
+					Action: &Action{
+						Type: ActionTypeCreate,
+						Create: &CreateAction{
+							From:  target,
+							Value: big.NewInt(0),
+							Gas:   50000,
+							Init:  []byte{0x60, 0x00},
+						},
+					},
+					Result: &TraceOutput{
+						Type: TraceOutputTypeCreate,
+						Create: &CreateOutput{
+							GasUsed: 30000,
+							Address: deployed,
+						},
+					},
+					TraceAddress: []uint{0},
+				},
+			},
+		},
+	}
+}
+
+func TestClickhouseChainId(t *testing.T) {
+	trace := newTestTxTrace()
+
+	calls := NewClickhouseCallAction(trace)
+	assert.Equal(t, []uint64{10}, calls.ChainId)
+	assert.Equal(t, len(calls.TraceIdx), len(calls.ChainId))
+
+	creates := NewClickhouseCreateAction(trace)
+	assert.Equal(t, []uint64{10}, creates.ChainId)
+
+	logs := NewClickhouseLogs(trace)
+	assert.Equal(t, []uint64{10}, logs.ChainId)
+
+	outputs := NewClickhouseCreateOutput(trace)
+	assert.Equal(t, len(outputs.TraceIdx), len(outputs.ChainId))
+}
+
+func TestClickhouseTxPosition(t *testing.T) {
+	trace := newTestTxTrace()
+	trace.BlockHash = common.HexToHash("0xb1")
+	trace.ParentHash = common.HexToHash("0xb0")
+	trace.TxIndex = 4
+	trace.Orderflow = &Orderflow{Source: OrderflowPublic}
+
+	// Every row locates its transaction on its own.
+	tables := NewClickhouseTables(trace, nil, nil, nil)
+	require.Len(t, tables, 7)
+	for name, table := range tables {
+		for _, row := range clickhouseRows(t, table) {
+			assert.JSONEq(t, `12345`, string(row["block_number"]), name)
+			assert.JSONEq(t, `"`+trace.BlockHash.Hex()+`"`, string(row["block_hash"]), name)
+			assert.JSONEq(t, `"`+trace.ParentHash.Hex()+`"`, string(row["parent_hash"]), name)
+			assert.JSONEq(t, `"`+trace.TxHash.Hex()+`"`, string(row["tx_hash"]), name)
+			assert.JSONEq(t, `4`, string(row["tx_index"]), name)
+		}
+	}
+	// Unknown blocks are left empty.
+	calls := NewClickhouseCallAction(newTestTxTrace())
+	assert.Equal(t, []string{""}, calls.BlockHash)
+	assert.Equal(t, []string{""}, calls.ParentHash)
+}
+
+func TestClickhouseBlobInterning(t *testing.T) {
+	trace := newTestTxTrace()
+	// Duplicate the call so the same call data is seen twice.
+	trace.Trace = append(trace.Trace, trace.Trace[0])
+
+	interner := NewBlobInterner(4, 16)
+	calls := NewClickhouseCallActionInterned(trace, interner)
+	assert.Equal(t, []string{"", ""}, calls.Input)
+	hash := crypto.Keccak256Hash([]byte{0xde, 0xad, 0xbe, 0xef}).Hex()
+	assert.Equal(t, []string{hash, hash}, calls.InputHash)
+
+	// The init code is below the size threshold and stays inline.
+	creates := NewClickhouseCreateActionInterned(trace, interner)
+	assert.Equal(t, []string{"6000"}, creates.Init)
+	assert.Equal(t, []string{""}, creates.InitHash)
+
+	blobs := interner.Flush()
+	assert.Equal(t, []string{hash}, blobs.Hash)
+	assert.Equal(t, []string{"deadbeef"}, blobs.Data)
+
+	// Blobs already emitted are not repeated by later batches.
+	NewClickhouseCallActionInterned(trace, interner)
+	assert.Empty(t, interner.Flush().Hash)
+
+	// Without an interner the columns stay inline.
+	calls = NewClickhouseCallAction(trace)
+	assert.Equal(t, []string{"deadbeef", "deadbeef"}, calls.Input)
+	assert.Equal(t, []string{"", ""}, calls.InputHash)
+}
+
+func TestClickhouseAccessList(t *testing.T) {
+	trace := newTestTxTrace()
+	var (
+		sender   = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		target   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		deployed = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		slot1    = common.HexToHash("0x01")
+		slot2    = common.HexToHash("0x02")
+	)
+	// The create frame touches the storage of its caller a second time.
+	trace.Trace[0].StorageAccess = &StorageAccess{
+		Address:        target,
+		Reads:          []common.Hash{slot1},
+		Writes:         []common.Hash{slot1},
+		TransientReads: []common.Hash{slot2},
+	}
+	trace.Trace[1].StorageAccess = &StorageAccess{
+		Address: target,
+		Reads:   []common.Hash{slot1, slot2},
+	}
+	trace.TxIndex = 3
+
+	accesses := NewClickhouseAccessList(trace)
+	assert.Equal(t, []string{sender.String(), target.String(), deployed.String()}, accesses.Address)
+	assert.Equal(t, []uint64{10, 10, 10}, accesses.ChainId)
+	assert.Equal(t, []uint64{12345, 12345, 12345}, accesses.BlockNumber)
+	assert.Equal(t, []uint64{3, 3, 3}, accesses.TxIndex)
+	assert.Equal(t, trace.TxHash.Hex(), accesses.TxHash[0])
+	assert.Equal(t, [][]string{{}, {slot1.Hex(), slot2.Hex()}, {}}, accesses.StorageReads)
+	assert.Equal(t, [][]string{{}, {slot1.Hex()}, {}}, accesses.StorageWrites)
+
+	assert.Equal(t, types.AccessList{
+		{Address: sender, StorageKeys: []common.Hash{}},
+		{Address: target, StorageKeys: []common.Hash{slot1, slot2}},
+		{Address: deployed, StorageKeys: []common.Hash{}},
+	}, NewAccessList(trace))
+}
+
+func TestSelectorStatsAggregator(t *testing.T) {
+	var (
+		target = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		failed = "execution reverted"
+	)
+	first := newTestTxTrace()
+	second := newTestTxTrace()
+	second.Trace[0].Trace.Error = &failed
+	second.Trace[0].Trace.Result = nil
+	// A plain transfer to the same contract has no selector.
+	transfer := newTestTxTrace()
+	transfer.Trace[0].Trace.Action.Call.Input = nil
+
+	aggregator := NewSelectorStatsAggregator()
+	aggregator.Add(first)
+	aggregator.Add(second)
+	aggregator.Add(transfer)
+
+	stats := aggregator.Flush()
+	assert.Equal(t, []uint64{10, 10}, stats.ChainId)
+	assert.Equal(t, []uint64{12345, 12345}, stats.BlockNumber)
+	assert.Equal(t, []string{target.String(), target.String()}, stats.Address)
+	assert.Equal(t, []string{"deadbeef", ""}, stats.Selector)
+	assert.Equal(t, []uint64{2, 1}, stats.Calls)
+	assert.Equal(t, []uint64{1, 0}, stats.Failed)
+	assert.Equal(t, []uint64{60000, 60000}, stats.GasUsed)
+
+	// Flushing resets the counts.
+	assert.Empty(t, aggregator.Flush().Address)
+}
+
+func TestClickhouseAnnotations(t *testing.T) {
+	trace := newTestTxTrace()
+	hash := trace.TxHash
+	annotations := NewClickhouseAnnotations(trace, []Annotation{
+		{Labels: []string{"exploit", "drainer"}, IncidentId: "INC-1", CreatedAt: 10},
+		{Classification: "false_positive", Source: "triage", CreatedAt: 20},
+	})
+	assert.Equal(t, []string{hash.Hex(), hash.Hex()}, annotations.TxHash)
+	assert.Equal(t, [][]string{{"exploit", "drainer"}, {}}, annotations.Labels)
+	assert.Equal(t, []string{"INC-1", ""}, annotations.IncidentId)
+	assert.Equal(t, []string{"", "false_positive"}, annotations.Classification)
+	assert.Equal(t, []uint64{10, 20}, annotations.CreatedAt)
+}
+
+func TestClickhouseTableSwitches(t *testing.T) {
+	trace := newTestTxTrace()
+	switches := ClickhouseTableSwitches{TableDecodedCallData: false, TableLogs: true}
+	assert.NoError(t, switches.Validate())
+	assert.NotContains(t, switches.Tables(), TableDecodedCallData)
+	assert.Contains(t, switches.Tables(), TableCallActions)
+
+	tables := NewClickhouseTables(trace, switches, nil, nil)
+	assert.Contains(t, tables, TableLogs)
+	assert.Contains(t, tables, TableCallActions)
+	assert.Contains(t, tables, TableCreateActions)
+	// Disabled tables and tables without rows are left out.
+	assert.NotContains(t, tables, TableDecodedCallData)
+	assert.NotContains(t, tables, TableRewardActions)
+	assert.Equal(t, NewClickhouseLogs(trace), tables[TableLogs])
+
+	assert.Error(t, ClickhouseTableSwitches{"steps": false}.Validate())
+}
+
+func TestClickhouseFilter(t *testing.T) {
+	var (
+		trace    = newTestTxTrace()
+		deployed = common.HexToAddress("0x3333333333333333333333333333333333333333")
+	)
+	tests := []struct {
+		filter *ClickhouseFilter
+		want   []uint64
+	}{
+		{filter: nil, want: []uint64{0, 1}},
+		{filter: &ClickhouseFilter{Kinds: []CallKind{CallKindCreate}}, want: []uint64{1}},
+		{filter: &ClickhouseFilter{Kinds: []CallKind{CallKindDelegateCall}}, want: []uint64{}},
+		{filter: &ClickhouseFilter{MinValue: (*hexutil.Big)(big.NewInt(1))}, want: []uint64{}},
+		{filter: &ClickhouseFilter{MinValue: (*hexutil.Big)(big.NewInt(0))}, want: []uint64{0, 1}},
+		{filter: &ClickhouseFilter{Addresses: []common.Address{deployed}}, want: []uint64{1}},
+		{filter: &ClickhouseFilter{Kinds: []CallKind{CallKindCall}, Addresses: []common.Address{deployed}}, want: []uint64{}},
+	}
+	for i, tt := range tests {
+		assert.NoError(t, tt.filter.Validate())
+		filtered := tt.filter.Apply(trace)
+		have := []uint64{}
+		for _, frame := range filtered.Trace {
+			have = append(have, frame.TraceIdx)
+		}
+		assert.Equal(t, tt.want, have, "test %d", i)
+	}
+	assert.Len(t, trace.Trace, 2, "original trace modified")
+
+	tables := NewClickhouseTables(trace, nil, nil, &ClickhouseFilter{Kinds: []CallKind{CallKindCreate}})
+	assert.Contains(t, tables, TableCreateActions)
+	assert.NotContains(t, tables, TableCallActions)
+	assert.NotContains(t, tables, TableLogs)
+
+	assert.Error(t, (&ClickhouseFilter{Kinds: []CallKind{CallKindCreate2}}).Validate())
+}
@@ -0,0 +1,80 @@
+package brontes
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ContractCoverage is the code coverage of a contract within a transaction.
+// Bit i of Bitmap, counting from the most significant bit of the first byte,
+// is set if the instruction at pc i was executed. The init code of a created
+// contract is reported apart from its deployed code, under its own hash.
+type ContractCoverage struct {
+	Address  common.Address `json:"address"`
+	CodeHash common.Hash    `json:"code_hash"`
+	CodeSize int            `json:"code_size"`
+	Bitmap   hexutil.Bytes  `json:"bitmap"`
+}
+
+// Covered reports whether the instruction at the given pc was executed.
+func (c *ContractCoverage) Covered(pc uint64) bool {
+	if pc >= uint64(c.CodeSize) {
+		return false
+	}
+	return c.Bitmap[pc/8]&(0x80>>(pc%8)) != 0
+}
+
+type coverageKey struct {
+	address  common.Address
+	codeHash common.Hash
+}
+
+// coverageRecorder collects the executed pcs of the contracts run by a
+// transaction.
+type coverageRecorder struct {
+	contracts map[coverageKey]*ContractCoverage
+	order     []*ContractCoverage       // in order of first execution
+	frames    map[int]*ContractCoverage // coverage of the code run by each frame
+}
+
+func newCoverageRecorder() *coverageRecorder {
+	return &coverageRecorder{
+		contracts: make(map[coverageKey]*ContractCoverage),
+		frames:    make(map[int]*ContractCoverage),
+	}
+}
+
+// record marks the pc as executed in the code run by the given frame. The
+// code is hashed once per frame, on its first step.
+func (r *coverageRecorder) record(traceIdx int, address common.Address, pc uint64, scope tracing.OpContext) {
+	coverage, ok := r.frames[traceIdx]
+	if !ok {
+		code := scope.ContractCode()
+		key := coverageKey{address: address, codeHash: crypto.Keccak256Hash(code)}
+		if coverage, ok = r.contracts[key]; !ok {
+			coverage = &ContractCoverage{
+				Address:  address,
+				CodeHash: key.codeHash,
+				CodeSize: len(code),
+				Bitmap:   make(hexutil.Bytes, (len(code)+7)/8),
+			}
+			r.contracts[key] = coverage
+			r.order = append(r.order, coverage)
+		}
+		r.frames[traceIdx] = coverage
+	}
+	// Executing past the end of the code is an implicit STOP.
+	if pc < uint64(coverage.CodeSize) {
+		coverage.Bitmap[pc/8] |= 0x80 >> (pc % 8)
+	}
+}
+
+// result returns the coverage of all executed contracts.
+func (r *coverageRecorder) result() []*ContractCoverage {
+	if r == nil {
+		return nil
+	}
+	return r.order
+}
@@ -0,0 +1,59 @@
+package brontes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverage(t *testing.T) {
+	var (
+		from = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		env  = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1}
+		tx   = types.NewTx(&types.LegacyTx{To: &to, Gas: 100000})
+		code = make([]byte, 10)
+	)
+	config := DefaultTracingInspectorConfig
+	config.RecordCoverage = true
+	inspector := NewBrontesInspector(context.Background(), config, params.MainnetChainConfig, env, tx, from)
+
+	// The contract is run twice, by the top-level call and a reentrant call.
+	scope := &testCodeScope{testScope: testScope{address: to}, code: code}
+	require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+	inspector.OnOpcode(0, byte(vm.PUSH1), 100000, 3, scope, nil, 1, nil)
+	require.NoError(t, inspector.OnEnter(1, byte(vm.CALL), to, to, nil, 50000, big.NewInt(0)))
+	inspector.OnOpcode(9, byte(vm.STOP), 50000, 0, scope, nil, 2, nil)
+	inspector.OnOpcode(10, byte(vm.STOP), 50000, 0, scope, nil, 2, nil)
+	inspector.OnExit(1, nil, 0, nil, false)
+	inspector.OnExit(0, nil, 21000, nil, false)
+
+	result, err := inspector.IntoTraceResults(tx, &types.Receipt{}, 0)
+	require.NoError(t, err)
+	require.Len(t, result.Coverage, 1)
+	coverage := result.Coverage[0]
+	assert.Equal(t, to, coverage.Address)
+	assert.Equal(t, 10, coverage.CodeSize)
+	assert.Equal(t, hexutil.Bytes{0x80, 0x40}, coverage.Bitmap)
+	assert.True(t, coverage.Covered(0))
+	assert.True(t, coverage.Covered(9))
+	assert.False(t, coverage.Covered(1))
+	assert.False(t, coverage.Covered(10))
+}
+
+// testCodeScope is a testScope running the given code.
+type testCodeScope struct {
+	testScope
+	code []byte
+}
+
+func (s *testCodeScope) ContractCode() []byte { return s.code }
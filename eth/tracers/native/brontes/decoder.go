@@ -0,0 +1,259 @@
+package brontes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ConstructorFunctionName is the function name reported in the decoded data
+// of create frames.
+const ConstructorFunctionName = "constructor"
+
+// ABIProvider looks up the ABI of a contract. It returns a nil ABI without an
+// error if the contract is unknown.
+type ABIProvider interface {
+	ABI(ctx context.Context, address common.Address) (*abi.ABI, error)
+}
+
+// ABIRegistry is an in-memory ABIProvider keyed by contract address.
+type ABIRegistry struct {
+	abis    map[common.Address]*abi.ABI
+	lock    sync.RWMutex
+	unwatch func() // stops reloading the directory, nil if not watched
+}
+
+func NewABIRegistry() *ABIRegistry {
+	return &ABIRegistry{abis: make(map[common.Address]*abi.ABI)}
+}
+
+// Register sets the ABI of the given contract.
+func (r *ABIRegistry) Register(address common.Address, contractABI *abi.ABI) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.abis[address] = contractABI
+}
+
+// ABI implements ABIProvider.
+func (r *ABIRegistry) ABI(ctx context.Context, address common.Address) (*abi.ABI, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.abis[address], nil
+}
+
+// replace swaps the contents of the registry with those of another.
+func (r *ABIRegistry) replace(other *ABIRegistry) {
+	other.lock.RLock()
+	abis := other.abis
+	other.lock.RUnlock()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.abis = abis
+}
+
+// Len returns the number of registered contracts.
+func (r *ABIRegistry) Len() int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return len(r.abis)
+}
+
+// LoadABIDir reads every <address>.json file of dir into a new registry.
+// Files not named after an address are skipped.
+func LoadABIDir(dir string) (*ABIRegistry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	registry := NewABIRegistry()
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		address := strings.TrimSuffix(name, ".json")
+		if !common.IsHexAddress(address) {
+			continue
+		}
+		blob, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		contractABI, err := abi.JSON(bytes.NewReader(blob))
+		if err != nil {
+			return nil, fmt.Errorf("invalid abi %s: %w", name, err)
+		}
+		registry.Register(common.HexToAddress(address), &contractABI)
+	}
+	return registry, nil
+}
+
+// OpenABIDir loads the registry of the given directory, which is read again
+// whenever its files change until the registry is closed. The registry is
+// updated in place, so the tracers holding it pick the changes up as well.
+func OpenABIDir(dir string) (*ABIRegistry, error) {
+	dir = filepath.Clean(dir)
+	registry, err := LoadABIDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	reload := func() {
+		fresh, err := LoadABIDir(dir)
+		if err != nil {
+			log.Warn("Failed to reload abi directory", "dir", dir, "err", err)
+			return
+		}
+		registry.replace(fresh)
+		log.Info("Reloaded abi directory", "dir", dir, "contracts", fresh.Len())
+	}
+	unwatch, err := watchPath(dir, false, reload)
+	if err != nil {
+		log.Warn("Failed to watch abi directory, changes need a restart", "dir", dir, "err", err)
+	}
+	registry.unwatch = unwatch
+	return registry, nil
+}
+
+// Close stops reloading the directory of the registry.
+func (r *ABIRegistry) Close() {
+	if r.unwatch != nil {
+		r.unwatch()
+	}
+}
+
+var (
+	abiRegistry     *ABIRegistry
+	abiRegistryLock sync.RWMutex
+)
+
+// SetABIRegistry installs the node-wide registry of the ABI directory
+// configured by the operator, used by tracers that opt into decoding with
+// ABIs. Decoding stays disabled until this is called.
+func SetABIRegistry(registry *ABIRegistry) {
+	abiRegistryLock.Lock()
+	defer abiRegistryLock.Unlock()
+	abiRegistry = registry
+}
+
+// RegisteredABIRegistry returns the node-wide ABI registry, or nil if none is
+// set.
+func RegisteredABIRegistry() *ABIRegistry {
+	abiRegistryLock.RLock()
+	defer abiRegistryLock.RUnlock()
+	return abiRegistry
+}
+
+// SplitInitCode splits the init code of a create frame into the creation
+// bytecode and the ABI-encoded constructor arguments appended to it. The
+// deployed runtime code is embedded in the creation bytecode, so everything
+// following its last occurrence is taken to be the arguments. If the deployed
+// code can't be located, the whole init code is returned as bytecode.
+func SplitInitCode(init, deployed []byte) (bytecode []byte, args []byte) {
+	if len(deployed) == 0 {
+		return init, nil
+	}
+	idx := bytes.LastIndex(init, deployed)
+	if idx < 0 {
+		return init, nil
+	}
+	end := idx + len(deployed)
+	return init[:end], init[end:]
+}
+
+// DecodeCallData decodes the input and output of a call against the given ABI.
+// The output is only decoded if present.
+func DecodeCallData(contractABI *abi.ABI, input, output []byte) (*DecodedCallData, error) {
+	if len(input) < 4 {
+		return nil, errors.New("call data too short")
+	}
+	method, err := contractABI.MethodById(input[:4])
+	if err != nil {
+		return nil, err
+	}
+	callData, err := decodeArguments(method.Inputs, input[4:])
+	if err != nil {
+		return nil, err
+	}
+	returnData := make([]DecodedParams, 0)
+	if len(output) > 0 {
+		if returnData, err = decodeArguments(method.Outputs, output); err != nil {
+			return nil, err
+		}
+	}
+	return &DecodedCallData{
+		FunctionName: method.Name,
+		CallData:     callData,
+		ReturnData:   returnData,
+		Provenance:   DecodeProvenanceABI,
+		Confidence:   1,
+	}, nil
+}
+
+// DecodeConstructorArgs decodes ABI-encoded constructor arguments.
+func DecodeConstructorArgs(contractABI *abi.ABI, args []byte) (*DecodedCallData, error) {
+	callData, err := decodeArguments(contractABI.Constructor.Inputs, args)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedCallData{
+		FunctionName: ConstructorFunctionName,
+		CallData:     callData,
+		ReturnData:   make([]DecodedParams, 0),
+		Provenance:   DecodeProvenanceABI,
+		Confidence:   1,
+	}, nil
+}
+
+func decodeArguments(args abi.Arguments, data []byte) ([]DecodedParams, error) {
+	values, err := args.Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+	return formatArguments(args, values), nil
+}
+
+// formatArguments renders the unpacked values of the arguments.
+func formatArguments(args abi.Arguments, values []interface{}) []DecodedParams {
+	params := make([]DecodedParams, 0, len(values))
+	for i, value := range values {
+		params = append(params, DecodedParams{
+			FieldName: args[i].Name,
+			FieldType: args[i].Type.String(),
+			Value:     formatDecodedValue(value),
+		})
+	}
+	return params
+}
+
+// formatDecodedValue renders an unpacked ABI value, printing byte arrays and
+// slices as hex rather than as lists of numbers.
+func formatDecodedValue(value interface{}) string {
+	switch v := value.(type) {
+	case common.Address:
+		return v.Hex()
+	case *big.Int:
+		return v.String()
+	case []byte:
+		return hexutil.Encode(v)
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		buf := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(buf), rv)
+		return hexutil.Encode(buf)
+	}
+	return fmt.Sprint(value)
+}
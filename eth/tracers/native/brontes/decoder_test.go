@@ -0,0 +1,110 @@
+package brontes
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testABI = `[
+	{"type":"constructor","inputs":[{"name":"owner","type":"address"},{"name":"supply","type":"uint256"}]},
+	{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}
+]`
+
+func TestSplitInitCode(t *testing.T) {
+	var (
+		deployed = []byte{0x60, 0x80, 0x60, 0x40}
+		args     = common.LeftPadBytes([]byte{0x2a}, 32)
+		init     = append(append([]byte{0x61, 0x00, 0x04}, deployed...), args...)
+	)
+	bytecode, have := SplitInitCode(init, deployed)
+	assert.Equal(t, init[:len(init)-32], bytecode)
+	assert.Equal(t, args, have)
+
+	bytecode, have = SplitInitCode(init, []byte{0xff})
+	assert.Equal(t, init, bytecode)
+	assert.Nil(t, have)
+}
+
+func TestDecodeCallData(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testABI))
+	require.NoError(t, err)
+
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	input, err := contractABI.Pack("transfer", to, big.NewInt(1000))
+	require.NoError(t, err)
+	output := common.LeftPadBytes([]byte{1}, 32)
+
+	decoded, err := DecodeCallData(&contractABI, input, output)
+	require.NoError(t, err)
+	assert.Equal(t, "transfer", decoded.FunctionName)
+	assert.Equal(t, DecodeProvenanceABI, decoded.Provenance)
+	assert.Equal(t, 1.0, decoded.Confidence)
+	assert.Equal(t, []DecodedParams{
+		{FieldName: "to", FieldType: "address", Value: to.Hex()},
+		{FieldName: "amount", FieldType: "uint256", Value: "1000"},
+	}, decoded.CallData)
+	assert.Equal(t, []DecodedParams{{FieldName: "", FieldType: "bool", Value: "true"}}, decoded.ReturnData)
+
+	args, err := contractABI.Pack("", to, big.NewInt(7))
+	require.NoError(t, err)
+	decoded, err = DecodeConstructorArgs(&contractABI, args)
+	require.NoError(t, err)
+	assert.Equal(t, ConstructorFunctionName, decoded.FunctionName)
+	assert.Equal(t, "7", decoded.CallData[1].Value)
+}
+
+func TestLoadABIDir(t *testing.T) {
+	dir := t.TempDir()
+	address := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, address.Hex()+".json"), []byte(testABI), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.json"), []byte("not an abi"), 0644))
+
+	registry, err := LoadABIDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, registry.Len())
+
+	contractABI, err := registry.ABI(context.Background(), address)
+	require.NoError(t, err)
+	require.NotNil(t, contractABI)
+	assert.Contains(t, contractABI.Methods, "transfer")
+}
+
+func TestOpenABIDirReload(t *testing.T) {
+	defer func(delay time.Duration) { reloadDelay = delay }(reloadDelay)
+	reloadDelay = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	registry, err := OpenABIDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 0, registry.Len())
+
+	// Contracts added to the directory are picked up by the held registry.
+	address := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, address.Hex()+".json"), []byte(testABI), 0644))
+	require.Eventually(t, func() bool { return registry.Len() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// Broken files keep the previous contents.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, address.Hex()+".json"), []byte("{"), 0644))
+	time.Sleep(100 * time.Millisecond)
+	contractABI, err := registry.ABI(context.Background(), address)
+	require.NoError(t, err)
+	assert.NotNil(t, contractABI)
+
+	// Closed registries are no longer reloaded.
+	registry.Close()
+	other := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, address.Hex()+".json"), []byte(testABI), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, other.Hex()+".json"), []byte(testABI), 0644))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, registry.Len())
+}
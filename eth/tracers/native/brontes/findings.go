@@ -0,0 +1,98 @@
+package brontes
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Kinds of findings besides the alert kinds.
+const (
+	FindingReentrancy        = "reentrancy"
+	FindingUncheckedCall     = "unchecked_call"
+	FindingUntrustedDelegate = "untrusted_delegate"
+	FindingCoinbaseBribe     = "coinbase_bribe"
+)
+
+// Finding is a noteworthy pattern found by an analyzer in a transaction,
+// the unit published to alerting sinks.
+type Finding struct {
+	Kind        string      `json:"kind"`
+	ChainId     uint64      `json:"chain_id"`
+	BlockNumber uint64      `json:"block_number"`
+	TxHash      common.Hash `json:"tx_hash"`
+	TxIndex     int         `json:"tx_index"`
+	// TraceIdx lists the frames involved, in execution order.
+	TraceIdx []uint64 `json:"trace_idx,omitempty"`
+	// Details holds the record of the analyzer, such as an Alert or a
+	// Reentrancy.
+	Details interface{} `json:"details,omitempty"`
+}
+
+// newFinding creates a finding of a transaction.
+func newFinding(value *TxTrace, kind string, traceIdx []uint64, details interface{}) Finding {
+	return Finding{
+		Kind:        kind,
+		ChainId:     value.ChainId,
+		BlockNumber: value.BlockNumber,
+		TxHash:      value.TxHash,
+		TxIndex:     value.TxIndex,
+		TraceIdx:    traceIdx,
+		Details:     details,
+	}
+}
+
+// Findings collects the findings of the analyzers that annotated the trace.
+func Findings(value *TxTrace) []Finding {
+	var findings []Finding
+	for _, alert := range value.Alerts {
+		findings = append(findings, newFinding(value, alert.Kind, alert.TraceIdx, alert))
+	}
+	for _, reentrancy := range value.Reentrancies {
+		findings = append(findings, newFinding(value, FindingReentrancy, reentrancy.Chain, reentrancy))
+	}
+	for _, frame := range value.Trace {
+		if frame.UncheckedCall {
+			findings = append(findings, newFinding(value, FindingUncheckedCall, []uint64{frame.TraceIdx}, nil))
+		}
+		if len(frame.UntrustedTarget) > 0 {
+			findings = append(findings, newFinding(value, FindingUntrustedDelegate, []uint64{frame.TraceIdx}, frame.UntrustedTarget))
+		}
+	}
+	return findings
+}
+
+// CoinbaseBribe is the value paid to the fee recipient of a block by a
+// transaction through plain transfers, outside of the priority fee.
+type CoinbaseBribe struct {
+	Coinbase common.Address `json:"coinbase"`
+	Value    *big.Int       `json:"value"`
+}
+
+// FindCoinbaseBribe returns a finding if the frames of a trace that were not
+// reverted transfer at least min wei to the coinbase, nil otherwise.
+func FindCoinbaseBribe(value *TxTrace, coinbase common.Address, min *big.Int) *Finding {
+	var (
+		reverted = revertedFrames(value)
+		total    = new(big.Int)
+		frames   []uint64
+	)
+	for i := range value.Trace {
+		frame := &value.Trace[i]
+		if reverted[i] || frame.Trace.Type != ActionTypeCall {
+			continue
+		}
+		// Call codes and delegate calls keep the value in the caller.
+		call := frame.Trace.Action.Call
+		if call.CallType != CallKindCall || call.To != coinbase || call.Value == nil || call.Value.Sign() <= 0 {
+			continue
+		}
+		total.Add(total, call.Value)
+		frames = append(frames, frame.TraceIdx)
+	}
+	if len(frames) == 0 || total.Cmp(min) < 0 {
+		return nil
+	}
+	finding := newFinding(value, FindingCoinbaseBribe, frames, CoinbaseBribe{Coinbase: coinbase, Value: total})
+	return &finding
+}
@@ -0,0 +1,50 @@
+package brontes
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindings(t *testing.T) {
+	var (
+		searcher = common.HexToAddress("0x01")
+		bot      = common.HexToAddress("0x02")
+		coinbase = common.HexToAddress("0xc0")
+		failed   = "execution reverted"
+	)
+	trace := &TxTrace{
+		ChainId:     1,
+		BlockNumber: 100,
+		TxHash:      common.HexToHash("0xaa"),
+		TxIndex:     2,
+		Trace: []TransactionTraceWithLogs{
+			callFrame(0, CallKindCall, searcher, bot),
+			callFrame(1, CallKindCall, bot, coinbase, 0),
+			callFrame(2, CallKindCall, bot, coinbase, 1),
+			callFrame(3, CallKindCall, bot, coinbase, 2),
+		},
+		Alerts: []Alert{{Kind: AlertTokenDrain, TraceIdx: []uint64{1, 2}}},
+	}
+	trace.Trace[1].Trace.Action.Call.Value = big.NewInt(3)
+	trace.Trace[2].Trace.Action.Call.Value = big.NewInt(4)
+	trace.Trace[2].UncheckedCall = true
+	// Reverted transfers do not reach the coinbase.
+	trace.Trace[3].Trace.Action.Call.Value = big.NewInt(100)
+	trace.Trace[3].Trace.Error = &failed
+
+	findings := Findings(trace)
+	require.Len(t, findings, 2)
+	assert.Equal(t, AlertTokenDrain, findings[0].Kind)
+	assert.Equal(t, trace.Alerts[0], findings[0].Details)
+	assert.Equal(t, Finding{Kind: FindingUncheckedCall, ChainId: 1, BlockNumber: 100, TxHash: trace.TxHash, TxIndex: 2, TraceIdx: []uint64{2}}, findings[1])
+
+	bribe := FindCoinbaseBribe(trace, coinbase, big.NewInt(7))
+	require.NotNil(t, bribe)
+	assert.Equal(t, []uint64{1, 2}, bribe.TraceIdx)
+	assert.Equal(t, CoinbaseBribe{Coinbase: coinbase, Value: big.NewInt(7)}, bribe.Details)
+	assert.Nil(t, FindCoinbaseBribe(trace, coinbase, big.NewInt(8)))
+}
@@ -0,0 +1,37 @@
+package brontes
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// CanonicalJSON returns the canonical encoding of the trace: the latest schema
+// version without the annotations that depend on the configuration of the
+// tracing node, i.e. address names and decoded call data. Nodes tracing the
+// same transaction with the same recording options produce identical bytes.
+func (t *TxTrace) CanonicalJSON() ([]byte, error) {
+	cpy := *t
+	cpy.SchemaVersion = LatestSchemaVersion
+	cpy.AddressNames = nil
+	cpy.Trace = make([]TransactionTraceWithLogs, len(t.Trace))
+	for i, frame := range t.Trace {
+		frame.DecodedData = nil
+		cpy.Trace[i] = frame
+	}
+	// The encoding of structs follows the field order and maps are sorted by
+	// key, so plain JSON encoding is deterministic.
+	return json.Marshal(&cpy)
+}
+
+// TraceHash returns the keccak256 hash of the canonical encoding of the trace,
+// for checking the integrity of exported traces and deduplicating the traces
+// of multiple exporting nodes.
+func (t *TxTrace) TraceHash() (common.Hash, error) {
+	enc, err := t.CanonicalJSON()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(enc), nil
+}
@@ -0,0 +1,41 @@
+package brontes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceHash(t *testing.T) {
+	trace := newTestTxTrace()
+	hash, err := trace.TraceHash()
+	require.NoError(t, err)
+
+	// The hash survives an export round trip.
+	enc, err := trace.MarshalSchema(LatestSchemaVersion)
+	require.NoError(t, err)
+	var decoded TxTrace
+	require.NoError(t, json.Unmarshal(enc, &decoded))
+	have, err := decoded.TraceHash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, have)
+
+	// Node local annotations do not affect the hash.
+	annotated := newTestTxTrace()
+	annotated.AddressNames = map[common.Address]string{{}: "zero"}
+	annotated.Trace[0].DecodedData = &DecodedCallData{FunctionName: "transfer"}
+	have, err = annotated.TraceHash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, have)
+	assert.NotNil(t, annotated.Trace[0].DecodedData, "trace modified")
+
+	// Any change to the execution does.
+	modified := newTestTxTrace()
+	modified.Trace[0].Trace.Result.Call.GasUsed++
+	have, err = modified.TraceHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, have)
+}
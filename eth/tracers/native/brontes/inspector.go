@@ -1,10 +1,13 @@
 package brontes
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
 	"slices"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/tracing"
@@ -25,13 +28,148 @@ const (
 )
 
 type TracingInspectorConfig struct {
-	RecordSteps            bool
-	RecordMemorySnapshots  bool
-	RecordStackSnapshots   StackSnapshotType
-	RecordStateDiff        bool
-	ExcludePrecompileCalls bool
-	RecordCallReturnData   bool
-	RecordLogs             bool
+	RecordSteps            bool              `json:"recordSteps"`
+	RecordMemorySnapshots  bool              `json:"recordMemorySnapshots"`
+	RecordStackSnapshots   StackSnapshotType `json:"recordStackSnapshots"`
+	RecordStateDiff        bool              `json:"recordStateDiff"`
+	ExcludePrecompileCalls bool              `json:"excludePrecompileCalls"`
+	RecordCallReturnData   bool              `json:"recordCallReturnData"`
+	RecordLogs             bool              `json:"recordLogs"`
+	// RecordOpcodeSummary keeps per-frame execution counters without
+	// recording individual steps.
+	RecordOpcodeSummary bool `json:"recordOpcodeSummary"`
+	// RecordStorageAccess keeps the set of storage slots (persistent and
+	// transient) read and written by each frame without recording individual
+	// steps.
+	RecordStorageAccess bool `json:"recordStorageAccess"`
+	// DecodeABIs decodes call data and constructor arguments with the
+	// node-wide ABIRegistry, loaded from the ABI directory configured by the
+	// operator. It has no effect unless the node installed a registry.
+	DecodeABIs bool `json:"decodeAbis,omitempty"`
+	// FetchABIs falls back to the node-wide ABIFetcher for contracts missing
+	// from the registry. It has no effect unless the node installed a fetcher.
+	FetchABIs bool `json:"fetchAbis,omitempty"`
+	// ResolveSelectors decodes the calls to contracts without a known ABI
+	// after the signature of their selector, looked up through the node-wide
+	// SelectorCache. It has no effect unless the node installed a cache.
+	ResolveSelectors bool `json:"resolveSelectors,omitempty"`
+	// AttachOrderflow attaches the orderflow metadata of the transaction,
+	// such as its bundle, looked up through the node-wide OrderflowStore. It
+	// has no effect unless the node installed a store.
+	AttachOrderflow bool `json:"attachOrderflow,omitempty"`
+	// ResolveNames attaches the names of the senders and recipients of the
+	// frames found in the node-wide address book, configured by the
+	// operator. It has no effect unless the node installed an address book.
+	ResolveNames bool `json:"resolveNames,omitempty"`
+	// ResolveENS names addresses by their ENS reverse records, read from the
+	// traced state.
+	ResolveENS bool `json:"resolveEns,omitempty"`
+	// OutputMode selects the shape of the result, OutputModeFull if empty.
+	OutputMode OutputMode `json:"outputMode,omitempty"`
+	// StepMemoryBudget bounds the memory, in bytes, held by the recorded steps
+	// of a transaction. Steps beyond the budget are spilled to a temporary
+	// file. Zero keeps all steps in memory.
+	StepMemoryBudget uint64 `json:"stepMemoryBudget,omitempty"`
+	// SchemaVersion selects the wire format of the full output mode, the
+	// latest if zero.
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+	// Redact replaces the call data, return data and code of the selected
+	// frames by their hash, and drops what is decoded from them.
+	Redact *RedactionConfig `json:"redact,omitempty"`
+	// RecordWitness keeps the state accessed by the transaction, so it can be
+	// re-executed statelessly.
+	RecordWitness bool `json:"recordWitness,omitempty"`
+	// RecordCoverage keeps a bitmap of the executed pcs of every contract run
+	// by the transaction.
+	RecordCoverage bool `json:"recordCoverage,omitempty"`
+	// RecordRefunds attributes the gas refund to the SSTOREs granting it.
+	RecordRefunds bool `json:"recordRefunds,omitempty"`
+	// DetectUncheckedCalls flags the calls whose success flag is ignored by
+	// the caller.
+	DetectUncheckedCalls bool `json:"detectUncheckedCalls,omitempty"`
+	// DetectReentrancy flags the frames re-entering a contract active on the
+	// call stack.
+	DetectReentrancy bool `json:"detectReentrancy,omitempty"`
+	// DetectUntrustedDelegates flags the delegate calls whose target is taken
+	// from call data or from storage written in the same transaction.
+	DetectUntrustedDelegates bool `json:"detectUntrustedDelegates,omitempty"`
+	// DetectDrainers raises alerts on approvals to contracts deployed in the
+	// transaction and on transfers of many tokens from the same owner.
+	DetectDrainers bool `json:"detectDrainers,omitempty"`
+	// StepOpcodeClasses restricts the recorded steps to the opcodes of the
+	// given classes, all steps being recorded if empty. It has no effect
+	// unless RecordSteps is set.
+	StepOpcodeClasses []OpcodeClass `json:"stepOpcodeClasses,omitempty"`
+	// StepSampling records only the first and last steps of every frame,
+	// all steps being recorded if unset. It applies to the steps left by
+	// StepOpcodeClasses and has no effect unless RecordSteps is set.
+	StepSampling *StepSampling `json:"stepSampling,omitempty"`
+	// CaptureOpcodes records the steps of only the named opcodes, such as
+	// "CALL" or "SSTORE", with full stack and memory snapshots whatever the
+	// snapshot settings. It implies RecordSteps and replaces
+	// StepOpcodeClasses, which must be left empty.
+	CaptureOpcodes []string `json:"captureOpcodes,omitempty"`
+	// Projection prunes the fields of the result, which is returned whole if
+	// unset.
+	Projection *Projection `json:"projection,omitempty"`
+	// NumberEncoding encodes all numeric fields of full and flat results
+	// alike, either as hex quantities or decimal strings. Numbers are encoded
+	// as in the original brontes format if unset.
+	NumberEncoding NumberEncoding `json:"numberEncoding,omitempty"`
+	// TransferFlow extracts the value and token transfers of full results,
+	// either the ones that took effect or all attempted ones. No transfers
+	// are extracted if unset.
+	TransferFlow TransferFlow `json:"transferFlow,omitempty"`
+	// PartialResults returns the frames traced before a failure in full
+	// results, listing what went wrong in their errors field, instead of
+	// failing the whole trace.
+	PartialResults bool `json:"partialResults,omitempty"`
+}
+
+// OutputMode is the shape of the tracer result.
+type OutputMode string
+
+const (
+	// OutputModeFull emits a TxTrace with the frames, their logs and
+	// annotations.
+	OutputModeFull OutputMode = "full"
+	// OutputModeFlat emits a FlatTxTrace holding only the parity style
+	// actions of the frames.
+	OutputModeFlat OutputMode = "flat"
+	// OutputModeArena emits an ArenaTxTrace holding the recorded call tree
+	// as is, for consumers processing the internal representation.
+	OutputModeArena OutputMode = "arena"
+)
+
+// Validate checks the config for unsupported values.
+func (c *TracingInspectorConfig) Validate() error {
+	switch c.OutputMode {
+	case "", OutputModeFull, OutputModeFlat, OutputModeArena:
+	default:
+		return fmt.Errorf("unsupported output mode %q", c.OutputMode)
+	}
+	if _, err := newOpcodeFilter(c.StepOpcodeClasses); err != nil {
+		return err
+	}
+	if _, err := newCaptureFilter(c.CaptureOpcodes); err != nil {
+		return err
+	}
+	if len(c.CaptureOpcodes) > 0 && len(c.StepOpcodeClasses) > 0 {
+		return errors.New("captureOpcodes and stepOpcodeClasses are mutually exclusive")
+	}
+	if err := c.StepSampling.Validate(); err != nil {
+		return err
+	}
+	if err := c.Projection.Validate(); err != nil {
+		return err
+	}
+	if err := c.NumberEncoding.Validate(); err != nil {
+		return err
+	}
+	if err := c.TransferFlow.Validate(); err != nil {
+		return err
+	}
+	return ValidateSchemaVersion(c.SchemaVersion)
 }
 
 // As is in the brontes code.
@@ -43,6 +181,8 @@ var DefaultTracingInspectorConfig = TracingInspectorConfig{
 	ExcludePrecompileCalls: true,
 	RecordCallReturnData:   true,
 	RecordLogs:             true,
+	RecordOpcodeSummary:    false,
+	RecordStorageAccess:    false,
 }
 
 type StackStep struct {
@@ -57,27 +197,112 @@ type BrontesInspector struct {
 	StepStack          []StackStep
 	LastCallReturnData *[]byte
 	SpecId             *forks.Fork
+	ChainId            uint64
 	ActivePrecompiles  map[common.Address]struct{}
 	Transaction        *types.Transaction
 	VMContext          *tracing.VMContext
 	From               common.Address
+	// BlockHash and ParentHash locate the block of the transaction, set by the
+	// tracer if known.
+	BlockHash  common.Hash
+	ParentHash common.Hash
+	// CreatedContracts holds the addresses deployed within the transaction,
+	// which are the only ones a post-Cancun selfdestruct removes.
+	CreatedContracts map[common.Address]struct{}
+	// ABIs is consulted to decode call frames if set.
+	ABIs ABIProvider
+	// Selectors is consulted to decode the call frames ABIs cannot decode if
+	// set.
+	Selectors SelectorResolver
+	// Orderflow is consulted for the orderflow metadata of the transaction
+	// if set.
+	Orderflow OrderflowProvider
+	// Names labels the addresses of the trace if set.
+	Names NameResolver
+	// OnProgress is called every ProgressInterval (DefaultProgressInterval if
+	// zero) while the transaction executes, if set. It runs on the execution
+	// goroutine and must not block.
+	OnProgress       func(TraceProgress)
+	ProgressInterval time.Duration
+	progress         progressTracker
+
+	lock       sync.RWMutex      // guards the arena against concurrent snapshots
+	ctx        context.Context   // aborts building the results once cancelled
+	stepMemory uint64            // approximate size of the steps held in memory
+	spill      *StepSpill        // steps beyond the memory budget, created on demand
+	spillErr   error             // set if steps could not be spilled
+	witness    *StateWitness     // nil unless the witness is recorded
+	coverage   *coverageRecorder // nil unless coverage is recorded
+	refunds    *refundRecorder   // nil unless refunds are recorded
+	unchecked  *uncheckedCallDetector
+	delegates  *delegateTargetDetector
+	stepFilter *opcodeFilter // nil if steps of all opcodes are recorded
+	errors     []TraceError  // failures reported along with the results
+
+	// pendingSteps holds the last step of every active frame until the
+	// memory size after it is known.
+	pendingSteps map[int]CallTraceStep
+	sampler      *stepSampler // nil unless steps are sampled
+
+	stateDiff              *stateDiffRecorder // nil unless the state diff is recorded
+	stateDiffDiscrepancies []StateDiffDiscrepancy
 }
 
+// NewBrontesInspector creates an inspector for a single transaction. Once ctx
+// is cancelled, building the trace results stops with the cancellation cause.
 func NewBrontesInspector(
+	ctx context.Context,
 	config TracingInspectorConfig,
 	chainConfig *params.ChainConfig,
 	env *tracing.VMContext,
 	tx *types.Transaction,
 	from common.Address,
 ) *BrontesInspector {
-	activePrecompiles := make(map[common.Address]struct{})
 	rules := chainConfig.Rules(env.BlockNumber, env.Random != nil, env.Time, env.ArbOSVersion)
-	precompiles := vm.ActivePrecompiles(rules)
-	for _, precompile := range precompiles {
-		activePrecompiles[precompile] = struct{}{}
+	specId := SpecIdFromRules(rules)
+
+	// The sender, recipient and coinbase are modified outside of the call
+	// frames, record them before the transaction executes.
+	var witness *StateWitness
+	if config.RecordWitness && env.StateDB != nil {
+		witness = NewStateWitness()
+		witness.touchAccount(env.StateDB, from)
+		if to := tx.To(); to != nil {
+			witness.touchAccount(env.StateDB, *to)
+		}
+		witness.touchAccount(env.StateDB, env.Coinbase)
+	}
+	var coverage *coverageRecorder
+	if config.RecordCoverage {
+		coverage = newCoverageRecorder()
+	}
+	var refunds *refundRecorder
+	if config.RecordRefunds && env.StateDB != nil {
+		refunds = newRefundRecorder(env.StateDB)
+	}
+	var stateDiff *stateDiffRecorder
+	if config.RecordStateDiff && env.StateDB != nil {
+		stateDiff = newStateDiffRecorder(env.StateDB)
+		stateDiff.observe(env.StateDB, from)
+		if to := tx.To(); to != nil {
+			stateDiff.observe(env.StateDB, *to)
+		}
+		stateDiff.observe(env.StateDB, env.Coinbase)
+	}
+	var unchecked *uncheckedCallDetector
+	if config.DetectUncheckedCalls {
+		unchecked = newUncheckedCallDetector()
+	}
+	var delegates *delegateTargetDetector
+	if config.DetectUntrustedDelegates {
+		delegates = newDelegateTargetDetector()
+	}
+	// Unknown classes and opcodes are rejected by Validate.
+	stepFilter, _ := newOpcodeFilter(config.StepOpcodeClasses)
+	if len(config.CaptureOpcodes) > 0 {
+		config.RecordSteps = true
+		stepFilter, _ = newCaptureFilter(config.CaptureOpcodes)
 	}
-	specId := chainConfig.LatestFork(env.Time, env.ArbOSVersion)
-
 	return &BrontesInspector{
 		Config:             config,
 		Traces:             NewCallTraceArena(),
@@ -85,13 +310,50 @@ func NewBrontesInspector(
 		StepStack:          make([]StackStep, 0),
 		LastCallReturnData: nil,
 		SpecId:             &specId,
-		ActivePrecompiles:  activePrecompiles,
+		ChainId:            rules.ChainID.Uint64(),
+		ActivePrecompiles:  activePrecompileSet(rules),
 		VMContext:          env,
 		Transaction:        tx,
 		From:               from,
+		CreatedContracts:   make(map[common.Address]struct{}),
+		ctx:                ctx,
+		witness:            witness,
+		coverage:           coverage,
+		refunds:            refunds,
+		unchecked:          unchecked,
+		delegates:          delegates,
+		stepFilter:         stepFilter,
+		pendingSteps:       make(map[int]CallTraceStep),
+		sampler:            newStepSampler(config.StepSampling),
+		stateDiff:          stateDiff,
 	}
 }
 
+// interrupted returns the cancellation cause of the inspector context, if any.
+func (b *BrontesInspector) interrupted() error {
+	if b.ctx == nil || b.ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(b.ctx)
+}
+
+// RecordError notes a failure of the given stage, which is reported along
+// with the partial results. The trace index is that of the frame the failure
+// occurred at, if known.
+func (b *BrontesInspector) RecordError(stage TraceErrorStage, err error, traceIdx *uint64) {
+	b.errors = append(b.errors, TraceError{Stage: stage, Message: err.Error(), TraceIdx: traceIdx})
+}
+
+// RecordPanic notes a panic of the given tracer hook, recovered with the
+// stack trace of the tracer, which is reported along with the results.
+func (b *BrontesInspector) RecordPanic(hook string, value interface{}, stack []byte) {
+	b.errors = append(b.errors, TraceError{
+		Stage:   TraceErrorStageExecution,
+		Message: fmt.Sprintf("%s panicked: %v", hook, value),
+		Stack:   string(stack),
+	})
+}
+
 func (insp *BrontesInspector) IsDeep() bool {
 	return len(insp.TraceStack) != 0
 }
@@ -141,9 +403,20 @@ func (b *BrontesInspector) startTraceOnCall(address common.Address, inputData []
 		selfDestructRefundTarget = &refundAddr
 	}
 
+	// Delegate calls run the callee's code against the caller's account, and
+	// a selfdestruct acts on the destructing contract rather than the target.
+	codeAddress, contextAddress := address, address
+	if kind.IsDelegate() {
+		contextAddress = caller
+	} else if kind.IsSelfDestruct() {
+		codeAddress, contextAddress = caller, caller
+	}
+
 	trace := CallTrace{
 		Depth:                    depth,
 		Address:                  address,
+		CodeAddress:              codeAddress,
+		ContextAddress:           contextAddress,
 		Kind:                     kind,
 		Data:                     inputData,
 		Value:                    value,
@@ -152,10 +425,36 @@ func (b *BrontesInspector) startTraceOnCall(address common.Address, inputData []
 		GasLimit:                 gasLimit,
 		SelfDestructRefundTarget: selfDestructRefundTarget,
 	}
-	traceIdx := b.Traces.PushTrace(0, pushKind, trace)
+	if b.Config.RecordOpcodeSummary {
+		trace.Summary = NewFrameSummary(depth)
+	}
+	if b.Config.RecordStorageAccess {
+		trace.StorageAccess = NewStorageAccess(contextAddress)
+	}
+	// Attach the frame to the active one, which is its caller.
+	parent := 0
+	if len(b.TraceStack) > 0 {
+		parent = b.TraceStack[len(b.TraceStack)-1]
+		trace.CallerStep = b.callerStep(parent)
+	}
+	traceIdx := b.Traces.PushTrace(parent, pushKind, trace)
 	b.TraceStack = append(b.TraceStack, traceIdx)
 }
 
+// callerStep returns the index the step of the parent entering a new frame
+// is recorded at, which is still pending. Steps held by sampling may be left
+// out, so their index is unknown.
+func (b *BrontesInspector) callerStep(parent int) *int {
+	if _, ok := b.pendingSteps[parent]; !ok {
+		return nil
+	}
+	idx := b.Traces.Arena[parent].Trace.StepCount()
+	if b.sampler != nil && idx >= b.sampler.head {
+		return nil
+	}
+	return &idx
+}
+
 func (b *BrontesInspector) fillTraceOnCallEnd(gasUsed uint64, err error, reverted bool, output []byte) {
 	traceIdx := b.popTraceIdx()
 	trace := &b.Traces.Arena[traceIdx].Trace
@@ -163,9 +462,17 @@ func (b *BrontesInspector) fillTraceOnCallEnd(gasUsed uint64, err error, reverte
 	trace.GasUsed = gasUsed
 	trace.Success = !reverted
 	trace.Output = output
+	// Frames stopped by REVERT keep their output, unlike other failures.
+	trace.Error = err
+	trace.Reverted = errors.Is(err, vm.ErrExecutionReverted)
 
 	b.LastCallReturnData = &output
 
+	// Propagate the deepest reached call depth to the enclosing frame.
+	if parent := b.ActiveTrace(); parent != nil && trace.Summary != nil && parent.Trace.Summary != nil {
+		parent.Trace.Summary.MaxDepth = max(parent.Trace.Summary.MaxDepth, trace.Summary.MaxDepth)
+	}
+
 	// if createdAddress != nil {
 	// 	trace.Address = *createdAddress
 	// }
@@ -176,17 +483,20 @@ func (b *BrontesInspector) startStep(pc uint64, op byte, gas, cost uint64, scope
 	traceIdx := b.lastTraceIdx()
 	traceNode := &b.Traces.Arena[traceIdx]
 
-	stepIdx := len(traceNode.Trace.Steps)
+	stepIdx := traceNode.Trace.StepCount()
 	b.StepStack = append(b.StepStack, StackStep{TraceIdx: traceIdx, StepIdx: stepIdx})
 
+	// Captured opcodes are always recorded with full snapshots.
+	capture := len(b.Config.CaptureOpcodes) > 0
+
 	var recordedMemory RecordedMemory
-	if b.Config.RecordMemorySnapshots {
-		recordedMemory = RecordedMemory{Data: scope.MemoryData()}
+	if b.Config.RecordMemorySnapshots || capture {
+		recordedMemory = RecordedMemory{Data: slices.Clone(scope.MemoryData())}
 	}
 
 	var stackData []uint256.Int
-	if b.Config.RecordStackSnapshots == StackSnapshotTypeFull {
-		stackData = scope.StackData()
+	if b.Config.RecordStackSnapshots == StackSnapshotTypeFull || capture {
+		stackData = slices.Clone(scope.StackData())
 	}
 
 	// Leaving out Stack and Memory snapshots empty for now.
@@ -198,35 +508,229 @@ func (b *BrontesInspector) startStep(pc uint64, op byte, gas, cost uint64, scope
 		Contract:         scope.Address(),
 		Stack:            &stackData,
 		PushStack:        nil,
-		MemorySize:       0,
+		MemorySize:       len(scope.MemoryData()),
 		Memory:           recordedMemory,
 		GasRemaining:     gas,
 		GasRefundCounter: 0,
 		GasCost:          cost,
-		StorageChange:    nil,
+		StorageChange:    b.storageChange(vm.OpCode(op), scope),
+	}
+	// The log of a LOG step is appended to the frame once the step executes.
+	if opcode := vm.OpCode(op); opcode >= vm.LOG0 && opcode <= vm.LOG4 {
+		logIndex := len(traceNode.Logs)
+		step.LogIndex = &logIndex
 	}
+	b.pendingSteps[traceIdx] = step
+}
 
-	traceNode.Trace.Steps = append(traceNode.Trace.Steps, step)
+// settleStep records the pending step of the active frame, now that the
+// memory size after it is known. The memory of exited frames is discarded, so
+// their last step is recorded without expansion, along with the last steps
+// held by sampling.
+func (b *BrontesInspector) settleStep(memorySize int, exited bool) {
+	traceIdx := b.lastTraceIdx()
+	if step, ok := b.pendingSteps[traceIdx]; ok {
+		delete(b.pendingSteps, traceIdx)
+		step.MemorySizeAfter = memorySize
+		if exited {
+			step.MemorySizeAfter = step.MemorySize
+		}
+		b.keepStep(traceIdx, step)
+	}
+	if exited && b.sampler != nil {
+		node := &b.Traces.Arena[traceIdx]
+		for _, step := range b.sampler.flush(traceIdx) {
+			b.recordStep(node, step)
+		}
+	}
 }
 
+// recordOpcodeSummary updates the execution counters of the active frame.
+func (b *BrontesInspector) recordOpcodeSummary(op byte, scope tracing.OpContext) {
+	summary := b.Traces.Arena[b.lastTraceIdx()].Trace.Summary
+	if summary == nil {
+		return
+	}
+	opcode := vm.OpCode(op)
+	summary.OpcodeCounts[opcode.String()]++
+	switch opcode {
+	case vm.SLOAD:
+		summary.SloadCount++
+	case vm.SSTORE:
+		summary.SstoreCount++
+	}
+	if size := uint64(len(scope.MemoryData())); size > summary.MemoryHighWater {
+		summary.MemoryHighWater = size
+	}
+}
+
+// recordStorageAccess adds the slot touched by a storage opcode to the access
+// set of the active frame.
+func (b *BrontesInspector) recordStorageAccess(op byte, scope tracing.OpContext) {
+	access := b.Traces.Arena[b.lastTraceIdx()].Trace.StorageAccess
+	if access == nil {
+		return
+	}
+	stack := scope.StackData()
+	if len(stack) == 0 {
+		return
+	}
+	slot := common.Hash(stack[len(stack)-1].Bytes32())
+	switch vm.OpCode(op) {
+	case vm.SLOAD:
+		access.AddRead(slot)
+	case vm.SSTORE:
+		access.AddWrite(slot)
+	case vm.TLOAD:
+		access.AddTransientRead(slot)
+	case vm.TSTORE:
+		access.AddTransientWrite(slot)
+	}
+}
+
+// isStorageOp returns true for opcodes accessing persistent or transient storage.
+func isStorageOp(op vm.OpCode) bool {
+	return op == vm.SLOAD || op == vm.SSTORE || op == vm.TLOAD || op == vm.TSTORE
+}
+
+// storageChange captures the storage slot accessed by the given opcode before
+// it executes. It returns nil for other opcodes or if the state is unavailable.
+func (b *BrontesInspector) storageChange(op vm.OpCode, scope tracing.OpContext) *StorageChange {
+	if !isStorageOp(op) || b.VMContext == nil || b.VMContext.StateDB == nil {
+		return nil
+	}
+	stack := scope.StackData()
+	if len(stack) == 0 || ((op == vm.SSTORE || op == vm.TSTORE) && len(stack) < 2) {
+		return nil
+	}
+	var (
+		statedb = b.VMContext.StateDB
+		address = scope.Address()
+		key     = common.Hash(stack[len(stack)-1].Bytes32())
+	)
+	switch op {
+	case vm.SLOAD:
+		return &StorageChange{
+			Key:    key.Big(),
+			Value:  statedb.GetState(address, key).Big(),
+			Reason: StorageChangeReasonSLOAD,
+		}
+	case vm.SSTORE:
+		return &StorageChange{
+			Key:      key.Big(),
+			Value:    stack[len(stack)-2].ToBig(),
+			HadValue: statedb.GetState(address, key).Big(),
+			Reason:   StorageChangeReasonSSTORE,
+		}
+	case vm.TLOAD:
+		return &StorageChange{
+			Key:    key.Big(),
+			Value:  statedb.GetTransientState(address, key).Big(),
+			Reason: StorageChangeReasonTLOAD,
+		}
+	default:
+		return &StorageChange{
+			Key:      key.Big(),
+			Value:    stack[len(stack)-2].ToBig(),
+			HadValue: statedb.GetTransientState(address, key).Big(),
+			Reason:   StorageChangeReasonTSTORE,
+		}
+	}
+}
+
+// IntoTraceResults converts the recorded frames into the full result of a
+// transaction with the given receipt.
 func (b *BrontesInspector) IntoTraceResults(tx *types.Transaction, receipt *types.Receipt, txIndex int) (*TxTrace, error) {
+	return b.IntoOutcomeTraceResults(tx, ReceiptOutcome(receipt), txIndex)
+}
+
+// IntoOutcomeTraceResults converts the recorded frames into the full result,
+// like IntoTraceResults, taking the outcome of the transaction from the given
+// outcome instead of a receipt, for simulations which have none.
+func (b *BrontesInspector) IntoOutcomeTraceResults(tx *types.Transaction, outcome *ExecutionOutcome, txIndex int) (*TxTrace, error) {
 	blockNumber := b.VMContext.BlockNumber
 	trace, err := b.buildTrace()
 	if err != nil {
 		return nil, err
 	}
 
+	var names map[common.Address]string
+	if b.Names != nil {
+		names = resolveNames(b.Names, b.VMContext.StateDB, *trace)
+	}
+
 	// Create a new big.Int for the effective price (initially 0)
 	effectivePrice := big.NewInt(0)
 
-	return &TxTrace{
+	result := &TxTrace{
+		ChainId:        b.ChainId,
 		BlockNumber:    blockNumber.Uint64(),
+		BlockHash:      b.BlockHash,
+		ParentHash:     b.ParentHash,
 		Trace:          *trace,
 		TxHash:         b.Transaction.Hash(),
 		TxIndex:        txIndex,
-		GasUsed:        new(big.Int).SetUint64(receipt.GasUsed),
+		GasUsed:        new(big.Int).SetUint64(outcome.GasUsed),
 		EffectivePrice: effectivePrice,
-		IsSuccess:      receipt.Status == types.ReceiptStatusSuccessful,
+		IsSuccess:      outcome.Success,
+		SpecId:         SpecName(*b.SpecId),
+		AddressNames:   names,
+		Stats:          NewTxStats(b.Traces.Nodes()),
+		Witness:        b.witness,
+		Coverage:       b.coverage.result(),
+		Refunds:        b.refunds.breakdown(b.Traces, outcome.GasUsed),
+		Errors:         b.errors,
+		Orderflow:      b.orderflow(),
+	}
+	if b.stateDiff != nil {
+		result.StateDiff = b.stateDiff.diff()
+		result.StateDiffDiscrepancies = b.stateDiffDiscrepancies
+	}
+	if b.Config.TransferFlow != "" {
+		result.Transfers = ExtractTransfers(result, b.Config.TransferFlow, outcome.Logs)
+	}
+	if b.Config.DetectDrainers {
+		result.Alerts = FindApprovalAlerts(result, DefaultDrainTokens)
+	}
+	if b.Config.DetectReentrancy {
+		result.Reentrancies = FindReentrancies(result)
+		for _, reentrancy := range result.Reentrancies {
+			reentrant := reentrancy.Chain[len(reentrancy.Chain)-1]
+			for i := range result.Trace {
+				if result.Trace[i].TraceIdx == reentrant {
+					result.Trace[i].Reentrant = true
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// IntoFlatTraceResults converts the recorded frames into their parity style
+// actions, skipping the logs and annotations of the full result.
+func (b *BrontesInspector) IntoFlatTraceResults(receipt *types.Receipt, txIndex int) (*FlatTxTrace, error) {
+	if len(b.Traces.Nodes()) == 0 {
+		return nil, errors.New("no traces found")
+	}
+	nodes := b.IterTraceableNodes()
+	traces := make([]FlatTransactionTrace, 0, len(nodes))
+	for _, node := range nodes {
+		if err := b.interrupted(); err != nil {
+			return nil, err
+		}
+		traceAddress := b.TraceAddress(b.Traces.Nodes(), node.Idx)
+		traces = append(traces, FlatTransactionTrace{
+			TransactionTrace: *b.buildTxTrace(&node, traceAddress),
+			TraceIdx:         node.TraceIdx(),
+		})
+	}
+	return &FlatTxTrace{
+		ChainId:     b.ChainId,
+		BlockNumber: b.VMContext.BlockNumber.Uint64(),
+		TxHash:      b.Transaction.Hash(),
+		TxIndex:     txIndex,
+		IsSuccess:   receipt.Status == types.ReceiptStatusSuccessful,
+		Trace:       traces,
 	}, nil
 }
 
@@ -373,7 +877,17 @@ func (b *BrontesInspector) buildTrace() (*[]TransactionTraceWithLogs, error) {
 	}
 
 	traces := make([]TransactionTraceWithLogs, 0, len(b.Traces.Nodes()))
+	reverted := revertedByParent(b.Traces.Nodes())
+	static := staticContext(b.Traces.Nodes())
 	for _, node := range b.IterTraceableNodes() {
+		if err := b.interrupted(); err != nil {
+			if !b.Config.PartialResults {
+				return nil, err
+			}
+			traceIdx := node.TraceIdx()
+			b.RecordError(TraceErrorStageBuild, err, &traceIdx)
+			break
+		}
 		traceAddress := b.TraceAddress(b.Traces.Nodes(), node.Idx)
 		trace := b.buildTxTrace(&node, traceAddress)
 		logs := make([]types.Log, 0, len(node.Logs))
@@ -386,13 +900,39 @@ func (b *BrontesInspector) buildTrace() (*[]TransactionTraceWithLogs, error) {
 		}
 		msgSender := findMsgSender(traces, trace)
 
+		var (
+			constructorArgs []byte
+			metadata        *ContractMetadata
+			decoded         *DecodedCallData
+			redacted        = b.Config.Redact.redacts(&node.Trace)
+		)
+		if node.Trace.Kind.IsAnyCreate() && node.Trace.Success && !redacted {
+			_, constructorArgs = SplitInitCode(node.Trace.Data, node.Trace.Output)
+			metadata, _ = ParseContractMetadata(node.Trace.Output)
+		}
+		if !redacted {
+			decoded = b.decodeNode(&node, constructorArgs)
+		}
 		traces = append(traces, TransactionTraceWithLogs{
-			Trace:       *trace,
-			Logs:        logs,
-			MsgSender:   msgSender,
-			DecodedData: nil,
-			TraceIdx:    uint64(node.Idx),
+			Trace:            *trace,
+			Logs:             logs,
+			MsgSender:        msgSender,
+			DecodedData:      decoded,
+			ConstructorArgs:  constructorArgs,
+			Metadata:         metadata,
+			TraceIdx:         node.TraceIdx(),
+			Summary:          node.Trace.Summary,
+			StorageAccess:    node.Trace.StorageAccess,
+			CodeAddress:      node.Trace.CodeAddress,
+			ContextAddress:   node.Trace.ContextAddress,
+			UncheckedCall:    b.unchecked != nil && b.unchecked.unchecked[node.Idx],
+			UntrustedTarget:  b.delegates.sources(node.Idx),
+			RevertedByParent: reverted[node.Idx],
+			StaticContext:    static[node.Idx],
 		})
+		if len(node.Logs) > 0 {
+			traces[len(traces)-1].Ordering = frameOrdering(&node)
+		}
 
 		// TODO: handle selfdestruct. Figure out how to get the result of instructions(opcode) after the execution.
 		// We need an additional hook for this (OnOpcodeEnd?)
@@ -400,6 +940,91 @@ func (b *BrontesInspector) buildTrace() (*[]TransactionTraceWithLogs, error) {
 	return &traces, nil
 }
 
+// staticContext reports for every recorded frame whether it runs in a static
+// context, its own or that of an ancestor, in which any state modification
+// fails. Parents precede their children in the arena.
+func staticContext(nodes []CallTraceNode) []bool {
+	result := make([]bool, len(nodes))
+	for i := range nodes {
+		result[i] = nodes[i].Trace.Kind.IsStaticCall()
+		if parent := nodes[i].Parent; parent != nil && *parent != i {
+			result[i] = result[i] || result[*parent]
+		}
+	}
+	return result
+}
+
+// decodeNode decodes the call data, or the constructor arguments of create
+// frames, if the ABI of the executed contract is known.
+func (b *BrontesInspector) decodeNode(node *CallTraceNode, constructorArgs []byte) *DecodedCallData {
+	if b.ABIs == nil {
+		return b.decodeSelector(node)
+	}
+	ctx := b.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	contractABI, err := b.ABIs.ABI(ctx, node.Trace.CodeAddress)
+	if err != nil {
+		log.Debug("Failed to look up contract abi", "address", node.Trace.CodeAddress, "err", err)
+		return b.decodeSelector(node)
+	}
+	if contractABI == nil {
+		return b.decodeSelector(node)
+	}
+	var decoded *DecodedCallData
+	switch {
+	case node.Trace.Kind.IsAnyCreate():
+		decoded, err = DecodeConstructorArgs(contractABI, constructorArgs)
+	case node.Trace.Kind.IsAnyCall():
+		decoded, err = DecodeCallData(contractABI, node.Trace.Data, node.Trace.Output)
+	}
+	if err != nil {
+		log.Debug("Failed to decode call data", "address", node.Trace.CodeAddress, "err", err)
+		return nil
+	}
+	return decoded
+}
+
+// orderflow returns the orderflow metadata of the transaction, if known.
+func (b *BrontesInspector) orderflow() *Orderflow {
+	if b.Orderflow == nil {
+		return nil
+	}
+	orderflow, err := b.Orderflow.Orderflow(b.Transaction.Hash())
+	if err != nil {
+		log.Debug("Failed to look up orderflow", "tx", b.Transaction.Hash(), "err", err)
+		return nil
+	}
+	return orderflow
+}
+
+// decodeSelector decodes the call data of call frames after the signature of
+// their selector, if it resolves.
+func (b *BrontesInspector) decodeSelector(node *CallTraceNode) *DecodedCallData {
+	if b.Selectors == nil || !node.Trace.Kind.IsAnyCall() || len(node.Trace.Data) < 4 {
+		return nil
+	}
+	ctx := b.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	signature, err := b.Selectors.Signature(ctx, [4]byte(node.Trace.Data[:4]))
+	if err != nil {
+		log.Debug("Failed to resolve selector", "selector", common.Bytes2Hex(node.Trace.Data[:4]), "err", err)
+		return nil
+	}
+	if signature == "" {
+		return nil
+	}
+	decoded, err := DecodeSelectorCallData(signature, node.Trace.Data)
+	if err != nil {
+		log.Debug("Failed to decode call data", "signature", signature, "err", err)
+		return nil
+	}
+	return decoded
+}
+
 func (b *BrontesInspector) buildTxTrace(node *CallTraceNode, traceAddress []uint) *TransactionTrace {
 	action := b.ParityAction(node)
 	var result *TraceOutput
@@ -421,7 +1046,9 @@ func (b *BrontesInspector) buildTxTrace(node *CallTraceNode, traceAddress []uint
 		TraceAddress: traceAddress,
 		Subtraces:    uint(len(node.Children)),
 	}
-
+	if b.Config.Redact.redacts(&node.Trace) {
+		redactTrace(txTrace)
+	}
 	return txTrace
 }
 
@@ -452,9 +1079,10 @@ func (b *BrontesInspector) ParityAction(node *CallTraceNode) *Action {
 		}
 	} else if node.Trace.Kind.IsSelfDestruct() {
 		inner := &SelfDestructAction{
-			Address:       node.Trace.Address,
+			Address:       node.Trace.ContextAddress,
 			RefundAddress: *node.Trace.SelfDestructRefundTarget,
 			Balance:       node.Trace.Value,
+			CodeRemoved:   node.Trace.SelfDestructCodeRemoved,
 		}
 		return &Action{
 			Type:         ActionTypeSelfDestruct,
@@ -500,16 +1128,30 @@ func (b *BrontesInspector) AsErrorMsg(node *CallTraceNode) *string {
 // for both call(), create() and selfdestruct()
 // NOTE: The to, from and value that are different for every callKind are handled correctly by the geth tracer framework.
 func (b *BrontesInspector) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	// Progress is reported before taking the lock, as OnProgress may snapshot
+	// the arena.
+	b.progressEnter(gas)
+	b.lock.Lock()
+	defer b.lock.Unlock()
 	callKind, err := FromCallTypeCode(typ)
 	if err != nil {
 		return err
 	}
-	op := vm.OpCode(typ)
-	if op == vm.CREATE || op == vm.CREATE2 {
+	if b.witness != nil {
+		b.witness.touchAccount(b.VMContext.StateDB, from)
+		b.witness.touchAccount(b.VMContext.StateDB, to)
+	}
+	if b.stateDiff != nil {
+		b.stateDiff.observe(b.VMContext.StateDB, from)
+		b.stateDiff.observe(b.VMContext.StateDB, to)
+	}
+	if callKind.IsAnyCreate() {
+		b.CreatedContracts[to] = struct{}{}
 		b.startTraceOnCall(to, input, value, callKind, depth, from, gas, nil)
-	} else if op == vm.SELFDESTRUCT {
+	} else if callKind.IsSelfDestruct() {
 		b.startTraceOnCall(to, input, value, callKind, depth, from, gas, nil)
-	} else if op == vm.CALL || op == vm.CALLCODE || op == vm.DELEGATECALL || op == vm.STATICCALL {
+		b.ActiveTrace().Trace.SelfDestructCodeRemoved = b.selfDestructRemovesCode(from)
+	} else if callKind.IsAnyCall() {
 		// handle Call
 		var maybePrecompile *bool
 		if b.Config.ExcludePrecompileCalls {
@@ -517,25 +1159,116 @@ func (b *BrontesInspector) OnEnter(depth int, typ byte, from common.Address, to
 			maybePrecompile = &temp
 		}
 		b.startTraceOnCall(to, input, value, callKind, depth, from, gas, maybePrecompile)
+		if b.delegates != nil {
+			b.delegates.onEnter(b.lastTraceIdx(), callKind)
+		}
 	}
 	return nil
 	// we only handle call and create and selfdestruct
 }
 
+// selfDestructRemovesCode reports whether a selfdestruct of the given contract
+// deletes its code and storage. Since EIP-6780 (Cancun) that only happens if the
+// contract was created in the same transaction; otherwise only its balance is
+// transferred.
+func (b *BrontesInspector) selfDestructRemovesCode(contract common.Address) bool {
+	if *b.SpecId < forks.Cancun {
+		return true
+	}
+	_, created := b.CreatedContracts[contract]
+	return created
+}
+
 // call/create end
 func (b *BrontesInspector) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	b.progressExit()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.refunds != nil {
+		b.refunds.settle()
+	}
+	if b.unchecked != nil {
+		// The steps of the frame run one level deeper than its caller.
+		b.unchecked.onFrameExit(depth+1, err != nil)
+		if idx := b.lastTraceIdx(); depth > 0 && b.Traces.Arena[idx].Trace.Kind.IsAnyCall() {
+			b.unchecked.onCallExit(idx, depth)
+		}
+	}
+	if b.Config.RecordSteps {
+		b.settleStep(0, true)
+		if err != nil {
+			b.dropFailedLogStep()
+		}
+	}
 	b.fillTraceOnCallEnd(gasUsed, err, reverted, output)
 }
 
+// dropFailedLogStep clears the log index of the last step of a failed frame
+// if that step is a LOG which did not emit its log. Spilled steps are left
+// as is.
+func (b *BrontesInspector) dropFailedLogStep() {
+	node := &b.Traces.Arena[b.lastTraceIdx()]
+	if len(node.Trace.Steps) == 0 || len(node.Trace.SpilledSteps) > 0 {
+		return
+	}
+	step := &node.Trace.Steps[len(node.Trace.Steps)-1]
+	if step.LogIndex != nil && *step.LogIndex >= len(node.Logs) {
+		step.LogIndex = nil
+	}
+}
+
+// gas change
+func (b *BrontesInspector) OnGasChange(old, new uint64, reason tracing.GasChangeReason) {
+	if b.refunds != nil {
+		b.refunds.onGasChange(old, new, reason)
+	}
+}
+
 // step
 func (b *BrontesInspector) OnOpcode(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+	b.progressStep(gas, cost)
+	if !b.Config.RecordSteps && !b.Config.RecordOpcodeSummary && !b.Config.RecordStorageAccess && b.witness == nil && b.coverage == nil && b.refunds == nil && b.unchecked == nil && b.delegates == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
 	if b.Config.RecordSteps {
-		b.startStep(pc, op, gas, cost, scope, rData, depth, err)
+		// The previous step of the frame left the memory as it is now.
+		b.settleStep(len(scope.MemoryData()), false)
+		if b.stepFilter.allows(vm.OpCode(op)) {
+			b.startStep(pc, op, gas, cost, scope, rData, depth, err)
+		}
+	}
+	if b.Config.RecordOpcodeSummary {
+		b.recordOpcodeSummary(op, scope)
+	}
+	if b.Config.RecordStorageAccess {
+		if isStorageOp(vm.OpCode(op)) {
+			b.recordStorageAccess(op, scope)
+		}
+	}
+	if b.witness != nil {
+		b.witness.recordOpcode(b.VMContext.StateDB, vm.OpCode(op), scope)
+	}
+	if b.coverage != nil {
+		traceIdx := b.lastTraceIdx()
+		b.coverage.record(traceIdx, b.Traces.Arena[traceIdx].Trace.CodeAddress, pc, scope)
+	}
+	if b.refunds != nil {
+		b.refunds.onOpcode(b.lastTraceIdx(), vm.OpCode(op), scope)
+	}
+	if b.unchecked != nil {
+		b.unchecked.onStep(vm.OpCode(op), len(scope.StackData()), depth)
+	}
+	if b.delegates != nil {
+		b.delegates.onStep(vm.OpCode(op), scope, depth)
 	}
 }
 
 // log
 func (b *BrontesInspector) OnLog(log *types.Log) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
 	traceIdx := b.lastTraceIdx()
 	traceNode := &b.Traces.Arena[traceIdx]
 	traceNode.Ordering = append(traceNode.Ordering, NewLogCallOrderLog(len(traceNode.Logs)))
@@ -0,0 +1,190 @@
+package brontes
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestInspector returns an inspector which traced a call with a single
+// nested call.
+func newTestInspector(t *testing.T, ctx context.Context, config TracingInspectorConfig) *BrontesInspector {
+	t.Helper()
+	var (
+		from = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		env  = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1}
+		tx   = types.NewTx(&types.LegacyTx{To: &to, Gas: 100000})
+	)
+	inspector := NewBrontesInspector(ctx, config, params.MainnetChainConfig, env, tx, from)
+	require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+	require.NoError(t, inspector.OnEnter(1, byte(vm.CALL), to, from, nil, 50000, big.NewInt(0)))
+	inspector.OnExit(1, nil, 21000, nil, false)
+	inspector.OnExit(0, nil, 42000, nil, false)
+	return inspector
+}
+
+func TestInspectorCancellation(t *testing.T) {
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 42000}
+
+	inspector := newTestInspector(t, context.Background(), DefaultTracingInspectorConfig)
+	result, err := inspector.IntoTraceResults(inspector.Transaction, receipt, 0)
+	require.NoError(t, err)
+	assert.Len(t, result.Trace, 2)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	inspector = newTestInspector(t, ctx, DefaultTracingInspectorConfig)
+	reason := errors.New("client disconnected")
+	cancel(reason)
+
+	_, err = inspector.IntoTraceResults(inspector.Transaction, receipt, 0)
+	assert.ErrorIs(t, err, reason)
+	_, err = inspector.IntoFlatTraceResults(receipt, 0)
+	assert.ErrorIs(t, err, reason)
+}
+
+func TestSnapshotArena(t *testing.T) {
+	var (
+		from = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		env  = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1}
+		tx   = types.NewTx(&types.LegacyTx{To: &to, Gas: 100000})
+	)
+	config := DefaultTracingInspectorConfig
+	config.RecordStorageAccess = true
+	inspector := NewBrontesInspector(context.Background(), config, params.MainnetChainConfig, env, tx, from)
+	require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+	inspector.ActiveTrace().Trace.StorageAccess.AddRead(common.Hash{1})
+
+	// Snapshots taken concurrently with execution must be consistent.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			inspector.SnapshotArena()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, inspector.OnEnter(1, byte(vm.CALL), to, from, nil, 50000, big.NewInt(0)))
+		inspector.OnExit(1, nil, 21000, nil, false)
+	}
+	<-done
+
+	snapshot := inspector.SnapshotArena()
+	require.Len(t, snapshot.Nodes(), 101)
+	assert.False(t, snapshot.Nodes()[0].Trace.Success, "top frame is still executing")
+
+	// Later changes don't leak into the snapshot.
+	inspector.ActiveTrace().Trace.StorageAccess.AddRead(common.Hash{2})
+	inspector.OnExit(0, nil, 42000, nil, false)
+	assert.Len(t, snapshot.Nodes()[0].Trace.StorageAccess.Reads, 1)
+	assert.False(t, snapshot.Nodes()[0].Trace.Success)
+	assert.True(t, inspector.Traces.Nodes()[0].Trace.Success)
+}
+
+func TestProgressEvents(t *testing.T) {
+	var (
+		from = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		env  = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1}
+		tx   = types.NewTx(&types.LegacyTx{To: &to, Gas: 121000})
+	)
+	inspector := NewBrontesInspector(context.Background(), DefaultTracingInspectorConfig, params.MainnetChainConfig, env, tx, from)
+
+	var events []TraceProgress
+	inspector.OnProgress = func(p TraceProgress) { events = append(events, p) }
+	inspector.ProgressInterval = time.Nanosecond
+
+	require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+	for i := 0; i < progressCheckInterval; i++ {
+		inspector.OnOpcode(0, byte(vm.PUSH1), 90000, 3, nil, nil, 1, nil)
+	}
+	require.NoError(t, inspector.OnEnter(1, byte(vm.CALL), to, from, nil, 5000, big.NewInt(0)))
+	inspector.OnExit(1, nil, 0, nil, false)
+	inspector.OnExit(0, nil, 0, nil, false)
+
+	// The first event may be skipped if the clock didn't advance since the start.
+	require.NotEmpty(t, events)
+	step, enter := events[len(events)-2], events[len(events)-1]
+	assert.Equal(t, TraceProgress{Frames: 1, Steps: progressCheckInterval, GasUsed: 121000 - 89997, Depth: 0, Elapsed: step.Elapsed}, step)
+	assert.Equal(t, TraceProgress{Frames: 2, Steps: progressCheckInterval, GasUsed: 121000 - 89997 - 5000, Depth: 1, Elapsed: enter.Elapsed}, enter)
+}
+
+func TestProgressSnapshot(t *testing.T) {
+	var (
+		from = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		env  = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1}
+		tx   = types.NewTx(&types.LegacyTx{To: &to, Gas: 121000})
+	)
+	inspector := NewBrontesInspector(context.Background(), DefaultTracingInspectorConfig, params.MainnetChainConfig, env, tx, from)
+
+	// Snapshotting the arena from the progress callback must not deadlock.
+	var snapshots []int
+	inspector.OnProgress = func(p TraceProgress) { snapshots = append(snapshots, len(inspector.SnapshotArena().Nodes())) }
+	inspector.ProgressInterval = time.Nanosecond
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+		for i := 0; i < progressCheckInterval; i++ {
+			inspector.OnOpcode(0, byte(vm.PUSH1), 90000, 3, nil, nil, 1, nil)
+		}
+		assert.NoError(t, inspector.OnEnter(1, byte(vm.CALL), to, from, nil, 5000, big.NewInt(0)))
+		inspector.OnExit(1, nil, 0, nil, false)
+		inspector.OnExit(0, nil, 0, nil, false)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("progress callback deadlocked")
+	}
+	require.NotEmpty(t, snapshots)
+	// Frames are reported as they are entered, before they are in the arena.
+	assert.Equal(t, 1, snapshots[len(snapshots)-1])
+}
+
+func TestStaticContext(t *testing.T) {
+	var (
+		from   = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to     = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		oracle = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		env    = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1}
+		tx     = types.NewTx(&types.LegacyTx{To: &to, Gas: 100000})
+	)
+	// The oracle queried through a static call calls back into the caller,
+	// which then calls the oracle again outside of the static context.
+	inspector := NewBrontesInspector(context.Background(), DefaultTracingInspectorConfig, params.MainnetChainConfig, env, tx, from)
+	require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+	require.NoError(t, inspector.OnEnter(1, byte(vm.STATICCALL), to, oracle, nil, 50000, nil))
+	require.NoError(t, inspector.OnEnter(2, byte(vm.CALL), oracle, to, nil, 20000, big.NewInt(0)))
+	inspector.OnExit(2, nil, 1000, nil, false)
+	inspector.OnExit(1, nil, 2000, nil, false)
+	require.NoError(t, inspector.OnEnter(1, byte(vm.CALL), to, oracle, nil, 20000, big.NewInt(0)))
+	inspector.OnExit(1, nil, 1000, nil, false)
+	inspector.OnExit(0, nil, 42000, nil, false)
+
+	trace, err := inspector.IntoTraceResults(nil, &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 42000}, 0)
+	require.NoError(t, err)
+	require.Len(t, trace.Trace, 4)
+	for i, want := range []bool{false, true, true, false} {
+		assert.Equal(t, want, trace.Trace[i].StaticContext, "frame %d", i)
+		assert.Equal(t, want, trace.Trace[i].InStaticContext(), "frame %d", i)
+	}
+	assert.False(t, trace.Trace[2].IsStaticCall())
+
+	reentrancies := FindReentrancies(trace)
+	require.Len(t, reentrancies, 1)
+	assert.True(t, reentrancies[0].ReadOnly)
+}
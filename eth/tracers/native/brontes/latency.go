@@ -0,0 +1,144 @@
+package brontes
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Stages of the export pipeline, the latency of which is measured from the
+// start of the import of every exported block.
+const (
+	StageTraced    = "traced"    // the last transaction of the block was traced
+	StageConverted = "converted" // the output of the last transaction was built
+	StageCommitted = "committed" // the output of the block was written to the sink
+)
+
+// LatencyStages lists the stages of the pipeline in the order blocks pass
+// them.
+var LatencyStages = []string{StageTraced, StageConverted, StageCommitted}
+
+// BlockLatency holds the times an exported block reached the stages of the
+// pipeline.
+type BlockLatency struct {
+	Number    uint64
+	Hash      common.Hash
+	Timestamp uint64 // timestamp of the block header
+
+	Imported  time.Time
+	Traced    time.Time
+	Converted time.Time
+	Committed time.Time
+}
+
+// stage returns the time the block reached a stage.
+func (l *BlockLatency) stage(name string) time.Time {
+	switch name {
+	case StageTraced:
+		return l.Traced
+	case StageConverted:
+		return l.Converted
+	default:
+		return l.Committed
+	}
+}
+
+// Lag returns the time between the timestamp of the block and its commit,
+// which stays low while the export keeps up with the head of the chain.
+func (l *BlockLatency) Lag() time.Duration {
+	return l.Committed.Sub(time.Unix(int64(l.Timestamp), 0))
+}
+
+// StageLatency summarizes the latencies of a stage over the recent blocks.
+type StageLatency struct {
+	Count              int64 // blocks measured since the node started
+	P50, P90, P99, Max time.Duration
+}
+
+// LatencySnapshot is the state of the export pipeline.
+type LatencySnapshot struct {
+	Last   *BlockLatency // last committed block, nil if none
+	Stages map[string]StageLatency
+}
+
+// latencyWindow is the number of recent blocks the percentiles of the
+// latencies are computed over.
+const latencyWindow = 1024
+
+var (
+	latencyTimers = func() map[string]*metrics.Timer {
+		timers := make(map[string]*metrics.Timer, len(LatencyStages))
+		for _, stage := range LatencyStages {
+			timers[stage] = metrics.NewRegisteredTimer("brontes/latency/"+stage, nil)
+		}
+		return timers
+	}()
+	exportedBlockGauge = metrics.NewRegisteredGauge("brontes/block", nil)
+	exportLagGauge     = metrics.NewRegisteredGauge("brontes/lag", nil)
+
+	// The metrics only sample while enabled, so the recent blocks are kept
+	// for the percentiles reported through the API.
+	recentLatencies []BlockLatency // ring of the recent blocks, the oldest at latencyCount%latencyWindow once full
+	latencyCount    int64          // blocks committed since the node started
+	latencyLock     sync.Mutex
+)
+
+// RecordBlockLatency records the latencies of a committed block in the
+// metrics and among the recent blocks of the pipeline.
+func RecordBlockLatency(l BlockLatency) {
+	for _, stage := range LatencyStages {
+		latencyTimers[stage].Update(l.stage(stage).Sub(l.Imported))
+	}
+	exportedBlockGauge.Update(int64(l.Number))
+	exportLagGauge.Update(int64(l.Lag() / time.Second))
+
+	latencyLock.Lock()
+	defer latencyLock.Unlock()
+	if len(recentLatencies) < latencyWindow {
+		recentLatencies = append(recentLatencies, l)
+	} else {
+		recentLatencies[latencyCount%latencyWindow] = l
+	}
+	latencyCount++
+}
+
+// PipelineLatency returns the last committed block and the latencies of the
+// stages of the pipeline over the recent blocks.
+func PipelineLatency() LatencySnapshot {
+	latencyLock.Lock()
+	defer latencyLock.Unlock()
+
+	snapshot := LatencySnapshot{Stages: make(map[string]StageLatency, len(LatencyStages))}
+	if latencyCount > 0 {
+		last := recentLatencies[(latencyCount-1)%latencyWindow]
+		snapshot.Last = &last
+	}
+	durations := make([]time.Duration, len(recentLatencies))
+	for _, stage := range LatencyStages {
+		for i := range recentLatencies {
+			durations[i] = recentLatencies[i].stage(stage).Sub(recentLatencies[i].Imported)
+		}
+		slices.Sort(durations)
+		snapshot.Stages[stage] = StageLatency{
+			Count: latencyCount,
+			P50:   percentile(durations, 0.5),
+			P90:   percentile(durations, 0.9),
+			P99:   percentile(durations, 0.99),
+			Max:   percentile(durations, 1),
+		}
+	}
+	return snapshot
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
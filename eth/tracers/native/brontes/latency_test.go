@@ -0,0 +1,36 @@
+package brontes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineLatency(t *testing.T) {
+	imported := time.Unix(1000, 0)
+	for i, committed := range []time.Duration{10, 20, 30} {
+		RecordBlockLatency(BlockLatency{
+			Number:    uint64(i + 1),
+			Timestamp: 998,
+			Imported:  imported,
+			Traced:    imported.Add(time.Millisecond),
+			Converted: imported.Add(2 * time.Millisecond),
+			Committed: imported.Add(committed * time.Millisecond),
+		})
+	}
+	snapshot := PipelineLatency()
+	require.NotNil(t, snapshot.Last)
+	assert.Equal(t, uint64(3), snapshot.Last.Number)
+	assert.Equal(t, 2*time.Second+30*time.Millisecond, snapshot.Last.Lag())
+
+	require.Len(t, snapshot.Stages, len(LatencyStages))
+	assert.Equal(t, StageLatency{Count: 3, P50: time.Millisecond, P90: time.Millisecond, P99: time.Millisecond, Max: time.Millisecond}, snapshot.Stages[StageTraced])
+	assert.Equal(t, 2*time.Millisecond, snapshot.Stages[StageConverted].P99)
+
+	committed := snapshot.Stages[StageCommitted]
+	assert.Equal(t, int64(3), committed.Count)
+	assert.Equal(t, 20*time.Millisecond, committed.P50)
+	assert.Equal(t, 30*time.Millisecond, committed.Max)
+}
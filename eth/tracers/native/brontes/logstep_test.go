@@ -0,0 +1,58 @@
+package brontes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogStepIndex(t *testing.T) {
+	var (
+		from  = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to    = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		inner = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		topic = common.HexToHash("0x01")
+		env   = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1}
+		tx    = types.NewTx(&types.LegacyTx{To: &to, Gas: 100000})
+	)
+	config := DefaultTracingInspectorConfig
+	config.RecordSteps = true
+	inspector := NewBrontesInspector(context.Background(), config, params.MainnetChainConfig, env, tx, from)
+	require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+
+	// The frame emits two logs, the second one after a step in between.
+	scope := &testScope{address: to}
+	inspector.OnOpcode(0, byte(vm.LOG1), 100000, 0, scope, nil, 1, nil)
+	inspector.OnLog(&types.Log{Address: to, Topics: []common.Hash{topic}})
+	inspector.OnOpcode(1, byte(vm.PUSH0), 100000, 0, scope, nil, 1, nil)
+	inspector.OnOpcode(2, byte(vm.LOG0), 100000, 0, scope, nil, 1, nil)
+	inspector.OnLog(&types.Log{Address: to})
+
+	// A LOG failing in a static call emits no log.
+	require.NoError(t, inspector.OnEnter(1, byte(vm.STATICCALL), to, inner, nil, 50000, nil))
+	inspector.OnOpcode(0, byte(vm.LOG0), 50000, 0, &testScope{address: inner}, nil, 2, nil)
+	inspector.OnExit(1, nil, 50000, vm.ErrWriteProtection, true)
+	inspector.OnExit(0, nil, 21000, nil, false)
+
+	nodes := inspector.Traces.Nodes()
+	require.Len(t, nodes, 2)
+	steps := nodes[0].Trace.Steps
+	require.Len(t, steps, 3)
+	require.NotNil(t, steps[0].LogIndex)
+	assert.Equal(t, 0, *steps[0].LogIndex)
+	assert.Nil(t, steps[1].LogIndex)
+	require.NotNil(t, steps[2].LogIndex)
+	assert.Equal(t, 1, *steps[2].LogIndex)
+	assert.Equal(t, []common.Hash{topic}, nodes[0].Logs[*steps[0].LogIndex].Topics)
+
+	require.Len(t, nodes[1].Trace.Steps, 1)
+	assert.Nil(t, nodes[1].Trace.Steps[0].LogIndex)
+}
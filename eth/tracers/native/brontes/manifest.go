@@ -0,0 +1,224 @@
+package brontes
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ManifestVersion is the version of the ExportManifest format.
+const ManifestVersion = 1
+
+// ManifestTraces is the key of the full traces in the row counts of a
+// manifest, next to the names of the ClickHouse tables.
+const ManifestTraces = "traces"
+
+// ManifestSelectors is the key of the selector statistics in the row counts
+// of a manifest.
+const ManifestSelectors = "selectors"
+
+// ManifestSummaries is the key of the transaction summaries in the row
+// counts of a manifest.
+const ManifestSummaries = "summaries"
+
+// ExportManifest describes a batch of exported blocks, so consumers of an
+// archive can check they hold all of its rows and traces. Only the blocks
+// listed are covered by the counts: blocks of the range traced by other
+// shards or aborted while being traced are left out.
+type ExportManifest struct {
+	Version       int             `json:"version"`
+	SchemaVersion int             `json:"schema_version"`
+	ChainId       uint64          `json:"chain_id"`
+	FromBlock     uint64          `json:"from_block"`
+	ToBlock       uint64          `json:"to_block"`
+	Blocks        []ManifestBlock `json:"blocks"`
+	// Rows is the number of rows exported per table, or of lines for the
+	// full traces.
+	Rows map[string]uint64 `json:"rows"`
+	// TraceHashes are the hashes of the full traces in chain order, empty
+	// unless full traces are exported.
+	TraceHashes []common.Hash `json:"trace_hashes,omitempty"`
+}
+
+// ManifestBlock is a block covered by a manifest.
+type ManifestBlock struct {
+	Number       uint64      `json:"number"`
+	Hash         common.Hash `json:"hash"`
+	Transactions uint64      `json:"transactions"`
+}
+
+// Validate fails on unknown versions and blocks out of order or outside of
+// the range of the manifest.
+func (m *ExportManifest) Validate() error {
+	if m.Version != ManifestVersion {
+		return fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	if m.FromBlock > m.ToBlock {
+		return fmt.Errorf("invalid manifest range %d-%d", m.FromBlock, m.ToBlock)
+	}
+	for i, block := range m.Blocks {
+		if block.Number < m.FromBlock || block.Number > m.ToBlock {
+			return fmt.Errorf("block %d outside of manifest range %d-%d", block.Number, m.FromBlock, m.ToBlock)
+		}
+		if i > 0 && block.Number <= m.Blocks[i-1].Number {
+			return fmt.Errorf("block %d out of order", block.Number)
+		}
+	}
+	return nil
+}
+
+// VerifyTraces checks that the given traces hold every trace of the blocks
+// of the manifest, unchanged. Traces of other blocks are ignored, so all the
+// traces of an archive can be checked against each of its manifests.
+func (m *ExportManifest) VerifyTraces(traces []*TxTrace) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	blocks := make(map[common.Hash]int, len(m.Blocks))
+	for i, block := range m.Blocks {
+		blocks[block.Hash] = i
+	}
+	var covered []*TxTrace
+	for _, trace := range traces {
+		if i, ok := blocks[trace.BlockHash]; ok && m.Blocks[i].Number == trace.BlockNumber {
+			covered = append(covered, trace)
+		}
+	}
+	sort.SliceStable(covered, func(i, j int) bool {
+		if covered[i].BlockNumber != covered[j].BlockNumber {
+			return covered[i].BlockNumber < covered[j].BlockNumber
+		}
+		return covered[i].TxIndex < covered[j].TxIndex
+	})
+	if have, want := uint64(len(covered)), m.Rows[ManifestTraces]; have != want {
+		return fmt.Errorf("have %d traces, manifest lists %d", have, want)
+	}
+	counts := make([]uint64, len(m.Blocks))
+	for _, trace := range covered {
+		counts[blocks[trace.BlockHash]]++
+	}
+	for i, block := range m.Blocks {
+		if counts[i] != block.Transactions {
+			return fmt.Errorf("block %d: have %d traces, manifest lists %d", block.Number, counts[i], block.Transactions)
+		}
+	}
+	if len(m.TraceHashes) == 0 {
+		return nil
+	}
+	if len(m.TraceHashes) != len(covered) {
+		return fmt.Errorf("have %d traces, manifest lists %d trace hashes", len(covered), len(m.TraceHashes))
+	}
+	for i, trace := range covered {
+		hash, err := trace.TraceHash()
+		if err != nil {
+			return err
+		}
+		if hash != m.TraceHashes[i] {
+			return fmt.Errorf("trace of %x in block %d: hash mismatch, have %x, want %x", trace.TxHash, trace.BlockNumber, hash, m.TraceHashes[i])
+		}
+	}
+	return nil
+}
+
+// ManifestBuilder accumulates the contents of a manifest while blocks are
+// exported. The exports of a block only count once the block is ended, so
+// aborted blocks can be discarded.
+type ManifestBuilder struct {
+	chainId       uint64
+	schemaVersion int
+	manifest      *ExportManifest // nil until a block is ended
+
+	// Exports of the current block.
+	txs    uint64
+	rows   map[string]uint64
+	hashes []common.Hash
+}
+
+// NewManifestBuilder creates a builder of manifests of exports in the given
+// schema version, the latest if zero.
+func NewManifestBuilder(chainId uint64, schemaVersion int) *ManifestBuilder {
+	if schemaVersion == 0 {
+		schemaVersion = LatestSchemaVersion
+	}
+	return &ManifestBuilder{
+		chainId:       chainId,
+		schemaVersion: schemaVersion,
+		rows:          make(map[string]uint64),
+	}
+}
+
+// AddTrace records the export of the full trace of a transaction.
+func (b *ManifestBuilder) AddTrace(trace *TxTrace) error {
+	hash, err := trace.TraceHash()
+	if err != nil {
+		return err
+	}
+	b.txs++
+	b.rows[ManifestTraces]++
+	b.hashes = append(b.hashes, hash)
+	return nil
+}
+
+// AddTransaction records the export of a transaction without its full
+// trace, such as the rows of its tables.
+func (b *ManifestBuilder) AddTransaction() {
+	b.txs++
+}
+
+// AddRows records the export of rows of a table.
+func (b *ManifestBuilder) AddRows(table string, rows int) {
+	b.rows[table] += uint64(rows)
+}
+
+// EndBlock adds the exports recorded since the previous block to the
+// manifest, as those of the given block.
+func (b *ManifestBuilder) EndBlock(number uint64, hash common.Hash) {
+	if b.manifest == nil {
+		b.manifest = &ExportManifest{
+			Version:       ManifestVersion,
+			SchemaVersion: b.schemaVersion,
+			ChainId:       b.chainId,
+			FromBlock:     number,
+			Rows:          make(map[string]uint64),
+		}
+	}
+	m := b.manifest
+	m.ToBlock = number
+	m.Blocks = append(m.Blocks, ManifestBlock{Number: number, Hash: hash, Transactions: b.txs})
+	for table, rows := range b.rows {
+		m.Rows[table] += rows
+	}
+	m.TraceHashes = append(m.TraceHashes, b.hashes...)
+	b.DiscardBlock()
+}
+
+// DiscardBlock drops the exports recorded since the previous block.
+func (b *ManifestBuilder) DiscardBlock() {
+	b.txs, b.hashes = 0, nil
+	b.rows = make(map[string]uint64)
+}
+
+// Build returns the manifest of the blocks ended since the previous call,
+// or nil if there are none.
+func (b *ManifestBuilder) Build() *ExportManifest {
+	m := b.manifest
+	b.manifest = nil
+	return m
+}
+
+// ClickhouseRowCount returns the number of rows of a table created by
+// NewClickhouseTables. Every table starts with its chain id column.
+func ClickhouseRowCount(table interface{}) (int, error) {
+	value := reflect.ValueOf(table)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return 0, fmt.Errorf("invalid clickhouse table %T", table)
+	}
+	column := value.Elem().FieldByName("ChainId")
+	if !column.IsValid() || column.Kind() != reflect.Slice {
+		return 0, errors.New("clickhouse table without chain id column")
+	}
+	return column.Len(), nil
+}
@@ -0,0 +1,103 @@
+package brontes
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newManifestTraces returns the traces of two transactions in block 10 and
+// one in block 11.
+func newManifestTraces() []*TxTrace {
+	var traces []*TxTrace
+	for i, position := range []struct {
+		block   uint64
+		txIndex int
+	}{{10, 0}, {10, 1}, {11, 0}} {
+		trace := newTestTxTrace()
+		trace.BlockNumber = position.block
+		trace.BlockHash = common.BigToHash(new(big.Int).SetUint64(position.block))
+		trace.TxIndex = position.txIndex
+		trace.TxHash = common.BigToHash(big.NewInt(int64(i + 1)))
+		traces = append(traces, trace)
+	}
+	return traces
+}
+
+func TestManifestBuilder(t *testing.T) {
+	traces := newManifestTraces()
+	builder := NewManifestBuilder(10, 0)
+	assert.Nil(t, builder.Build())
+
+	require.NoError(t, builder.AddTrace(traces[0]))
+	require.NoError(t, builder.AddTrace(traces[1]))
+	builder.AddRows(TableLogs, 2)
+	builder.EndBlock(10, traces[0].BlockHash)
+
+	// Exports of aborted blocks are not part of the manifest.
+	require.NoError(t, builder.AddTrace(traces[2]))
+	builder.AddRows(TableLogs, 1)
+	builder.DiscardBlock()
+
+	require.NoError(t, builder.AddTrace(traces[2]))
+	builder.EndBlock(11, traces[2].BlockHash)
+
+	manifest := builder.Build()
+	require.NotNil(t, manifest)
+	assert.NoError(t, manifest.Validate())
+	assert.Equal(t, LatestSchemaVersion, manifest.SchemaVersion)
+	assert.Equal(t, uint64(10), manifest.FromBlock)
+	assert.Equal(t, uint64(11), manifest.ToBlock)
+	assert.Equal(t, []ManifestBlock{
+		{Number: 10, Hash: traces[0].BlockHash, Transactions: 2},
+		{Number: 11, Hash: traces[2].BlockHash, Transactions: 1},
+	}, manifest.Blocks)
+	assert.Equal(t, map[string]uint64{ManifestTraces: 3, TableLogs: 2}, manifest.Rows)
+	assert.Len(t, manifest.TraceHashes, 3)
+	assert.Nil(t, builder.Build())
+
+	assert.NoError(t, manifest.VerifyTraces(traces))
+	// The order traces are read in does not matter, nor traces of other
+	// blocks.
+	other := newTestTxTrace()
+	other.BlockNumber = 12
+	assert.NoError(t, manifest.VerifyTraces([]*TxTrace{traces[2], other, traces[1], traces[0]}))
+
+	// Missing and altered traces are caught.
+	assert.Error(t, manifest.VerifyTraces(traces[1:]))
+	altered := *traces[1]
+	altered.GasUsed = big.NewInt(1)
+	assert.Error(t, manifest.VerifyTraces([]*TxTrace{traces[0], &altered, traces[2]}))
+	// Traces of a block reorged out do not count.
+	reorged := *traces[2]
+	reorged.BlockHash = common.HexToHash("0xdead")
+	assert.Error(t, manifest.VerifyTraces([]*TxTrace{traces[0], traces[1], &reorged}))
+}
+
+func TestManifestValidate(t *testing.T) {
+	manifest := &ExportManifest{Version: ManifestVersion, FromBlock: 10, ToBlock: 11, Blocks: []ManifestBlock{{Number: 11}, {Number: 10}}}
+	assert.Error(t, manifest.Validate())
+	manifest.Blocks = []ManifestBlock{{Number: 12}}
+	assert.Error(t, manifest.Validate())
+	manifest.Blocks = []ManifestBlock{{Number: 10}}
+	assert.NoError(t, manifest.Validate())
+	manifest.Version = ManifestVersion + 1
+	assert.Error(t, manifest.Validate())
+}
+
+func TestClickhouseRowCount(t *testing.T) {
+	for name, table := range NewClickhouseTables(newTestTxTrace(), nil, nil, nil) {
+		count, err := ClickhouseRowCount(table)
+		require.NoError(t, err, "table %s", name)
+		assert.Positive(t, count, "table %s", name)
+	}
+	count, err := ClickhouseRowCount(NewClickhouseLogs(newTestTxTrace()))
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, err = ClickhouseRowCount(&ClickhouseBlobs{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package brontes
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// MemoryWordSize is the size in bytes of an EVM memory word.
+const MemoryWordSize = 32
+
+// MemoryWords splits memory into words. The slices share the memory; the
+// last one is shorter if the memory does not end on a word boundary, which
+// only happens for truncated snapshots.
+func MemoryWords(mem []byte) [][]byte {
+	words := make([][]byte, 0, (len(mem)+MemoryWordSize-1)/MemoryWordSize)
+	for start := 0; start < len(mem); start += MemoryWordSize {
+		end := min(start+MemoryWordSize, len(mem))
+		words = append(words, mem[start:end:end])
+	}
+	return words
+}
+
+// MemoryChunks encodes memory as hex strings of one word each, the format
+// of memory in the trace output. It returns nil for empty memory.
+func MemoryChunks(mem []byte) []string {
+	if len(mem) == 0 {
+		return nil
+	}
+	words := MemoryWords(mem)
+	chunks := make([]string, len(words))
+	for i, word := range words {
+		chunks[i] = hexutil.Encode(word)
+	}
+	return chunks
+}
+
+// ParseMemoryChunks decodes memory encoded by MemoryChunks. Every chunk but
+// the last must hold a full word.
+func ParseMemoryChunks(chunks []string) ([]byte, error) {
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	mem := make([]byte, 0, len(chunks)*MemoryWordSize)
+	for i, chunk := range chunks {
+		word, err := hexutil.Decode(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory chunk %d: %w", i, err)
+		}
+		if len(word) > MemoryWordSize || (len(word) < MemoryWordSize && i < len(chunks)-1) {
+			return nil, fmt.Errorf("invalid memory chunk %d: %d bytes", i, len(word))
+		}
+		mem = append(mem, word...)
+	}
+	return mem, nil
+}
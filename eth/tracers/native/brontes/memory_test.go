@@ -0,0 +1,72 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryWords(t *testing.T) {
+	mem := make([]byte, 70)
+	for i := range mem {
+		mem[i] = byte(i)
+	}
+	words := MemoryWords(mem)
+	require.Len(t, words, 3)
+	assert.Equal(t, mem[:32], words[0])
+	assert.Equal(t, mem[64:], words[2])
+	assert.Empty(t, MemoryWords(nil))
+
+	recorded := NewRecordedMemory(mem)
+	assert.Equal(t, words, recorded.Words())
+	word := recorded.Word(2)
+	assert.Equal(t, mem[64:], word[:6])
+	assert.Equal(t, make([]byte, 26), word[6:])
+	assert.Equal(t, [MemoryWordSize]byte{}, recorded.Word(3))
+	assert.Equal(t, [MemoryWordSize]byte{}, recorded.Word(-1))
+}
+
+func TestMemoryChunks(t *testing.T) {
+	mem := make([]byte, 64)
+	mem[31], mem[32] = 0x01, 0x02
+	chunks := MemoryChunks(mem)
+	assert.Equal(t, []string{
+		"0x0000000000000000000000000000000000000000000000000000000000000001",
+		"0x0200000000000000000000000000000000000000000000000000000000000000",
+	}, chunks)
+	parsed, err := ParseMemoryChunks(chunks)
+	require.NoError(t, err)
+	assert.Equal(t, mem, parsed)
+
+	assert.Nil(t, MemoryChunks(nil))
+	_, err = ParseMemoryChunks([]string{"0x01", "0x02"})
+	assert.Error(t, err, "short chunk before the last one")
+	_, err = ParseMemoryChunks([]string{"zz"})
+	assert.Error(t, err)
+}
+
+func TestStepMemorySize(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	// MSTORE(0x40, 1) grows the memory to three words.
+	code := []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0x40, byte(vm.MSTORE), byte(vm.PUSH1), 0, byte(vm.MLOAD), byte(vm.STOP)}
+	config := DefaultTracingInspectorConfig
+	config.RecordSteps = true
+	inspector, _, _ := executeInspected(t, config, code, callee, nil)
+
+	steps := inspector.Traces.Nodes()[0].Trace.Steps
+	require.Len(t, steps, 6)
+	var (
+		before = []int{0, 0, 0, 96, 96, 96}
+		after  = []int{0, 0, 96, 96, 96, 96}
+	)
+	for i, step := range steps {
+		assert.Equal(t, before[i], step.MemorySize, "step %d", i)
+		assert.Equal(t, after[i], step.MemorySizeAfter, "step %d", i)
+	}
+	assert.Equal(t, 96, steps[2].MemoryExpansion())
+	assert.Equal(t, uint64(9), steps[2].MemoryExpansionGas())
+	assert.Zero(t, steps[4].MemoryExpansionGas())
+}
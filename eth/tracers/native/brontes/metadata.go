@@ -0,0 +1,233 @@
+package brontes
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ContractMetadata is the compiler metadata appended to deployed bytecode as a
+// CBOR map, whose length is stored in the last two bytes of the code. The
+// hashes identify the metadata file, which verification services such as
+// Sourcify use to match contracts to their sources.
+type ContractMetadata struct {
+	IPFS         string        `json:"ipfs,omitempty"`  // base58 encoded CIDv0
+	Bzzr0        hexutil.Bytes `json:"bzzr0,omitempty"` // swarm hash, solc < 0.5.12
+	Bzzr1        hexutil.Bytes `json:"bzzr1,omitempty"` // swarm hash, solc < 0.6.0
+	Solc         string        `json:"solc,omitempty"`  // compiler version
+	Experimental bool          `json:"experimental,omitempty"`
+	Raw          hexutil.Bytes `json:"raw"` // the CBOR encoded metadata
+}
+
+var (
+	errNoMetadata      = errors.New("no metadata found")
+	errInvalidMetadata = errors.New("invalid cbor metadata")
+)
+
+// ParseContractMetadata extracts the CBOR metadata from the tail of deployed
+// bytecode.
+func ParseContractMetadata(code []byte) (*ContractMetadata, error) {
+	if len(code) < 2 {
+		return nil, errNoMetadata
+	}
+	length := int(binary.BigEndian.Uint16(code[len(code)-2:]))
+	if length == 0 || length > len(code)-2 {
+		return nil, errNoMetadata
+	}
+	raw := code[len(code)-2-length : len(code)-2]
+
+	dec := cborDecoder{data: raw}
+	entries, err := dec.readMapHeader()
+	if err != nil {
+		return nil, err
+	}
+	meta := &ContractMetadata{Raw: raw}
+	for i := 0; i < entries; i++ {
+		key, err := dec.readText()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "ipfs":
+			hash, err := dec.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			meta.IPFS = base58Encode(hash)
+		case "bzzr0":
+			if meta.Bzzr0, err = dec.readBytes(); err != nil {
+				return nil, err
+			}
+		case "bzzr1":
+			if meta.Bzzr1, err = dec.readBytes(); err != nil {
+				return nil, err
+			}
+		case "solc":
+			// Releases encode the version as three bytes, prereleases as a string.
+			if dec.peekMajor() == cborTextString {
+				if meta.Solc, err = dec.readText(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			version, err := dec.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			if len(version) != 3 {
+				return nil, errInvalidMetadata
+			}
+			meta.Solc = fmt.Sprintf("%d.%d.%d", version[0], version[1], version[2])
+		case "experimental":
+			if meta.Experimental, err = dec.readBool(); err != nil {
+				return nil, err
+			}
+		default:
+			if err := dec.skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if dec.pos != len(raw) {
+		return nil, errInvalidMetadata
+	}
+	return meta, nil
+}
+
+const (
+	cborByteString = 2
+	cborTextString = 3
+	cborMap        = 5
+	cborSimple     = 7
+)
+
+// cborDecoder reads the subset of CBOR emitted by compilers: a definite length
+// map with text keys and byte string, text string or boolean values.
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) peekMajor() byte {
+	if d.pos >= len(d.data) {
+		return 0xff
+	}
+	return d.data[d.pos] >> 5
+}
+
+func (d *cborDecoder) readHeader() (major byte, arg uint64, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, errInvalidMetadata
+	}
+	head := d.data[d.pos]
+	d.pos++
+	major, info := head>>5, head&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		if d.pos+size > len(d.data) {
+			return 0, 0, errInvalidMetadata
+		}
+		for _, b := range d.data[d.pos : d.pos+size] {
+			arg = arg<<8 | uint64(b)
+		}
+		d.pos += size
+		return major, arg, nil
+	default:
+		return 0, 0, errInvalidMetadata
+	}
+}
+
+func (d *cborDecoder) readMapHeader() (int, error) {
+	major, arg, err := d.readHeader()
+	if err != nil {
+		return 0, err
+	}
+	if major != cborMap || arg > uint64(len(d.data)) {
+		return 0, errInvalidMetadata
+	}
+	return int(arg), nil
+}
+
+func (d *cborDecoder) readString(want byte) ([]byte, error) {
+	major, arg, err := d.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	if major != want || arg > uint64(len(d.data)-d.pos) {
+		return nil, errInvalidMetadata
+	}
+	out := d.data[d.pos : d.pos+int(arg)]
+	d.pos += int(arg)
+	return out, nil
+}
+
+func (d *cborDecoder) readBytes() ([]byte, error) {
+	return d.readString(cborByteString)
+}
+
+func (d *cborDecoder) readText() (string, error) {
+	text, err := d.readString(cborTextString)
+	return string(text), err
+}
+
+func (d *cborDecoder) readBool() (bool, error) {
+	major, arg, err := d.readHeader()
+	if err != nil {
+		return false, err
+	}
+	if major != cborSimple || (arg != 20 && arg != 21) {
+		return false, errInvalidMetadata
+	}
+	return arg == 21, nil
+}
+
+// skip consumes a single value of a type the decoder has no use for.
+func (d *cborDecoder) skip() error {
+	switch d.peekMajor() {
+	case cborByteString, cborTextString:
+		_, err := d.readString(d.peekMajor())
+		return err
+	default:
+		major, _, err := d.readHeader()
+		if err != nil {
+			return err
+		}
+		if major == cborMap || major == 4 {
+			// Nested containers are never emitted by compilers.
+			return errInvalidMetadata
+		}
+		return nil
+	}
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Encode encodes data with the bitcoin alphabet used by IPFS.
+func base58Encode(data []byte) string {
+	var (
+		num   = new(big.Int).SetBytes(data)
+		radix = big.NewInt(58)
+		mod   = new(big.Int)
+		out   []byte
+	)
+	for num.Sign() > 0 {
+		num.DivMod(num, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
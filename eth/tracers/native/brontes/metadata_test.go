@@ -0,0 +1,37 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseContractMetadata(t *testing.T) {
+	// {"ipfs": <multihash>, "solc": 0.8.19} as appended by solc, followed by
+	// the two byte length.
+	cbor := "a2646970667358221220000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f64736f6c6343000813"
+	code := common.FromHex("0x6080604052" + cbor + "0033")
+
+	meta, err := ParseContractMetadata(code)
+	require.NoError(t, err)
+	assert.Equal(t, "QmNLfbof5rLekrACjeuLk9JmGZD2HDBHCU4z16iYKmx5SE", meta.IPFS)
+	assert.Equal(t, "0.8.19", meta.Solc)
+	assert.Equal(t, common.FromHex(cbor), []byte(meta.Raw))
+}
+
+func TestParseContractMetadataInvalid(t *testing.T) {
+	for _, code := range []string{
+		"0x",
+		"0x6080604052",
+		// length exceeding the code
+		"0x60806040520fff",
+		// truncated map
+		"0x6080a2646970667300" + "08",
+	} {
+		if _, err := ParseContractMetadata(common.FromHex(code)); err == nil {
+			t.Errorf("expected error for code %s", code)
+		}
+	}
+}
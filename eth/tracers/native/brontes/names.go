@@ -0,0 +1,243 @@
+package brontes
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// NameResolver maps addresses to human readable names. It returns an empty
+// string for unknown addresses.
+type NameResolver interface {
+	Name(state tracing.StateDB, address common.Address) string
+}
+
+// AddressBook is a NameResolver backed by a static list of names.
+type AddressBook map[common.Address]string
+
+// Name implements NameResolver.
+func (b AddressBook) Name(_ tracing.StateDB, address common.Address) string {
+	return b[address]
+}
+
+// LoadAddressBook reads a JSON object mapping addresses to names.
+func LoadAddressBook(path string) (AddressBook, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var book AddressBook
+	if err := json.Unmarshal(blob, &book); err != nil {
+		return nil, fmt.Errorf("invalid address book %s: %w", path, err)
+	}
+	return book, nil
+}
+
+// AddressBookFile is a NameResolver serving the address book of a file,
+// read again whenever the file changes.
+type AddressBookFile struct {
+	book    AddressBook
+	lock    sync.RWMutex
+	unwatch func() // stops reloading the file, nil if not watched
+}
+
+// Name implements NameResolver.
+func (f *AddressBookFile) Name(state tracing.StateDB, address common.Address) string {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.book[address]
+}
+
+// Len returns the number of named addresses.
+func (f *AddressBookFile) Len() int {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return len(f.book)
+}
+
+// OpenAddressBook loads the address book at path and keeps it up to date with
+// the changes to the file until it is closed.
+func OpenAddressBook(path string) (*AddressBookFile, error) {
+	path = filepath.Clean(path)
+	book, err := LoadAddressBook(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &AddressBookFile{book: book}
+	reload := func() {
+		book, err := LoadAddressBook(path)
+		if err != nil {
+			log.Warn("Failed to reload address book", "path", path, "err", err)
+			return
+		}
+		file.lock.Lock()
+		file.book = book
+		file.lock.Unlock()
+		log.Info("Reloaded address book", "path", path, "names", len(book))
+	}
+	unwatch, err := watchPath(path, true, reload)
+	if err != nil {
+		log.Warn("Failed to watch address book, changes need a restart", "path", path, "err", err)
+	}
+	file.unwatch = unwatch
+	return file, nil
+}
+
+// Close stops reloading the file of the address book.
+func (f *AddressBookFile) Close() {
+	if f.unwatch != nil {
+		f.unwatch()
+	}
+}
+
+var (
+	addressBook     *AddressBookFile
+	addressBookLock sync.RWMutex
+)
+
+// SetAddressBook installs the node-wide address book configured by the
+// operator, used by tracers that opt into naming addresses. Names stay
+// unresolved until this is called.
+func SetAddressBook(book *AddressBookFile) {
+	addressBookLock.Lock()
+	defer addressBookLock.Unlock()
+	addressBook = book
+}
+
+// RegisteredAddressBook returns the node-wide address book, or nil if none is
+// set.
+func RegisteredAddressBook() *AddressBookFile {
+	addressBookLock.RLock()
+	defer addressBookLock.RUnlock()
+	return addressBook
+}
+
+// ENSRegistryAddress is the address of the ENS registry on mainnet and the
+// public testnets.
+var ENSRegistryAddress = common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e")
+
+// ENSReverseResolver resolves ENS reverse records by reading contract storage
+// directly, as no EVM is available at trace time. It only understands
+// resolvers with the storage layout of the ENS PublicResolver, which serves
+// the reverse records of the default reverse registrar. The names are the
+// claims of the address owners and are not checked against forward records.
+type ENSReverseResolver struct {
+	Registry common.Address
+}
+
+const (
+	ensRecordsSlot        = 0 // ENSRegistry.records
+	ensRecordVersionsSlot = 0 // PublicResolver.recordVersions
+	ensNamesSlot          = 8 // PublicResolver.versionable_names
+
+	// ensMaxNameLength bounds the names read from storage.
+	ensMaxNameLength = 256
+)
+
+// Name implements NameResolver.
+func (r ENSReverseResolver) Name(state tracing.StateDB, address common.Address) string {
+	if state == nil {
+		return ""
+	}
+	node := ensReverseNode(address)
+	resolverSlot := incrementSlot(mappingSlot(node, common.BigToHash(big.NewInt(ensRecordsSlot))), 1)
+	resolver := common.BytesToAddress(state.GetState(r.Registry, resolverSlot).Bytes())
+	if resolver == (common.Address{}) {
+		return ""
+	}
+	version := state.GetState(resolver, mappingSlot(node, common.BigToHash(big.NewInt(ensRecordVersionsSlot))))
+	names := mappingSlot(version, common.BigToHash(big.NewInt(ensNamesSlot)))
+	return readStorageString(state, resolver, mappingSlot(node, names))
+}
+
+// ensReverseNode returns the namehash of <address>.addr.reverse.
+func ensReverseNode(address common.Address) common.Hash {
+	node := common.Hash{}
+	for _, label := range []string{"reverse", "addr", hex.EncodeToString(address.Bytes())} {
+		node = crypto.Keccak256Hash(node.Bytes(), crypto.Keccak256([]byte(label)))
+	}
+	return node
+}
+
+// mappingSlot returns the storage slot of key in the mapping at slot.
+func mappingSlot(key common.Hash, slot common.Hash) common.Hash {
+	return crypto.Keccak256Hash(key.Bytes(), slot.Bytes())
+}
+
+func incrementSlot(slot common.Hash, n int64) common.Hash {
+	return common.BigToHash(new(big.Int).Add(slot.Big(), big.NewInt(n)))
+}
+
+// readStorageString decodes a solidity string stored at slot. Short strings
+// are packed with their doubled length in the lowest byte, long ones store
+// the doubled length plus one and keep the data at keccak(slot).
+func readStorageString(state tracing.StateDB, address common.Address, slot common.Hash) string {
+	head := state.GetState(address, slot)
+	if head[31]&1 == 0 {
+		length := int(head[31] / 2)
+		if length > 31 {
+			return ""
+		}
+		return string(head[:length])
+	}
+	length := new(big.Int).Rsh(head.Big(), 1)
+	if !length.IsUint64() || length.Uint64() > ensMaxNameLength {
+		return ""
+	}
+	var (
+		out  = make([]byte, 0, length.Uint64())
+		data = crypto.Keccak256Hash(slot.Bytes())
+	)
+	for i := int64(0); uint64(len(out)) < length.Uint64(); i++ {
+		word := state.GetState(address, incrementSlot(data, i))
+		out = append(out, word[:min(32, int(length.Uint64())-len(out))]...)
+	}
+	return string(out)
+}
+
+// ChainedNameResolver queries a list of resolvers in order, returning the
+// first name found.
+type ChainedNameResolver []NameResolver
+
+// Name implements NameResolver.
+func (c ChainedNameResolver) Name(state tracing.StateDB, address common.Address) string {
+	for _, resolver := range c {
+		if name := resolver.Name(state, address); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// resolveNames returns the names of the senders and recipients of the given
+// frames, omitting unnamed addresses.
+func resolveNames(resolver NameResolver, state tracing.StateDB, traces []TransactionTraceWithLogs) map[common.Address]string {
+	var (
+		names = make(map[common.Address]string)
+		seen  = make(map[common.Address]struct{})
+	)
+	for _, trace := range traces {
+		for _, address := range []common.Address{trace.GetFromAddr(), trace.GetToAddr()} {
+			if _, ok := seen[address]; ok {
+				continue
+			}
+			seen[address] = struct{}{}
+			if name := resolver.Name(state, address); name != "" {
+				names[address] = name
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return names
+}
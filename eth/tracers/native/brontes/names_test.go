@@ -0,0 +1,86 @@
+package brontes
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setENSName writes the reverse record of address as the ENS registry and
+// PublicResolver would.
+func setENSName(statedb *state.StateDB, resolver common.Address, address common.Address, name string) {
+	node := ensReverseNode(address)
+	resolverSlot := incrementSlot(mappingSlot(node, common.Hash{}), 1)
+	statedb.SetState(ENSRegistryAddress, resolverSlot, common.BytesToHash(resolver.Bytes()))
+
+	version := common.BigToHash(big.NewInt(3))
+	statedb.SetState(resolver, mappingSlot(node, common.Hash{}), version)
+	slot := mappingSlot(node, mappingSlot(version, common.BigToHash(big.NewInt(ensNamesSlot))))
+	if len(name) < 32 {
+		var head common.Hash
+		copy(head[:], name)
+		head[31] = byte(2 * len(name))
+		statedb.SetState(resolver, slot, head)
+		return
+	}
+	statedb.SetState(resolver, slot, common.BigToHash(big.NewInt(int64(2*len(name)+1))))
+	data := crypto.Keccak256Hash(slot.Bytes())
+	for i := 0; i*32 < len(name); i++ {
+		var word common.Hash
+		copy(word[:], name[i*32:])
+		statedb.SetState(resolver, incrementSlot(data, int64(i)), word)
+	}
+}
+
+func TestENSReverseResolver(t *testing.T) {
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	require.NoError(t, err)
+
+	var (
+		resolver = common.HexToAddress("0x231b0ee14048e9dccd1d247744d114a4eb5e8e63")
+		short    = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		long     = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		unnamed  = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		longName = strings.Repeat("sub.", 10) + "vitalik.eth"
+	)
+	setENSName(statedb, resolver, short, "vitalik.eth")
+	setENSName(statedb, resolver, long, longName)
+
+	ens := ENSReverseResolver{Registry: ENSRegistryAddress}
+	assert.Equal(t, "vitalik.eth", ens.Name(statedb, short))
+	assert.Equal(t, longName, ens.Name(statedb, long))
+	assert.Equal(t, "", ens.Name(statedb, unnamed))
+
+	book := AddressBook{unnamed: "router"}
+	names := ChainedNameResolver{book, ens}
+	assert.Equal(t, "router", names.Name(statedb, unnamed))
+	assert.Equal(t, "vitalik.eth", names.Name(statedb, short))
+}
+
+func TestOpenAddressBookReload(t *testing.T) {
+	defer func(delay time.Duration) { reloadDelay = delay }(reloadDelay)
+	reloadDelay = 10 * time.Millisecond
+
+	var (
+		path    = filepath.Join(t.TempDir(), "names.json")
+		address = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	)
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0644))
+	book, err := OpenAddressBook(path)
+	require.NoError(t, err)
+	defer book.Close()
+	assert.Equal(t, 0, book.Len())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"`+address.Hex()+`": "router"}`), 0644))
+	require.Eventually(t, func() bool { return book.Name(nil, address) == "router" }, 5*time.Second, 10*time.Millisecond)
+}
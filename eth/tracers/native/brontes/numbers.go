@@ -0,0 +1,166 @@
+package brontes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// NumberEncoding is the encoding of the numeric fields of the tracer result.
+// By default some are hex quantities and others plain JSON numbers, as
+// inherited from the original brontes format.
+type NumberEncoding string
+
+const (
+	// NumberEncodingDefault keeps the encoding of every field as is.
+	NumberEncodingDefault NumberEncoding = ""
+	// NumberEncodingHex encodes all numeric fields as hex quantities.
+	NumberEncodingHex NumberEncoding = "hex"
+	// NumberEncodingDecimal encodes all numeric fields as decimal strings,
+	// which keeps large values intact in parsers reading numbers as floats.
+	NumberEncodingDecimal NumberEncoding = "decimal"
+)
+
+// numericFields lists the paths of the numeric fields of the full and flat
+// outputs, in the syntax of projections, with "*" standing for every key of
+// an object keyed by address or name. Arrays of numbers are re-encoded as a
+// whole. The schema version is left out, as consumers read it before knowing
+// how the trace is encoded, and so is the confidence of decoded call data,
+// which is not an integer.
+var numericFields = []string{
+	"chain_id", "block_number", "gas_used", "effective_price", "tx_index",
+	// frames of the full output
+	"trace.trace_idx",
+	"trace.trace.subtraces",
+	"trace.trace.traceAddress",
+	"trace.trace.action.gas",
+	"trace.trace.action.value",
+	"trace.trace.action.balance",
+	"trace.trace.result.gasUsed",
+	"trace.logs.blockNumber",
+	"trace.logs.transactionIndex",
+	"trace.logs.logIndex",
+	"trace.ordering.index",
+	"trace.summary.sload_count",
+	"trace.summary.sstore_count",
+	"trace.summary.max_depth",
+	"trace.summary.memory_high_water",
+	"trace.summary.opcode_counts.*",
+	// frames of the flat output
+	"trace.subtraces",
+	"trace.traceAddress",
+	"trace.action.gas",
+	"trace.action.value",
+	"trace.action.balance",
+	"trace.result.gasUsed",
+	// transfers
+	"transfers.value", "transfers.trace_idx",
+	// statistics
+	"stats.total_frames", "stats.max_depth", "stats.max_fan_out", "stats.log_count", "stats.frames_per_kind.*",
+	// state
+	"witness.accounts.*.balance", "witness.accounts.*.nonce",
+	"state_diff.*.balance.from", "state_diff.*.balance.to",
+	"state_diff.*.nonce.from", "state_diff.*.nonce.to",
+	// analyses
+	"coverage.code_size",
+	"refunds.refund_counter", "refunds.refund_applied", "refunds.gas_consumed", "refunds.gas_used",
+	"refunds.sstores.trace_idx", "refunds.sstores.delta",
+	"reentrancies.chain",
+	"alerts.trace_idx",
+	"orderflow.bundle_index", "orderflow.bundle_size",
+	"errors.trace_idx",
+}
+
+// Validate checks that the encoding is supported.
+func (e NumberEncoding) Validate() error {
+	switch e {
+	case NumberEncodingDefault, NumberEncodingHex, NumberEncodingDecimal:
+		return nil
+	default:
+		return fmt.Errorf("unsupported number encoding %q", e)
+	}
+}
+
+// Apply re-encodes the numeric fields of an encoded tracer result. The
+// default encoding returns the result as is.
+func (e NumberEncoding) Apply(result []byte) ([]byte, error) {
+	if e == NumberEncodingDefault {
+		return result, nil
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(result))
+	dec.UseNumber() // keep large integers intact
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	tree, err := newProjectionTree(numericFields)
+	if err != nil {
+		return nil, err
+	}
+	tree.encodeNumbers(value, e)
+	return json.Marshal(value)
+}
+
+// encodeNumbers re-encodes the fields of the value selected by the tree.
+func (t *projectionTree) encodeNumbers(value interface{}, e NumberEncoding) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range t.children {
+			if key == "*" {
+				for key, field := range v {
+					v[key] = child.encodeField(field, e)
+				}
+			} else if field, ok := v[key]; ok {
+				v[key] = child.encodeField(field, e)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			t.encodeNumbers(v[i], e)
+		}
+	}
+}
+
+// encodeField re-encodes a field selected by the tree, returning its new
+// value.
+func (t *projectionTree) encodeField(field interface{}, e NumberEncoding) interface{} {
+	if !t.leaf {
+		t.encodeNumbers(field, e)
+		return field
+	}
+	if numbers, ok := field.([]interface{}); ok {
+		for i := range numbers {
+			numbers[i] = e.encode(numbers[i])
+		}
+		return numbers
+	}
+	return e.encode(field)
+}
+
+// encode converts a JSON number or hex quantity into the encoding. Other
+// values, such as nulls, are returned as is.
+func (e NumberEncoding) encode(value interface{}) interface{} {
+	var n *big.Int
+	switch v := value.(type) {
+	case json.Number:
+		n, _ = new(big.Int).SetString(v.String(), 10)
+	case string:
+		if digits, ok := strings.CutPrefix(v, "0x"); ok {
+			n, _ = new(big.Int).SetString(digits, 16)
+		}
+	}
+	if n == nil {
+		return value
+	}
+	if e == NumberEncodingHex {
+		return hexutil.EncodeBig(n)
+	}
+	return n.String()
+}
@@ -0,0 +1,149 @@
+package brontes
+
+import (
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNumberEncoding(t *testing.T) {
+	trace := newTestTxTrace()
+	trace.Trace[0].DecodedData = &DecodedCallData{
+		FunctionName: "transfer",
+		CallData:     []DecodedParams{{FieldName: "to", FieldType: "address", Value: "0x12"}},
+	}
+	blob, err := trace.MarshalSchema(SchemaVersionV2)
+	require.NoError(t, err)
+
+	// The default encoding leaves the result untouched.
+	have, err := NumberEncodingDefault.Apply(blob)
+	require.NoError(t, err)
+	assert.Equal(t, blob, have)
+
+	type frame struct {
+		TraceIdx interface{} `json:"trace_idx"`
+		Trace    struct {
+			Subtraces interface{}            `json:"subtraces"`
+			Action    map[string]interface{} `json:"action"`
+			Result    map[string]interface{} `json:"result"`
+		} `json:"trace"`
+		DecodedData *DecodedCallData `json:"decoded_data"`
+	}
+	type result struct {
+		SchemaVersion  interface{} `json:"schema_version"`
+		ChainId        interface{} `json:"chain_id"`
+		BlockNumber    interface{} `json:"block_number"`
+		GasUsed        interface{} `json:"gas_used"`
+		EffectivePrice interface{} `json:"effective_price"`
+		TxIndex        interface{} `json:"tx_index"`
+		Trace          []frame     `json:"trace"`
+	}
+	tests := []struct {
+		encoding NumberEncoding
+		want     []interface{}
+	}{
+		{NumberEncodingDecimal, []interface{}{"10", "12345", "80000", "1", "0", "0", "1", "100000", "0", "60000"}},
+		{NumberEncodingHex, []interface{}{"0xa", "0x3039", "0x13880", "0x1", "0x0", "0x0", "0x1", "0x186a0", "0x0", "0xea60"}},
+	}
+	for _, tt := range tests {
+		require.NoError(t, tt.encoding.Validate())
+		out, err := tt.encoding.Apply(blob)
+		require.NoError(t, err)
+		var res result
+		require.NoError(t, json.Unmarshal(out, &res))
+		top := res.Trace[0]
+		have := []interface{}{
+			res.ChainId, res.BlockNumber, res.GasUsed, res.EffectivePrice, res.TxIndex,
+			top.TraceIdx, top.Trace.Subtraces, top.Trace.Action["gas"], top.Trace.Action["value"], top.Trace.Result["gasUsed"],
+		}
+		assert.Equal(t, tt.want, have, "encoding %s", tt.encoding)
+		// The version and decoded values are left alone.
+		assert.Equal(t, float64(SchemaVersionV2), res.SchemaVersion)
+		assert.Equal(t, "0x12", top.DecodedData.CallData[0].Value)
+	}
+	assert.Error(t, NumberEncoding("octal").Validate())
+}
+
+// numericPaths collects the paths of the integer fields of a result type as
+// encoded in JSON, following the encodings of actions and outputs.
+func numericPaths(typ reflect.Type, path string, paths map[string]bool) {
+	switch typ {
+	case reflect.TypeOf(big.Int{}), reflect.TypeOf(hexutil.Big{}), reflect.TypeOf(uint256.Int{}),
+		reflect.TypeOf(hexutil.Uint64(0)), reflect.TypeOf(hexutil.Uint(0)):
+		paths[path] = true
+		return
+	case reflect.TypeOf(Action{}):
+		typ = reflect.TypeOf(actionMarshaling{})
+	case reflect.TypeOf(TraceOutput{}):
+		numericPaths(reflect.TypeOf(CallOutput{}), path, paths)
+		numericPaths(reflect.TypeOf(CreateOutput{}), path, paths)
+		return
+	}
+	if reflect.PointerTo(typ).Implements(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()) {
+		return // hashes, addresses, bytes and enums
+	}
+	switch typ.Kind() {
+	case reflect.Ptr:
+		numericPaths(typ.Elem(), path, paths)
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() != reflect.Uint8 {
+			numericPaths(typ.Elem(), path, paths)
+		}
+	case reflect.Map:
+		numericPaths(typ.Elem(), path+".*", paths)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		paths[path] = true
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if field.Anonymous && name == "" {
+				numericPaths(field.Type, path, paths)
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			numericPaths(field.Type, name, paths)
+		}
+	}
+}
+
+func TestNumericFieldsComplete(t *testing.T) {
+	paths := make(map[string]bool)
+	numericPaths(reflect.TypeOf(TxTrace{}), "", paths)
+	numericPaths(reflect.TypeOf(FlatTxTrace{}), "", paths)
+	delete(paths, "schema_version")
+
+	var want []string
+	for path := range paths {
+		want = append(want, path)
+	}
+	sort.Strings(want)
+	have := append([]string(nil), numericFields...)
+	sort.Strings(have)
+	assert.Equal(t, want, have, "numeric fields out of date")
+}
+
+func TestNumberEncodingWildcards(t *testing.T) {
+	blob := []byte(`{"state_diff":{"0x01":{"nonce":{"from":"0x1","to":"0x2"},"code_hash":{"from":"0x3"}}},"reentrancies":[{"chain":[1,2]}]}`)
+	out, err := NumberEncodingDecimal.Apply(blob)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"state_diff":{"0x01":{"nonce":{"from":"1","to":"2"},"code_hash":{"from":"0x3"}}},"reentrancies":[{"chain":["1","2"]}]}`, string(out))
+}
@@ -0,0 +1,75 @@
+package brontes
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// OpcodeClass is a group of related opcodes steps can be recorded for.
+type OpcodeClass string
+
+const (
+	// OpcodeClassStorage covers the persistent and transient storage reads
+	// and writes.
+	OpcodeClassStorage OpcodeClass = "storage"
+	// OpcodeClassCall covers the message calls.
+	OpcodeClassCall OpcodeClass = "call"
+	// OpcodeClassLog covers the LOG0 to LOG4 opcodes.
+	OpcodeClassLog OpcodeClass = "log"
+	// OpcodeClassCreate covers contract creation and self destruction.
+	OpcodeClassCreate OpcodeClass = "create"
+)
+
+// opcodeClasses lists the opcodes of every class.
+var opcodeClasses = map[OpcodeClass][]vm.OpCode{
+	OpcodeClassStorage: {vm.SLOAD, vm.SSTORE, vm.TLOAD, vm.TSTORE},
+	OpcodeClassCall:    {vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL},
+	OpcodeClassLog:     {vm.LOG0, vm.LOG1, vm.LOG2, vm.LOG3, vm.LOG4},
+	OpcodeClassCreate:  {vm.CREATE, vm.CREATE2, vm.SELFDESTRUCT},
+}
+
+// opcodeFilter is the set of opcodes steps are recorded for. A nil filter
+// admits every opcode.
+type opcodeFilter [256]bool
+
+// newOpcodeFilter returns the filter admitting the opcodes of the given
+// classes, nil if no class is given.
+func newOpcodeFilter(classes []OpcodeClass) (*opcodeFilter, error) {
+	if len(classes) == 0 {
+		return nil, nil
+	}
+	filter := new(opcodeFilter)
+	for _, class := range classes {
+		ops, ok := opcodeClasses[class]
+		if !ok {
+			return nil, fmt.Errorf("unknown opcode class %q", class)
+		}
+		for _, op := range ops {
+			filter[op] = true
+		}
+	}
+	return filter, nil
+}
+
+// allows reports whether steps of the opcode are recorded.
+func (f *opcodeFilter) allows(op vm.OpCode) bool {
+	return f == nil || f[op]
+}
+
+// newCaptureFilter returns the filter admitting the named opcodes, nil if no
+// opcode is given.
+func newCaptureFilter(names []string) (*opcodeFilter, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	filter := new(opcodeFilter)
+	for _, name := range names {
+		op, err := parseOpName(name)
+		if err != nil {
+			return nil, err
+		}
+		filter[op] = true
+	}
+	return filter, nil
+}
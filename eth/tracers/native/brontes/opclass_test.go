@@ -0,0 +1,90 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepOpcodeClasses(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	// The caller stores a word and calls the callee, which loads it.
+	code := []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE)}
+	code = append(code, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH20))
+	code = append(code, callee.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.STOP))
+	calleeCode := []byte{byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.STOP)}
+
+	tests := []struct {
+		classes []OpcodeClass
+		caller  []vm.OpCode
+		callee  []vm.OpCode
+	}{
+		{
+			classes: []OpcodeClass{OpcodeClassStorage},
+			caller:  []vm.OpCode{vm.SSTORE},
+			callee:  []vm.OpCode{vm.SLOAD},
+		},
+		{
+			classes: []OpcodeClass{OpcodeClassCall, OpcodeClassLog},
+			caller:  []vm.OpCode{vm.CALL},
+		},
+		{
+			classes: []OpcodeClass{OpcodeClassCreate},
+		},
+	}
+	for _, tt := range tests {
+		config := DefaultTracingInspectorConfig
+		config.RecordSteps = true
+		config.StepOpcodeClasses = tt.classes
+		require.NoError(t, config.Validate())
+
+		inspector, _, _ := executeInspected(t, config, code, callee, calleeCode)
+		nodes := inspector.Traces.Nodes()
+		require.Len(t, nodes, 2)
+		for i, want := range [][]vm.OpCode{tt.caller, tt.callee} {
+			var have []vm.OpCode
+			for _, step := range nodes[i].Trace.Steps {
+				have = append(have, step.Op)
+			}
+			assert.Equal(t, want, have, "classes %v, frame %d", tt.classes, i)
+		}
+	}
+	config := DefaultTracingInspectorConfig
+	config.StepOpcodeClasses = []OpcodeClass{"arithmetic"}
+	assert.Error(t, config.Validate())
+}
+
+func TestCaptureOpcodes(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	// The caller writes memory and storage, then overwrites the memory, which
+	// leaves the snapshot of the SSTORE step untouched.
+	code := []byte{
+		byte(vm.PUSH1), 0xaa, byte(vm.PUSH1), 0, byte(vm.MSTORE8),
+		byte(vm.PUSH1), 1, byte(vm.PUSH1), 2, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0xbb, byte(vm.PUSH1), 0, byte(vm.MSTORE8),
+		byte(vm.STOP),
+	}
+	config := DefaultTracingInspectorConfig
+	config.CaptureOpcodes = []string{"SSTORE"}
+	require.NoError(t, config.Validate())
+
+	inspector, _, _ := executeInspected(t, config, code, callee, nil)
+	steps := inspector.Traces.Nodes()[0].Trace.Steps
+	require.Len(t, steps, 1)
+	assert.Equal(t, vm.SSTORE, steps[0].Op)
+	require.NotNil(t, steps[0].Stack)
+	assert.Len(t, *steps[0].Stack, 2)
+	assert.Equal(t, uint64(2), (*steps[0].Stack)[1].Uint64())
+	require.Len(t, steps[0].Memory.Data, 32)
+	assert.Equal(t, byte(0xaa), steps[0].Memory.Data[0])
+
+	config.CaptureOpcodes = []string{"NOPE"}
+	assert.Error(t, config.Validate())
+	config.CaptureOpcodes = []string{"CALL"}
+	config.StepOpcodeClasses = []OpcodeClass{OpcodeClassStorage}
+	assert.Error(t, config.Validate())
+}
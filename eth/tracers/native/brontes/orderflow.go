@@ -0,0 +1,207 @@
+package brontes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// OrderflowSource is the channel a transaction reached the block builder
+// through.
+type OrderflowSource string
+
+const (
+	// OrderflowPublic is a transaction seen in the public mempool.
+	OrderflowPublic OrderflowSource = "public"
+	// OrderflowPrivate is a transaction sent to the builder privately, on
+	// its own.
+	OrderflowPrivate OrderflowSource = "private"
+	// OrderflowBundle is a transaction included as part of a bundle.
+	OrderflowBundle OrderflowSource = "bundle"
+)
+
+const (
+	// maxOrderflowTags is the number of tags kept per transaction.
+	maxOrderflowTags = 16
+	// maxOrderflowLabel is the length in bytes of the builder name and of
+	// every tag.
+	maxOrderflowLabel = 64
+)
+
+// Orderflow is the ordering metadata of a transaction known to the node, such
+// as the bundle it was included in, supplied by the builder or orderflow
+// provider. It lets traces of bundled transactions be told apart from those
+// of public mempool transactions.
+type Orderflow struct {
+	Source OrderflowSource `json:"source"`
+	// BundleHash identifies the bundle of the transaction, and BundleIndex
+	// and BundleSize its position within the bundle. They are only set for
+	// bundled transactions.
+	BundleHash  *common.Hash `json:"bundle_hash,omitempty"`
+	BundleIndex *uint64      `json:"bundle_index,omitempty"`
+	BundleSize  uint64       `json:"bundle_size,omitempty"`
+	// Builder names the builder of the block, if known.
+	Builder string `json:"builder,omitempty"`
+	// Tags are free-form labels of the orderflow provider.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Validate fails on unknown sources and bundle fields set for transactions
+// outside of bundles.
+func (o *Orderflow) Validate() error {
+	switch o.Source {
+	case OrderflowPublic, OrderflowPrivate:
+		if o.BundleHash != nil || o.BundleIndex != nil || o.BundleSize != 0 {
+			return fmt.Errorf("bundle fields set for %s transaction", o.Source)
+		}
+	case OrderflowBundle:
+		if o.BundleHash == nil {
+			return errors.New("bundle transaction without bundle hash")
+		}
+		if o.BundleIndex != nil && o.BundleSize != 0 && *o.BundleIndex >= o.BundleSize {
+			return fmt.Errorf("bundle index %d out of bundle of %d", *o.BundleIndex, o.BundleSize)
+		}
+	default:
+		return fmt.Errorf("unknown orderflow source %q", o.Source)
+	}
+	if len(o.Builder) > maxOrderflowLabel {
+		return fmt.Errorf("builder name too long: %d bytes, limit %d", len(o.Builder), maxOrderflowLabel)
+	}
+	if len(o.Tags) > maxOrderflowTags {
+		return fmt.Errorf("too many orderflow tags: %d, limit %d", len(o.Tags), maxOrderflowTags)
+	}
+	for _, tag := range o.Tags {
+		if len(tag) > maxOrderflowLabel {
+			return fmt.Errorf("orderflow tag too long: %d bytes, limit %d", len(tag), maxOrderflowLabel)
+		}
+	}
+	return nil
+}
+
+// OrderflowProvider looks up the orderflow metadata of transactions. It
+// returns nil without an error for transactions without metadata.
+type OrderflowProvider interface {
+	Orderflow(hash common.Hash) (*Orderflow, error)
+}
+
+// orderflowPrefix prefixes the database keys of the orderflow metadata,
+// followed by the transaction hash.
+var orderflowPrefix = []byte("brontes-orderflow-")
+
+func orderflowKey(hash common.Hash) []byte {
+	return append(append([]byte{}, orderflowPrefix...), hash[:]...)
+}
+
+// OrderflowStore is an OrderflowProvider keeping the metadata in a key-value
+// store, so it can be supplied before the transaction is included and
+// survives restarts.
+type OrderflowStore struct {
+	db ethdb.KeyValueStore
+}
+
+// NewOrderflowStore creates a store backed by db.
+func NewOrderflowStore(db ethdb.KeyValueStore) *OrderflowStore {
+	return &OrderflowStore{db: db}
+}
+
+// Orderflow implements OrderflowProvider.
+func (s *OrderflowStore) Orderflow(hash common.Hash) (*Orderflow, error) {
+	if ok, _ := s.db.Has(orderflowKey(hash)); !ok {
+		return nil, nil
+	}
+	enc, err := s.db.Get(orderflowKey(hash))
+	if err != nil {
+		return nil, err
+	}
+	orderflow := new(Orderflow)
+	if err := json.Unmarshal(enc, orderflow); err != nil {
+		return nil, fmt.Errorf("corrupt orderflow of %x: %v", hash, err)
+	}
+	return orderflow, nil
+}
+
+// SetOrderflow stores the metadata of a transaction, replacing any stored
+// before.
+func (s *OrderflowStore) SetOrderflow(hash common.Hash, orderflow *Orderflow) error {
+	if err := orderflow.Validate(); err != nil {
+		return err
+	}
+	enc, err := json.Marshal(orderflow)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(orderflowKey(hash), enc)
+}
+
+// ClickhouseOrderflow represents the orderflow metadata of transactions for
+// ClickHouse, one row per transaction with metadata.
+type ClickhouseOrderflow struct {
+	ChainId []uint64
+	ClickhouseTxPosition
+	Source      []string
+	BundleHash  []string
+	BundleIndex []uint64
+	BundleSize  []uint64
+	Builder     []string
+	Tags        [][]string
+}
+
+// NewClickhouseOrderflow creates a ClickhouseOrderflow from a TxTrace, with
+// no rows if the trace has no orderflow metadata. Transactions outside of
+// bundles have an empty bundle hash.
+func NewClickhouseOrderflow(value *TxTrace) *ClickhouseOrderflow {
+	result := &ClickhouseOrderflow{}
+	orderflow := value.Orderflow
+	if orderflow == nil {
+		return result
+	}
+	var (
+		bundleHash  string
+		bundleIndex uint64
+		tags        = orderflow.Tags
+	)
+	if orderflow.BundleHash != nil {
+		bundleHash = orderflow.BundleHash.Hex()
+	}
+	if orderflow.BundleIndex != nil {
+		bundleIndex = *orderflow.BundleIndex
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	result.ChainId = append(result.ChainId, value.ChainId)
+	result.appendTx(value)
+	result.Source = append(result.Source, string(orderflow.Source))
+	result.BundleHash = append(result.BundleHash, bundleHash)
+	result.BundleIndex = append(result.BundleIndex, bundleIndex)
+	result.BundleSize = append(result.BundleSize, orderflow.BundleSize)
+	result.Builder = append(result.Builder, orderflow.Builder)
+	result.Tags = append(result.Tags, tags)
+	return result
+}
+
+var (
+	orderflowStore     *OrderflowStore
+	orderflowStoreLock sync.RWMutex
+)
+
+// SetOrderflowStore installs the node-wide orderflow store consulted by
+// tracers that opt into attaching orderflow metadata. No metadata is
+// attached until this is called.
+func SetOrderflowStore(store *OrderflowStore) {
+	orderflowStoreLock.Lock()
+	defer orderflowStoreLock.Unlock()
+	orderflowStore = store
+}
+
+// RegisteredOrderflowStore returns the node-wide orderflow store, or nil if
+// none is set.
+func RegisteredOrderflowStore() *OrderflowStore {
+	orderflowStoreLock.RLock()
+	defer orderflowStoreLock.RUnlock()
+	return orderflowStore
+}
@@ -0,0 +1,95 @@
+package brontes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderflowValidate(t *testing.T) {
+	var (
+		bundle = common.HexToHash("0xb0")
+		index  = uint64(2)
+	)
+	tests := []struct {
+		name      string
+		orderflow Orderflow
+		valid     bool
+	}{
+		{"public", Orderflow{Source: OrderflowPublic}, true},
+		{"bundle", Orderflow{Source: OrderflowBundle, BundleHash: &bundle, BundleIndex: &index, BundleSize: 3}, true},
+		{"unknown source", Orderflow{Source: "sideways"}, false},
+		{"bundle without hash", Orderflow{Source: OrderflowBundle}, false},
+		{"private with bundle", Orderflow{Source: OrderflowPrivate, BundleHash: &bundle}, false},
+		{"index out of bundle", Orderflow{Source: OrderflowBundle, BundleHash: &bundle, BundleIndex: &index, BundleSize: 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.orderflow.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestOrderflowAttached(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	code := append([]byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH20),
+	}, callee.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.STOP))
+
+	inspector, tx, receipt := executeInspected(t, DefaultTracingInspectorConfig, code, callee, []byte{byte(vm.STOP)})
+	store := NewOrderflowStore(memorydb.New())
+	inspector.Orderflow = store
+
+	// Transactions without metadata are left untouched.
+	trace, err := inspector.IntoTraceResults(tx, receipt, 1)
+	require.NoError(t, err)
+	assert.Nil(t, trace.Orderflow)
+	assert.NotContains(t, NewClickhouseTables(trace, nil, nil, nil), TableOrderflow)
+
+	var (
+		bundle    = common.HexToHash("0xb0")
+		index     = uint64(1)
+		orderflow = &Orderflow{Source: OrderflowBundle, BundleHash: &bundle, BundleIndex: &index, BundleSize: 2, Builder: "builder0x69"}
+	)
+	assert.Error(t, store.SetOrderflow(tx.Hash(), &Orderflow{Source: OrderflowBundle}))
+	assert.Error(t, store.SetOrderflow(tx.Hash(), &Orderflow{Source: OrderflowPublic, Builder: strings.Repeat("b", maxOrderflowLabel+1)}))
+	assert.Error(t, store.SetOrderflow(tx.Hash(), &Orderflow{Source: OrderflowPublic, Tags: []string{strings.Repeat("t", maxOrderflowLabel+1)}}))
+	require.NoError(t, store.SetOrderflow(tx.Hash(), orderflow))
+
+	trace, err = inspector.IntoTraceResults(tx, receipt, 1)
+	require.NoError(t, err)
+	assert.Equal(t, orderflow, trace.Orderflow)
+
+	want := NewClickhouseTables(trace, nil, nil, nil)
+	have, err := inspector.IntoClickhouseTables(1, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, want[TableOrderflow], have[TableOrderflow])
+	assert.Equal(t, &ClickhouseOrderflow{
+		ChainId: []uint64{trace.ChainId},
+		ClickhouseTxPosition: ClickhouseTxPosition{
+			BlockNumber: []uint64{1},
+			BlockHash:   []string{""},
+			ParentHash:  []string{""},
+			TxHash:      []string{tx.Hash().Hex()},
+			TxIndex:     []uint64{1},
+		},
+		Source:      []string{"bundle"},
+		BundleHash:  []string{bundle.Hex()},
+		BundleIndex: []uint64{1},
+		BundleSize:  []uint64{2},
+		Builder:     []string{"builder0x69"},
+		Tags:        [][]string{{}},
+	}, have[TableOrderflow])
+}
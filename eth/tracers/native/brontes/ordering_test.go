@@ -0,0 +1,56 @@
+package brontes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameOrdering(t *testing.T) {
+	var (
+		from  = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to    = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		inner = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		env   = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1}
+		tx    = types.NewTx(&types.LegacyTx{To: &to, Gas: 100000})
+	)
+	inspector := NewBrontesInspector(context.Background(), DefaultTracingInspectorConfig, params.MainnetChainConfig, env, tx, from)
+	require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+
+	// The root frame logs around two subcalls, the first of which logs too.
+	inspector.OnLog(&types.Log{Address: to})
+	require.NoError(t, inspector.OnEnter(1, byte(vm.CALL), to, inner, nil, 50000, big.NewInt(0)))
+	require.NoError(t, inspector.OnEnter(2, byte(vm.STATICCALL), inner, to, nil, 20000, nil))
+	inspector.OnExit(2, nil, 100, nil, false)
+	inspector.OnLog(&types.Log{Address: inner})
+	inspector.OnExit(1, nil, 1000, nil, false)
+	inspector.OnLog(&types.Log{Address: to})
+	require.NoError(t, inspector.OnEnter(1, byte(vm.STATICCALL), to, inner, nil, 50000, nil))
+	inspector.OnExit(1, nil, 1000, nil, false)
+	inspector.OnExit(0, nil, 21000, nil, false)
+
+	// The arena keeps the positions of the children.
+	nodes := inspector.Traces.Nodes()
+	require.Len(t, nodes, 4)
+	assert.Equal(t, []LogCallOrder{
+		NewLogCallOrderLog(0), NewLogCallOrderCall(0), NewLogCallOrderLog(1), NewLogCallOrderCall(1),
+	}, nodes[0].Ordering)
+
+	// The output refers to the trace indices of the children.
+	result, err := inspector.IntoTraceResults(tx, &types.Receipt{Status: types.ReceiptStatusSuccessful}, 0)
+	require.NoError(t, err)
+	require.Len(t, result.Trace, 4)
+	assert.Equal(t, []FrameOrdering{
+		{Type: "log", Index: 0}, {Type: "call", Index: 1}, {Type: "log", Index: 1}, {Type: "call", Index: 3},
+	}, result.Trace[0].Ordering)
+	assert.Equal(t, []FrameOrdering{{Type: "call", Index: 2}, {Type: "log", Index: 0}}, result.Trace[1].Ordering)
+	assert.Nil(t, result.Trace[3].Ordering)
+}
@@ -0,0 +1,34 @@
+package brontes
+
+import (
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ExecutionOutcome is the outcome of a transaction needed to complete its
+// trace. It is taken from the receipt of the transaction, or from the result
+// of its execution in simulations which have no receipt.
+type ExecutionOutcome struct {
+	GasUsed uint64
+	Success bool
+	Logs    []*types.Log // logs kept by the transaction
+}
+
+// ReceiptOutcome returns the outcome recorded in a receipt.
+func ReceiptOutcome(receipt *types.Receipt) *ExecutionOutcome {
+	return &ExecutionOutcome{
+		GasUsed: receipt.GasUsed,
+		Success: receipt.Status == types.ReceiptStatusSuccessful,
+		Logs:    receipt.Logs,
+	}
+}
+
+// ResultOutcome returns the outcome of an execution without receipt, along
+// with the logs it kept, as returned by the GetLogs method of the state.
+func ResultOutcome(result *core.ExecutionResult, logs []*types.Log) *ExecutionOutcome {
+	return &ExecutionOutcome{
+		GasUsed: result.UsedGas,
+		Success: !result.Failed(),
+		Logs:    logs,
+	}
+}
@@ -0,0 +1,47 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutcomeTraceResults(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	inspector, tx, _ := executeInspected(t, DefaultTracingInspectorConfig, []byte{byte(vm.STOP)}, callee, nil)
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 21000}
+
+	// The outcome of a simulation completes the trace like the receipt.
+	want, err := inspector.IntoTraceResults(tx, receipt, 0)
+	require.NoError(t, err)
+	outcome := ResultOutcome(&core.ExecutionResult{UsedGas: receipt.GasUsed}, nil)
+	have, err := inspector.IntoOutcomeTraceResults(tx, outcome, 0)
+	require.NoError(t, err)
+	assert.Equal(t, want, have)
+	assert.True(t, have.IsSuccess)
+	assert.Empty(t, VerifyOutcome(have, outcome))
+
+	failed := ResultOutcome(&core.ExecutionResult{UsedGas: 1, Err: vm.ErrExecutionReverted}, nil)
+	have, err = inspector.IntoOutcomeTraceResults(tx, failed, 0)
+	require.NoError(t, err)
+	assert.False(t, have.IsSuccess)
+	assert.Equal(t, uint64(1), have.GasUsed.Uint64())
+}
+
+func TestVerifyOutcome(t *testing.T) {
+	trace := newTestTxTrace()
+	outcome := &ExecutionOutcome{GasUsed: 80000, Success: true, Logs: []*types.Log{&trace.Trace[0].Logs[0]}}
+	assert.Empty(t, VerifyOutcome(trace, outcome))
+
+	outcome.Success, outcome.Logs = false, nil
+	var checks []string
+	for _, d := range VerifyOutcome(trace, outcome) {
+		checks = append(checks, d.Check)
+	}
+	assert.Equal(t, []string{CheckStatus, CheckLogsBloom}, checks)
+}
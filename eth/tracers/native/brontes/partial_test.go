@@ -0,0 +1,43 @@
+package brontes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialResults(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	code := append([]byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH20),
+	}, callee.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.POP))
+
+	config := DefaultTracingInspectorConfig
+	config.PartialResults = true
+	inspector, tx, _ := executeInspected(t, config, code, callee, []byte{byte(vm.STOP)})
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful}
+
+	// Building stops at the first frame once cancelled, reporting where.
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errors.New("build timeout"))
+	inspector.ctx = ctx
+	trace, err := inspector.IntoTraceResults(tx, receipt, 0)
+	require.NoError(t, err)
+	assert.Empty(t, trace.Trace)
+	first := uint64(0)
+	assert.Equal(t, []TraceError{{Stage: TraceErrorStageBuild, Message: "build timeout", TraceIdx: &first}}, trace.Errors)
+
+	// Without partial results the trace fails.
+	inspector.Config.PartialResults = false
+	inspector.errors = nil
+	_, err = inspector.IntoTraceResults(tx, receipt, 0)
+	assert.EqualError(t, err, "build timeout")
+}
@@ -0,0 +1,105 @@
+package brontes
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PathEntry is a frame on the execution path to a step, along with the step
+// the frame was executing at that point.
+type PathEntry struct {
+	TraceIdx uint64         `json:"trace_idx"`
+	Kind     CallKind       `json:"kind"`
+	Address  common.Address `json:"address"`
+	// StepIdx is the index of the step among the recorded steps of the frame,
+	// nil if the step was not recorded.
+	StepIdx *int           `json:"step_idx,omitempty"`
+	Step    *CallTraceStep `json:"step,omitempty"`
+}
+
+// ExecutionPath returns the frames leading to a recorded step of a frame,
+// outermost first, much like a stack trace at that step. Every entry but the
+// last holds the step entering the next frame, unless that step was not
+// recorded, such as steps filtered out or sampled away. The last entry holds
+// the requested step.
+func (b *BrontesInspector) ExecutionPath(traceIdx, stepIdx int) ([]PathEntry, error) {
+	nodes := b.Traces.Nodes()
+	if traceIdx < 0 || traceIdx >= len(nodes) {
+		return nil, fmt.Errorf("trace index %d out of range", traceIdx)
+	}
+	step, err := b.stepAt(&nodes[traceIdx], stepIdx)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		path = []PathEntry{newPathEntry(&nodes[traceIdx], &stepIdx, step)}
+		node = &nodes[traceIdx]
+	)
+	for node.Parent != nil && node.Idx != 0 {
+		var (
+			callerIdx = node.Trace.CallerStep
+			caller    *CallTraceStep
+		)
+		parent := &nodes[*node.Parent]
+		if callerIdx != nil {
+			if caller, err = b.stepAt(parent, *callerIdx); err != nil {
+				return nil, err
+			}
+		}
+		path = append(path, newPathEntry(parent, callerIdx, caller))
+		node = parent
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+// FailurePath returns the execution path to the last step of the frame a
+// failed transaction failed in, following the failures of the last frames
+// entered down from the top-level frame. It returns nil if the transaction
+// did not fail.
+func (b *BrontesInspector) FailurePath() ([]PathEntry, error) {
+	nodes := b.Traces.Nodes()
+	if len(nodes) == 0 || nodes[0].Trace.Success {
+		return nil, nil
+	}
+	node := &nodes[0]
+	for len(node.Children) > 0 {
+		last := &nodes[node.Children[len(node.Children)-1]]
+		if last.Trace.Success {
+			break
+		}
+		node = last
+	}
+	if node.Trace.StepCount() == 0 {
+		return nil, fmt.Errorf("no steps recorded for frame %d", node.Idx)
+	}
+	return b.ExecutionPath(node.Idx, node.Trace.StepCount()-1)
+}
+
+func newPathEntry(node *CallTraceNode, stepIdx *int, step *CallTraceStep) PathEntry {
+	return PathEntry{
+		TraceIdx: node.TraceIdx(),
+		Kind:     node.Trace.Kind,
+		Address:  node.Trace.CodeAddress,
+		StepIdx:  stepIdx,
+		Step:     step,
+	}
+}
+
+// stepAt returns a recorded step of the frame, reading it back from disk if
+// it was spilled.
+func (b *BrontesInspector) stepAt(node *CallTraceNode, stepIdx int) (*CallTraceStep, error) {
+	if stepIdx < 0 || stepIdx >= node.Trace.StepCount() {
+		return nil, fmt.Errorf("step index %d out of range for frame %d", stepIdx, node.Idx)
+	}
+	if stepIdx < len(node.Trace.Steps) {
+		return &node.Trace.Steps[stepIdx], nil
+	}
+	if b.spillErr != nil {
+		return nil, b.spillErr
+	}
+	return b.spill.read(node.Trace.SpilledSteps[stepIdx-len(node.Trace.Steps)])
+}
@@ -0,0 +1,62 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionPath(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	// The caller calls the reverting callee, the CALL being its eighth step.
+	code := append([]byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH20),
+	}, callee.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.STOP))
+	revert := []byte{byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT)}
+
+	config := DefaultTracingInspectorConfig
+	config.RecordSteps = true
+	inspector, _, _ := executeInspected(t, config, code, callee, revert)
+
+	path, err := inspector.ExecutionPath(1, 1)
+	require.NoError(t, err)
+	require.Len(t, path, 2)
+	assert.Equal(t, uint64(0), path[0].TraceIdx)
+	require.NotNil(t, path[0].StepIdx)
+	assert.Equal(t, 7, *path[0].StepIdx)
+	assert.Equal(t, vm.CALL, path[0].Step.Op)
+	assert.Equal(t, uint64(1), path[1].TraceIdx)
+	assert.Equal(t, callee, path[1].Address)
+	assert.Equal(t, vm.DUP1, path[1].Step.Op)
+
+	// The transaction succeeded despite the failed call. Once failed, as if
+	// the caller bubbled the revert up, the failure originates in the callee.
+	path, err = inspector.FailurePath()
+	require.NoError(t, err)
+	assert.Nil(t, path)
+	inspector.Traces.Arena[0].Trace.Success = false
+	path, err = inspector.FailurePath()
+	require.NoError(t, err)
+	require.Len(t, path, 2)
+	assert.Equal(t, 2, *path[1].StepIdx)
+	assert.Equal(t, vm.REVERT, path[1].Step.Op)
+
+	_, err = inspector.ExecutionPath(1, 3)
+	assert.Error(t, err)
+	_, err = inspector.ExecutionPath(2, 0)
+	assert.Error(t, err)
+
+	// Calls entered by steps left out have no known calling step.
+	config.StepOpcodeClasses = []OpcodeClass{OpcodeClassStorage}
+	inspector, _, _ = executeInspected(t, config, code, callee, revert)
+	assert.Nil(t, inspector.Traces.Nodes()[1].Trace.CallerStep)
+	inspector.Traces.Arena[0].Trace.Success = false
+	path, err = inspector.FailurePath()
+	assert.Error(t, err, "no steps recorded")
+	assert.Nil(t, path)
+}
@@ -0,0 +1,99 @@
+package brontes
+
+import (
+	"time"
+)
+
+// progressCheckInterval is the number of opcodes executed between checks of
+// the progress reporting interval, to keep clock reads off the hot path.
+const progressCheckInterval = 1024
+
+// DefaultProgressInterval is used if OnProgress is set without an interval.
+const DefaultProgressInterval = time.Second
+
+// TraceProgress describes how far the traced transaction has executed.
+type TraceProgress struct {
+	Frames  int           // call frames entered so far
+	Steps   uint64        // opcodes executed so far
+	GasUsed uint64        // approximate gas consumed so far, including intrinsic gas
+	Depth   int           // depth of the currently executing frame, 0 for the top-level call
+	Elapsed time.Duration // time since the transaction started
+}
+
+// progressTracker keeps the counters reported to OnProgress.
+type progressTracker struct {
+	started  time.Time
+	reported time.Time
+	steps    uint64
+	frames   int
+	gasLeft  []uint64 // gas available to every frame on the call stack
+}
+
+func (b *BrontesInspector) progressEnter(gas uint64) {
+	if b.OnProgress == nil {
+		return
+	}
+	if b.progress.started.IsZero() {
+		b.progress.started = time.Now()
+		b.progress.reported = b.progress.started
+	}
+	b.progress.frames++
+	b.progress.gasLeft = append(b.progress.gasLeft, gas)
+	b.maybeReportProgress()
+}
+
+func (b *BrontesInspector) progressExit() {
+	if b.OnProgress == nil || len(b.progress.gasLeft) == 0 {
+		return
+	}
+	b.progress.gasLeft = b.progress.gasLeft[:len(b.progress.gasLeft)-1]
+}
+
+func (b *BrontesInspector) progressStep(gas, cost uint64) {
+	if b.OnProgress == nil || len(b.progress.gasLeft) == 0 {
+		return
+	}
+	b.progress.steps++
+	// The cost of calls includes the gas forwarded to the callee, which is
+	// accounted for by the callee frame once entered.
+	left := uint64(0)
+	if gas > cost {
+		left = gas - cost
+	}
+	b.progress.gasLeft[len(b.progress.gasLeft)-1] = left
+	if b.progress.steps%progressCheckInterval == 0 {
+		b.maybeReportProgress()
+	}
+}
+
+// maybeReportProgress invokes OnProgress if the reporting interval elapsed.
+func (b *BrontesInspector) maybeReportProgress() {
+	interval := b.ProgressInterval
+	if interval == 0 {
+		interval = DefaultProgressInterval
+	}
+	now := time.Now()
+	if now.Sub(b.progress.reported) < interval {
+		return
+	}
+	b.progress.reported = now
+	b.OnProgress(b.currentProgress(now))
+}
+
+func (b *BrontesInspector) currentProgress(now time.Time) TraceProgress {
+	var left uint64
+	for _, gas := range b.progress.gasLeft {
+		left += gas
+	}
+	var used uint64
+	if limit := b.Transaction.Gas(); limit > left {
+		used = limit - left
+	}
+	return TraceProgress{
+		Frames:  b.progress.frames,
+		Steps:   b.progress.steps,
+		GasUsed: used,
+		Depth:   max(len(b.progress.gasLeft)-1, 0),
+		Elapsed: now.Sub(b.progress.started),
+	}
+}
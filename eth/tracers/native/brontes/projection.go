@@ -0,0 +1,137 @@
+package brontes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Projection selects the fields of the tracer result, pruning the output
+// server-side for consumers needing only a subset of it. Fields are given as
+// dot separated paths of JSON keys, arrays being traversed transparently, so
+// "trace.logs" selects the logs of every frame of a full trace and
+// "nodes.trace.steps" the steps of every node of an arena trace.
+type Projection struct {
+	// Include keeps only the given fields, along with the fields identifying
+	// the transaction. All fields are kept if empty.
+	Include []string `json:"include,omitempty"`
+	// Exclude drops the given fields, after Include is applied.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// projectionIdentity lists the top-level fields kept by every projection.
+var projectionIdentity = []string{"schema_version", "chain_id", "block_number", "tx_hash", "tx_index"}
+
+// projectionTree is a set of field paths, sharing common prefixes.
+type projectionTree struct {
+	leaf     bool // the whole subtree is selected
+	children map[string]*projectionTree
+}
+
+func newProjectionTree(paths []string) (*projectionTree, error) {
+	root := new(projectionTree)
+	for _, path := range paths {
+		node := root
+		for _, key := range strings.Split(path, ".") {
+			if key == "" {
+				return nil, fmt.Errorf("invalid projection path %q", path)
+			}
+			if node.children == nil {
+				node.children = make(map[string]*projectionTree)
+			}
+			child, ok := node.children[key]
+			if !ok {
+				child = new(projectionTree)
+				node.children[key] = child
+			}
+			node = child
+		}
+		node.leaf = true
+	}
+	return root, nil
+}
+
+// include returns the parts of the value selected by the tree.
+func (t *projectionTree) include(value interface{}) interface{} {
+	if t.leaf {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t.children))
+		for key, child := range t.children {
+			if field, ok := v[key]; ok {
+				out[key] = child.include(field)
+			}
+		}
+		return out
+	case []interface{}:
+		for i := range v {
+			v[i] = t.include(v[i])
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// exclude removes the parts of the value selected by the tree.
+func (t *projectionTree) exclude(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range t.children {
+			if child.leaf {
+				delete(v, key)
+			} else if field, ok := v[key]; ok {
+				child.exclude(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			t.exclude(v[i])
+		}
+	}
+}
+
+// Validate checks the field paths of the projection.
+func (p *Projection) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if _, err := newProjectionTree(p.Include); err != nil {
+		return err
+	}
+	_, err := newProjectionTree(p.Exclude)
+	return err
+}
+
+// Apply prunes an encoded tracer result. A nil projection returns the result
+// as is.
+func (p *Projection) Apply(result []byte) ([]byte, error) {
+	if p == nil || (len(p.Include) == 0 && len(p.Exclude) == 0) {
+		return result, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(result))
+	dec.UseNumber() // keep large integers intact
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if len(p.Include) > 0 {
+		tree, err := newProjectionTree(slices.Concat(p.Include, projectionIdentity))
+		if err != nil {
+			return nil, err
+		}
+		value = tree.include(value)
+	}
+	if len(p.Exclude) > 0 {
+		tree, err := newProjectionTree(p.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		tree.exclude(value)
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,72 @@
+package brontes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjection(t *testing.T) {
+	result := []byte(`{
+		"chain_id": 1,
+		"block_number": 18000000,
+		"tx_hash": "0x01",
+		"tx_index": 3,
+		"gas_used": "0x5208",
+		"stats": {"frames": 2},
+		"trace": [
+			{"trace_idx": 0, "trace": {"type": "call"}, "logs": [{"data": "0x"}], "decoded_data": {"function_name": "f"}},
+			{"trace_idx": 1, "trace": {"type": "call"}, "logs": [], "decoded_data": null}
+		]
+	}`)
+	tests := []struct {
+		projection *Projection
+		want       string
+	}{
+		{
+			projection: nil,
+			want:       string(result),
+		},
+		{
+			projection: &Projection{Include: []string{"trace.trace_idx", "trace.logs"}},
+			want: `{"chain_id": 1, "block_number": 18000000, "tx_hash": "0x01", "tx_index": 3, "trace": [
+				{"trace_idx": 0, "logs": [{"data": "0x"}]},
+				{"trace_idx": 1, "logs": []}
+			]}`,
+		},
+		{
+			projection: &Projection{Exclude: []string{"stats", "trace.decoded_data", "trace.logs.data"}},
+			want: `{"chain_id": 1, "block_number": 18000000, "tx_hash": "0x01", "tx_index": 3, "gas_used": "0x5208", "trace": [
+				{"trace_idx": 0, "trace": {"type": "call"}, "logs": [{}]},
+				{"trace_idx": 1, "trace": {"type": "call"}, "logs": []}
+			]}`,
+		},
+		{
+			projection: &Projection{Include: []string{"trace"}, Exclude: []string{"trace.trace"}},
+			want: `{"chain_id": 1, "block_number": 18000000, "tx_hash": "0x01", "tx_index": 3, "trace": [
+				{"trace_idx": 0, "logs": [{"data": "0x"}], "decoded_data": {"function_name": "f"}},
+				{"trace_idx": 1, "logs": [], "decoded_data": null}
+			]}`,
+		},
+	}
+	for i, tt := range tests {
+		require.NoError(t, tt.projection.Validate())
+		have, err := tt.projection.Apply(result)
+		require.NoError(t, err)
+		assert.JSONEq(t, tt.want, string(have), "test %d", i)
+	}
+	assert.Error(t, (&Projection{Include: []string{"trace..logs"}}).Validate())
+}
+
+func TestProjectionKeepsLargeNumbers(t *testing.T) {
+	projection := &Projection{Exclude: []string{"trace"}}
+	have, err := projection.Apply([]byte(`{"block_number": 18446744073709551615, "trace": []}`))
+	require.NoError(t, err)
+	var decoded struct {
+		BlockNumber uint64 `json:"block_number"`
+	}
+	require.NoError(t, json.Unmarshal(have, &decoded))
+	assert.Equal(t, uint64(18446744073709551615), decoded.BlockNumber)
+}
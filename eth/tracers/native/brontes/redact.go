@@ -0,0 +1,61 @@
+package brontes
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// RedactionConfig selects the frames whose call data is replaced by its
+// keccak256 hash, for deployments that must not persist raw call data. The
+// structure of the trace, values, gas and logs are kept.
+type RedactionConfig struct {
+	// All redacts every frame of the transaction.
+	All bool `json:"all,omitempty"`
+	// Addresses redacts the frames calling, executing the code of, or
+	// creating any of these accounts.
+	Addresses []common.Address `json:"addresses,omitempty"`
+}
+
+// redacts reports whether the call data of the frame is redacted.
+func (c *RedactionConfig) redacts(trace *CallTrace) bool {
+	if c == nil {
+		return false
+	}
+	if c.All {
+		return true
+	}
+	for _, addr := range c.Addresses {
+		if addr == trace.Address || addr == trace.CodeAddress || addr == trace.ContextAddress {
+			return true
+		}
+	}
+	return false
+}
+
+// redactBytes returns the keccak256 hash of data. Empty data stays empty.
+func redactBytes(data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	return crypto.Keccak256(data)
+}
+
+// redactTrace replaces the input and output of a frame with their hashes.
+func redactTrace(trace *TransactionTrace) {
+	if action := trace.Action; action != nil {
+		switch action.Type {
+		case ActionTypeCall:
+			action.Call.Input = redactBytes(action.Call.Input)
+		case ActionTypeCreate:
+			action.Create.Init = redactBytes(action.Create.Init)
+		}
+	}
+	if result := trace.Result; result != nil {
+		switch {
+		case result.Type == TraceOutputTypeCall && result.Call != nil:
+			result.Call.Output = redactBytes(result.Call.Output)
+		case result.Type == TraceOutputTypeCreate && result.Create != nil:
+			result.Create.Code = redactBytes(result.Create.Code)
+		}
+	}
+}
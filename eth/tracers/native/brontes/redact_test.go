@@ -0,0 +1,71 @@
+package brontes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedaction(t *testing.T) {
+	var (
+		from    = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to      = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		other   = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		input   = []byte{0xde, 0xad, 0xbe, 0xef}
+		output  = []byte{0x01, 0x02}
+		topic   = common.HexToHash("0x01")
+		receipt = &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 42000}
+	)
+	trace := func(redact *RedactionConfig) *TxTrace {
+		config := DefaultTracingInspectorConfig
+		config.Redact = redact
+		var (
+			env = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1}
+			tx  = types.NewTx(&types.LegacyTx{To: &to, Gas: 100000})
+		)
+		inspector := NewBrontesInspector(context.Background(), config, params.MainnetChainConfig, env, tx, from)
+		require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, input, 100000, big.NewInt(0)))
+		require.NoError(t, inspector.OnEnter(1, byte(vm.CALL), to, other, input, 50000, big.NewInt(7)))
+		inspector.OnLog(&types.Log{Address: other, Topics: []common.Hash{topic}, Data: output})
+		inspector.OnExit(1, output, 21000, nil, false)
+		inspector.OnExit(0, output, 42000, nil, false)
+
+		result, err := inspector.IntoTraceResults(tx, receipt, 0)
+		require.NoError(t, err)
+		require.Len(t, result.Trace, 2)
+		return result
+	}
+	hashedInput := hexutil.Bytes(crypto.Keccak256(input))
+	hashedOutput := hexutil.Bytes(crypto.Keccak256(output))
+
+	// Without redaction the call data is kept.
+	plain := trace(nil)
+	assert.Equal(t, hexutil.Bytes(input), plain.Trace[1].Trace.Action.Call.Input)
+
+	// Only the frames calling the configured address are redacted, keeping
+	// their values and logs.
+	redacted := trace(&RedactionConfig{Addresses: []common.Address{other}})
+	assert.Equal(t, hexutil.Bytes(input), redacted.Trace[0].Trace.Action.Call.Input)
+	assert.Equal(t, hexutil.Bytes(output), redacted.Trace[0].Trace.Result.Call.Output)
+	assert.Equal(t, hashedInput, redacted.Trace[1].Trace.Action.Call.Input)
+	assert.Equal(t, hashedOutput, redacted.Trace[1].Trace.Result.Call.Output)
+	assert.Equal(t, big.NewInt(7), redacted.Trace[1].Trace.Action.Call.Value)
+	assert.Equal(t, plain.Trace[1].Logs, redacted.Trace[1].Logs)
+
+	// Redacting globally covers every frame.
+	redacted = trace(&RedactionConfig{All: true})
+	for _, frame := range redacted.Trace {
+		assert.Equal(t, hashedInput, frame.Trace.Action.Call.Input)
+		assert.Equal(t, hashedOutput, frame.Trace.Result.Call.Output)
+	}
+}
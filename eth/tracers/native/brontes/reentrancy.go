@@ -0,0 +1,77 @@
+package brontes
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Reentrancy is a contract being called again while one of its frames is
+// still executing further up the call stack.
+type Reentrancy struct {
+	// Address is the context address of the re-entered contract.
+	Address common.Address `json:"address"`
+	// Chain lists the trace indices from the interrupted frame of the
+	// contract down to the frame re-entering it.
+	Chain []uint64 `json:"chain"`
+	// ReadOnly is set if the contract is re-entered in a static context,
+	// which may observe state the interrupted frame has yet to update.
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// FindReentrancies returns the frames of a trace re-entering a contract that
+// is active on the call stack, in execution order. Delegate calls run in the
+// context of their caller and never re-enter it, and a contract calling
+// itself directly is not reported, as no other contract ran in between.
+func FindReentrancies(value *TxTrace) []Reentrancy {
+	var (
+		positions = make(map[string]int, len(value.Trace))
+		result    []Reentrancy
+	)
+	for i := range value.Trace {
+		positions[traceAddressKey(value.Trace[i].Trace.TraceAddress)] = i
+	}
+	for i := range value.Trace {
+		frame := &value.Trace[i]
+		if frame.Trace.Type != ActionTypeCall {
+			continue
+		}
+		// Walk up the stack to the closest frame running in the same context,
+		// noting whether any other contract ran in between.
+		var (
+			chain   = []uint64{frame.TraceIdx}
+			foreign bool
+			addr    = frame.Trace.TraceAddress
+		)
+		for len(addr) > 0 {
+			addr = addr[:len(addr)-1]
+			pos, ok := positions[traceAddressKey(addr)]
+			if !ok {
+				break
+			}
+			ancestor := &value.Trace[pos]
+			chain = append(chain, ancestor.TraceIdx)
+			if ancestor.ContextAddress != frame.ContextAddress {
+				foreign = true
+				continue
+			}
+			if foreign {
+				for l, r := 0, len(chain)-1; l < r; l, r = l+1, r-1 {
+					chain[l], chain[r] = chain[r], chain[l]
+				}
+				result = append(result, Reentrancy{
+					Address:  frame.ContextAddress,
+					Chain:    chain,
+					ReadOnly: frame.InStaticContext(),
+				})
+			}
+			break
+		}
+	}
+	return result
+}
+
+// traceAddressKey returns a map key of a trace address.
+func traceAddressKey(addr []uint) string {
+	return fmt.Sprint(addr)
+}
@@ -0,0 +1,55 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// callFrame creates a call frame of the given kind at a trace address.
+func callFrame(idx uint64, kind CallKind, from, to common.Address, traceAddress ...uint) TransactionTraceWithLogs {
+	context := to
+	if kind.IsDelegate() {
+		context = from
+	}
+	return TransactionTraceWithLogs{
+		Trace: TransactionTrace{
+			Type:         ActionTypeCall,
+			Action:       &Action{Type: ActionTypeCall, Call: &CallAction{CallType: kind, From: from, To: to}},
+			TraceAddress: append([]uint{}, traceAddress...),
+		},
+		TraceIdx:       idx,
+		CodeAddress:    to,
+		ContextAddress: context,
+	}
+}
+
+func TestFindReentrancies(t *testing.T) {
+	var (
+		eoa    = common.HexToAddress("0x01")
+		vault  = common.HexToAddress("0x02")
+		token  = common.HexToAddress("0x03")
+		oracle = common.HexToAddress("0x04")
+		impl   = common.HexToAddress("0x05")
+	)
+	trace := &TxTrace{Trace: []TransactionTraceWithLogs{
+		callFrame(0, CallKindCall, eoa, vault),
+		// Delegating to the implementation keeps running in the vault.
+		callFrame(1, CallKindDelegateCall, vault, impl, 0),
+		// The vault calling itself is not a reentrancy.
+		callFrame(2, CallKindCall, vault, vault, 0, 0),
+		callFrame(3, CallKindCall, vault, token, 0, 1),
+		callFrame(4, CallKindCall, token, vault, 0, 1, 0),
+		callFrame(5, CallKindCall, vault, oracle, 0, 2),
+		callFrame(6, CallKindStaticCall, oracle, vault, 0, 2, 0),
+		callFrame(7, CallKindCall, eoa, token, 1),
+	}}
+	assert.Equal(t, []Reentrancy{
+		{Address: vault, Chain: []uint64{1, 3, 4}},
+		{Address: vault, Chain: []uint64{1, 5, 6}, ReadOnly: true},
+	}, FindReentrancies(trace))
+
+	trace.Trace = trace.Trace[:4]
+	assert.Empty(t, FindReentrancies(trace))
+}
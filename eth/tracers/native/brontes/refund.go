@@ -0,0 +1,116 @@
+package brontes
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// SstoreRefund is the change of the gas refund counter caused by an SSTORE,
+// following the net gas metering rules of EIP-2200 and EIP-3529. Negative
+// deltas take back refunds granted by earlier writes to the slot.
+type SstoreRefund struct {
+	TraceIdx uint64         `json:"trace_idx"`
+	Address  common.Address `json:"address"`
+	Slot     common.Hash    `json:"slot"`
+	Delta    int64          `json:"delta"`
+	// Reverted is set if the frame of the SSTORE or one of its ancestors
+	// reverted, discarding the refund.
+	Reverted bool `json:"reverted"`
+}
+
+// RefundBreakdown explains the difference between the gas consumed by a
+// transaction and the gas it finally used: the refund counter accumulated by
+// the SSTOREs that were not reverted is refunded up to a cap, a fifth of the
+// gas consumed since EIP-3529.
+type RefundBreakdown struct {
+	Sstores []SstoreRefund `json:"sstores"`
+	// RefundCounter is the refund counter at the end of the execution.
+	RefundCounter uint64 `json:"refund_counter"`
+	// RefundApplied is the refund actually granted, after the cap.
+	RefundApplied uint64 `json:"refund_applied"`
+	GasConsumed   uint64 `json:"gas_consumed"` // gas used before the refund
+	GasUsed       uint64 `json:"gas_used"`     // gas used after the refund
+}
+
+// refundRecorder attributes the changes of the refund counter to SSTOREs.
+// The refund of an SSTORE is only known once it executed, so it is settled on
+// the next step or frame exit.
+type refundRecorder struct {
+	statedb tracing.StateDB
+	sstores []SstoreRefund
+	pending *SstoreRefund // SSTORE awaiting its refund
+	before  uint64        // refund counter before the pending SSTORE
+	counter uint64        // refund counter last observed
+	applied uint64
+}
+
+func newRefundRecorder(statedb tracing.StateDB) *refundRecorder {
+	return &refundRecorder{statedb: statedb}
+}
+
+// settle records the refund of the pending SSTORE, if any.
+func (r *refundRecorder) settle() {
+	r.counter = r.statedb.GetRefund()
+	if r.pending == nil {
+		return
+	}
+	if delta := int64(r.counter) - int64(r.before); delta != 0 {
+		r.pending.Delta = delta
+		r.sstores = append(r.sstores, *r.pending)
+	}
+	r.pending = nil
+}
+
+func (r *refundRecorder) onOpcode(traceIdx int, op vm.OpCode, scope tracing.OpContext) {
+	r.settle()
+	if op != vm.SSTORE {
+		return
+	}
+	stack := scope.StackData()
+	if len(stack) == 0 {
+		return
+	}
+	r.pending = &SstoreRefund{
+		TraceIdx: uint64(traceIdx),
+		Address:  scope.Address(),
+		Slot:     common.Hash(stack[len(stack)-1].Bytes32()),
+	}
+	r.before = r.counter
+}
+
+func (r *refundRecorder) onGasChange(old, new uint64, reason tracing.GasChangeReason) {
+	if reason == tracing.GasChangeTxRefunds {
+		r.applied = new - old
+	}
+}
+
+// breakdown returns the refunds of the transaction. Reverts are resolved
+// against the finished call tree.
+func (r *refundRecorder) breakdown(arena *CallTraceArena, gasUsed uint64) *RefundBreakdown {
+	if r == nil {
+		return nil
+	}
+	sstores := make([]SstoreRefund, len(r.sstores))
+	for i, sstore := range r.sstores {
+		for idx := int(sstore.TraceIdx); ; {
+			node := &arena.Arena[idx]
+			if !node.Trace.Success {
+				sstore.Reverted = true
+				break
+			}
+			if node.Parent == nil {
+				break
+			}
+			idx = *node.Parent
+		}
+		sstores[i] = sstore
+	}
+	return &RefundBreakdown{
+		Sstores:       sstores,
+		RefundCounter: r.counter,
+		RefundApplied: r.applied,
+		GasConsumed:   gasUsed + r.applied,
+		GasUsed:       gasUsed,
+	}
+}
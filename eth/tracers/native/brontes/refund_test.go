@@ -0,0 +1,67 @@
+package brontes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefundBreakdown(t *testing.T) {
+	var (
+		from   = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to     = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		callee = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		slot1  = common.HexToHash("0x01")
+		slot2  = common.HexToHash("0x02")
+	)
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	require.NoError(t, err)
+
+	config := DefaultTracingInspectorConfig
+	config.RecordRefunds = true
+	var (
+		env = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1, StateDB: statedb}
+		tx  = types.NewTx(&types.LegacyTx{To: &to, Gas: 100000})
+	)
+	inspector := NewBrontesInspector(context.Background(), config, params.MainnetChainConfig, env, tx, from)
+	require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+
+	// Clearing a slot grants a refund.
+	scope := &testScope{address: to}
+	inspector.OnOpcode(0, byte(vm.SSTORE), 90000, 5000, scope.slot(slot1), nil, 1, nil)
+	statedb.AddRefund(4800)
+	inspector.OnOpcode(1, byte(vm.SLOAD), 85000, 2100, scope.slot(slot1), nil, 1, nil)
+
+	// The refund granted by a reverted call is discarded.
+	require.NoError(t, inspector.OnEnter(1, byte(vm.CALL), to, callee, nil, 50000, big.NewInt(0)))
+	calleeScope := &testScope{address: callee}
+	inspector.OnOpcode(0, byte(vm.SSTORE), 50000, 5000, calleeScope.slot(slot2), nil, 2, nil)
+	statedb.AddRefund(4800)
+	inspector.OnOpcode(1, byte(vm.REVERT), 45000, 0, calleeScope, nil, 2, nil)
+	statedb.SubRefund(4800) // reverted along with the call
+	inspector.OnExit(1, nil, 5000, vm.ErrExecutionReverted, true)
+	inspector.OnExit(0, nil, 40000, nil, false)
+	inspector.OnGasChange(60000, 64800, tracing.GasChangeTxRefunds)
+
+	result, err := inspector.IntoTraceResults(tx, &types.Receipt{GasUsed: 35200}, 0)
+	require.NoError(t, err)
+	refunds := result.Refunds
+	require.NotNil(t, refunds)
+	assert.Equal(t, []SstoreRefund{
+		{TraceIdx: 0, Address: to, Slot: slot1, Delta: 4800},
+		{TraceIdx: 1, Address: callee, Slot: slot2, Delta: 4800, Reverted: true},
+	}, refunds.Sstores)
+	assert.Equal(t, uint64(4800), refunds.RefundCounter)
+	assert.Equal(t, uint64(4800), refunds.RefundApplied)
+	assert.Equal(t, uint64(40000), refunds.GasConsumed)
+	assert.Equal(t, uint64(35200), refunds.GasUsed)
+}
@@ -0,0 +1,65 @@
+package brontes
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay is the time waited after a change before reloading, so that a
+// batch of writes is picked up at once.
+var reloadDelay = 500 * time.Millisecond
+
+// watchPath calls reload whenever the directory at path, or the file at path
+// if file is set, changes, until the returned function is called. The parent
+// directory of files is watched, as editors and deployment tools replace
+// files rather than writing them.
+func watchPath(path string, file bool, reload func()) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	path = filepath.Clean(path)
+	dir := path
+	if file {
+		dir = filepath.Dir(path)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	delay := reloadDelay
+	go func() {
+		defer watcher.Close()
+
+		var (
+			timer   = time.NewTimer(0)
+			pending <-chan time.Time
+		)
+		<-timer.C
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if file && filepath.Clean(event.Name) != path {
+					continue
+				}
+				timer.Reset(delay)
+				pending = timer.C
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Warn("Brontes config watcher failed", "path", path, "err", err)
+			case <-pending:
+				pending = nil
+				reload()
+			}
+		}
+	}()
+	return func() { watcher.Close() }, nil
+}
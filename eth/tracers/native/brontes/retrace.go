@@ -0,0 +1,46 @@
+package brontes
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultEscalationConfig is the configuration transactions flagged by the
+// summary mode are traced again with: the default one with the steps and the
+// state diff recorded.
+var DefaultEscalationConfig = func() TracingInspectorConfig {
+	config := DefaultTracingInspectorConfig
+	config.RecordSteps = true
+	config.RecordStateDiff = true
+	return config
+}()
+
+// Retracer traces a transaction of the chain again, typically in more detail
+// than it was traced with as its block was processed. It fails if the
+// transaction is not part of the chain yet.
+type Retracer interface {
+	Retrace(ctx context.Context, hash common.Hash, config TracingInspectorConfig) (json.RawMessage, error)
+}
+
+var (
+	retracer     Retracer
+	retracerLock sync.RWMutex
+)
+
+// SetRetracer installs the node-wide retracer used by tracers escalating
+// flagged transactions. Nothing is traced again until this is called.
+func SetRetracer(r Retracer) {
+	retracerLock.Lock()
+	defer retracerLock.Unlock()
+	retracer = r
+}
+
+// RegisteredRetracer returns the node-wide retracer, or nil if none is set.
+func RegisteredRetracer() Retracer {
+	retracerLock.RLock()
+	defer retracerLock.RUnlock()
+	return retracer
+}
@@ -0,0 +1,97 @@
+package brontes
+
+import (
+	"errors"
+	"slices"
+)
+
+// StepSampling bounds the steps recorded per frame to the first Head and the
+// last Tail ones. This keeps the context of entering and exiting every frame,
+// such as the steps leading to a revert, while bounding the output of frames
+// running long loops.
+type StepSampling struct {
+	Head int `json:"head"`
+	Tail int `json:"tail"`
+}
+
+// Validate checks that the sampling keeps any step.
+func (s *StepSampling) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.Head < 0 || s.Tail < 0 {
+		return errors.New("step sampling head and tail must not be negative")
+	}
+	if s.Head == 0 && s.Tail == 0 {
+		return errors.New("step sampling keeps no step")
+	}
+	return nil
+}
+
+// stepSampler holds the last steps of the active frames past their head,
+// until the frames exit.
+type stepSampler struct {
+	head, tail int
+	tails      map[int]*stepRing
+}
+
+// stepRing is a ring buffer of the last steps of a frame.
+type stepRing struct {
+	steps  []CallTraceStep
+	oldest int // position of the oldest step once full
+}
+
+func newStepSampler(sampling *StepSampling) *stepSampler {
+	if sampling == nil {
+		return nil
+	}
+	return &stepSampler{
+		head:  sampling.Head,
+		tail:  sampling.Tail,
+		tails: make(map[int]*stepRing),
+	}
+}
+
+// push holds a step of the frame past its head, returning whether an older
+// step of the frame was dropped to make room for it, or the step itself if
+// no tail is kept.
+func (s *stepSampler) push(traceIdx int, step CallTraceStep) bool {
+	if s.tail == 0 {
+		return true
+	}
+	ring, ok := s.tails[traceIdx]
+	if !ok {
+		ring = &stepRing{steps: make([]CallTraceStep, 0, s.tail)}
+		s.tails[traceIdx] = ring
+	}
+	if len(ring.steps) < s.tail {
+		ring.steps = append(ring.steps, step)
+		return false
+	}
+	ring.steps[ring.oldest] = step
+	ring.oldest = (ring.oldest + 1) % s.tail
+	return true
+}
+
+// flush returns the held steps of an exited frame in execution order.
+func (s *stepSampler) flush(traceIdx int) []CallTraceStep {
+	ring, ok := s.tails[traceIdx]
+	if !ok {
+		return nil
+	}
+	delete(s.tails, traceIdx)
+	return slices.Concat(ring.steps[ring.oldest:], ring.steps[:ring.oldest])
+}
+
+// keepStep records a settled step of the frame, unless the frame is sampled
+// and past its head, in which case the step is held among its last steps.
+func (b *BrontesInspector) keepStep(traceIdx int, step CallTraceStep) {
+	node := &b.Traces.Arena[traceIdx]
+	if b.sampler == nil || node.Trace.StepCount() < b.sampler.head {
+		b.recordStep(node, step)
+		return
+	}
+	if b.sampler.push(traceIdx, step) {
+		node.Trace.SkippedSteps++
+	}
+}
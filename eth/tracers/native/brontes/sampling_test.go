@@ -0,0 +1,49 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepSampling(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	// Eight pushes and a stop, at even pcs.
+	var code []byte
+	for i := 0; i < 8; i++ {
+		code = append(code, byte(vm.PUSH1), byte(i))
+	}
+	code = append(code, byte(vm.STOP))
+
+	tests := []struct {
+		sampling *StepSampling
+		pcs      []int
+		skipped  int
+	}{
+		{sampling: nil, pcs: []int{0, 2, 4, 6, 8, 10, 12, 14, 16}},
+		{sampling: &StepSampling{Head: 2, Tail: 3}, pcs: []int{0, 2, 12, 14, 16}, skipped: 4},
+		{sampling: &StepSampling{Head: 3}, pcs: []int{0, 2, 4}, skipped: 6},
+		{sampling: &StepSampling{Tail: 2}, pcs: []int{14, 16}, skipped: 7},
+		{sampling: &StepSampling{Head: 5, Tail: 5}, pcs: []int{0, 2, 4, 6, 8, 10, 12, 14, 16}},
+	}
+	for i, tt := range tests {
+		config := DefaultTracingInspectorConfig
+		config.RecordSteps = true
+		config.StepSampling = tt.sampling
+		require.NoError(t, config.Validate())
+		inspector, _, _ := executeInspected(t, config, code, callee, nil)
+
+		trace := inspector.Traces.Nodes()[0].Trace
+		var pcs []int
+		for _, step := range trace.Steps {
+			pcs = append(pcs, step.Pc)
+		}
+		assert.Equal(t, tt.pcs, pcs, "test %d", i)
+		assert.Equal(t, tt.skipped, trace.SkippedSteps, "test %d", i)
+	}
+	assert.Error(t, (&StepSampling{}).Validate())
+	assert.Error(t, (&StepSampling{Head: -1, Tail: 2}).Validate())
+}
@@ -0,0 +1,129 @@
+package brontes
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Versions of the TxTrace wire format. Consumers pin a version to keep their
+// parsers working while the format evolves.
+const (
+	// SchemaVersionV1 is the format of the original brontes tracer, without
+	// any of the fields added since.
+	SchemaVersionV1 = 1
+	// SchemaVersionV2 adds chain and fork metadata, statistics, address names
+	// and per-frame annotations, and tags the trace with its version.
+	SchemaVersionV2 = 2
+
+	LatestSchemaVersion = SchemaVersionV2
+)
+
+// ValidateSchemaVersion checks that the given version is supported. Zero
+// selects the latest version.
+func ValidateSchemaVersion(version int) error {
+	if version < 0 || version > LatestSchemaVersion {
+		return fmt.Errorf("unsupported trace schema version %d, latest is %d", version, LatestSchemaVersion)
+	}
+	return nil
+}
+
+// MarshalSchema encodes the trace in the given schema version, the latest if
+// zero.
+func (t *TxTrace) MarshalSchema(version int) ([]byte, error) {
+	switch version {
+	case 0, SchemaVersionV2:
+		cpy := *t
+		cpy.SchemaVersion = SchemaVersionV2
+		return json.Marshal(&cpy)
+	case SchemaVersionV1:
+		return json.Marshal(newTxTraceV1(t))
+	default:
+		return nil, ValidateSchemaVersion(version)
+	}
+}
+
+type txTraceV1 struct {
+	BlockNumber    uint64       `json:"block_number"`
+	Trace          []traceV1    `json:"trace"`
+	TxHash         common.Hash  `json:"tx_hash"`
+	GasUsed        *hexutil.Big `json:"gas_used"`
+	EffectivePrice *hexutil.Big `json:"effective_price"`
+	TxIndex        int          `json:"tx_index"`
+	IsSuccess      bool         `json:"is_success"`
+}
+
+type traceV1 struct {
+	Trace       transactionTraceV1 `json:"trace"`
+	Logs        []types.Log        `json:"logs"`
+	MsgSender   common.Address     `json:"msg_sender"`
+	TraceIdx    uint64             `json:"trace_idx"`
+	DecodedData *decodedCallDataV1 `json:"decoded_data,omitempty"`
+}
+
+// decodedCallDataV1 is the decoded call data without its provenance.
+type decodedCallDataV1 struct {
+	FunctionName string          `json:"function_name"`
+	CallData     []DecodedParams `json:"call_data"`
+	ReturnData   []DecodedParams `json:"return_data"`
+}
+
+func newTxTraceV1(t *TxTrace) *txTraceV1 {
+	traces := make([]traceV1, len(t.Trace))
+	for i := range t.Trace {
+		frame := &t.Trace[i]
+		var decoded *decodedCallDataV1
+		if data := frame.DecodedData; data != nil {
+			decoded = &decodedCallDataV1{
+				FunctionName: data.FunctionName,
+				CallData:     data.CallData,
+				ReturnData:   data.ReturnData,
+			}
+		}
+		traces[i] = traceV1{
+			Trace:       transactionTraceV1{&frame.Trace},
+			Logs:        frame.Logs,
+			MsgSender:   frame.MsgSender,
+			TraceIdx:    frame.TraceIdx,
+			DecodedData: decoded,
+		}
+	}
+	return &txTraceV1{
+		BlockNumber:    t.BlockNumber,
+		Trace:          traces,
+		TxHash:         t.TxHash,
+		GasUsed:        (*hexutil.Big)(t.GasUsed),
+		EffectivePrice: (*hexutil.Big)(t.EffectivePrice),
+		TxIndex:        t.TxIndex,
+		IsSuccess:      t.IsSuccess,
+	}
+}
+
+// transactionTraceV1 encodes a frame without the codeRemoved flag of
+// selfdestruct actions.
+type transactionTraceV1 struct {
+	*TransactionTrace
+}
+
+func (t transactionTraceV1) MarshalJSON() ([]byte, error) {
+	blob, err := json.Marshal(t.TransactionTrace)
+	if err != nil || t.Action == nil || t.Action.Type != ActionTypeSelfDestruct {
+		return blob, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(blob, &fields); err != nil {
+		return nil, err
+	}
+	var action map[string]json.RawMessage
+	if err := json.Unmarshal(fields["action"], &action); err != nil {
+		return nil, err
+	}
+	delete(action, "codeRemoved")
+	if fields["action"], err = json.Marshal(action); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,70 @@
+package brontes
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalSchema(t *testing.T) {
+	trace := newTestTxTrace()
+	trace.SpecId = "Cancun"
+	trace.Trace = append(trace.Trace, TransactionTraceWithLogs{
+		TraceIdx: 2,
+		Trace: TransactionTrace{
+			Type: ActionTypeSelfDestruct,
+			Action: &Action{
+				Type: ActionTypeSelfDestruct,
+				SelfDestruct: &SelfDestructAction{
+					Address:       common.HexToAddress("0x3333333333333333333333333333333333333333"),
+					RefundAddress: common.HexToAddress("0x1111111111111111111111111111111111111111"),
+					Balance:       big.NewInt(1),
+				},
+			},
+			TraceAddress: []uint{1},
+		},
+	})
+
+	// The latest version tags the trace and keeps all fields.
+	blob, err := trace.MarshalSchema(0)
+	require.NoError(t, err)
+	var latest map[string]any
+	require.NoError(t, json.Unmarshal(blob, &latest))
+	assert.EqualValues(t, LatestSchemaVersion, latest["schema_version"])
+	assert.EqualValues(t, 10, latest["chain_id"])
+	assert.Equal(t, "Cancun", latest["spec_id"])
+	assert.Contains(t, string(blob), `"codeRemoved":false`)
+	assert.Zero(t, trace.SchemaVersion, "marshaling must not modify the trace")
+
+	// The first version only has the original fields.
+	blob, err = trace.MarshalSchema(SchemaVersionV1)
+	require.NoError(t, err)
+	var v1 map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(blob, &v1))
+	assert.ElementsMatch(t, []string{"block_number", "trace", "tx_hash", "gas_used", "effective_price", "tx_index", "is_success"}, keys(v1))
+	assert.JSONEq(t, `"0x13880"`, string(v1["gas_used"]))
+
+	var frames []map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(v1["trace"], &frames))
+	require.Len(t, frames, 3)
+	for _, frame := range frames {
+		assert.ElementsMatch(t, []string{"trace", "logs", "msg_sender", "trace_idx"}, keys(frame))
+	}
+	assert.NotContains(t, string(frames[2]["trace"]), "codeRemoved")
+	assert.Contains(t, string(frames[2]["trace"]), `"refundAddress"`)
+
+	_, err = trace.MarshalSchema(LatestSchemaVersion + 1)
+	assert.Error(t, err)
+}
+
+func keys[V any](m map[string]V) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
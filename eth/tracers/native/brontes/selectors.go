@@ -0,0 +1,399 @@
+package brontes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/pebble"
+	"github.com/ethereum/go-ethereum/log"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultFourByteURL is the signature database queried if no URL is
+	// configured.
+	DefaultFourByteURL = "https://www.4byte.directory"
+
+	defaultSelectorFetchRate = 2 // requests per second
+
+	// selectorMissTTL is the time selectors unknown to the source are not
+	// looked up again for, and selectorErrorTTL that of failed lookups.
+	selectorMissTTL  = time.Hour
+	selectorErrorTTL = time.Minute
+	// selectorMissLimit bounds the number of remembered misses.
+	selectorMissLimit = 64 * 1024
+	// selectorQueue is the number of selectors waiting to be looked up in
+	// the background before further ones are dropped.
+	selectorQueue = 1024
+)
+
+// selectorPrefix prefixes the database keys of the resolved selectors,
+// followed by the selector.
+var selectorPrefix = []byte("brontes-selector-")
+
+func selectorDBKey(selector [4]byte) []byte {
+	return append(append([]byte{}, selectorPrefix...), selector[:]...)
+}
+
+// SelectorResolver resolves function selectors to text signatures such as
+// "transfer(address,uint256)". It returns an empty signature without an error
+// if the selector is unknown.
+type SelectorResolver interface {
+	Signature(ctx context.Context, selector [4]byte) (string, error)
+}
+
+// SelectorSource looks up the text signatures matching a function selector,
+// the most likely first.
+type SelectorSource interface {
+	Signatures(ctx context.Context, selector [4]byte) ([]string, error)
+}
+
+// SelectorCache is a SelectorResolver persisting the signatures found by its
+// source in a key-value store, so every selector is looked up once over the
+// lifetime of the node. Selectors unknown to the source and failed lookups
+// are remembered for a while.
+type SelectorCache struct {
+	db     ethdb.KeyValueStore
+	source SelectorSource // nil to only serve stored signatures
+
+	lock     sync.Mutex
+	missing  map[[4]byte]time.Time     // expiry of the remembered misses
+	fetching map[[4]byte]chan struct{} // closed once the lookup ends
+
+	queue  chan [4]byte // selectors looked up in the background
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewSelectorCache creates a cache of the given source backed by db.
+func NewSelectorCache(db ethdb.KeyValueStore, source SelectorSource) *SelectorCache {
+	c := &SelectorCache{
+		db:       db,
+		source:   source,
+		missing:  make(map[[4]byte]time.Time),
+		fetching: make(map[[4]byte]chan struct{}),
+		queue:    make(chan [4]byte, selectorQueue),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	if source != nil {
+		c.wg.Add(1)
+		go c.loop()
+	}
+	return c
+}
+
+// OpenSelectorCache creates a cache of the given source backed by a pebble
+// database in dir.
+func OpenSelectorCache(dir string, source SelectorSource) (*SelectorCache, error) {
+	db, err := pebble.New(dir, 16, 16, "brontes/selectors/", false, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewSelectorCache(db, source), nil
+}
+
+// stored returns the stored signature of a selector.
+func (c *SelectorCache) stored(selector [4]byte) (string, bool) {
+	blob, err := c.db.Get(selectorDBKey(selector))
+	if err != nil {
+		return "", false
+	}
+	return string(blob), true
+}
+
+// known reports whether a selector is remembered as missing, and otherwise
+// returns the channel closed once its ongoing lookup ends, if any. The lock
+// must be held.
+func (c *SelectorCache) known(selector [4]byte) (bool, chan struct{}) {
+	if expiry, ok := c.missing[selector]; ok {
+		if time.Now().Before(expiry) {
+			return true, nil
+		}
+		delete(c.missing, selector)
+	}
+	return false, c.fetching[selector]
+}
+
+// Signature implements SelectorResolver. Concurrent lookups of a selector
+// share a single fetch, which does not hold up the lookups of the others.
+func (c *SelectorCache) Signature(ctx context.Context, selector [4]byte) (string, error) {
+	for {
+		if signature, ok := c.stored(selector); ok {
+			return signature, nil
+		}
+		if c.source == nil {
+			return "", nil
+		}
+		c.lock.Lock()
+		missing, done := c.known(selector)
+		if missing {
+			c.lock.Unlock()
+			return "", nil
+		}
+		if done != nil {
+			c.lock.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		done = make(chan struct{})
+		c.fetching[selector] = done
+		c.lock.Unlock()
+
+		return c.fetch(ctx, selector, done)
+	}
+}
+
+// fetch looks up a selector at the source, storing the signature found or
+// remembering the miss.
+func (c *SelectorCache) fetch(ctx context.Context, selector [4]byte, done chan struct{}) (string, error) {
+	var (
+		signature string
+		ttl       time.Duration
+	)
+	signatures, err := c.source.Signatures(ctx, selector)
+	switch {
+	case err != nil:
+		// Lookups given up by the caller are not the fault of the source.
+		if ctx.Err() == nil {
+			ttl = selectorErrorTTL
+		}
+	case len(signatures) == 0:
+		ttl = selectorMissTTL
+	default:
+		signature = signatures[0]
+		err = c.db.Put(selectorDBKey(selector), []byte(signature))
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ttl > 0 {
+		c.remember(selector, ttl)
+	}
+	delete(c.fetching, selector)
+	close(done)
+	return signature, err
+}
+
+// remember records a miss, unless too many are remembered already. The lock
+// must be held.
+func (c *SelectorCache) remember(selector [4]byte, ttl time.Duration) {
+	if len(c.missing) >= selectorMissLimit {
+		now := time.Now()
+		for missed, expiry := range c.missing {
+			if now.After(expiry) {
+				delete(c.missing, missed)
+			}
+		}
+		if len(c.missing) >= selectorMissLimit {
+			return
+		}
+	}
+	c.missing[selector] = time.Now().Add(ttl)
+}
+
+// Deferred returns a resolver only serving the stored signatures, for tracers
+// which must not wait on the network such as the live tracer. Other selectors
+// are looked up in the background and resolve in later traces.
+func (c *SelectorCache) Deferred() SelectorResolver {
+	return deferredSelectors{cache: c}
+}
+
+type deferredSelectors struct {
+	cache *SelectorCache
+}
+
+// Signature implements SelectorResolver.
+func (d deferredSelectors) Signature(ctx context.Context, selector [4]byte) (string, error) {
+	c := d.cache
+	if signature, ok := c.stored(selector); ok || c.source == nil {
+		return signature, nil
+	}
+	c.lock.Lock()
+	missing, done := c.known(selector)
+	c.lock.Unlock()
+	if missing || done != nil {
+		return "", nil
+	}
+	select {
+	case c.queue <- selector:
+	default:
+	}
+	return "", nil
+}
+
+// loop looks up the queued selectors until the cache is closed.
+func (c *SelectorCache) loop() {
+	defer c.wg.Done()
+	for {
+		select {
+		case selector := <-c.queue:
+			if _, err := c.Signature(c.ctx, selector); err != nil {
+				log.Debug("Failed to resolve selector", "selector", hexutil.Encode(selector[:]), "err", err)
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// Close stops the background lookups and closes the database of the cache.
+func (c *SelectorCache) Close() error {
+	c.cancel()
+	c.wg.Wait()
+	return c.db.Close()
+}
+
+// FourByteSource is a SelectorSource querying a 4byte.directory compatible
+// signature database.
+type FourByteSource struct {
+	url     string
+	client  *http.Client
+	limiter *rate.Limiter
+}
+
+// NewFourByteSource creates a source querying the database at url,
+// DefaultFourByteURL if empty, at most rateLimit times per second.
+func NewFourByteSource(url string, rateLimit float64) *FourByteSource {
+	if url == "" {
+		url = DefaultFourByteURL
+	}
+	if rateLimit <= 0 {
+		rateLimit = defaultSelectorFetchRate
+	}
+	return &FourByteSource{
+		url:     strings.TrimSuffix(url, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		limiter: rate.NewLimiter(rate.Limit(rateLimit), 1),
+	}
+}
+
+// Signatures implements SelectorSource. Colliding signatures are ordered by
+// submission, the oldest being the most likely.
+func (s *FourByteSource) Signatures(ctx context.Context, selector [4]byte) ([]string, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/v1/signatures/?hex_signature=%s", s.url, hexutil.Encode(selector[:])), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signature database returned status %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	var page struct {
+		Results []struct {
+			Id            uint64 `json:"id"`
+			TextSignature string `json:"text_signature"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, err
+	}
+	sort.Slice(page.Results, func(i, j int) bool { return page.Results[i].Id < page.Results[j].Id })
+	signatures := make([]string, 0, len(page.Results))
+	for _, result := range page.Results {
+		signatures = append(signatures, result.TextSignature)
+	}
+	return signatures, nil
+}
+
+// Confidence of call data decoded against a guessed signature. The guess is
+// trusted more the better the signature explains the call data.
+const (
+	// selectorNameConfidence is the confidence of a function named after the
+	// guessed signature without decoding its arguments.
+	selectorNameConfidence = 0.5
+	// selectorDecodedConfidence is the confidence of arguments decoding from
+	// a prefix of the call data.
+	selectorDecodedConfidence = 0.7
+	// selectorExactConfidence is the confidence of arguments encoding back
+	// into the exact call data.
+	selectorExactConfidence = 0.9
+)
+
+// DecodeSelectorCallData decodes the input of a call against the text
+// signature of the called function. Arguments are only decoded for
+// signatures without tuples, which are named after the function alone.
+func DecodeSelectorCallData(signature string, input []byte) (*DecodedCallData, error) {
+	open := strings.IndexByte(signature, '(')
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return nil, fmt.Errorf("invalid signature %q", signature)
+	}
+	decoded := &DecodedCallData{
+		FunctionName: signature[:open],
+		CallData:     make([]DecodedParams, 0),
+		ReturnData:   make([]DecodedParams, 0),
+		Provenance:   DecodeProvenanceSelector,
+		Confidence:   selectorNameConfidence,
+	}
+	params := signature[open+1 : len(signature)-1]
+	if strings.ContainsAny(params, "()") {
+		return decoded, nil
+	}
+	var args abi.Arguments
+	if params != "" {
+		for _, param := range strings.Split(params, ",") {
+			typ, err := abi.NewType(param, "", nil)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, abi.Argument{Type: typ})
+		}
+	}
+	values, err := args.Unpack(input[4:])
+	if err != nil {
+		return nil, err
+	}
+	decoded.CallData = formatArguments(args, values)
+	decoded.Confidence = selectorDecodedConfidence
+	if packed, err := args.Pack(values...); err == nil && bytes.Equal(packed, input[4:]) {
+		decoded.Confidence = selectorExactConfidence
+	}
+	return decoded, nil
+}
+
+var (
+	selectorCache     *SelectorCache
+	selectorCacheLock sync.RWMutex
+)
+
+// SetSelectorCache installs the node-wide selector cache used by tracers
+// that opt into resolving selectors. Resolution stays disabled until this is
+// called.
+func SetSelectorCache(cache *SelectorCache) {
+	selectorCacheLock.Lock()
+	defer selectorCacheLock.Unlock()
+	selectorCache = cache
+}
+
+// RegisteredSelectorCache returns the node-wide selector cache, or nil if
+// none is set.
+func RegisteredSelectorCache() *SelectorCache {
+	selectorCacheLock.RLock()
+	defer selectorCacheLock.RUnlock()
+	return selectorCache
+}
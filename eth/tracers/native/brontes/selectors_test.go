@@ -0,0 +1,189 @@
+package brontes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectorCache(t *testing.T) {
+	var (
+		transfer = [4]byte{0xa9, 0x05, 0x9c, 0xbb}
+		unknown  = [4]byte{0xde, 0xad, 0xbe, 0xef}
+		requests atomic.Int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Query().Get("hex_signature") != "0xa9059cbb" {
+			fmt.Fprint(w, `{"count":0,"results":[]}`)
+			return
+		}
+		// Colliding signatures are returned newest first.
+		fmt.Fprint(w, `{"count":2,"results":[
+			{"id":200,"text_signature":"many_msg_babbage(bytes1)"},
+			{"id":145,"text_signature":"transfer(address,uint256)"}
+		]}`)
+	}))
+	defer server.Close()
+
+	db := memorydb.New()
+	cache := NewSelectorCache(db, NewFourByteSource(server.URL, 1000))
+	defer cache.Close()
+	signature, err := cache.Signature(context.Background(), transfer)
+	require.NoError(t, err)
+	assert.Equal(t, "transfer(address,uint256)", signature)
+	signature, err = cache.Signature(context.Background(), unknown)
+	require.NoError(t, err)
+	assert.Empty(t, signature)
+
+	// Repeated lookups are served from the cache.
+	count := requests.Load()
+	_, err = cache.Signature(context.Background(), transfer)
+	require.NoError(t, err)
+	_, err = cache.Signature(context.Background(), unknown)
+	require.NoError(t, err)
+	assert.Equal(t, count, requests.Load())
+
+	// Resolved signatures outlive the cache.
+	signature, err = NewSelectorCache(db, nil).Signature(context.Background(), transfer)
+	require.NoError(t, err)
+	assert.Equal(t, "transfer(address,uint256)", signature)
+}
+
+// blockingSource is a SelectorSource resolving every selector to transfer,
+// once released if blocking, or failing if broken.
+type blockingSource struct {
+	release  chan struct{}
+	broken   bool
+	requests atomic.Int32
+}
+
+func (s *blockingSource) Signatures(ctx context.Context, selector [4]byte) ([]string, error) {
+	s.requests.Add(1)
+	if s.release != nil {
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if s.broken {
+		return nil, errors.New("source unavailable")
+	}
+	return []string{"transfer(address,uint256)"}, nil
+}
+
+func TestSelectorCacheSlowSource(t *testing.T) {
+	var (
+		slow   = [4]byte{1}
+		stored = [4]byte{2}
+		source = &blockingSource{release: make(chan struct{})}
+		db     = memorydb.New()
+	)
+	require.NoError(t, db.Put(selectorDBKey(stored), []byte("approve(address,uint256)")))
+	cache := NewSelectorCache(db, source)
+	defer cache.Close()
+
+	lookup := make(chan string)
+	go func() {
+		signature, _ := cache.Signature(context.Background(), slow)
+		lookup <- signature
+	}()
+	require.Eventually(t, func() bool { return source.requests.Load() == 1 }, time.Second, time.Millisecond)
+
+	// Other selectors resolve while the lookup is ongoing, and callers give
+	// up on it with their context.
+	signature, err := cache.Signature(context.Background(), stored)
+	require.NoError(t, err)
+	assert.Equal(t, "approve(address,uint256)", signature)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = cache.Signature(ctx, slow)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(source.release)
+	assert.Equal(t, "transfer(address,uint256)", <-lookup)
+	assert.Equal(t, int32(1), source.requests.Load())
+}
+
+func TestSelectorCacheFailures(t *testing.T) {
+	source := &blockingSource{broken: true}
+	cache := NewSelectorCache(memorydb.New(), source)
+	defer cache.Close()
+
+	_, err := cache.Signature(context.Background(), [4]byte{1})
+	assert.Error(t, err)
+
+	// Failed lookups are not retried for a while.
+	signature, err := cache.Signature(context.Background(), [4]byte{1})
+	require.NoError(t, err)
+	assert.Empty(t, signature)
+	assert.Equal(t, int32(1), source.requests.Load())
+
+	cache.lock.Lock()
+	cache.missing[[4]byte{1}] = time.Now().Add(-time.Second)
+	cache.lock.Unlock()
+	_, err = cache.Signature(context.Background(), [4]byte{1})
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), source.requests.Load())
+}
+
+func TestSelectorCacheDeferred(t *testing.T) {
+	source := &blockingSource{}
+	cache := NewSelectorCache(memorydb.New(), source)
+	defer cache.Close()
+
+	// Unknown selectors are looked up in the background.
+	resolver := cache.Deferred()
+	signature, err := resolver.Signature(context.Background(), [4]byte{1})
+	require.NoError(t, err)
+	assert.Empty(t, signature)
+	require.Eventually(t, func() bool {
+		signature, _ := resolver.Signature(context.Background(), [4]byte{1})
+		return signature == "transfer(address,uint256)"
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(1), source.requests.Load())
+}
+
+func TestDecodeSelectorCallData(t *testing.T) {
+	recipient := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	input := common.FromHex("0xa9059cbb" +
+		"0000000000000000000000002222222222222222222222222222222222222222" +
+		"0000000000000000000000000000000000000000000000000000000000000064")
+
+	decoded, err := DecodeSelectorCallData("transfer(address,uint256)", input)
+	require.NoError(t, err)
+	assert.Equal(t, "transfer", decoded.FunctionName)
+	assert.Equal(t, []DecodedParams{
+		{FieldType: "address", Value: recipient.Hex()},
+		{FieldType: "uint256", Value: "100"},
+	}, decoded.CallData)
+	assert.Equal(t, DecodeProvenanceSelector, decoded.Provenance)
+	assert.Equal(t, selectorExactConfidence, decoded.Confidence)
+
+	// Trailing bytes are not explained by the signature.
+	decoded, err = DecodeSelectorCallData("transfer(address,uint256)", append(input, 0x01))
+	require.NoError(t, err)
+	assert.Len(t, decoded.CallData, 2)
+	assert.Equal(t, selectorDecodedConfidence, decoded.Confidence)
+
+	// Arguments of signatures with tuples are left undecoded.
+	decoded, err = DecodeSelectorCallData("swap((address,uint256),bytes)", input)
+	require.NoError(t, err)
+	assert.Equal(t, "swap", decoded.FunctionName)
+	assert.Empty(t, decoded.CallData)
+	assert.Equal(t, selectorNameConfidence, decoded.Confidence)
+
+	_, err = DecodeSelectorCallData("transfer", input)
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package brontes
+
+import (
+	"maps"
+	"slices"
+)
+
+// SnapshotArena returns a copy of the call frames recorded so far. It may be
+// called from any goroutine while the transaction is still executing, for
+// example to report the progress of long running simulations. Frames which
+// haven't exited yet are included with their results unset.
+func (b *BrontesInspector) SnapshotArena() *CallTraceArena {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	nodes := make([]CallTraceNode, len(b.Traces.Arena))
+	for i, node := range b.Traces.Arena {
+		nodes[i] = node.copy()
+	}
+	return &CallTraceArena{Arena: nodes}
+}
+
+// copy returns a copy of the node sharing no mutable state with it. Recorded
+// steps are never modified, so only the step slices are copied.
+func (ctn *CallTraceNode) copy() CallTraceNode {
+	cpy := *ctn
+	if ctn.Parent != nil {
+		parent := *ctn.Parent
+		cpy.Parent = &parent
+	}
+	cpy.Children = slices.Clone(ctn.Children)
+	cpy.Logs = slices.Clone(ctn.Logs)
+	cpy.Ordering = slices.Clone(ctn.Ordering)
+	cpy.Trace.Steps = slices.Clone(ctn.Trace.Steps)
+	cpy.Trace.SpilledSteps = slices.Clone(ctn.Trace.SpilledSteps)
+	if ctn.Trace.Summary != nil {
+		summary := *ctn.Trace.Summary
+		summary.OpcodeCounts = maps.Clone(ctn.Trace.Summary.OpcodeCounts)
+		cpy.Trace.Summary = &summary
+	}
+	if ctn.Trace.StorageAccess != nil {
+		cpy.Trace.StorageAccess = ctn.Trace.StorageAccess.Copy()
+	}
+	return cpy
+}
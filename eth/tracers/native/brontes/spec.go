@@ -0,0 +1,111 @@
+package brontes
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/forks"
+)
+
+// SpecIdFromRules returns the most recent fork enabled by the given rules.
+// Unlike ChainConfig.LatestFork, this also resolves pre-merge forks, so it can
+// be used to tell apart historical blocks.
+func SpecIdFromRules(rules params.Rules) forks.Fork {
+	switch {
+	case rules.IsOsaka:
+		return forks.Osaka
+	case rules.IsPrague:
+		return forks.Prague
+	case rules.IsCancun:
+		return forks.Cancun
+	case rules.IsShanghai:
+		return forks.Shanghai
+	case rules.IsMerge:
+		return forks.Paris
+	case rules.IsLondon:
+		return forks.London
+	case rules.IsBerlin:
+		return forks.Berlin
+	case rules.IsIstanbul:
+		return forks.Istanbul
+	case rules.IsPetersburg:
+		return forks.Petersburg
+	case rules.IsConstantinople:
+		return forks.Constantinople
+	case rules.IsByzantium:
+		return forks.Byzantium
+	case rules.IsEIP158:
+		return forks.SpuriousDragon
+	case rules.IsEIP150:
+		return forks.TangerineWhistle
+	case rules.IsHomestead:
+		return forks.Homestead
+	default:
+		return forks.Frontier
+	}
+}
+
+// precompileSetKey identifies a distinct set of active precompiles. The fork
+// alone is not enough on Arbitrum chains, where the set depends on ArbOS.
+type precompileSetKey struct {
+	specId     forks.Fork
+	isArbitrum bool
+	isStylus   bool
+}
+
+// precompileSets caches the active precompile set per spec, so inspectors
+// created for every traced transaction share the lookup map instead of
+// rebuilding it. The cached maps must be treated as read-only.
+var precompileSets sync.Map // precompileSetKey -> map[common.Address]struct{}
+
+// activePrecompileSet returns the cached set of precompiles enabled by rules.
+func activePrecompileSet(rules params.Rules) map[common.Address]struct{} {
+	key := precompileSetKey{
+		specId:     SpecIdFromRules(rules),
+		isArbitrum: rules.IsArbitrum,
+		isStylus:   rules.IsStylus,
+	}
+	if set, ok := precompileSets.Load(key); ok {
+		return set.(map[common.Address]struct{})
+	}
+	precompiles := vm.ActivePrecompiles(rules)
+	set := make(map[common.Address]struct{}, len(precompiles))
+	for _, precompile := range precompiles {
+		set[precompile] = struct{}{}
+	}
+	actual, _ := precompileSets.LoadOrStore(key, set)
+	return actual.(map[common.Address]struct{})
+}
+
+var specNames = map[forks.Fork]string{
+	forks.Frontier:         "frontier",
+	forks.FrontierThawing:  "frontier_thawing",
+	forks.Homestead:        "homestead",
+	forks.DAO:              "dao",
+	forks.TangerineWhistle: "tangerine_whistle",
+	forks.SpuriousDragon:   "spurious_dragon",
+	forks.Byzantium:        "byzantium",
+	forks.Constantinople:   "constantinople",
+	forks.Petersburg:       "petersburg",
+	forks.Istanbul:         "istanbul",
+	forks.MuirGlacier:      "muir_glacier",
+	forks.Berlin:           "berlin",
+	forks.London:           "london",
+	forks.ArrowGlacier:     "arrow_glacier",
+	forks.GrayGlacier:      "gray_glacier",
+	forks.Paris:            "paris",
+	forks.Shanghai:         "shanghai",
+	forks.Cancun:           "cancun",
+	forks.Prague:           "prague",
+	forks.Osaka:            "osaka",
+}
+
+// SpecName returns the lowercase name of the given fork, as emitted in traces.
+func SpecName(specId forks.Fork) string {
+	if name, ok := specNames[specId]; ok {
+		return name
+	}
+	return "unknown"
+}
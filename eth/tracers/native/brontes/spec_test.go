@@ -0,0 +1,56 @@
+package brontes
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/forks"
+)
+
+func TestSpecIdFromRules(t *testing.T) {
+	config := params.MainnetChainConfig
+	tests := []struct {
+		number *big.Int
+		merged bool
+		time   uint64
+		want   forks.Fork
+	}{
+		{big.NewInt(0), false, 0, forks.Frontier},
+		{big.NewInt(4_370_000), false, 0, forks.Byzantium},
+		{big.NewInt(12_244_000), false, 0, forks.Berlin},
+		{big.NewInt(15_537_394), true, 1681338455, forks.Shanghai},
+		{big.NewInt(19_426_587), true, 1710338135, forks.Cancun},
+	}
+	for _, tt := range tests {
+		rules := config.Rules(tt.number, tt.merged, tt.time, 0)
+		if have := SpecIdFromRules(rules); have != tt.want {
+			t.Errorf("block %v: spec id mismatch, have %v want %v", tt.number, have, tt.want)
+		}
+	}
+}
+
+func TestActivePrecompileSetCached(t *testing.T) {
+	config := params.MainnetChainConfig
+	byzantium := config.Rules(big.NewInt(4_370_000), false, 0, 0)
+	cancun := config.Rules(big.NewInt(19_426_587), true, 1710338135, 0)
+
+	first, second := activePrecompileSet(cancun), activePrecompileSet(cancun)
+	if len(first) == 0 {
+		t.Fatal("empty precompile set")
+	}
+	first[common.Address{}] = struct{}{}
+	if _, ok := second[common.Address{}]; !ok {
+		t.Fatal("precompile set was rebuilt instead of shared")
+	}
+	delete(first, common.Address{})
+
+	pointEval := common.BytesToAddress([]byte{0x0a})
+	if _, ok := activePrecompileSet(byzantium)[pointEval]; ok {
+		t.Error("point evaluation precompile active before cancun")
+	}
+	if _, ok := activePrecompileSet(cancun)[pointEval]; !ok {
+		t.Error("point evaluation precompile missing after cancun")
+	}
+}
@@ -0,0 +1,154 @@
+package brontes
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// stepOverhead approximates the in-memory size of a step without its stack
+// and memory snapshots.
+const stepOverhead = 160
+
+// StepSpill stores the steps recorded after a transaction exceeded its step
+// memory budget in a temporary file. Frames keep the file offsets of their
+// spilled steps, which are read back one at a time when iterating.
+type StepSpill struct {
+	file   *os.File
+	path   string
+	writer *bufio.Writer
+	offset int64
+}
+
+func newStepSpill() (*StepSpill, error) {
+	file, err := os.CreateTemp("", "brontes-steps-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	// Unlink the file right away where the platform allows it, so it does not
+	// outlive the process if the spill is never closed.
+	path := file.Name()
+	if os.Remove(path) == nil {
+		path = ""
+	}
+	return &StepSpill{file: file, path: path, writer: bufio.NewWriter(file)}, nil
+}
+
+// spilledStep drops any custom marshaling of CallTraceStep, so the spill
+// format does not depend on the trace output format.
+type spilledStep CallTraceStep
+
+// write appends a step to the file, returning its offset.
+func (s *StepSpill) write(step *CallTraceStep) (int64, error) {
+	blob, err := json.Marshal((*spilledStep)(step))
+	if err != nil {
+		return 0, err
+	}
+	offset := s.offset
+	if _, err := s.writer.Write(append(blob, '\n')); err != nil {
+		return 0, err
+	}
+	s.offset += int64(len(blob)) + 1
+	return offset, nil
+}
+
+// read decodes the step stored at the given offset.
+func (s *StepSpill) read(offset int64) (*CallTraceStep, error) {
+	if err := s.writer.Flush(); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(io.NewSectionReader(s.file, offset, s.offset-offset))
+	step := new(spilledStep)
+	if err := dec.Decode(step); err != nil {
+		return nil, err
+	}
+	return (*CallTraceStep)(step), nil
+}
+
+// Close removes the spill file.
+func (s *StepSpill) Close() error {
+	err := s.file.Close()
+	if s.path != "" {
+		err = os.Remove(s.path)
+	}
+	return err
+}
+
+// stepSize approximates the memory held by a recorded step.
+func stepSize(step *CallTraceStep) uint64 {
+	size := uint64(stepOverhead + len(step.Memory.Data))
+	if step.Stack != nil {
+		size += uint64(32 * len(*step.Stack))
+	}
+	if step.PushStack != nil {
+		size += uint64(32 * len(*step.PushStack))
+	}
+	return size
+}
+
+// recordStep keeps a step of the given frame in memory until the step memory
+// budget is exhausted, and spills it to disk afterwards. Once a step spilled,
+// all later ones do too, even if they would fit the budget, so the spilled
+// steps of every frame follow those kept in memory.
+func (b *BrontesInspector) recordStep(node *CallTraceNode, step CallTraceStep) {
+	spilling := b.spill != nil || b.spillErr != nil
+	if !spilling && (b.Config.StepMemoryBudget == 0 || b.stepMemory+stepSize(&step) <= b.Config.StepMemoryBudget) {
+		b.stepMemory += stepSize(&step)
+		node.Trace.Steps = append(node.Trace.Steps, step)
+		return
+	}
+	if !spilling {
+		b.spill, b.spillErr = newStepSpill()
+	}
+	if b.spillErr != nil {
+		return
+	}
+	offset, err := b.spill.write(&step)
+	if err != nil {
+		b.spillErr = err
+		return
+	}
+	node.Trace.SpilledSteps = append(node.Trace.SpilledSteps, offset)
+}
+
+// StepCount returns the number of steps recorded for the frame, including
+// the ones spilled to disk.
+func (ct *CallTrace) StepCount() int {
+	return len(ct.Steps) + len(ct.SpilledSteps)
+}
+
+// IterSteps calls fn with every recorded step of the frame in execution
+// order, reading spilled steps back from disk as needed. It fails if steps
+// were lost because the spill file could not be written.
+func (b *BrontesInspector) IterSteps(node *CallTraceNode, fn func(*CallTraceStep) error) error {
+	if b.spillErr != nil {
+		return b.spillErr
+	}
+	for i := range node.Trace.Steps {
+		if err := fn(&node.Trace.Steps[i]); err != nil {
+			return err
+		}
+	}
+	for _, offset := range node.Trace.SpilledSteps {
+		step, err := b.spill.read(offset)
+		if err != nil {
+			return err
+		}
+		if err := fn(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the resources held by the inspector, removing the spilled
+// steps. The recorded traces must not be used afterwards.
+func (b *BrontesInspector) Close() error {
+	if b == nil || b.spill == nil {
+		return nil
+	}
+	err := b.spill.Close()
+	b.spill = nil
+	return err
+}
@@ -0,0 +1,81 @@
+package brontes
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepSpill(t *testing.T) {
+	inspector := &BrontesInspector{Traces: NewCallTraceArena()}
+	inspector.Config.StepMemoryBudget = 2 * stepOverhead
+	node := &inspector.Traces.Arena[0]
+
+	for pc := 0; pc < 5; pc++ {
+		stack := []uint256.Int{*uint256.NewInt(uint64(pc))}
+		step := CallTraceStep{Pc: pc, Op: vm.PUSH1, Stack: &stack, GasRemaining: 100 - uint64(pc)}
+		if pc == 4 {
+			step.Op = vm.SLOAD
+			step.StorageChange = &StorageChange{Key: big.NewInt(1), Value: big.NewInt(2), Reason: StorageChangeReasonSLOAD}
+		}
+		inspector.recordStep(node, step)
+	}
+	// Only the first step fits the budget alongside its stack snapshot.
+	assert.Len(t, node.Trace.Steps, 1)
+	assert.Len(t, node.Trace.SpilledSteps, 4)
+	assert.Equal(t, 5, node.Trace.StepCount())
+	require.NotNil(t, inspector.spill)
+	path := inspector.spill.file.Name()
+
+	var steps []*CallTraceStep
+	require.NoError(t, inspector.IterSteps(node, func(step *CallTraceStep) error {
+		steps = append(steps, step)
+		return nil
+	}))
+	require.Len(t, steps, 5)
+	for pc, step := range steps {
+		assert.Equal(t, pc, step.Pc)
+		assert.Equal(t, uint64(pc), (*step.Stack)[0].Uint64())
+		assert.Equal(t, 100-uint64(pc), step.GasRemaining)
+	}
+	assert.Equal(t, vm.SLOAD, steps[4].Op)
+	assert.Equal(t, big.NewInt(2), steps[4].StorageChange.Value)
+
+	require.NoError(t, inspector.Close())
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStepSpillOrder(t *testing.T) {
+	inspector := &BrontesInspector{Traces: NewCallTraceArena()}
+	inspector.Config.StepMemoryBudget = 2 * stepOverhead
+	node := &inspector.Traces.Arena[0]
+	defer inspector.Close()
+
+	// The second step exceeds the budget with its memory snapshot, the third
+	// would fit but follows a spilled step.
+	inspector.recordStep(node, CallTraceStep{Pc: 0})
+	inspector.recordStep(node, CallTraceStep{Pc: 1, Memory: RecordedMemory{Data: make([]byte, 2*stepOverhead)}})
+	inspector.recordStep(node, CallTraceStep{Pc: 2})
+	assert.Len(t, node.Trace.Steps, 1)
+	assert.Len(t, node.Trace.SpilledSteps, 2)
+
+	// Arena results stream the steps in execution order from the spill file.
+	steps := &ArenaSteps{inspector: inspector, node: node}
+	blob, err := json.Marshal(steps)
+	require.NoError(t, err)
+	var decoded []struct {
+		Pc int `json:"pc"`
+	}
+	require.NoError(t, json.Unmarshal(blob, &decoded))
+	require.Len(t, decoded, 3)
+	for pc, step := range decoded {
+		assert.Equal(t, pc, step.Pc)
+	}
+}
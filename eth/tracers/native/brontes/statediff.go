@@ -0,0 +1,333 @@
+package brontes
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Diff is the value of a field before and after the transaction.
+type Diff[T any] struct {
+	From T `json:"from"`
+	To   T `json:"to"`
+}
+
+// AccountDiff holds the fields of an account changed by the transaction,
+// unchanged fields are left out.
+type AccountDiff struct {
+	Balance  *Diff[*hexutil.Big]               `json:"balance,omitempty"`
+	Nonce    *Diff[hexutil.Uint64]             `json:"nonce,omitempty"`
+	CodeHash *Diff[common.Hash]                `json:"code_hash,omitempty"`
+	Storage  map[common.Hash]Diff[common.Hash] `json:"storage,omitempty"`
+}
+
+// StateDiffDiscrepancy is a difference between the state diff accumulated
+// from the state change hooks and the state at the end of the transaction.
+type StateDiffDiscrepancy struct {
+	Address common.Address `json:"address"`
+	// Field is the field of the account that differs, one of "balance",
+	// "nonce", "code_hash" and "storage", or "untracked" for accounts changed
+	// by the transaction without any hook reporting it.
+	Field    string       `json:"field"`
+	Slot     *common.Hash `json:"slot,omitempty"`
+	Recorded string       `json:"recorded,omitempty"`
+	Actual   string       `json:"actual,omitempty"`
+}
+
+// dirtyAccountsLister is implemented by states listing the accounts changed
+// since the last commit.
+type dirtyAccountsLister interface {
+	GetDirtyAccounts() []common.Address
+}
+
+// stateDiffRecorder accumulates the state diff of a transaction from the
+// state change hooks.
+type stateDiffRecorder struct {
+	order    []common.Address // accounts in order of first change
+	accounts map[common.Address]*recordedAccount
+	// dirtyBefore holds the accounts changed before the transaction, nil if
+	// the state cannot list them.
+	dirtyBefore map[common.Address]struct{}
+	// observed holds the accounts entered by the transaction as they were
+	// first seen, telling merely touched accounts apart from changed ones.
+	observed map[common.Address]observedAccount
+}
+
+// observedAccount is an account as first seen by the transaction.
+type observedAccount struct {
+	balance  *big.Int
+	nonce    uint64
+	codeHash common.Hash
+}
+
+// recordedAccount holds the first previous and the last new value of every
+// changed field of an account.
+type recordedAccount struct {
+	balance  *Diff[*big.Int]
+	nonce    *Diff[uint64]
+	codeHash *Diff[common.Hash]
+	storage  map[common.Hash]*Diff[common.Hash]
+}
+
+func newStateDiffRecorder(statedb tracing.StateDB) *stateDiffRecorder {
+	r := &stateDiffRecorder{
+		accounts: make(map[common.Address]*recordedAccount),
+		observed: make(map[common.Address]observedAccount),
+	}
+	if lister, ok := statedb.(dirtyAccountsLister); ok {
+		r.dirtyBefore = make(map[common.Address]struct{})
+		for _, addr := range lister.GetDirtyAccounts() {
+			r.dirtyBefore[addr] = struct{}{}
+		}
+	}
+	return r
+}
+
+// observe keeps an account as first seen by the transaction.
+func (r *stateDiffRecorder) observe(statedb tracing.StateDB, addr common.Address) {
+	if _, ok := r.observed[addr]; ok {
+		return
+	}
+	r.observed[addr] = observeAccount(statedb, addr)
+}
+
+func observeAccount(statedb tracing.StateDB, addr common.Address) observedAccount {
+	return observedAccount{
+		balance:  statedb.GetBalance(addr).ToBig(),
+		nonce:    statedb.GetNonce(addr),
+		codeHash: statedb.GetCodeHash(addr),
+	}
+}
+
+// empty reports whether the account is empty as defined by EIP-161.
+func (a observedAccount) empty() bool {
+	return a.balance.Sign() == 0 && a.nonce == 0 && (a.codeHash == common.Hash{} || a.codeHash == types.EmptyCodeHash)
+}
+
+// account returns the recorded changes of an account, adding it on first
+// change.
+func (r *stateDiffRecorder) account(addr common.Address) *recordedAccount {
+	acc, ok := r.accounts[addr]
+	if !ok {
+		acc = new(recordedAccount)
+		r.accounts[addr] = acc
+		r.order = append(r.order, addr)
+	}
+	return acc
+}
+
+func (r *stateDiffRecorder) onBalanceChange(addr common.Address, prev, new *big.Int) {
+	acc := r.account(addr)
+	if acc.balance == nil {
+		acc.balance = &Diff[*big.Int]{From: new0(prev)}
+	}
+	acc.balance.To = new0(new)
+}
+
+func (r *stateDiffRecorder) onNonceChange(addr common.Address, prev, new uint64) {
+	acc := r.account(addr)
+	if acc.nonce == nil {
+		acc.nonce = &Diff[uint64]{From: prev}
+	}
+	acc.nonce.To = new
+}
+
+func (r *stateDiffRecorder) onCodeChange(addr common.Address, prevCodeHash, codeHash common.Hash) {
+	acc := r.account(addr)
+	if acc.codeHash == nil {
+		acc.codeHash = &Diff[common.Hash]{From: prevCodeHash}
+	}
+	acc.codeHash.To = codeHash
+}
+
+func (r *stateDiffRecorder) onStorageChange(addr common.Address, slot, prev, new common.Hash) {
+	acc := r.account(addr)
+	if acc.storage == nil {
+		acc.storage = make(map[common.Hash]*Diff[common.Hash])
+	}
+	diff, ok := acc.storage[slot]
+	if !ok {
+		diff = &Diff[common.Hash]{From: prev}
+		acc.storage[slot] = diff
+	}
+	diff.To = new
+}
+
+// new0 copies a balance, treating nil as zero.
+func new0(value *big.Int) *big.Int {
+	if value == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(value)
+}
+
+// diff returns the accumulated changes. Fields changed back to their
+// original value are left out.
+func (r *stateDiffRecorder) diff() map[common.Address]*AccountDiff {
+	if r == nil {
+		return nil
+	}
+	result := make(map[common.Address]*AccountDiff)
+	for _, addr := range r.order {
+		var (
+			acc  = r.accounts[addr]
+			diff = new(AccountDiff)
+		)
+		if acc.balance != nil && acc.balance.From.Cmp(acc.balance.To) != 0 {
+			diff.Balance = &Diff[*hexutil.Big]{From: (*hexutil.Big)(acc.balance.From), To: (*hexutil.Big)(acc.balance.To)}
+		}
+		if acc.nonce != nil && acc.nonce.From != acc.nonce.To {
+			diff.Nonce = &Diff[hexutil.Uint64]{From: hexutil.Uint64(acc.nonce.From), To: hexutil.Uint64(acc.nonce.To)}
+		}
+		if acc.codeHash != nil && acc.codeHash.From != acc.codeHash.To {
+			diff.CodeHash = acc.codeHash
+		}
+		for slot, change := range acc.storage {
+			if change.From != change.To {
+				if diff.Storage == nil {
+					diff.Storage = make(map[common.Hash]Diff[common.Hash])
+				}
+				diff.Storage[slot] = *change
+			}
+		}
+		if diff.Balance != nil || diff.Nonce != nil || diff.CodeHash != nil || diff.Storage != nil {
+			result[addr] = diff
+		}
+	}
+	return result
+}
+
+// validate cross-checks the accumulated diff against the state at the end of
+// the transaction. Every recorded field must hold its last recorded value,
+// and every account changed by the transaction must have been recorded.
+// Accounts changed by earlier transactions of the block cannot be told apart,
+// so changes of those that no hook reported go unnoticed.
+func (r *stateDiffRecorder) validate(statedb tracing.StateDB) []StateDiffDiscrepancy {
+	var discrepancies []StateDiffDiscrepancy
+	mismatch := func(addr common.Address, field string, slot *common.Hash, recorded, actual interface{}) {
+		discrepancies = append(discrepancies, StateDiffDiscrepancy{
+			Address:  addr,
+			Field:    field,
+			Slot:     slot,
+			Recorded: fmt.Sprint(recorded),
+			Actual:   fmt.Sprint(actual),
+		})
+	}
+	for _, addr := range r.order {
+		acc := r.accounts[addr]
+		if acc.balance != nil {
+			if actual := statedb.GetBalance(addr).ToBig(); actual.Cmp(acc.balance.To) != 0 {
+				mismatch(addr, "balance", nil, acc.balance.To, actual)
+			}
+		}
+		if acc.nonce != nil {
+			if actual := statedb.GetNonce(addr); actual != acc.nonce.To {
+				mismatch(addr, "nonce", nil, acc.nonce.To, actual)
+			}
+		}
+		// Accounts without code report the empty code hash once they exist,
+		// and none at all once deleted.
+		if acc.codeHash != nil && statedb.Exist(addr) {
+			if actual := statedb.GetCodeHash(addr); actual != acc.codeHash.To {
+				mismatch(addr, "code_hash", nil, acc.codeHash.To.Hex(), actual.Hex())
+			}
+		}
+		slots := make([]common.Hash, 0, len(acc.storage))
+		for slot := range acc.storage {
+			slots = append(slots, slot)
+		}
+		slices.SortFunc(slots, common.Hash.Cmp)
+		for _, slot := range slots {
+			if actual := statedb.GetState(addr, slot); actual != acc.storage[slot].To {
+				mismatch(addr, "storage", &slot, acc.storage[slot].To.Hex(), actual.Hex())
+			}
+		}
+	}
+	lister, ok := statedb.(dirtyAccountsLister)
+	if !ok || r.dirtyBefore == nil {
+		return discrepancies
+	}
+	var untracked []common.Address
+	for _, addr := range lister.GetDirtyAccounts() {
+		if _, ok := r.dirtyBefore[addr]; ok {
+			continue
+		}
+		if _, ok := r.accounts[addr]; ok {
+			continue
+		}
+		// Touched accounts are dirty without any change: those left empty
+		// are deleted, and entered ones must differ from when first seen.
+		if !statedb.Exist(addr) {
+			continue
+		}
+		actual := observeAccount(statedb, addr)
+		if actual.empty() {
+			continue
+		}
+		if seen, ok := r.observed[addr]; ok && seen.balance.Cmp(actual.balance) == 0 && seen.nonce == actual.nonce && seen.codeHash == actual.codeHash {
+			continue
+		}
+		untracked = append(untracked, addr)
+	}
+	slices.SortFunc(untracked, common.Address.Cmp)
+	for _, addr := range untracked {
+		discrepancies = append(discrepancies, StateDiffDiscrepancy{Address: addr, Field: "untracked"})
+	}
+	return discrepancies
+}
+
+// OnBalanceChange records a balance change if the state diff is recorded.
+func (b *BrontesInspector) OnBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	if b.stateDiff == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.stateDiff.onBalanceChange(addr, prev, new)
+}
+
+// OnNonceChange records a nonce change if the state diff is recorded.
+func (b *BrontesInspector) OnNonceChange(addr common.Address, prev, new uint64) {
+	if b.stateDiff == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.stateDiff.onNonceChange(addr, prev, new)
+}
+
+// OnCodeChange records a code change if the state diff is recorded.
+func (b *BrontesInspector) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	if b.stateDiff == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.stateDiff.onCodeChange(addr, prevCodeHash, codeHash)
+}
+
+// OnStorageChange records a storage change if the state diff is recorded.
+func (b *BrontesInspector) OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+	if b.stateDiff == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.stateDiff.onStorageChange(addr, slot, prev, new)
+}
+
+// OnTxEnd cross-checks the recorded state diff against the state once the
+// transaction is complete, keeping the discrepancies for the results.
+func (b *BrontesInspector) OnTxEnd() {
+	if b.stateDiff == nil || b.VMContext.StateDB == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.stateDiffDiscrepancies = b.stateDiff.validate(b.VMContext.StateDB)
+}
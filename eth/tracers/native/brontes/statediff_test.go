@@ -0,0 +1,63 @@
+package brontes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateDiffValidation(t *testing.T) {
+	var (
+		from    = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to      = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		touched = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		changed = common.HexToAddress("0x4444444444444444444444444444444444444444")
+		slot    = common.HexToHash("0x01")
+	)
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	require.NoError(t, err)
+	statedb.SetNonce(touched, 1, tracing.NonceChangeUnspecified)
+	statedb.IntermediateRoot(true)
+
+	config := DefaultTracingInspectorConfig
+	config.RecordStateDiff = true
+	var (
+		env = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1, StateDB: statedb}
+		tx  = types.NewTx(&types.LegacyTx{To: &to, Gas: 100000})
+	)
+	inspector := NewBrontesInspector(context.Background(), config, params.MainnetChainConfig, env, tx, from)
+	require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+
+	// A recorded change matching the state.
+	statedb.SetNonce(from, 1, tracing.NonceChangeEoACall)
+	inspector.OnNonceChange(from, 0, 1)
+	// A recorded change the state does not hold.
+	statedb.SetState(to, slot, common.HexToHash("0x02"))
+	inspector.OnStorageChange(to, slot, common.Hash{}, common.HexToHash("0x03"))
+	// An entered account merely touched, and one changed without a hook.
+	require.NoError(t, inspector.OnEnter(1, byte(vm.CALL), to, touched, nil, 50000, big.NewInt(0)))
+	statedb.AddBalance(touched, new(uint256.Int), tracing.BalanceChangeTransfer)
+	inspector.OnExit(1, nil, 0, nil, false)
+	statedb.SetNonce(changed, 1, tracing.NonceChangeUnspecified)
+	inspector.OnExit(0, nil, 0, nil, false)
+	inspector.OnTxEnd()
+
+	result, err := inspector.IntoTraceResults(tx, &types.Receipt{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, &Diff[hexutil.Uint64]{From: 0, To: 1}, result.StateDiff[from].Nonce)
+	assert.Equal(t, []StateDiffDiscrepancy{
+		{Address: to, Field: "storage", Slot: &slot, Recorded: common.HexToHash("0x03").Hex(), Actual: common.HexToHash("0x02").Hex()},
+		{Address: changed, Field: "untracked"},
+	}, result.StateDiffDiscrepancies)
+}
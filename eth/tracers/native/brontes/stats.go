@@ -0,0 +1,32 @@
+package brontes
+
+// TxStats summarizes the shape of a transaction's call tree, for monitoring
+// transaction complexity without parsing the full trace.
+type TxStats struct {
+	TotalFrames   int              `json:"total_frames"`
+	MaxDepth      int              `json:"max_depth"`   // depth of the deepest frame, 0 for the top-level call
+	MaxFanOut     int              `json:"max_fan_out"` // most subcalls made by a single frame
+	FramesPerKind map[CallKind]int `json:"frames_per_kind"`
+	LogCount      int              `json:"log_count"`
+}
+
+// NewTxStats computes the statistics of the given call tree nodes. Precompile
+// calls are skipped, matching the frames included in the trace.
+func NewTxStats(nodes []CallTraceNode) *TxStats {
+	stats := &TxStats{FramesPerKind: make(map[CallKind]int)}
+	if len(nodes) == 0 {
+		return stats
+	}
+	rootDepth := nodes[0].Trace.Depth
+	for _, node := range nodes {
+		if node.IsPrecompile() {
+			continue
+		}
+		stats.TotalFrames++
+		stats.FramesPerKind[node.Kind()]++
+		stats.LogCount += len(node.Logs)
+		stats.MaxDepth = max(stats.MaxDepth, node.Trace.Depth-rootDepth)
+		stats.MaxFanOut = max(stats.MaxFanOut, len(node.Children))
+	}
+	return stats
+}
@@ -0,0 +1,33 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTxStats(t *testing.T) {
+	precompile := true
+	arena := NewCallTraceArena()
+	arena.PushTrace(0, PushTraceKindPushAndAttachToParent, CallTrace{Depth: 0, Kind: CallKindCall})
+	arena.PushTrace(0, PushTraceKindPushAndAttachToParent, CallTrace{Depth: 1, Kind: CallKindStaticCall})
+	arena.PushTrace(0, PushTraceKindPushAndAttachToParent, CallTrace{Depth: 1, Kind: CallKindDelegateCall})
+	arena.PushTrace(0, PushTraceKindPushAndAttachToParent, CallTrace{Depth: 2, Kind: CallKindCreate})
+	arena.PushTrace(0, PushTraceKindPushAndAttachToParent, CallTrace{Depth: 1, Kind: CallKindStaticCall, MaybePrecompile: &precompile})
+	arena.Arena[0].Logs = []LogData{{}}
+	arena.Arena[3].Logs = []LogData{{}, {}}
+
+	stats := NewTxStats(arena.Nodes())
+	assert.Equal(t, &TxStats{
+		TotalFrames: 4,
+		MaxDepth:    2,
+		MaxFanOut:   3,
+		FramesPerKind: map[CallKind]int{
+			CallKindCall:         1,
+			CallKindStaticCall:   1,
+			CallKindDelegateCall: 1,
+			CallKindCreate:       1,
+		},
+		LogCount: 3,
+	}, stats)
+}
@@ -0,0 +1,196 @@
+package brontes
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+)
+
+// stepJSON is the wire format of a CallTraceStep. Opcodes are encoded by
+// name, stack values as hex quantities and the memory as hex chunks of 32
+// bytes. Snapshots which were not recorded are left out.
+type stepJSON struct {
+	Depth            int                `json:"depth"`
+	Pc               int                `json:"pc"`
+	Op               string             `json:"op"`
+	Contract         common.Address     `json:"contract"`
+	Stack            *[]string          `json:"stack,omitempty"`
+	PushStack        *[]string          `json:"push_stack,omitempty"`
+	Memory           []string           `json:"memory,omitempty"`
+	MemorySize       int                `json:"memory_size"`
+	MemorySizeAfter  int                `json:"memory_size_after"`
+	MemoryExpansion  int                `json:"memory_expansion"`     // derived, ignored when decoding
+	MemoryGas        uint64             `json:"memory_expansion_gas"` // derived, ignored when decoding
+	GasRemaining     uint64             `json:"gas_remaining"`
+	GasRefundCounter uint64             `json:"gas_refund_counter"`
+	GasCost          uint64             `json:"gas_cost"`
+	StorageChange    *storageChangeJSON `json:"storage_change,omitempty"`
+	LogIndex         *int               `json:"log_index,omitempty"`
+}
+
+type storageChangeJSON struct {
+	Key      *hexutil.Big `json:"key"`
+	Value    *hexutil.Big `json:"value"`
+	HadValue *hexutil.Big `json:"had_value,omitempty"`
+	Reason   string       `json:"reason"`
+}
+
+// opName returns the name of an opcode, or its hex value if undefined.
+func opName(op vm.OpCode) string {
+	if vm.StringToOp(op.String()) == op {
+		return op.String()
+	}
+	return fmt.Sprintf("0x%02x", byte(op))
+}
+
+// parseOpName is the inverse of opName.
+func parseOpName(name string) (vm.OpCode, error) {
+	if op := vm.StringToOp(name); op != vm.STOP || name == "STOP" {
+		return op, nil
+	}
+	if strings.HasPrefix(name, "0x") {
+		if v, err := strconv.ParseUint(name[2:], 16, 8); err == nil {
+			return vm.OpCode(v), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown opcode %q", name)
+}
+
+// String returns the name of the reason used in the trace output.
+func (r StorageChangeReason) String() string {
+	switch r {
+	case StorageChangeReasonSLOAD:
+		return "sload"
+	case StorageChangeReasonSSTORE:
+		return "sstore"
+	case StorageChangeReasonTLOAD:
+		return "tload"
+	case StorageChangeReasonTSTORE:
+		return "tstore"
+	}
+	return fmt.Sprintf("reason %d", int(r))
+}
+
+func parseStorageChangeReason(name string) (StorageChangeReason, error) {
+	for r := StorageChangeReasonSLOAD; r <= StorageChangeReasonTSTORE; r++ {
+		if r.String() == name {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown storage change reason %q", name)
+}
+
+func encodeStack(stack *[]uint256.Int) *[]string {
+	if stack == nil || *stack == nil {
+		return nil
+	}
+	values := make([]string, len(*stack))
+	for i := range *stack {
+		values[i] = (*stack)[i].Hex()
+	}
+	return &values
+}
+
+func decodeStack(values *[]string) (*[]uint256.Int, error) {
+	if values == nil {
+		return nil, nil
+	}
+	stack := make([]uint256.Int, len(*values))
+	for i, value := range *values {
+		v, err := uint256.FromHex(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stack value %q: %w", value, err)
+		}
+		stack[i] = *v
+	}
+	return &stack, nil
+}
+
+// MarshalJSON encodes the step in its stable wire format.
+func (s CallTraceStep) MarshalJSON() ([]byte, error) {
+	enc := stepJSON{
+		Depth:            s.Depth,
+		Pc:               s.Pc,
+		Op:               opName(s.Op),
+		Contract:         s.Contract,
+		Stack:            encodeStack(s.Stack),
+		PushStack:        encodeStack(s.PushStack),
+		Memory:           s.Memory.MemoryChunks(),
+		MemorySize:       s.MemorySize,
+		MemorySizeAfter:  s.MemorySizeAfter,
+		MemoryExpansion:  s.MemoryExpansion(),
+		MemoryGas:        s.MemoryExpansionGas(),
+		GasRemaining:     s.GasRemaining,
+		GasRefundCounter: s.GasRefundCounter,
+		GasCost:          s.GasCost,
+		LogIndex:         s.LogIndex,
+	}
+	if change := s.StorageChange; change != nil {
+		enc.StorageChange = &storageChangeJSON{
+			Key:      (*hexutil.Big)(change.Key),
+			Value:    (*hexutil.Big)(change.Value),
+			HadValue: (*hexutil.Big)(change.HadValue),
+			Reason:   change.Reason.String(),
+		}
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON decodes a step from its stable wire format.
+func (s *CallTraceStep) UnmarshalJSON(input []byte) error {
+	var dec stepJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	op, err := parseOpName(dec.Op)
+	if err != nil {
+		return err
+	}
+	stack, err := decodeStack(dec.Stack)
+	if err != nil {
+		return err
+	}
+	pushStack, err := decodeStack(dec.PushStack)
+	if err != nil {
+		return err
+	}
+	memory, err := ParseMemoryChunks(dec.Memory)
+	if err != nil {
+		return err
+	}
+	*s = CallTraceStep{
+		Depth:            dec.Depth,
+		Pc:               dec.Pc,
+		Op:               op,
+		Contract:         dec.Contract,
+		Stack:            stack,
+		PushStack:        pushStack,
+		Memory:           RecordedMemory{Data: memory},
+		MemorySize:       dec.MemorySize,
+		MemorySizeAfter:  dec.MemorySizeAfter,
+		GasRemaining:     dec.GasRemaining,
+		GasRefundCounter: dec.GasRefundCounter,
+		GasCost:          dec.GasCost,
+		LogIndex:         dec.LogIndex,
+	}
+	if change := dec.StorageChange; change != nil {
+		reason, err := parseStorageChangeReason(change.Reason)
+		if err != nil {
+			return err
+		}
+		s.StorageChange = &StorageChange{
+			Key:      (*big.Int)(change.Key),
+			Value:    (*big.Int)(change.Value),
+			HadValue: (*big.Int)(change.HadValue),
+			Reason:   reason,
+		}
+	}
+	return nil
+}
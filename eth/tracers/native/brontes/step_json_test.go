@@ -0,0 +1,81 @@
+package brontes
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSteps() []CallTraceStep {
+	var (
+		stack     = []uint256.Int{*uint256.NewInt(1), *uint256.NewInt(0xabcdef)}
+		pushStack = []uint256.Int{}
+		logIndex  = 2
+		memory    = make([]byte, 40)
+	)
+	memory[0], memory[39] = 0xaa, 0xbb
+	return []CallTraceStep{
+		{
+			Depth:            2,
+			Pc:               17,
+			Op:               vm.SSTORE,
+			Contract:         common.HexToAddress("0x2222222222222222222222222222222222222222"),
+			Stack:            &stack,
+			PushStack:        &pushStack,
+			Memory:           RecordedMemory{Data: memory},
+			MemorySize:       64,
+			MemorySizeAfter:  96,
+			GasRemaining:     90000,
+			GasRefundCounter: 4800,
+			GasCost:          20000,
+			StorageChange: &StorageChange{
+				Key:      big.NewInt(1),
+				Value:    big.NewInt(7),
+				HadValue: big.NewInt(5),
+				Reason:   StorageChangeReasonSSTORE,
+			},
+		},
+		{Depth: 1, Op: vm.LOG2, LogIndex: &logIndex},
+		{Depth: 1, Pc: 3, Op: vm.OpCode(0x0c)}, // undefined
+	}
+}
+
+func TestCallTraceStepJSON(t *testing.T) {
+	steps := testSteps()
+	blob, err := json.Marshal(steps)
+	require.NoError(t, err)
+
+	var fields []map[string]interface{}
+	require.NoError(t, json.Unmarshal(blob, &fields))
+	assert.Equal(t, "SSTORE", fields[0]["op"])
+	assert.Equal(t, []interface{}{"0x1", "0xabcdef"}, fields[0]["stack"])
+	assert.Equal(t, []interface{}{}, fields[0]["push_stack"])
+	assert.Equal(t, []interface{}{
+		"0xaa00000000000000000000000000000000000000000000000000000000000000",
+		"0x00000000000000bb",
+	}, fields[0]["memory"])
+	assert.Equal(t, "sstore", fields[0]["storage_change"].(map[string]interface{})["reason"])
+	assert.Equal(t, float64(32), fields[0]["memory_expansion"])
+	assert.Equal(t, float64(3), fields[0]["memory_expansion_gas"])
+	assert.Equal(t, float64(2), fields[1]["log_index"])
+	assert.NotContains(t, fields[1], "stack")
+	assert.Equal(t, "0x0c", fields[2]["op"])
+
+	var decoded []CallTraceStep
+	require.NoError(t, json.Unmarshal(blob, &decoded))
+	assert.Equal(t, steps, decoded)
+}
+
+func TestCallTraceStepProto(t *testing.T) {
+	for i, step := range testSteps() {
+		var decoded CallTraceStep
+		require.NoError(t, decoded.UnmarshalProto(step.MarshalProto()))
+		assert.Equal(t, step, decoded, "step %d", i)
+	}
+}
@@ -0,0 +1,224 @@
+package brontes
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the CallTraceStep message, see step.proto.
+const (
+	stepFieldDepth protowire.Number = iota + 1
+	stepFieldPc
+	stepFieldOp
+	stepFieldContract
+	stepFieldStack
+	stepFieldPushStack
+	stepFieldMemory
+	stepFieldMemorySize
+	stepFieldGasRemaining
+	stepFieldGasRefundCounter
+	stepFieldGasCost
+	stepFieldStorageChange
+	stepFieldLogIndex
+	stepFieldHasStack
+	stepFieldHasPushStack
+	stepFieldMemorySizeAfter
+)
+
+// Field numbers of the StorageChange message, see step.proto.
+const (
+	changeFieldKey protowire.Number = iota + 1
+	changeFieldValue
+	changeFieldHadValue
+	changeFieldReason
+)
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendStack(b []byte, num, has protowire.Number, stack *[]uint256.Int) []byte {
+	if stack == nil || *stack == nil {
+		return b
+	}
+	for i := range *stack {
+		b = appendBytes(b, num, (*stack)[i].Bytes())
+	}
+	return appendVarint(b, has, 1)
+}
+
+// MarshalProto encodes the step as a CallTraceStep protobuf message.
+func (s *CallTraceStep) MarshalProto() []byte {
+	var b []byte
+	b = appendVarint(b, stepFieldDepth, uint64(s.Depth))
+	b = appendVarint(b, stepFieldPc, uint64(s.Pc))
+	b = appendVarint(b, stepFieldOp, uint64(s.Op))
+	b = appendBytes(b, stepFieldContract, s.Contract.Bytes())
+	b = appendStack(b, stepFieldStack, stepFieldHasStack, s.Stack)
+	b = appendStack(b, stepFieldPushStack, stepFieldHasPushStack, s.PushStack)
+	if len(s.Memory.Data) > 0 {
+		b = appendBytes(b, stepFieldMemory, s.Memory.Data)
+	}
+	b = appendVarint(b, stepFieldMemorySize, uint64(s.MemorySize))
+	b = appendVarint(b, stepFieldMemorySizeAfter, uint64(s.MemorySizeAfter))
+	b = appendVarint(b, stepFieldGasRemaining, s.GasRemaining)
+	b = appendVarint(b, stepFieldGasRefundCounter, s.GasRefundCounter)
+	b = appendVarint(b, stepFieldGasCost, s.GasCost)
+	if change := s.StorageChange; change != nil {
+		var c []byte
+		if change.Key != nil {
+			c = appendBytes(c, changeFieldKey, change.Key.Bytes())
+		}
+		if change.Value != nil {
+			c = appendBytes(c, changeFieldValue, change.Value.Bytes())
+		}
+		if change.HadValue != nil {
+			c = appendBytes(c, changeFieldHadValue, change.HadValue.Bytes())
+		}
+		c = appendVarint(c, changeFieldReason, uint64(change.Reason))
+		b = appendBytes(b, stepFieldStorageChange, c)
+	}
+	if s.LogIndex != nil {
+		b = appendVarint(b, stepFieldLogIndex, uint64(*s.LogIndex))
+	}
+	return b
+}
+
+// consumeField reads the next field of a message, returning its number and
+// either its varint or its bytes value.
+func consumeField(b []byte) (protowire.Number, uint64, []byte, int, error) {
+	num, typ, n := protowire.ConsumeTag(b)
+	if n < 0 {
+		return 0, 0, nil, 0, protowire.ParseError(n)
+	}
+	switch typ {
+	case protowire.VarintType:
+		v, m := protowire.ConsumeVarint(b[n:])
+		if m < 0 {
+			return 0, 0, nil, 0, protowire.ParseError(m)
+		}
+		return num, v, nil, n + m, nil
+	case protowire.BytesType:
+		v, m := protowire.ConsumeBytes(b[n:])
+		if m < 0 {
+			return 0, 0, nil, 0, protowire.ParseError(m)
+		}
+		return num, 0, v, n + m, nil
+	default:
+		// Skip fields of unknown types for forward compatibility.
+		m := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if m < 0 {
+			return 0, 0, nil, 0, protowire.ParseError(m)
+		}
+		return 0, 0, nil, n + m, nil
+	}
+}
+
+// UnmarshalProto decodes a step from a CallTraceStep protobuf message.
+func (s *CallTraceStep) UnmarshalProto(b []byte) error {
+	var (
+		step                   CallTraceStep
+		stack, pushStack       []uint256.Int
+		hasStack, hasPushStack bool
+	)
+	for len(b) > 0 {
+		num, v, bytes, n, err := consumeField(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+		switch num {
+		case stepFieldDepth:
+			step.Depth = int(v)
+		case stepFieldPc:
+			step.Pc = int(v)
+		case stepFieldOp:
+			if v > 0xff {
+				return fmt.Errorf("invalid opcode %d", v)
+			}
+			step.Op = vm.OpCode(v)
+		case stepFieldContract:
+			step.Contract = common.BytesToAddress(bytes)
+		case stepFieldStack:
+			stack = append(stack, *new(uint256.Int).SetBytes(bytes))
+		case stepFieldPushStack:
+			pushStack = append(pushStack, *new(uint256.Int).SetBytes(bytes))
+		case stepFieldMemory:
+			step.Memory = RecordedMemory{Data: common.CopyBytes(bytes)}
+		case stepFieldMemorySize:
+			step.MemorySize = int(v)
+		case stepFieldMemorySizeAfter:
+			step.MemorySizeAfter = int(v)
+		case stepFieldGasRemaining:
+			step.GasRemaining = v
+		case stepFieldGasRefundCounter:
+			step.GasRefundCounter = v
+		case stepFieldGasCost:
+			step.GasCost = v
+		case stepFieldStorageChange:
+			change, err := unmarshalStorageChange(bytes)
+			if err != nil {
+				return err
+			}
+			step.StorageChange = change
+		case stepFieldLogIndex:
+			index := int(v)
+			step.LogIndex = &index
+		case stepFieldHasStack:
+			hasStack = v != 0
+		case stepFieldHasPushStack:
+			hasPushStack = v != 0
+		}
+	}
+	if hasStack {
+		if stack == nil {
+			stack = []uint256.Int{}
+		}
+		step.Stack = &stack
+	}
+	if hasPushStack {
+		if pushStack == nil {
+			pushStack = []uint256.Int{}
+		}
+		step.PushStack = &pushStack
+	}
+	*s = step
+	return nil
+}
+
+func unmarshalStorageChange(b []byte) (*StorageChange, error) {
+	change := new(StorageChange)
+	for len(b) > 0 {
+		num, v, bytes, n, err := consumeField(b)
+		if err != nil {
+			return nil, err
+		}
+		b = b[n:]
+		switch num {
+		case changeFieldKey:
+			change.Key = new(big.Int).SetBytes(bytes)
+		case changeFieldValue:
+			change.Value = new(big.Int).SetBytes(bytes)
+		case changeFieldHadValue:
+			change.HadValue = new(big.Int).SetBytes(bytes)
+		case changeFieldReason:
+			change.Reason = StorageChangeReason(v)
+		}
+	}
+	if change.Reason > StorageChangeReasonTSTORE {
+		return nil, errors.New("invalid storage change reason")
+	}
+	return change, nil
+}
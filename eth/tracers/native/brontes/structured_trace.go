@@ -17,6 +17,7 @@ type TraceActions interface {
 	GetCallData() []byte
 	GetReturnCallData() []byte
 	IsStaticCall() bool
+	InStaticContext() bool
 	IsCreate() bool
 	ActionType() ActionType
 	GetCreateOutput() common.Address
@@ -29,10 +30,26 @@ type DecodedParams struct {
 	Value     string `json:"value"`
 }
 
+// DecodeProvenance is the source decoded call data is derived from.
+type DecodeProvenance string
+
+const (
+	// DecodeProvenanceABI is call data decoded against the ABI of the
+	// executed contract.
+	DecodeProvenanceABI DecodeProvenance = "abi"
+	// DecodeProvenanceSelector is call data decoded against the signature
+	// guessed from its selector, which may collide with other signatures.
+	DecodeProvenanceSelector DecodeProvenance = "selector"
+)
+
 type DecodedCallData struct {
 	FunctionName string          `json:"function_name"`
 	CallData     []DecodedParams `json:"call_data"`
 	ReturnData   []DecodedParams `json:"return_data"`
+	// Provenance is the source the data was decoded from, and Confidence
+	// the likelihood, between 0 and 1, of the decoding being right.
+	Provenance DecodeProvenance `json:"provenance,omitempty"`
+	Confidence float64          `json:"confidence,omitempty"`
 }
 
 type CallFrameInfo struct {
@@ -61,12 +78,51 @@ type TransactionTraceWithLogs struct {
 	MsgSender   common.Address   `json:"msg_sender"`
 	TraceIdx    uint64           `json:"trace_idx"`
 	DecodedData *DecodedCallData `json:"decoded_data,omitempty"`
+	// ConstructorArgs holds the ABI-encoded arguments appended to the init
+	// code of successful create frames.
+	ConstructorArgs hexutil.Bytes `json:"constructor_args,omitempty"`
+	// Metadata is the compiler metadata found at the tail of the code deployed
+	// by create frames, for matching against verification databases.
+	Metadata      *ContractMetadata `json:"metadata,omitempty"`
+	Summary       *FrameSummary     `json:"summary,omitempty"`
+	StorageAccess *StorageAccess    `json:"storage_access,omitempty"`
+	// CodeAddress and ContextAddress differ for delegate calls, where the code
+	// of the library runs in the context of the proxy.
+	CodeAddress    common.Address `json:"code_address"`
+	ContextAddress common.Address `json:"context_address"`
+	// UncheckedCall is set for calls whose success flag was ignored by the
+	// caller, if detected.
+	UncheckedCall bool `json:"unchecked_call,omitempty"`
+	// Reentrant is set for calls re-entering a contract active on the call
+	// stack, if detected.
+	Reentrant bool `json:"reentrant,omitempty"`
+	// UntrustedTarget lists where the target of a delegate call came from if
+	// it was taken from call data or from storage written in the same
+	// transaction, which may let callers hijack the delegating contract.
+	UntrustedTarget []string `json:"untrusted_target,omitempty"`
+	// RevertedByParent is set for frames inside the subtree of a failed frame,
+	// whose effects were rolled back whatever their own outcome.
+	RevertedByParent bool `json:"reverted_by_parent,omitempty"`
+	// StaticContext is set for frames that cannot modify state, being static
+	// calls or running inside the subtree of one.
+	StaticContext bool `json:"static_context,omitempty"`
+	// Ordering interleaves the logs of the frame with its subcalls in
+	// execution order. Frames without logs leave it out, their subcalls being
+	// in trace address order.
+	Ordering []FrameOrdering `json:"ordering,omitempty"`
 }
 
 func (t *TransactionTraceWithLogs) IsStaticCall() bool {
 	return t.Trace.IsStaticCall()
 }
 
+// InStaticContext reports whether the frame runs in a static context, either
+// its own or one inherited from an ancestor. Traces recorded before the
+// context was tracked only know about the kind of the frame itself.
+func (t *TransactionTraceWithLogs) InStaticContext() bool {
+	return t.StaticContext || t.IsStaticCall()
+}
+
 func (t *TransactionTraceWithLogs) IsCreate() bool {
 	return t.Trace.IsCreate()
 }
@@ -133,13 +189,111 @@ func (t *TransactionTraceWithLogs) GetCallFrameInfo() CallFrameInfo {
 }
 
 type TxTrace struct {
-	BlockNumber    uint64                     `json:"block_number"`
+	// SchemaVersion is the version of the wire format, unset for traces
+	// encoded in the original format.
+	SchemaVersion int    `json:"schema_version,omitempty"`
+	ChainId       uint64 `json:"chain_id"`
+	BlockNumber   uint64 `json:"block_number"`
+	// BlockHash and ParentHash locate the block in the chain, so traces of
+	// reorged blocks can be told apart. They are zero if unknown.
+	BlockHash      common.Hash                `json:"block_hash"`
+	ParentHash     common.Hash                `json:"parent_hash"`
 	Trace          []TransactionTraceWithLogs `json:"trace"`
 	TxHash         common.Hash                `json:"tx_hash"`
 	GasUsed        *big.Int                   `json:"gas_used"`
 	EffectivePrice *big.Int                   `json:"effective_price"`
 	TxIndex        int                        `json:"tx_index"`
 	IsSuccess      bool                       `json:"is_success"`
+	// SpecId is the name of the fork active when the transaction was executed,
+	// so the trace stays interpretable without the chain config at hand.
+	SpecId string `json:"spec_id,omitempty"`
+	// AddressNames labels the senders and recipients of the frames, if name
+	// resolution is enabled.
+	AddressNames map[common.Address]string `json:"address_names,omitempty"`
+	Stats        *TxStats                  `json:"stats,omitempty"`
+	// Witness is the state accessed by the transaction, if recorded.
+	Witness *StateWitness `json:"witness,omitempty"`
+	// Coverage lists the executed pcs of the contracts run by the
+	// transaction, if recorded.
+	Coverage []*ContractCoverage `json:"coverage,omitempty"`
+	// Refunds breaks the gas refund down per SSTORE, if recorded.
+	Refunds *RefundBreakdown `json:"refunds,omitempty"`
+	// Reentrancies lists the reentrancy chains of the transaction, if
+	// detected.
+	Reentrancies []Reentrancy `json:"reentrancies,omitempty"`
+	// Alerts lists the suspicious approval and transfer patterns of the
+	// transaction, if detected.
+	Alerts []Alert `json:"alerts,omitempty"`
+	// StateDiff is the change of the state by the transaction, if recorded.
+	StateDiff map[common.Address]*AccountDiff `json:"state_diff,omitempty"`
+	// StateDiffDiscrepancies lists where the recorded state diff disagrees
+	// with the state at the end of the transaction.
+	StateDiffDiscrepancies []StateDiffDiscrepancy `json:"state_diff_discrepancies,omitempty"`
+	// Transfers lists the value and token transfers of the transaction, if
+	// extracted.
+	Transfers []Transfer `json:"transfers,omitempty"`
+	// Orderflow is the ordering metadata of the transaction, such as its
+	// bundle, if known to the node.
+	Orderflow *Orderflow `json:"orderflow,omitempty"`
+	// Errors lists the failures that cut the trace short, which are panics of
+	// the tracer, and any other failure if partial results are requested. The
+	// frames listed are those traced before the failures.
+	Errors []TraceError `json:"errors,omitempty"`
+}
+
+// TraceErrorStage is the stage of tracing a failure occurred at.
+type TraceErrorStage string
+
+const (
+	// TraceErrorStageExecution is a failure while the transaction executes,
+	// after which no more frames are recorded.
+	TraceErrorStageExecution TraceErrorStage = "execution"
+	// TraceErrorStageBuild is a failure while converting the recorded frames,
+	// after which no more frames are converted.
+	TraceErrorStageBuild TraceErrorStage = "build"
+)
+
+// TraceError describes a failure that cut a trace short.
+type TraceError struct {
+	Stage   TraceErrorStage `json:"stage"`
+	Message string          `json:"message"`
+	// TraceIdx is the index of the frame the failure occurred at, if known.
+	// For build failures it is the first frame left out.
+	TraceIdx *uint64 `json:"trace_idx,omitempty"`
+	// Stack is the stack trace of the tracer if it panicked.
+	Stack string `json:"stack,omitempty"`
+}
+
+// FlatTxTrace is the result of the flat output mode, listing the actions of
+// the frames in execution order with their trace addresses.
+type FlatTxTrace struct {
+	ChainId     uint64                 `json:"chain_id"`
+	BlockNumber uint64                 `json:"block_number"`
+	Trace       []FlatTransactionTrace `json:"trace"`
+	TxHash      common.Hash            `json:"tx_hash"`
+	TxIndex     int                    `json:"tx_index"`
+	IsSuccess   bool                   `json:"is_success"`
+}
+
+// FlatTransactionTrace is a frame of the flat output mode, carrying the same
+// trace_idx as the frame in the other outputs and tables.
+type FlatTransactionTrace struct {
+	TransactionTrace
+	TraceIdx uint64 `json:"trace_idx"`
+}
+
+func (t *FlatTransactionTrace) UnmarshalJSON(input []byte) error {
+	var dec struct {
+		TraceIdx uint64 `json:"trace_idx"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if err := t.TransactionTrace.UnmarshalJSON(input); err != nil {
+		return err
+	}
+	t.TraceIdx = dec.TraceIdx
+	return nil
 }
 
 func (t *TxTrace) MarshalJSON() ([]byte, error) {
@@ -154,3 +308,20 @@ func (t *TxTrace) MarshalJSON() ([]byte, error) {
 		Alias:          (*Alias)(t),
 	})
 }
+
+func (t *TxTrace) UnmarshalJSON(input []byte) error {
+	type Alias TxTrace
+	dec := &struct {
+		GasUsed        *hexutil.Big `json:"gas_used"`
+		EffectivePrice *hexutil.Big `json:"effective_price"`
+		*Alias
+	}{
+		Alias: (*Alias)(t),
+	}
+	if err := json.Unmarshal(input, dec); err != nil {
+		return err
+	}
+	t.GasUsed = (*big.Int)(dec.GasUsed)
+	t.EffectivePrice = (*big.Int)(dec.EffectivePrice)
+	return nil
+}
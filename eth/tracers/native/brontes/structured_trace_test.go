@@ -33,6 +33,7 @@ func TestTxTraceJSONMarshaling(t *testing.T) {
 		GasUsed:        big.NewInt(21000),
 		EffectivePrice: big.NewInt(1000000000),
 		IsSuccess:      true,
+		SpecId:         "cancun",
 	}
 
 	// Marshal to JSON
@@ -50,6 +51,7 @@ func TestTxTraceJSONMarshaling(t *testing.T) {
 	assert.Equal(t, txTrace.GasUsed.String(), unmarshaledTxTrace.GasUsed.String())
 	assert.Equal(t, txTrace.EffectivePrice.String(), unmarshaledTxTrace.EffectivePrice.String())
 	assert.Equal(t, txTrace.IsSuccess, unmarshaledTxTrace.IsSuccess)
+	assert.Equal(t, txTrace.SpecId, unmarshaledTxTrace.SpecId)
 	assert.Equal(t, len(txTrace.Trace), len(unmarshaledTxTrace.Trace))
 	if len(txTrace.Trace) > 0 {
 		assert.Equal(t, txTrace.Trace[0].TraceIdx, unmarshaledTxTrace.Trace[0].TraceIdx)
@@ -59,4 +61,39 @@ func TestTxTraceJSONMarshaling(t *testing.T) {
 			assert.Equal(t, txTrace.Trace[0].Logs[0].TxHash, unmarshaledTxTrace.Trace[0].Logs[0].TxHash)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestTransactionTraceJSONRoundTrip(t *testing.T) {
+	trace := newTestTxTrace()
+	trace.Trace = append(trace.Trace, TransactionTraceWithLogs{
+		TraceIdx: 2,
+		Trace: TransactionTrace{
+			Type: ActionTypeSelfDestruct,
+			Action: &Action{
+				Type: ActionTypeSelfDestruct,
+				SelfDestruct: &SelfDestructAction{
+					Address:       common.HexToAddress("0x3333333333333333333333333333333333333333"),
+					RefundAddress: common.HexToAddress("0x1111111111111111111111111111111111111111"),
+					Balance:       big.NewInt(7),
+					CodeRemoved:   true,
+				},
+			},
+			TraceAddress: []uint{1},
+		},
+	})
+	blob, err := json.Marshal(trace)
+	assert.NoError(t, err)
+
+	var decoded TxTrace
+	assert.NoError(t, json.Unmarshal(blob, &decoded))
+	assert.Len(t, decoded.Trace, 3)
+	assert.Equal(t, trace.Trace[0].Trace.Action.Call.To, decoded.Trace[0].Trace.Action.Call.To)
+	assert.Equal(t, trace.Trace[0].Trace.Action.Call.Input, decoded.Trace[0].Trace.Action.Call.Input)
+	assert.Equal(t, trace.Trace[0].Trace.Result.Call.GasUsed, decoded.Trace[0].Trace.Result.Call.GasUsed)
+	assert.Equal(t, trace.Trace[1].Trace.Result.Create.Address, decoded.Trace[1].Trace.Result.Create.Address)
+	assert.Equal(t, trace.Trace[2].Trace.Action.SelfDestruct, decoded.Trace[2].Trace.Action.SelfDestruct)
+
+	// Re-encoding yields the same output.
+	again, err := json.Marshal(&decoded)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(blob), string(again))
+}
@@ -0,0 +1,246 @@
+package brontes
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// TxSummary is the compact record of a transaction kept in place of its
+// trace once the trace expired from an archive, or recorded instead of the
+// trace by the summary mode.
+type TxSummary struct {
+	ChainId     uint64      `json:"chain_id"`
+	BlockNumber uint64      `json:"block_number"`
+	TxHash      common.Hash `json:"tx_hash"`
+	TxIndex     int         `json:"tx_index"`
+	GasUsed     *big.Int    `json:"gas_used"`
+	IsSuccess   bool        `json:"is_success"`
+	Frames      int         `json:"frames"`
+	Stats       *TxStats    `json:"stats,omitempty"`
+	// ValueTransfers and ValueTransferred are the number of transfers of
+	// the native currency that took effect and their total in wei.
+	ValueTransfers   int      `json:"value_transfers"`
+	ValueTransferred *big.Int `json:"value_transferred"`
+	// TokenTransfers is the number of ERC-20 transfers logged by the frames
+	// that did not revert.
+	TokenTransfers int `json:"token_transfers"`
+	// CoinbasePayment is the native currency transferred to the fee
+	// recipient of the block by the frames that did not revert, only
+	// recorded by the summary mode.
+	CoinbasePayment *big.Int `json:"coinbase_payment,omitempty"`
+	// Flags lists the reasons the transaction was flagged for full tracing,
+	// empty unless it was.
+	Flags []string `json:"flags,omitempty"`
+}
+
+// Summary returns the summary of the trace.
+func (t *TxTrace) Summary() *TxSummary {
+	summary := &TxSummary{
+		ChainId:          t.ChainId,
+		BlockNumber:      t.BlockNumber,
+		TxHash:           t.TxHash,
+		TxIndex:          t.TxIndex,
+		GasUsed:          t.GasUsed,
+		IsSuccess:        t.IsSuccess,
+		Frames:           len(t.Trace),
+		Stats:            t.Stats,
+		ValueTransferred: new(big.Int),
+	}
+	for _, transfer := range ExtractTransfers(t, TransferFlowNet, nil) {
+		switch transfer.Kind {
+		case TransferKindValue:
+			summary.ValueTransfers++
+			summary.ValueTransferred.Add(summary.ValueTransferred, transfer.Value.ToInt())
+		case TransferKindToken:
+			summary.TokenTransfers++
+		}
+	}
+	return summary
+}
+
+// Reasons a transaction is flagged for full tracing.
+const (
+	FlagFailed   = "failed"
+	FlagGas      = "gas"
+	FlagValue    = "value"
+	FlagAddress  = "address"
+	FlagCoinbase = "coinbase"
+	FlagReverted = "reverted"
+)
+
+// SummaryFlagConfig selects the transactions flagged for full tracing while
+// only their summaries are recorded. A transaction is flagged if it meets
+// any of the set criteria.
+type SummaryFlagConfig struct {
+	// Failed flags the transactions that failed.
+	Failed bool `json:"failed"`
+	// MinGasUsed flags the transactions using at least this much gas.
+	MinGasUsed uint64 `json:"minGasUsed"`
+	// MinValue flags the transactions transferring at least this much of
+	// the native currency, in wei.
+	MinValue *hexutil.Big `json:"minValue"`
+	// Addresses flags the transactions calling, creating or selfdestructing
+	// any of these accounts.
+	Addresses []common.Address `json:"addresses"`
+	// MinCoinbasePayment flags the transactions paying at least this much
+	// of the native currency to the fee recipient directly, in wei.
+	MinCoinbasePayment *hexutil.Big `json:"minCoinbasePayment"`
+	// MinRevertedGas flags the failed transactions using at least this much
+	// gas.
+	MinRevertedGas uint64 `json:"minRevertedGas"`
+}
+
+// Validate fails on criteria flagging every transaction.
+func (c *SummaryFlagConfig) Validate() error {
+	if c.MinValue != nil && c.MinValue.ToInt().Sign() <= 0 {
+		return errors.New("minimum flagged value must be positive")
+	}
+	if c.MinCoinbasePayment != nil && c.MinCoinbasePayment.ToInt().Sign() <= 0 {
+		return errors.New("minimum flagged coinbase payment must be positive")
+	}
+	return nil
+}
+
+// summaryFrame accumulates the transfers of a frame and its subframes, kept
+// by the caller unless the frame reverts.
+type summaryFrame struct {
+	valueTransfers int
+	value          *big.Int
+	tokenTransfers int
+	coinbase       *big.Int // value transferred to the fee recipient
+}
+
+func newSummaryFrame() summaryFrame {
+	return summaryFrame{value: new(big.Int), coinbase: new(big.Int)}
+}
+
+// SummaryRecorder records the summary of a transaction from the call frames
+// and logs alone, without building its call tree. It is cheap enough to run
+// on every transaction, leaving full tracing to the transactions it flags.
+// Calls to precompiles are not counted as frames.
+type SummaryRecorder struct {
+	summary     *TxSummary
+	coinbase    common.Address
+	precompiles map[common.Address]struct{}
+	flags       *SummaryFlagConfig
+	watched     map[common.Address]struct{}
+	touched     bool // whether a watched account was called
+	// frames are the transfers of the open frames, on top of those of the
+	// transaction.
+	frames []summaryFrame
+}
+
+// NewSummaryRecorder creates a recorder of the summary of a transaction,
+// flagging it against the given criteria if any.
+func NewSummaryRecorder(chainConfig *params.ChainConfig, env *tracing.VMContext, tx *types.Transaction, txIndex int, flags *SummaryFlagConfig) *SummaryRecorder {
+	rules := chainConfig.Rules(env.BlockNumber, env.Random != nil, env.Time, env.ArbOSVersion)
+	r := &SummaryRecorder{
+		summary: &TxSummary{
+			BlockNumber: env.BlockNumber.Uint64(),
+			TxHash:      tx.Hash(),
+			TxIndex:     txIndex,
+		},
+		coinbase:    env.Coinbase,
+		precompiles: activePrecompileSet(rules),
+		flags:       flags,
+		frames:      []summaryFrame{newSummaryFrame()},
+	}
+	if chainConfig.ChainID != nil {
+		r.summary.ChainId = chainConfig.ChainID.Uint64()
+	}
+	if flags != nil && len(flags.Addresses) > 0 {
+		r.watched = make(map[common.Address]struct{}, len(flags.Addresses))
+		for _, addr := range flags.Addresses {
+			r.watched[addr] = struct{}{}
+		}
+	}
+	return r
+}
+
+// OnEnter records the start of a call frame.
+func (r *SummaryRecorder) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	frame := newSummaryFrame()
+	kind, err := FromCallTypeCode(typ)
+	if err == nil {
+		if _, ok := r.precompiles[to]; !ok || !kind.IsAnyCall() {
+			r.summary.Frames++
+		}
+		// Call codes and delegate calls keep the value in the caller.
+		moves := kind == CallKindCall || kind == CallKindExtCall || kind.IsAnyCreate() || kind.IsSelfDestruct()
+		if moves && value != nil && value.Sign() > 0 {
+			frame.valueTransfers++
+			frame.value.Set(value)
+			if to == r.coinbase {
+				frame.coinbase.Set(value)
+			}
+		}
+	}
+	if _, ok := r.watched[to]; ok {
+		r.touched = true
+	}
+	r.frames = append(r.frames, frame)
+}
+
+// OnExit records the end of a call frame, dropping its transfers if it
+// failed.
+func (r *SummaryRecorder) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if len(r.frames) < 2 {
+		return
+	}
+	frame := r.frames[len(r.frames)-1]
+	r.frames = r.frames[:len(r.frames)-1]
+	if err != nil {
+		return
+	}
+	parent := &r.frames[len(r.frames)-1]
+	parent.valueTransfers += frame.valueTransfers
+	parent.value.Add(parent.value, frame.value)
+	parent.tokenTransfers += frame.tokenTransfers
+	parent.coinbase.Add(parent.coinbase, frame.coinbase)
+}
+
+// OnLog records a log of the current frame.
+func (r *SummaryRecorder) OnLog(log *types.Log) {
+	if len(log.Topics) == 3 && log.Topics[0] == transferTopic && len(log.Data) == 32 {
+		r.frames[len(r.frames)-1].tokenTransfers++
+	}
+}
+
+// Summary returns the summary of the transaction given its receipt,
+// flagged against the criteria of the recorder.
+func (r *SummaryRecorder) Summary(receipt *types.Receipt) *TxSummary {
+	summary, transfers := r.summary, r.frames[0]
+	summary.ValueTransfers = transfers.valueTransfers
+	summary.ValueTransferred = transfers.value
+	summary.TokenTransfers = transfers.tokenTransfers
+	summary.CoinbasePayment = transfers.coinbase
+	summary.GasUsed = new(big.Int).SetUint64(receipt.GasUsed)
+	summary.IsSuccess = receipt.Status == types.ReceiptStatusSuccessful
+	if flags := r.flags; flags != nil {
+		if flags.Failed && !summary.IsSuccess {
+			summary.Flags = append(summary.Flags, FlagFailed)
+		}
+		if flags.MinGasUsed > 0 && receipt.GasUsed >= flags.MinGasUsed {
+			summary.Flags = append(summary.Flags, FlagGas)
+		}
+		if flags.MinValue != nil && summary.ValueTransferred.Cmp(flags.MinValue.ToInt()) >= 0 {
+			summary.Flags = append(summary.Flags, FlagValue)
+		}
+		if r.touched {
+			summary.Flags = append(summary.Flags, FlagAddress)
+		}
+		if flags.MinCoinbasePayment != nil && summary.CoinbasePayment.Cmp(flags.MinCoinbasePayment.ToInt()) >= 0 {
+			summary.Flags = append(summary.Flags, FlagCoinbase)
+		}
+		if flags.MinRevertedGas > 0 && !summary.IsSuccess && receipt.GasUsed >= flags.MinRevertedGas {
+			summary.Flags = append(summary.Flags, FlagReverted)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,135 @@
+package brontes
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxSummary(t *testing.T) {
+	trace := newTestTxTrace()
+	trace.Stats = &TxStats{TotalFrames: 2, MaxDepth: 1}
+	trace.Trace[0].Trace.Action.Call.Value = big.NewInt(7)
+
+	// Summaries are taken from decoded traces when archives are pruned.
+	enc, err := json.Marshal(trace)
+	require.NoError(t, err)
+	var decoded TxTrace
+	require.NoError(t, json.Unmarshal(enc, &decoded))
+
+	summary := decoded.Summary()
+	assert.Equal(t, trace.TxHash, summary.TxHash)
+	assert.Equal(t, trace.BlockNumber, summary.BlockNumber)
+	assert.Equal(t, trace.GasUsed, summary.GasUsed)
+	assert.Equal(t, 2, summary.Frames)
+	assert.Equal(t, trace.Stats.MaxDepth, summary.Stats.MaxDepth)
+	assert.Equal(t, 1, summary.ValueTransfers)
+	assert.Equal(t, big.NewInt(7), summary.ValueTransferred)
+}
+
+func TestSummaryRecorder(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		target   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		watched  = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		random   = common.Hash{}
+		env      = &tracing.VMContext{BlockNumber: big.NewInt(7), Time: 1, Random: &random}
+		tx       = types.NewTx(&types.LegacyTx{Nonce: 1})
+		transfer = &types.Log{
+			Topics: []common.Hash{transferTopic, common.BytesToHash(target.Bytes()), common.BytesToHash(sender.Bytes())},
+			Data:   common.LeftPadBytes([]byte{1}, 32),
+		}
+	)
+	flags := &SummaryFlagConfig{
+		Failed:    true,
+		MinValue:  (*hexutil.Big)(big.NewInt(5)),
+		Addresses: []common.Address{watched},
+	}
+	recorder := NewSummaryRecorder(params.MergedTestChainConfig, env, tx, 3, flags)
+	recorder.OnEnter(0, byte(vm.CALL), sender, target, nil, 100000, big.NewInt(5))
+	recorder.OnLog(transfer)
+	// Precompile calls are not frames.
+	recorder.OnEnter(1, byte(vm.STATICCALL), target, common.BytesToAddress([]byte{1}), nil, 3000, nil)
+	recorder.OnExit(1, nil, 3000, nil, false)
+	// Transfers of reverted frames are rolled back.
+	recorder.OnEnter(1, byte(vm.CALL), target, watched, nil, 50000, big.NewInt(2))
+	recorder.OnLog(transfer)
+	recorder.OnExit(1, nil, 50000, vm.ErrExecutionReverted, true)
+	// Delegate calls move no value.
+	recorder.OnEnter(1, byte(vm.DELEGATECALL), target, sender, nil, 50000, big.NewInt(5))
+	recorder.OnExit(1, nil, 1000, nil, false)
+	recorder.OnExit(0, nil, 60000, nil, false)
+
+	summary := recorder.Summary(&types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 80000})
+	assert.Equal(t, params.MergedTestChainConfig.ChainID.Uint64(), summary.ChainId)
+	assert.Equal(t, uint64(7), summary.BlockNumber)
+	assert.Equal(t, tx.Hash(), summary.TxHash)
+	assert.Equal(t, 3, summary.TxIndex)
+	assert.True(t, summary.IsSuccess)
+	assert.Equal(t, big.NewInt(80000), summary.GasUsed)
+	assert.Equal(t, 3, summary.Frames)
+	assert.Equal(t, 1, summary.ValueTransfers)
+	assert.Equal(t, big.NewInt(5), summary.ValueTransferred)
+	assert.Equal(t, 1, summary.TokenTransfers)
+	assert.Equal(t, []string{FlagValue, FlagAddress}, summary.Flags)
+
+	// Failed transactions transfer nothing.
+	recorder = NewSummaryRecorder(params.MergedTestChainConfig, env, tx, 0, flags)
+	recorder.OnEnter(0, byte(vm.CALL), sender, target, nil, 100000, big.NewInt(5))
+	recorder.OnExit(0, nil, 100000, vm.ErrOutOfGas, false)
+	summary = recorder.Summary(&types.Receipt{Status: types.ReceiptStatusFailed, GasUsed: 100000})
+	assert.Equal(t, 0, summary.ValueTransfers)
+	assert.Equal(t, 0, summary.ValueTransferred.Sign())
+	assert.Equal(t, []string{FlagFailed}, summary.Flags)
+
+	assert.Error(t, (&SummaryFlagConfig{MinValue: new(hexutil.Big)}).Validate())
+}
+
+func TestSummaryRecorderTriggers(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		target   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		coinbase = common.HexToAddress("0xc0ffee")
+		random   = common.Hash{}
+		env      = &tracing.VMContext{Coinbase: coinbase, BlockNumber: big.NewInt(7), Time: 1, Random: &random}
+		tx       = types.NewTx(&types.LegacyTx{})
+		flags    = &SummaryFlagConfig{
+			MinCoinbasePayment: (*hexutil.Big)(big.NewInt(10)),
+			MinRevertedGas:     50000,
+		}
+	)
+	// Payments to the fee recipient count unless rolled back.
+	recorder := NewSummaryRecorder(params.MergedTestChainConfig, env, tx, 0, flags)
+	recorder.OnEnter(0, byte(vm.CALL), sender, target, nil, 100000, nil)
+	recorder.OnEnter(1, byte(vm.CALL), target, coinbase, nil, 0, big.NewInt(10))
+	recorder.OnExit(1, nil, 0, nil, false)
+	recorder.OnEnter(1, byte(vm.CALL), target, coinbase, nil, 0, big.NewInt(5))
+	recorder.OnExit(1, nil, 0, vm.ErrExecutionReverted, true)
+	recorder.OnExit(0, nil, 30000, nil, false)
+	summary := recorder.Summary(&types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 51000})
+	assert.Equal(t, big.NewInt(10), summary.CoinbasePayment)
+	assert.Equal(t, []string{FlagCoinbase}, summary.Flags)
+
+	// Cheap failures are not flagged.
+	for _, gasUsed := range []uint64{30000, 60000} {
+		recorder = NewSummaryRecorder(params.MergedTestChainConfig, env, tx, 0, flags)
+		recorder.OnEnter(0, byte(vm.CALL), sender, target, nil, 100000, nil)
+		recorder.OnExit(0, nil, gasUsed, vm.ErrExecutionReverted, true)
+		summary = recorder.Summary(&types.Receipt{Status: types.ReceiptStatusFailed, GasUsed: gasUsed})
+		if gasUsed < flags.MinRevertedGas {
+			assert.Empty(t, summary.Flags)
+		} else {
+			assert.Equal(t, []string{FlagReverted}, summary.Flags)
+		}
+	}
+	assert.Error(t, (&SummaryFlagConfig{MinCoinbasePayment: new(hexutil.Big)}).Validate())
+}
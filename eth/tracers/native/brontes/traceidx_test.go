@@ -0,0 +1,60 @@
+package brontes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceIdxConsistency(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	call := func(target common.Address) []byte {
+		code := append([]byte{
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+			byte(vm.PUSH20),
+		}, target.Bytes()...)
+		return append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.POP))
+	}
+	// The caller calls the identity precompile, then the callee, leaving a
+	// gap in the indices of the frames of the outputs skipping precompiles.
+	code := append(call(common.BytesToAddress([]byte{4})), call(callee)...)
+	inspector, tx, _ := executeInspected(t, DefaultTracingInspectorConfig, code, callee, []byte{byte(vm.STOP)})
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful}
+
+	full, err := inspector.IntoTraceResults(tx, receipt, 0)
+	require.NoError(t, err)
+	var want []uint64
+	for _, frame := range full.Trace {
+		want = append(want, frame.TraceIdx)
+	}
+	assert.Equal(t, []uint64{0, 2}, want)
+
+	flat, err := inspector.IntoFlatTraceResults(receipt, 0)
+	require.NoError(t, err)
+	var have []uint64
+	for _, frame := range flat.Trace {
+		have = append(have, frame.TraceIdx)
+	}
+	assert.Equal(t, want, have)
+	blob, err := json.Marshal(flat)
+	require.NoError(t, err)
+	var decoded FlatTxTrace
+	require.NoError(t, json.Unmarshal(blob, &decoded))
+	assert.Equal(t, flat.Trace[1].TraceIdx, decoded.Trace[1].TraceIdx)
+	assert.Equal(t, flat.Trace[1].Action.Call.To, decoded.Trace[1].Action.Call.To)
+
+	arena, err := inspector.IntoArenaTraceResults(receipt, 0)
+	require.NoError(t, err)
+	require.Len(t, arena.Nodes, 3)
+	assert.Equal(t, callee, arena.Nodes[want[1]].Trace.Address)
+
+	tables, err := inspector.IntoClickhouseTables(0, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, want, tables[TableCallActions].(*ClickhouseCallAction).TraceIdx)
+	assert.Equal(t, want, NewClickhouseCallAction(full).TraceIdx)
+}
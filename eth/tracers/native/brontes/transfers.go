@@ -0,0 +1,153 @@
+package brontes
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TransferFlow selects the transfers extracted from a trace.
+type TransferFlow string
+
+const (
+	// TransferFlowNet keeps the transfers that took effect, leaving out the
+	// ones of reverted frames and the token transfers whose log is missing
+	// from the receipt.
+	TransferFlowNet TransferFlow = "net"
+	// TransferFlowGross keeps every attempted transfer, marking the ones
+	// rolled back.
+	TransferFlowGross TransferFlow = "gross"
+)
+
+// Validate checks that the flow is supported.
+func (f TransferFlow) Validate() error {
+	switch f {
+	case "", TransferFlowNet, TransferFlowGross:
+		return nil
+	default:
+		return fmt.Errorf("unsupported transfer flow %q", f)
+	}
+}
+
+// Kinds of transfers.
+const (
+	// TransferKindValue is a transfer of the native currency by a call, a
+	// creation or a selfdestruct.
+	TransferKindValue = "value"
+	// TransferKindToken is an ERC-20 transfer, found by its Transfer log.
+	TransferKindToken = "token"
+)
+
+// transferTopic is the topic of the ERC-20 Transfer event.
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// Transfer is a movement of the native currency or of tokens between two
+// accounts.
+type Transfer struct {
+	Kind string `json:"kind"`
+	// Token is the token contract, nil for value transfers.
+	Token *common.Address `json:"token,omitempty"`
+	From  common.Address  `json:"from"`
+	To    common.Address  `json:"to"`
+	Value *hexutil.Big    `json:"value"`
+	// TraceIdx is the frame transferring the value or emitting the log.
+	TraceIdx uint64 `json:"trace_idx"`
+	// Reverted is set for the transfers rolled back, only kept by the gross
+	// flow.
+	Reverted bool `json:"reverted,omitempty"`
+}
+
+// ExtractTransfers returns the value and token transfers of a trace in
+// execution order, the value transferred by a frame preceding the token
+// transfers it logged. The net flow checks the token transfers against the
+// logs kept by the transaction if they are given.
+func ExtractTransfers(value *TxTrace, flow TransferFlow, logs []*types.Log) []Transfer {
+	var (
+		reverted  = revertedFrames(value)
+		transfers []Transfer
+		kept      = slices.Clone(logs)
+	)
+	add := func(transfer Transfer, frame int) {
+		transfer.Reverted = reverted[frame]
+		if flow == TransferFlowNet && transfer.Reverted {
+			return
+		}
+		transfers = append(transfers, transfer)
+	}
+	for i := range value.Trace {
+		frame := &value.Trace[i]
+		if transfer, ok := valueTransfer(frame); ok {
+			add(transfer, i)
+		}
+		// Logs are emitted on behalf of the account the code runs for, the
+		// proxy for tokens delegating to their implementation.
+		token := frame.ContextAddress
+		if token == (common.Address{}) {
+			token = frame.Trace.Action.GetToAddr()
+		}
+		for _, log := range frame.Logs {
+			if len(log.Topics) != 3 || log.Topics[0] != transferTopic || len(log.Data) != 32 {
+				continue
+			}
+			if flow == TransferFlowNet && !reverted[i] && logs != nil {
+				idx := slices.IndexFunc(kept, func(l *types.Log) bool {
+					return l.Address == token && slices.Equal(l.Topics, log.Topics) && bytes.Equal(l.Data, log.Data)
+				})
+				if idx < 0 {
+					continue
+				}
+				kept = slices.Delete(kept, idx, idx+1)
+			}
+			add(Transfer{
+				Kind:     TransferKindToken,
+				Token:    &token,
+				From:     common.BytesToAddress(log.Topics[1].Bytes()),
+				To:       common.BytesToAddress(log.Topics[2].Bytes()),
+				Value:    (*hexutil.Big)(new(big.Int).SetBytes(log.Data)),
+				TraceIdx: frame.TraceIdx,
+			}, i)
+		}
+	}
+	return transfers
+}
+
+// valueTransfer returns the native currency transferred by a frame, if any.
+// Call codes and delegate calls keep the value in the caller.
+func valueTransfer(frame *TransactionTraceWithLogs) (Transfer, bool) {
+	var (
+		action   = frame.Trace.Action
+		from, to common.Address
+		amount   *big.Int
+	)
+	switch {
+	case action == nil:
+		return Transfer{}, false
+	case action.Type == ActionTypeCall:
+		if kind := action.Call.CallType; kind != CallKindCall && kind != CallKindExtCall {
+			return Transfer{}, false
+		}
+		from, to, amount = action.Call.From, action.Call.To, action.Call.Value
+	case action.Type == ActionTypeCreate:
+		from, to, amount = action.Create.From, frame.GetCreateOutput(), action.Create.Value
+	case action.Type == ActionTypeSelfDestruct:
+		from, to, amount = action.SelfDestruct.Address, action.SelfDestruct.RefundAddress, action.SelfDestruct.Balance
+	default:
+		return Transfer{}, false
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return Transfer{}, false
+	}
+	return Transfer{
+		Kind:     TransferKindValue,
+		From:     from,
+		To:       to,
+		Value:    (*hexutil.Big)(amount),
+		TraceIdx: frame.TraceIdx,
+	}, true
+}
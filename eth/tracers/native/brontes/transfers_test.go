@@ -0,0 +1,62 @@
+package brontes
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractTransfers(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		target   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		deployed = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		holder   = common.HexToAddress("0x4444444444444444444444444444444444444444")
+		failed   = "execution reverted"
+	)
+	transferLog := func(from, to common.Address, amount int64) types.Log {
+		return types.Log{
+			Address: target,
+			Topics:  []common.Hash{transferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+			Data:    common.BigToHash(big.NewInt(amount)).Bytes(),
+		}
+	}
+	trace := newTestTxTrace()
+	trace.Trace[0].ContextAddress = target
+	trace.Trace[0].Trace.Action.Call.Value = big.NewInt(5)
+	trace.Trace[0].Logs = []types.Log{transferLog(sender, holder, 10), transferLog(holder, sender, 1)}
+	// The create fails, rolling back the value it was sent and its logs.
+	trace.Trace[1].ContextAddress = deployed
+	trace.Trace[1].Trace.Action.Create.Value = big.NewInt(2)
+	trace.Trace[1].Trace.Error = &failed
+	trace.Trace[1].Logs = []types.Log{transferLog(target, holder, 7)}
+
+	gross := ExtractTransfers(trace, TransferFlowGross, nil)
+	require.Len(t, gross, 5)
+	assert.Equal(t, Transfer{Kind: TransferKindValue, From: sender, To: target, Value: (*hexutil.Big)(big.NewInt(5))}, gross[0])
+	assert.Equal(t, TransferKindToken, gross[1].Kind)
+	assert.Equal(t, target, *gross[1].Token)
+	assert.Equal(t, holder, gross[1].To)
+	assert.Equal(t, big.NewInt(10), gross[1].Value.ToInt())
+	assert.False(t, gross[2].Reverted)
+	assert.True(t, gross[3].Reverted)
+	assert.Equal(t, uint64(1), gross[3].TraceIdx)
+	assert.True(t, gross[4].Reverted)
+
+	net := ExtractTransfers(trace, TransferFlowNet, nil)
+	assert.Equal(t, gross[:3], net)
+
+	// Token transfers whose log is missing from the receipt are left out.
+	kept := transferLog(holder, sender, 1)
+	net = ExtractTransfers(trace, TransferFlowNet, []*types.Log{&kept})
+	require.Len(t, net, 2)
+	assert.Equal(t, TransferKindValue, net[0].Kind)
+	assert.Equal(t, holder, net[1].From)
+
+	assert.Error(t, TransferFlow("attempted").Validate())
+}
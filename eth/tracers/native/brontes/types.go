@@ -3,11 +3,14 @@ package brontes
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"math/big"
+	"slices"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/holiman/uint256"
 )
 
@@ -31,8 +34,11 @@ type CallTrace struct {
 	Success                  bool
 	Caller                   common.Address
 	Address                  common.Address // For CALL calls, this is the callee; for CREATE, it is the created address.
+	CodeAddress              common.Address // Account whose code is executed; the library for delegate calls.
+	ContextAddress           common.Address // Account whose storage and balance are used; the caller for delegate calls.
 	MaybePrecompile          *bool
 	SelfDestructRefundTarget *common.Address
+	SelfDestructCodeRemoved  bool // false if a post-Cancun selfdestruct only transferred the balance
 	Kind                     CallKind
 	Value                    *big.Int
 	Data                     hexutil.Bytes
@@ -42,6 +48,11 @@ type CallTrace struct {
 	Reverted                 bool
 	Error                    error
 	Steps                    []CallTraceStep
+	SpilledSteps             []int64        // spill file offsets of the steps following Steps
+	SkippedSteps             int            // steps left out of sampled frames, between their first and last steps
+	CallerStep               *int           // index of the step of the parent entering the frame, nil if not recorded
+	Summary                  *FrameSummary  // nil unless opcode summaries are recorded
+	StorageAccess            *StorageAccess // nil unless storage accesses are recorded
 }
 
 func (ct *CallTrace) IsError() bool {
@@ -70,12 +81,18 @@ type CallTraceNode struct {
 	Ordering []LogCallOrder
 }
 
+// TraceIdx returns the canonical index of the frame within its transaction,
+// its position in the arena in order of entry. It is assigned once when the
+// frame is pushed, and every output refers to frames by it, so the rows of
+// the exported tables of a transaction can be joined on it. Frames left out of
+// an output, such as precompile calls, leave gaps.
+func (ctn *CallTraceNode) TraceIdx() uint64 {
+	return uint64(ctn.Idx)
+}
+
 // ExecutionAddress returns the execution address based on the call kind.
 func (ctn *CallTraceNode) ExecutionAddress() common.Address {
-	if ctn.Trace.Kind.IsDelegate() {
-		return ctn.Trace.Caller
-	}
-	return ctn.Trace.Address
+	return ctn.Trace.ContextAddress
 }
 
 // IsPrecompile returns true if the trace is a call to a precompile.
@@ -100,19 +117,48 @@ func (ctn *CallTraceNode) IsSelfdestruct() bool {
 // Call kinds and conversions
 // ---------------------------------------------------------------------
 
-// CallKind is an enumeration of call types.
+// CallKind is an enumeration of call types. It is encoded as its name in all
+// outputs.
 type CallKind string
 
 const (
-	CallKindCall         = "call"
-	CallKindStaticCall   = "staticcall"
-	CallKindCallCode     = "callcode"
-	CallKindDelegateCall = "delegatecall"
-	CallKindCreate       = "create"
-	CallKindCreate2      = "create2"
-	CallKindSelfDestruct = "selfdestruct"
+	CallKindCall         CallKind = "call"
+	CallKindStaticCall   CallKind = "staticcall"
+	CallKindCallCode     CallKind = "callcode"
+	CallKindDelegateCall CallKind = "delegatecall"
+	CallKindCreate       CallKind = "create"
+	CallKindCreate2      CallKind = "create2"
+	CallKindSelfDestruct CallKind = "selfdestruct"
+
+	// The call and create kinds of EOF contracts (EIP-7069, EIP-7620).
+	CallKindExtCall         CallKind = "extcall"
+	CallKindExtStaticCall   CallKind = "extstaticcall"
+	CallKindExtDelegateCall CallKind = "extdelegatecall"
+	CallKindEOFCreate       CallKind = "eofcreate"
+
+	// CallKindAuthCall is the kind revm based tracers give to calls made on
+	// behalf of an authorizing account, from EIP-3074 which EIP-7702
+	// superseded. It is never produced here, but accepted when decoding
+	// traces of those tracers.
+	CallKindAuthCall CallKind = "authcall"
 )
 
+// callKinds holds the valid call kinds.
+var callKinds = map[CallKind]struct{}{
+	CallKindCall:            {},
+	CallKindStaticCall:      {},
+	CallKindCallCode:        {},
+	CallKindDelegateCall:    {},
+	CallKindCreate:          {},
+	CallKindCreate2:         {},
+	CallKindSelfDestruct:    {},
+	CallKindExtCall:         {},
+	CallKindExtStaticCall:   {},
+	CallKindExtDelegateCall: {},
+	CallKindEOFCreate:       {},
+	CallKindAuthCall:        {},
+}
+
 func FromCallTypeCode(typ byte) (CallKind, error) {
 	callScheme := vm.OpCode(typ)
 	switch callScheme {
@@ -130,24 +176,66 @@ func FromCallTypeCode(typ byte) (CallKind, error) {
 		return CallKindCreate2, nil
 	case vm.SELFDESTRUCT:
 		return CallKindSelfDestruct, nil
+	case vm.EXTCALL:
+		return CallKindExtCall, nil
+	case vm.EXTSTATICCALL:
+		return CallKindExtStaticCall, nil
+	case vm.EXTDELEGATECALL:
+		return CallKindExtDelegateCall, nil
+	case vm.EOFCREATE:
+		return CallKindEOFCreate, nil
 	}
 	return "", fmt.Errorf("unknown call type: %s", callScheme)
 }
 
+// String returns the name of the kind.
+func (ck CallKind) String() string {
+	return string(ck)
+}
+
+// IsValid reports whether the kind is one of the known kinds.
+func (ck CallKind) IsValid() bool {
+	_, ok := callKinds[ck]
+	return ok
+}
+
+// MarshalText encodes the kind as its name, failing on unknown kinds.
+func (ck CallKind) MarshalText() ([]byte, error) {
+	if !ck.IsValid() {
+		return nil, fmt.Errorf("invalid call kind %q", string(ck))
+	}
+	return []byte(ck), nil
+}
+
+// UnmarshalText decodes the name of a kind, failing on unknown kinds.
+func (ck *CallKind) UnmarshalText(input []byte) error {
+	kind := CallKind(input)
+	if !kind.IsValid() {
+		return fmt.Errorf("invalid call kind %q", string(input))
+	}
+	*ck = kind
+	return nil
+}
+
 func (ck CallKind) IsAnyCreate() bool {
-	return ck == CallKindCreate || ck == CallKindCreate2
+	return ck == CallKindCreate || ck == CallKindCreate2 || ck == CallKindEOFCreate
 }
 
 func (ck CallKind) IsAnyCall() bool {
-	return ck == CallKindCall || ck == CallKindCallCode || ck == CallKindStaticCall || ck == CallKindDelegateCall
+	switch ck {
+	case CallKindCall, CallKindCallCode, CallKindStaticCall, CallKindDelegateCall,
+		CallKindExtCall, CallKindExtStaticCall, CallKindExtDelegateCall, CallKindAuthCall:
+		return true
+	}
+	return false
 }
 
 func (ck CallKind) IsDelegate() bool {
-	return ck == CallKindDelegateCall || ck == CallKindCallCode
+	return ck == CallKindDelegateCall || ck == CallKindCallCode || ck == CallKindExtDelegateCall
 }
 
 func (ck CallKind) IsStaticCall() bool {
-	return ck == CallKindStaticCall
+	return ck == CallKindStaticCall || ck == CallKindExtStaticCall
 }
 
 func (ck CallKind) IsSelfDestruct() bool {
@@ -174,25 +262,150 @@ type CallTraceStep struct {
 	Stack            *[]uint256.Int // nil if not captured
 	PushStack        *[]uint256.Int
 	Memory           RecordedMemory
-	MemorySize       int
+	MemorySize       int // size of the memory before the step, in bytes
+	MemorySizeAfter  int // size after the step, unchanged for the last step of a frame
 	GasRemaining     uint64
 	GasRefundCounter uint64
 	GasCost          uint64
 	StorageChange    *StorageChange
+	// LogIndex is the index in the logs of the frame of the log emitted by
+	// a LOG step, nil for other opcodes and for LOG steps that failed.
+	LogIndex *int
+}
+
+// MemoryExpansion returns the number of bytes the step grew the memory by.
+func (s *CallTraceStep) MemoryExpansion() int {
+	return max(s.MemorySizeAfter-s.MemorySize, 0)
+}
+
+// MemoryExpansionGas returns the gas charged for growing the memory by the
+// step, which is part of its cost.
+func (s *CallTraceStep) MemoryExpansionGas() uint64 {
+	return memoryGas(s.MemorySizeAfter) - memoryGas(min(s.MemorySize, s.MemorySizeAfter))
+}
+
+// memoryGas returns the total gas charged for a memory of the given size,
+// which is linear in its number of words with a quadratic term.
+func memoryGas(size int) uint64 {
+	words := (uint64(size) + 31) / 32
+	return words*params.MemoryGas + words*words/params.QuadCoeffDiv
+}
+
+// FrameSummary holds aggregated execution counters of a single call frame,
+// recorded instead of (or next to) full step traces.
+type FrameSummary struct {
+	OpcodeCounts    map[string]uint64 `json:"opcode_counts"`
+	SloadCount      uint64            `json:"sload_count"`
+	SstoreCount     uint64            `json:"sstore_count"`
+	MaxDepth        int               `json:"max_depth"`         // deepest call depth reached within the frame, including subcalls
+	MemoryHighWater uint64            `json:"memory_high_water"` // largest memory size of the frame, in bytes
+}
+
+func NewFrameSummary(depth int) *FrameSummary {
+	return &FrameSummary{
+		OpcodeCounts: make(map[string]uint64),
+		MaxDepth:     depth,
+	}
 }
 
 // ---------------------------------------------------------------------
 // Storage and memory types
 // ---------------------------------------------------------------------
 
+// StorageAccess is the set of storage slots read and written by a single
+// call frame, in order of first access. Address is the account whose storage
+// was accessed, which for delegate calls is the caller. Transient storage
+// slots are kept apart from persistent ones.
+type StorageAccess struct {
+	Address         common.Address `json:"address"`
+	Reads           []common.Hash  `json:"reads"`
+	Writes          []common.Hash  `json:"writes"`
+	TransientReads  []common.Hash  `json:"transient_reads,omitempty"`
+	TransientWrites []common.Hash  `json:"transient_writes,omitempty"`
+
+	reads           map[common.Hash]struct{}
+	writes          map[common.Hash]struct{}
+	transientReads  map[common.Hash]struct{}
+	transientWrites map[common.Hash]struct{}
+}
+
+func NewStorageAccess(address common.Address) *StorageAccess {
+	return &StorageAccess{
+		Address: address,
+		Reads:   make([]common.Hash, 0),
+		Writes:  make([]common.Hash, 0),
+		reads:   make(map[common.Hash]struct{}),
+		writes:  make(map[common.Hash]struct{}),
+
+		transientReads:  make(map[common.Hash]struct{}),
+		transientWrites: make(map[common.Hash]struct{}),
+	}
+}
+
+// Copy returns a deep copy of the access set.
+func (sa *StorageAccess) Copy() *StorageAccess {
+	return &StorageAccess{
+		Address:         sa.Address,
+		Reads:           slices.Clone(sa.Reads),
+		Writes:          slices.Clone(sa.Writes),
+		TransientReads:  slices.Clone(sa.TransientReads),
+		TransientWrites: slices.Clone(sa.TransientWrites),
+		reads:           maps.Clone(sa.reads),
+		writes:          maps.Clone(sa.writes),
+		transientReads:  maps.Clone(sa.transientReads),
+		transientWrites: maps.Clone(sa.transientWrites),
+	}
+}
+
+// AddRead records a read of the given slot, ignoring duplicates.
+func (sa *StorageAccess) AddRead(slot common.Hash) {
+	if _, ok := sa.reads[slot]; !ok {
+		sa.reads[slot] = struct{}{}
+		sa.Reads = append(sa.Reads, slot)
+	}
+}
+
+// AddWrite records a write to the given slot, ignoring duplicates.
+func (sa *StorageAccess) AddWrite(slot common.Hash) {
+	if _, ok := sa.writes[slot]; !ok {
+		sa.writes[slot] = struct{}{}
+		sa.Writes = append(sa.Writes, slot)
+	}
+}
+
+// AddTransientRead records a read of the given transient slot, ignoring duplicates.
+func (sa *StorageAccess) AddTransientRead(slot common.Hash) {
+	if _, ok := sa.transientReads[slot]; !ok {
+		sa.transientReads[slot] = struct{}{}
+		sa.TransientReads = append(sa.TransientReads, slot)
+	}
+}
+
+// AddTransientWrite records a write to the given transient slot, ignoring duplicates.
+func (sa *StorageAccess) AddTransientWrite(slot common.Hash) {
+	if _, ok := sa.transientWrites[slot]; !ok {
+		sa.transientWrites[slot] = struct{}{}
+		sa.TransientWrites = append(sa.TransientWrites, slot)
+	}
+}
+
 // StorageChangeReason indicates why a storage slot was modified.
 type StorageChangeReason int
 
 const (
 	StorageChangeReasonSLOAD StorageChangeReason = iota
 	StorageChangeReasonSSTORE
+	// Transient storage (EIP-1153) is tracked separately, as it is discarded
+	// at the end of the transaction.
+	StorageChangeReasonTLOAD
+	StorageChangeReasonTSTORE
 )
 
+// IsTransient returns true if the change happened in transient storage.
+func (r StorageChangeReason) IsTransient() bool {
+	return r == StorageChangeReasonTLOAD || r == StorageChangeReasonTSTORE
+}
+
 // StorageChange represents a change to contract storage.
 type StorageChange struct {
 	Key      *big.Int
@@ -232,8 +445,24 @@ func (rm *RecordedMemory) IsEmpty() bool {
 	return len(rm.Data) == 0
 }
 
+// MemoryChunks returns the memory as hex chunks of one word each.
 func (rm *RecordedMemory) MemoryChunks() []string {
-	return convertMemory(rm.AsBytes())
+	return MemoryChunks(rm.AsBytes())
+}
+
+// Words returns the memory split into words, see MemoryWords.
+func (rm *RecordedMemory) Words() [][]byte {
+	return MemoryWords(rm.AsBytes())
+}
+
+// Word returns the word at the given index, zero padded if the memory ends
+// within or before it.
+func (rm *RecordedMemory) Word(index int) [MemoryWordSize]byte {
+	var word [MemoryWordSize]byte
+	if start := index * MemoryWordSize; index >= 0 && start < len(rm.Data) {
+		copy(word[:], rm.Data[start:])
+	}
+	return word
 }
 
 // TransactionTrace represents a parity transaction trace.
@@ -246,8 +475,42 @@ type TransactionTrace struct {
 	TraceAddress []uint       `json:"traceAddress"`
 }
 
+// UnmarshalJSON decodes the action and result according to the trace type.
+func (t *TransactionTrace) UnmarshalJSON(input []byte) error {
+	type Alias TransactionTrace
+	dec := &struct {
+		Action json.RawMessage `json:"action"`
+		Result json.RawMessage `json:"result,omitempty"`
+		*Alias
+	}{
+		Alias: (*Alias)(t),
+	}
+	if err := json.Unmarshal(input, dec); err != nil {
+		return err
+	}
+	t.Action, t.Result = nil, nil
+	if len(dec.Action) > 0 && string(dec.Action) != "null" {
+		action, err := unmarshalAction(t.Type, dec.Action)
+		if err != nil {
+			return err
+		}
+		t.Action = action
+	}
+	if len(dec.Result) > 0 && string(dec.Result) != "null" {
+		switch t.Type {
+		case ActionTypeCall:
+			t.Result = &TraceOutput{Type: TraceOutputTypeCall, Call: new(CallOutput)}
+			return json.Unmarshal(dec.Result, t.Result.Call)
+		case ActionTypeCreate:
+			t.Result = &TraceOutput{Type: TraceOutputTypeCreate, Create: new(CreateOutput)}
+			return json.Unmarshal(dec.Result, t.Result.Create)
+		}
+	}
+	return nil
+}
+
 func (t *TransactionTrace) IsStaticCall() bool {
-	if t.Type == ActionTypeCall && t.Action != nil && t.Action.Call != nil && t.Action.Call.CallType == CallKindStaticCall {
+	if t.Type == ActionTypeCall && t.Action != nil && t.Action.Call != nil && t.Action.Call.CallType.IsStaticCall() {
 		return true
 	}
 	return false
@@ -258,7 +521,7 @@ func (t *TransactionTrace) IsCreate() bool {
 }
 
 func (t *TransactionTrace) IsDelegateCall() bool {
-	if t.Type == ActionTypeCall && t.Action != nil && t.Action.Call != nil && t.Action.Call.CallType == CallKindDelegateCall {
+	if t.Type == ActionTypeCall && t.Action != nil && t.Action.Call != nil && (t.Action.Call.CallType == CallKindDelegateCall || t.Action.Call.CallType == CallKindExtDelegateCall) {
 		return true
 	}
 	return false
@@ -282,27 +545,29 @@ type Action struct {
 	Reward       *RewardAction       `json:"-"`
 }
 
-func (a *Action) MarshalJSON() ([]byte, error) {
-	type actionMarshaling struct {
-		Author        *common.Address `json:"author,omitempty"`
-		RewardType    string          `json:"rewardType,omitempty"`
-		Address       *common.Address `json:"address,omitempty"`
-		Balance       *hexutil.Big    `json:"balance,omitempty"`
-		CallType      string          `json:"callType,omitempty"`
-		From          *common.Address `json:"from,omitempty"`
-		Gas           *hexutil.Uint64 `json:"gas,omitempty"`
-		Init          *hexutil.Bytes  `json:"init,omitempty"`
-		Input         *hexutil.Bytes  `json:"input,omitempty"`
-		RefundAddress *common.Address `json:"refundAddress,omitempty"`
-		To            *common.Address `json:"to,omitempty"`
-		Value         *hexutil.Big    `json:"value,omitempty"`
-	}
+// actionMarshaling is the JSON encoding shared by all action types.
+type actionMarshaling struct {
+	Author        *common.Address `json:"author,omitempty"`
+	RewardType    string          `json:"rewardType,omitempty"`
+	Address       *common.Address `json:"address,omitempty"`
+	Balance       *hexutil.Big    `json:"balance,omitempty"`
+	CallType      CallKind        `json:"callType,omitempty"`
+	CodeRemoved   *bool           `json:"codeRemoved,omitempty"`
+	From          *common.Address `json:"from,omitempty"`
+	Gas           *hexutil.Uint64 `json:"gas,omitempty"`
+	Init          *hexutil.Bytes  `json:"init,omitempty"`
+	Input         *hexutil.Bytes  `json:"input,omitempty"`
+	RefundAddress *common.Address `json:"refundAddress,omitempty"`
+	To            *common.Address `json:"to,omitempty"`
+	Value         *hexutil.Big    `json:"value,omitempty"`
+}
 
+func (a *Action) MarshalJSON() ([]byte, error) {
 	am := actionMarshaling{}
 
 	switch a.Type {
 	case ActionTypeCall:
-		am.CallType = string(a.Call.CallType)
+		am.CallType = a.Call.CallType
 		am.From = &a.Call.From
 		am.To = &a.Call.To
 		am.Value = (*hexutil.Big)(big.NewInt(0))
@@ -326,6 +591,7 @@ func (a *Action) MarshalJSON() ([]byte, error) {
 			am.Balance = (*hexutil.Big)(a.SelfDestruct.Balance)
 		}
 		am.RefundAddress = &a.SelfDestruct.RefundAddress
+		am.CodeRemoved = &a.SelfDestruct.CodeRemoved
 	case ActionTypeReward:
 		am.Author = &a.Reward.Author
 		am.RewardType = string(a.Reward.RewardType)
@@ -337,6 +603,49 @@ func (a *Action) MarshalJSON() ([]byte, error) {
 	return json.Marshal(am)
 }
 
+// unmarshalAction decodes an action of the given type, which is carried by
+// the enclosing trace rather than the action itself.
+func unmarshalAction(typ ActionType, input []byte) (*Action, error) {
+	var am actionMarshaling
+	if err := json.Unmarshal(input, &am); err != nil {
+		return nil, err
+	}
+	var (
+		a     = &Action{Type: typ}
+		value = (*big.Int)(am.Value)
+	)
+	switch typ {
+	case ActionTypeCall:
+		a.Call = &CallAction{CallType: am.CallType, Value: value}
+		setIfPresent(&a.Call.From, am.From)
+		setIfPresent(&a.Call.To, am.To)
+		setIfPresent((*hexutil.Uint64)(&a.Call.Gas), am.Gas)
+		setIfPresent(&a.Call.Input, am.Input)
+	case ActionTypeCreate:
+		a.Create = &CreateAction{Value: value}
+		setIfPresent(&a.Create.From, am.From)
+		setIfPresent((*hexutil.Uint64)(&a.Create.Gas), am.Gas)
+		setIfPresent(&a.Create.Init, am.Init)
+	case ActionTypeSelfDestruct:
+		a.SelfDestruct = &SelfDestructAction{Balance: (*big.Int)(am.Balance)}
+		setIfPresent(&a.SelfDestruct.Address, am.Address)
+		setIfPresent(&a.SelfDestruct.RefundAddress, am.RefundAddress)
+		setIfPresent(&a.SelfDestruct.CodeRemoved, am.CodeRemoved)
+	case ActionTypeReward:
+		a.Reward = &RewardAction{RewardType: RewardType(am.RewardType), Value: value}
+		setIfPresent(&a.Reward.Author, am.Author)
+	default:
+		return nil, fmt.Errorf("unknown action type: %s", typ)
+	}
+	return a, nil
+}
+
+func setIfPresent[T any](dst *T, src *T) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
 func (a *Action) GetFromAddr() common.Address {
 	switch a.Type {
 	case ActionTypeCall:
@@ -502,6 +811,9 @@ type SelfDestructAction struct {
 	Address       common.Address `json:"address"`
 	RefundAddress common.Address `json:"refundAddress"`
 	Balance       *big.Int       `json:"balance"`
+	// CodeRemoved is false when, under EIP-6780, the selfdestruct of a contract
+	// not created in the same transaction only transferred its balance.
+	CodeRemoved bool `json:"codeRemoved"`
 }
 
 func (sa *SelfDestructAction) GetFromAddr() common.Address {
@@ -554,7 +866,8 @@ type LogCallOrderType int
 const (
 	// LogCallOrderLog indicates that the ordering holds the index of a corresponding log.
 	LogCallOrderLog LogCallOrderType = iota
-	// LogCallOrderCall indicates that the ordering holds the index of a corresponding trace node.
+	// LogCallOrderCall indicates that the ordering holds the position of a
+	// corresponding trace node in the children of the node.
 	LogCallOrderCall
 )
 
@@ -573,6 +886,36 @@ func NewLogCallOrderLog(i int) LogCallOrder {
 	return LogCallOrder{Type: LogCallOrderLog, Index: i}
 }
 
+// String returns the name of the ordering type used in the trace output.
+func (t LogCallOrderType) String() string {
+	if t == LogCallOrderCall {
+		return "call"
+	}
+	return "log"
+}
+
+// FrameOrdering is an entry of the interleaving of the logs and calls of a
+// frame, Index being the index of a log of the frame for "log" entries and
+// the TraceIdx of a child frame for "call" entries.
+type FrameOrdering struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+}
+
+// frameOrdering resolves the ordering of a node into the logs and trace
+// indices of the output.
+func frameOrdering(node *CallTraceNode) []FrameOrdering {
+	ordering := make([]FrameOrdering, 0, len(node.Ordering))
+	for _, order := range node.Ordering {
+		index := order.Index
+		if order.Type == LogCallOrderCall {
+			index = node.Children[order.Index]
+		}
+		ordering = append(ordering, FrameOrdering{Type: order.Type.String(), Index: index})
+	}
+	return ordering
+}
+
 type TransactionInfo struct {
 	Hash        *common.Hash
 	Index       *uint64
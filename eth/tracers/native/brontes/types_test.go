@@ -0,0 +1,63 @@
+package brontes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallKindText(t *testing.T) {
+	for kind := range callKinds {
+		text, err := kind.MarshalText()
+		require.NoError(t, err)
+		assert.Equal(t, kind.String(), string(text))
+
+		var decoded CallKind
+		require.NoError(t, decoded.UnmarshalText(text))
+		assert.Equal(t, kind, decoded)
+	}
+	var kind CallKind
+	assert.Error(t, kind.UnmarshalText([]byte("jump")))
+	_, err := CallKind("jump").MarshalText()
+	assert.Error(t, err)
+
+	// Unknown kinds are rejected when decoding actions.
+	_, err = unmarshalAction(ActionTypeCall, []byte(`{"callType":"jump"}`))
+	assert.Error(t, err)
+	decoded, err := unmarshalAction(ActionTypeCall, []byte(`{"callType":"extdelegatecall"}`))
+	require.NoError(t, err)
+	assert.Equal(t, CallKindExtDelegateCall, decoded.Call.CallType)
+
+	stats, err := json.Marshal(&TxStats{FramesPerKind: map[CallKind]int{CallKindEOFCreate: 1}})
+	require.NoError(t, err)
+	assert.Contains(t, string(stats), `"frames_per_kind":{"eofcreate":1}`)
+}
+
+func TestEOFCallKinds(t *testing.T) {
+	tests := []struct {
+		op     vm.OpCode
+		kind   CallKind
+		call   bool
+		create bool
+	}{
+		{vm.EXTCALL, CallKindExtCall, true, false},
+		{vm.EXTSTATICCALL, CallKindExtStaticCall, true, false},
+		{vm.EXTDELEGATECALL, CallKindExtDelegateCall, true, false},
+		{vm.EOFCREATE, CallKindEOFCreate, false, true},
+	}
+	for _, tt := range tests {
+		kind, err := FromCallTypeCode(byte(tt.op))
+		require.NoError(t, err)
+		assert.Equal(t, tt.kind, kind)
+		assert.Equal(t, tt.call, kind.IsAnyCall(), kind)
+		assert.Equal(t, tt.create, kind.IsAnyCreate(), kind)
+	}
+	assert.True(t, CallKindExtStaticCall.IsStaticCall())
+	assert.True(t, CallKindExtDelegateCall.IsDelegate())
+	assert.False(t, CallKindExtCall.IsDelegate())
+	_, err := FromCallTypeCode(byte(vm.JUMP))
+	assert.Error(t, err)
+}
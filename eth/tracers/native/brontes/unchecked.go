@@ -0,0 +1,146 @@
+package brontes
+
+import (
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// maxCheckSteps bounds the number of caller steps followed to find out
+// whether the success flag of a call is checked. Flags still on the stack
+// by then are assumed to be checked.
+const maxCheckSteps = 64
+
+// callCheck follows the success flag pushed by a call through the stack of
+// the caller. The flag is checked once it, or a value computed from it, is
+// consumed by ISZERO or JUMPI. It is unchecked if all its copies are popped
+// or left on the stack when the caller returns. Flags escaping into memory,
+// storage or logs are assumed to be checked elsewhere.
+type callCheck struct {
+	traceIdx int          // frame of the call
+	depth    int          // execution depth of the caller steps
+	started  bool         // whether the flag was pushed
+	tainted  map[int]bool // stack positions, from the bottom, holding the flag
+	lastOp   vm.OpCode    // last caller opcode, awaiting its effect on the stack
+	lastLen  int          // stack size before the last opcode
+	steps    int
+}
+
+// uncheckedCallDetector flags the calls whose success flag is ignored by the
+// caller.
+type uncheckedCallDetector struct {
+	pending   []*callCheck
+	unchecked map[int]bool // frames of unchecked calls
+}
+
+func newUncheckedCallDetector() *uncheckedCallDetector {
+	return &uncheckedCallDetector{unchecked: make(map[int]bool)}
+}
+
+// onCallExit starts following the success flag of a finished call, which
+// the caller steps at the given depth find on top of their stack.
+func (d *uncheckedCallDetector) onCallExit(traceIdx int, callerDepth int) {
+	d.pending = append(d.pending, &callCheck{traceIdx: traceIdx, depth: callerDepth, tainted: make(map[int]bool)})
+}
+
+// onStep updates the flags followed in the frame running at the given depth.
+func (d *uncheckedCallDetector) onStep(op vm.OpCode, stackLen int, depth int) {
+	kept := d.pending[:0]
+	for _, check := range d.pending {
+		if check.depth != depth {
+			kept = append(kept, check)
+			continue
+		}
+		checked := false
+		if !check.started {
+			check.started = true
+			check.tainted[stackLen-1] = true
+		} else {
+			checked = check.apply(stackLen)
+		}
+		check.lastOp, check.lastLen = op, stackLen
+		check.steps++
+		switch {
+		case checked || check.steps > maxCheckSteps:
+		case len(check.tainted) == 0:
+			d.unchecked[check.traceIdx] = true
+		default:
+			kept = append(kept, check)
+		}
+	}
+	d.pending = kept
+}
+
+// onFrameExit resolves the flags still followed in a frame returning without
+// error, as their values are discarded.
+func (d *uncheckedCallDetector) onFrameExit(depth int, failed bool) {
+	kept := d.pending[:0]
+	for _, check := range d.pending {
+		if check.depth != depth {
+			kept = append(kept, check)
+			continue
+		}
+		if !failed && check.started && len(check.tainted) > 0 {
+			d.unchecked[check.traceIdx] = true
+		}
+	}
+	d.pending = kept
+}
+
+// apply moves the flag according to the effect of the last opcode, given the
+// resulting stack size, reporting whether the flag was checked.
+func (c *callCheck) apply(stackLen int) bool {
+	op, before := c.lastOp, c.lastLen
+	switch {
+	case op >= vm.DUP1 && op <= vm.DUP16:
+		if c.tainted[before-int(op-vm.DUP1)-1] {
+			c.tainted[before] = true
+		}
+		return false
+	case op >= vm.SWAP1 && op <= vm.SWAP16:
+		top, other := before-1, before-int(op-vm.SWAP1)-2
+		c.tainted[top], c.tainted[other] = c.tainted[other], c.tainted[top]
+		c.clean()
+		return false
+	}
+	pushes := 1
+	if pushesNothing(op) {
+		pushes = 0
+	}
+	pops := before - stackLen + pushes
+	consumed := false
+	for pos := range c.tainted {
+		if pos >= before-pops {
+			delete(c.tainted, pos)
+			consumed = true
+		}
+	}
+	if !consumed || op == vm.POP {
+		return false
+	}
+	if op == vm.ISZERO || op == vm.JUMPI || pushes == 0 {
+		return true
+	}
+	// The result of the operation carries the flag on.
+	c.tainted[stackLen-1] = true
+	return false
+}
+
+// clean drops the stack positions not holding the flag.
+func (c *callCheck) clean() {
+	for pos, tainted := range c.tainted {
+		if !tainted {
+			delete(c.tainted, pos)
+		}
+	}
+}
+
+// pushesNothing reports whether the opcode leaves no result on the stack.
+func pushesNothing(op vm.OpCode) bool {
+	switch op {
+	case vm.POP, vm.JUMP, vm.JUMPI, vm.JUMPDEST, vm.MSTORE, vm.MSTORE8, vm.SSTORE, vm.TSTORE,
+		vm.CALLDATACOPY, vm.CODECOPY, vm.EXTCODECOPY, vm.RETURNDATACOPY, vm.MCOPY,
+		vm.LOG0, vm.LOG1, vm.LOG2, vm.LOG3, vm.LOG4,
+		vm.STOP, vm.RETURN, vm.REVERT, vm.INVALID, vm.SELFDESTRUCT:
+		return true
+	}
+	return false
+}
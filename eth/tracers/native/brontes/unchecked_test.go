@@ -0,0 +1,103 @@
+package brontes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/stretchr/testify/require"
+)
+
+// executeTraced runs code calling into the callee and returns its trace.
+func executeTraced(t *testing.T, config TracingInspectorConfig, code []byte, callee common.Address, calleeCode []byte) *TxTrace {
+	t.Helper()
+	inspector, tx, receipt := executeInspected(t, config, code, callee, calleeCode)
+	result, err := inspector.IntoTraceResults(tx, receipt, 0)
+	require.NoError(t, err)
+	return result
+}
+
+// executeInspected runs code calling into the callee and returns the
+// inspector having recorded it.
+func executeInspected(t *testing.T, config TracingInspectorConfig, code []byte, callee common.Address, calleeCode []byte) (*BrontesInspector, *types.Transaction, *types.Receipt) {
+	t.Helper()
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabase(triedb.NewDatabase(rawdb.NewMemoryDatabase(), nil), nil))
+	require.NoError(t, err)
+	statedb.SetCode(callee, calleeCode)
+
+	var (
+		inspector *BrontesInspector
+		receipt   *types.Receipt
+		tx        *types.Transaction
+	)
+	hooks := &tracing.Hooks{
+		OnTxStart: func(env *tracing.VMContext, transaction *types.Transaction, from common.Address) {
+			inspector = NewBrontesInspector(context.Background(), config, params.AllEthashProtocolChanges, env, transaction, from)
+			tx = transaction
+		},
+		OnTxEnd: func(r *types.Receipt, err error) { receipt = r },
+		OnEnter: func(depth int, typ byte, from, to common.Address, input []byte, gas uint64, value *big.Int) {
+			require.NoError(t, inspector.OnEnter(depth, typ, from, to, input, gas, value))
+		},
+		OnExit: func(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+			inspector.OnExit(depth, output, gasUsed, err, reverted)
+		},
+		OnOpcode: func(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+			inspector.OnOpcode(pc, op, gas, cost, scope, rData, depth, err)
+		},
+		OnLog:       func(l *types.Log) { inspector.OnLog(l) },
+		OnGasChange: func(old, new uint64, reason tracing.GasChangeReason) { inspector.OnGasChange(old, new, reason) },
+	}
+	_, _, err = runtime.Execute(code, nil, &runtime.Config{
+		ChainConfig: params.AllEthashProtocolChanges,
+		BlockNumber: big.NewInt(1),
+		State:       statedb,
+		EVMConfig:   vm.Config{Tracer: hooks},
+	})
+	require.NoError(t, err)
+	return inspector, tx, receipt
+}
+
+func TestUncheckedCalls(t *testing.T) {
+	callee := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	// call pushes the arguments of a call to the callee and executes it,
+	// leaving the success flag at pc 33.
+	call := append([]byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH20),
+	}, callee.Bytes()...)
+	call = append(call, byte(vm.GAS), byte(vm.CALL))
+
+	tests := []struct {
+		name      string
+		check     []byte
+		unchecked bool
+	}{
+		{"popped", []byte{byte(vm.POP), byte(vm.STOP)}, true},
+		{"left on stack", []byte{byte(vm.STOP)}, true},
+		{"copy popped", []byte{byte(vm.DUP1), byte(vm.POP), byte(vm.POP), byte(vm.STOP)}, true},
+		{"require", []byte{byte(vm.ISZERO), byte(vm.PUSH1), 38, byte(vm.JUMPI), byte(vm.STOP), byte(vm.JUMPDEST), byte(vm.STOP)}, false},
+		{"swapped and branched", []byte{byte(vm.PUSH1), 41, byte(vm.SWAP1), byte(vm.PUSH1), 1, byte(vm.AND), byte(vm.SWAP1), byte(vm.JUMPI), byte(vm.JUMPDEST), byte(vm.STOP)}, false},
+		{"stored", []byte{byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.STOP)}, false},
+	}
+	config := DefaultTracingInspectorConfig
+	config.DetectUncheckedCalls = true
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := append(append([]byte{}, call...), tt.check...)
+			trace := executeTraced(t, config, code, callee, []byte{byte(vm.STOP)})
+			require.Len(t, trace.Trace, 2)
+			require.False(t, trace.Trace[0].UncheckedCall)
+			require.Equal(t, tt.unchecked, trace.Trace[1].UncheckedCall)
+		})
+	}
+}
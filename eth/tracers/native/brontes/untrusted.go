@@ -0,0 +1,222 @@
+package brontes
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// Sources of untrusted delegate call targets.
+const (
+	// TargetSourceCalldata marks targets derived from the call data of the
+	// delegating frame.
+	TargetSourceCalldata = "calldata"
+	// TargetSourceStorage marks targets loaded from storage slots written
+	// earlier in the same transaction.
+	TargetSourceStorage = "storage"
+)
+
+// taint is a set of untrusted sources a value is derived from.
+type taint uint8
+
+const (
+	taintCalldata taint = 1 << iota
+	taintStorage
+)
+
+// sources returns the names of the sources of the taint.
+func (t taint) sources() []string {
+	var sources []string
+	if t&taintCalldata != 0 {
+		sources = append(sources, TargetSourceCalldata)
+	}
+	if t&taintStorage != 0 {
+		sources = append(sources, TargetSourceStorage)
+	}
+	return sources
+}
+
+// taintFrame follows the taint of the stack and memory of a single frame.
+// Memory is tracked per word, values copied by other means than MSTORE and
+// CALLDATACOPY are not followed.
+type taintFrame struct {
+	address common.Address   // context address of the frame
+	stack   []taint          // taint of the stack items, from the bottom
+	memory  map[uint64]taint // taint of the memory words
+	started bool             // whether the frame executed an opcode
+	lastOp  vm.OpCode        // last opcode, awaiting its effect on the stack
+	lastLen int              // stack size before the last opcode
+	fixed   bool             // whether the result taint is set by the last opcode
+	result  taint            // result taint if fixed
+}
+
+// delegateTargetDetector flags the delegate calls whose target is derived
+// from call data, or from storage written in the same transaction, which
+// allows the caller to run arbitrary code in the context of the contract.
+type delegateTargetDetector struct {
+	frames  []*taintFrame
+	written map[common.Address]map[common.Hash]bool // slots written so far
+	next    taint                                   // taint of the delegate call about to enter
+	flagged map[int]taint                           // delegate call frames with untrusted targets
+}
+
+func newDelegateTargetDetector() *delegateTargetDetector {
+	return &delegateTargetDetector{
+		written: make(map[common.Address]map[common.Hash]bool),
+		flagged: make(map[int]taint),
+	}
+}
+
+// onStep updates the taint of the frame running at the given depth and
+// records the effect of the opcode about to be executed.
+func (d *delegateTargetDetector) onStep(op vm.OpCode, scope tracing.OpContext, depth int) {
+	for len(d.frames) > depth {
+		d.frames = d.frames[:len(d.frames)-1]
+	}
+	for len(d.frames) < depth {
+		d.frames = append(d.frames, &taintFrame{address: scope.Address(), memory: make(map[uint64]taint)})
+	}
+	f := d.frames[depth-1]
+	stack := scope.StackData()
+	if f.started {
+		f.apply(len(stack))
+	}
+	if len(f.stack) != len(stack) {
+		// Lost track of the stack, start over with untainted items.
+		f.stack = make([]taint, len(stack))
+	}
+	f.started, f.lastOp, f.lastLen, f.fixed, f.result = true, op, len(stack), false, 0
+
+	// peek returns the nth stack item from the top, reporting whether it
+	// fits in 64 bits.
+	peek := func(n int) (uint64, bool) {
+		return stack[len(stack)-1-n].Uint64(), stack[len(stack)-1-n].IsUint64()
+	}
+	if len(stack) < stackArgs(op) {
+		// Failing opcodes may underflow the stack.
+		return
+	}
+	switch op {
+	case vm.CALLDATALOAD:
+		f.fixed, f.result = true, taintCalldata
+	case vm.MLOAD:
+		f.fixed = true
+		if offset, ok := peek(0); ok {
+			f.result = f.memory[offset/32] | f.memory[(offset+31)/32]
+		}
+	case vm.MSTORE:
+		offset, ok := peek(0)
+		if !ok {
+			break
+		}
+		value := f.stack[len(stack)-2]
+		if offset%32 == 0 {
+			f.setWord(offset/32, value)
+		} else {
+			f.memory[offset/32] |= value
+			f.memory[offset/32+1] |= value
+		}
+	case vm.CALLDATACOPY:
+		dest, ok1 := peek(0)
+		size, ok2 := peek(2)
+		if ok1 && ok2 && size > 0 && dest+size > dest {
+			for word := dest / 32; word <= (dest+size-1)/32; word++ {
+				f.memory[word] |= taintCalldata
+			}
+		}
+	case vm.SLOAD:
+		f.fixed = true
+		if d.written[f.address][common.Hash(stack[len(stack)-1].Bytes32())] {
+			f.result = taintStorage
+		}
+	case vm.SSTORE:
+		slots, ok := d.written[f.address]
+		if !ok {
+			slots = make(map[common.Hash]bool)
+			d.written[f.address] = slots
+		}
+		slots[common.Hash(stack[len(stack)-1].Bytes32())] = true
+	case vm.DELEGATECALL:
+		d.next = f.stack[len(stack)-2]
+		f.fixed = true
+	case vm.CALL, vm.CALLCODE, vm.STATICCALL, vm.CREATE, vm.CREATE2:
+		// The success flag and created address do not depend on the
+		// taint of the operands.
+		f.fixed = true
+	}
+}
+
+// stackArgs returns the number of stack items inspected by onStep.
+func stackArgs(op vm.OpCode) int {
+	switch op {
+	case vm.MLOAD, vm.SLOAD:
+		return 1
+	case vm.MSTORE, vm.SSTORE, vm.DELEGATECALL:
+		return 2
+	case vm.CALLDATACOPY:
+		return 3
+	}
+	return 0
+}
+
+// onEnter assigns the taint of the target of a delegate call to its frame.
+func (d *delegateTargetDetector) onEnter(traceIdx int, kind CallKind) {
+	if kind == CallKindDelegateCall && d.next != 0 {
+		d.flagged[traceIdx] = d.next
+	}
+	d.next = 0
+}
+
+// sources returns the sources of the target of a delegate call frame, nil
+// if trusted or not detected.
+func (d *delegateTargetDetector) sources(traceIdx int) []string {
+	if d == nil {
+		return nil
+	}
+	return d.flagged[traceIdx].sources()
+}
+
+// setWord sets the taint of a memory word.
+func (f *taintFrame) setWord(word uint64, t taint) {
+	if t == 0 {
+		delete(f.memory, word)
+	} else {
+		f.memory[word] = t
+	}
+}
+
+// apply updates the stack taint according to the effect of the last opcode,
+// given the resulting stack size. Results are tainted by their operands.
+func (f *taintFrame) apply(stackLen int) {
+	op, before := f.lastOp, f.lastLen
+	switch {
+	case op >= vm.DUP1 && op <= vm.DUP16:
+		if n := before - int(op-vm.DUP1) - 1; n >= 0 && stackLen == before+1 {
+			f.stack = append(f.stack, f.stack[n])
+		}
+		return
+	case op >= vm.SWAP1 && op <= vm.SWAP16:
+		if other := before - int(op-vm.SWAP1) - 2; other >= 0 && stackLen == before {
+			f.stack[before-1], f.stack[other] = f.stack[other], f.stack[before-1]
+		}
+		return
+	}
+	pushes := 1
+	if pushesNothing(op) {
+		pushes = 0
+	}
+	pops := before - stackLen + pushes
+	if pops < 0 || pops > before {
+		return
+	}
+	result := f.result
+	if !f.fixed {
+		for _, t := range f.stack[before-pops:] {
+			result |= t
+		}
+	}
+	f.stack = f.stack[:before-pops]
+	if pushes == 1 {
+		f.stack = append(f.stack, result)
+	}
+}
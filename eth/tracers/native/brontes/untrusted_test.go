@@ -0,0 +1,50 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUntrustedDelegateTargets(t *testing.T) {
+	impl := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	// delegate pushes the argument offsets and sizes of a delegate call,
+	// the target is pushed by each test.
+	delegate := []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0}
+	call := []byte{byte(vm.GAS), byte(vm.DELEGATECALL), byte(vm.STOP)}
+	pushImpl := append([]byte{byte(vm.PUSH20)}, impl.Bytes()...)
+	mask := append([]byte{byte(vm.PUSH20)}, common.MaxAddress.Bytes()...)
+
+	tests := []struct {
+		name    string
+		target  []byte
+		sources []string
+	}{
+		{"constant", pushImpl, nil},
+		{"calldata", []byte{byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD)}, []string{TargetSourceCalldata}},
+		{"masked calldata", append(append([]byte{byte(vm.PUSH1), 4, byte(vm.CALLDATALOAD)}, mask...), byte(vm.AND)), []string{TargetSourceCalldata}},
+		{"copied calldata", []byte{
+			byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.PUSH1), 64, byte(vm.CALLDATACOPY),
+			byte(vm.PUSH1), 64, byte(vm.MLOAD),
+		}, []string{TargetSourceCalldata}},
+		{"unwritten storage", []byte{byte(vm.PUSH1), 0, byte(vm.SLOAD)}, nil},
+		{"written storage", append(append(pushImpl, byte(vm.PUSH1), 0, byte(vm.SSTORE)), byte(vm.PUSH1), 0, byte(vm.SLOAD)), []string{TargetSourceStorage}},
+		{"overwritten memory", []byte{
+			byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.CALLDATACOPY),
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 0, byte(vm.MLOAD),
+		}, nil},
+	}
+	config := DefaultTracingInspectorConfig
+	config.DetectUntrustedDelegates = true
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := append(append(append([]byte{}, delegate...), tt.target...), call...)
+			trace := executeTraced(t, config, code, impl, []byte{byte(vm.STOP)})
+			require.Len(t, trace.Trace, 2)
+			require.Equal(t, tt.sources, trace.Trace[1].UntrustedTarget)
+		})
+	}
+}
@@ -1,10 +1,5 @@
 package brontes
 
-import (
-	"encoding/hex"
-	"fmt"
-)
-
 type TraceStyle int
 
 const (
@@ -25,18 +20,3 @@ func maybeRevertReason(data []byte) *string {
 	reason := "revert"
 	return &reason
 }
-
-// convertMemory converts a []byte into 32‐byte hex string chunks.
-func convertMemory(mem []byte) []string {
-	const chunkSize = 32
-	var chunks []string
-	for i := 0; i < len(mem); i += chunkSize {
-		end := i + chunkSize
-		if end > len(mem) {
-			end = len(mem)
-		}
-		chunk := mem[i:end]
-		chunks = append(chunks, fmt.Sprintf("0x%s", hex.EncodeToString(chunk)))
-	}
-	return chunks
-}
@@ -0,0 +1,152 @@
+package brontes
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Checks performed by VerifyReceipt.
+const (
+	CheckStatus          = "status"
+	CheckGasUsed         = "gas_used"
+	CheckLogsBloom       = "logs_bloom"
+	CheckContractAddress = "contract_address"
+)
+
+// Discrepancy is a mismatch between a trace and the receipt of its
+// transaction.
+type Discrepancy struct {
+	Check    string `json:"check"`
+	Expected string `json:"expected"` // value of the receipt
+	Actual   string `json:"actual"`   // value derived from the trace
+}
+
+// VerifyReceipt checks the outcome derived from the trace against the
+// on-chain receipt of the transaction: the status, the gas used, the bloom of
+// the logs emitted by frames that were not reverted, and the address of a
+// contract created by the transaction. Any mismatch points to a tracer bug.
+func VerifyReceipt(trace *TxTrace, receipt *types.Receipt) []Discrepancy {
+	var discrepancies []Discrepancy
+	check := func(name string, expected, actual any) {
+		if e, a := fmt.Sprint(expected), fmt.Sprint(actual); e != a {
+			discrepancies = append(discrepancies, Discrepancy{Check: name, Expected: e, Actual: a})
+		}
+	}
+	check(CheckStatus, receipt.Status == types.ReceiptStatusSuccessful, trace.IsSuccess)
+	if trace.GasUsed != nil {
+		check(CheckGasUsed, receipt.GasUsed, trace.GasUsed)
+	}
+	bloom := traceBloom(trace)
+	check(CheckLogsBloom, hexutil.Bytes(receipt.Bloom.Bytes()), hexutil.Bytes(bloom.Bytes()))
+
+	var created common.Address
+	if len(trace.Trace) > 0 && len(trace.Trace[0].Trace.TraceAddress) == 0 && trace.Trace[0].IsCreate() {
+		created = trace.Trace[0].GetCreateOutput()
+	}
+	check(CheckContractAddress, receipt.ContractAddress, created)
+	return discrepancies
+}
+
+// VerifyOutcome checks the outcome derived from the trace against the outcome
+// of an execution without receipt, such as a simulation: the status, the gas
+// used and the bloom of the logs kept by the transaction.
+func VerifyOutcome(trace *TxTrace, outcome *ExecutionOutcome) []Discrepancy {
+	var discrepancies []Discrepancy
+	check := func(name string, expected, actual any) {
+		if e, a := fmt.Sprint(expected), fmt.Sprint(actual); e != a {
+			discrepancies = append(discrepancies, Discrepancy{Check: name, Expected: e, Actual: a})
+		}
+	}
+	check(CheckStatus, outcome.Success, trace.IsSuccess)
+	if trace.GasUsed != nil {
+		check(CheckGasUsed, outcome.GasUsed, trace.GasUsed)
+	}
+	expected := types.CreateBloom(&types.Receipt{Logs: outcome.Logs})
+	bloom := traceBloom(trace)
+	check(CheckLogsBloom, hexutil.Bytes(expected.Bytes()), hexutil.Bytes(bloom.Bytes()))
+	return discrepancies
+}
+
+// traceBloom computes the bloom filter of the logs kept by the transaction,
+// leaving out the logs of frames reverted by themselves or by an ancestor.
+func traceBloom(trace *TxTrace) types.Bloom {
+	var bloom types.Bloom
+	reverted := revertedFrames(trace)
+	for i, frame := range trace.Trace {
+		if reverted[i] {
+			continue
+		}
+		for _, log := range frame.Logs {
+			bloom.Add(log.Address.Bytes())
+			for _, topic := range log.Topics {
+				bloom.Add(topic.Bytes())
+			}
+		}
+	}
+	return bloom
+}
+
+// revertedFrames reports for every frame of the trace whether its effects
+// were reverted, by itself or by an ancestor.
+func revertedFrames(trace *TxTrace) []bool {
+	var (
+		result   = make([]bool, len(trace.Trace))
+		failures [][]uint
+	)
+	for i, frame := range trace.Trace {
+		address := frame.Trace.TraceAddress
+		if frame.Trace.Error != nil {
+			failures = append(failures, address)
+		}
+		if frame.RevertedByParent {
+			result[i] = true
+			continue
+		}
+		result[i] = slices.ContainsFunc(failures, func(ancestor []uint) bool {
+			return len(ancestor) <= len(address) && slices.Equal(ancestor, address[:len(ancestor)])
+		})
+	}
+	return result
+}
+
+// revertedByParent reports for every recorded frame whether one of its
+// ancestors failed, rolling back the effects of the frame. Parents precede
+// their children in the arena.
+func revertedByParent(nodes []CallTraceNode) []bool {
+	result := make([]bool, len(nodes))
+	for i := range nodes {
+		if parent := nodes[i].Parent; parent != nil && *parent != i {
+			result[i] = !nodes[*parent].Trace.Success || result[*parent]
+		}
+	}
+	return result
+}
+
+// ClickhouseQuarantine represents the discrepancies found between traces and
+// receipts for ClickHouse, one row per failed check, so suspicious traces can
+// be set aside and re-traced.
+type ClickhouseQuarantine struct {
+	ChainId []uint64
+	ClickhouseTxPosition
+	Check    []string
+	Expected []string
+	Actual   []string
+}
+
+// NewClickhouseQuarantine creates a ClickhouseQuarantine from the discrepancies
+// found in a TxTrace
+func NewClickhouseQuarantine(value *TxTrace, discrepancies []Discrepancy) *ClickhouseQuarantine {
+	result := &ClickhouseQuarantine{}
+	for _, d := range discrepancies {
+		result.ChainId = append(result.ChainId, value.ChainId)
+		result.appendTx(value)
+		result.Check = append(result.Check, d.Check)
+		result.Expected = append(result.Expected, d.Expected)
+		result.Actual = append(result.Actual, d.Actual)
+	}
+	return result
+}
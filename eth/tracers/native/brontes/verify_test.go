@@ -0,0 +1,44 @@
+package brontes
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyReceipt(t *testing.T) {
+	trace := newTestTxTrace()
+	receipt := &types.Receipt{
+		Status:  types.ReceiptStatusSuccessful,
+		GasUsed: 80000,
+		Logs:    []*types.Log{&trace.Trace[0].Logs[0]},
+	}
+	receipt.Bloom = types.CreateBloom(receipt)
+	assert.Empty(t, VerifyReceipt(trace, receipt))
+
+	// Logs of reverted frames are not part of the receipt.
+	failed := "execution reverted"
+	reverted := newTestTxTrace()
+	reverted.Trace[1].Trace.Error = &failed
+	reverted.Trace[1].Logs = []types.Log{{Address: common.HexToAddress("0x44")}}
+	assert.Empty(t, VerifyReceipt(reverted, receipt))
+
+	// Mismatches are reported per check.
+	receipt.GasUsed = 1
+	receipt.ContractAddress = common.HexToAddress("0x55")
+	receipt.Bloom = types.Bloom{}
+	discrepancies := VerifyReceipt(trace, receipt)
+	var checks []string
+	for _, d := range discrepancies {
+		checks = append(checks, d.Check)
+	}
+	assert.Equal(t, []string{CheckGasUsed, CheckLogsBloom, CheckContractAddress}, checks)
+	assert.Equal(t, Discrepancy{Check: CheckGasUsed, Expected: "1", Actual: "80000"}, discrepancies[0])
+
+	quarantine := NewClickhouseQuarantine(trace, discrepancies)
+	assert.Equal(t, checks, quarantine.Check)
+	assert.Equal(t, []uint64{12345, 12345, 12345}, quarantine.BlockNumber)
+	assert.Equal(t, trace.TxHash.Hex(), quarantine.TxHash[0])
+}
@@ -0,0 +1,72 @@
+package brontes
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// StateWitness is the state a transaction accessed, as it was when first
+// accessed: the accounts it touched with their code, and the storage slots it
+// read or wrote. It allows re-executing the transaction statelessly, without
+// the node that traced it.
+type StateWitness struct {
+	Accounts map[common.Address]*WitnessAccount `json:"accounts"`
+}
+
+// WitnessAccount is an account of the state witness. Accounts that did not
+// exist have a zero balance and nonce and no code.
+type WitnessAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+func NewStateWitness() *StateWitness {
+	return &StateWitness{Accounts: make(map[common.Address]*WitnessAccount)}
+}
+
+// touchAccount records the account unless it has been accessed before.
+func (w *StateWitness) touchAccount(statedb tracing.StateDB, addr common.Address) *WitnessAccount {
+	if account, ok := w.Accounts[addr]; ok {
+		return account
+	}
+	account := &WitnessAccount{
+		Balance: (*hexutil.Big)(statedb.GetBalance(addr).ToBig()),
+		Nonce:   statedb.GetNonce(addr),
+		Code:    common.CopyBytes(statedb.GetCode(addr)),
+	}
+	w.Accounts[addr] = account
+	return account
+}
+
+// touchSlot records the storage slot of the account unless it has been
+// accessed before.
+func (w *StateWitness) touchSlot(statedb tracing.StateDB, addr common.Address, slot common.Hash) {
+	account := w.touchAccount(statedb, addr)
+	if account.Storage == nil {
+		account.Storage = make(map[common.Hash]common.Hash)
+	}
+	if _, ok := account.Storage[slot]; !ok {
+		account.Storage[slot] = statedb.GetState(addr, slot)
+	}
+}
+
+// recordOpcode records the accounts and storage slots accessed by the given
+// opcode before it executes. Calls and creations are recorded when the frame
+// is entered.
+func (w *StateWitness) recordOpcode(statedb tracing.StateDB, op vm.OpCode, scope tracing.OpContext) {
+	stack := scope.StackData()
+	if len(stack) == 0 {
+		return
+	}
+	top := stack[len(stack)-1]
+	switch op {
+	case vm.SLOAD, vm.SSTORE:
+		w.touchSlot(statedb, scope.Address(), common.Hash(top.Bytes32()))
+	case vm.BALANCE, vm.EXTCODESIZE, vm.EXTCODECOPY, vm.EXTCODEHASH, vm.SELFDESTRUCT:
+		w.touchAccount(statedb, common.Address(top.Bytes20()))
+	}
+}
@@ -0,0 +1,91 @@
+package brontes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testScope is the context of an executing opcode with a fixed stack.
+type testScope struct {
+	address common.Address
+	stack   []uint256.Int
+}
+
+func (s *testScope) MemoryData() []byte       { return nil }
+func (s *testScope) StackData() []uint256.Int { return s.stack }
+func (s *testScope) Caller() common.Address   { return common.Address{} }
+func (s *testScope) Address() common.Address  { return s.address }
+func (s *testScope) CallValue() *uint256.Int  { return new(uint256.Int) }
+func (s *testScope) CallInput() []byte        { return nil }
+func (s *testScope) ContractCode() []byte     { return nil }
+func (s *testScope) slot(slot common.Hash) *testScope {
+	s.stack = []uint256.Int{*new(uint256.Int).SetBytes(slot[:])}
+	return s
+}
+
+func TestStateWitness(t *testing.T) {
+	var (
+		from     = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to       = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		queried  = common.HexToAddress("0x3333333333333333333333333333333333333333")
+		coinbase = common.HexToAddress("0x4444444444444444444444444444444444444444")
+		slot     = common.HexToHash("0x01")
+		code     = []byte{0x60, 0x00}
+	)
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	require.NoError(t, err)
+	statedb.SetBalance(from, uint256.NewInt(100), tracing.BalanceChangeUnspecified)
+	statedb.SetNonce(from, 7, tracing.NonceChangeUnspecified)
+	statedb.SetCode(to, code)
+	statedb.SetState(to, slot, common.HexToHash("0xaa"))
+	statedb.SetBalance(queried, uint256.NewInt(5), tracing.BalanceChangeUnspecified)
+
+	config := DefaultTracingInspectorConfig
+	config.RecordWitness = true
+	var (
+		env = &tracing.VMContext{BlockNumber: big.NewInt(1), Time: 1, Coinbase: coinbase, StateDB: statedb}
+		tx  = types.NewTx(&types.LegacyTx{To: &to, Gas: 100000})
+	)
+	inspector := NewBrontesInspector(context.Background(), config, params.MainnetChainConfig, env, tx, from)
+	require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+
+	// The slot is recorded with its value before the first write.
+	scope := &testScope{address: to}
+	inspector.OnOpcode(0, byte(vm.SSTORE), 100000, 0, scope.slot(slot), nil, 1, nil)
+	statedb.SetState(to, slot, common.HexToHash("0xbb"))
+	inspector.OnOpcode(1, byte(vm.SLOAD), 100000, 0, scope.slot(slot), nil, 1, nil)
+	scope.stack = []uint256.Int{*new(uint256.Int).SetBytes(queried[:])}
+	inspector.OnOpcode(2, byte(vm.BALANCE), 100000, 0, scope, nil, 1, nil)
+	inspector.OnExit(0, nil, 21000, nil, false)
+
+	result, err := inspector.IntoTraceResults(tx, &types.Receipt{Status: types.ReceiptStatusSuccessful}, 0)
+	require.NoError(t, err)
+	witness := result.Witness
+	require.NotNil(t, witness)
+	assert.Len(t, witness.Accounts, 4)
+	assert.Equal(t, uint64(7), witness.Accounts[from].Nonce)
+	assert.Equal(t, big.NewInt(100), witness.Accounts[from].Balance.ToInt())
+	assert.Equal(t, code, []byte(witness.Accounts[to].Code))
+	assert.Equal(t, map[common.Hash]common.Hash{slot: common.HexToHash("0xaa")}, witness.Accounts[to].Storage)
+	assert.Equal(t, big.NewInt(5), witness.Accounts[queried].Balance.ToInt())
+	assert.Contains(t, witness.Accounts, coinbase)
+
+	// The witness is left out unless enabled.
+	inspector = NewBrontesInspector(context.Background(), DefaultTracingInspectorConfig, params.MainnetChainConfig, env, tx, from)
+	require.NoError(t, inspector.OnEnter(0, byte(vm.CALL), from, to, nil, 100000, big.NewInt(0)))
+	inspector.OnExit(0, nil, 21000, nil, false)
+	result, err = inspector.IntoTraceResults(tx, &types.Receipt{}, 0)
+	require.NoError(t, err)
+	assert.Nil(t, result.Witness)
+}
@@ -0,0 +1,334 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/native/brontes"
+
+	// Register the brontes tracer.
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
+)
+
+var (
+	errTracingUnsupported   = errors.New("brontes tracing not enabled")
+	errTracingAuthenticated = errors.New("brontes tracing only served on the authenticated RPC endpoint")
+)
+
+// brontesAPI returns the brontes tracing API of the node, so its access limits
+// and trace cache apply to GraphQL queries as well. GraphQL is served without
+// authentication, so an API restricted to the authenticated endpoint is not.
+func (r *Resolver) brontesAPI() (*tracers.BrontesAPI, error) {
+	api := tracers.RegisteredBrontesAPI()
+	if api == nil {
+		return nil, errTracingUnsupported
+	}
+	if api.Authenticated() {
+		return nil, errTracingAuthenticated
+	}
+	return api, nil
+}
+
+// decodeBrontesTrace decodes the result of the brontes tracer.
+func decodeBrontesTrace(result interface{}) (*BrontesTrace, error) {
+	blob, ok := result.(json.RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected brontes trace result %T", result)
+	}
+	trace := new(brontes.TxTrace)
+	if err := json.Unmarshal(blob, trace); err != nil {
+		return nil, err
+	}
+	return &BrontesTrace{trace: trace}, nil
+}
+
+// BrontesTrace is the brontes trace of a transaction.
+type BrontesTrace struct {
+	trace *brontes.TxTrace
+}
+
+func (t *BrontesTrace) TxHash() common.Hash {
+	return t.trace.TxHash
+}
+
+func (t *BrontesTrace) TxIndex() hexutil.Uint64 {
+	return hexutil.Uint64(t.trace.TxIndex)
+}
+
+func (t *BrontesTrace) GasUsed() hexutil.Big {
+	return bigOrZero(t.trace.GasUsed)
+}
+
+func (t *BrontesTrace) EffectivePrice() hexutil.Big {
+	return bigOrZero(t.trace.EffectivePrice)
+}
+
+func (t *BrontesTrace) IsSuccess() bool {
+	return t.trace.IsSuccess
+}
+
+func (t *BrontesTrace) Frames() []*BrontesFrame {
+	frames := make([]*BrontesFrame, len(t.trace.Trace))
+	for i := range t.trace.Trace {
+		frames[i] = &BrontesFrame{frame: &t.trace.Trace[i]}
+	}
+	return frames
+}
+
+// BrontesFrame is a single call frame of a brontes trace.
+type BrontesFrame struct {
+	frame *brontes.TransactionTraceWithLogs
+}
+
+func (f *BrontesFrame) TraceIdx() hexutil.Uint64 {
+	return hexutil.Uint64(f.frame.TraceIdx)
+}
+
+func (f *BrontesFrame) TraceAddress() []hexutil.Uint64 {
+	addr := make([]hexutil.Uint64, len(f.frame.Trace.TraceAddress))
+	for i, idx := range f.frame.Trace.TraceAddress {
+		addr[i] = hexutil.Uint64(idx)
+	}
+	return addr
+}
+
+func (f *BrontesFrame) Subtraces() hexutil.Uint64 {
+	return hexutil.Uint64(f.frame.Trace.Subtraces)
+}
+
+func (f *BrontesFrame) Type() string {
+	return string(f.frame.Trace.Type)
+}
+
+func (f *BrontesFrame) CallType() *string {
+	if call := f.call(); call != nil {
+		kind := string(call.CallType)
+		return &kind
+	}
+	return nil
+}
+
+func (f *BrontesFrame) MsgSender() common.Address {
+	return f.frame.MsgSender
+}
+
+func (f *BrontesFrame) From() *common.Address {
+	action := f.frame.Trace.Action
+	if action == nil {
+		return nil
+	}
+	from := action.GetFromAddr()
+	return &from
+}
+
+// To returns the callee of calls, the created contract of creates and the
+// beneficiary of selfdestructs.
+func (f *BrontesFrame) To() *common.Address {
+	var (
+		action = f.frame.Trace.Action
+		result = f.frame.Trace.Result
+	)
+	switch {
+	case action == nil:
+		return nil
+	case action.Call != nil:
+		return &action.Call.To
+	case action.SelfDestruct != nil:
+		return &action.SelfDestruct.RefundAddress
+	case result != nil && result.Create != nil:
+		return &result.Create.Address
+	}
+	return nil
+}
+
+func (f *BrontesFrame) Value() *hexutil.Big {
+	action := f.frame.Trace.Action
+	switch {
+	case action == nil:
+		return nil
+	case action.Call != nil:
+		value := bigOrZero(action.Call.Value)
+		return &value
+	case action.Create != nil:
+		value := bigOrZero(action.Create.Value)
+		return &value
+	case action.SelfDestruct != nil:
+		value := bigOrZero(action.SelfDestruct.Balance)
+		return &value
+	}
+	return nil
+}
+
+func (f *BrontesFrame) Gas() *hexutil.Uint64 {
+	action := f.frame.Trace.Action
+	switch {
+	case action == nil:
+		return nil
+	case action.Call != nil:
+		gas := hexutil.Uint64(action.Call.Gas)
+		return &gas
+	case action.Create != nil:
+		gas := hexutil.Uint64(action.Create.Gas)
+		return &gas
+	}
+	return nil
+}
+
+// Input returns the call data of calls and the init code of creates.
+func (f *BrontesFrame) Input() *hexutil.Bytes {
+	action := f.frame.Trace.Action
+	switch {
+	case action == nil:
+		return nil
+	case action.Call != nil:
+		return &action.Call.Input
+	case action.Create != nil:
+		return &action.Create.Init
+	}
+	return nil
+}
+
+// Output returns the return data of calls and the deployed code of creates.
+func (f *BrontesFrame) Output() *hexutil.Bytes {
+	result := f.frame.Trace.Result
+	switch {
+	case result == nil:
+		return nil
+	case result.Call != nil:
+		return &result.Call.Output
+	case result.Create != nil:
+		return &result.Create.Code
+	}
+	return nil
+}
+
+func (f *BrontesFrame) GasUsed() *hexutil.Uint64 {
+	result := f.frame.Trace.Result
+	switch {
+	case result == nil:
+		return nil
+	case result.Call != nil:
+		gas := hexutil.Uint64(result.Call.GasUsed)
+		return &gas
+	case result.Create != nil:
+		gas := hexutil.Uint64(result.Create.GasUsed)
+		return &gas
+	}
+	return nil
+}
+
+func (f *BrontesFrame) Error() *string {
+	return f.frame.Trace.Error
+}
+
+func (f *BrontesFrame) Logs() []*BrontesLog {
+	logs := make([]*BrontesLog, len(f.frame.Logs))
+	for i := range f.frame.Logs {
+		logs[i] = &BrontesLog{
+			address: f.frame.Logs[i].Address,
+			topics:  f.frame.Logs[i].Topics,
+			data:    f.frame.Logs[i].Data,
+		}
+	}
+	return logs
+}
+
+func (f *BrontesFrame) call() *brontes.CallAction {
+	if f.frame.Trace.Action == nil {
+		return nil
+	}
+	return f.frame.Trace.Action.Call
+}
+
+// BrontesLog is a log emitted by a call frame.
+type BrontesLog struct {
+	address common.Address
+	topics  []common.Hash
+	data    hexutil.Bytes
+}
+
+func (l *BrontesLog) Address() common.Address {
+	return l.address
+}
+
+func (l *BrontesLog) Topics() []common.Hash {
+	return l.topics
+}
+
+func (l *BrontesLog) Data() hexutil.Bytes {
+	return l.data
+}
+
+// BrontesTrace traces the transaction with the brontes inspector. Pending
+// transactions have no trace.
+func (t *Transaction) BrontesTrace(ctx context.Context) (*BrontesTrace, error) {
+	_, block := t.resolve(ctx)
+	if block == nil {
+		return nil, nil
+	}
+	api, err := t.r.brontesAPI()
+	if err != nil {
+		return nil, err
+	}
+	result, err := api.TraceTransaction(ctx, t.hash, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBrontesTrace(result)
+}
+
+// BrontesTraces traces all transactions of the block with the brontes
+// inspector.
+func (b *Block) BrontesTraces(ctx context.Context) ([]*BrontesTrace, error) {
+	hash, err := b.Hash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	api, err := b.r.brontesAPI()
+	if err != nil {
+		return nil, err
+	}
+	results, err := api.TraceBlockByHash(ctx, hash, nil)
+	if err != nil {
+		return nil, err
+	}
+	traces := make([]*BrontesTrace, len(results))
+	for i, res := range results {
+		if res.Error != "" {
+			return nil, fmt.Errorf("failed to trace transaction %s: %s", res.TxHash, res.Error)
+		}
+		if traces[i], err = decodeBrontesTrace(res.Result); err != nil {
+			return nil, err
+		}
+	}
+	return traces, nil
+}
+
+func bigOrZero(v *big.Int) hexutil.Big {
+	if v == nil {
+		return hexutil.Big{}
+	}
+	return hexutil.Big(*v)
+}
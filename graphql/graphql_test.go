@@ -19,6 +19,7 @@ package graphql
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -38,6 +39,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 
@@ -476,7 +478,8 @@ func newGQLService(t *testing.T, stack *node.Node, shanghai bool, gspec *core.Ge
 	if err != nil {
 		t.Fatalf("could not create import blocks: %v", err)
 	}
-	// Set up handler
+	// Set up handler, with the brontes API the node registers
+	tracers.BrontesAPIs(ethBackend.APIBackend, nil)
 	filterSystem := filters.NewFilterSystem(ethBackend.APIBackend, filters.Config{})
 	handler, err := newHandler(stack, ethBackend.APIBackend, filterSystem, []string{}, []string{})
 	if err != nil {
@@ -484,3 +487,80 @@ func newGQLService(t *testing.T, stack *node.Node, shanghai bool, gspec *core.Ge
 	}
 	return handler, chain
 }
+
+func TestGraphQLBrontesTraces(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000000000)
+		dad     = common.HexToAddress("0x0000000000000000000000000000000000000dad")
+	)
+	stack := createNode(t)
+	defer stack.Close()
+	genesis := &core.Genesis{
+		Config:     params.AllEthashProtocolChanges,
+		GasLimit:   11500000,
+		Difficulty: big.NewInt(1048576),
+		Alloc: types.GenesisAlloc{
+			address: {Balance: funds},
+			// The address 0xdad sloads 0x00 and 0x01
+			dad: {
+				Code:    []byte{byte(vm.PC), byte(vm.PC), byte(vm.SLOAD), byte(vm.SLOAD)},
+				Nonce:   0,
+				Balance: big.NewInt(0),
+			},
+		},
+		BaseFee: big.NewInt(params.InitialBaseFee),
+	}
+	signer := types.LatestSigner(genesis.Config)
+	tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+		Nonce:    uint64(0),
+		To:       &dad,
+		Value:    big.NewInt(100),
+		Gas:      50000,
+		GasPrice: big.NewInt(params.InitialBaseFee),
+	})
+	newGQLService(t, stack, false, genesis, 1, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{1})
+		gen.AddTx(tx)
+	})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+
+	frame := `{"traceAddress":[],"type":"call","callType":"call","from":"0x71562b71999873db5b286df957af199ec94617f7","to":"0x0000000000000000000000000000000000000dad","value":"0x64","logs":[]}`
+	for i, tt := range []struct {
+		body string
+		want string
+	}{
+		{
+			body: `{"query": "{block {brontesTraces { txIndex isSuccess frames { traceAddress type callType from to value logs { address } }}}}"}`,
+			want: `{"data":{"block":{"brontesTraces":[{"txIndex":"0x0","isSuccess":true,"frames":[` + frame + `]}]}}}`,
+		},
+		{
+			body: fmt.Sprintf(`{"query": "{transaction(hash: \"%s\") { brontesTrace { txHash frames { traceAddress type callType from to value logs { address } }}}}"}`, tx.Hash()),
+			want: fmt.Sprintf(`{"data":{"transaction":{"brontesTrace":{"txHash":"%s","frames":[%s]}}}}`, tx.Hash(), frame),
+		},
+	} {
+		resp, err := http.Post(fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("could not post: %v", err)
+		}
+		bodyBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("could not read from response body: %v", err)
+		}
+		if have := string(bodyBytes); have != tt.want {
+			t.Errorf("testcase %d %s,\nhave:\n%v\nwant:\n%v", i, tt.body, have, tt.want)
+		}
+	}
+}
+
+func TestGraphQLBrontesAuthenticated(t *testing.T) {
+	// An API restricted to the authenticated endpoint is not served by GraphQL.
+	tracers.BrontesAPIs(nil, &tracers.BrontesConfig{Authenticated: true})
+	if _, err := new(Resolver).brontesAPI(); !errors.Is(err, errTracingAuthenticated) {
+		t.Fatalf("have error %v, want %v", err, errTracingAuthenticated)
+	}
+}
@@ -89,6 +89,56 @@ const schema string = `
         amount: Long!
     }
 
+    # BrontesLog is a log emitted by a call frame of a brontes trace.
+    type BrontesLog {
+        address: Address!
+        topics: [Bytes32!]!
+        data: Bytes!
+    }
+
+    # BrontesFrame is a call frame of a brontes trace.
+    type BrontesFrame {
+        # TraceIdx is the index of the frame in execution order.
+        traceIdx: Long!
+        # TraceAddress is the path of the frame in the call tree.
+        traceAddress: [Long!]!
+        # Subtraces is the number of direct subcalls of the frame.
+        subtraces: Long!
+        # Type is one of call, create or selfdestruct.
+        type: String!
+        # CallType is the kind of call, null for creates and selfdestructs.
+        callType: String
+        # MsgSender is the msg.sender of the frame.
+        msgSender: Address!
+        from: Address
+        # To is the callee of calls, the created contract of creates and the
+        # beneficiary of selfdestructs.
+        to: Address
+        value: BigInt
+        gas: Long
+        # Input is the call data of calls and the init code of creates.
+        input: Bytes
+        # Output is the return data of calls and the deployed code of creates.
+        output: Bytes
+        gasUsed: Long
+        # Error is the failure reason of reverted frames.
+        error: String
+        # Logs are the logs emitted by the frame itself.
+        logs: [BrontesLog!]!
+    }
+
+    # BrontesTrace is the call tree of a transaction recorded by the brontes
+    # inspector.
+    type BrontesTrace {
+        txHash: Bytes32!
+        txIndex: Long!
+        gasUsed: BigInt!
+        effectivePrice: BigInt!
+        isSuccess: Boolean!
+        # Frames lists the call frames in execution order.
+        frames: [BrontesFrame!]!
+    }
+
     # Transaction is an Ethereum transaction.
     type Transaction {
         # Hash is the hash of this transaction.
@@ -170,6 +220,9 @@ const schema string = `
         rawReceipt: Bytes!
         # BlobVersionedHashes is a set of hash outputs from the blobs in the transaction.
         blobVersionedHashes: [Bytes32!]
+        # BrontesTrace re-executes the transaction with the brontes inspector.
+        # This will be null if the transaction has not yet been mined.
+        brontesTrace: BrontesTrace
     }
 
     # BlockFilterCriteria encapsulates log filter criteria for a filter applied
@@ -276,6 +329,9 @@ const schema string = `
         blobGasUsed: Long
         # ExcessBlobGas is a running total of blob gas consumed in excess of the target, prior to the block.
         excessBlobGas: Long
+        # BrontesTraces re-executes the transactions of the block with the
+        # brontes inspector.
+        brontesTraces: [BrontesTrace!]!
     }
 
     # CallData represents the data associated with a local contract call.
@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -677,7 +678,7 @@ func (context *ChainContext) Config() *params.ChainConfig {
 	return context.b.ChainConfig()
 }
 
-func doCall(ctx context.Context, b Backend, args TransactionArgs, state *state.StateDB, header *types.Header, overrides *override.StateOverride, blockOverrides *override.BlockOverrides, timeout time.Duration, globalGasCap uint64, runMode core.MessageRunMode) (*core.ExecutionResult, error) {
+func doCall(ctx context.Context, b Backend, args TransactionArgs, state *state.StateDB, header *types.Header, overrides *override.StateOverride, blockOverrides *override.BlockOverrides, timeout time.Duration, globalGasCap uint64, runMode core.MessageRunMode, tracer *tracing.Hooks) (*core.ExecutionResult, error) {
 	blockCtx := core.NewEVMBlockContext(header, NewChainContext(ctx, b), nil)
 	if blockOverrides != nil {
 		blockOverrides.Apply(&blockCtx)
@@ -705,19 +706,19 @@ func doCall(ctx context.Context, b Backend, args TransactionArgs, state *state.S
 	} else {
 		gp.AddGas(globalGasCap)
 	}
-	return applyMessage(ctx, b, args, state, header, timeout, gp, &blockCtx, &vm.Config{NoBaseFee: true}, precompiles, true, runMode)
+	return applyMessage(ctx, b, args, state, header, timeout, gp, &blockCtx, &vm.Config{NoBaseFee: true, Tracer: tracer}, precompiles, true, runMode)
 }
 
-func applyMessage(ctx context.Context, b Backend, args TransactionArgs, state *state.StateDB, header *types.Header, timeout time.Duration, gp *core.GasPool, blockContext *vm.BlockContext, vmConfig *vm.Config, precompiles vm.PrecompiledContracts, skipChecks bool, runMode core.MessageRunMode) (*core.ExecutionResult, error) {
+func applyMessage(ctx context.Context, b Backend, args TransactionArgs, statedb *state.StateDB, header *types.Header, timeout time.Duration, gp *core.GasPool, blockContext *vm.BlockContext, vmConfig *vm.Config, precompiles vm.PrecompiledContracts, skipChecks bool, runMode core.MessageRunMode) (*core.ExecutionResult, error) {
 	// Get a new instance of the EVM.
 	if err := args.CallDefaults(gp.Gas(), blockContext.BaseFee, b.ChainConfig().ChainID); err != nil {
 		return nil, err
 	}
-	msg := args.ToMessage(blockContext.BaseFee, gp.Gas(), header, state, runMode, skipChecks, skipChecks)
+	msg := args.ToMessage(blockContext.BaseFee, gp.Gas(), header, statedb, runMode, skipChecks, skipChecks)
 
 	// Arbitrum: raise the gas cap to ignore L1 costs so that it's compute-only
 	if gp.Gas() > 0 {
-		postingGas, err := core.RPCPostingGasHook(msg, header, state)
+		postingGas, err := core.RPCPostingGasHook(msg, header, statedb)
 		if err != nil {
 			return nil, err
 		}
@@ -731,7 +732,7 @@ func applyMessage(ctx context.Context, b Backend, args TransactionArgs, state *s
 	// Arbitrum: support NodeInterface.sol by swapping out the message if needed
 	var res *core.ExecutionResult
 	var err error
-	msg, res, err = core.InterceptRPCMessage(msg, ctx, state, header, b, blockContext)
+	msg, res, err = core.InterceptRPCMessage(msg, ctx, statedb, header, b, blockContext)
 	if err != nil || res != nil {
 		return res, err
 	}
@@ -744,14 +745,32 @@ func applyMessage(ctx context.Context, b Backend, args TransactionArgs, state *s
 	if msg.BlobGasFeeCap != nil && msg.BlobGasFeeCap.BitLen() == 0 {
 		blockContext.BlobBaseFee = new(big.Int)
 	}
-	evm := b.GetEVM(ctx, state, header, vmConfig, blockContext)
+	evm := b.GetEVM(ctx, statedb, header, vmConfig, blockContext)
 	if precompiles != nil {
 		evm.SetPrecompiles(precompiles)
 	}
-	res, err = applyMessageWithEVM(ctx, evm, msg, state, timeout, gp, b, header, *blockContext)
+	tracer := vmConfig.Tracer
+	if tracer != nil {
+		// The state changes are reported to the tracer as well.
+		evm.StateDB = state.NewHookedState(statedb, tracer)
+		if tracer.OnTxStart != nil {
+			tracer.OnTxStart(evm.GetVMContext(), args.ToTransaction(types.LegacyTxType), msg.From)
+		}
+	}
+	res, err = applyMessageWithEVM(ctx, evm, msg, statedb, timeout, gp, b, header, *blockContext)
+	if tracer != nil && tracer.OnTxEnd != nil {
+		var receipt *types.Receipt
+		if res != nil {
+			receipt = &types.Receipt{GasUsed: res.UsedGas, Status: types.ReceiptStatusSuccessful}
+			if res.Failed() {
+				receipt.Status = types.ReceiptStatusFailed
+			}
+		}
+		tracer.OnTxEnd(receipt, err)
+	}
 	// If an internal state error occurred, let that have precedence. Otherwise,
 	// a "trie root missing" type of error will masquerade as e.g. "insufficient gas"
-	if err := state.Error(); err != nil {
+	if err := statedb.Error(); err != nil {
 		return nil, err
 	}
 	return res, err
@@ -841,6 +860,13 @@ func updateHeaderForPendingBlocks(blockNrOrHash rpc.BlockNumberOrHash, header *t
 }
 
 func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *override.StateOverride, blockOverrides *override.BlockOverrides, timeout time.Duration, globalGasCap uint64, runMode core.MessageRunMode) (*core.ExecutionResult, error) {
+	return DoCallWithTracer(ctx, b, args, blockNrOrHash, overrides, blockOverrides, timeout, globalGasCap, runMode, nil)
+}
+
+// DoCallWithTracer executes the call like DoCall, running the given tracer
+// over it if not nil. The trace is taken from the very execution producing
+// the result, so it cannot drift from what eth_call returns.
+func DoCallWithTracer(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *override.StateOverride, blockOverrides *override.BlockOverrides, timeout time.Duration, globalGasCap uint64, runMode core.MessageRunMode, tracer *tracing.Hooks) (*core.ExecutionResult, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
 	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
@@ -848,7 +874,7 @@ func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash
 		return nil, err
 	}
 	header = updateHeaderForPendingBlocks(blockNrOrHash, header)
-	return doCall(ctx, b, args, state, header, overrides, blockOverrides, timeout, globalGasCap, runMode)
+	return doCall(ctx, b, args, state, header, overrides, blockOverrides, timeout, globalGasCap, runMode, tracer)
 }
 
 // Call executes the given transaction on the state for the given block number.
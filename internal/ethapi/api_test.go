@@ -49,6 +49,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -1174,6 +1175,62 @@ func TestCall(t *testing.T) {
 	}
 }
 
+func TestDoCallWithTracer(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(1)
+		contract = common.HexToAddress("0x0000000000000000000000000000000000000dad")
+		genesis  = &core.Genesis{
+			Config: params.MergedTestChainConfig,
+			Alloc: types.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+				// SSTORE(0, 1); RETURN(0, 0)
+				contract: {Code: common.FromHex("0x600160005560006000f3")},
+			},
+		}
+	)
+	backend := newTestBackend(t, 1, genesis, beacon.New(ethash.NewFaker()), func(i int, b *core.BlockGen) {
+		b.SetPoS()
+	})
+	var (
+		started, ended bool
+		frames         int
+		storage        []common.Hash
+	)
+	tracer := &tracing.Hooks{
+		OnTxStart: func(vm *tracing.VMContext, tx *types.Transaction, from common.Address) {
+			started = from == accounts[0].addr
+		},
+		OnEnter: func(depth int, typ byte, from, to common.Address, input []byte, gas uint64, value *big.Int) {
+			frames++
+		},
+		OnStorageChange: func(addr common.Address, slot, prev, new common.Hash) {
+			storage = append(storage, new)
+		},
+		OnTxEnd: func(receipt *types.Receipt, err error) {
+			ended = err == nil && receipt != nil && receipt.Status == types.ReceiptStatusSuccessful
+		},
+	}
+	args := TransactionArgs{From: &accounts[0].addr, To: &contract}
+	result, err := DoCallWithTracer(context.Background(), backend, args, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), nil, nil, 0, 0, core.MessageEthcallMode, tracer)
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if result.Failed() {
+		t.Fatalf("call reverted: %v", result.Err)
+	}
+	if !started || !ended {
+		t.Errorf("transaction hooks not run: started %v, ended %v", started, ended)
+	}
+	if frames != 1 {
+		t.Errorf("frame count mismatch: have %d, want 1", frames)
+	}
+	if want := []common.Hash{common.BigToHash(big.NewInt(1))}; !reflect.DeepEqual(storage, want) {
+		t.Errorf("storage changes mismatch: have %v, want %v", storage, want)
+	}
+}
+
 func TestSimulateV1(t *testing.T) {
 	t.Parallel()
 	// Initialize test accounts